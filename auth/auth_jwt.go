@@ -0,0 +1,118 @@
+// auth/auth_jwt.go
+// JWT 令牌工具 - 详细注释版
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ====== JWT 基础 ======
+/*
+JWT（JSON Web Token）是一种无状态的认证令牌格式。
+
+结构：header.payload.signature
+  - header: 签名算法，如 {"alg":"HS256","typ":"JWT"}
+  - payload: 声明（claims），如用户 ID、过期时间
+  - signature: 对前两部分的签名，防止篡改
+
+Gin 和 Echo 的认证中间件共用本包，避免重复实现。
+
+安装：
+  go get -u github.com/golang-jwt/jwt/v5
+
+签名算法：
+  目前只支持 HS256（对称密钥，签发和验证使用同一个 secret）。
+
+  以后需要 RS256（非对称密钥）时：
+  1. 签发：jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(privateKey)
+     privateKey 由 jwt.ParseRSAPrivateKeyFromPEM 加载
+  2. 验证：keyFunc 中检查 token.Method 是 *jwt.SigningMethodRSA，返回 publicKey
+     publicKey 由 jwt.ParseRSAPublicKeyFromPEM 加载
+  3. 把 secret []byte 参数换成 interface{} 或单独提供 GenerateTokenRS256/ParseTokenRS256
+*/
+
+// ====== 错误定义 ======
+
+var (
+	// ErrTokenExpired 令牌已过期
+	ErrTokenExpired = errors.New("token expired")
+
+	// ErrInvalidSignature 签名无效（密钥错误或令牌被篡改）
+	ErrInvalidSignature = errors.New("invalid token signature")
+
+	// ErrTokenMalformed 令牌格式错误
+	ErrTokenMalformed = errors.New("malformed token")
+)
+
+// ====== 声明 ======
+
+// Claims 自定义声明
+// 嵌入 jwt.RegisteredClaims 获得 exp、iat、sub 等标准字段
+type Claims struct {
+	UserID uint     `json:"user_id"` // 用户 ID
+	Roles  []string `json:"roles"`   // 用户角色
+	jwt.RegisteredClaims
+}
+
+// HasRole 检查是否拥有指定角色
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ====== 签发与解析 ======
+
+// GenerateToken 签发令牌
+// ttl 为有效期，会覆盖 claims 中的 ExpiresAt 和 IssuedAt
+func GenerateToken(claims Claims, secret []byte, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+	// 使用 HS256 签名
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(secret)
+	if err != nil {
+		return "", fmt.Errorf("签发令牌失败: %w", err)
+	}
+
+	return signed, nil
+}
+
+// ParseToken 解析并验证令牌
+// 过期返回 ErrTokenExpired，签名错误返回 ErrInvalidSignature，
+// 格式错误返回 ErrTokenMalformed
+func ParseToken(token string, secret []byte) (*Claims, error) {
+	claims := &Claims{}
+
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		// 必须检查算法，防止 alg=none 等攻击
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	if err != nil {
+		// 把 jwt 库的错误映射为本包的哨兵错误
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, ErrTokenExpired
+		case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+			return nil, ErrInvalidSignature
+		default:
+			return nil, fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+		}
+	}
+
+	return claims, nil
+}
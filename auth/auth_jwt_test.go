@@ -0,0 +1,77 @@
+// auth/auth_jwt_test.go
+// JWT 令牌工具的测试
+
+package auth
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testSecret = []byte("test-secret")
+
+func TestGenerateParseRoundTrip(t *testing.T) {
+	in := Claims{
+		UserID:           42,
+		Roles:            []string{"admin", "editor"},
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "alice"},
+	}
+	token, err := GenerateToken(in, testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+
+	got, err := ParseToken(token, testSecret)
+	if err != nil {
+		t.Fatalf("ParseToken() error = %v", err)
+	}
+	if got.UserID != 42 || !slices.Equal(got.Roles, in.Roles) || got.Subject != "alice" {
+		t.Errorf("ParseToken() = %+v, want %+v", got, in)
+	}
+	if got.ExpiresAt == nil || time.Until(got.ExpiresAt.Time) <= 59*time.Minute {
+		t.Errorf("ExpiresAt = %v, want 约 1 小时后", got.ExpiresAt)
+	}
+	if !got.HasRole("editor") || got.HasRole("owner") {
+		t.Errorf("HasRole() 结果错误，roles = %v", got.Roles)
+	}
+}
+
+func TestParseTokenErrors(t *testing.T) {
+	valid, _ := GenerateToken(Claims{UserID: 1}, testSecret, time.Hour)
+	expired, _ := GenerateToken(Claims{UserID: 1}, testSecret, -time.Minute)
+	// alg=none 的令牌必须被拒绝
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, Claims{UserID: 1}).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	// HS512 不在允许的算法列表中
+	hs512, _ := jwt.NewWithClaims(jwt.SigningMethodHS512, Claims{UserID: 1}).SignedString(testSecret)
+
+	tests := []struct {
+		name   string
+		token  string
+		secret []byte
+		want   error
+	}{
+		{"已过期", expired, testSecret, ErrTokenExpired},
+		{"密钥错误", valid, []byte("other-secret"), ErrInvalidSignature},
+		{"签名被篡改", valid[:len(valid)-2] + "xx", testSecret, ErrInvalidSignature},
+		{"不是 JWT", "not-a-token", testSecret, ErrTokenMalformed},
+		{"空字符串", "", testSecret, ErrTokenMalformed},
+		{"alg=none", none, testSecret, ErrInvalidSignature},
+		{"不允许的算法", hs512, testSecret, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseToken(tt.token, tt.secret)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ParseToken() error = %v, want %v", err, tt.want)
+			}
+			if claims != nil {
+				t.Errorf("ParseToken() claims = %+v, want nil", claims)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// bind/bind_request.go
+// net/http 请求绑定 - 详细注释版
+
+package bind
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/austoin/GolangTutorial/validate"
+)
+
+// ====== 请求绑定基础 ======
+/*
+Gin 的 ShouldBind、Echo 的 c.Bind 会根据 Content-Type 把请求填充到结构体，
+原生 net/http 只有 r.Body、r.Form、r.URL.Query()，需要手动解析和转换。
+
+bind 包提供同样的能力：
+
+  type SearchRequest struct {
+      Keyword string   `query:"q" validate:"required"`
+      Page    int      `query:"page"`
+      Tags    []string `query:"tag"` // ?tag=a&tag=b
+  }
+
+  var req SearchRequest
+  if err := bind.Query(r, &req); err != nil {
+      // *bind.Error 或 validate.ValidationErrors
+  }
+
+  bind.JSON(r, &req)  // 按 json 标签，使用 encoding/json
+  bind.Form(r, &req)  // 按 form 标签，支持 urlencoded 和 multipart
+  bind.Body(r, &req)  // 根据 Content-Type 选择 JSON 或 Form
+
+错误分两类：
+  - *bind.Error：请求本身有问题（格式错误、类型不匹配、过大），Status 为建议的状态码
+  - validate.ValidationErrors：绑定成功但校验失败（字段带 validate 标签时才会校验）
+
+dest 不是结构体指针属于调用方的代码错误，返回普通 error。
+*/
+
+// ====== 错误类型 ======
+
+// DefaultMaxBodySize 请求体默认上限
+const DefaultMaxBodySize = 1 << 20 // 1MB
+
+// maxMultipartMemory multipart 表单保存在内存中的上限，超出部分写入临时文件
+const maxMultipartMemory = 8 << 20
+
+// Error 请求绑定失败
+type Error struct {
+	Status  int    // 建议的 HTTP 状态码：400、413 或 415
+	Message string // 可以直接返回给客户端的错误信息
+	Err     error  // 原始错误
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap 返回原始错误
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// badRequest 创建 400 错误
+func badRequest(err error, format string, args ...interface{}) *Error {
+	return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// ====== 绑定入口 ======
+
+// JSON 把 JSON 请求体解码到 dest，然后校验
+// Content-Type 存在且不是 application/json 时返回 415
+func JSON(r *http.Request, dest interface{}) error {
+	if err := checkContentType(r, "application/json"); err != nil {
+		return err
+	}
+	if err := checkDest(dest); err != nil {
+		return err
+	}
+
+	body := http.MaxBytesReader(nil, r.Body, DefaultMaxBodySize)
+	if err := json.NewDecoder(body).Decode(dest); err != nil {
+		return jsonDecodeError(err)
+	}
+	return validate.Struct(dest)
+}
+
+// Form 按 form 标签把表单字段填充到 dest，然后校验
+// 支持 application/x-www-form-urlencoded 和 multipart/form-data，只读取请求体中的字段
+func Form(r *http.Request, dest interface{}) error {
+	if err := checkContentType(r, "application/x-www-form-urlencoded", "multipart/form-data"); err != nil {
+		return err
+	}
+	if err := checkDest(dest); err != nil {
+		return err
+	}
+
+	var err error
+	r.Body = http.MaxBytesReader(nil, r.Body, DefaultMaxBodySize)
+	if isMultipart(r) {
+		err = r.ParseMultipartForm(maxMultipartMemory)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return bodyTooLarge(tooLarge)
+		}
+		return badRequest(err, "Invalid form body")
+	}
+
+	if err := decodeValues(r.PostForm, "form", dest); err != nil {
+		return err
+	}
+	return validate.Struct(dest)
+}
+
+// Query 按 query 标签把查询参数填充到 dest，然后校验
+func Query(r *http.Request, dest interface{}) error {
+	if err := checkDest(dest); err != nil {
+		return err
+	}
+	if err := decodeValues(r.URL.Query(), "query", dest); err != nil {
+		return err
+	}
+	return validate.Struct(dest)
+}
+
+// Body 根据 Content-Type 选择 JSON 或 Form
+// 没有 Content-Type 时按 JSON 处理
+func Body(r *http.Request, dest interface{}) error {
+	switch mediaType(r) {
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		return Form(r, dest)
+	default:
+		return JSON(r, dest)
+	}
+}
+
+// ====== 内部实现 ======
+
+// mediaType 返回去掉参数（如 charset）后的 Content-Type
+func mediaType(r *http.Request) string {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return ct
+	}
+	return mt
+}
+
+// isMultipart 是否为 multipart 表单
+func isMultipart(r *http.Request) bool {
+	return mediaType(r) == "multipart/form-data"
+}
+
+// checkContentType Content-Type 存在时必须是 allowed 之一
+func checkContentType(r *http.Request, allowed ...string) error {
+	mt := mediaType(r)
+	if mt == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if mt == a {
+			return nil
+		}
+	}
+	return &Error{
+		Status:  http.StatusUnsupportedMediaType,
+		Message: fmt.Sprintf("Unsupported Content-Type %q", mt),
+	}
+}
+
+// jsonDecodeError 把 encoding/json 的错误归类为明确的错误信息
+func jsonDecodeError(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		tooLarge  *http.MaxBytesError
+	)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return badRequest(err, "Request body required")
+	case errors.As(err, &tooLarge):
+		return bodyTooLarge(tooLarge)
+	case errors.As(err, &syntaxErr):
+		return badRequest(err, "Invalid JSON at offset %d", syntaxErr.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest(err, "Invalid JSON: unexpected end of input")
+	case errors.As(err, &typeErr):
+		return badRequest(err, "Invalid type for field %q: expected %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	}
+	return badRequest(err, "Invalid JSON body")
+}
+
+// bodyTooLarge 413 错误
+func bodyTooLarge(err *http.MaxBytesError) *Error {
+	return &Error{
+		Status:  http.StatusRequestEntityTooLarge,
+		Message: fmt.Sprintf("Request body exceeds %d bytes", err.Limit),
+		Err:     err,
+	}
+}
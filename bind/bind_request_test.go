@@ -0,0 +1,178 @@
+// bind/bind_request_test.go
+// 请求绑定的测试
+
+package bind
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/austoin/GolangTutorial/validate"
+)
+
+type createUserRequest struct {
+	Username string `json:"username" form:"username" validate:"required,min=3"`
+	Age      int    `json:"age" form:"age"`
+}
+
+// newRequest 创建带 Content-Type 的 POST 请求，contentType 为空时不设置
+func newRequest(contentType, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/users?username=fromquery", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+// checkBindError 断言 err 是给定状态码的 *Error，且消息包含 wantMsg
+func checkBindError(t *testing.T, err error, wantStatus int, wantMsg string) {
+	t.Helper()
+	var be *Error
+	if !errors.As(err, &be) {
+		t.Fatalf("error = %v (%T), want *bind.Error", err, err)
+	}
+	if be.Status != wantStatus || !strings.Contains(be.Message, wantMsg) {
+		t.Errorf("Error = %d %q, want %d 且包含 %q", be.Status, be.Message, wantStatus, wantMsg)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int    // 0 表示成功
+		wantMsg     string // 错误信息需要包含的内容
+	}{
+		{"合法请求", "application/json", `{"username":"alice","age":30}`, 0, ""},
+		{"带 charset", "application/json; charset=utf-8", `{"username":"alice"}`, 0, ""},
+		{"没有 Content-Type 按 JSON 处理", "", `{"username":"alice"}`, 0, ""},
+		{"空请求体", "application/json", ``, http.StatusBadRequest, "Request body required"},
+		{"语法错误", "application/json", `{"username":}`, http.StatusBadRequest, "Invalid JSON at offset"},
+		{"请求体被截断", "application/json", `{"username":"alice"`, http.StatusBadRequest, "unexpected end of input"},
+		{"类型不匹配", "application/json", `{"username":"alice","age":"thirty"}`, http.StatusBadRequest, `field "age": expected int, got string`},
+		{"请求体过大", "application/json", `{"username":"` + strings.Repeat("a", DefaultMaxBodySize) + `"}`, http.StatusRequestEntityTooLarge, "exceeds"},
+		{"不支持的 Content-Type", "text/plain", `{"username":"alice"}`, http.StatusUnsupportedMediaType, `"text/plain"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req createUserRequest
+			err := JSON(newRequest(tt.contentType, tt.body), &req)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("JSON() error = %v", err)
+				}
+				if req.Username != "alice" {
+					t.Errorf("Username = %q, want alice", req.Username)
+				}
+				return
+			}
+			checkBindError(t, err, tt.wantStatus, tt.wantMsg)
+		})
+	}
+}
+
+func TestJSONValidation(t *testing.T) {
+	var req createUserRequest
+	err := JSON(newRequest("application/json", `{"username":"al"}`), &req)
+
+	// 绑定成功但校验失败，返回校验错误而不是 *Error
+	var ve validate.ValidationErrors
+	if !errors.As(err, &ve) || len(ve) != 1 || ve[0].Field != "username" {
+		t.Errorf("JSON() error = %v, want username 的校验错误", err)
+	}
+	var be *Error
+	if errors.As(err, &be) {
+		t.Error("校验错误不应该是 *bind.Error")
+	}
+}
+
+func TestForm(t *testing.T) {
+	t.Run("urlencoded", func(t *testing.T) {
+		var req createUserRequest
+		err := Form(newRequest("application/x-www-form-urlencoded", "username=alice&age=30"), &req)
+		if err != nil || req.Username != "alice" || req.Age != 30 {
+			t.Errorf("Form() = %+v, %v, want alice 30", req, err)
+		}
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		mw.WriteField("username", "bob")
+		mw.WriteField("age", "25")
+		mw.Close()
+
+		var req createUserRequest
+		err := Form(newRequest(mw.FormDataContentType(), buf.String()), &req)
+		if err != nil || req.Username != "bob" || req.Age != 25 {
+			t.Errorf("Form() = %+v, %v, want bob 25", req, err)
+		}
+	})
+
+	t.Run("只读取请求体中的字段", func(t *testing.T) {
+		// URL 中有 username=fromquery，请求体中没有
+		var req createUserRequest
+		err := Form(newRequest("application/x-www-form-urlencoded", "age=30"), &req)
+		var ve validate.ValidationErrors
+		if !errors.As(err, &ve) || req.Username != "" {
+			t.Errorf("Form() = %+v, %v, want 忽略查询参数并校验失败", req, err)
+		}
+	})
+
+	t.Run("字段类型错误", func(t *testing.T) {
+		var req createUserRequest
+		err := Form(newRequest("application/x-www-form-urlencoded", "username=alice&age=old"), &req)
+		checkBindError(t, err, http.StatusBadRequest, `field "age"`)
+	})
+
+	t.Run("请求体过大", func(t *testing.T) {
+		var req createUserRequest
+		body := "username=" + strings.Repeat("a", DefaultMaxBodySize)
+		err := Form(newRequest("application/x-www-form-urlencoded", body), &req)
+		checkBindError(t, err, http.StatusRequestEntityTooLarge, "exceeds")
+	})
+
+	t.Run("不支持的 Content-Type", func(t *testing.T) {
+		var req createUserRequest
+		err := Form(newRequest("application/json", `{"username":"alice"}`), &req)
+		checkBindError(t, err, http.StatusUnsupportedMediaType, "application/json")
+	})
+}
+
+func TestBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"JSON", "application/json", `{"username":"alice","age":30}`},
+		{"表单", "application/x-www-form-urlencoded", "username=alice&age=30"},
+		{"没有 Content-Type", "", `{"username":"alice","age":30}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req createUserRequest
+			if err := Body(newRequest(tt.contentType, tt.body), &req); err != nil || req != (createUserRequest{"alice", 30}) {
+				t.Errorf("Body() = %+v, %v, want alice 30", req, err)
+			}
+		})
+	}
+}
+
+func TestInvalidDest(t *testing.T) {
+	var notStruct int
+	var nilPtr *createUserRequest
+	for _, dest := range []interface{}{nil, createUserRequest{}, &notStruct, nilPtr} {
+		err := JSON(newRequest("application/json", `{}`), dest)
+		var be *Error
+		if err == nil || errors.As(err, &be) {
+			t.Errorf("JSON(%T) error = %v, want 普通 error", dest, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// bind/bind_values.go
+// 表单与查询参数到结构体的转换 - 详细注释版
+
+package bind
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ====== 字段映射 ======
+/*
+url.Values 是 map[string][]string，需要按标签找到字段并转换类型：
+
+  标签     `form:"age"` / `query:"page"`，没有标签时使用字段名，"-" 表示跳过
+  类型     string、bool、整数、无符号整数、浮点数，以及它们的切片和指针
+  切片     同名参数的所有值：?tag=a&tag=b → []string{"a", "b"}
+  缺省     请求中没有的参数不修改字段，可以在绑定前设置默认值
+
+嵌入的结构体（没有标签时）会展开，字段视为外层字段。
+*/
+
+// checkDest dest 必须是非 nil 的结构体指针
+func checkDest(dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: dest must be a non-nil pointer to struct, got %T", dest)
+	}
+	return nil
+}
+
+// decodeValues 按 tagKey 标签把 values 填充到 dest
+func decodeValues(values url.Values, tagKey string, dest interface{}) error {
+	return decodeStruct(values, tagKey, reflect.ValueOf(dest).Elem())
+}
+
+// decodeStruct 遍历结构体字段
+func decodeStruct(values url.Values, tagKey string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get(tagKey)
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && tag == "" {
+			if err := decodeStruct(values, tagKey, rv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+		vals, ok := values[name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+
+		if err := setField(rv.Field(i), vals); err != nil {
+			return badRequest(err, "Invalid value for field %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// setField 把参数值写入字段，切片使用全部值，其他类型使用第一个值
+func setField(fv reflect.Value, vals []string) error {
+	switch fv.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(fv.Type().Elem())
+		if err := setField(elem.Elem(), vals); err != nil {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+
+	case reflect.Slice:
+		slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, s := range vals {
+			if err := setScalar(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+
+	default:
+		return setScalar(fv, vals[0])
+	}
+}
+
+// setScalar 按字段类型解析单个值
+// 数值按字段的位数解析，超出范围时返回错误
+func setScalar(fv reflect.Value, s string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", s)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return numError(s, "an integer", err)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return numError(s, "a non-negative integer", err)
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return numError(s, "a number", err)
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+// numError 区分格式错误和超出范围
+func numError(s, want string, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("%q is out of range", s)
+	}
+	return fmt.Errorf("%q is not %s", s, want)
+}
@@ -0,0 +1,94 @@
+// bind/bind_values_test.go
+// 查询参数到结构体转换的测试
+
+package bind
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// Paging 嵌入的结构体，字段视为外层字段
+type Paging struct {
+	Page int `query:"page"`
+	Size int `query:"size"`
+}
+
+type searchRequest struct {
+	Paging
+	Keyword  string   `query:"q"`
+	Tags     []string `query:"tag"`
+	IDs      []uint   `query:"id"`
+	Active   *bool    `query:"active"`
+	MinScore float64  `query:"min_score"`
+	Level    int8     `query:"level"`
+	Ignored  string   `query:"-"`
+	Sort     string   // 没有标签时使用字段名
+}
+
+// query 用查询参数绑定 dest
+func query(rawQuery string, dest interface{}) error {
+	return Query(httptest.NewRequest(http.MethodGet, "/search?"+rawQuery, nil), dest)
+}
+
+func TestQuery(t *testing.T) {
+	active := true
+	var got searchRequest
+	err := query("q=go&tag=a&tag=b&id=1&id=2&active=true&min_score=4.5&level=-3&page=2&size=20&Ignored=x&Sort=name", &got)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	want := searchRequest{
+		Paging:   Paging{Page: 2, Size: 20},
+		Keyword:  "go",
+		Tags:     []string{"a", "b"},
+		IDs:      []uint{1, 2},
+		Active:   &active,
+		MinScore: 4.5,
+		Level:    -3,
+		Sort:     "name",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Query() = %+v, want %+v", got, want)
+	}
+}
+
+func TestQueryKeepsDefaults(t *testing.T) {
+	// 请求中没有的参数保持绑定前的值
+	got := searchRequest{Paging: Paging{Page: 1, Size: 10}, Sort: "created_at"}
+	if err := query("q=go", &got); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if got.Page != 1 || got.Size != 10 || got.Sort != "created_at" || got.Active != nil {
+		t.Errorf("Query() = %+v, want 保留默认值", got)
+	}
+}
+
+func TestQueryInvalidValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawQuery string
+		wantMsg  string
+	}{
+		{"整数格式错误", "page=two", `Invalid value for field "page": "two" is not an integer`},
+		{"超出 int8 范围", "level=200", `Invalid value for field "level": "200" is out of range`},
+		{"无符号整数为负数", "id=1&id=-2", `Invalid value for field "id": "-2" is not a non-negative integer`},
+		{"布尔值无效", "active=maybe", `Invalid value for field "active": "maybe" is not a boolean`},
+		{"浮点数无效", "min_score=high", `Invalid value for field "min_score": "high" is not a number`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got searchRequest
+			checkBindError(t, query(tt.rawQuery, &got), http.StatusBadRequest, tt.wantMsg)
+		})
+	}
+}
+
+func TestQueryUnsupportedType(t *testing.T) {
+	var dest struct {
+		Meta map[string]string `query:"meta"`
+	}
+	checkBindError(t, query("meta=x", &dest), http.StatusBadRequest, "unsupported field type")
+}
@@ -0,0 +1,226 @@
+// cache/cache_lru.go
+// 内存 LRU 缓存（支持 TTL）- 详细注释版
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ====== LRU 基础 ======
+/*
+LRU（Least Recently Used，最近最少使用）是最常见的缓存淘汰策略：
+容量满时淘汰最久没有被访问的数据。
+
+实现方式：
+  - 哈希表：O(1) 按 key 查找
+  - 双向链表：按访问顺序排列，表头最新、表尾最旧
+
+过期策略：
+  - 惰性过期：Get 时发现过期才删除
+  - 定期清理：可选的后台 janitor 协程定期扫描删除
+
+使用示例：
+  c := cache.NewLRU[string, int](1000)
+  c.Set("a", 1, time.Minute)
+  v, ok := c.Get("a")
+*/
+
+// entry 链表中存储的数据
+type entry[K comparable, V any] struct {
+	key      K
+	value    V
+	expireAt time.Time // 零值表示永不过期
+}
+
+// expired 判断是否已过期
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && now.After(e.expireAt)
+}
+
+// Stats 缓存统计
+type Stats struct {
+	Hits      int64 // 命中次数
+	Misses    int64 // 未命中次数（包括已过期）
+	Evictions int64 // 因容量淘汰的次数
+	Size      int   // 当前条目数
+}
+
+// LRU 并发安全的 LRU 缓存
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int                 // 最大条目数
+	ll       *list.List          // 双向链表，表头为最近访问
+	items    map[K]*list.Element // key -> 链表节点
+
+	hits      int64
+	misses    int64
+	evictions int64
+
+	stopJanitor chan struct{} // 关闭后 janitor 退出
+	stopOnce    sync.Once
+}
+
+// NewLRU 创建容量为 capacity 的 LRU 缓存
+// capacity <= 0 表示不限容量
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[K]*list.Element),
+	}
+}
+
+// ====== 读写操作 ======
+
+// Get 获取缓存值
+// 命中时把条目移到表头；已过期的条目会被删除并视为未命中
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return zero, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if e.expired(time.Now()) {
+		// 惰性过期
+		c.removeElement(el)
+		c.misses++
+		return zero, false
+	}
+
+	c.ll.MoveToFront(el)
+	c.hits++
+	return e.value, true
+}
+
+// Set 设置缓存值
+// ttl <= 0 表示永不过期；容量满时淘汰最久未访问的条目
+func (c *LRU[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl)
+	}
+
+	// 已存在：更新值并移到表头
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value = value
+		e.expireAt = expireAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	// 新增
+	el := c.ll.PushFront(&entry[K, V]{key: key, value: value, expireAt: expireAt})
+	c.items[key] = el
+
+	// 超出容量时淘汰表尾
+	if c.capacity > 0 && c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+		c.evictions++
+	}
+}
+
+// Delete 删除缓存值，返回是否存在
+func (c *LRU[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	c.removeElement(el)
+	return true
+}
+
+// Len 返回当前条目数（可能包含尚未清理的过期条目）
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Stats 返回统计数据快照
+func (c *LRU[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      c.ll.Len(),
+	}
+}
+
+// removeElement 删除链表节点，调用方需持有 c.mu
+func (c *LRU[K, V]) removeElement(el *list.Element) {
+	e := c.ll.Remove(el).(*entry[K, V])
+	delete(c.items, e.key)
+}
+
+// ====== 后台清理 ======
+
+// StartJanitor 启动后台协程，每隔 interval 删除过期条目
+// 只需调用一次，使用 Stop 停止
+func (c *LRU[K, V]) StartJanitor(interval time.Duration) {
+	c.mu.Lock()
+	if c.stopJanitor != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.stopJanitor = make(chan struct{})
+	stop := c.stopJanitor
+	c.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.deleteExpired()
+			}
+		}
+	}()
+}
+
+// Stop 停止后台清理协程
+func (c *LRU[K, V]) Stop() {
+	c.mu.Lock()
+	stop := c.stopJanitor
+	c.mu.Unlock()
+
+	if stop != nil {
+		c.stopOnce.Do(func() { close(stop) })
+	}
+}
+
+// deleteExpired 删除所有过期条目
+func (c *LRU[K, V]) deleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for el := c.ll.Back(); el != nil; {
+		prev := el.Prev()
+		if el.Value.(*entry[K, V]).expired(now) {
+			c.removeElement(el)
+		}
+		el = prev
+	}
+}
@@ -0,0 +1,153 @@
+// cache/cache_lru_test.go
+// LRU 缓存的测试
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictionOrder(t *testing.T) {
+	c := NewLRU[string, int](3)
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	c.Set("c", 3, 0)
+
+	// 访问 a，使 b 成为最久未访问的条目
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v, want 1, true", v, ok)
+	}
+	c.Set("d", 4, 0)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b 应该被淘汰")
+	}
+
+	// 更新已存在的 c 也算访问，接下来淘汰的是 a
+	c.Set("c", 30, 0)
+	c.Set("e", 5, 0)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a 应该被淘汰")
+	}
+	for key, want := range map[string]int{"c": 30, "d": 4, "e": 5} {
+		if v, ok := c.Get(key); !ok || v != want {
+			t.Errorf("Get(%s) = %d, %v, want %d, true", key, v, ok, want)
+		}
+	}
+
+	want := Stats{Hits: 4, Misses: 2, Evictions: 2, Size: 3}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLRUUnlimited(t *testing.T) {
+	c := NewLRU[int, int](0)
+	for i := range 1000 {
+		c.Set(i, i, 0)
+	}
+	if c.Len() != 1000 || c.Stats().Evictions != 0 {
+		t.Errorf("Len() = %d, Evictions = %d, want 1000, 0", c.Len(), c.Stats().Evictions)
+	}
+}
+
+func TestLRUTTL(t *testing.T) {
+	c := NewLRU[string, string](10)
+	c.Set("short", "v", 20*time.Millisecond)
+	c.Set("forever", "v", 0)
+
+	if _, ok := c.Get("short"); !ok {
+		t.Fatal("未过期时 Get(short) 未命中")
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	// 过期条目在 Get 时惰性删除
+	if c.Len() != 2 {
+		t.Errorf("惰性删除前 Len() = %d, want 2", c.Len())
+	}
+	if _, ok := c.Get("short"); ok {
+		t.Error("过期后 Get(short) 仍然命中")
+	}
+	if c.Len() != 1 {
+		t.Errorf("惰性删除后 Len() = %d, want 1", c.Len())
+	}
+	if _, ok := c.Get("forever"); !ok {
+		t.Error("ttl <= 0 的条目不应过期")
+	}
+
+	// 重新 Set 会刷新过期时间
+	c.Set("short", "v", time.Hour)
+	if _, ok := c.Get("short"); !ok {
+		t.Error("重新 Set 后 Get(short) 未命中")
+	}
+}
+
+func TestLRUDelete(t *testing.T) {
+	c := NewLRU[string, int](10)
+	c.Set("a", 1, 0)
+	if !c.Delete("a") {
+		t.Error("Delete(a) = false, want true")
+	}
+	if c.Delete("a") {
+		t.Error("重复 Delete(a) = true, want false")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("删除后 Get(a) 仍然命中")
+	}
+}
+
+func TestLRUJanitor(t *testing.T) {
+	c := NewLRU[string, int](10)
+	c.Set("a", 1, 10*time.Millisecond)
+	c.Set("b", 2, 10*time.Millisecond)
+	c.Set("keep", 3, time.Hour)
+
+	c.StartJanitor(5 * time.Millisecond)
+	c.StartJanitor(5 * time.Millisecond) // 重复启动是无操作
+	defer c.Stop()
+
+	// janitor 主动删除过期条目，不需要 Get 触发
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Len() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor 没有清理过期条目，Len() = %d", c.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, ok := c.Get("keep"); !ok {
+		t.Error("未过期的条目被 janitor 删除")
+	}
+
+	c.Stop()
+	c.Stop() // 重复停止不会 panic
+}
+
+func TestLRUConcurrent(t *testing.T) {
+	c := NewLRU[string, int](50)
+	c.StartJanitor(time.Millisecond)
+	defer c.Stop()
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				key := fmt.Sprintf("k%d", (g*500+i)%100)
+				c.Set(key, i, time.Millisecond)
+				c.Get(key)
+				if i%10 == 0 {
+					c.Delete(key)
+				}
+				c.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := c.Len(); n > 50 {
+		t.Errorf("Len() = %d, 超过容量 50", n)
+	}
+}
@@ -4,8 +4,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	// 导入 GORM 和数据库驱动
@@ -13,8 +21,13 @@ import (
 	// 官方网站：https://gorm.io
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
+
+	// 与 gorm/logger 同名，使用别名区分
+	applog "github.com/austoin/GolangTutorial/logger"
+	"github.com/austoin/GolangTutorial/paging"
 )
 
 // ====== 数据模型定义 ======
@@ -41,9 +54,15 @@ type User struct {
 	// gorm:"autoUpdateTime" 自动设置更新时间为当前时间
 	UpdatedAt time.Time `gorm:"autoUpdateTime"`
 
+	// 软删除
+	DeletedAt gorm.DeletedAt `gorm:"index"` // 软删除支持
+
 	// gorm:"-" 忽略此字段
 	Age int `gorm:"-"` // 不存储年龄，只在内存中使用
 
+	// 账户余额，TransferMoney 和 BatchUpdateBalances 使用
+	Balance float64 `gorm:"default:0"`
+
 	// 关联关系
 	// gorm:"foreignKey:UserID" 定义外键
 	Posts []Post `gorm:"foreignKey:UserID"` // 一对多关系
@@ -62,6 +81,12 @@ type Post struct {
 	// gorm:"references:ID" 指定引用的列
 	User User `gorm:"references:ID"` // 属于 User
 
+	// 一对多关系，GetUserPostsWithComments 预加载
+	Comments []Comment `gorm:"foreignKey:PostID"`
+
+	// 创建时间，用于按时间筛选帖子
+	CreatedAt time.Time `gorm:"autoCreateTime;index"`
+
 	// 软删除
 	DeletedAt gorm.DeletedAt `gorm:"index"` // 软删除支持
 }
@@ -84,15 +109,56 @@ type Comment struct {
 // ====== 数据库连接 ======
 
 // Database GORM 数据库封装
+//
+// 每个 CRUD 方法都有一个 Ctx 后缀的版本，例如 GetUserByIDCtx(ctx, id)，
+// 内部使用 d.db.WithContext(ctx)，HTTP 请求取消或超时时查询会被中止。
+// 不带 Ctx 的方法使用 context.Background()，保持原有用法不变。
+// 各个 Ctx 方法只在取消行为有特别之处（如是否在事务中）时单独说明。
 type Database struct {
 	db *gorm.DB // GORM DB 实例
+
+	// 慢查询钩子配置，可以在运行时修改
+	slowThreshold atomic.Int64 // 慢查询阈值（纳秒）
+	redactParams  atomic.Bool  // 记录慢查询时是否隐藏参数值
+
+	seed atomic.Int64 // SeedUsers 使用的随机种子
+
+	inTx bool // 由 Tx 创建，db 绑定在事务上
 }
 
 // NewDatabase 创建数据库连接
 func NewDatabase(dsn string) (*Database, error) {
 	// 1. 配置 GORM
+	config := newGormConfig()
+
+	// 2. 打开数据库连接
+	// gorm.Open 接受 Dialector 和 Config
+	// Dialector 是数据库驱动的抽象
+	db, err := gorm.Open(mysql.Open(dsn), config)
+	if err != nil {
+		return nil, fmt.Errorf("连接数据库失败: %w", err)
+	}
+
+	// 3. 配置连接池（通过 *gorm.DB 访问底层 sql.DB）
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("获取底层连接失败: %w", err)
+	}
+
+	// 设置连接池参数
+	sqlDB.SetMaxOpenConns(25)
+	sqlDB.SetMaxIdleConns(5)
+	sqlDB.SetConnMaxLifetime(5 * time.Minute)
+
+	applog.Info("GORM 数据库连接成功")
+
+	return newDatabaseFromDB(db)
+}
+
+// newGormConfig 示例使用的 GORM 配置
+func newGormConfig() *gorm.Config {
 	// Config 结构体包含各种配置选项
-	config := &gorm.Config{
+	return &gorm.Config{
 		// NamingStrategy 命名策略
 		// 用于自动生成表名、列名等
 		NamingStrategy: schema.NamingStrategy{
@@ -101,9 +167,14 @@ func NewDatabase(dsn string) (*Database, error) {
 		},
 
 		// Logger 日志配置
-		// NewLogger 创建自定义日志
-		// LogLevel 日志级别
-		Logger: logger.Default.LogMode(logger.Info),
+		// logger.New 创建自定义日志
+		// SlowThreshold 超过该耗时的 SQL 会以 SLOW SQL 警告输出
+		// ParameterizedQueries 为 true 时日志中只输出占位符，不输出参数值
+		Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+			SlowThreshold:        defaultSlowThreshold,
+			LogLevel:             logger.Info,
+			ParameterizedQueries: true,
+		}),
 
 		// SkipDefaultTransaction 跳过默认事务
 		// 对于大量写入操作，关闭事务可能提高性能
@@ -112,34 +183,33 @@ func NewDatabase(dsn string) (*Database, error) {
 		// PrepareStmt 预编译 SQL
 		// 在执行前预编译，提高性能
 		PrepareStmt: true,
-	}
 
-	// 2. 打开数据库连接
-	// gorm.Open 接受 Dialector 和 Config
-	// Dialector 是数据库驱动的抽象
-	db, err := gorm.Open(mysql.Open(dsn), config)
-	if err != nil {
-		return nil, fmt.Errorf("连接数据库失败: %w", err)
+		// TranslateError 把驱动的错误转换为 GORM 的通用错误
+		// 例如唯一索引冲突（MySQL 1062）转换为 gorm.ErrDuplicatedKey，调用方不需要依赖具体驱动
+		TranslateError: true,
 	}
+}
 
-	// 3. 配置连接池（通过 *gorm.DB 访问底层 sql.DB）
-	sqlDB, err := db.DB()
-	if err != nil {
-		return nil, fmt.Errorf("获取底层连接失败: %w", err)
+// newDatabaseFromDB 包装已经打开的连接，设置默认参数并注册慢查询钩子
+// 测试中用来包装 testfixtures.NewTestDBWithConfig(t, newGormConfig()) 返回的连接
+func newDatabaseFromDB(db *gorm.DB) (*Database, error) {
+	d := &Database{db: db}
+	d.SetSlowThreshold(defaultSlowThreshold)
+	d.SetRedactParams(true)
+	d.SetSeed(defaultSeed)
+	if err := d.registerSlowQueryHook(); err != nil {
+		return nil, fmt.Errorf("注册慢查询钩子失败: %w", err)
 	}
 
-	// 设置连接池参数
-	sqlDB.SetMaxOpenConns(25)
-	sqlDB.SetMaxIdleConns(5)
-	sqlDB.SetConnMaxLifetime(5 * time.Minute)
-
-	log.Println("GORM 数据库连接成功")
-
-	return &Database{db: db}, nil
+	return d, nil
 }
 
 // Close 关闭数据库连接
+// 事务内的 Database（Tx 回调的参数）不能关闭，连接由外层 Database 管理
 func (d *Database) Close() error {
+	if d.inTx {
+		return errors.New("cannot close a transaction-scoped Database")
+	}
 	sqlDB, err := d.db.DB()
 	if err != nil {
 		return err
@@ -152,6 +222,80 @@ func (d *Database) DB() *gorm.DB {
 	return d.db
 }
 
+// ====== 慢查询日志 ======
+/*
+GORM 的回调（Callback）可以在每个操作的各个阶段插入逻辑：
+  db.Callback().Query().Before("gorm:query").Register(name, fn)
+  db.Callback().Query().After("gorm:query").Register(name, fn)
+
+这里在查询前记录开始时间，查询后计算耗时，超过阈值时使用结构化日志输出
+SQL、影响行数和耗时。与 GORM 自带的 SlowThreshold 相比：
+  - 阈值可以通过 SetSlowThreshold 在运行时调整
+  - 输出到共享的 logger 包，能被统一采集
+
+参数值可能包含密码、令牌等敏感信息，默认隐藏（SQL 中保留 ? 占位符），
+开发环境可以调用 SetRedactParams(false) 输出完整的 SQL。
+*/
+
+// defaultSlowThreshold 默认慢查询阈值
+const defaultSlowThreshold = 200 * time.Millisecond
+
+// slowQueryStartKey 保存查询开始时间的实例键
+const slowQueryStartKey = "slowlog:start"
+
+// SetSlowThreshold 设置慢查询阈值，<= 0 表示关闭慢查询日志
+func (d *Database) SetSlowThreshold(threshold time.Duration) {
+	d.slowThreshold.Store(int64(threshold))
+}
+
+// SetRedactParams 设置慢查询日志是否隐藏参数值
+func (d *Database) SetRedactParams(redact bool) {
+	d.redactParams.Store(redact)
+}
+
+// registerSlowQueryHook 注册慢查询回调
+func (d *Database) registerSlowQueryHook() error {
+	query := d.db.Callback().Query()
+
+	if err := query.Before("gorm:query").Register("slowlog:before_query", func(db *gorm.DB) {
+		db.InstanceSet(slowQueryStartKey, time.Now())
+	}); err != nil {
+		return err
+	}
+
+	return query.After("gorm:query").Register("slowlog:after_query", d.logSlowQuery)
+}
+
+// logSlowQuery 查询结束后检查耗时
+func (d *Database) logSlowQuery(db *gorm.DB) {
+	threshold := time.Duration(d.slowThreshold.Load())
+	if threshold <= 0 {
+		return
+	}
+
+	v, ok := db.InstanceGet(slowQueryStartKey)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(v.(time.Time))
+	if elapsed < threshold {
+		return
+	}
+
+	// 隐藏参数时直接使用带占位符的 SQL
+	sql := db.Statement.SQL.String()
+	if !d.redactParams.Load() {
+		sql = db.Dialector.Explain(sql, db.Statement.Vars...)
+	}
+
+	applog.WithContext(db.Statement.Context).Warn("slow query",
+		"sql", sql,
+		"rows", db.Statement.RowsAffected,
+		"duration", elapsed.String(),
+		"threshold", threshold.String(),
+	)
+}
+
 // ====== 自动迁移 ======
 
 // AutoMigrate 自动迁移数据库表结构
@@ -159,7 +303,7 @@ func (d *Database) DB() *gorm.DB {
 func (d *Database) AutoMigrate() error {
 	// AutoMigrate 根据结构体变化自动更新表结构
 	// 只会添加新列，不会删除或修改已有列
-	log.Println("开始自动迁移...")
+	applog.Info("开始自动迁移")
 
 	err := d.db.AutoMigrate(
 		&User{},    // 迁移 User 表
@@ -171,7 +315,7 @@ func (d *Database) AutoMigrate() error {
 		return fmt.Errorf("自动迁移失败: %w", err)
 	}
 
-	log.Println("自动迁移完成")
+	applog.Info("自动迁移完成")
 	return nil
 }
 
@@ -179,9 +323,13 @@ func (d *Database) AutoMigrate() error {
 
 // CreateUser 创建用户
 func (d *Database) CreateUser(user *User) error {
+	return d.CreateUserCtx(context.Background(), user)
+}
+
+func (d *Database) CreateUserCtx(ctx context.Context, user *User) error {
 	// 1. 创建记录
 	// Create 返回 *gorm.DB，可以链式调用
-	result := d.db.Create(user)
+	result := d.db.WithContext(ctx).Create(user)
 
 	// 2. 检查错误
 	if result.Error != nil {
@@ -190,29 +338,40 @@ func (d *Database) CreateUser(user *User) error {
 
 	// 3. 获取插入的 ID
 	// user.ID 会被自动填充
-	log.Printf("创建用户成功，ID: %d", user.ID)
+	applog.Info("创建用户成功", "id", user.ID)
 
 	return nil
 }
 
 // CreateUsers 批量创建用户
 func (d *Database) CreateUsers(users []User) error {
+	return d.CreateUsersCtx(context.Background(), users)
+}
+
+// CreateUsersCtx 超过一批时 GORM 把所有批次放在同一个事务中，ctx 中途取消会整体回滚
+func (d *Database) CreateUsersCtx(ctx context.Context, users []User) error {
 	// 1. 批量创建
 	// CreateInBatches 分批创建，避免内存溢出
-	result := d.db.CreateInBatches(users, 100)
+	result := d.db.WithContext(ctx).CreateInBatches(users, 100)
 
 	if result.Error != nil {
 		return fmt.Errorf("批量创建用户失败: %w", result.Error)
 	}
 
-	log.Printf("批量创建用户成功，数量: %d", result.RowsAffected)
+	applog.Info("批量创建用户成功", "count", result.RowsAffected)
 	return nil
 }
 
 // CreateUserWithPosts 创建用户及帖子
 func (d *Database) CreateUserWithPosts(user *User, posts []Post) error {
+	return d.CreateUserWithPostsCtx(context.Background(), user, posts)
+}
+
+// CreateUserWithPostsCtx 分两条语句插入用户和帖子，不在同一个事务中：
+// ctx 在两者之间取消时用户已经创建，需要原子性时在 Tx 中调用
+func (d *Database) CreateUserWithPostsCtx(ctx context.Context, user *User, posts []Post) error {
 	// 1. 创建用户
-	if err := d.db.Create(user).Error; err != nil {
+	if err := d.db.WithContext(ctx).Create(user).Error; err != nil {
 		return err
 	}
 
@@ -222,7 +381,7 @@ func (d *Database) CreateUserWithPosts(user *User, posts []Post) error {
 	}
 
 	// 3. 创建帖子
-	if err := d.db.Create(&posts).Error; err != nil {
+	if err := d.db.WithContext(ctx).Create(&posts).Error; err != nil {
 		return err
 	}
 
@@ -233,12 +392,16 @@ func (d *Database) CreateUserWithPosts(user *User, posts []Post) error {
 
 // GetUserByID 根据 ID 查询用户
 func (d *Database) GetUserByID(id uint) (*User, error) {
+	return d.GetUserByIDCtx(context.Background(), id)
+}
+
+func (d *Database) GetUserByIDCtx(ctx context.Context, id uint) (*User, error) {
 	var user User
 
 	// 1. 查询单条记录
 	// First 找到第一条匹配的记录
 	// 如果找不到，返回 gorm.ErrRecordNotFound 错误
-	result := d.db.First(&user, id)
+	result := d.db.WithContext(ctx).First(&user, id)
 
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
@@ -252,10 +415,14 @@ func (d *Database) GetUserByID(id uint) (*User, error) {
 
 // GetUserByUsername 根据用户名查询用户
 func (d *Database) GetUserByUsername(username string) (*User, error) {
+	return d.GetUserByUsernameCtx(context.Background(), username)
+}
+
+func (d *Database) GetUserByUsernameCtx(ctx context.Context, username string) (*User, error) {
 	var user User
 
 	// 使用 Where 条件查询
-	result := d.db.Where("username = ?", username).First(&user)
+	result := d.db.WithContext(ctx).Where("username = ?", username).First(&user)
 
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
@@ -269,10 +436,14 @@ func (d *Database) GetUserByUsername(username string) (*User, error) {
 
 // GetAllUsers 查询所有用户
 func (d *Database) GetAllUsers() ([]User, error) {
+	return d.GetAllUsersCtx(context.Background())
+}
+
+func (d *Database) GetAllUsersCtx(ctx context.Context) ([]User, error) {
 	var users []User
 
 	// 查询所有记录
-	result := d.db.Find(&users)
+	result := d.db.WithContext(ctx).Find(&users)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -283,11 +454,15 @@ func (d *Database) GetAllUsers() ([]User, error) {
 
 // GetUsersByCondition 条件查询
 func (d *Database) GetUsersByCondition(conditions map[string]interface{}) ([]User, error) {
+	return d.GetUsersByConditionCtx(context.Background(), conditions)
+}
+
+func (d *Database) GetUsersByConditionCtx(ctx context.Context, conditions map[string]interface{}) ([]User, error) {
 	var users []User
 
 	// 使用 Where 条件
 	// 支持链式调用
-	result := d.db.Where(conditions).Find(&users)
+	result := d.db.WithContext(ctx).Where(conditions).Find(&users)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -298,10 +473,14 @@ func (d *Database) GetUsersByCondition(conditions map[string]interface{}) ([]Use
 
 // GetUsersByEmailPrefix 按邮箱前缀查询
 func (d *Database) GetUsersByEmailPrefix(prefix string) ([]User, error) {
+	return d.GetUsersByEmailPrefixCtx(context.Background(), prefix)
+}
+
+func (d *Database) GetUsersByEmailPrefixCtx(ctx context.Context, prefix string) ([]User, error) {
 	var users []User
 
-	// 使用 LIKE 进行模糊查询
-	result := d.db.Where("email LIKE ?", prefix+"%").Find(&users)
+	// 使用 LIKE 进行模糊查询，prefix 中的通配符按字面匹配
+	result := d.db.WithContext(ctx).Where(`email LIKE ? ESCAPE '!'`, escapeLike(prefix)+"%").Find(&users)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -310,13 +489,51 @@ func (d *Database) GetUsersByEmailPrefix(prefix string) ([]User, error) {
 	return users, nil
 }
 
+// escapeLike 转义 %、_ 和转义字符 !，与 database_sql.go 中的同名函数相同
+// 两个示例是独立的 main 程序，不能共享代码
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
 // GetUserWithPosts 获取用户及其帖子
 func (d *Database) GetUserWithPosts(id uint) (*User, error) {
+	return d.GetUserWithPostsCtx(context.Background(), id)
+}
+
+func (d *Database) GetUserWithPostsCtx(ctx context.Context, id uint) (*User, error) {
 	var user User
 
 	// Preload 预加载关联数据
 	// 这样可以一次性获取用户及其所有帖子
-	result := d.db.Preload("Posts").First(&user, id)
+	result := d.db.WithContext(ctx).Preload("Posts").First(&user, id)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
+// GetUserWithRecentPosts 获取用户及其最近的帖子
+// 只预加载 since 之后创建的帖子，按创建时间倒序，最多 limit 条；limit <= 0 表示不限制
+func (d *Database) GetUserWithRecentPosts(id uint, since time.Time, limit int) (*User, error) {
+	return d.GetUserWithRecentPostsCtx(context.Background(), id, since, limit)
+}
+
+func (d *Database) GetUserWithRecentPostsCtx(ctx context.Context, id uint, since time.Time, limit int) (*User, error) {
+	var user User
+
+	// Preload 可以传入函数自定义关联查询条件
+	// 注意：Limit 作用于整个预加载查询，查询多个用户时不是"每个用户 limit 条"
+	result := d.db.WithContext(ctx).Preload("Posts", func(db *gorm.DB) *gorm.DB {
+		db = db.Where("created_at >= ?", since).Order("created_at desc")
+		if limit > 0 {
+			db = db.Limit(limit)
+		}
+		return db
+	}).First(&user, id)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -327,9 +544,13 @@ func (d *Database) GetUserWithPosts(id uint) (*User, error) {
 
 // GetUserPosts 获取用户的帖子
 func (d *Database) GetUserPosts(userID uint) ([]Post, error) {
+	return d.GetUserPostsCtx(context.Background(), userID)
+}
+
+func (d *Database) GetUserPostsCtx(ctx context.Context, userID uint) ([]Post, error) {
 	var posts []Post
 
-	result := d.db.Where("user_id = ?", userID).Find(&posts)
+	result := d.db.WithContext(ctx).Where("user_id = ?", userID).Find(&posts)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -340,10 +561,14 @@ func (d *Database) GetUserPosts(userID uint) ([]Post, error) {
 
 // GetUserPostsWithComments 获取用户的帖子及其评论
 func (d *Database) GetUserPostsWithComments(userID uint) ([]Post, error) {
+	return d.GetUserPostsWithCommentsCtx(context.Background(), userID)
+}
+
+func (d *Database) GetUserPostsWithCommentsCtx(ctx context.Context, userID uint) ([]Post, error) {
 	var posts []Post
 
 	// 预加载多层关联
-	result := d.db.Preload("Comments").Preload("Comments.User").
+	result := d.db.WithContext(ctx).Preload("Comments").Preload("Comments.User").
 		Where("user_id = ?", userID).Find(&posts)
 
 	if result.Error != nil {
@@ -353,11 +578,46 @@ func (d *Database) GetUserPostsWithComments(userID uint) ([]Post, error) {
 	return posts, nil
 }
 
+// maxKeysetLimit 游标分页每页最大数量
+const maxKeysetLimit = 100
+
+// ListUsersKeyset 游标（keyset）分页查询
+// 与 OFFSET 分页不同，WHERE id > ? 可以直接利用主键索引定位，
+// 翻到很深的页时也不会变慢，并且插入新数据不会导致重复或遗漏
+// 返回的 nextCursor 作为下一页的 afterID，为 0 表示没有更多数据
+func (d *Database) ListUsersKeyset(afterID uint, limit int) (users []User, nextCursor uint, err error) {
+	return d.ListUsersKeysetCtx(context.Background(), afterID, limit)
+}
+
+func (d *Database) ListUsersKeysetCtx(ctx context.Context, afterID uint, limit int) (users []User, nextCursor uint, err error) {
+	// 限制每页数量
+	if limit <= 0 || limit > maxKeysetLimit {
+		limit = maxKeysetLimit
+	}
+
+	// 多查一条，用于判断是否还有下一页
+	result := d.db.WithContext(ctx).Where("id > ?", afterID).Order("id").Limit(limit + 1).Find(&users)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	if len(users) > limit {
+		users = users[:limit]
+		nextCursor = users[limit-1].ID
+	}
+
+	return users, nextCursor, nil
+}
+
 // CountUsers 统计用户数量
 func (d *Database) CountUsers() (int64, error) {
+	return d.CountUsersCtx(context.Background())
+}
+
+func (d *Database) CountUsersCtx(ctx context.Context) (int64, error) {
 	var count int64
 
-	result := d.db.Model(&User{}).Count(&count)
+	result := d.db.WithContext(ctx).Model(&User{}).Count(&count)
 	return count, result.Error
 }
 
@@ -365,9 +625,13 @@ func (d *Database) CountUsers() (int64, error) {
 
 // UpdateUser 更新用户
 func (d *Database) UpdateUser(user *User) error {
+	return d.UpdateUserCtx(context.Background(), user)
+}
+
+func (d *Database) UpdateUserCtx(ctx context.Context, user *User) error {
 	// 1. 保存更新
 	// Save 会更新所有字段
-	result := d.db.Save(user)
+	result := d.db.WithContext(ctx).Save(user)
 
 	if result.Error != nil {
 		return fmt.Errorf("更新用户失败: %w", result.Error)
@@ -378,7 +642,11 @@ func (d *Database) UpdateUser(user *User) error {
 
 // UpdateUserField 更新用户单个字段
 func (d *Database) UpdateUserField(id uint, field string, value interface{}) error {
-	result := d.db.Model(&User{}).Where("id = ?", id).Update(field, value)
+	return d.UpdateUserFieldCtx(context.Background(), id, field, value)
+}
+
+func (d *Database) UpdateUserFieldCtx(ctx context.Context, id uint, field string, value interface{}) error {
+	result := d.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Update(field, value)
 
 	if result.Error != nil {
 		return result.Error
@@ -391,16 +659,59 @@ func (d *Database) UpdateUserField(id uint, field string, value interface{}) err
 	return nil
 }
 
+var (
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("用户不存在")
+
+	// ErrEmailTaken 邮箱已被其他用户使用
+	ErrEmailTaken = errors.New("邮箱已被使用")
+)
+
 // UpdateUserEmail 更新用户邮箱
+// 用户不存在返回 ErrUserNotFound，邮箱被其他用户占用返回 ErrEmailTaken
 func (d *Database) UpdateUserEmail(id uint, email string) error {
-	result := d.db.Model(&User{}).Where("id = ?", id).Update("email", email)
+	return d.UpdateUserEmailCtx(context.Background(), id, email)
+}
+
+// UpdateUserEmailCtx 中唯一索引是邮箱唯一的最终保证，预检查只是为了给出明确的错误：
+// 预检查通过后、UPDATE 执行前，并发请求仍可能抢先占用同一个邮箱，
+// 这时 UPDATE 触发唯一索引冲突（MySQL 1062），同样转换为 ErrEmailTaken。
+func (d *Database) UpdateUserEmailCtx(ctx context.Context, id uint, email string) error {
+	db := d.db.WithContext(ctx)
+
+	// 1. 用户必须存在
+	var user User
+	if err := db.Select("id", "email").First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	// MySQL 中值没有变化时 RowsAffected 为 0，提前返回避免误判为用户不存在
+	if user.Email == email {
+		return nil
+	}
 
+	// 2. 预检查：软删除的用户同样受唯一索引约束，需要 Unscoped
+	var taken int64
+	if err := db.Unscoped().Model(&User{}).Where("email = ? AND id <> ?", email, id).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrEmailTaken
+	}
+
+	// 3. 更新
+	result := db.Model(&User{}).Where("id = ?", id).Update("email", email)
+	if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		return ErrEmailTaken
+	}
 	if result.Error != nil {
 		return result.Error
 	}
-
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("用户不存在: %d", id)
+		// 预检查之后被删除
+		return ErrUserNotFound
 	}
 
 	return nil
@@ -408,7 +719,11 @@ func (d *Database) UpdateUserEmail(id uint, email string) error {
 
 // UpdateUsersByCondition 批量更新
 func (d *Database) UpdateUsersByCondition(condition map[string]interface{}, updates map[string]interface{}) error {
-	result := d.db.Model(&User{}).Where(condition).Updates(updates)
+	return d.UpdateUsersByConditionCtx(context.Background(), condition, updates)
+}
+
+func (d *Database) UpdateUsersByConditionCtx(ctx context.Context, condition map[string]interface{}, updates map[string]interface{}) error {
+	result := d.db.WithContext(ctx).Model(&User{}).Where(condition).Updates(updates)
 
 	if result.Error != nil {
 		return result.Error
@@ -417,12 +732,63 @@ func (d *Database) UpdateUsersByCondition(condition map[string]interface{}, upda
 	return nil
 }
 
+// batchUpdateChunkSize 批量更新时每条 SQL 包含的最大行数
+// 每行占 3 个占位符（WHEN ? THEN ? 和 IN 中的一个），避免超出数据库的占位符上限
+const batchUpdateChunkSize = 500
+
+// BatchUpdateBalances 按 ID 批量设置不同的余额
+func (d *Database) BatchUpdateBalances(updates map[uint]float64) error {
+	return d.BatchUpdateBalancesCtx(context.Background(), updates)
+}
+
+// BatchUpdateBalancesCtx 每 batchUpdateChunkSize 行生成一条 UPDATE ... CASE 语句，
+// 所有分块在同一个事务中执行，ctx 中途取消时已执行的分块一起回滚：
+//
+//	UPDATE t_users SET balance = CASE id WHEN 1 THEN 10 WHEN 2 THEN 20 ELSE balance END,
+//	       updated_at = ? WHERE id IN (1, 2) AND deleted_at IS NULL
+//
+// ID 按升序处理，多个批量更新并发执行时加锁顺序一致，不会互相死锁
+func (d *Database) BatchUpdateBalancesCtx(ctx context.Context, updates map[uint]float64) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	ids := slices.Sorted(maps.Keys(updates))
+
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for chunk := range slices.Chunk(ids, batchUpdateChunkSize) {
+			var sb strings.Builder
+			args := make([]interface{}, 0, 2*len(chunk))
+
+			sb.WriteString("CASE id")
+			for _, id := range chunk {
+				sb.WriteString(" WHEN ? THEN ?")
+				args = append(args, id, updates[id])
+			}
+			sb.WriteString(" ELSE balance END")
+
+			result := tx.Model(&User{}).Where("id IN ?", chunk).
+				Update("balance", gorm.Expr(sb.String(), args...))
+			if result.Error != nil {
+				return fmt.Errorf("批量更新余额失败: %w", result.Error)
+			}
+		}
+
+		applog.Info("批量更新余额成功", "count", len(ids))
+		return nil
+	})
+}
+
 // ====== 删除操作 ======
 
 // DeleteUser 删除用户（软删除）
 func (d *Database) DeleteUser(id uint) error {
+	return d.DeleteUserCtx(context.Background(), id)
+}
+
+func (d *Database) DeleteUserCtx(ctx context.Context, id uint) error {
 	// 如果模型包含 DeletedAt 字段，Delete 默认执行软删除
-	result := d.db.Delete(&User{}, id)
+	result := d.db.WithContext(ctx).Delete(&User{}, id)
 
 	if result.Error != nil {
 		return result.Error
@@ -437,8 +803,12 @@ func (d *Database) DeleteUser(id uint) error {
 
 // DeleteUserPermanently 永久删除用户
 func (d *Database) DeleteUserPermanently(id uint) error {
+	return d.DeleteUserPermanentlyCtx(context.Background(), id)
+}
+
+func (d *Database) DeleteUserPermanentlyCtx(ctx context.Context, id uint) error {
 	// Unscoped 忽略软删除字段
-	result := d.db.Unscoped().Delete(&User{}, id)
+	result := d.db.WithContext(ctx).Unscoped().Delete(&User{}, id)
 
 	if result.Error != nil {
 		return result.Error
@@ -447,24 +817,292 @@ func (d *Database) DeleteUserPermanently(id uint) error {
 	return nil
 }
 
+// ErrEmptyFilter 删除条件为空
+// 防止误删整张表
+var ErrEmptyFilter = errors.New("删除条件不能为空")
+
+// UserFilter 批量删除的过滤条件
+// 至少需要设置一个条件
+type UserFilter struct {
+	IDs           []uint    // ID 列表
+	Username      string    // 用户名（精确匹配）
+	EmailPrefix   string    // 邮箱前缀
+	CreatedBefore time.Time // 创建时间早于
+
+	// Hard 为 true 时永久删除（Unscoped），包括已软删除的记录
+	Hard bool
+}
+
 // DeleteUsersByCondition 批量删除
-func (d *Database) DeleteUsersByCondition(condition map[string]interface{}) error {
-	result := d.db.Where(condition).Delete(&User{})
+// 返回受影响的行数
+func (d *Database) DeleteUsersByCondition(condition map[string]interface{}) (int64, error) {
+	return d.DeleteUsersByConditionCtx(context.Background(), condition)
+}
+
+func (d *Database) DeleteUsersByConditionCtx(ctx context.Context, condition map[string]interface{}) (int64, error) {
+	// 空条件会删除整张表，直接拒绝
+	if len(condition) == 0 {
+		return 0, ErrEmptyFilter
+	}
+
+	result := d.db.WithContext(ctx).Where(condition).Delete(&User{})
 
 	if result.Error != nil {
-		return result.Error
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+// DeleteUsersByFilter 按过滤条件批量删除
+// 默认软删除，filter.Hard 为 true 时永久删除
+// 返回受影响的行数
+func (d *Database) DeleteUsersByFilter(filter UserFilter) (int64, error) {
+	return d.DeleteUsersByFilterCtx(context.Background(), filter)
+}
+
+func (d *Database) DeleteUsersByFilterCtx(ctx context.Context, filter UserFilter) (int64, error) {
+	query := d.db.WithContext(ctx).Model(&User{})
+	conditions := 0
+
+	// 逐个添加条件
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+		conditions++
+	}
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+		conditions++
+	}
+	if filter.EmailPrefix != "" {
+		query = query.Where(`email LIKE ? ESCAPE '!'`, escapeLike(filter.EmailPrefix)+"%")
+		conditions++
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedBefore)
+		conditions++
+	}
+
+	if conditions == 0 {
+		return 0, ErrEmptyFilter
+	}
+
+	// Unscoped 忽略软删除字段，执行真正的 DELETE
+	if filter.Hard {
+		query = query.Unscoped()
+	}
+
+	result := query.Delete(&User{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("批量删除用户失败: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+// ====== 评论 ======
+/*
+评论属于一个帖子和一个用户，按创建时间正序返回（先发的在前），同一时刻按 ID 排序保证稳定。
+
+引用检查：
+  - 创建前检查用户和帖子存在（软删除的视为不存在），返回 ErrUserNotFound / ErrPostNotFound
+  - 外键约束是最终保证：检查之后引用被并发删除时 INSERT 触发外键冲突，
+    重新检查一次给出同样明确的错误
+
+GetPostComments 按页返回评论和总数，预加载评论作者；作者已被软删除时 User 为零值。
+*/
+
+var (
+	// ErrPostNotFound 帖子不存在
+	ErrPostNotFound = errors.New("帖子不存在")
+
+	// ErrCommentNotFound 评论不存在
+	ErrCommentNotFound = errors.New("评论不存在")
+)
+
+// CreateComment 创建评论
+// 用户不存在返回 ErrUserNotFound，帖子不存在返回 ErrPostNotFound
+func (d *Database) CreateComment(comment *Comment) error {
+	return d.CreateCommentCtx(context.Background(), comment)
+}
+
+// CreateCommentCtx 的引用检查和插入使用同一个 ctx，检查通过后取消同样会中止插入
+func (d *Database) CreateCommentCtx(ctx context.Context, comment *Comment) error {
+	db := d.db.WithContext(ctx)
+
+	if err := checkCommentRefs(db, comment); err != nil {
+		return err
+	}
+
+	// 只插入评论本身，不保存 User、Post 关联
+	err := db.Omit(clause.Associations).Create(comment).Error
+	if errors.Is(err, gorm.ErrForeignKeyViolated) {
+		if refErr := checkCommentRefs(db, comment); refErr != nil {
+			return refErr
+		}
+	}
+	return err
+}
+
+// checkCommentRefs 检查评论引用的用户和帖子存在
+func checkCommentRefs(db *gorm.DB, comment *Comment) error {
+	var n int64
+	if err := db.Model(&User{}).Where("id = ?", comment.UserID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+
+	if err := db.Model(&Post{}).Where("id = ?", comment.PostID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+// GetPostComments 分页获取帖子的评论（page 从 1 开始），返回当前页和评论总数
+// page、size 的修正规则与 paging.Clamp 相同；帖子不存在返回 ErrPostNotFound
+func (d *Database) GetPostComments(postID uint, page, size int) ([]Comment, int64, error) {
+	return d.GetPostCommentsCtx(context.Background(), postID, page, size)
+}
+
+func (d *Database) GetPostCommentsCtx(ctx context.Context, postID uint, page, size int) ([]Comment, int64, error) {
+	db := d.db.WithContext(ctx)
+	page, size = paging.Clamp(page, size)
+
+	var posts int64
+	if err := db.Model(&Post{}).Where("id = ?", postID).Count(&posts).Error; err != nil {
+		return nil, 0, err
+	}
+	if posts == 0 {
+		return nil, 0, ErrPostNotFound
 	}
 
+	var total int64
+	if err := db.Model(&Comment{}).Where("post_id = ?", postID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 超出最后一页时不再查询，也避免 page 很大时偏移量溢出
+	comments := []Comment{}
+	if int64(page-1) >= (total+int64(size)-1)/int64(size) {
+		return comments, total, nil
+	}
+
+	err := db.Preload("User").Where("post_id = ?", postID).
+		Order("created_at, id").Offset((page - 1) * size).Limit(size).
+		Find(&comments).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return comments, total, nil
+}
+
+// DeleteComment 删除评论（Comment 没有 DeletedAt，是物理删除）
+// 评论不存在返回 ErrCommentNotFound
+func (d *Database) DeleteComment(id uint) error {
+	return d.DeleteCommentCtx(context.Background(), id)
+}
+
+func (d *Database) DeleteCommentCtx(ctx context.Context, id uint) error {
+	result := d.db.WithContext(ctx).Delete(&Comment{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCommentNotFound
+	}
 	return nil
 }
 
+// ====== 泛型仓储 ======
+/*
+每个模型都需要相同的增删改查，使用泛型（Go 1.18+）可以只写一遍：
+
+  userRepo := NewRepository[User](db.DB())
+  postRepo := NewRepository[Post](db.DB())
+
+  userRepo.Create(&User{Username: "alice"})
+  user, _ := userRepo.FindByID(1)
+  posts, _ := postRepo.Find("user_id = ?", user.ID)
+*/
+
+// Repository 通用的 CRUD 仓储
+type Repository[T any] struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建仓储
+func NewRepository[T any](db *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+// Create 创建记录
+func (r *Repository[T]) Create(entity *T) error {
+	return r.db.Create(entity).Error
+}
+
+// FindByID 根据主键查询，找不到返回 (nil, nil)
+func (r *Repository[T]) FindByID(id any) (*T, error) {
+	var entity T
+
+	result := r.db.First(&entity, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &entity, nil
+}
+
+// Find 条件查询，conds 与 db.Find 的内联条件相同
+// 例如：Find("age > ?", 18) 或 Find(map[string]interface{}{"username": "alice"})
+func (r *Repository[T]) Find(conds ...interface{}) ([]T, error) {
+	var entities []T
+
+	if err := r.db.Find(&entities, conds...).Error; err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+// Update 保存所有字段
+func (r *Repository[T]) Update(entity *T) error {
+	return r.db.Save(entity).Error
+}
+
+// Delete 根据主键删除（模型有 DeletedAt 字段时为软删除）
+func (r *Repository[T]) Delete(id any) error {
+	var entity T
+	return r.db.Delete(&entity, id).Error
+}
+
 // ====== 原生 SQL ======
 
-// QueryRaw 原生查询
+// QueryRaw 原生查询，结果格式见 RawToMaps
 func (d *Database) QueryRaw(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return d.RawToMaps(context.Background(), query, args...)
+}
+
+// RawToMaps 执行原生查询，每行转换为 列名 -> 值 的映射
+// 适合报表、统计面板等不想为每种结果定义结构体的场景：
+//
+//	rows, err := db.RawToMaps(ctx,
+//	    "SELECT age, COUNT(*) AS total FROM t_users GROUP BY age ORDER BY age")
+//	// [{"age": 18, "total": 3}, {"age": 20, "total": 5}]
+//
+// NULL 转换为 nil；驱动以 []byte 返回的文本列（MySQL 的 VARCHAR、DECIMAL 等）转换为 string，
+// 序列化为 JSON 时不会变成 base64。没有结果时返回空切片
+func (d *Database) RawToMaps(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
 	// Row 返回一行数据
 	// Rows 返回多行数据
-	rows, err := d.db.Raw(query, args...).Rows()
+	rows, err := d.db.WithContext(ctx).Raw(query, args...).Rows()
 	if err != nil {
 		return nil, err
 	}
@@ -477,9 +1115,9 @@ func (d *Database) QueryRaw(query string, args ...interface{}) ([]map[string]int
 	}
 
 	// 遍历行
-	var results []map[string]interface{}
+	results := make([]map[string]interface{}, 0)
 	for rows.Next() {
-		// 创建切片来存储值
+		// 创建切片来存储值，扫描到 interface{} 时 NULL 为 nil
 		values := make([]interface{}, len(columns))
 		valuePtrs := make([]interface{}, len(columns))
 
@@ -493,28 +1131,47 @@ func (d *Database) QueryRaw(query string, args ...interface{}) ([]map[string]int
 		}
 
 		// 构建结果映射
-		rowMap := make(map[string]interface{})
+		// 驱动返回的 []byte 在下一次 Scan 时可能被复用，string() 会复制一份
+		rowMap := make(map[string]interface{}, len(columns))
 		for i, col := range columns {
-			rowMap[col] = values[i]
+			if b, ok := values[i].([]byte); ok {
+				rowMap[col] = string(b)
+			} else {
+				rowMap[col] = values[i]
+			}
 		}
 
 		results = append(results, rowMap)
 	}
 
+	// 遍历中途出错（如连接断开）时 Next 返回 false，需要检查 Err
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return results, nil
 }
 
 // ExecRaw 原生执行
 func (d *Database) ExecRaw(query string, args ...interface{}) error {
-	return d.db.Exec(query, args...).Error
+	return d.ExecRawCtx(context.Background(), query, args...)
+}
+
+func (d *Database) ExecRawCtx(ctx context.Context, query string, args ...interface{}) error {
+	return d.db.WithContext(ctx).Exec(query, args...).Error
 }
 
 // ====== 事务 ======
 
 // TransferMoney 转账示例
 func (d *Database) TransferMoney(fromID, toID uint, amount float64) error {
+	return d.TransferMoneyCtx(context.Background(), fromID, toID, amount)
+}
+
+// TransferMoneyCtx 扣款和加款在同一个事务中，ctx 取消时整体回滚
+func (d *Database) TransferMoneyCtx(ctx context.Context, fromID, toID uint, amount float64) error {
 	// 使用事务
-	return d.db.Transaction(func(tx *gorm.DB) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// 扣款
 		result := tx.Model(&User{}).Where("id = ? AND balance >= ?", fromID, amount).
 			Update("balance", gorm.Expr("balance - ?", amount))
@@ -535,6 +1192,165 @@ func (d *Database) TransferMoney(fromID, toID uint, amount float64) error {
 	})
 }
 
+// ====== 事务内的 Database ======
+/*
+组合多个已有方法时，很容易写出"看起来在事务里、实际没有"的代码：
+
+  d.db.Transaction(func(tx *gorm.DB) error {
+      if err := d.CreateUser(user); err != nil { // 用的是 d.db，不在事务中！
+          return err
+      }
+      return tx.Create(&post).Error
+  })
+
+Tx 把事务包装成一个新的 *Database 传给回调，在 txDB 上调用的所有方法都使用同一个事务：
+
+  err := d.Tx(func(txDB *Database) error {
+      if err := txDB.CreateUser(user); err != nil {
+          return err
+      }
+      post.UserID = user.ID
+      return txDB.DB().Create(&post).Error // 需要直接操作时用 txDB.DB()
+  })
+
+回调返回错误或 panic 时回滚，否则提交。
+在 txDB 上再调用 Tx 会使用保存点（GORM 的嵌套事务），内层失败只回滚内层。
+回调中不要再使用外层的 d，那会在事务之外执行。
+*/
+
+// Tx 在事务中执行 fn，fn 收到的 txDB 上的所有方法都在该事务中执行
+func (d *Database) Tx(fn func(txDB *Database) error) error {
+	return d.TxCtx(context.Background(), fn)
+}
+
+// TxCtx 的事务绑定在 ctx 上，ctx 取消或超时会回滚事务
+func (d *Database) TxCtx(ctx context.Context, fn func(txDB *Database) error) error {
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(d.withTx(tx))
+	})
+}
+
+// withTx 创建绑定在 tx 上的 Database
+// 慢查询钩子注册在 d 上，事务中的查询仍按 d 的配置记录，这里只需要复制种子
+func (d *Database) withTx(tx *gorm.DB) *Database {
+	txDB := &Database{db: tx, inTx: true}
+	txDB.seed.Store(d.seed.Load())
+	return txDB
+}
+
+// ====== 测试数据 ======
+/*
+测试和本地开发经常需要一批用户数据：
+
+  db.SetSeed(42)                  // 可选，相同种子生成相同的数据
+  users, err := db.SeedUsers(10)  // user0..user9
+  ...
+  db.Truncate(&Comment{}, &Post{}, &User{}) // 测试结束后清空
+
+生成规则：
+  - 用户名 user0、user1 ... user{n-1}，邮箱 user{i}@<随机域名>
+  - 年龄在 18~79 之间（Age 不落库，只在返回值中）
+  - 用户名、邮箱有唯一索引，重复调用前需要先 Truncate
+
+Truncate 清空表并重置自增 ID：
+  - MySQL：在同一个连接上临时关闭外键检查后 TRUNCATE，不受表之间外键的影响
+  - 其他数据库：按参数顺序 DELETE，需要先传子表（Comment、Post），再传父表（User）
+  - 不传参数时清空 AutoMigrate 管理的全部表
+*/
+
+const defaultSeed = 1
+
+// seedEmailDomains 生成邮箱时使用的域名
+var seedEmailDomains = []string{"example.com", "example.org", "test.dev", "mail.test"}
+
+// SetSeed 设置 SeedUsers 使用的随机种子
+func (d *Database) SetSeed(seed int64) {
+	d.seed.Store(seed)
+}
+
+// SeedUsers 生成并插入 n 个测试用户
+func (d *Database) SeedUsers(n int) ([]User, error) {
+	return d.SeedUsersCtx(context.Background(), n)
+}
+
+// SeedUsersCtx 通过 CreateUsersCtx 插入，取消时的行为与其相同
+func (d *Database) SeedUsersCtx(ctx context.Context, n int) ([]User, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	seed := uint64(d.seed.Load())
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	users := make([]User, n)
+	for i := range users {
+		username := fmt.Sprintf("user%d", i)
+		users[i] = User{
+			Username: username,
+			Email:    username + "@" + seedEmailDomains[rng.IntN(len(seedEmailDomains))],
+			Age:      18 + rng.IntN(62),
+		}
+	}
+
+	if err := d.CreateUsersCtx(ctx, users); err != nil {
+		return nil, fmt.Errorf("生成测试用户失败: %w", err)
+	}
+	return users, nil
+}
+
+// Truncate 清空 models 对应的表
+func (d *Database) Truncate(models ...interface{}) error {
+	return d.TruncateCtx(context.Background(), models...)
+}
+
+// TruncateCtx 在 MySQL 上 TRUNCATE 会隐式提交，ctx 中途取消时前面的表已经清空
+func (d *Database) TruncateCtx(ctx context.Context, models ...interface{}) error {
+	if len(models) == 0 {
+		models = []interface{}{&Comment{}, &Post{}, &User{}}
+	}
+
+	tables := make([]string, 0, len(models))
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: d.db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("解析模型失败: %w", err)
+		}
+		tables = append(tables, stmt.Schema.Table)
+	}
+
+	// SET FOREIGN_KEY_CHECKS 只对当前会话生效，必须固定在同一个连接上执行
+	return d.db.WithContext(ctx).Connection(func(tx *gorm.DB) (err error) {
+		if tx.Dialector.Name() != "mysql" {
+			for _, table := range tables {
+				if err := tx.Exec("DELETE FROM ?", clause.Table{Name: table}).Error; err != nil {
+					return fmt.Errorf("清空表 %s 失败: %w", table, err)
+				}
+			}
+			return nil
+		}
+
+		if err := tx.Exec("SET FOREIGN_KEY_CHECKS = 0").Error; err != nil {
+			return err
+		}
+		// 连接会回到连接池，无论成功与否都要恢复外键检查
+		// ctx 已取消时也要执行，所以不使用 ctx 的取消信号
+		defer func() {
+			restore := tx.WithContext(context.WithoutCancel(ctx))
+			if restoreErr := restore.Exec("SET FOREIGN_KEY_CHECKS = 1").Error; err == nil {
+				err = restoreErr
+			}
+		}()
+
+		for _, table := range tables {
+			if err := tx.Exec("TRUNCATE TABLE ?", clause.Table{Name: table}).Error; err != nil {
+				return fmt.Errorf("清空表 %s 失败: %w", table, err)
+			}
+		}
+		applog.Info("清空表完成", "tables", tables)
+		return nil
+	})
+}
+
 // ====== 钩子函数 ======
 
 /*
@@ -602,6 +1418,15 @@ func main() {
 		fmt.Printf("查询到用户: %s (%s)\n", user.Username, user.Email)
 	}
 
+	// 使用泛型仓储完成同样的操作
+	userRepo := NewRepository[User](db.DB())
+	if found, _ := userRepo.Find("username = ?", "bob"); len(found) > 0 {
+		fmt.Printf("仓储查询到用户: %s (%s)\n", found[0].Username, found[0].Email)
+	}
+	if u, _ := userRepo.FindByID(2); u != nil {
+		fmt.Printf("仓储按 ID 查询到用户: %s\n", u.Username)
+	}
+
 	// 5. 预加载测试
 	userWithPosts, _ := db.GetUserWithPosts(user.ID)
 	if userWithPosts != nil {
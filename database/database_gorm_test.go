@@ -0,0 +1,1002 @@
+// database/database_gorm_test.go
+// GORM 示例的测试
+//
+// database 目录下每个文件都是独立的示例程序（各有一个 main），需要按文件运行：
+//   go test database/database_gorm.go database/database_gorm_test.go
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	applog "github.com/austoin/GolangTutorial/logger"
+	"github.com/austoin/GolangTutorial/testfixtures"
+)
+
+// newTestDatabase 内存 SQLite 上的 Database，使用与示例相同的配置（表前缀 t_）并完成迁移
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	cfg := newGormConfig()
+	cfg.Logger = logger.Default.LogMode(logger.Silent)
+
+	d, err := newDatabaseFromDB(testfixtures.NewTestDBWithConfig(t, cfg))
+	if err != nil {
+		t.Fatalf("newDatabaseFromDB() error = %v", err)
+	}
+	if err := d.AutoMigrate(); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	return d
+}
+
+// createTestUsers 按用户名创建用户，邮箱为 "<username>@example.com"
+func createTestUsers(t *testing.T, d *Database, usernames ...string) []*User {
+	t.Helper()
+	users := make([]*User, len(usernames))
+	for i, name := range usernames {
+		users[i] = &User{Username: name, Email: name + "@example.com"}
+		if err := d.db.Create(users[i]).Error; err != nil {
+			t.Fatalf("创建用户 %s 失败: %v", name, err)
+		}
+	}
+	return users
+}
+
+// countUsers 统计用户数，unscoped 为 true 时包括软删除的记录
+func countUsers(t *testing.T, d *Database, unscoped bool) int64 {
+	t.Helper()
+	q := d.db.Model(&User{})
+	if unscoped {
+		q = q.Unscoped()
+	}
+	var n int64
+	if err := q.Count(&n).Error; err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	return n
+}
+
+// ====== 慢查询日志 ======
+
+// logBuffer 并发安全的日志缓冲区
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// find 返回所有 msg 匹配的 JSON 日志
+func (b *logBuffer) find(t *testing.T, msg string) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("日志不是 JSON: %q", line)
+		}
+		if m["msg"] == msg {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// captureLogs 测试期间把默认日志器换成写入缓冲区的 JSON 日志器
+func captureLogs(t *testing.T) *logBuffer {
+	t.Helper()
+	saved := applog.L()
+	t.Cleanup(func() { applog.SetDefault(saved) })
+
+	buf := &logBuffer{}
+	applog.SetDefault(applog.New(applog.Config{Format: "json", Output: buf}))
+	return buf
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	d := newTestDatabase(t)
+	createTestUsers(t, d, "alice")
+	ctx := applog.ContextWithRequestID(context.Background(), "req-slow")
+	if !d.redactParams.Load() || time.Duration(d.slowThreshold.Load()) != defaultSlowThreshold {
+		t.Errorf("默认配置应隐藏参数值，阈值为 %v", defaultSlowThreshold)
+	}
+
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		redact    bool
+		wantLog   bool
+		wantParam bool // SQL 中是否出现参数值
+	}{
+		{"隐藏参数值", time.Nanosecond, true, true, false},
+		{"关闭隐藏后输出完整 SQL", time.Nanosecond, false, true, true},
+		{"未超过阈值不记录", time.Hour, false, false, false},
+		{"阈值为 0 关闭慢查询日志", 0, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			d.SetSlowThreshold(tt.threshold)
+			d.SetRedactParams(tt.redact)
+
+			if _, err := d.GetUserByUsernameCtx(ctx, "s3cret-name"); err != nil {
+				t.Fatalf("GetUserByUsernameCtx() error = %v", err)
+			}
+
+			entries := logs.find(t, "slow query")
+			if !tt.wantLog {
+				if len(entries) != 0 {
+					t.Errorf("不应记录慢查询: %v", entries)
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("慢查询日志 %d 条, want 1", len(entries))
+			}
+			entry := entries[0]
+			sql, _ := entry["sql"].(string)
+			if got := strings.Contains(sql, "s3cret-name"); got != tt.wantParam {
+				t.Errorf("sql = %q, 包含参数值 = %v, want %v", sql, got, tt.wantParam)
+			}
+			if !tt.wantParam && !strings.Contains(sql, "?") {
+				t.Errorf("sql = %q, 应保留 ? 占位符", sql)
+			}
+			if entry["request_id"] != "req-slow" || entry["threshold"] != tt.threshold.String() || entry["duration"] == nil {
+				t.Errorf("日志 = %v", entry)
+			}
+		})
+	}
+}
+
+// ====== 查询操作 ======
+
+func TestListUsersKeyset(t *testing.T) {
+	d := newTestDatabase(t)
+	names := []string{"u01", "u02", "u03", "u04", "u05", "u06", "u07"}
+	created := createTestUsers(t, d, names...)
+	// 删除中间一条，游标分页应跳过 ID 空洞
+	if err := d.db.Delete(created[3]).Error; err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	var (
+		got   []string
+		pages int
+		after uint
+	)
+	for {
+		users, next, err := d.ListUsersKeyset(after, 2)
+		if err != nil {
+			t.Fatalf("ListUsersKeyset(%d, 2) error = %v", after, err)
+		}
+		pages++
+		if len(users) > 2 {
+			t.Fatalf("第 %d 页返回 %d 条, want <= 2", pages, len(users))
+		}
+		for _, u := range users {
+			got = append(got, u.Username)
+		}
+		if next == 0 {
+			break
+		}
+		if next != users[len(users)-1].ID {
+			t.Fatalf("nextCursor = %d, want 本页最后一条的 ID %d", next, users[len(users)-1].ID)
+		}
+		after = next
+	}
+
+	want := []string{"u01", "u02", "u03", "u05", "u06", "u07"}
+	if !slices.Equal(got, want) {
+		t.Errorf("逐页合并的结果 = %v, want %v（无遗漏、无重复）", got, want)
+	}
+	if pages != 3 {
+		t.Errorf("共 %d 页, want 3", pages)
+	}
+
+	// 数量正好整除时，最后一页之后不应再返回游标
+	users, next, _ := d.ListUsersKeyset(created[4].ID, 2)
+	if len(users) != 2 || next != 0 {
+		t.Errorf("最后满页返回 %d 条, nextCursor = %d, want 2 条且游标为 0", len(users), next)
+	}
+}
+
+func TestGetUserWithRecentPosts(t *testing.T) {
+	d := newTestDatabase(t)
+	users := createTestUsers(t, d, "alice", "bob")
+	now := time.Now()
+	day := 24 * time.Hour
+
+	// autoCreateTime 只在 CreatedAt 为零值时填充，这里显式指定时间
+	for _, p := range []Post{
+		{Title: "10 天前", UserID: users[0].ID, CreatedAt: now.Add(-10 * day)},
+		{Title: "1 天前", UserID: users[0].ID, CreatedAt: now.Add(-day)},
+		{Title: "3 天前", UserID: users[0].ID, CreatedAt: now.Add(-3 * day)},
+		{Title: "2 天前", UserID: users[0].ID, CreatedAt: now.Add(-2 * day)},
+		{Title: "bob 的帖子", UserID: users[1].ID, CreatedAt: now},
+	} {
+		if err := d.db.Create(&p).Error; err != nil {
+			t.Fatalf("创建帖子失败: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		since time.Time
+		limit int
+		want  []string
+	}{
+		{"按时间筛选并倒序", now.Add(-7 * day), 10, []string{"1 天前", "2 天前", "3 天前"}},
+		{"limit 截断", now.Add(-7 * day), 2, []string{"1 天前", "2 天前"}},
+		{"limit 为 0 不限制", now.Add(-7 * day), 0, []string{"1 天前", "2 天前", "3 天前"}},
+		{"limit 为负数不限制", time.Time{}, -1, []string{"1 天前", "2 天前", "3 天前", "10 天前"}},
+		{"没有符合条件的帖子", now.Add(day), 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := d.GetUserWithRecentPosts(users[0].ID, tt.since, tt.limit)
+			if err != nil {
+				t.Fatalf("GetUserWithRecentPosts() error = %v", err)
+			}
+			var got []string
+			for _, p := range user.Posts {
+				got = append(got, p.Title)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("预加载的帖子 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := d.GetUserWithRecentPosts(999, now, 10); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("用户不存在时 error = %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+// likeTestEmails 用户名和邮箱中含有 LIKE 通配符和转义字符的用户
+var likeTestEmails = []string{"50%off@x.com", "50xoff@x.com", "a_b@x.com", "axb@x.com", "c!d@x.com", "cd@x.com"}
+
+// createLikeTestUsers 按 likeTestEmails 创建用户，用户名与邮箱相同
+func createLikeTestUsers(t *testing.T, d *Database) {
+	t.Helper()
+	for _, email := range likeTestEmails {
+		if err := d.db.Create(&User{Username: email, Email: email}).Error; err != nil {
+			t.Fatalf("创建用户 %s 失败: %v", email, err)
+		}
+	}
+}
+
+func TestGetUsersByEmailPrefix(t *testing.T) {
+	d := newTestDatabase(t)
+	createLikeTestUsers(t, d)
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"50%", []string{"50%off@x.com"}},
+		{"a_", []string{"a_b@x.com"}},
+		{"c!", []string{"c!d@x.com"}},
+		{"a", []string{"a_b@x.com", "axb@x.com"}},
+		{"", likeTestEmails},
+		{"nobody", nil},
+	}
+	for _, tt := range tests {
+		users, err := d.GetUsersByEmailPrefix(tt.prefix)
+		if err != nil {
+			t.Fatalf("GetUsersByEmailPrefix(%q) error = %v", tt.prefix, err)
+		}
+		var got []string
+		for _, u := range users {
+			got = append(got, u.Email)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("GetUsersByEmailPrefix(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+// ====== Context 取消 ======
+
+func TestContextCancellation(t *testing.T) {
+	d := newTestDatabase(t)
+	alice := createTestUsers(t, d, "alice")[0]
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("已取消的 ctx 中止查询", func(t *testing.T) {
+		if _, err := d.GetUserByIDCtx(canceled, alice.ID); !errors.Is(err, context.Canceled) {
+			t.Errorf("GetUserByIDCtx() error = %v, want context.Canceled", err)
+		}
+		if _, err := d.CountUsersCtx(canceled); !errors.Is(err, context.Canceled) {
+			t.Errorf("CountUsersCtx() error = %v, want context.Canceled", err)
+		}
+		users := []User{{Username: "bob", Email: "bob@example.com"}}
+		if err := d.CreateUsersCtx(canceled, users); !errors.Is(err, context.Canceled) {
+			t.Errorf("CreateUsersCtx() error = %v, want context.Canceled", err)
+		}
+		if n := countUsers(t, d, true); n != 1 {
+			t.Errorf("用户数 = %d, want 1", n)
+		}
+	})
+
+	t.Run("事务中取消会整体回滚", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		err := d.TxCtx(ctx, func(txDB *Database) error {
+			if err := txDB.CreateUserCtx(ctx, &User{Username: "carol", Email: "carol@example.com"}); err != nil {
+				return err
+			}
+			cancel()
+			return txDB.CreateUserCtx(ctx, &User{Username: "dave", Email: "dave@example.com"})
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("TxCtx() error = %v, want context.Canceled", err)
+		}
+		if n := countUsers(t, d, true); n != 1 {
+			t.Errorf("用户数 = %d, want 1（carol 随事务回滚）", n)
+		}
+	})
+}
+
+// ====== 更新操作 ======
+
+func TestUpdateUserEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      uint
+		email   string
+		wantErr error
+	}{
+		{"更新成功", 1, "alice@new.com", nil},
+		{"邮箱没有变化", 1, "alice@example.com", nil},
+		{"用户不存在", 99, "ghost@example.com", ErrUserNotFound},
+		{"邮箱被其他用户占用", 1, "bob@example.com", ErrEmailTaken},
+		{"邮箱被软删除的用户占用", 1, "carol@example.com", ErrEmailTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDatabase(t)
+			users := createTestUsers(t, d, "alice", "bob", "carol")
+			d.db.Delete(users[2])
+
+			err := d.UpdateUserEmail(tt.id, tt.email)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUserEmail() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var alice User
+			d.db.First(&alice, 1)
+			want := "alice@example.com"
+			if tt.wantErr == nil && tt.id == 1 {
+				want = tt.email
+			}
+			if alice.Email != want {
+				t.Errorf("alice 的邮箱 = %q, want %q", alice.Email, want)
+			}
+		})
+	}
+}
+
+func TestUpdateUserEmailUniqueIndex(t *testing.T) {
+	d := newTestDatabase(t)
+	createTestUsers(t, d, "alice")
+
+	// 预检查之后、UPDATE 之前另一个请求抢先注册了同一个邮箱
+	var (
+		once   sync.Once
+		stolen bool
+	)
+	d.db.Callback().Update().Before("gorm:update").Register("test:steal_email", func(tx *gorm.DB) {
+		once.Do(func() {
+			err := tx.Session(&gorm.Session{NewDB: true}).Create(&User{Username: "mallory", Email: "taken@example.com"}).Error
+			stolen = err == nil
+		})
+	})
+
+	if err := d.UpdateUserEmail(1, "taken@example.com"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("UpdateUserEmail() error = %v, want 唯一索引冲突转换为 ErrEmailTaken", err)
+	}
+	if !stolen {
+		t.Fatal("没有模拟出并发注册，测试无效")
+	}
+}
+
+func TestBatchUpdateBalances(t *testing.T) {
+	d := newTestDatabase(t)
+	users := createTestUsers(t, d, "alice", "bob", "carol", "dave", "erin")
+	d.DeleteUser(users[4].ID)
+
+	balances := func() map[string]float64 {
+		var all []User
+		d.db.Unscoped().Order("id").Find(&all)
+		out := make(map[string]float64)
+		for _, u := range all {
+			out[u.Username] = u.Balance
+		}
+		return out
+	}
+
+	err := d.BatchUpdateBalances(map[uint]float64{
+		users[0].ID: 10,
+		users[1].ID: 20.5,
+		users[2].ID: 30,
+		users[4].ID: 50, // 软删除的用户不更新
+		9999:        99, // 不存在的 ID 被忽略
+	})
+	if err != nil {
+		t.Fatalf("BatchUpdateBalances() error = %v", err)
+	}
+
+	// 每个用户得到各自的余额，没有出现在 updates 中的用户不变
+	want := map[string]float64{"alice": 10, "bob": 20.5, "carol": 30, "dave": 0, "erin": 0}
+	if got := balances(); !maps.Equal(got, want) {
+		t.Errorf("余额 = %v, want %v", got, want)
+	}
+
+	if err := d.BatchUpdateBalances(nil); err != nil {
+		t.Errorf("BatchUpdateBalances(nil) error = %v", err)
+	}
+}
+
+func TestBatchUpdateBalancesChunks(t *testing.T) {
+	d := newTestDatabase(t)
+	users, err := d.SeedUsers(batchUpdateChunkSize*2 + 1)
+	if err != nil {
+		t.Fatalf("SeedUsers() error = %v", err)
+	}
+
+	// 超过 batchUpdateChunkSize 时分成多条 UPDATE，结果与单条相同
+	updates := make(map[uint]float64, len(users))
+	for i, u := range users {
+		updates[u.ID] = float64(i)
+	}
+	if err := d.BatchUpdateBalances(updates); err != nil {
+		t.Fatalf("BatchUpdateBalances() error = %v", err)
+	}
+
+	var all []User
+	d.db.Find(&all)
+	for _, u := range all {
+		if u.Balance != updates[u.ID] {
+			t.Fatalf("用户 %d 余额 = %v, want %v", u.ID, u.Balance, updates[u.ID])
+		}
+	}
+}
+
+// ====== 删除操作 ======
+
+func TestDeleteUsersByFilter(t *testing.T) {
+	d := newTestDatabase(t)
+	users := createTestUsers(t, d, "alice", "bob", "carol", "dave")
+
+	t.Run("空条件被拒绝", func(t *testing.T) {
+		for _, f := range []UserFilter{{}, {Hard: true}} {
+			if n, err := d.DeleteUsersByFilter(f); !errors.Is(err, ErrEmptyFilter) || n != 0 {
+				t.Errorf("DeleteUsersByFilter(%+v) = %d, %v, want 0, ErrEmptyFilter", f, n, err)
+			}
+		}
+		if _, err := d.DeleteUsersByCondition(nil); !errors.Is(err, ErrEmptyFilter) {
+			t.Errorf("DeleteUsersByCondition(nil) error = %v, want ErrEmptyFilter", err)
+		}
+		if n := countUsers(t, d, true); n != 4 {
+			t.Errorf("用户数 = %d, want 4", n)
+		}
+	})
+
+	t.Run("软删除", func(t *testing.T) {
+		n, err := d.DeleteUsersByFilter(UserFilter{IDs: []uint{users[0].ID, users[1].ID}})
+		if err != nil || n != 2 {
+			t.Fatalf("DeleteUsersByFilter() = %d, %v, want 2", n, err)
+		}
+		if got := countUsers(t, d, false); got != 2 {
+			t.Errorf("可见用户数 = %d, want 2", got)
+		}
+		// 软删除只设置 deleted_at，记录仍在表中
+		if got := countUsers(t, d, true); got != 4 {
+			t.Errorf("表中记录数 = %d, want 4", got)
+		}
+		// 已软删除的记录不会被再次软删除
+		if n, _ := d.DeleteUsersByFilter(UserFilter{Username: "alice"}); n != 0 {
+			t.Errorf("重复软删除影响 %d 行, want 0", n)
+		}
+	})
+
+	t.Run("硬删除包括已软删除的记录", func(t *testing.T) {
+		n, err := d.DeleteUsersByFilter(UserFilter{IDs: []uint{users[0].ID, users[2].ID}, Hard: true})
+		if err != nil || n != 2 {
+			t.Fatalf("DeleteUsersByFilter(Hard) = %d, %v, want 2", n, err)
+		}
+		if got := countUsers(t, d, true); got != 2 {
+			t.Errorf("表中记录数 = %d, want 2（bob 已软删除，dave 未删除）", got)
+		}
+	})
+
+	t.Run("邮箱前缀中的通配符按字面匹配", func(t *testing.T) {
+		d := newTestDatabase(t)
+		createLikeTestUsers(t, d)
+		n, err := d.DeleteUsersByFilter(UserFilter{EmailPrefix: "a_"})
+		if err != nil || n != 1 {
+			t.Errorf("DeleteUsersByFilter(EmailPrefix: a_) = %d, %v, want 1（只删除 a_b）", n, err)
+		}
+	})
+
+	t.Run("按条件删除", func(t *testing.T) {
+		n, err := d.DeleteUsersByCondition(map[string]interface{}{"username": "dave"})
+		if err != nil || n != 1 {
+			t.Errorf("DeleteUsersByCondition() = %d, %v, want 1", n, err)
+		}
+	})
+}
+
+// ====== 评论 ======
+
+// createTestPost 创建 user 的帖子
+func createTestPost(t *testing.T, d *Database, user *User, title string) *Post {
+	t.Helper()
+	post := &Post{Title: title, UserID: user.ID}
+	if err := d.db.Create(post).Error; err != nil {
+		t.Fatalf("创建帖子失败: %v", err)
+	}
+	return post
+}
+
+func TestCreateComment(t *testing.T) {
+	d := newTestDatabase(t)
+	users := createTestUsers(t, d, "alice", "bob")
+	post := createTestPost(t, d, users[0], "hello")
+	deleted := createTestPost(t, d, users[0], "deleted")
+	if err := d.db.Delete(deleted).Error; err != nil {
+		t.Fatalf("软删除帖子失败: %v", err)
+	}
+	if err := d.db.Delete(users[1]).Error; err != nil {
+		t.Fatalf("软删除用户失败: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		userID  uint
+		postID  uint
+		wantErr error
+	}{
+		{"成功", users[0].ID, post.ID, nil},
+		{"用户不存在", 999, post.ID, ErrUserNotFound},
+		{"用户已软删除", users[1].ID, post.ID, ErrUserNotFound},
+		{"帖子不存在", users[0].ID, 999, ErrPostNotFound},
+		{"帖子已软删除", users[0].ID, deleted.ID, ErrPostNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comment := &Comment{Content: tt.name, UserID: tt.userID, PostID: tt.postID}
+			err := d.CreateComment(comment)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateComment() error = %v, want %v", err, tt.wantErr)
+			}
+			if (comment.ID != 0) != (tt.wantErr == nil) {
+				t.Errorf("comment.ID = %d, 成功时才应该插入", comment.ID)
+			}
+		})
+	}
+
+	// 只插入评论本身，不保存关联的 User
+	comment := &Comment{Content: "with user", UserID: users[0].ID, PostID: post.ID, User: User{Username: "mallory", Email: "m@example.com"}}
+	if err := d.CreateComment(comment); err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+	if n := countUsers(t, d, true); n != 2 {
+		t.Errorf("用户数 = %d, want 2（不应该创建关联的用户）", n)
+	}
+}
+
+func TestGetPostComments(t *testing.T) {
+	d := newTestDatabase(t)
+	users := createTestUsers(t, d, "alice", "bob", "carol")
+	post := createTestPost(t, d, users[0], "hello")
+	other := createTestPost(t, d, users[0], "other")
+
+	// 插入顺序与时间顺序不同；c3、c4 同一时刻，按 ID 排序
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []Comment{
+		{Content: "c5", UserID: users[0].ID, PostID: post.ID, CreatedAt: base.Add(5 * time.Minute)},
+		{Content: "c1", UserID: users[1].ID, PostID: post.ID, CreatedAt: base.Add(time.Minute)},
+		{Content: "c3", UserID: users[2].ID, PostID: post.ID, CreatedAt: base.Add(3 * time.Minute)},
+		{Content: "c4", UserID: users[0].ID, PostID: post.ID, CreatedAt: base.Add(3 * time.Minute)},
+		{Content: "c2", UserID: users[1].ID, PostID: post.ID, CreatedAt: base.Add(2 * time.Minute)},
+		{Content: "other", UserID: users[0].ID, PostID: other.ID, CreatedAt: base},
+	} {
+		if err := d.CreateComment(&c); err != nil {
+			t.Fatalf("CreateComment() error = %v", err)
+		}
+	}
+	// 作者被软删除后评论仍然返回，User 为零值
+	if err := d.db.Delete(users[2]).Error; err != nil {
+		t.Fatalf("软删除用户失败: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		page, size  int
+		want        []string
+		wantAuthors []string
+	}{
+		{"第一页", 1, 2, []string{"c1", "c2"}, []string{"bob", "bob"}},
+		{"第二页，作者已删除", 2, 2, []string{"c3", "c4"}, []string{"", "alice"}},
+		{"最后一页不满", 3, 2, []string{"c5"}, []string{"alice"}},
+		{"超出最后一页", 4, 2, nil, nil},
+		{"page 很大", 1 << 40, 2, nil, nil},
+		{"page 为 0 修正为 1", 0, 2, []string{"c1", "c2"}, []string{"bob", "bob"}},
+		{"size 为 0 使用默认值", 1, 0, []string{"c1", "c2", "c3", "c4", "c5"}, []string{"bob", "bob", "", "alice", "alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comments, total, err := d.GetPostComments(post.ID, tt.page, tt.size)
+			if err != nil {
+				t.Fatalf("GetPostComments() error = %v", err)
+			}
+			if total != 5 {
+				t.Errorf("total = %d, want 5", total)
+			}
+			if comments == nil {
+				t.Error("没有评论时应该返回空切片而不是 nil")
+			}
+			var got, authors []string
+			for _, c := range comments {
+				got = append(got, c.Content)
+				authors = append(authors, c.User.Username)
+			}
+			if !slices.Equal(got, tt.want) || !slices.Equal(authors, tt.wantAuthors) {
+				t.Errorf("评论 = %v, 作者 = %q, want %v, %q", got, authors, tt.want, tt.wantAuthors)
+			}
+		})
+	}
+
+	t.Run("帖子不存在", func(t *testing.T) {
+		if _, _, err := d.GetPostComments(999, 1, 10); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("GetPostComments() error = %v, want ErrPostNotFound", err)
+		}
+	})
+}
+
+func TestDeleteComment(t *testing.T) {
+	d := newTestDatabase(t)
+	users := createTestUsers(t, d, "alice")
+	post := createTestPost(t, d, users[0], "hello")
+	comment := &Comment{Content: "bye", UserID: users[0].ID, PostID: post.ID}
+	if err := d.CreateComment(comment); err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+
+	if err := d.DeleteComment(comment.ID); err != nil {
+		t.Fatalf("DeleteComment() error = %v", err)
+	}
+	// 物理删除，Unscoped 也查不到
+	var n int64
+	d.db.Unscoped().Model(&Comment{}).Count(&n)
+	if n != 0 {
+		t.Errorf("评论数 = %d, want 0", n)
+	}
+	if err := d.DeleteComment(comment.ID); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("再次删除 error = %v, want ErrCommentNotFound", err)
+	}
+}
+
+// ====== 泛型仓储 ======
+
+func TestRepository(t *testing.T) {
+	d := newTestDatabase(t)
+	users := NewRepository[User](d.db)
+	posts := NewRepository[Post](d.db)
+
+	alice := &User{Username: "alice", Email: "alice@example.com"}
+	if err := users.Create(alice); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if alice.ID == 0 {
+		t.Fatal("Create() 没有回填主键")
+	}
+	users.Create(&User{Username: "bob", Email: "bob@example.org"})
+	for _, title := range []string{"p1", "p2"} {
+		posts.Create(&Post{Title: title, UserID: alice.ID})
+	}
+
+	got, err := users.FindByID(alice.ID)
+	if err != nil || got == nil || got.Username != "alice" {
+		t.Fatalf("FindByID(%d) = %+v, %v, want alice", alice.ID, got, err)
+	}
+	if got, err := users.FindByID(999); got != nil || err != nil {
+		t.Errorf("FindByID(999) = %+v, %v, want nil, nil", got, err)
+	}
+
+	found, err := users.Find("email LIKE ?", "%@example.com")
+	if err != nil || len(found) != 1 || found[0].Username != "alice" {
+		t.Errorf(`Find("email LIKE ?", "%%@example.com") = %v, %v, want [alice]`, found, err)
+	}
+	if all, _ := users.Find(); len(all) != 2 {
+		t.Errorf("Find() 返回 %d 条, want 2", len(all))
+	}
+	if ps, _ := posts.Find(map[string]interface{}{"user_id": alice.ID}); len(ps) != 2 {
+		t.Errorf("按 user_id 查询帖子返回 %d 条, want 2", len(ps))
+	}
+
+	got.Email = "alice@example.net"
+	if err := users.Update(got); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if reloaded, _ := users.FindByID(alice.ID); reloaded.Email != "alice@example.net" {
+		t.Errorf("Update() 后 Email = %q, want alice@example.net", reloaded.Email)
+	}
+
+	// User 有 DeletedAt，Delete 为软删除
+	if err := users.Delete(alice.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got, _ := users.FindByID(alice.ID); got != nil {
+		t.Errorf("删除后 FindByID() = %+v, want nil", got)
+	}
+	if n := countUsers(t, d, true); n != 2 {
+		t.Errorf("包括软删除的用户数 = %d, want 2", n)
+	}
+}
+
+// ====== 原生 SQL ======
+
+func TestRawToMaps(t *testing.T) {
+	d := newTestDatabase(t)
+	ctx := context.Background()
+	users := createTestUsers(t, d, "alice", "bob", "carol")
+	d.BatchUpdateBalances(map[uint]float64{users[0].ID: 10, users[1].ID: 10, users[2].ID: 20})
+
+	t.Run("GROUP BY 聚合", func(t *testing.T) {
+		rows, err := d.RawToMaps(ctx, "SELECT balance, COUNT(*) AS total FROM t_users GROUP BY balance ORDER BY balance")
+		if err != nil {
+			t.Fatalf("RawToMaps() error = %v", err)
+		}
+		want := []map[string]interface{}{
+			{"balance": 10.0, "total": int64(2)},
+			{"balance": 20.0, "total": int64(1)},
+		}
+		if !slices.EqualFunc(rows, want, maps.Equal) {
+			t.Errorf("RawToMaps() = %v, want %v", rows, want)
+		}
+	})
+
+	t.Run("NULL 和 []byte", func(t *testing.T) {
+		rows, err := d.RawToMaps(ctx, "SELECT NULL AS missing, CAST(? AS BLOB) AS raw", "hello")
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("RawToMaps() = %v, %v, want 一行", rows, err)
+		}
+		if v, ok := rows[0]["missing"]; !ok || v != nil {
+			t.Errorf("missing = %#v, want nil", v)
+		}
+		// []byte 转换为 string，序列化为 JSON 时不是 base64
+		if v := rows[0]["raw"]; v != "hello" {
+			t.Errorf("raw = %#v, want \"hello\"", v)
+		}
+	})
+
+	t.Run("没有结果返回空切片", func(t *testing.T) {
+		rows, err := d.RawToMaps(ctx, "SELECT id FROM t_users WHERE username = ?", "nobody")
+		if err != nil || rows == nil || len(rows) != 0 {
+			t.Errorf("RawToMaps() = %v, %v, want []", rows, err)
+		}
+	})
+
+	if _, err := d.RawToMaps(ctx, "SELECT * FROM no_such_table"); err == nil {
+		t.Error("查询不存在的表应该返回错误")
+	}
+}
+
+// ====== 事务内的 Database ======
+
+func TestTx(t *testing.T) {
+	errPostFailed := errors.New("post failed")
+	tests := []struct {
+		name      string
+		fn        func(txDB *Database) error
+		wantErr   error
+		wantUsers int64
+		wantPosts int64
+	}{
+		{
+			"全部成功时提交",
+			func(txDB *Database) error {
+				user := &User{Username: "alice", Email: "alice@example.com"}
+				if err := txDB.CreateUser(user); err != nil {
+					return err
+				}
+				return txDB.DB().Create(&Post{Title: "hello", UserID: user.ID}).Error
+			},
+			nil, 1, 1,
+		},
+		{
+			"后续步骤失败时回滚已创建的用户",
+			func(txDB *Database) error {
+				if err := txDB.CreateUser(&User{Username: "alice", Email: "alice@example.com"}); err != nil {
+					return err
+				}
+				return errPostFailed
+			},
+			errPostFailed, 0, 0,
+		},
+		{
+			"内层保存点失败只回滚内层",
+			func(txDB *Database) error {
+				if err := txDB.CreateUser(&User{Username: "alice", Email: "alice@example.com"}); err != nil {
+					return err
+				}
+				err := txDB.Tx(func(inner *Database) error {
+					if err := inner.CreateUser(&User{Username: "bob", Email: "bob@example.com"}); err != nil {
+						return err
+					}
+					return errPostFailed
+				})
+				if !errors.Is(err, errPostFailed) {
+					return fmt.Errorf("内层 Tx() error = %v, want errPostFailed", err)
+				}
+				return nil
+			},
+			nil, 1, 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDatabase(t)
+
+			if err := d.Tx(tt.fn); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Tx() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := countUsers(t, d, true); got != tt.wantUsers {
+				t.Errorf("用户数 = %d, want %d", got, tt.wantUsers)
+			}
+			var posts int64
+			d.db.Model(&Post{}).Count(&posts)
+			if posts != tt.wantPosts {
+				t.Errorf("帖子数 = %d, want %d", posts, tt.wantPosts)
+			}
+		})
+	}
+}
+
+func TestTxPanicRollsBack(t *testing.T) {
+	d := newTestDatabase(t)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recover() = %v, want boom", r)
+			}
+		}()
+		d.Tx(func(txDB *Database) error {
+			txDB.CreateUser(&User{Username: "alice", Email: "alice@example.com"})
+			panic("boom")
+		})
+	}()
+
+	if got := countUsers(t, d, true); got != 0 {
+		t.Errorf("panic 后用户数 = %d, want 0", got)
+	}
+}
+
+func TestTxCtxCanceled(t *testing.T) {
+	d := newTestDatabase(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := d.TxCtx(ctx, func(txDB *Database) error {
+		if err := txDB.CreateUser(&User{Username: "alice", Email: "alice@example.com"}); err != nil {
+			return err
+		}
+		cancel()
+		return txDB.DB().Create(&Post{Title: "hello"}).Error
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("TxCtx() error = %v, want context.Canceled", err)
+	}
+	if got := countUsers(t, d, true); got != 0 {
+		t.Errorf("ctx 取消后用户数 = %d, want 0", got)
+	}
+}
+
+// ====== 测试数据 ======
+
+func TestSeedUsers(t *testing.T) {
+	emails := func(users []User) []string {
+		var out []string
+		for _, u := range users {
+			out = append(out, u.Email)
+		}
+		return out
+	}
+
+	a, b := newTestDatabase(t), newTestDatabase(t)
+	a.SetSeed(42)
+	b.SetSeed(42)
+	usersA, err := a.SeedUsers(5)
+	if err != nil {
+		t.Fatalf("SeedUsers() error = %v", err)
+	}
+	usersB, _ := b.SeedUsers(5)
+
+	// 相同种子生成相同的数据
+	if !slices.Equal(emails(usersA), emails(usersB)) {
+		t.Errorf("相同种子生成的邮箱不同: %v vs %v", emails(usersA), emails(usersB))
+	}
+	for i, u := range usersA {
+		if u.ID == 0 || u.Username != fmt.Sprintf("user%d", i) || u.Age < 18 || u.Age > 79 {
+			t.Errorf("第 %d 个用户 = %+v", i, u)
+		}
+	}
+	if n := countUsers(t, a, false); n != 5 {
+		t.Errorf("用户数 = %d, want 5", n)
+	}
+
+	// 用户名有唯一索引，重复生成前需要 Truncate
+	if _, err := a.SeedUsers(1); err == nil {
+		t.Error("重复 SeedUsers() 应该因唯一索引冲突失败")
+	}
+	if users, err := a.SeedUsers(0); users != nil || err != nil {
+		t.Errorf("SeedUsers(0) = %v, %v, want nil, nil", users, err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	d := newTestDatabase(t)
+	users := createTestUsers(t, d, "alice", "bob")
+	post := Post{Title: "p", UserID: users[0].ID}
+	d.db.Create(&post)
+	d.db.Create(&Comment{Content: "c", UserID: users[1].ID, PostID: post.ID})
+	d.DeleteUser(users[1].ID) // 软删除的记录也要清空
+
+	count := func(model interface{}) int64 {
+		var n int64
+		d.db.Unscoped().Model(model).Count(&n)
+		return n
+	}
+
+	t.Run("只清空指定的表", func(t *testing.T) {
+		if err := d.Truncate(&Comment{}); err != nil {
+			t.Fatalf("Truncate(Comment) error = %v", err)
+		}
+		if count(&Comment{}) != 0 || count(&Post{}) != 1 || count(&User{}) != 2 {
+			t.Errorf("评论 %d、帖子 %d、用户 %d, want 0、1、2", count(&Comment{}), count(&Post{}), count(&User{}))
+		}
+	})
+
+	t.Run("不传参数时清空全部表", func(t *testing.T) {
+		if err := d.Truncate(); err != nil {
+			t.Fatalf("Truncate() error = %v", err)
+		}
+		if count(&Comment{}) != 0 || count(&Post{}) != 0 || count(&User{}) != 0 {
+			t.Errorf("评论 %d、帖子 %d、用户 %d, want 全部为 0", count(&Comment{}), count(&Post{}), count(&User{}))
+		}
+		// 清空后可以重新生成
+		if _, err := d.SeedUsers(3); err != nil {
+			t.Errorf("Truncate() 后 SeedUsers() error = %v", err)
+		}
+	})
+
+	if err := d.Truncate("not a model"); err == nil {
+		t.Error("Truncate(非模型) 应该返回错误")
+	}
+}
@@ -5,11 +5,25 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/austoin/GolangTutorial/logger"
+	"github.com/austoin/GolangTutorial/safego"
 )
 
 // ====== Redis 基础 ======
@@ -39,10 +53,28 @@ Redis 是一个高性能的键值存储系统，常用于缓存、消息队列
 type RedisClient struct {
 	client *redis.Client // Redis 客户端实例
 	ctx    context.Context
+
+	// 保活相关
+	healthy   atomic.Bool                     // 最近一次 PING 是否成功
+	ping      func(ctx context.Context) error // 健康检查函数，默认 PING
+	stop      chan struct{}                   // 通知保活协程退出
+	done      chan struct{}                   // 保活协程已退出
+	closeOnce sync.Once
+
+	loads loadGroup // CachedLoad 的进程内请求合并
 }
 
+// defaultKeepaliveInterval 默认保活间隔
+const defaultKeepaliveInterval = 10 * time.Second
+
 // NewRedisClient 创建 Redis 客户端
 func NewRedisClient(addr, password string, db int) (*RedisClient, error) {
+	return NewRedisClientWithKeepalive(addr, password, db, defaultKeepaliveInterval)
+}
+
+// NewRedisClientWithKeepalive 创建 Redis 客户端，并按 interval 定期 PING
+// go-redis 每次执行命令时会自动重连，保活的作用是把健康状态暴露给调用方
+func NewRedisClientWithKeepalive(addr, password string, db int, interval time.Duration) (*RedisClient, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr:     addr,     // Redis 地址，如 "localhost:6379"
 		Password: password, // 密码（为空表示不需要）
@@ -58,19 +90,91 @@ func NewRedisClient(addr, password string, db int) (*RedisClient, error) {
 		return nil, fmt.Errorf("连接 Redis 失败: %w", err)
 	}
 
-	log.Printf("Redis 连接成功: %s", addr)
+	logger.Info("Redis 连接成功", "addr", addr)
+
+	return newRedisClient(client, interval), nil
+}
 
-	return &RedisClient{
+// newRedisClient 包装已经连接的客户端，interval <= 0 时不启动保活
+// 测试中用来包装 testfixtures.NewTestRedis 返回的客户端
+func newRedisClient(client *redis.Client, interval time.Duration) *RedisClient {
+	r := &RedisClient{
 		client: client,
-		ctx:    ctx,
-	}, nil
+		ctx:    context.Background(),
+		ping: func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		},
+	}
+	r.healthy.Store(true)
+	r.startKeepalive(interval)
+	return r
 }
 
 // Close 关闭连接
 func (r *RedisClient) Close() error {
+	// 先停止保活协程，再关闭连接
+	r.closeOnce.Do(func() {
+		if r.stop != nil {
+			close(r.stop)
+			<-r.done
+		}
+	})
 	return r.client.Close()
 }
 
+// ====== 保活 ======
+
+// IsHealthy 返回最近一次 PING 是否成功
+// 可用于就绪检查（readiness probe）
+func (r *RedisClient) IsHealthy() bool {
+	return r.healthy.Load()
+}
+
+// startKeepalive 启动保活协程
+func (r *RedisClient) startKeepalive(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.keepalive(interval)
+}
+
+// keepalive 定期 PING，并在健康状态变化时记录日志
+func (r *RedisClient) keepalive(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.checkHealth(interval)
+		}
+	}
+}
+
+// checkHealth 执行一次 PING 并更新健康状态
+func (r *RedisClient) checkHealth(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(r.ctx, timeout)
+	defer cancel()
+
+	err := r.ping(ctx)
+	healthy := err == nil
+
+	// Swap 返回旧值，只在状态变化时打印日志
+	if prev := r.healthy.Swap(healthy); prev != healthy {
+		if healthy {
+			logger.Info("Redis 恢复健康")
+		} else {
+			logger.Warn("Redis 不健康", "err", err)
+		}
+	}
+}
+
 // Client 获取原生客户端
 func (r *RedisClient) Client() *redis.Client {
 	return r.client
@@ -136,6 +240,34 @@ func (r *RedisClient) SetEX(key string, value interface{}, expiration time.Durat
 	return r.client.Set(r.ctx, key, value, expiration).Err()
 }
 
+// ErrNotFound 键不存在
+// 比直接比较 redis.Nil 更明确，调用方使用 errors.Is(err, ErrNotFound) 判断
+var ErrNotFound = errors.New("redis: key not found")
+
+// GetSet 原子地设置新值并返回旧值
+// GETSET 命令自 Redis 6.2 起废弃，这里使用等价的 SET key value GET
+// 键原本不存在时新值仍会写入，返回 ErrNotFound
+// 注意：SET ... GET 会清除键原有的过期时间
+func (r *RedisClient) GetSet(key string, value interface{}) (old string, err error) {
+	// SET key value GET
+	old, err = r.client.SetArgs(r.ctx, key, value, redis.SetArgs{Get: true}).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return old, err
+}
+
+// GetDel 原子地获取并删除键（Redis 6.2+）
+// 键不存在时返回 ErrNotFound
+func (r *RedisClient) GetDel(key string) (string, error) {
+	// GETDEL key
+	val, err := r.client.GetDel(r.ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return val, err
+}
+
 // MGet 批量获取
 func (r *RedisClient) MGet(keys ...string) ([]interface{}, error) {
 	// MGET key [key ...]
@@ -143,11 +275,186 @@ func (r *RedisClient) MGet(keys ...string) ([]interface{}, error) {
 }
 
 // MSet 批量设置
+// 集群模式下所有键必须在同一个槽，见"集群多键操作"
 func (r *RedisClient) MSet(values ...interface{}) error {
 	// MSET key value [key value ...]
 	return r.client.MSet(r.ctx, values...).Err()
 }
 
+// ====== 带过期的计数器 ======
+
+// incrWithExpiryScript INCR 并在第一次递增时设置过期时间
+// 在一个脚本中完成，避免 INCR 和 EXPIRE 分两次调用时的竞态
+var incrWithExpiryScript = redis.NewScript(`
+	local n = redis.call("INCR", KEYS[1])
+	if n == 1 then
+		redis.call("PEXPIRE", KEYS[1], ARGV[1])
+	end
+	return n
+`)
+
+// IncrWithExpiry 递增计数器，第一次递增时设置过期时间
+// 适用于 API 配额：窗口内计数，窗口结束后自动清零
+func (r *RedisClient) IncrWithExpiry(key string, ttl time.Duration) (int64, error) {
+	return incrWithExpiryScript.Run(r.ctx, r.client, []string{key}, ttl.Milliseconds()).Int64()
+}
+
+// GetRemaining 返回剩余配额（limit - 当前计数，最小为 0）
+// 计数器不存在时返回 limit
+func (r *RedisClient) GetRemaining(key string, limit int64) (int64, error) {
+	n, err := r.client.Get(r.ctx, key).Int64()
+	if err == redis.Nil {
+		return limit, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if remaining := limit - n; remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+// FixedWindowAllow 固定窗口限流：每个 window 内最多放行 limit 次
+// count 为本窗口内（含本次）的请求数；被拒绝的请求同样计数
+//
+// 与 ratelimit 包的滑动窗口相比，每个 key 只占一个整数、一次脚本调用，适合高吞吐接口；
+// 代价是窗口交界处可能放行接近 2*limit 个请求（上个窗口末尾与下个窗口开头各 limit 个），
+// 需要严格平滑时使用滑动窗口
+func (r *RedisClient) FixedWindowAllow(key string, limit int, window time.Duration) (allowed bool, count int, err error) {
+	n, err := r.IncrWithExpiry(key, window)
+	if err != nil {
+		return false, 0, err
+	}
+	return n <= int64(limit), int(n), nil
+}
+
+// ====== 不低于零的扣减 ======
+/*
+库存、配额这类计数不能扣成负数。先 GET 判断再 DECRBY 有竞态：
+两个请求同时读到库存 1，都判断通过，最终库存变成 -1。
+
+DecrIfPositive 在一个 Lua 脚本中完成"检查 + 扣减"：
+
+  left, ok, err := rc.DecrIfPositive("stock:1001", 2)
+  if err != nil { ... }
+  if !ok {
+      // 库存不足，left 为当前库存（未被修改）
+  }
+
+key 不存在视为 0，任何正数的扣减都会被拒绝。
+DECRBY 不会改变 key 的过期时间。
+*/
+
+// decrIfPositiveScript 扣减后不小于 0 时才执行 DECRBY
+// 返回 {1, 新值} 或 {0, 当前值}
+var decrIfPositiveScript = redis.NewScript(`
+	local cur = tonumber(redis.call("GET", KEYS[1]) or "0")
+	if cur == nil then
+		return redis.error_reply("ERR value is not an integer")
+	end
+	local amount = tonumber(ARGV[1])
+	if cur - amount < 0 then
+		return {0, cur}
+	end
+	return {1, redis.call("DECRBY", KEYS[1], amount)}
+`)
+
+// DecrIfPositive 原子地把 key 减少 amount，结果小于 0 时拒绝
+// ok 为 true 时 newValue 是扣减后的值；为 false 时 newValue 是当前值，key 保持不变
+// amount 必须为正数
+func (r *RedisClient) DecrIfPositive(key string, amount int64) (newValue int64, ok bool, err error) {
+	if amount <= 0 {
+		return 0, false, fmt.Errorf("decrement amount must be positive, got %d", amount)
+	}
+
+	res, err := decrIfPositiveScript.Run(r.ctx, r.client, []string{key}, amount).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	return res[1], res[0] == 1, nil
+}
+
+// ====== 时间分桶计数器 ======
+/*
+按固定时长分桶计数，用于每分钟请求数之类的简单指标：
+
+  client.IncrBucket("metrics:api:requests", time.Minute)
+  series, _ := client.ReadBuckets("metrics:api:requests", time.Minute, 10) // 最近 10 分钟
+
+每个桶是一个普通的整数键 prefix:<桶编号>，桶编号 = Unix 时间 / 桶时长，
+同一时刻所有实例算出的编号相同，多实例的计数自然汇总到一起。
+
+保留时长：
+  每个桶在第一次递增时设置过期时间 bucketRetention 个桶时长，之后自动删除，
+  因此 ReadBuckets 最多能读到最近 bucketRetention 个桶，更早的桶返回 0。
+  需要长期保存时应定期读取并写入时序数据库。
+*/
+
+// bucketRetention 每个桶保留的桶时长数
+const bucketRetention = 10
+
+// IncrBucket 递增当前时间所在桶的计数，返回本桶递增后的值
+func (r *RedisClient) IncrBucket(prefix string, bucket time.Duration) (int64, error) {
+	return r.incrBucketAt(prefix, bucket, time.Now())
+}
+
+// ReadBuckets 读取最近 n 个桶（含当前桶）的计数，按时间从旧到新排列
+// 不存在或已过期的桶为 0
+func (r *RedisClient) ReadBuckets(prefix string, bucket time.Duration, n int) ([]int64, error) {
+	return r.readBucketsAt(prefix, bucket, n, time.Now())
+}
+
+func (r *RedisClient) incrBucketAt(prefix string, bucket time.Duration, now time.Time) (int64, error) {
+	if bucket <= 0 {
+		return 0, fmt.Errorf("invalid bucket duration: %s", bucket)
+	}
+	return r.IncrWithExpiry(bucketKey(prefix, bucketIndex(now, bucket)), bucket*bucketRetention)
+}
+
+func (r *RedisClient) readBucketsAt(prefix string, bucket time.Duration, n int, now time.Time) ([]int64, error) {
+	if bucket <= 0 {
+		return nil, fmt.Errorf("invalid bucket duration: %s", bucket)
+	}
+	if n <= 0 {
+		return []int64{}, nil
+	}
+
+	current := bucketIndex(now, bucket)
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = bucketKey(prefix, current-int64(n-1-i))
+	}
+
+	values, err := r.client.MGet(r.ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int64, n)
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue // 键不存在时为 nil
+		}
+		if counts[i], err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("bucket %s is not an integer: %w", keys[i], err)
+		}
+	}
+	return counts, nil
+}
+
+// bucketIndex 时间 t 所在的桶编号
+func bucketIndex(t time.Time, bucket time.Duration) int64 {
+	return t.UnixNano() / int64(bucket)
+}
+
+// bucketKey 桶对应的键名
+func bucketKey(prefix string, index int64) string {
+	return prefix + ":" + strconv.FormatInt(index, 10)
+}
+
 // ====== Hash 操作 ======
 
 // HSet 设置哈希字段
@@ -169,11 +476,36 @@ func (r *RedisClient) HGetAll(key string) (map[string]string, error) {
 }
 
 // HMSet 批量设置哈希字段
+// 注意：Redis 4.0 起 HMSET 已废弃，HSET 可以一次设置多个字段，新代码请使用 HSetMany
 func (r *RedisClient) HMSet(key string, values map[string]interface{}) error {
 	// HMSET key field value [field value ...]
 	return r.client.HMSet(r.ctx, key, values).Err()
 }
 
+// HSetMany 一次设置多个哈希字段，返回新增的字段数（已存在的字段只更新值，不计入）
+// values 为空时直接返回 0：不带字段的 HSET 会被 Redis 以参数个数错误拒绝
+func (r *RedisClient) HSetMany(key string, values map[string]interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	// HSET key field value [field value ...]
+	return r.client.HSet(r.ctx, key, values).Result()
+}
+
+// HRandField 随机返回 count 个字段（Redis 6.2+）
+// count > 0：字段不重复，最多返回全部字段
+// count < 0：可能重复，返回 |count| 个字段
+func (r *RedisClient) HRandField(key string, count int) ([]string, error) {
+	// HRANDFIELD key count
+	return r.client.HRandField(r.ctx, key, count).Result()
+}
+
+// HRandFieldWithValues 随机返回 count 个字段及其值，count 的含义同 HRandField
+func (r *RedisClient) HRandFieldWithValues(key string, count int) ([]redis.KeyValue, error) {
+	// HRANDFIELD key count WITHVALUES
+	return r.client.HRandFieldWithValues(r.ctx, key, count).Result()
+}
+
 // HMGet 批量获取哈希字段
 func (r *RedisClient) HMGet(key string, fields ...string) ([]interface{}, error) {
 	// HMGET key field [field ...]
@@ -204,6 +536,190 @@ func (r *RedisClient) HLen(key string) (int64, error) {
 	return r.client.HLen(r.ctx, key).Result()
 }
 
+// ====== Hash 结构体映射 ======
+/*
+HGetAll 返回 map[string]string，每个字段都要手动转换类型。
+HSetStruct / HGetStruct 按 redis 标签在结构体和哈希之间转换：
+
+  type Profile struct {
+      Name      string    `redis:"name"`
+      Age       int       `redis:"age"`
+      Score     float64   `redis:"score"`
+      VIP       bool      `redis:"vip"`
+      UpdatedAt time.Time `redis:"updated_at"`
+      Password  string    `redis:"-"` // 不写入 Redis
+      Nickname  string    // 没有标签时使用字段名 "Nickname"
+  }
+
+  client.HSetStruct("profile:1", &p)
+
+  var p Profile
+  err := client.HGetStruct("profile:1", &p)
+  if errors.Is(err, ErrNotFound) { ... }
+
+支持的字段类型：string、bool、各种整数和浮点数、time.Time（RFC 3339 格式，保留纳秒）。
+time.Duration 按整数纳秒保存。嵌入的结构体字段会展开到同一个哈希中。
+未导出的字段被忽略；其他类型（切片、map、指针等）返回错误，需要时先序列化为 JSON 字符串。
+
+HGetStruct 只覆盖哈希中存在的字段，不存在的字段保持 dest 原来的值。
+*/
+
+// timeType time.Time 的反射类型，time.Time 是结构体，需要单独处理而不是展开
+var timeType = reflect.TypeOf(time.Time{})
+
+// HSetStruct 把结构体字段写入哈希
+// v 必须是结构体或结构体指针
+func (r *RedisClient) HSetStruct(key string, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("HSetStruct: expected struct, got %T", v)
+	}
+
+	values := make(map[string]interface{})
+	err := walkHashFields(rv, func(name string, field reflect.Value) error {
+		s, err := formatHashValue(field)
+		if err != nil {
+			return fmt.Errorf("HSetStruct: field %s: %w", name, err)
+		}
+		values[name] = s
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil // HSET 不允许空字段列表
+	}
+	return r.client.HSet(r.ctx, key, values).Err()
+}
+
+// HGetStruct 读取哈希并写入 dest 的对应字段
+// dest 必须是结构体指针；键不存在时返回 ErrNotFound
+func (r *RedisClient) HGetStruct(key string, dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("HGetStruct: expected non-nil struct pointer, got %T", dest)
+	}
+
+	values, err := r.client.HGetAll(r.ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	// HGETALL 对不存在的键返回空结果
+	if len(values) == 0 {
+		return ErrNotFound
+	}
+
+	return walkHashFields(rv.Elem(), func(name string, field reflect.Value) error {
+		s, ok := values[name]
+		if !ok {
+			return nil
+		}
+		if err := parseHashValue(field, s); err != nil {
+			return fmt.Errorf("HGetStruct: field %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// walkHashFields 遍历结构体中参与映射的字段
+// 跳过未导出字段（包括未导出的嵌入类型）和 redis:"-"，嵌入的结构体递归展开
+func walkHashFields(rv reflect.Value, fn func(name string, field reflect.Value) error) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag := sf.Tag.Get("redis")
+		if tag == "-" {
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Type != timeType && tag == "" {
+			if err := walkHashFields(rv.Field(i), fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+		if err := fn(name, rv.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatHashValue 把字段值转换为字符串
+func formatHashValue(v reflect.Value) (string, error) {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// parseHashValue 把字符串解析后写入字段
+// 使用字段自身的位数解析，超出范围时返回错误而不是静默截断
+func parseHashValue(v reflect.Value, s string) error {
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
 // ====== List 操作 ======
 
 // LPush 从左侧插入
@@ -254,6 +770,37 @@ func (r *RedisClient) LSet(key string, index int64, value interface{}) error {
 	return r.client.LSet(r.ctx, key, index, value).Err()
 }
 
+// PushCapped 插入元素并裁剪列表，只保留最新的 maxLen 个
+// left 为 true 时 LPUSH（最新的在表头），否则 RPUSH（最新的在表尾）
+// 适合最近动态、操作日志等有界列表，返回裁剪后的长度
+func (r *RedisClient) PushCapped(key string, maxLen int64, left bool, values ...interface{}) (int64, error) {
+	if maxLen <= 0 {
+		return 0, fmt.Errorf("maxLen must be positive, got %d", maxLen)
+	}
+
+	// MULTI
+	// LPUSH key value [value ...]   或 RPUSH
+	// LTRIM key 0 maxLen-1          或 LTRIM key -maxLen -1
+	// EXEC
+	// 在事务中执行，其他客户端不会看到超出 maxLen 的中间状态
+	var push *redis.IntCmd
+	_, err := r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		if left {
+			push = pipe.LPush(r.ctx, key, values...)
+			pipe.LTrim(r.ctx, key, 0, maxLen-1)
+		} else {
+			push = pipe.RPush(r.ctx, key, values...)
+			pipe.LTrim(r.ctx, key, -maxLen, -1)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return min(push.Val(), maxLen), nil
+}
+
 // ====== Set 操作 ======
 
 // SAdd 添加集合成员
@@ -274,6 +821,21 @@ func (r *RedisClient) SIsMember(key string, member interface{}) (bool, error) {
 	return r.client.SIsMember(r.ctx, key, member).Result()
 }
 
+// SMIsMember 一次检查多个成员是否存在（Redis 6.2+）
+// 返回的切片与 members 一一对应
+func (r *RedisClient) SMIsMember(key string, members ...interface{}) ([]bool, error) {
+	// SMISMEMBER key member [member ...]
+	return r.client.SMIsMember(r.ctx, key, members...).Result()
+}
+
+// SRandMember 随机返回 count 个成员，不会删除成员
+// count > 0：成员不重复，最多返回整个集合
+// count < 0：可能重复，返回 |count| 个成员
+func (r *RedisClient) SRandMember(key string, count int) ([]string, error) {
+	// SRANDMEMBER key count
+	return r.client.SRandMemberN(r.ctx, key, int64(count)).Result()
+}
+
 // SCard 获取集合基数（大小）
 func (r *RedisClient) SCard(key string) (int64, error) {
 	// SCARD key
@@ -287,6 +849,7 @@ func (r *RedisClient) SRem(key string, members ...interface{}) (int64, error) {
 }
 
 // SInter 求交集
+// 集群模式下所有键必须在同一个槽，见"集群多键操作"
 func (r *RedisClient) SInter(keys ...string) ([]string, error) {
 	// SINTER key [key ...]
 	return r.client.SInter(r.ctx, keys...).Result()
@@ -353,85 +916,802 @@ func (r *RedisClient) ZRank(key, member string) (int64, error) {
 	return r.client.ZRank(r.ctx, key, member).Result()
 }
 
-// ====== 键操作 ======
+// ====== 排序 ======
+/*
+SORT 在服务端对 List、Set、ZSet 的成员排序，不修改原 key：
 
-// Exists 检查键是否存在
-func (r *RedisClient) Exists(keys ...string) (int64, error) {
-	// EXISTS key [key ...]
-	return r.client.Exists(r.ctx, keys...).Result()
+  SORT key [BY pattern] [LIMIT offset count] [GET pattern ...] [ASC | DESC] [ALPHA]
+
+  rc.Sort("scores", SortOptions{Order: "DESC", Count: 10})       // 数值降序前 10 个
+  rc.Sort("names", SortOptions{Alpha: true})                     // 按字典序
+  rc.Sort("user_ids", SortOptions{                               // 按外部 key 排序并取字段
+      By:  "user:*->age",
+      Get: []string{"#", "user:*->name"},                        // # 表示成员本身
+  })
+
+默认按数值排序，成员无法转换为数字时 Redis 报错，需要设置 Alpha。
+使用 Get 时每个成员返回 len(Get) 个值，外部 key 不存在时对应位置为空字符串。
+By 为 "nosort" 时跳过排序，常与 Get 配合只做批量取值。
+
+SORT 的复杂度为 O(N+M*log(M))，大集合排序会阻塞 Redis，应配合 Count 或在客户端排序。
+*/
+
+// SortOptions SORT 的参数
+type SortOptions struct {
+	By     string   // 按外部 key 排序，如 "weight_*"、"user:*->age"
+	Get    []string // 返回外部 key 的值而不是成员本身
+	Offset int64    // 跳过的元素个数
+	Count  int64    // 返回的元素个数，0 表示全部
+	Alpha  bool     // 按字典序而不是数值排序
+	Order  string   // "ASC"（默认）或 "DESC"，不区分大小写
 }
 
-// Del 删除键
-func (r *RedisClient) Del(keys ...string) (int64, error) {
-	// DEL key [key ...]
-	return r.client.Del(r.ctx, keys...).Result()
+// toRedis 校验参数并转换为 go-redis 的 Sort
+func (o SortOptions) toRedis() (*redis.Sort, error) {
+	order := strings.ToUpper(o.Order)
+	if order != "" && order != "ASC" && order != "DESC" {
+		return nil, fmt.Errorf("sort: invalid order %q, want ASC or DESC", o.Order)
+	}
+	if o.Offset < 0 || o.Count < 0 {
+		return nil, fmt.Errorf("sort: offset and count must be non-negative, got %d and %d", o.Offset, o.Count)
+	}
+
+	// 只设置 Offset 时 LIMIT offset 0 会返回空结果，-1 表示取到末尾
+	count := o.Count
+	if o.Offset > 0 && count == 0 {
+		count = -1
+	}
+	return &redis.Sort{
+		By:     o.By,
+		Offset: o.Offset,
+		Count:  count,
+		Get:    o.Get,
+		Order:  order,
+		Alpha:  o.Alpha,
+	}, nil
 }
 
-// Expire 设置过期时间
-func (r *RedisClient) Expire(key string, expiration time.Duration) (bool, error) {
-	// EXPIRE key seconds
-	return r.client.Expire(r.ctx, key, expiration).Result()
+// Sort 对 List、Set 或 ZSet 排序，返回排序后的成员（或 Get 指定的值）
+func (r *RedisClient) Sort(key string, opts SortOptions) ([]string, error) {
+	args, err := opts.toRedis()
+	if err != nil {
+		return nil, err
+	}
+
+	vals, err := r.client.Sort(r.ctx, key, args).Result()
+	if err != nil {
+		// 数值排序遇到非数字成员：ERR One or more scores can't be converted into double
+		if !opts.Alpha && strings.Contains(err.Error(), "converted into double") {
+			return nil, fmt.Errorf("sort %s: members are not numeric, set Alpha for lexicographic sort: %w", key, err)
+		}
+		return nil, err
+	}
+	return vals, nil
 }
 
-// TTL 获取剩余过期时间
-func (r *RedisClient) TTL(key string) (time.Duration, error) {
-	// TTL key
-	return r.client.TTL(r.ctx, key).Result()
+// SortInts 与 Sort 相同，结果解析为整数
+// 任一结果不是整数（包括 Get 取到的空值）时返回错误
+func (r *RedisClient) SortInts(key string, opts SortOptions) ([]int64, error) {
+	vals, err := r.Sort(key, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	nums := make([]int64, len(vals))
+	for i, v := range vals {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("sort %s: result %q at index %d is not an integer", key, v, i)
+		}
+		nums[i] = n
+	}
+	return nums, nil
 }
 
-// Rename 重命名键
-func (r *RedisClient) Rename(key, newkey string) error {
-	// RENAME key newkey
-	return r.client.Rename(r.ctx, key, newkey).Err()
+// ====== 排行榜 ======
+
+// ScoreEntry 排行榜条目
+type ScoreEntry struct {
+	Member string  // 成员（玩家）
+	Score  float64 // 分数
+	Rank   int64   // 排名，从 1 开始
 }
 
-// Type 获取键类型
-func (r *RedisClient) Type(key string) (string, error) {
-	// TYPE key
-	return r.client.Type(r.ctx, key).Result()
+// Leaderboard 基于 ZSet 的排行榜
+// 分数越高排名越靠前
+type Leaderboard struct {
+	r   *RedisClient
+	key string // 排行榜对应的 ZSet 键
 }
 
-// ====== 过期操作 ======
+// NewLeaderboard 创建绑定到指定键的排行榜
+func NewLeaderboard(r *RedisClient, key string) *Leaderboard {
+	return &Leaderboard{r: r, key: key}
+}
 
-// Persist 移除过期时间
-func (r *RedisClient) Persist(key string) (bool, error) {
-	// PERSIST key
-	return r.client.Persist(r.ctx, key).Result()
+// AddScore 增加成员分数，返回新的分数
+func (l *Leaderboard) AddScore(member string, delta float64) (float64, error) {
+	// ZINCRBY key increment member
+	// 成员不存在时会自动创建
+	return l.r.client.ZIncrBy(l.r.ctx, l.key, delta, member).Result()
 }
 
-// ====== 管道操作 ======
+// TopN 获取前 n 名
+func (l *Leaderboard) TopN(n int64) ([]ScoreEntry, error) {
+	if n <= 0 {
+		return nil, nil
+	}
 
-// Pipeline 管道操作示例
-func (r *RedisClient) PipelineExample() {
-	// 创建管道
-	pipe := r.client.Pipeline()
+	// ZREVRANGE key 0 n-1 WITHSCORES
+	zs, err := l.r.client.ZRevRangeWithScores(l.r.ctx, l.key, 0, n-1).Result()
+	if err != nil {
+		return nil, err
+	}
 
-	// 添加命令到管道
-	incr := pipe.Incr(r.ctx, "pipeline_counter")
-	pipe.Expire(r.ctx, "pipeline_counter", time.Hour)
+	return toScoreEntries(zs, 0), nil
+}
 
-	// 执行管道中的所有命令
-	_, err := pipe.Exec(r.ctx)
+// RankOf 获取成员排名（从 1 开始）
+// 成员不存在时返回 redis.Nil
+func (l *Leaderboard) RankOf(member string) (int64, error) {
+	// ZREVRANK key member
+	// 返回的排名从 0 开始
+	rank, err := l.r.client.ZRevRank(l.r.ctx, l.key, member).Result()
 	if err != nil {
-		log.Printf("管道执行失败: %v", err)
-		return
+		return 0, err
 	}
-
-	// 获取结果
-	fmt.Println("管道计数器:", incr.Val())
+	return rank + 1, nil
 }
 
-// ====== 事务操作 ======
+// Around 获取成员前后 radius 名之内的条目（包含成员自己）
+// 在榜首或榜尾时窗口会被截断
+func (l *Leaderboard) Around(member string, radius int64) ([]ScoreEntry, error) {
+	rank, err := l.r.client.ZRevRank(l.r.ctx, l.key, member).Result()
+	if err != nil {
+		return nil, err
+	}
 
-// TxPipelined 事务管道
-func (r *RedisClient) TxPipelinedExample() {
-	var incr int64
+	start := rank - radius
+	if start < 0 {
+		start = 0
+	}
+	stop := rank + radius
 
-	// Watch 监控键
-	err := r.client.Watch(r.ctx, func(tx *redis.Tx) error {
-		// 获取当前值
-		n, err := tx.Get(r.ctx, "counter").Int()
-		if err != nil && err != redis.Nil {
+	// 超出末尾的 stop 会被 Redis 自动截断
+	zs, err := l.r.client.ZRevRangeWithScores(l.r.ctx, l.key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return toScoreEntries(zs, start), nil
+}
+
+// toScoreEntries 把 redis.Z 转换为 ScoreEntry
+// offset 是第一条数据的排名（从 0 开始）
+func toScoreEntries(zs []redis.Z, offset int64) []ScoreEntry {
+	entries := make([]ScoreEntry, 0, len(zs))
+	for i, z := range zs {
+		member, _ := z.Member.(string)
+		entries = append(entries, ScoreEntry{
+			Member: member,
+			Score:  z.Score,
+			Rank:   offset + int64(i) + 1,
+		})
+	}
+	return entries
+}
+
+// ====== 延时队列 ======
+
+// DelayQueue 基于 ZSet 的延时队列
+// score 为任务的执行时间戳（毫秒），到期的任务可以被取出执行
+type DelayQueue struct {
+	r   *RedisClient
+	key string // 延时队列对应的 ZSet 键
+}
+
+// NewDelayQueue 创建绑定到指定键的延时队列
+func NewDelayQueue(r *RedisClient, key string) *DelayQueue {
+	return &DelayQueue{r: r, key: key}
+}
+
+// popReadyScript 原子地取出到期任务
+// ZRANGEBYSCORE 和 ZREM 在同一个脚本中执行，多个 worker 不会领取到同一个任务
+var popReadyScript = redis.NewScript(`
+	local members = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+	if #members > 0 then
+		redis.call("ZREM", KEYS[1], unpack(members))
+	end
+	return members
+`)
+
+// Schedule 安排任务在 runAt 时执行
+// 同一个 member 重复调度会更新执行时间
+func (q *DelayQueue) Schedule(member string, runAt time.Time) error {
+	// ZADD key score member
+	return q.r.client.ZAdd(q.r.ctx, q.key, redis.Z{
+		Score:  float64(runAt.UnixMilli()),
+		Member: member,
+	}).Err()
+}
+
+// PopReady 取出最多 limit 个在 now 之前到期的任务
+func (q *DelayQueue) PopReady(now time.Time, limit int64) ([]string, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	members, err := popReadyScript.Run(q.r.ctx, q.r.client,
+		[]string{q.key}, now.UnixMilli(), limit).StringSlice()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return members, err
+}
+
+// delayQueueBatch PollLoop 每次最多取出的任务数
+const delayQueueBatch = 100
+
+// PollLoop 每隔 interval 取出到期任务并交给 handler 处理
+// 阻塞直到 ctx 取消，返回 ctx.Err()
+// handler 返回的错误只记录日志，任务不会重新入队
+func (q *DelayQueue) PollLoop(ctx context.Context, interval time.Duration, handler func(member string) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		members, err := q.PopReady(time.Now(), delayQueueBatch)
+		if err != nil {
+			logger.Error("取出延时任务失败", "key", q.key, "err", err)
+			continue
+		}
+
+		for _, m := range members {
+			if err := handler(m); err != nil {
+				logger.Error("处理延时任务失败", "key", q.key, "member", m, "err", err)
+			}
+		}
+	}
+}
+
+// ====== 可靠队列 Worker ======
+/*
+用 List 做队列时，BRPOP 取出后 worker 崩溃，任务就丢了。
+QueueWorker 使用 BLMOVE 把任务原子地从队列移到 processing 列表，处理完成后再删除：
+
+  生产者  LPUSH jobs        ──▶ [jobs]
+  worker  BLMOVE jobs → jobs:processing   取出并保留副本
+          handler(job)
+            成功   LREM jobs:processing          HDEL jobs:attempts
+            失败   HINCRBY jobs:attempts job 1
+                   未达上限 → 移回 jobs 末尾重试
+                   达到上限 → 移到 jobs:dead（死信队列）
+
+  w := NewQueueWorker(rc, "jobs", func(ctx context.Context, job string) error {
+      return sendEmail(ctx, job)
+  }, QueueWorkerOptions{Concurrency: 4, MaxAttempts: 5})
+
+  w.Recover()         // 可选：把上次崩溃遗留在 processing 中的任务放回队列
+  err := w.Run(ctx)   // 阻塞，ctx 取消后不再取新任务，等待处理中的任务结束
+
+注意：
+  - 失败次数以任务内容为键，内容相同的任务共享计数，任务中应带唯一 ID
+  - 关闭时 handler 因 ctx 取消返回的错误不计入失败次数，任务直接放回队列
+  - 死信队列需要人工或定时任务处理（例如修复后 LMOVE 回 jobs）
+*/
+
+const (
+	defaultQueueMaxAttempts  = 3
+	defaultQueueBlockTimeout = time.Second
+)
+
+// QueueWorkerOptions QueueWorker 配置
+type QueueWorkerOptions struct {
+	Concurrency  int           // 并发 worker 数，默认 1
+	MaxAttempts  int           // 最多处理次数（含第一次），默认 3
+	BlockTimeout time.Duration // 每次 BLMOVE 的阻塞时间，也是关闭时最长的等待时间，默认 1s
+}
+
+// QueueWorker 从 List 队列中可靠地消费任务
+type QueueWorker struct {
+	r       *RedisClient
+	queue   string // 待处理队列，生产者 LPUSH
+	handler func(ctx context.Context, job string) error
+	opts    QueueWorkerOptions
+}
+
+// NewQueueWorker 创建消费 queue 的 worker
+// 辅助键 queue:processing、queue:attempts、queue:dead 由 worker 维护
+func NewQueueWorker(r *RedisClient, queue string, handler func(ctx context.Context, job string) error, opts QueueWorkerOptions) *QueueWorker {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultQueueMaxAttempts
+	}
+	if opts.BlockTimeout <= 0 {
+		opts.BlockTimeout = defaultQueueBlockTimeout
+	}
+	return &QueueWorker{r: r, queue: queue, handler: handler, opts: opts}
+}
+
+func (w *QueueWorker) processingKey() string { return w.queue + ":processing" }
+func (w *QueueWorker) attemptsKey() string   { return w.queue + ":attempts" }
+
+// DeadLetterKey 死信队列的键
+func (w *QueueWorker) DeadLetterKey() string { return w.queue + ":dead" }
+
+// Enqueue 把任务加入队列
+func (w *QueueWorker) Enqueue(jobs ...interface{}) error {
+	return w.r.client.LPush(w.r.ctx, w.queue, jobs...).Err()
+}
+
+// Recover 把 processing 列表中遗留的任务全部放回队列，返回放回的个数
+// 只能在没有 worker 运行时调用，否则会把正在处理的任务重复投递
+func (w *QueueWorker) Recover() (int64, error) {
+	var n int64
+	for {
+		// LMOVE processing queue RIGHT RIGHT：放到队列的出队端，优先处理
+		err := w.r.client.LMove(w.r.ctx, w.processingKey(), w.queue, "RIGHT", "RIGHT").Err()
+		if err == redis.Nil {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// Run 启动 Concurrency 个 worker，阻塞直到 ctx 取消且所有处理中的任务结束
+// 正常关闭时返回 ctx.Err()
+func (w *QueueWorker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// loop 单个 worker 的取任务循环
+func (w *QueueWorker) loop(ctx context.Context) {
+	// Redis 操作使用不会被取消的 ctx：
+	// 阻塞中的 BLMOVE 被取消时，任务可能已经移到 processing 却没有被处理；
+	// 处理结果也必须在关闭时写回。BlockTimeout 保证最多等待这么久就能检查 ctx
+	rctx := context.WithoutCancel(ctx)
+
+	for ctx.Err() == nil {
+		// BLMOVE queue processing RIGHT LEFT timeout
+		job, err := w.r.client.BLMove(rctx, w.queue, w.processingKey(), "RIGHT", "LEFT", w.opts.BlockTimeout).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			logger.Error("取出队列任务失败", "queue", w.queue, "err", err)
+			// 连接异常时避免空转
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.opts.BlockTimeout):
+			}
+			continue
+		}
+
+		w.process(ctx, rctx, job)
+	}
+}
+
+// process 处理一个任务并记录结果
+func (w *QueueWorker) process(ctx, rctx context.Context, job string) {
+	err := safego.Run(func() error {
+		return w.handler(ctx, job)
+	})
+
+	switch {
+	case err == nil:
+		err = w.finish(rctx, job)
+	case ctx.Err() != nil:
+		// 关闭过程中被中断，不算失败
+		err = w.requeue(rctx, job)
+	default:
+		err = w.fail(rctx, job, err)
+	}
+	if err != nil {
+		logger.Error("更新队列任务状态失败", "queue", w.queue, "job", job, "err", err)
+	}
+}
+
+// finish 处理成功：从 processing 中删除任务并清除失败次数
+func (w *QueueWorker) finish(ctx context.Context, job string) error {
+	_, err := w.r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, w.processingKey(), 1, job)
+		pipe.HDel(ctx, w.attemptsKey(), job)
+		return nil
+	})
+	return err
+}
+
+// requeue 把任务从 processing 移回队列末尾
+func (w *QueueWorker) requeue(ctx context.Context, job string) error {
+	_, err := w.r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, w.processingKey(), 1, job)
+		pipe.LPush(ctx, w.queue, job)
+		return nil
+	})
+	return err
+}
+
+// fail 记录一次失败，达到 MaxAttempts 时移入死信队列
+func (w *QueueWorker) fail(ctx context.Context, job string, handlerErr error) error {
+	attempts, err := w.r.client.HIncrBy(ctx, w.attemptsKey(), job, 1).Result()
+	if err != nil {
+		return err
+	}
+
+	if attempts < int64(w.opts.MaxAttempts) {
+		logger.Warn("队列任务处理失败，稍后重试", "queue", w.queue, "job", job, "attempts", attempts, "err", handlerErr)
+		return w.requeue(ctx, job)
+	}
+
+	logger.Error("队列任务多次失败，移入死信队列", "queue", w.queue, "job", job, "attempts", attempts, "err", handlerErr)
+	_, err = w.r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, w.processingKey(), 1, job)
+		pipe.LPush(ctx, w.DeadLetterKey(), job)
+		pipe.HDel(ctx, w.attemptsKey(), job)
+		return nil
+	})
+	return err
+}
+
+// ====== 键操作 ======
+
+// Exists 检查键是否存在
+func (r *RedisClient) Exists(keys ...string) (int64, error) {
+	// EXISTS key [key ...]
+	return r.client.Exists(r.ctx, keys...).Result()
+}
+
+// Del 删除键
+func (r *RedisClient) Del(keys ...string) (int64, error) {
+	// DEL key [key ...]
+	return r.client.Del(r.ctx, keys...).Result()
+}
+
+// Expire 设置过期时间
+func (r *RedisClient) Expire(key string, expiration time.Duration) (bool, error) {
+	// EXPIRE key seconds
+	return r.client.Expire(r.ctx, key, expiration).Result()
+}
+
+// TTL 获取剩余过期时间
+func (r *RedisClient) TTL(key string) (time.Duration, error) {
+	// TTL key
+	return r.client.TTL(r.ctx, key).Result()
+}
+
+// Rename 重命名键
+func (r *RedisClient) Rename(key, newkey string) error {
+	// RENAME key newkey
+	return r.client.Rename(r.ctx, key, newkey).Err()
+}
+
+// Type 获取键类型
+func (r *RedisClient) Type(key string) (string, error) {
+	// TYPE key
+	return r.client.Type(r.ctx, key).Result()
+}
+
+// RenameNX newkey 不存在时才重命名，返回是否重命名
+// 源键不存在时返回 ErrNotFound
+func (r *RedisClient) RenameNX(key, newkey string) (bool, error) {
+	// RENAMENX key newkey
+	ok, err := r.client.RenameNX(r.ctx, key, newkey).Result()
+	if isNoSuchKey(err) {
+		return false, ErrNotFound
+	}
+	return ok, err
+}
+
+// SafeRename 与 Rename 相同，源键不存在时返回 ErrNotFound 而不是 "ERR no such key"
+// newkey 已存在时会被覆盖，不想覆盖时使用 RenameNX
+func (r *RedisClient) SafeRename(key, newkey string) error {
+	err := r.Rename(key, newkey)
+	if isNoSuchKey(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// isNoSuchKey RENAME/RENAMENX 的源键不存在时 Redis 返回 "ERR no such key"
+func isNoSuchKey(err error) bool {
+	var rerr redis.Error
+	return errors.As(err, &rerr) && strings.Contains(rerr.Error(), "no such key")
+}
+
+// delIfTypeScript TYPE 与 ARGV[1] 相同时删除，TYPE 的返回值在 Lua 中是 {ok = "string"}
+var delIfTypeScript = redis.NewScript(`
+	if redis.call("TYPE", KEYS[1]).ok == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0
+`)
+
+// DelIfType 键的类型是 expectedType 时才删除，返回是否删除
+// 键被改作其他类型使用时（例如缓存的 string 变成了别处写入的 hash）不会误删
+// expectedType 取值与 TYPE 命令相同：string、list、set、zset、hash、stream
+func (r *RedisClient) DelIfType(key, expectedType string) (bool, error) {
+	expectedType = strings.ToLower(expectedType)
+	switch expectedType {
+	case "string", "list", "set", "zset", "hash", "stream":
+	default:
+		return false, fmt.Errorf("unknown redis type %q", expectedType)
+	}
+
+	n, err := delIfTypeScript.Run(r.ctx, r.client, []string{key}, expectedType).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ====== 复制与迁移 ======
+/*
+COPY 在服务端复制一个键（值、类型和过期时间），数据不经过客户端，适合预热缓存：
+
+  rc.Copy("config:v2", "config:current", true) // 覆盖 config:current
+
+DUMP / RESTORE 把键序列化为 Redis 内部格式再还原，可以在不同数据库、不同实例之间搬运：
+
+  data, _ := src.Dump("user:1")
+  dst.Restore("user:1", ttl, data, false)
+
+注意：
+  - DUMP 的格式带有 RDB 版本号，只能还原到相同或更高版本的 Redis
+  - DUMP 不包含过期时间，需要先用 PTTL 读出后传给 Restore
+  - 集群模式下 COPY 的两个键必须在同一个槽
+*/
+
+// ErrKeyExists 目标键已存在
+var ErrKeyExists = errors.New("redis: key already exists")
+
+// Copy 在当前数据库内复制键，返回是否复制（Redis 6.2+）
+// replace 为 false 且 dst 已存在时返回 false；src 不存在时返回 ErrNotFound
+func (r *RedisClient) Copy(src, dst string, replace bool) (bool, error) {
+	return r.CopyToDB(src, dst, r.client.Options().DB, replace)
+}
+
+// CopyToDB 把键复制到编号为 db 的数据库，规则与 Copy 相同
+func (r *RedisClient) CopyToDB(src, dst string, db int, replace bool) (bool, error) {
+	// COPY source destination DB db [REPLACE]
+	n, err := r.client.Copy(r.ctx, src, dst, db, replace).Result()
+	if err != nil {
+		return false, err
+	}
+	if n == 1 {
+		return true, nil
+	}
+
+	// COPY 在源键不存在和目标键已存在时都返回 0，需要再检查一次源键
+	exists, err := r.client.Exists(r.ctx, src).Result()
+	if err != nil {
+		return false, err
+	}
+	if exists == 0 {
+		return false, ErrNotFound
+	}
+	return false, nil
+}
+
+// Dump 序列化键的值，键不存在时返回 ErrNotFound
+func (r *RedisClient) Dump(key string) ([]byte, error) {
+	// DUMP key
+	data, err := r.client.Dump(r.ctx, key).Result()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(data), nil
+}
+
+// Restore 用 Dump 的结果创建键，ttl 为 0 表示不过期
+// replace 为 false 且键已存在时返回 ErrKeyExists
+func (r *RedisClient) Restore(key string, ttl time.Duration, data []byte, replace bool) error {
+	var err error
+	if replace {
+		// RESTORE key ttl serialized-value REPLACE
+		err = r.client.RestoreReplace(r.ctx, key, ttl, string(data)).Err()
+	} else {
+		// RESTORE key ttl serialized-value
+		err = r.client.Restore(r.ctx, key, ttl, string(data)).Err()
+	}
+
+	var rerr redis.Error
+	if errors.As(err, &rerr) && strings.HasPrefix(rerr.Error(), "BUSYKEY") {
+		return ErrKeyExists
+	}
+	return err
+}
+
+// ====== 过期操作 ======
+
+// Persist 移除过期时间
+func (r *RedisClient) Persist(key string) (bool, error) {
+	// PERSIST key
+	return r.client.Persist(r.ctx, key).Result()
+}
+
+// ExpireWithOption 按条件设置过期时间（Redis 7.0+）
+// opt 取值（不区分大小写）：
+//   - NX：键没有过期时间时才设置
+//   - XX：键已有过期时间时才设置
+//   - GT：新的过期时间大于当前值时才设置（只延长；没有过期时间视为无限长，不会设置）
+//   - LT：新的过期时间小于当前值时才设置（只缩短；没有过期时间视为无限长，总会设置）
+//
+// 返回是否设置成功，条件不满足或键不存在时返回 false
+func (r *RedisClient) ExpireWithOption(key string, ttl time.Duration, opt string) (bool, error) {
+	switch strings.ToUpper(opt) {
+	case "NX":
+		// EXPIRE key seconds NX
+		return r.client.ExpireNX(r.ctx, key, ttl).Result()
+	case "XX":
+		// EXPIRE key seconds XX
+		return r.client.ExpireXX(r.ctx, key, ttl).Result()
+	case "GT":
+		// EXPIRE key seconds GT
+		return r.client.ExpireGT(r.ctx, key, ttl).Result()
+	case "LT":
+		// EXPIRE key seconds LT
+		return r.client.ExpireLT(r.ctx, key, ttl).Result()
+	default:
+		return false, fmt.Errorf("不支持的过期选项 %q，可选值：NX、XX、GT、LT", opt)
+	}
+}
+
+// ExpireMany 使用管道为多个键设置相同的过期时间
+// 返回成功设置的键数量（不存在的键不计入）
+func (r *RedisClient) ExpireMany(keys []string, ttl time.Duration) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	// 一次网络往返发送所有 EXPIRE 命令
+	cmds := make([]*redis.BoolCmd, len(keys))
+	_, err := r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Expire(r.ctx, key, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for _, cmd := range cmds {
+		if cmd.Val() {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ====== 批量读取与删除 ======
+/*
+MGET 一次取多个键，但只要有一个键不是 String 类型，整条命令就返回 WRONGTYPE，其他键的值也拿不到；
+在 Cluster 中还要求所有键在同一个槽（见 MGetClusterSafe）。逐个 GET 则每个键一次网络往返。
+
+BatchGet / BatchDelete 在管道中为每个键发送一条单键命令：
+  - 仍然只有一次网络往返（键很多时每 batchPipelineSize 个一批）
+  - 每条命令的结果独立，一个键出错不影响其他键，错误按键返回
+
+  values, errs := rc.BatchGet([]string{"user:1", "user:2", "queue:jobs"})
+  // values = {"user:1": "..."}          user:2 不存在，不在 map 中，也不算错误
+  // errs   = ["GET queue:jobs: WRONGTYPE ..."]
+
+DEL 可以删除任何类型的键，不会有 WRONGTYPE；BatchDelete 的错误来自连接失败、ACL 拒绝等。
+连接中断时这一批中的每个键都会带上同样的错误。
+*/
+
+// batchPipelineSize 每个管道中的命令数，避免一次把几十万条命令放进内存
+const batchPipelineSize = 1000
+
+// BatchGet 批量读取 String 键
+// 不存在的键不出现在结果中；读取失败的键（类型错误等）各自返回一个错误，其余键照常返回
+func (r *RedisClient) BatchGet(keys []string) (map[string]string, []error) {
+	values := make(map[string]string, len(keys))
+	var errs []error
+
+	for batch := range slices.Chunk(keys, batchPipelineSize) {
+		cmds := make([]*redis.StringCmd, len(batch))
+		// 返回的错误就是第一条失败命令的错误（包括 redis.Nil），逐条检查即可
+		r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range batch {
+				cmds[i] = pipe.Get(r.ctx, key)
+			}
+			return nil
+		})
+
+		for i, cmd := range cmds {
+			switch val, err := cmd.Result(); {
+			case err == nil:
+				values[batch[i]] = val
+			case err != redis.Nil:
+				errs = append(errs, fmt.Errorf("GET %s: %w", batch[i], err))
+			}
+		}
+	}
+	return values, errs
+}
+
+// BatchDelete 批量删除键，返回实际删除的数量（不存在的键不计入）
+// 删除失败的键各自返回一个错误，不影响其他键
+func (r *RedisClient) BatchDelete(keys []string) (deleted int64, errs []error) {
+	for batch := range slices.Chunk(keys, batchPipelineSize) {
+		cmds := make([]*redis.IntCmd, len(batch))
+		r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range batch {
+				cmds[i] = pipe.Del(r.ctx, key)
+			}
+			return nil
+		})
+
+		for i, cmd := range cmds {
+			n, err := cmd.Result()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("DEL %s: %w", batch[i], err))
+				continue
+			}
+			deleted += n
+		}
+	}
+	return deleted, errs
+}
+
+// ====== 管道操作 ======
+
+// Pipeline 管道操作示例
+func (r *RedisClient) PipelineExample() {
+	// 创建管道
+	pipe := r.client.Pipeline()
+
+	// 添加命令到管道
+	incr := pipe.Incr(r.ctx, "pipeline_counter")
+	pipe.Expire(r.ctx, "pipeline_counter", time.Hour)
+
+	// 执行管道中的所有命令
+	_, err := pipe.Exec(r.ctx)
+	if err != nil {
+		logger.Error("管道执行失败", "err", err)
+		return
+	}
+
+	// 获取结果
+	fmt.Println("管道计数器:", incr.Val())
+}
+
+// ====== 事务操作 ======
+
+// TxPipelined 事务管道
+func (r *RedisClient) TxPipelinedExample() {
+	var incr int64
+
+	// Watch 监控键
+	err := r.client.Watch(r.ctx, func(tx *redis.Tx) error {
+		// 获取当前值
+		n, err := tx.Get(r.ctx, "counter").Int()
+		if err != nil && err != redis.Nil {
 			return err
 		}
 
@@ -446,12 +1726,187 @@ func (r *RedisClient) TxPipelinedExample() {
 	}, "counter")
 
 	if err != nil {
-		log.Printf("事务失败: %v", err)
+		logger.Error("事务失败", "err", err)
 	}
 
 	fmt.Println("计数器值:", incr)
 }
 
+// ====== 复制确认写入 ======
+/*
+Redis 主从复制是异步的：SET 返回 OK 时数据只在主节点上，
+主节点此时宕机并发生故障切换，这次写入就丢了。
+
+WAIT numreplicas timeout 阻塞到此前在同一连接上的写入被至少 numreplicas 个副本确认，
+或者超时，返回实际确认的副本数：
+
+  acked, err := rc.SetAndWait("order:1001", payload, 0, 1, 100*time.Millisecond)
+  if errors.Is(err, ErrNotEnoughReplicas) {
+      // 写入已在主节点生效，只是还没有足够的副本确认
+  }
+
+注意：
+  - 只对有副本的部署有意义；单机 Redis 上 WAIT 总是返回 0，numReplicas >= 1 时必然超时
+  - WAIT 只统计同一连接上的写入，连接池中 SET 和 WAIT 可能拿到不同连接，
+    所以先用 client.Conn() 取出一个专用连接再执行两条命令
+  - 确认数不足时写入不会回滚，WAIT 也不能保证强一致，只是缩小丢数据的窗口
+*/
+
+// ErrNotEnoughReplicas 超时前确认写入的副本数少于要求
+var ErrNotEnoughReplicas = errors.New("redis: not enough replicas acknowledged the write")
+
+// SetAndWait 写入后等待至少 numReplicas 个副本确认，返回确认的副本数
+// 超时前确认数不足时返回实际确认数和 ErrNotEnoughReplicas
+func (r *RedisClient) SetAndWait(key string, value interface{}, expiration time.Duration, numReplicas int, timeout time.Duration) (acked int64, err error) {
+	if numReplicas <= 0 {
+		return 0, fmt.Errorf("numReplicas must be positive, got %d", numReplicas)
+	}
+	// WAIT 的 timeout 为 0 表示无限等待，这里不允许
+	if timeout < time.Millisecond {
+		return 0, fmt.Errorf("wait timeout must be at least 1ms, got %s", timeout)
+	}
+
+	// 从连接池取出一个专用连接，SET 和 WAIT 在同一连接上执行
+	conn := r.client.Conn()
+	defer conn.Close()
+
+	// SET key value [PX milliseconds]
+	if err := conn.Set(r.ctx, key, value, expiration).Err(); err != nil {
+		return 0, err
+	}
+	// WAIT numreplicas timeout
+	// go-redis 按 timeout 设置这条命令的读超时，不受客户端 ReadTimeout 限制
+	acked, err = conn.Wait(r.ctx, numReplicas, timeout).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if acked < int64(numReplicas) {
+		logger.Warn("副本确认数不足", "key", key, "acked", acked, "want", numReplicas, "timeout", timeout)
+		return acked, fmt.Errorf("%w: %d of %d within %s", ErrNotEnoughReplicas, acked, numReplicas, timeout)
+	}
+	return acked, nil
+}
+
+// ====== 集群多键操作 ======
+/*
+Redis Cluster 把键空间分成 16384 个槽（slot），slot = CRC16(key) % 16384，
+每个节点负责一部分槽。MGET、MSET、SINTER、事务等多键命令要求所有键在同一个槽，
+否则返回 CROSSSLOT 错误。
+
+两种处理方式：
+  1. 读操作可以拆分：按槽分组，每组一个 MGET，再按原顺序合并（MGetClusterSafe）
+  2. 必须原子执行的操作（MSET、SINTER、MULTI/EXEC、Lua 脚本）只能让键落在同一个槽：
+     使用哈希标签 {tag}，只有花括号内的部分参与计算槽位
+
+       user:{1000}:profile
+       user:{1000}:settings  → 都按 "1000" 计算，必定在同一个槽
+
+     执行前可以用 SameSlot 检查，避免上线到集群后才发现 CROSSSLOT
+
+哈希标签也不能滥用：大量键使用同一个标签会集中到一个节点，造成热点。
+
+单机 Redis 没有槽的概念，这些函数同样可用，代码迁移到集群时无需修改。
+*/
+
+// redisClusterSlots Redis Cluster 的槽数量
+const redisClusterSlots = 16384
+
+// HashSlot 计算键所在的槽，与 Redis CLUSTER KEYSLOT 结果一致
+func HashSlot(key string) int {
+	return int(crc16(hashTagKey(key)) % redisClusterSlots)
+}
+
+// hashTagKey 返回参与槽位计算的部分
+// 键中包含 {...} 且花括号内非空时只使用第一个花括号内的内容
+func hashTagKey(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+// crc16 CRC16-XMODEM（多项式 0x1021，初始值 0），Redis Cluster 使用的校验算法
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// SameSlot 检查所有键是否在同一个槽
+func SameSlot(keys ...string) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	first := HashSlot(keys[0])
+	for _, key := range keys[1:] {
+		if HashSlot(key) != first {
+			return false
+		}
+	}
+	return true
+}
+
+// MGetClusterSafe 批量获取，键可以分布在不同的槽
+// 按槽分组后在一个管道中发送多个 MGET，结果按 keys 的顺序返回，不存在的键为 nil
+func (r *RedisClient) MGetClusterSafe(keys ...string) ([]interface{}, error) {
+	return mgetClusterSafe(r.ctx, r.client, keys)
+}
+
+// mgetClusterSafe MGetClusterSafe 的实现，接受 redis.Cmdable 以便用于 *redis.ClusterClient
+// 集群客户端的管道会把每个 MGET 发往负责对应槽的节点
+func mgetClusterSafe(ctx context.Context, c redis.Cmdable, keys []string) ([]interface{}, error) {
+	results := make([]interface{}, len(keys))
+	if len(keys) == 0 {
+		return results, nil
+	}
+
+	// 按槽分组，记录每个键在 keys 中的位置；slots 保持首次出现的顺序，便于调试
+	groups := make(map[int][]int)
+	var slots []int
+	for i, key := range keys {
+		slot := HashSlot(key)
+		if _, ok := groups[slot]; !ok {
+			slots = append(slots, slot)
+		}
+		groups[slot] = append(groups[slot], i)
+	}
+
+	cmds := make([]*redis.SliceCmd, len(slots))
+	_, err := c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, slot := range slots {
+			groupKeys := make([]string, len(groups[slot]))
+			for j, idx := range groups[slot] {
+				groupKeys[j] = keys[idx]
+			}
+			cmds[i] = pipe.MGet(ctx, groupKeys...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 按原始位置放回
+	for i, slot := range slots {
+		for j, v := range cmds[i].Val() {
+			results[groups[slot][j]] = v
+		}
+	}
+	return results, nil
+}
+
 // ====== 发布订阅 ======
 
 // PubSub 发布订阅示例
@@ -462,7 +1917,7 @@ func (r *RedisClient) PubSubExample() {
 	// 等待订阅成功
 	_, err := pubsub.Receive(r.ctx)
 	if err != nil {
-		log.Printf("订阅失败: %v", err)
+		logger.Error("订阅失败", "err", err)
 		return
 	}
 
@@ -480,6 +1935,393 @@ func (r *RedisClient) PubSubExample() {
 	pubsub.Close()
 }
 
+// ====== 持久订阅 ======
+/*
+PubSubExample 只接收一条消息就退出，实际服务需要长期保持订阅：
+  - 连接断开后自动重连，重连间隔指数退避
+  - ctx 取消时干净退出（返回 nil）
+  - 处理函数较慢时不阻塞接收循环：消息交给有界的工作池处理
+
+顺序保证：
+  同一频道的消息总是分配给同一个 worker，因此同一频道内按发布顺序处理；
+  不同频道之间并发处理，不保证顺序。
+
+注意：Redis Pub/Sub 不持久化消息，断线期间发布的消息会丢失，
+需要可靠投递时请使用 List 或 Stream。
+
+使用示例：
+  err := client.Subscribe(ctx, []string{"orders"}, func(channel, payload string) error {
+      fmt.Println(channel, payload)
+      return nil
+  })
+*/
+
+const (
+	subscribeWorkers    = 4                      // 工作池 worker 数量
+	subscribeQueueSize  = 64                     // 每个 worker 的队列长度
+	subscribeMinBackoff = 100 * time.Millisecond // 首次重连间隔
+	subscribeMaxBackoff = 5 * time.Second        // 最大重连间隔
+)
+
+// MessageHandler 订阅消息处理函数
+// 返回的错误只记录日志，不会中断订阅
+type MessageHandler func(channel, payload string) error
+
+// Subscribe 订阅频道，阻塞直到 ctx 取消
+func (r *RedisClient) Subscribe(ctx context.Context, channels []string, handler MessageHandler) error {
+	return r.subscribeLoop(ctx, false, channels, handler)
+}
+
+// PSubscribe 按模式订阅频道（如 "news.*"），阻塞直到 ctx 取消
+// handler 收到的 channel 是实际的频道名
+func (r *RedisClient) PSubscribe(ctx context.Context, patterns []string, handler MessageHandler) error {
+	return r.subscribeLoop(ctx, true, patterns, handler)
+}
+
+// subscribeLoop 订阅主循环：断线后退避重连
+func (r *RedisClient) subscribeLoop(ctx context.Context, pattern bool, names []string, handler MessageHandler) error {
+	if len(names) == 0 {
+		return fmt.Errorf("至少需要订阅一个频道")
+	}
+
+	pool := newDispatchPool(subscribeWorkers, subscribeQueueSize, handler)
+	// 退出前等待已接收的消息处理完
+	defer pool.stop()
+
+	backoff := subscribeMinBackoff
+	for {
+		err := r.receiveMessages(ctx, pattern, names, pool, func() {
+			// 订阅成功后重置退避时间
+			backoff = subscribeMinBackoff
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		logger.Warn("订阅连接断开，准备重连", "channels", names, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > subscribeMaxBackoff {
+			backoff = subscribeMaxBackoff
+		}
+	}
+}
+
+// receiveMessages 建立一次订阅并持续接收消息，直到出错或 ctx 取消
+func (r *RedisClient) receiveMessages(ctx context.Context, pattern bool, names []string, pool *dispatchPool, onSubscribed func()) error {
+	var ps *redis.PubSub
+	if pattern {
+		ps = r.client.PSubscribe(ctx, names...)
+	} else {
+		ps = r.client.Subscribe(ctx, names...)
+	}
+	defer ps.Close()
+
+	// 阻塞读取不响应 ctx 取消，取消时关闭连接让读取返回
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ps.Close()
+		case <-done:
+		}
+	}()
+
+	// 等待订阅确认
+	if _, err := ps.Receive(ctx); err != nil {
+		return err
+	}
+	onSubscribed()
+
+	for {
+		msg, err := ps.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+		if !pool.dispatch(ctx, msg) {
+			return ctx.Err()
+		}
+	}
+}
+
+// dispatchPool 有界工作池，按频道把消息分配给固定的 worker
+type dispatchPool struct {
+	queues []chan *redis.Message
+	wg     sync.WaitGroup
+}
+
+// newDispatchPool 创建并启动工作池
+func newDispatchPool(workers, queueSize int, handler MessageHandler) *dispatchPool {
+	p := &dispatchPool{queues: make([]chan *redis.Message, workers)}
+	for i := range p.queues {
+		q := make(chan *redis.Message, queueSize)
+		p.queues[i] = q
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for msg := range q {
+				// 处理函数 panic 时只记录错误，worker 继续处理后续消息
+				err := safego.Run(func() error { return handler(msg.Channel, msg.Payload) })
+				if err != nil {
+					logger.Error("处理订阅消息失败", "channel", msg.Channel, "err", err)
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// dispatch 把消息放入对应 worker 的队列
+// 队列满时等待（背压），ctx 取消时返回 false
+func (p *dispatchPool) dispatch(ctx context.Context, msg *redis.Message) bool {
+	h := fnv.New32a()
+	h.Write([]byte(msg.Channel))
+	q := p.queues[h.Sum32()%uint32(len(p.queues))]
+
+	select {
+	case q <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// stop 关闭所有队列并等待 worker 处理完剩余消息
+func (p *dispatchPool) stop() {
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.wg.Wait()
+}
+
+// ====== 键空间通知 ======
+/*
+Redis 可以在键被修改、删除或过期时向特殊频道发布通知：
+
+  __keyspace@<db>__:<key>   消息内容是事件名，如 "expired"
+  __keyevent@<db>__:<event> 消息内容是键名
+
+默认关闭，需要在服务端开启（或写入 redis.conf）：
+  CONFIG SET notify-keyspace-events KEA
+  K = keyspace 频道，E = keyevent 频道，A = 所有事件类型
+  只关心过期时可以用 "Kx"，开得越多对性能影响越大
+
+使用示例：
+  err := client.WatchKeyspaceEvents(ctx, "session:*", func(ev KeyEvent) error {
+      if ev.Event == "expired" {
+          log.Printf("会话过期: %s", ev.Key)
+      }
+      return nil
+  })
+
+基于 PSubscribe，同样会断线重连，同一个键的事件按顺序处理。
+注意过期事件在 Redis 实际删除键时才发布（惰性删除或定期扫描），可能晚于 TTL 到期时间。
+*/
+
+// KeyEvent 键空间事件
+type KeyEvent struct {
+	Key   string // 键名
+	Event string // 事件名，如 "set"、"del"、"expired"
+}
+
+const (
+	keyspaceChannelPrefix = "__keyspace@"
+	keyeventChannelPrefix = "__keyevent@"
+)
+
+// WatchKeyspaceEvents 监听当前 DB 中匹配 pattern 的键的事件，阻塞直到 ctx 取消
+// 订阅 __keyspace@<db>__:<pattern>，需要服务端开启 notify-keyspace-events
+func (r *RedisClient) WatchKeyspaceEvents(ctx context.Context, pattern string, handler func(event KeyEvent) error) error {
+	channel := fmt.Sprintf("%s%d__:%s", keyspaceChannelPrefix, r.client.Options().DB, pattern)
+
+	return r.PSubscribe(ctx, []string{channel}, func(channel, payload string) error {
+		ev, ok := parseKeyEvent(channel, payload)
+		if !ok {
+			return fmt.Errorf("无法解析键空间通知: %s", channel)
+		}
+		return handler(ev)
+	})
+}
+
+// parseKeyEvent 解析键空间通知，支持 __keyspace@ 和 __keyevent@ 两种频道
+func parseKeyEvent(channel, payload string) (KeyEvent, bool) {
+	// "__keyspace@0__:user:1" 以第一个 "__:" 分隔，键名本身可以包含冒号
+	_, suffix, ok := strings.Cut(channel, "__:")
+	if !ok || suffix == "" {
+		return KeyEvent{}, false
+	}
+
+	switch {
+	case strings.HasPrefix(channel, keyspaceChannelPrefix):
+		return KeyEvent{Key: suffix, Event: payload}, true
+	case strings.HasPrefix(channel, keyeventChannelPrefix):
+		return KeyEvent{Key: payload, Event: suffix}, true
+	}
+	return KeyEvent{}, false
+}
+
+// ====== 布隆过滤器 ======
+/*
+布隆过滤器用很少的内存判断"元素是否可能存在"：
+  - Exists 返回 false：一定不存在（没有漏判）
+  - Exists 返回 true：可能存在，有一定的误判率
+
+适合：URL 去重、缓存穿透防护（先判断 ID 是否可能存在再查库）、推荐去重等。
+
+两种实现：
+  1. RedisBloom 模块可用时使用 BF.RESERVE / BF.ADD / BF.EXISTS
+  2. 否则用普通字符串做位数组，SETBIT / GETBIT 自己管理
+
+位数组的大小按预期元素数 n 和误判率 p 计算：
+  位数 m = -n * ln(p) / (ln2)^2
+  哈希函数个数 k = m / n * ln2
+  例如 n=100 万、p=1%：m ≈ 958 万位（约 1.2MB），k = 7
+
+k 个哈希位置由两个 64 位 FNV 哈希组合得到（Kirsch-Mitzenmacher）：
+  g_i(x) = h1(x) + i * h2(x)  mod m
+效果与 k 个独立哈希函数相当，每个元素只需计算两次哈希。
+
+使用示例：
+  bf, err := rdb.NewBloomFilter("bloom:urls", 1_000_000, 0.01)
+  bf.Add("https://example.com/a")
+  ok, err := bf.Exists("https://example.com/a") // true
+
+已插入的元素超过预期数量时误判率会快速上升，需要按业务增长预留容量。
+*/
+
+// maxBloomBits Redis 字符串最大 512MB，即 2^32 位
+const maxBloomBits = 1 << 32
+
+// BloomFilter 基于 Redis 的布隆过滤器
+type BloomFilter struct {
+	r      *RedisClient
+	key    string
+	native bool // 是否使用 RedisBloom 模块
+
+	// 位数组实现的参数
+	bits   uint64 // 位数组大小 m
+	hashes int    // 哈希函数个数 k
+}
+
+// NewBloomFilter 创建布隆过滤器
+// expectedItems 为预期元素数，fpRate 为期望的误判率（0~1 之间）
+// 会先尝试 BF.RESERVE，Redis 不支持该命令时退回到位数组实现
+func (r *RedisClient) NewBloomFilter(key string, expectedItems int64, fpRate float64) (*BloomFilter, error) {
+	if expectedItems <= 0 {
+		return nil, fmt.Errorf("expectedItems must be positive, got %d", expectedItems)
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, fmt.Errorf("fpRate must be in (0, 1), got %v", fpRate)
+	}
+
+	bf := &BloomFilter{r: r, key: key}
+
+	// BF.RESERVE key error_rate capacity
+	err := r.client.BFReserve(r.ctx, key, fpRate, expectedItems).Err()
+	if err == nil || strings.Contains(err.Error(), "item exists") {
+		// 创建成功，或者过滤器已存在（沿用已有的参数）
+		bf.native = true
+		return bf, nil
+	}
+	if !isUnknownCommand(err) {
+		return nil, err
+	}
+
+	// 退回位数组实现
+	bits, hashes := bloomParams(float64(expectedItems), fpRate)
+	if bits > maxBloomBits {
+		return nil, fmt.Errorf("bloom filter needs %d bits, exceeds redis string limit", bits)
+	}
+	bf.bits, bf.hashes = bits, hashes
+	return bf, nil
+}
+
+// Native 是否使用 RedisBloom 模块
+func (bf *BloomFilter) Native() bool {
+	return bf.native
+}
+
+// Add 添加元素
+func (bf *BloomFilter) Add(member string) error {
+	if bf.native {
+		// BF.ADD key item
+		return bf.r.client.BFAdd(bf.r.ctx, bf.key, member).Err()
+	}
+
+	// 用管道一次发送 k 个 SETBIT
+	_, err := bf.r.client.Pipelined(bf.r.ctx, func(pipe redis.Pipeliner) error {
+		for _, offset := range bf.offsets(member) {
+			pipe.SetBit(bf.r.ctx, bf.key, int64(offset), 1)
+		}
+		return nil
+	})
+	return err
+}
+
+// Exists 判断元素是否可能存在
+func (bf *BloomFilter) Exists(member string) (bool, error) {
+	if bf.native {
+		// BF.EXISTS key item
+		return bf.r.client.BFExists(bf.r.ctx, bf.key, member).Result()
+	}
+
+	offsets := bf.offsets(member)
+	cmds := make([]*redis.IntCmd, len(offsets))
+	_, err := bf.r.client.Pipelined(bf.r.ctx, func(pipe redis.Pipeliner) error {
+		for i, offset := range offsets {
+			cmds[i] = pipe.GetBit(bf.r.ctx, bf.key, int64(offset))
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// 任何一位为 0 说明一定没有添加过
+	for _, cmd := range cmds {
+		if cmd.Val() == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// offsets 计算元素对应的 k 个位偏移
+func (bf *BloomFilter) offsets(member string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(member))
+	h1 := h.Sum64()
+
+	h = fnv.New64()
+	h.Write([]byte(member))
+	h2 := h.Sum64() | 1 // 保证为奇数，避免 h2 为 0 时所有位置相同
+
+	offsets := make([]uint64, bf.hashes)
+	for i := range offsets {
+		offsets[i] = (h1 + uint64(i)*h2) % bf.bits
+	}
+	return offsets
+}
+
+// bloomParams 按预期元素数 n 和误判率 p 计算位数 m 和哈希函数个数 k
+func bloomParams(n, p float64) (bits uint64, hashes int) {
+	m := math.Ceil(-n * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / n * math.Ln2))
+	return uint64(m), max(k, 1)
+}
+
+// isUnknownCommand 判断错误是否为 Redis 不支持该命令
+func isUnknownCommand(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown command")
+}
+
 // ====== 分布式锁 ======
 
 // Lock 尝试获取分布式锁
@@ -502,6 +2344,272 @@ func (r *RedisClient) Unlock(key, value string) error {
 	return script.Run(r.ctx, r.client, []string{key}, value).Err()
 }
 
+// ====== 分布式信号量 ======
+/*
+分布式锁只允许一个持有者；信号量允许最多 N 个，例如限制同时调用某个第三方接口的实例数。
+
+实现：每个信号量是一个 ZSet，成员为持有者的随机令牌，分数为获取时间（毫秒）。
+获取时在 Lua 脚本中原子地执行：
+  1. 删除获取时间早于 now - ttl 的成员（租约已过期）
+  2. 成员数小于 limit 时加入新令牌，否则拒绝
+
+  token, ok, err := rc.AcquireSemaphore("sem:payment-api", 5, 30*time.Second)
+  if err != nil || !ok {
+      return // 已有 5 个持有者
+  }
+  defer rc.ReleaseSemaphore("sem:payment-api", token)
+
+TTL 是租约而不是超时：
+  - 持有者崩溃没有释放时，ttl 之后名额自动回收，不会永久占用
+  - 持有时间超过 ttl 的持有者会被当作已过期，名额可能被别人拿走，
+    此时实际并发数会超过 limit，因此 ttl 应明显大于任务的最长耗时
+  - 过期判断使用获取时的 ttl，同一个 key 的所有调用方应使用相同的 ttl
+
+时间取自 Redis 服务器（TIME 命令），不受各客户端时钟偏差影响。
+*/
+
+// acquireSemaphoreScript 清理过期持有者后尝试加入
+// KEYS[1] 信号量 key，ARGV[1] limit，ARGV[2] ttl（毫秒），ARGV[3] 令牌
+var acquireSemaphoreScript = redis.NewScript(`
+	local t = redis.call("TIME")
+	local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+	local ttl = tonumber(ARGV[2])
+
+	redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - ttl)
+	if redis.call("ZCARD", KEYS[1]) < tonumber(ARGV[1]) then
+		redis.call("ZADD", KEYS[1], now, ARGV[3])
+		redis.call("PEXPIRE", KEYS[1], ttl)
+		return 1
+	end
+	return 0
+`)
+
+// AcquireSemaphore 尝试获取信号量的一个名额，不阻塞
+// acquired 为 true 时用返回的 token 调用 ReleaseSemaphore 释放
+func (r *RedisClient) AcquireSemaphore(key string, limit int, ttl time.Duration) (token string, acquired bool, err error) {
+	if limit <= 0 {
+		return "", false, fmt.Errorf("semaphore limit must be positive, got %d", limit)
+	}
+	if ttl < time.Millisecond {
+		return "", false, fmt.Errorf("semaphore ttl must be at least 1ms, got %s", ttl)
+	}
+
+	token = rand.Text()
+	n, err := acquireSemaphoreScript.Run(r.ctx, r.client, []string{key}, limit, ttl.Milliseconds(), token).Int()
+	if err != nil {
+		return "", false, err
+	}
+	if n == 0 {
+		return "", false, nil
+	}
+	return token, true, nil
+}
+
+// ReleaseSemaphore 释放名额
+// 令牌已经过期被清理时什么也不做
+func (r *RedisClient) ReleaseSemaphore(key, token string) error {
+	// ZREM key token
+	return r.client.ZRem(r.ctx, key, token).Err()
+}
+
+// ====== 缓存防击穿 ======
+/*
+热点 key 过期的瞬间，大量请求同时未命中，全部去查数据库（缓存击穿）。
+
+CachedLoad 分两层合并这些请求：
+
+  进程内  同一个 key 同时只有一个 Goroutine 执行加载，其余等待它的结果
+  进程间  加载前用 SET key:lock NX PX 抢锁：
+            抢到   → 执行 loader，写入缓存，释放锁
+            没抢到 → 其他进程正在加载，每隔一小段时间重新读缓存，
+                     等到缓存写入就直接返回；等待超时（对方崩溃或过慢）才自己加载
+
+  user, err := rc.CachedLoad("user:42", time.Hour, func() (string, error) {
+      u, err := db.GetUserByID(42)
+      if err != nil {
+          return "", err
+      }
+      b, err := json.Marshal(u)
+      return string(b), err
+  })
+
+loader 返回错误时不写缓存，错误原样返回给本进程所有等待者。
+锁有过期时间（stampedeLockTTL），持有锁的进程崩溃不会导致其他进程一直等待。
+*/
+
+const (
+	stampedeLockTTL      = 5 * time.Second       // 加载锁的过期时间
+	stampedeWaitTimeout  = time.Second           // 没抢到锁时最多等待多久
+	stampedeWaitInterval = 50 * time.Millisecond // 等待期间重新读缓存的间隔
+)
+
+// errLoaderPanicked loader panic 时返回给等待者的错误
+var errLoaderPanicked = errors.New("cache loader panicked")
+
+// CachedLoad 读取缓存，未命中时加载并写入缓存，同一个 key 的并发加载（包括跨进程）只执行一次
+func (r *RedisClient) CachedLoad(key string, ttl time.Duration, loader func() (string, error)) (string, error) {
+	val, err := r.client.Get(r.ctx, key).Result()
+	if err == nil {
+		return val, nil
+	}
+	if err != redis.Nil {
+		return "", err
+	}
+
+	return r.loads.do(key, func() (string, error) {
+		return r.loadWithLock(key, ttl, loader)
+	})
+}
+
+// loadWithLock 抢到锁时加载；没抢到时等待其他进程填充缓存，超时后自己加载
+func (r *RedisClient) loadWithLock(key string, ttl time.Duration, loader func() (string, error)) (string, error) {
+	lockKey := key + ":lock"
+	token := rand.Text()
+
+	locked, err := r.Lock(lockKey, token, stampedeLockTTL)
+	if err != nil {
+		return "", err
+	}
+	if locked {
+		defer r.Unlock(lockKey, token)
+		return r.loadAndStore(key, ttl, loader)
+	}
+
+	deadline := time.Now().Add(stampedeWaitTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(stampedeWaitInterval)
+
+		val, err := r.client.Get(r.ctx, key).Result()
+		if err == nil {
+			return val, nil
+		}
+		if err != redis.Nil {
+			return "", err
+		}
+	}
+
+	logger.Warn("等待缓存加载超时，自行加载", "key", key)
+	return r.loadAndStore(key, ttl, loader)
+}
+
+// loadAndStore 执行 loader 并写入缓存
+func (r *RedisClient) loadAndStore(key string, ttl time.Duration, loader func() (string, error)) (string, error) {
+	val, err := loader()
+	if err != nil {
+		return "", err
+	}
+	if err := r.client.Set(r.ctx, key, val, ttl).Err(); err != nil {
+		// 写缓存失败不影响本次结果，下次请求会重新加载
+		logger.Warn("写入缓存失败", "key", key, "err", err)
+	}
+	return val, nil
+}
+
+// loadCall 一次进行中的加载
+type loadCall struct {
+	done chan struct{}
+	val  string
+	err  error
+}
+
+// loadGroup 合并同一个 key 的并发加载，零值可用
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+// do 同一个 key 同时只执行一次 fn，其他调用者等待并共享结果
+func (g *loadGroup) do(key string, fn func() (string, error)) (string, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*loadCall)
+	}
+	// fn panic 时 err 保持为 errLoaderPanicked，等待者不会一直阻塞
+	c := &loadCall{done: make(chan struct{}), err: errLoaderPanicked}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err
+}
+
+// ====== 幂等键 ======
+/*
+幂等键用于安全地重试非幂等操作（如下单、扣款）：
+
+  first, _ := r.AcquireIdempotencyKey(key, time.Hour)
+  if !first {
+      // 重复请求：返回之前保存的结果
+      var resp Order
+      if found, _ := r.GetIdempotentResult(key, &resp); found {
+          return resp
+      }
+      // 结果还没写入：第一个请求仍在处理中，返回 409 让客户端稍后重试
+  }
+  resp := doWork()
+  r.StoreIdempotentResult(key, resp, time.Hour)
+
+注意竞态：AcquireIdempotencyKey 成功到 StoreIdempotentResult 之间存在时间窗口，
+这期间的重复请求既拿不到锁也读不到结果。调用方应把"未找到结果"视为"处理中"，
+而不是重新执行操作。结果本身就是完成标记：存在表示已完成，不存在表示处理中或已失败。
+如果处理失败，应删除锁键（idem:lock:<key>）允许客户端重试。
+*/
+
+// idempotencyLockKey 幂等锁的键
+func idempotencyLockKey(key string) string {
+	return "idem:lock:" + key
+}
+
+// idempotencyResultKey 幂等结果的键
+func idempotencyResultKey(key string) string {
+	return "idem:result:" + key
+}
+
+// AcquireIdempotencyKey 获取幂等键
+// 第一次调用返回 true，ttl 内的重复调用返回 false
+func (r *RedisClient) AcquireIdempotencyKey(key string, ttl time.Duration) (firstSeen bool, err error) {
+	// SET idem:lock:<key> 1 NX EX ttl
+	return r.client.SetNX(r.ctx, idempotencyLockKey(key), 1, ttl).Result()
+}
+
+// StoreIdempotentResult 保存操作结果（JSON 序列化）
+func (r *RedisClient) StoreIdempotentResult(key string, result interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("序列化结果失败: %w", err)
+	}
+	return r.client.Set(r.ctx, idempotencyResultKey(key), data, ttl).Err()
+}
+
+// GetIdempotentResult 读取保存的结果到 dest
+// 结果不存在时返回 found=false
+func (r *RedisClient) GetIdempotentResult(key string, dest interface{}) (found bool, err error) {
+	data, err := r.client.Get(r.ctx, idempotencyResultKey(key)).Bytes()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		return false, fmt.Errorf("反序列化结果失败: %w", err)
+	}
+	return true, nil
+}
+
 // ====== 缓存示例 ======
 
 // CacheUser 缓存用户信息
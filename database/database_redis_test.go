@@ -0,0 +1,2529 @@
+// database/database_redis_test.go
+// Redis 示例的测试
+//
+// database 目录下每个文件都是独立的示例程序（各有一个 main），需要按文件运行：
+//   go test database/database_redis.go database/database_redis_test.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/austoin/GolangTutorial/testfixtures"
+)
+
+// newTestRedisClient 连接 miniredis 的 RedisClient，不启动保活
+func newTestRedisClient(t *testing.T) *RedisClient {
+	t.Helper()
+	client, _ := testfixtures.NewTestRedis(t)
+	return newRedisClient(client, 0)
+}
+
+// ====== 保活 ======
+
+// waitFor 轮询 cond，超时后报告失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestKeepaliveTracksHealth(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 10*time.Millisecond)
+
+	if !rc.IsHealthy() {
+		t.Fatal("初始状态 IsHealthy() = false, want true")
+	}
+
+	mr.Close()
+	waitFor(t, " Redis 停止后变为不健康", func() bool { return !rc.IsHealthy() })
+
+	// go-redis 每次执行命令时自动重连，服务恢复后保活 PING 重新成功
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	waitFor(t, " Redis 恢复后变为健康", func() bool { return rc.IsHealthy() })
+
+	rc.Close()
+	select {
+	case <-rc.done:
+	default:
+		t.Error("Close() 返回后保活协程仍在运行")
+	}
+}
+
+func TestKeepaliveDisabled(t *testing.T) {
+	t.Parallel()
+	rc := newTestRedisClient(t)
+	if rc.stop != nil {
+		t.Error("interval 为 0 时不应启动保活协程")
+	}
+	if err := rc.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+// ====== 分布式信号量 ======
+
+func TestSemaphore(t *testing.T) {
+	t.Parallel()
+	rc := newTestRedisClient(t)
+	const key = "sem:payment-api"
+
+	// 前 3 个获取成功，令牌互不相同
+	tokens := map[string]bool{}
+	for i := 1; i <= 3; i++ {
+		token, ok, err := rc.AcquireSemaphore(key, 3, time.Minute)
+		if err != nil || !ok || token == "" {
+			t.Fatalf("第 %d 次 AcquireSemaphore() = %q, %v, %v, want 获取成功", i, token, ok, err)
+		}
+		tokens[token] = true
+	}
+	if len(tokens) != 3 {
+		t.Fatalf("令牌 %v 有重复", tokens)
+	}
+
+	// 第 4 个被拒绝
+	token, ok, err := rc.AcquireSemaphore(key, 3, time.Minute)
+	if err != nil || ok || token != "" {
+		t.Fatalf("名额已满时 AcquireSemaphore() = %q, %v, %v, want 拒绝", token, ok, err)
+	}
+
+	// 释放未知的令牌不占用名额，也不报错
+	if err := rc.ReleaseSemaphore(key, "unknown"); err != nil {
+		t.Fatalf("ReleaseSemaphore(unknown) error = %v", err)
+	}
+	if _, ok, _ := rc.AcquireSemaphore(key, 3, time.Minute); ok {
+		t.Fatal("释放未知令牌后不应该空出名额")
+	}
+
+	// 释放一个后空出一个名额
+	for token := range tokens {
+		if err := rc.ReleaseSemaphore(key, token); err != nil {
+			t.Fatalf("ReleaseSemaphore() error = %v", err)
+		}
+		break
+	}
+	if _, ok, err := rc.AcquireSemaphore(key, 3, time.Minute); !ok || err != nil {
+		t.Fatalf("释放后 AcquireSemaphore() = %v, %v, want 获取成功", ok, err)
+	}
+	if _, ok, _ := rc.AcquireSemaphore(key, 3, time.Minute); ok {
+		t.Error("空出的名额只能被获取一次")
+	}
+}
+
+func TestSemaphoreLeaseExpires(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+	const key = "sem:report"
+
+	// 时间取自 Redis 服务器，用 SetTime 控制
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mr.SetTime(t0)
+	if _, ok, _ := rc.AcquireSemaphore(key, 2, 30*time.Second); !ok {
+		t.Fatal("第 1 次获取失败")
+	}
+	mr.SetTime(t0.Add(20 * time.Second))
+	if _, ok, _ := rc.AcquireSemaphore(key, 2, 30*time.Second); !ok {
+		t.Fatal("第 2 次获取失败")
+	}
+	if _, ok, _ := rc.AcquireSemaphore(key, 2, 30*time.Second); ok {
+		t.Fatal("名额已满时不应该获取成功")
+	}
+
+	// 第 1 个持有者没有释放，租约到期后名额被回收，第 2 个仍然有效
+	mr.SetTime(t0.Add(31 * time.Second))
+	if _, ok, _ := rc.AcquireSemaphore(key, 2, 30*time.Second); !ok {
+		t.Fatal("租约过期后应该可以获取")
+	}
+	if _, ok, _ := rc.AcquireSemaphore(key, 2, 30*time.Second); ok {
+		t.Error("只回收过期的名额，未过期的仍然占用")
+	}
+	if n := mr.TTL(key); n != 30*time.Second {
+		t.Errorf("TTL = %v, want 30s（每次获取刷新）", n)
+	}
+}
+
+func TestSemaphoreConcurrent(t *testing.T) {
+	t.Parallel()
+	rc := newTestRedisClient(t)
+
+	var acquired atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, err := rc.AcquireSemaphore("sem:shared", 5, time.Minute)
+			if err != nil {
+				t.Errorf("AcquireSemaphore() error = %v", err)
+			}
+			if ok {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := acquired.Load(); got != 5 {
+		t.Errorf("并发获取成功 %d 个, want 5（不能超发）", got)
+	}
+}
+
+func TestSemaphoreInvalid(t *testing.T) {
+	t.Parallel()
+	rc := newTestRedisClient(t)
+
+	tests := []struct {
+		name  string
+		limit int
+		ttl   time.Duration
+	}{
+		{"limit 为 0", 0, time.Second},
+		{"limit 为负数", -1, time.Second},
+		{"ttl 小于 1ms", 1, time.Microsecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok, err := rc.AcquireSemaphore("sem:x", tt.limit, tt.ttl); ok || err == nil {
+				t.Errorf("AcquireSemaphore(%d, %v) = %v, %v, want 错误", tt.limit, tt.ttl, ok, err)
+			}
+		})
+	}
+}
+
+// ====== 缓存防击穿 ======
+
+func TestCachedLoad(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0) // 0 表示不启动保活协程
+
+	calls := 0
+	loader := func() (string, error) {
+		calls++
+		return "v1", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		got, err := rc.CachedLoad("user:42", time.Minute, loader)
+		if err != nil || got != "v1" {
+			t.Fatalf("CachedLoad() = %q, %v, want %q", got, err, "v1")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader 执行了 %d 次，want 1（第二次应命中缓存）", calls)
+	}
+
+	mr.FastForward(time.Minute) // 让 TTL 立即过期
+	if _, err := rc.CachedLoad("user:42", time.Minute, loader); err != nil {
+		t.Fatalf("CachedLoad() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("过期后 loader 执行了 %d 次，want 2", calls)
+	}
+}
+
+func TestCachedLoadErrorNotCached(t *testing.T) {
+	t.Parallel()
+	rc := newTestRedisClient(t)
+
+	errDB := errors.New("db down")
+	if _, err := rc.CachedLoad("k", time.Minute, func() (string, error) { return "", errDB }); !errors.Is(err, errDB) {
+		t.Fatalf("CachedLoad() error = %v, want %v", err, errDB)
+	}
+	got, err := rc.CachedLoad("k", time.Minute, func() (string, error) { return "ok", nil })
+	if err != nil || got != "ok" {
+		t.Errorf("出错后重新加载 CachedLoad() = %q, %v, want %q", got, err, "ok")
+	}
+}
+
+func TestCachedLoadConcurrent(t *testing.T) {
+	t.Parallel()
+	rc := newTestRedisClient(t)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func() (string, error) {
+		calls.Add(1)
+		<-release
+		return "v1", nil
+	}
+
+	const n = 20
+	results := make(chan string, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := rc.CachedLoad("hot", time.Minute, loader)
+			if err != nil {
+				t.Errorf("CachedLoad() error = %v", err)
+			}
+			results <- got
+		}()
+	}
+	waitFor(t, "loader 开始执行", func() bool { return calls.Load() == 1 })
+	close(release)
+	wg.Wait()
+	close(results)
+
+	// 进程内合并：只执行一次 loader，所有调用者拿到同一个结果
+	if got := calls.Load(); got != 1 {
+		t.Errorf("loader 执行了 %d 次, want 1", got)
+	}
+	for got := range results {
+		if got != "v1" {
+			t.Errorf("CachedLoad() = %q, want v1", got)
+		}
+	}
+}
+
+func TestCachedLoadAcrossClients(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	// 两个 RedisClient 连接同一个 Redis，模拟两个进程
+	other := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { other.Close() })
+	a, b := newRedisClient(client, 0), newRedisClient(other, 0)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	aDone := make(chan error, 1)
+	go func() {
+		got, err := a.CachedLoad("user:1", time.Minute, func() (string, error) {
+			calls.Add(1)
+			<-release
+			return "from-a", nil
+		})
+		if err == nil && got != "from-a" {
+			err = fmt.Errorf("a 得到 %q", got)
+		}
+		aDone <- err
+	}()
+	waitFor(t, "a 抢到加载锁", func() bool { return mr.Exists("user:1:lock") })
+
+	bDone := make(chan string, 1)
+	go func() {
+		got, err := b.CachedLoad("user:1", time.Minute, func() (string, error) {
+			calls.Add(1)
+			return "from-b", nil
+		})
+		if err != nil {
+			t.Errorf("b CachedLoad() error = %v", err)
+		}
+		bDone <- got
+	}()
+
+	// b 没抢到锁，在等待 a 写入缓存
+	time.Sleep(2 * stampedeWaitInterval)
+	close(release)
+
+	if err := <-aDone; err != nil {
+		t.Fatalf("a CachedLoad() error = %v", err)
+	}
+	if got := <-bDone; got != "from-a" {
+		t.Errorf("b CachedLoad() = %q, want a 加载的 from-a", got)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("loader 共执行了 %d 次, want 1", got)
+	}
+	// 加载完成后释放锁
+	if mr.Exists("user:1:lock") {
+		t.Error("加载完成后锁没有释放")
+	}
+}
+
+func TestCachedLoadLockHolderTimeout(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+
+	// 另一个进程持有锁但一直没有写入缓存（例如已经崩溃）
+	mr.Set("user:1:lock", "other-token")
+
+	start := time.Now()
+	got, err := rc.CachedLoad("user:1", time.Minute, func() (string, error) { return "self", nil })
+	if err != nil || got != "self" {
+		t.Fatalf("CachedLoad() = %q, %v, want self", got, err)
+	}
+	if elapsed := time.Since(start); elapsed < stampedeWaitTimeout {
+		t.Errorf("耗时 %v, want 至少等待 %v 后才自己加载", elapsed, stampedeWaitTimeout)
+	}
+	// 不能释放别人的锁
+	if v, _ := mr.Get("user:1:lock"); v != "other-token" {
+		t.Errorf("锁 = %q, want 保持 other-token", v)
+	}
+}
+
+func TestCachedLoadPanic(t *testing.T) {
+	t.Parallel()
+	rc := newTestRedisClient(t)
+
+	release := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() { panicked <- recover() }()
+		rc.CachedLoad("k", time.Minute, func() (string, error) {
+			<-release
+			panic("boom")
+		})
+	}()
+	waitFor(t, "第一个调用开始加载", func() bool {
+		rc.loads.mu.Lock()
+		defer rc.loads.mu.Unlock()
+		return rc.loads.calls["k"] != nil
+	})
+
+	// 等待中的调用者收到 errLoaderPanicked，不会一直阻塞
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := rc.CachedLoad("k", time.Minute, func() (string, error) { return "unused", nil })
+		waiter <- err
+	}()
+	time.Sleep(20 * time.Millisecond) // 让第二个调用进入等待
+	close(release)
+
+	if r := <-panicked; r != "boom" {
+		t.Errorf("执行 loader 的调用者 recover() = %v, want boom", r)
+	}
+	if err := <-waiter; !errors.Is(err, errLoaderPanicked) {
+		t.Errorf("等待者 error = %v, want errLoaderPanicked", err)
+	}
+}
+
+// ====== String 操作 ======
+
+func TestGetSet(t *testing.T) {
+	r := newTestRedisClient(t)
+
+	// 键不存在时仍会写入新值
+	if old, err := r.GetSet("k", "v1"); !errors.Is(err, ErrNotFound) || old != "" {
+		t.Errorf("GetSet(新键) = %q, %v, want \"\", ErrNotFound", old, err)
+	}
+	if v, _ := r.client.Get(r.ctx, "k").Result(); v != "v1" {
+		t.Fatalf("Get() = %q, want v1", v)
+	}
+
+	r.client.Expire(r.ctx, "k", time.Minute)
+	if old, err := r.GetSet("k", "v2"); err != nil || old != "v1" {
+		t.Errorf("GetSet() = %q, %v, want v1", old, err)
+	}
+	// SET ... GET 会清除原有的过期时间
+	if ttl, _ := r.client.TTL(r.ctx, "k").Result(); ttl != -1 {
+		t.Errorf("TTL = %v, want -1（没有过期时间）", ttl)
+	}
+
+	r.client.RPush(r.ctx, "list", "x")
+	if _, err := r.GetSet("list", "v"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("GetSet(列表) error = %v, want WRONGTYPE", err)
+	}
+}
+
+func TestGetDel(t *testing.T) {
+	r := newTestRedisClient(t)
+	r.client.Set(r.ctx, "token", "abc", 0)
+
+	if v, err := r.GetDel("token"); err != nil || v != "abc" {
+		t.Fatalf("GetDel() = %q, %v, want abc", v, err)
+	}
+	// 取出后键已删除，第二次调用得不到值
+	if v, err := r.GetDel("token"); !errors.Is(err, ErrNotFound) || v != "" {
+		t.Errorf("第二次 GetDel() = %q, %v, want ErrNotFound", v, err)
+	}
+	if n, _ := r.client.Exists(r.ctx, "token").Result(); n != 0 {
+		t.Error("GetDel() 之后键仍然存在")
+	}
+}
+
+// ====== 带过期的计数器 ======
+
+func TestIncrWithExpiry(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+	const limit = 3
+
+	if left, err := r.GetRemaining("quota", limit); err != nil || left != limit {
+		t.Fatalf("计数器不存在时 GetRemaining() = %d, %v, want %d", left, err, limit)
+	}
+
+	if n, err := r.IncrWithExpiry("quota", time.Minute); err != nil || n != 1 {
+		t.Fatalf("第一次 IncrWithExpiry() = %d, %v, want 1", n, err)
+	}
+	if ttl := mr.TTL("quota"); ttl != time.Minute {
+		t.Errorf("第一次递增后 TTL = %s, want 1m", ttl)
+	}
+
+	// 之后的递增不会重置过期时间，窗口从第一次递增开始算
+	mr.FastForward(40 * time.Second)
+	for want := int64(2); want <= 4; want++ {
+		if n, _ := r.IncrWithExpiry("quota", time.Minute); n != want {
+			t.Errorf("IncrWithExpiry() = %d, want %d", n, want)
+		}
+	}
+	if ttl := mr.TTL("quota"); ttl != 20*time.Second {
+		t.Errorf("后续递增后 TTL = %s, want 20s（不应被重置）", ttl)
+	}
+	if left, _ := r.GetRemaining("quota", limit); left != 0 {
+		t.Errorf("超出配额后 GetRemaining() = %d, want 0（不为负数）", left)
+	}
+
+	// 窗口结束后计数清零
+	mr.FastForward(20 * time.Second)
+	if left, _ := r.GetRemaining("quota", limit); left != limit {
+		t.Errorf("过期后 GetRemaining() = %d, want %d", left, limit)
+	}
+	if n, _ := r.IncrWithExpiry("quota", time.Minute); n != 1 {
+		t.Errorf("过期后 IncrWithExpiry() = %d, want 1", n)
+	}
+	if left, _ := r.GetRemaining("quota", limit); left != 2 {
+		t.Errorf("GetRemaining() = %d, want 2", left)
+	}
+}
+
+func TestIncrWithExpiryConcurrentFirst(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+	const n = 20
+
+	// 所有 goroutine 同时对一个不存在的键做"第一次"递增，
+	// 每种先后顺序下都必须恰好有一次设置过期时间
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, err := r.IncrWithExpiry("quota:race", time.Minute); err != nil {
+				t.Errorf("IncrWithExpiry() error = %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got, _ := mr.Get("quota:race"); got != strconv.Itoa(n) {
+		t.Errorf("计数 = %s, want %d", got, n)
+	}
+	if ttl := mr.TTL("quota:race"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("并发递增后 TTL = %s, want (0, 1m]", ttl)
+	}
+
+	mr.FastForward(30 * time.Second)
+	for range 3 {
+		r.IncrWithExpiry("quota:race", time.Minute)
+	}
+	if ttl := mr.TTL("quota:race"); ttl != 30*time.Second {
+		t.Errorf("后续递增后 TTL = %s, want 30s（不应被重置）", ttl)
+	}
+}
+
+func TestFixedWindowAllow(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+
+	for i := 1; i <= 3; i++ {
+		if ok, count, err := r.FixedWindowAllow("rl:api", 3, time.Minute); !ok || count != i || err != nil {
+			t.Fatalf("第 %d 次 FixedWindowAllow() = %v, %d, %v, want 放行", i, ok, count, err)
+		}
+	}
+	// 超出上限被拒绝，被拒绝的请求同样计数
+	for i := 4; i <= 5; i++ {
+		if ok, count, err := r.FixedWindowAllow("rl:api", 3, time.Minute); ok || count != i || err != nil {
+			t.Errorf("第 %d 次 FixedWindowAllow() = %v, %d, %v, want 拒绝", i, ok, count, err)
+		}
+	}
+	// 窗口从第一次请求开始计时，被拒绝的请求不会延长窗口
+	if ttl := mr.TTL("rl:api"); ttl != time.Minute {
+		t.Errorf("TTL = %v, want 1m", ttl)
+	}
+	// 不同 key 独立计数
+	if ok, count, _ := r.FixedWindowAllow("rl:other", 3, time.Minute); !ok || count != 1 {
+		t.Errorf("其他 key FixedWindowAllow() = %v, %d, want 放行且计数为 1", ok, count)
+	}
+
+	mr.FastForward(time.Minute)
+	if ok, count, err := r.FixedWindowAllow("rl:api", 3, time.Minute); !ok || count != 1 || err != nil {
+		t.Errorf("窗口过期后 FixedWindowAllow() = %v, %d, %v, want 放行且重新计数", ok, count, err)
+	}
+}
+
+// ====== 不低于零的扣减 ======
+
+func TestDecrIfPositive(t *testing.T) {
+	tests := []struct {
+		name    string
+		stock   string // 空表示 key 不存在
+		amount  int64
+		want    int64
+		wantOK  bool
+		wantErr bool
+	}{
+		{"库存充足", "5", 2, 3, true, false},
+		{"正好扣到 0", "2", 2, 0, true, false},
+		{"库存不足返回当前值", "1", 2, 1, false, false},
+		{"key 不存在视为 0", "", 1, 0, false, false},
+		{"值不是整数", "abc", 1, 0, false, true},
+		{"扣减量为 0", "5", 0, 0, false, true},
+		{"扣减量为负数", "5", -1, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRedisClient(t)
+			if tt.stock != "" {
+				r.Set("stock", tt.stock, 0)
+			}
+
+			got, ok, err := r.DecrIfPositive("stock", tt.amount)
+			if got != tt.want || ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Errorf("DecrIfPositive(%d) = %d, %v, %v, want %d, %v (wantErr %v)", tt.amount, got, ok, err, tt.want, tt.wantOK, tt.wantErr)
+			}
+			// 被拒绝或出错时 key 保持不变
+			if !ok {
+				stored, err := r.Get("stock")
+				if (tt.stock == "" && !errors.Is(err, redis.Nil)) || (tt.stock != "" && stored != tt.stock) {
+					t.Errorf("拒绝后 stock = %q, %v, want 保持 %q", stored, err, tt.stock)
+				}
+			}
+		})
+	}
+}
+
+func TestDecrIfPositiveKeepsTTL(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+	r.Set("quota", "10", time.Hour)
+
+	if _, ok, err := r.DecrIfPositive("quota", 3); !ok || err != nil {
+		t.Fatalf("DecrIfPositive() = %v, %v", ok, err)
+	}
+	if ttl := mr.TTL("quota"); ttl != time.Hour {
+		t.Errorf("TTL = %v, want 扣减后保持 1h", ttl)
+	}
+}
+
+func TestDecrIfPositiveConcurrent(t *testing.T) {
+	r := newTestRedisClient(t)
+	r.Set("stock", "50", 0)
+
+	// 100 个请求各扣 1，只有 50 个成功，库存不会变成负数
+	var succeeded atomic.Int64
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			left, ok, err := r.DecrIfPositive("stock", 1)
+			if err != nil {
+				t.Errorf("DecrIfPositive() error = %v", err)
+				return
+			}
+			if left < 0 {
+				t.Errorf("DecrIfPositive() 返回负数 %d", left)
+			}
+			if ok {
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := succeeded.Load(); got != 50 {
+		t.Errorf("成功扣减 %d 次, want 50", got)
+	}
+	if stock, _ := r.Get("stock"); stock != "0" {
+		t.Errorf("最终库存 = %s, want 0", stock)
+	}
+}
+
+// ====== 时间分桶计数器 ======
+
+func TestBuckets(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+	// 另一个实例连接同一个 Redis，计数汇总到相同的桶
+	other := newRedisClient(client, 0)
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	incr := func(r *RedisClient, at time.Time, want int64) {
+		t.Helper()
+		if n, err := r.incrBucketAt("req", time.Minute, at); err != nil || n != want {
+			t.Fatalf("incrBucketAt(%s) = %d, %v, want %d", at.Format("15:04:05"), n, err, want)
+		}
+	}
+	incr(r, t0, 1)
+	incr(other, t0.Add(59*time.Second), 2) // 同一分钟内落在同一个桶
+	incr(r, t0.Add(time.Minute), 1)
+	incr(r, t0.Add(3*time.Minute), 1)
+
+	if ttl := mr.TTL(bucketKey("req", bucketIndex(t0, time.Minute))); ttl != bucketRetention*time.Minute {
+		t.Errorf("桶的 TTL = %v, want %v", ttl, bucketRetention*time.Minute)
+	}
+
+	// 从旧到新，缺失的桶为 0
+	got, err := r.readBucketsAt("req", time.Minute, 5, t0.Add(3*time.Minute+30*time.Second))
+	if want := []int64{0, 2, 1, 0, 1}; err != nil || !slices.Equal(got, want) {
+		t.Errorf("readBucketsAt() = %v, %v, want %v", got, err, want)
+	}
+
+	// 超过保留时长的桶被删除
+	mr.FastForward(bucketRetention * time.Minute)
+	got, _ = r.readBucketsAt("req", time.Minute, 5, t0.Add(3*time.Minute))
+	if want := []int64{0, 0, 0, 0, 0}; !slices.Equal(got, want) {
+		t.Errorf("过期后 readBucketsAt() = %v, want %v", got, want)
+	}
+
+	t.Run("参数和数据错误", func(t *testing.T) {
+		if _, err := r.incrBucketAt("req", 0, t0); err == nil {
+			t.Error("incrBucketAt(bucket=0) 应该返回错误")
+		}
+		if _, err := r.readBucketsAt("req", -time.Second, 3, t0); err == nil {
+			t.Error("readBucketsAt(bucket<0) 应该返回错误")
+		}
+		if got, err := r.readBucketsAt("req", time.Minute, 0, t0); err != nil || got == nil || len(got) != 0 {
+			t.Errorf("readBucketsAt(n=0) = %v, %v, want []", got, err)
+		}
+		mr.Set(bucketKey("bad", bucketIndex(t0, time.Minute)), "abc")
+		if _, err := r.readBucketsAt("bad", time.Minute, 1, t0); err == nil {
+			t.Error("桶的值不是整数时应该返回错误")
+		}
+	})
+}
+
+// ====== Hash 操作 ======
+
+func TestHSetMany(t *testing.T) {
+	r := newTestRedisClient(t)
+
+	n, err := r.HSetMany("user:1", map[string]interface{}{"name": "alice", "age": 30})
+	if err != nil || n != 2 {
+		t.Fatalf("HSetMany() = %d, %v, want 2", n, err)
+	}
+	// 已存在的字段只更新值，不计入新增数
+	n, err = r.HSetMany("user:1", map[string]interface{}{"age": 31, "city": "Beijing"})
+	if err != nil || n != 1 {
+		t.Errorf("HSetMany(更新 + 新增) = %d, %v, want 1", n, err)
+	}
+	got, _ := r.HGetAll("user:1")
+	want := map[string]string{"name": "alice", "age": "31", "city": "Beijing"}
+	if !maps.Equal(got, want) {
+		t.Errorf("HGetAll() = %v, want %v", got, want)
+	}
+
+	for _, values := range []map[string]interface{}{nil, {}} {
+		if n, err := r.HSetMany("user:2", values); n != 0 || err != nil {
+			t.Errorf("HSetMany(%v) = %d, %v, want 0, nil", values, n, err)
+		}
+	}
+	if n, _ := r.client.Exists(r.ctx, "user:2").Result(); n != 0 {
+		t.Error("空 values 不应创建键")
+	}
+}
+
+func TestHRandField(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+	// miniredis 在 RESP3 下把 HRANDFIELD WITHVALUES 的结果作为 map 返回，
+	// 真实的 Redis 返回字段-值对的数组；WithValues 改用 RESP2 连接
+	resp2 := redis.NewClient(&redis.Options{Addr: mr.Addr(), Protocol: 2})
+	t.Cleanup(func() { resp2.Close() })
+	r2 := newRedisClient(resp2, 0)
+	stored := map[string]string{"name": "alice", "age": "30", "city": "Beijing", "role": "admin", "tier": "gold"}
+	r.client.HSet(r.ctx, "user:1", stored)
+
+	tests := []struct {
+		name     string
+		count    int
+		wantLen  int
+		distinct bool
+	}{
+		{"正数返回不重复的字段", 3, 3, true},
+		{"超过字段数时返回全部字段", 10, 5, true},
+		{"负数返回 |count| 个可能重复的字段", -12, 12, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := r.HRandField("user:1", tt.count)
+			if err != nil || len(fields) != tt.wantLen {
+				t.Fatalf("HRandField(%d) = %v, %v, want %d 个字段", tt.count, fields, err, tt.wantLen)
+			}
+			seen := make(map[string]bool)
+			for _, f := range fields {
+				if _, ok := stored[f]; !ok {
+					t.Errorf("返回了哈希外的字段 %q", f)
+				}
+				if tt.distinct && seen[f] {
+					t.Errorf("字段 %q 重复", f)
+				}
+				seen[f] = true
+			}
+
+			// WITHVALUES 时每个字段带上自己的值
+			kvs, err := r2.HRandFieldWithValues("user:1", tt.count)
+			if err != nil || len(kvs) != tt.wantLen {
+				t.Fatalf("HRandFieldWithValues(%d) = %v, %v, want %d 个字段", tt.count, kvs, err, tt.wantLen)
+			}
+			seen = make(map[string]bool)
+			for _, kv := range kvs {
+				if want, ok := stored[kv.Key]; !ok || kv.Value != want {
+					t.Errorf("HRandFieldWithValues() 返回 %s=%q, want %s=%q", kv.Key, kv.Value, kv.Key, want)
+				}
+				if tt.distinct && seen[kv.Key] {
+					t.Errorf("字段 %q 重复", kv.Key)
+				}
+				seen[kv.Key] = true
+			}
+		})
+	}
+
+	if fields, err := r.HRandField("missing", 3); err != nil || len(fields) != 0 {
+		t.Errorf("HRandField(missing) = %v, %v, want 空", fields, err)
+	}
+	if kvs, err := r2.HRandFieldWithValues("missing", -3); err != nil || len(kvs) != 0 {
+		t.Errorf("HRandFieldWithValues(missing) = %v, %v, want 空", kvs, err)
+	}
+}
+
+// ====== Hash 结构体映射 ======
+
+// HashMeta 嵌入的结构体，字段展开到同一个哈希（嵌入类型需要导出）
+type HashMeta struct {
+	ID int64 `redis:"id"`
+}
+
+type hashProfile struct {
+	HashMeta
+	Name      string        `redis:"name"`
+	Age       int8          `redis:"age"`
+	Score     float64       `redis:"score"`
+	VIP       bool          `redis:"vip"`
+	Level     uint16        `redis:"level,omitempty"`
+	UpdatedAt time.Time     `redis:"updated_at"`
+	Timeout   time.Duration `redis:"timeout"`
+	Password  string        `redis:"-"`
+	Nickname  string
+	internal  string
+}
+
+func TestHSetStructRoundTrip(t *testing.T) {
+	r := newTestRedisClient(t)
+	in := hashProfile{
+		HashMeta:  HashMeta{ID: 7},
+		Name:      "alice",
+		Age:       30,
+		Score:     99.5,
+		VIP:       true,
+		Level:     3,
+		UpdatedAt: time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC),
+		Timeout:   1500 * time.Millisecond,
+		Password:  "secret",
+		Nickname:  "ali",
+		internal:  "x",
+	}
+	if err := r.HSetStruct("profile:1", &in); err != nil {
+		t.Fatalf("HSetStruct() error = %v", err)
+	}
+
+	got, _ := r.HGetAll("profile:1")
+	want := map[string]string{
+		"id":         "7",
+		"name":       "alice",
+		"age":        "30",
+		"score":      "99.5",
+		"vip":        "true",
+		"level":      "3",
+		"updated_at": "2024-01-02T03:04:05.123456789Z",
+		"timeout":    "1500000000",
+		"Nickname":   "ali",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("哈希内容 = %v, want %v", got, want)
+	}
+
+	var out hashProfile
+	if err := r.HGetStruct("profile:1", &out); err != nil {
+		t.Fatalf("HGetStruct() error = %v", err)
+	}
+	// redis:"-" 和未导出字段不参与映射
+	in.Password, in.internal = "", ""
+	if out != in {
+		t.Errorf("HGetStruct() = %+v, want %+v", out, in)
+	}
+}
+
+func TestHGetStructPartial(t *testing.T) {
+	r := newTestRedisClient(t)
+	r.HSetMany("profile:2", map[string]interface{}{"name": "bob", "unknown": "ignored"})
+
+	// 哈希中不存在的字段保持原值，多余的字段被忽略
+	out := hashProfile{Name: "old", Age: 20}
+	if err := r.HGetStruct("profile:2", &out); err != nil {
+		t.Fatalf("HGetStruct() error = %v", err)
+	}
+	if out.Name != "bob" || out.Age != 20 {
+		t.Errorf("HGetStruct() = %+v, want Name bob 且 Age 保持 20", out)
+	}
+}
+
+func TestHGetStructErrors(t *testing.T) {
+	r := newTestRedisClient(t)
+	r.HSetMany("bad:age", map[string]interface{}{"age": "300"})
+	r.HSetMany("bad:vip", map[string]interface{}{"vip": "maybe"})
+	r.HSetMany("bad:time", map[string]interface{}{"updated_at": "yesterday"})
+
+	tests := []struct {
+		name    string
+		key     string
+		dest    interface{}
+		wantErr error
+	}{
+		{"键不存在", "profile:missing", &hashProfile{}, ErrNotFound},
+		{"超出 int8 范围", "bad:age", &hashProfile{}, strconv.ErrRange},
+		{"布尔值无效", "bad:vip", &hashProfile{}, strconv.ErrSyntax},
+		{"时间格式无效", "bad:time", &hashProfile{}, nil},
+		{"不是指针", "bad:age", hashProfile{}, nil},
+		{"nil 指针", "bad:age", (*hashProfile)(nil), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.HGetStruct(tt.key, tt.dest)
+			if err == nil {
+				t.Fatal("HGetStruct() 应该返回错误")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("HGetStruct() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHSetStructErrors(t *testing.T) {
+	r := newTestRedisClient(t)
+
+	if err := r.HSetStruct("k", "not a struct"); err == nil {
+		t.Error("HSetStruct(string) 应该返回错误")
+	}
+	type withSlice struct {
+		Tags []string `redis:"tags"`
+	}
+	if err := r.HSetStruct("k", withSlice{Tags: []string{"a"}}); err == nil {
+		t.Error("不支持的字段类型应该返回错误")
+	}
+	// 没有可映射的字段时不发送空的 HSET
+	if err := r.HSetStruct("k", struct{ hidden int }{}); err != nil {
+		t.Errorf("HSetStruct(无字段) error = %v", err)
+	}
+	if n, _ := r.client.Exists(r.ctx, "k").Result(); n != 0 {
+		t.Error("出错或没有字段时不应创建键")
+	}
+}
+
+// ====== List 操作 ======
+
+func TestPushCapped(t *testing.T) {
+	tests := []struct {
+		name string
+		left bool
+		want []string
+	}{
+		{"LPUSH 保留表头最新的 3 个", true, []string{"e", "d", "c"}},
+		{"RPUSH 保留表尾最新的 3 个", false, []string{"c", "d", "e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRedisClient(t)
+
+			if n, err := r.PushCapped("feed", 3, tt.left, "a", "b"); err != nil || n != 2 {
+				t.Fatalf("PushCapped(a, b) = %d, %v, want 2", n, err)
+			}
+			// 超出 maxLen 后裁掉最旧的元素，返回裁剪后的长度
+			if n, err := r.PushCapped("feed", 3, tt.left, "c", "d", "e"); err != nil || n != 3 {
+				t.Fatalf("PushCapped(c, d, e) = %d, %v, want 3", n, err)
+			}
+			if got, _ := r.LRange("feed", 0, -1); !slices.Equal(got, tt.want) {
+				t.Errorf("LRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	r := newTestRedisClient(t)
+	if _, err := r.PushCapped("feed", 0, true, "a"); err == nil {
+		t.Error("PushCapped(maxLen=0) 应该返回错误")
+	}
+	if n, _ := r.LLen("feed"); n != 0 {
+		t.Errorf("maxLen 非法时不应写入, LLen() = %d", n)
+	}
+}
+
+// ====== Set 操作 ======
+
+func TestSMIsMember(t *testing.T) {
+	r := newTestRedisClient(t)
+	r.client.SAdd(r.ctx, "tags", "go", "redis")
+
+	got, err := r.SMIsMember("tags", "go", "java", "redis")
+	if err != nil || !slices.Equal(got, []bool{true, false, true}) {
+		t.Errorf("SMIsMember() = %v, %v, want [true false true]", got, err)
+	}
+	// 键不存在时视为空集合
+	if got, err := r.SMIsMember("missing", "go", "redis"); err != nil || !slices.Equal(got, []bool{false, false}) {
+		t.Errorf("SMIsMember(missing) = %v, %v, want [false false]", got, err)
+	}
+}
+
+func TestSRandMember(t *testing.T) {
+	r := newTestRedisClient(t)
+	members := []string{"a", "b", "c", "d", "e"}
+	r.client.SAdd(r.ctx, "set", "a", "b", "c", "d", "e")
+
+	tests := []struct {
+		name     string
+		count    int
+		wantLen  int
+		distinct bool
+	}{
+		{"正数返回不重复的成员", 3, 3, true},
+		{"超过集合大小时返回全部成员", 10, 5, true},
+		{"负数返回 |count| 个可能重复的成员", -8, 8, false},
+		{"0 返回空", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.SRandMember("set", tt.count)
+			if err != nil || len(got) != tt.wantLen {
+				t.Fatalf("SRandMember(%d) = %v, %v, want %d 个成员", tt.count, got, err, tt.wantLen)
+			}
+			seen := make(map[string]bool)
+			for _, m := range got {
+				if !slices.Contains(members, m) {
+					t.Errorf("返回了集合外的成员 %q", m)
+				}
+				if tt.distinct && seen[m] {
+					t.Errorf("成员 %q 重复", m)
+				}
+				seen[m] = true
+			}
+		})
+	}
+
+	// 不会删除成员
+	if n, _ := r.client.SCard(r.ctx, "set").Result(); n != 5 {
+		t.Errorf("SCard() = %d, want 5", n)
+	}
+	if got, err := r.SRandMember("missing", 3); err != nil || len(got) != 0 {
+		t.Errorf("SRandMember(missing) = %v, %v, want 空", got, err)
+	}
+}
+
+// ====== 排序 ======
+
+// sortStub 拦截 SORT 命令，记录参数并返回预设结果（miniredis 不支持 SORT）
+type sortStub struct {
+	mu    sync.Mutex
+	args  []interface{}
+	reply []string
+	err   error
+}
+
+func (s *sortStub) DialHook(next redis.DialHook) redis.DialHook { return next }
+func (s *sortStub) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (s *sortStub) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() != "sort" {
+			return next(ctx, cmd)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.args = cmd.Args()
+		if s.err != nil {
+			cmd.SetErr(s.err)
+			return s.err
+		}
+		cmd.(*redis.StringSliceCmd).SetVal(s.reply)
+		return nil
+	}
+}
+
+// sent 返回最近一次 SORT 的参数，形如 "[sort key limit 0 3 DESC]"
+func (s *sortStub) sent() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprint(s.args)
+}
+
+// newSortTestClient 带 sortStub 的 RedisClient
+func newSortTestClient(t *testing.T) (*RedisClient, *sortStub) {
+	t.Helper()
+	client, _ := testfixtures.NewTestRedis(t)
+	stub := &sortStub{}
+	client.AddHook(stub)
+	return newRedisClient(client, 0), stub
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     SortOptions
+		wantArgs string
+	}{
+		{"默认数值升序", SortOptions{}, "[sort k]"},
+		{"数值降序前 3 个", SortOptions{Order: "desc", Count: 3}, "[sort k limit 0 3 DESC]"},
+		{"按字典序", SortOptions{Alpha: true}, "[sort k alpha]"},
+		{"分页", SortOptions{Offset: 10, Count: 5}, "[sort k limit 10 5]"},
+		{"只有 Offset 时取到末尾", SortOptions{Offset: 2}, "[sort k limit 2 -1]"},
+		{"按外部 key 排序并取字段", SortOptions{By: "user:*->age", Get: []string{"#", "user:*->name"}, Order: "ASC"},
+			"[sort k by user:*->age get # get user:*->name ASC]"},
+		{"nosort", SortOptions{By: "nosort", Get: []string{"user:*->name"}}, "[sort k by nosort get user:*->name]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, stub := newSortTestClient(t)
+			stub.reply = []string{"a", "b"}
+
+			got, err := r.Sort("k", tt.opts)
+			if err != nil || !slices.Equal(got, stub.reply) {
+				t.Fatalf("Sort() = %v, %v, want %v", got, err, stub.reply)
+			}
+			if sent := stub.sent(); sent != tt.wantArgs {
+				t.Errorf("发送的命令 = %s, want %s", sent, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestSortInvalidOptions(t *testing.T) {
+	r, stub := newSortTestClient(t)
+	for _, opts := range []SortOptions{
+		{Order: "RANDOM"},
+		{Offset: -1},
+		{Count: -1},
+	} {
+		if _, err := r.Sort("k", opts); err == nil {
+			t.Errorf("Sort(%+v) 应该返回错误", opts)
+		}
+	}
+	if sent := stub.sent(); sent != "[]" {
+		t.Errorf("参数无效时不应发送命令, 发送了 %s", sent)
+	}
+}
+
+func TestSortNonNumeric(t *testing.T) {
+	r, stub := newSortTestClient(t)
+	stub.err = errors.New("ERR One or more scores can't be converted into double")
+
+	// 数值排序失败时提示设置 Alpha，并保留原始错误
+	_, err := r.Sort("names", SortOptions{})
+	if err == nil || !strings.Contains(err.Error(), "set Alpha") || !errors.Is(err, stub.err) {
+		t.Errorf("Sort() error = %v, want 提示设置 Alpha 并包装原始错误", err)
+	}
+	// Alpha 排序的错误原样返回
+	if _, err := r.Sort("names", SortOptions{Alpha: true}); err != stub.err {
+		t.Errorf("Sort(Alpha) error = %v, want 原始错误", err)
+	}
+}
+
+func TestSortInts(t *testing.T) {
+	r, stub := newSortTestClient(t)
+
+	stub.reply = []string{"3", "10", "-2"}
+	got, err := r.SortInts("k", SortOptions{})
+	if err != nil || !slices.Equal(got, []int64{3, 10, -2}) {
+		t.Errorf("SortInts() = %v, %v, want [3 10 -2]", got, err)
+	}
+
+	// Get 取到不存在的外部 key 时为空字符串
+	stub.reply = []string{"3", ""}
+	if _, err := r.SortInts("k", SortOptions{Get: []string{"w_*"}}); err == nil || !strings.Contains(err.Error(), "index 1") {
+		t.Errorf("SortInts() error = %v, want 指出第 1 个结果不是整数", err)
+	}
+}
+
+// ====== 排行榜 ======
+
+func TestLeaderboard(t *testing.T) {
+	t.Parallel()
+	lb := NewLeaderboard(newTestRedisClient(t), "lb")
+
+	// 10 名玩家，alice 分两次加分；最终排名 p1..p10
+	for _, s := range []struct {
+		member string
+		delta  float64
+	}{
+		{"alice", 50}, {"bob", 95}, {"carol", 30}, {"dave", 70}, {"erin", 85},
+		{"frank", 60}, {"grace", 20}, {"heidi", 75}, {"ivan", 40}, {"judy", 10},
+		{"alice", 40}, // alice 累计 90
+	} {
+		if _, err := lb.AddScore(s.member, s.delta); err != nil {
+			t.Fatalf("AddScore(%s) error = %v", s.member, err)
+		}
+	}
+	board := []ScoreEntry{
+		{"bob", 95, 1}, {"alice", 90, 2}, {"erin", 85, 3}, {"heidi", 75, 4}, {"dave", 70, 5},
+		{"frank", 60, 6}, {"ivan", 40, 7}, {"carol", 30, 8}, {"grace", 20, 9}, {"judy", 10, 10},
+	}
+
+	top, err := lb.TopN(3)
+	if err != nil {
+		t.Fatalf("TopN() error = %v", err)
+	}
+	if !slices.Equal(top, board[:3]) {
+		t.Errorf("TopN(3) = %v, want %v", top, board[:3])
+	}
+	if top, _ := lb.TopN(0); top != nil {
+		t.Errorf("TopN(0) = %v, want nil", top)
+	}
+	if top, _ := lb.TopN(20); !slices.Equal(top, board) {
+		t.Errorf("TopN(20) = %v, want 全部 10 名（不足时返回全部）", top)
+	}
+
+	for _, e := range board {
+		if rank, err := lb.RankOf(e.Member); err != nil || rank != e.Rank {
+			t.Errorf("RankOf(%s) = %d, %v, want %d", e.Member, rank, err, e.Rank)
+		}
+	}
+	if _, err := lb.RankOf("nobody"); err != redis.Nil {
+		t.Errorf("RankOf(nobody) error = %v, want redis.Nil", err)
+	}
+
+	tests := []struct {
+		name   string
+		member string
+		radius int64
+		want   []ScoreEntry
+	}{
+		{"中间", "frank", 2, board[3:8]},
+		{"榜首时窗口被截断", "bob", 2, board[:3]},
+		{"榜尾时窗口被截断", "judy", 2, board[7:]},
+		{"半径为 0 只有自己", "dave", 0, board[4:5]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			around, err := lb.Around(tt.member, tt.radius)
+			if err != nil {
+				t.Fatalf("Around() error = %v", err)
+			}
+			if !slices.Equal(around, tt.want) {
+				t.Errorf("Around(%s, %d) = %v, want %v", tt.member, tt.radius, around, tt.want)
+			}
+		})
+	}
+	if around, err := lb.Around("nobody", 2); err != redis.Nil || around != nil {
+		t.Errorf("Around(nobody) = %v, %v, want nil, redis.Nil", around, err)
+	}
+}
+
+// ====== 延时队列 ======
+
+func TestDelayQueuePopReady(t *testing.T) {
+	q := NewDelayQueue(newTestRedisClient(t), "delay")
+	now := time.Now()
+
+	for member, runAt := range map[string]time.Time{
+		"past-2": now.Add(-2 * time.Minute),
+		"past-1": now.Add(-time.Minute),
+		"past-3": now.Add(-3 * time.Minute),
+		"now":    now,
+		"future": now.Add(time.Hour),
+	} {
+		if err := q.Schedule(member, runAt); err != nil {
+			t.Fatalf("Schedule(%s) error = %v", member, err)
+		}
+	}
+
+	// 按执行时间从早到晚取出，limit 限制数量
+	got, err := q.PopReady(now, 2)
+	if err != nil {
+		t.Fatalf("PopReady() error = %v", err)
+	}
+	if want := []string{"past-3", "past-2"}; !slices.Equal(got, want) {
+		t.Errorf("PopReady(now, 2) = %v, want %v", got, want)
+	}
+	got, _ = q.PopReady(now, 10)
+	if want := []string{"past-1", "now"}; !slices.Equal(got, want) {
+		t.Errorf("PopReady(now, 10) = %v, want %v", got, want)
+	}
+
+	// 已取出的不会再出现，未到期的保留
+	if got, _ := q.PopReady(now, 10); len(got) != 0 {
+		t.Errorf("再次 PopReady() = %v, want 空", got)
+	}
+	if got, _ := q.PopReady(now.Add(2*time.Hour), 10); !slices.Equal(got, []string{"future"}) {
+		t.Errorf("到期后 PopReady() = %v, want [future]", got)
+	}
+	if got, err := q.PopReady(now, 0); got != nil || err != nil {
+		t.Errorf("PopReady(now, 0) = %v, %v, want nil, nil", got, err)
+	}
+}
+
+func TestDelayQueueConcurrentWorkers(t *testing.T) {
+	r := newTestRedisClient(t)
+	q := NewDelayQueue(r, "delay")
+	past := time.Now().Add(-time.Minute)
+	const tasks = 200
+	for i := range tasks {
+		q.Schedule(fmt.Sprintf("task-%d", i), past)
+	}
+
+	// 多个 worker 同时领取，每个任务只能被领取一次
+	var (
+		mu      sync.Mutex
+		claimed = make(map[string]int)
+		wg      sync.WaitGroup
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				got, err := q.PopReady(time.Now(), 7)
+				if err != nil {
+					t.Errorf("PopReady() error = %v", err)
+					return
+				}
+				if len(got) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, m := range got {
+					claimed[m]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != tasks {
+		t.Errorf("领取了 %d 个不同的任务, want %d", len(claimed), tasks)
+	}
+	for m, n := range claimed {
+		if n != 1 {
+			t.Errorf("任务 %s 被领取了 %d 次", m, n)
+		}
+	}
+}
+
+func TestDelayQueuePollLoop(t *testing.T) {
+	q := NewDelayQueue(newTestRedisClient(t), "delay")
+	q.Schedule("ready", time.Now().Add(-time.Second))
+	q.Schedule("later", time.Now().Add(time.Hour))
+
+	handled := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- q.PollLoop(ctx, 10*time.Millisecond, func(m string) error {
+			handled <- m
+			return errors.New("handler 的错误只记录日志")
+		})
+	}()
+
+	select {
+	case m := <-handled:
+		if m != "ready" {
+			t.Errorf("处理了 %q, want ready", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待 PollLoop 处理任务超时")
+	}
+
+	// 再轮询几次，已处理的任务不会重复，未到期的不会被处理
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("PollLoop() = %v, want context.Canceled", err)
+	}
+	close(handled)
+	for m := range handled {
+		t.Errorf("多处理了任务 %q", m)
+	}
+}
+
+// ====== 可靠队列 Worker ======
+
+// runQueueWorker 在后台运行 w，返回停止并等待 Run 返回的函数
+func runQueueWorker(t *testing.T, w *QueueWorker) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	return func() {
+		cancel()
+		// 每个 worker 最多在 BLMOVE 中阻塞 BlockTimeout
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Run() = %v, want context.Canceled", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run() 没有在 ctx 取消后返回")
+		}
+	}
+}
+
+// listKeyLen List 的长度，键不存在时为 0
+func listKeyLen(t *testing.T, r *RedisClient, key string) int64 {
+	t.Helper()
+	n, err := r.client.LLen(r.ctx, key).Result()
+	if err != nil {
+		t.Fatalf("LLEN %s error = %v", key, err)
+	}
+	return n
+}
+
+func TestQueueWorkerProcesses(t *testing.T) {
+	r := newTestRedisClient(t)
+
+	var mu sync.Mutex
+	var handled []string
+	w := NewQueueWorker(r, "jobs", func(ctx context.Context, job string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, job)
+		return nil
+	}, QueueWorkerOptions{Concurrency: 3})
+
+	var jobs []interface{}
+	var want []string
+	for i := range 10 {
+		jobs = append(jobs, fmt.Sprintf("job-%d", i))
+		want = append(want, fmt.Sprintf("job-%d", i))
+	}
+	if err := w.Enqueue(jobs...); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	stop := runQueueWorker(t, w)
+	waitFor(t, "处理全部任务", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == len(want)
+	})
+	stop()
+
+	// 每个任务恰好处理一次，处理完后不留下辅助数据
+	mu.Lock()
+	slices.Sort(handled)
+	mu.Unlock()
+	if !slices.Equal(handled, want) {
+		t.Errorf("处理的任务 = %v, want %v", handled, want)
+	}
+	for _, key := range []string{"jobs", "jobs:processing", "jobs:attempts", "jobs:dead"} {
+		if n, _ := r.client.Exists(r.ctx, key).Result(); n != 0 {
+			t.Errorf("处理完成后 %s 仍然存在", key)
+		}
+	}
+}
+
+func TestQueueWorkerRetryAndDeadLetter(t *testing.T) {
+	r := newTestRedisClient(t)
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	w := NewQueueWorker(r, "jobs", func(ctx context.Context, job string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[job]++
+		switch {
+		case job == "bad":
+			return errors.New("always fails")
+		case job == "flaky" && calls[job] == 1:
+			return errors.New("fails once")
+		case job == "panics":
+			panic("boom")
+		}
+		return nil
+	}, QueueWorkerOptions{MaxAttempts: 3})
+	w.Enqueue("bad", "flaky", "panics")
+
+	stop := runQueueWorker(t, w)
+	waitFor(t, "失败的任务进入死信队列", func() bool { return listKeyLen(t, r, w.DeadLetterKey()) == 2 })
+	waitFor(t, "队列清空", func() bool {
+		return listKeyLen(t, r, "jobs") == 0 && listKeyLen(t, r, "jobs:processing") == 0
+	})
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 达到 MaxAttempts 才进入死信队列，panic 同样算失败
+	want := map[string]int{"bad": 3, "flaky": 2, "panics": 3}
+	if !maps.Equal(calls, want) {
+		t.Errorf("处理次数 = %v, want %v", calls, want)
+	}
+	dead, _ := r.client.LRange(r.ctx, w.DeadLetterKey(), 0, -1).Result()
+	slices.Sort(dead)
+	if !slices.Equal(dead, []string{"bad", "panics"}) {
+		t.Errorf("死信队列 = %v, want [bad panics]", dead)
+	}
+	// 成功或进入死信队列后失败次数被清除
+	if n, _ := r.client.HLen(r.ctx, "jobs:attempts").Result(); n != 0 {
+		t.Errorf("jobs:attempts 还有 %d 个字段", n)
+	}
+}
+
+func TestQueueWorkerShutdownRequeues(t *testing.T) {
+	r := newTestRedisClient(t)
+
+	started := make(chan struct{})
+	w := NewQueueWorker(r, "jobs", func(ctx context.Context, job string) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, QueueWorkerOptions{MaxAttempts: 1})
+	w.Enqueue("slow")
+
+	stop := runQueueWorker(t, w)
+	<-started
+	stop()
+
+	// 关闭时被中断的任务放回队列，不计入失败次数，也不会进入死信队列
+	queued, _ := r.client.LRange(r.ctx, "jobs", 0, -1).Result()
+	if !slices.Equal(queued, []string{"slow"}) {
+		t.Errorf("jobs = %v, want [slow]", queued)
+	}
+	if n := listKeyLen(t, r, w.DeadLetterKey()) + listKeyLen(t, r, "jobs:processing"); n != 0 {
+		t.Errorf("死信队列或 processing 中有 %d 个任务, want 0", n)
+	}
+	if n, _ := r.client.HLen(r.ctx, "jobs:attempts").Result(); n != 0 {
+		t.Error("关闭时中断不应计入失败次数")
+	}
+}
+
+func TestQueueWorkerRecover(t *testing.T) {
+	r := newTestRedisClient(t)
+	w := NewQueueWorker(r, "jobs", func(ctx context.Context, job string) error { return nil }, QueueWorkerOptions{})
+
+	// 模拟崩溃：processing 中遗留了两个任务，队列中还有一个新任务
+	r.client.LPush(r.ctx, "jobs:processing", "old-1", "old-2")
+	w.Enqueue("new")
+
+	n, err := w.Recover()
+	if err != nil || n != 2 {
+		t.Fatalf("Recover() = %d, %v, want 2", n, err)
+	}
+	// 遗留的任务放在出队端（右侧），先于新任务被取出
+	queued, _ := r.client.LRange(r.ctx, "jobs", 0, -1).Result()
+	if want := []string{"new", "old-1", "old-2"}; !slices.Equal(queued, want) {
+		t.Errorf("jobs = %v, want %v", queued, want)
+	}
+	if n, err := w.Recover(); n != 0 || err != nil {
+		t.Errorf("再次 Recover() = %d, %v, want 0, nil", n, err)
+	}
+}
+
+// ====== 键操作 ======
+
+func TestRenameNX(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+	mr.Set("src", "v1")
+	mr.Set("taken", "v2")
+
+	tests := []struct {
+		name    string
+		key     string
+		newkey  string
+		want    bool
+		wantErr error
+	}{
+		{"目标已存在时不重命名", "src", "taken", false, nil},
+		{"目标不存在时重命名", "src", "dst", true, nil},
+		{"源键不存在返回 ErrNotFound", "missing", "other", false, ErrNotFound},
+	}
+	for _, tt := range tests {
+		got, err := rc.RenameNX(tt.key, tt.newkey)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: RenameNX(%q, %q) = %v, %v, want %v, %v", tt.name, tt.key, tt.newkey, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	// 目标已存在时两个键都保持不变
+	if v, _ := mr.Get("taken"); v != "v2" {
+		t.Errorf("taken = %q, want v2（不被覆盖）", v)
+	}
+	if v, _ := mr.Get("dst"); v != "v1" || mr.Exists("src") {
+		t.Errorf("dst = %q, src 存在 = %v, want v1 且 src 已删除", v, mr.Exists("src"))
+	}
+}
+
+func TestSafeRename(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+	mr.Set("src", "v1")
+	mr.Set("dst", "old")
+
+	// 目标已存在时被覆盖
+	if err := rc.SafeRename("src", "dst"); err != nil {
+		t.Fatalf("SafeRename() error = %v", err)
+	}
+	if v, _ := mr.Get("dst"); v != "v1" || mr.Exists("src") {
+		t.Errorf("dst = %q, src 存在 = %v, want v1 且 src 已删除", v, mr.Exists("src"))
+	}
+
+	if err := rc.SafeRename("src", "dst"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("源键不存在时 SafeRename() error = %v, want ErrNotFound", err)
+	}
+	// Rename 返回的是 Redis 的原始错误
+	if err := rc.Rename("src", "dst"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Rename() error = %v, want ERR no such key", err)
+	}
+}
+
+func TestDelIfType(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+	mr.Set("cache:user:1", "json")
+	mr.HSet("cache:user:2", "name", "alice")
+	mr.Lpush("queue", "job")
+
+	tests := []struct {
+		name     string
+		key      string
+		typ      string
+		want     bool
+		wantKept bool // 调用后键是否仍存在
+	}{
+		{"类型相同时删除", "cache:user:1", "string", true, false},
+		{"类型不同时保留", "cache:user:2", "string", false, true},
+		{"类型名不区分大小写", "queue", "LIST", true, false},
+		{"键不存在", "missing", "string", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rc.DelIfType(tt.key, tt.typ)
+			if err != nil || got != tt.want {
+				t.Errorf("DelIfType(%q, %q) = %v, %v, want %v", tt.key, tt.typ, got, err, tt.want)
+			}
+			if mr.Exists(tt.key) != tt.wantKept {
+				t.Errorf("调用后键存在 = %v, want %v", mr.Exists(tt.key), tt.wantKept)
+			}
+		})
+	}
+
+	t.Run("未知类型返回错误", func(t *testing.T) {
+		if _, err := rc.DelIfType("cache:user:2", "document"); err == nil {
+			t.Error("DelIfType() 未知类型应该返回错误")
+		}
+		if !mr.Exists("cache:user:2") {
+			t.Error("未知类型时不应该删除")
+		}
+	})
+}
+
+// ====== 复制与迁移 ======
+
+func TestCopy(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+	mr.Set("config:v2", "new")
+	mr.SetTTL("config:v2", time.Hour)
+	mr.Set("config:current", "old")
+
+	tests := []struct {
+		name      string
+		src, dst  string
+		replace   bool
+		want      bool
+		wantErr   error
+		wantValue string // 调用后 dst 的值
+	}{
+		{"目标已存在且不覆盖", "config:v2", "config:current", false, false, nil, "old"},
+		{"覆盖已存在的目标", "config:v2", "config:current", true, true, nil, "new"},
+		{"复制到新键", "config:v2", "config:backup", false, true, nil, "new"},
+		{"源键不存在", "missing", "config:x", true, false, ErrNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rc.Copy(tt.src, tt.dst, tt.replace)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Copy(%q, %q, %v) = %v, %v, want %v, %v", tt.src, tt.dst, tt.replace, got, err, tt.want, tt.wantErr)
+			}
+			if v, _ := mr.Get(tt.dst); v != tt.wantValue {
+				t.Errorf("%s = %q, want %q", tt.dst, v, tt.wantValue)
+			}
+		})
+	}
+
+	// 复制值的同时复制过期时间，源键保持不变
+	if ttl := mr.TTL("config:backup"); ttl != time.Hour {
+		t.Errorf("复制后的 TTL = %v, want 1h", ttl)
+	}
+	if v, _ := mr.Get("config:v2"); v != "new" {
+		t.Errorf("源键 = %q, want 不变", v)
+	}
+}
+
+func TestCopyToDB(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+	mr.Set("user:1", "alice")
+
+	if ok, err := rc.CopyToDB("user:1", "user:1", 1, false); !ok || err != nil {
+		t.Fatalf("CopyToDB() = %v, %v, want true", ok, err)
+	}
+	if v, _ := mr.DB(1).Get("user:1"); v != "alice" {
+		t.Errorf("db 1 中的 user:1 = %q, want alice", v)
+	}
+	// 目标数据库中已存在
+	if ok, err := rc.CopyToDB("user:1", "user:1", 1, false); ok || err != nil {
+		t.Errorf("再次 CopyToDB() = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func TestDumpRestore(t *testing.T) {
+	t.Parallel()
+	src := newTestRedisClient(t)
+	dstClient, dstMR := testfixtures.NewTestRedis(t)
+	dst := newRedisClient(dstClient, 0)
+
+	if err := src.Set("user:1", "alice", 0); err != nil {
+		t.Fatal(err)
+	}
+	data, err := src.Dump("user:1")
+	if err != nil || len(data) == 0 {
+		t.Fatalf("Dump() = %q, %v", data, err)
+	}
+
+	// 在另一个实例上还原，并带上过期时间
+	if err := dst.Restore("user:1", time.Minute, data, false); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if v, _ := dstMR.Get("user:1"); v != "alice" || dstMR.TTL("user:1") != time.Minute {
+		t.Errorf("还原后 user:1 = %q (TTL %v), want alice (TTL 1m)", v, dstMR.TTL("user:1"))
+	}
+
+	// 已存在时不覆盖，replace 为 true 时覆盖
+	dstMR.Set("user:1", "changed")
+	if err := dst.Restore("user:1", 0, data, false); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("键已存在时 Restore() error = %v, want ErrKeyExists", err)
+	}
+	if v, _ := dstMR.Get("user:1"); v != "changed" {
+		t.Errorf("未覆盖时 user:1 = %q, want changed", v)
+	}
+	if err := dst.Restore("user:1", 0, data, true); err != nil {
+		t.Fatalf("Restore(replace) error = %v", err)
+	}
+	if v, _ := dstMR.Get("user:1"); v != "alice" || dstMR.TTL("user:1") != 0 {
+		t.Errorf("覆盖后 user:1 = %q (TTL %v), want alice 且不过期", v, dstMR.TTL("user:1"))
+	}
+
+	if _, err := src.Dump("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("键不存在时 Dump() error = %v, want ErrNotFound", err)
+	}
+}
+
+// ====== 过期操作 ======
+
+func TestExpireWithOption(t *testing.T) {
+	r := newTestRedisClient(t)
+	ttl := func(key string) time.Duration {
+		t.Helper()
+		d, err := r.client.TTL(r.ctx, key).Result()
+		if err != nil {
+			t.Fatalf("TTL(%s) error = %v", key, err)
+		}
+		return d
+	}
+	reset := func(key string, ttl time.Duration) {
+		t.Helper()
+		if err := r.client.Set(r.ctx, key, "v", ttl).Err(); err != nil {
+			t.Fatalf("Set(%s) error = %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		initial time.Duration // 0 表示没有过期时间
+		opt     string
+		ttl     time.Duration
+		wantOK  bool
+		wantTTL time.Duration // -1 表示没有过期时间
+	}{
+		{"NX 没有过期时间时设置", 0, "NX", time.Minute, true, time.Minute},
+		{"NX 已有过期时间时不设置", time.Hour, "nx", time.Minute, false, time.Hour},
+		{"XX 已有过期时间时设置", time.Hour, "XX", time.Minute, true, time.Minute},
+		{"XX 没有过期时间时不设置", 0, "XX", time.Minute, false, -1},
+		{"GT 只延长", time.Minute, "GT", time.Hour, true, time.Hour},
+		{"GT 不缩短", time.Hour, "GT", time.Minute, false, time.Hour},
+		{"GT 没有过期时间视为无限长", 0, "gt", time.Hour, false, -1},
+		{"LT 只缩短", time.Hour, "LT", time.Minute, true, time.Minute},
+		{"LT 不延长", time.Minute, "LT", time.Hour, false, time.Minute},
+		{"LT 没有过期时间总会设置", 0, "LT", time.Hour, true, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset("k", tt.initial)
+			ok, err := r.ExpireWithOption("k", tt.ttl, tt.opt)
+			if err != nil || ok != tt.wantOK {
+				t.Fatalf("ExpireWithOption(%v, %s) = %v, %v, want %v", tt.ttl, tt.opt, ok, err, tt.wantOK)
+			}
+			if got := ttl("k"); got != tt.wantTTL {
+				t.Errorf("TTL = %v, want %v", got, tt.wantTTL)
+			}
+		})
+	}
+
+	if ok, err := r.ExpireWithOption("missing", time.Minute, "NX"); ok || err != nil {
+		t.Errorf("键不存在时 ExpireWithOption() = %v, %v, want false, nil", ok, err)
+	}
+	if _, err := r.ExpireWithOption("k", time.Minute, "EQ"); err == nil {
+		t.Error("不支持的选项应该返回错误")
+	}
+}
+
+func TestExpireMany(t *testing.T) {
+	r := newTestRedisClient(t)
+	for _, key := range []string{"a", "b", "c"} {
+		r.client.Set(r.ctx, key, "v", 0)
+	}
+
+	n, err := r.ExpireMany([]string{"a", "b", "missing", "c"}, time.Minute)
+	if err != nil || n != 3 {
+		t.Fatalf("ExpireMany() = %d, %v, want 3（不存在的键不计入）", n, err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if d, _ := r.client.TTL(r.ctx, key).Result(); d != time.Minute {
+			t.Errorf("TTL(%s) = %v, want 1m", key, d)
+		}
+	}
+	if n, err := r.ExpireMany(nil, time.Minute); n != 0 || err != nil {
+		t.Errorf("ExpireMany(nil) = %d, %v, want 0, nil", n, err)
+	}
+}
+
+// ====== 批量读取与删除 ======
+
+// pipelineCounter 统计包含 name 命令的管道数
+type pipelineCounter struct {
+	name string
+	n    atomic.Int32
+}
+
+func (p *pipelineCounter) DialHook(next redis.DialHook) redis.DialHook          { return next }
+func (p *pipelineCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook { return next }
+
+func (p *pipelineCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if slices.ContainsFunc(cmds, func(c redis.Cmder) bool { return c.Name() == p.name }) {
+			p.n.Add(1)
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func TestBatchGet(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+	mr.Set("user:1", "alice")
+	mr.Set("user:3", "carol")
+	mr.Set("empty", "")
+	mr.Lpush("queue:jobs", "job")
+	mr.HSet("user:profile", "name", "dave")
+
+	values, errs := rc.BatchGet([]string{"user:1", "user:2", "queue:jobs", "user:3", "empty", "user:profile", "user:1"})
+
+	// 类型错误的键不影响其他键；不存在的键不在结果中，也不算错误
+	want := map[string]string{"user:1": "alice", "user:3": "carol", "empty": ""}
+	if !maps.Equal(values, want) {
+		t.Errorf("BatchGet() values = %v, want %v", values, want)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("BatchGet() errs = %v, want 2 个类型错误", errs)
+	}
+	for i, key := range []string{"queue:jobs", "user:profile"} {
+		if msg := errs[i].Error(); !strings.HasPrefix(msg, "GET "+key+": ") || !strings.Contains(msg, "WRONGTYPE") {
+			t.Errorf("errs[%d] = %q, want GET %s: WRONGTYPE ...", i, msg, key)
+		}
+	}
+
+	if values, errs := rc.BatchGet(nil); len(values) != 0 || errs != nil {
+		t.Errorf("BatchGet(nil) = %v, %v, want 空", values, errs)
+	}
+}
+
+func TestBatchDelete(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+	mr.Set("user:1", "alice")
+	mr.Lpush("queue:jobs", "job")
+	mr.HSet("user:profile", "name", "dave")
+	mr.Set("keep", "v")
+
+	// DEL 可以删除任何类型；不存在和重复的键不计入
+	deleted, errs := rc.BatchDelete([]string{"user:1", "missing", "queue:jobs", "user:profile", "user:1"})
+	if deleted != 3 || errs != nil {
+		t.Errorf("BatchDelete() = %d, %v, want 3, nil", deleted, errs)
+	}
+	if keys := mr.Keys(); !slices.Equal(keys, []string{"keep"}) {
+		t.Errorf("剩余的键 = %v, want [keep]", keys)
+	}
+}
+
+func TestBatchPipelines(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	gets := &pipelineCounter{name: "get"}
+	dels := &pipelineCounter{name: "del"}
+	client.AddHook(gets)
+	client.AddHook(dels)
+	rc := newRedisClient(client, 0)
+
+	keys := make([]string, 2*batchPipelineSize+1)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k:%d", i)
+		mr.Set(keys[i], strconv.Itoa(i))
+	}
+
+	// 每 batchPipelineSize 个键一个管道
+	values, errs := rc.BatchGet(keys)
+	if len(values) != len(keys) || errs != nil || values["k:2000"] != "2000" {
+		t.Errorf("BatchGet() 返回 %d 个值, errs = %v, want %d 个", len(values), errs, len(keys))
+	}
+	if n := gets.n.Load(); n != 3 {
+		t.Errorf("BatchGet 使用了 %d 个管道, want 3", n)
+	}
+
+	deleted, errs := rc.BatchDelete(keys)
+	if deleted != int64(len(keys)) || errs != nil {
+		t.Errorf("BatchDelete() = %d, %v, want %d", deleted, errs, len(keys))
+	}
+	if n := dels.n.Load(); n != 3 {
+		t.Errorf("BatchDelete 使用了 %d 个管道, want 3", n)
+	}
+}
+
+func TestBatchConnectionError(t *testing.T) {
+	t.Parallel()
+	client, mr := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+	mr.Close()
+
+	// 连接失败时这一批中的每个键都带上错误
+	keys := []string{"a", "b", "c"}
+	values, errs := rc.BatchGet(keys)
+	if len(values) != 0 || len(errs) != len(keys) {
+		t.Errorf("BatchGet() = %v, %d 个错误, want 空和 %d 个错误", values, len(errs), len(keys))
+	}
+	deleted, errs := rc.BatchDelete(keys)
+	if deleted != 0 || len(errs) != len(keys) {
+		t.Errorf("BatchDelete() = %d, %d 个错误, want 0 和 %d 个错误", deleted, len(errs), len(keys))
+	}
+	for i, err := range errs {
+		if !strings.HasPrefix(err.Error(), "DEL "+keys[i]+": ") {
+			t.Errorf("errs[%d] = %q, want 以 DEL %s: 开头", i, err, keys[i])
+		}
+	}
+}
+
+// ====== 复制确认写入 ======
+
+// waitStub 拦截 WAIT 命令并返回预设的确认数（miniredis 没有副本，WAIT 总是返回 0）
+type waitStub struct {
+	mu    sync.Mutex
+	cmds  []string // 依次收到的命令
+	acked int64
+	err   error
+}
+
+func (s *waitStub) DialHook(next redis.DialHook) redis.DialHook { return next }
+func (s *waitStub) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (s *waitStub) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		// 新连接初始化时的 HELLO 等命令也会经过这里，只记录 SET 和 WAIT
+		switch cmd.Name() {
+		case "set":
+			s.record(cmd)
+			return next(ctx, cmd)
+		case "wait":
+			s.record(cmd)
+		default:
+			return next(ctx, cmd)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.err != nil {
+			cmd.SetErr(s.err)
+			return s.err
+		}
+		cmd.(*redis.IntCmd).SetVal(s.acked)
+		return nil
+	}
+}
+
+// record 记录收到的命令
+func (s *waitStub) record(cmd redis.Cmder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cmds = append(s.cmds, fmt.Sprint(cmd.Args()))
+}
+
+func TestSetAndWait(t *testing.T) {
+	errWait := errors.New("connection reset")
+	tests := []struct {
+		name      string
+		acked     int64
+		waitErr   error
+		replicas  int
+		wantAcked int64
+		wantErr   error
+	}{
+		{"确认数等于要求", 1, nil, 1, 1, nil},
+		{"确认数多于要求", 2, nil, 1, 2, nil},
+		{"确认数不足", 1, nil, 2, 1, ErrNotEnoughReplicas},
+		{"没有副本确认", 0, nil, 1, 0, ErrNotEnoughReplicas},
+		{"WAIT 出错", 0, errWait, 1, 0, errWait},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mr := testfixtures.NewTestRedis(t)
+			stub := &waitStub{acked: tt.acked, err: tt.waitErr}
+			client.AddHook(stub)
+			rc := newRedisClient(client, 0)
+
+			acked, err := rc.SetAndWait("order:1001", "paid", time.Minute, tt.replicas, 100*time.Millisecond)
+			if acked != tt.wantAcked || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetAndWait() = %d, %v, want %d, %v", acked, err, tt.wantAcked, tt.wantErr)
+			}
+
+			// 确认数不足时写入也不回滚
+			if v, _ := mr.Get("order:1001"); v != "paid" || mr.TTL("order:1001") != time.Minute {
+				t.Errorf("order:1001 = %q (TTL %v), want paid (TTL 1m)", v, mr.TTL("order:1001"))
+			}
+			want := []string{"[set order:1001 paid ex 60]", fmt.Sprintf("[wait %d 100]", tt.replicas)}
+			if !slices.Equal(stub.cmds, want) {
+				t.Errorf("发送的命令 = %q, want %q", stub.cmds, want)
+			}
+		})
+	}
+}
+
+func TestSetAndWaitNoReplicas(t *testing.T) {
+	t.Parallel()
+	client, _ := testfixtures.NewTestRedis(t)
+	rc := newRedisClient(client, 0)
+
+	// 没有拦截 WAIT：单机 Redis 上 WAIT 返回 0，要求至少 1 个副本时必然失败
+	acked, err := rc.SetAndWait("k", "v", 0, 1, 10*time.Millisecond)
+	if acked != 0 || !errors.Is(err, ErrNotEnoughReplicas) {
+		t.Errorf("SetAndWait() = %d, %v, want 0, ErrNotEnoughReplicas", acked, err)
+	}
+}
+
+func TestSetAndWaitInvalid(t *testing.T) {
+	t.Parallel()
+	client, _ := testfixtures.NewTestRedis(t)
+	stub := &waitStub{acked: 1}
+	client.AddHook(stub)
+	rc := newRedisClient(client, 0)
+
+	tests := []struct {
+		name     string
+		replicas int
+		timeout  time.Duration
+	}{
+		{"numReplicas 为 0", 0, time.Second},
+		{"timeout 为 0（无限等待）", 1, 0},
+		{"timeout 小于 1ms", 1, time.Microsecond},
+	}
+	for _, tt := range tests {
+		if _, err := rc.SetAndWait("k", "v", 0, tt.replicas, tt.timeout); err == nil {
+			t.Errorf("%s: SetAndWait() 应该返回错误", tt.name)
+		}
+	}
+	if len(stub.cmds) != 0 {
+		t.Errorf("参数无效时不应该发送命令, got %q", stub.cmds)
+	}
+}
+
+// ====== 集群多键操作 ======
+
+func TestHashSlot(t *testing.T) {
+	// 期望值来自 Redis 的 CLUSTER KEYSLOT
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"foo", 12182},
+		{"somekey", 11058},
+		{"foo{hash_tag}", 2515},
+		{"{hash_tag}bar", 2515},
+		{"{}foo", HashSlot("{}foo")}, // 花括号内为空时整个键参与计算
+		{"foo{}{bar}", HashSlot("foo{}{bar}")},
+	}
+	for _, tt := range tests {
+		if got := HashSlot(tt.key); got != tt.want {
+			t.Errorf("HashSlot(%q) = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+
+	tagTests := []struct {
+		key, want string
+	}{
+		{"user:{1000}:profile", "1000"},
+		{"{a}{b}", "a"},    // 只使用第一个花括号
+		{"{}foo", "{}foo"}, // 空标签不生效
+		{"foo{}{bar}", "foo{}{bar}"},
+		{"foo{bar", "foo{bar"}, // 没有右花括号
+		{"plain", "plain"},
+	}
+	for _, tt := range tagTests {
+		if got := hashTagKey(tt.key); got != tt.want {
+			t.Errorf("hashTagKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSameSlot(t *testing.T) {
+	if !SameSlot() || !SameSlot("foo") {
+		t.Error("没有键或只有一个键时应该返回 true")
+	}
+	if !SameSlot("user:{1000}:profile", "user:{1000}:settings") {
+		t.Error("哈希标签相同的键应该在同一个槽")
+	}
+	if SameSlot("foo", "somekey") {
+		t.Error("foo 和 somekey 不在同一个槽")
+	}
+}
+
+// pipelineRecorder 记录管道中发送的 MGET 命令（连接初始化的 CLIENT SETINFO 也走管道，需要排除）
+type pipelineRecorder struct {
+	mu   sync.Mutex
+	cmds [][]interface{}
+}
+
+func (p *pipelineRecorder) DialHook(next redis.DialHook) redis.DialHook          { return next }
+func (p *pipelineRecorder) ProcessHook(next redis.ProcessHook) redis.ProcessHook { return next }
+
+func (p *pipelineRecorder) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		p.mu.Lock()
+		for _, cmd := range cmds {
+			if cmd.Name() == "mget" {
+				p.cmds = append(p.cmds, cmd.Args())
+			}
+		}
+		p.mu.Unlock()
+		return next(ctx, cmds)
+	}
+}
+
+func TestMGetClusterSafe(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+	rec := &pipelineRecorder{}
+	client.AddHook(rec)
+
+	mr.Set("foo", "1")
+	mr.Set("somekey", "2")
+	mr.Set("user:{1}:a", "3")
+	mr.Set("user:{1}:b", "4")
+
+	keys := []string{"user:{1}:a", "foo", "missing", "user:{1}:b", "somekey", "foo"}
+	got, err := r.MGetClusterSafe(keys...)
+	if err != nil {
+		t.Fatalf("MGetClusterSafe() error = %v", err)
+	}
+	// 按 keys 的顺序返回，不存在的键为 nil，重复的键各自有结果
+	want := []interface{}{"3", "1", nil, "4", "2", "1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("MGetClusterSafe() = %v, want %v", got, want)
+	}
+
+	// 每个槽一个 MGET，同一槽的键使用同一个 MGET
+	slots := make(map[int]bool)
+	for _, k := range keys {
+		slots[HashSlot(k)] = true
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.cmds) != len(slots) {
+		t.Fatalf("发送了 %d 条命令 %v, want 每个槽一条（%d）", len(rec.cmds), rec.cmds, len(slots))
+	}
+	for _, args := range rec.cmds {
+		var cmdKeys []string
+		for _, a := range args[1:] {
+			cmdKeys = append(cmdKeys, a.(string))
+		}
+		if !SameSlot(cmdKeys...) {
+			t.Errorf("命令 %v 应该是单个槽的 MGET", args)
+		}
+	}
+
+	if got, err := r.MGetClusterSafe(); err != nil || len(got) != 0 {
+		t.Errorf("MGetClusterSafe() 没有键 = %v, %v, want []", got, err)
+	}
+}
+
+// ====== 持久订阅 ======
+
+// received 记录订阅处理函数收到的消息
+type received struct {
+	mu   sync.Mutex
+	msgs map[string][]string // channel -> payloads
+}
+
+func (rc *received) handler(channel, payload string) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.msgs == nil {
+		rc.msgs = make(map[string][]string)
+	}
+	rc.msgs[channel] = append(rc.msgs[channel], payload)
+	return nil
+}
+
+func (rc *received) get(channel string) []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return slices.Clone(rc.msgs[channel])
+}
+
+// runSubscription 在后台运行订阅，测试结束时取消并检查返回值
+func runSubscription(t *testing.T, subscribe func(ctx context.Context) error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- subscribe(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("ctx 取消后返回 %v, want nil", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Error("ctx 取消后订阅没有退出")
+		}
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+
+	var got received
+	runSubscription(t, func(ctx context.Context) error {
+		return r.Subscribe(ctx, []string{"orders", "users"}, got.handler)
+	})
+	waitFor(t, "订阅生效", func() bool { return mr.PubSubNumSub("orders")["orders"] == 1 })
+
+	// 同一频道内按发布顺序处理
+	var want []string
+	for i := range 20 {
+		want = append(want, fmt.Sprint(i))
+		mr.Publish("orders", fmt.Sprint(i))
+	}
+	mr.Publish("users", "alice")
+	mr.Publish("other", "不应收到")
+
+	waitFor(t, "收到全部消息", func() bool { return len(got.get("orders")) == 20 && len(got.get("users")) == 1 })
+	if o := got.get("orders"); !slices.Equal(o, want) {
+		t.Errorf("orders 收到 %v, want 按发布顺序的 %v", o, want)
+	}
+	if o := got.get("other"); len(o) != 0 {
+		t.Errorf("收到了未订阅频道的消息 %v", o)
+	}
+}
+
+func TestPSubscribe(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+
+	var got received
+	runSubscription(t, func(ctx context.Context) error {
+		return r.PSubscribe(ctx, []string{"news.*"}, got.handler)
+	})
+	waitFor(t, "订阅生效", func() bool { return mr.PubSubNumPat() == 1 })
+
+	mr.Publish("news.sport", "goal")
+	mr.Publish("news.tech", "go 1.24")
+	mr.Publish("weather", "sunny")
+
+	// handler 收到的是实际的频道名，而不是模式
+	waitFor(t, "收到匹配的消息", func() bool { return len(got.get("news.sport")) == 1 && len(got.get("news.tech")) == 1 })
+	if w := got.get("weather"); len(w) != 0 {
+		t.Errorf("收到了不匹配的频道 %v", w)
+	}
+}
+
+func TestSubscribeReconnect(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+
+	var got received
+	runSubscription(t, func(ctx context.Context) error {
+		return r.Subscribe(ctx, []string{"orders"}, got.handler)
+	})
+	waitFor(t, "订阅生效", func() bool { return mr.PubSubNumSub("orders")["orders"] == 1 })
+	mr.Publish("orders", "before")
+	waitFor(t, "断线前的消息", func() bool { return len(got.get("orders")) == 1 })
+
+	// 服务端重启，订阅连接断开后应自动重新订阅
+	mr.Close()
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	waitFor(t, "重新订阅", func() bool { return mr.PubSubNumSub("orders")["orders"] == 1 })
+
+	mr.Publish("orders", "after")
+	waitFor(t, "重连后的消息", func() bool { return len(got.get("orders")) == 2 })
+	if o := got.get("orders"); !slices.Equal(o, []string{"before", "after"}) {
+		t.Errorf("收到 %v, want [before after]", o)
+	}
+}
+
+func TestSubscribeWorkerPool(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+
+	// slow 频道的处理函数阻塞，不应影响其他频道（slow 和 fast 按频道名哈希到不同的 worker）；
+	// panic 也不会中断订阅
+	release := make(chan struct{})
+	defer close(release)
+	var got received
+	runSubscription(t, func(ctx context.Context) error {
+		return r.Subscribe(ctx, []string{"slow", "fast", "bad"}, func(channel, payload string) error {
+			switch channel {
+			case "slow":
+				<-release
+			case "bad":
+				panic("handler panic")
+			}
+			return got.handler(channel, payload)
+		})
+	})
+	waitFor(t, "订阅生效", func() bool { return mr.PubSubNumSub("fast")["fast"] == 1 })
+
+	mr.Publish("slow", "blocked")
+	mr.Publish("bad", "boom")
+	for i := range 5 {
+		mr.Publish("fast", fmt.Sprint(i))
+	}
+	waitFor(t, "fast 频道的消息", func() bool { return len(got.get("fast")) == 5 })
+}
+
+func TestSubscribeNoChannels(t *testing.T) {
+	r := newTestRedisClient(t)
+	if err := r.Subscribe(context.Background(), nil, func(string, string) error { return nil }); err == nil {
+		t.Error("Subscribe(nil) 应该返回错误")
+	}
+}
+
+// ====== 键空间通知 ======
+
+func TestParseKeyEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		payload string
+		want    KeyEvent
+		wantOK  bool
+	}{
+		{"keyspace 频道", "__keyspace@0__:session:1", "expired", KeyEvent{Key: "session:1", Event: "expired"}, true},
+		{"keyevent 频道", "__keyevent@3__:del", "user:1", KeyEvent{Key: "user:1", Event: "del"}, true},
+		{"键名包含 __:", "__keyspace@0__:a__:b", "set", KeyEvent{Key: "a__:b", Event: "set"}, true},
+		{"普通频道", "orders", "1", KeyEvent{}, false},
+		{"缺少键名", "__keyspace@0__:", "set", KeyEvent{}, false},
+		{"未知前缀", "__other@0__:key", "set", KeyEvent{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseKeyEvent(tt.channel, tt.payload)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseKeyEvent(%q, %q) = %+v, %v, want %+v, %v", tt.channel, tt.payload, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWatchKeyspaceEvents(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+
+	var (
+		mu     sync.Mutex
+		events []KeyEvent
+	)
+	runSubscription(t, func(ctx context.Context) error {
+		return r.WatchKeyspaceEvents(ctx, "session:*", func(ev KeyEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, ev)
+			return nil
+		})
+	})
+	waitFor(t, "订阅生效", func() bool { return mr.PubSubNumPat() == 1 })
+
+	// miniredis 不会发布键空间通知，手动发布与 Redis 相同格式的消息
+	mr.Publish("__keyspace@0__:session:1", "set")
+	mr.Publish("__keyspace@0__:session:1", "expired")
+	mr.Publish("__keyspace@0__:user:1", "set")    // 不匹配 pattern
+	mr.Publish("__keyspace@1__:session:2", "set") // 其他 DB
+
+	want := []KeyEvent{{Key: "session:1", Event: "set"}, {Key: "session:1", Event: "expired"}}
+	waitFor(t, "收到事件", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) >= len(want)
+	})
+	time.Sleep(20 * time.Millisecond) // 确认没有多余的事件
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(events, want) {
+		t.Errorf("收到 %+v, want %+v", events, want)
+	}
+}
+
+// ====== 布隆过滤器 ======
+
+func TestBloomFilter(t *testing.T) {
+	r := newTestRedisClient(t)
+
+	// miniredis 不支持 RedisBloom，使用位数组实现
+	bf, err := r.NewBloomFilter("bloom:urls", 1000, 0.01)
+	if err != nil {
+		t.Fatalf("NewBloomFilter() error = %v", err)
+	}
+	if bf.Native() {
+		t.Fatal("Native() = true, want 位数组实现")
+	}
+
+	for i := range 1000 {
+		if err := bf.Add(fmt.Sprintf("https://example.com/%d", i)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	// 已添加的元素一定存在
+	for i := range 1000 {
+		if ok, err := bf.Exists(fmt.Sprintf("https://example.com/%d", i)); !ok || err != nil {
+			t.Fatalf("Exists(已添加的第 %d 个) = %v, %v, want true", i, ok, err)
+		}
+	}
+
+	// 未添加的元素误判率接近配置的 1%
+	falsePositives := 0
+	for i := range 10000 {
+		if ok, _ := bf.Exists(fmt.Sprintf("https://other.com/%d", i)); ok {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.03 {
+		t.Errorf("误判率 = %.3f, want 接近 0.01", rate)
+	}
+}
+
+func TestNewBloomFilterInvalid(t *testing.T) {
+	r := newTestRedisClient(t)
+	tests := []struct {
+		name          string
+		expectedItems int64
+		fpRate        float64
+	}{
+		{"预期元素数为 0", 0, 0.01},
+		{"误判率为 0", 1000, 0},
+		{"误判率为 1", 1000, 1},
+		{"位数组超过字符串上限", 1 << 40, 0.01},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.NewBloomFilter("bloom", tt.expectedItems, tt.fpRate); err == nil {
+				t.Errorf("NewBloomFilter(%d, %v) 应该返回错误", tt.expectedItems, tt.fpRate)
+			}
+		})
+	}
+}
+
+func TestBloomParams(t *testing.T) {
+	// n=100 万、p=1%：m ≈ 958 万位，k = 7
+	bits, hashes := bloomParams(1_000_000, 0.01)
+	if bits < 9_500_000 || bits > 9_600_000 || hashes != 7 {
+		t.Errorf("bloomParams(1e6, 0.01) = %d, %d, want ≈9585059, 7", bits, hashes)
+	}
+	// 误判率很高时至少使用一个哈希函数
+	if _, hashes := bloomParams(10, 0.9); hashes != 1 {
+		t.Errorf("bloomParams(10, 0.9) hashes = %d, want 1", hashes)
+	}
+}
+
+// ====== 幂等键 ======
+
+func TestIdempotencyKey(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	r := newRedisClient(client, 0)
+
+	type result struct {
+		OrderID int    `json:"order_id"`
+		Status  string `json:"status"`
+	}
+
+	// 第一次请求：获得幂等键，执行操作并保存结果
+	first, err := r.AcquireIdempotencyKey("pay-1", time.Minute)
+	if err != nil || !first {
+		t.Fatalf("第一次 AcquireIdempotencyKey() = %v, %v, want true", first, err)
+	}
+	var got result
+	if found, err := r.GetIdempotentResult("pay-1", &got); err != nil || found {
+		t.Fatalf("保存前 GetIdempotentResult() = %v, %v, want false", found, err)
+	}
+	if err := r.StoreIdempotentResult("pay-1", result{42, "paid"}, time.Minute); err != nil {
+		t.Fatalf("StoreIdempotentResult() error = %v", err)
+	}
+
+	// 重复请求：拿不到幂等键，但能读到第一次的结果
+	first, err = r.AcquireIdempotencyKey("pay-1", time.Minute)
+	if err != nil || first {
+		t.Fatalf("重复 AcquireIdempotencyKey() = %v, %v, want false", first, err)
+	}
+	found, err := r.GetIdempotentResult("pay-1", &got)
+	if err != nil || !found || got != (result{42, "paid"}) {
+		t.Errorf("GetIdempotentResult() = %v, %+v, %v, want true, {42 paid}", found, got, err)
+	}
+
+	// 不同的键互不影响
+	if first, _ := r.AcquireIdempotencyKey("pay-2", time.Minute); !first {
+		t.Error("AcquireIdempotencyKey(pay-2) = false, want true")
+	}
+
+	// ttl 过期后可以重新获得
+	mr.FastForward(time.Minute)
+	if first, _ := r.AcquireIdempotencyKey("pay-1", time.Minute); !first {
+		t.Error("过期后 AcquireIdempotencyKey() = false, want true")
+	}
+}
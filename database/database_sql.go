@@ -4,9 +4,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	// 导入数据库驱动
@@ -24,12 +27,13 @@ import (
 // User 结构体表示用户表的数据模型
 // 使用标签（tag）来映射数据库列名
 type User struct {
-	ID        int64     `json:"id"`         // 用户唯一标识
-	Username  string    `json:"username"`   // 用户名
-	Email     string    `json:"email"`      // 邮箱
-	Password  string    `json:"-"`          // 密码不序列化
-	CreatedAt time.Time `json:"created_at"` // 创建时间
-	UpdatedAt time.Time `json:"updated_at"` // 更新时间
+	ID        int64        `json:"id"`         // 用户唯一标识
+	Username  string       `json:"username"`   // 用户名
+	Email     string       `json:"email"`      // 邮箱
+	Password  string       `json:"-"`          // 密码不序列化
+	CreatedAt time.Time    `json:"created_at"` // 创建时间
+	UpdatedAt time.Time    `json:"updated_at"` // 更新时间
+	DeletedAt sql.NullTime `json:"deleted_at"` // 软删除时间，NULL 表示未删除
 }
 
 // UserModel 数据库操作封装
@@ -97,8 +101,10 @@ func (m *UserModel) CreateTable() error {
 			password VARCHAR(255) NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP NULL DEFAULT NULL,
 			INDEX idx_username (username),
-			INDEX idx_email (email)
+			INDEX idx_email (email),
+			INDEX idx_deleted_at (deleted_at)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
 	`
 
@@ -111,8 +117,10 @@ func (m *UserModel) CreateTable() error {
 	}
 
 	// 3. 检查影响行数（可选）
-	// RowsAffected 返回受影响的行数
-	_ = result.RowsAffected()
+	// RowsAffected 返回受影响的行数和错误，CREATE TABLE 这类 DDL 语句通常为 0
+	if _, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
 
 	log.Println("用户表创建成功")
 	return nil
@@ -200,7 +208,7 @@ func (m *UserModel) InsertUsers(users []User) error {
 func (m *UserModel) GetUserByID(id int64) (*User, error) {
 	// 1. 查询单行数据
 	// QueryRow 查询一行数据，返回 *sql.Row
-	query := "SELECT id, username, email, password, created_at, updated_at FROM users WHERE id = ?"
+	query := "SELECT id, username, email, password, created_at, updated_at, deleted_at FROM users WHERE id = ? AND deleted_at IS NULL"
 	row := m.db.QueryRow(query, id)
 
 	// 2. 扫描数据到结构体
@@ -208,7 +216,7 @@ func (m *UserModel) GetUserByID(id int64) (*User, error) {
 	// 注意：参数数量和类型必须匹配
 	user := &User{}
 	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.CreatedAt, &user.UpdatedAt)
+		&user.CreatedAt, &user.UpdatedAt, &user.DeletedAt)
 
 	// 3. 处理查询结果
 	if err == sql.ErrNoRows {
@@ -224,12 +232,12 @@ func (m *UserModel) GetUserByID(id int64) (*User, error) {
 
 // GetUserByUsername 根据用户名查询用户
 func (m *UserModel) GetUserByUsername(username string) (*User, error) {
-	query := "SELECT id, username, email, password, created_at, updated_at FROM users WHERE username = ?"
+	query := "SELECT id, username, email, password, created_at, updated_at, deleted_at FROM users WHERE username = ? AND deleted_at IS NULL"
 	row := m.db.QueryRow(query, username)
 
 	user := &User{}
 	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.CreatedAt, &user.UpdatedAt)
+		&user.CreatedAt, &user.UpdatedAt, &user.DeletedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -245,7 +253,7 @@ func (m *UserModel) GetUserByUsername(username string) (*User, error) {
 func (m *UserModel) GetAllUsers() ([]User, error) {
 	// 1. 查询多行数据
 	// Query 返回 *sql.Rows，包含所有匹配的行
-	query := "SELECT id, username, email, password, created_at, updated_at FROM users ORDER BY id"
+	query := "SELECT id, username, email, password, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL ORDER BY id"
 	rows, err := m.db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("查询失败: %w", err)
@@ -258,7 +266,7 @@ func (m *UserModel) GetAllUsers() ([]User, error) {
 		user := User{}
 		// 3. 扫描每一行
 		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password,
-			&user.CreatedAt, &user.UpdatedAt)
+			&user.CreatedAt, &user.UpdatedAt, &user.DeletedAt)
 		if err != nil {
 			return nil, fmt.Errorf("扫描行失败: %w", err)
 		}
@@ -277,11 +285,11 @@ func (m *UserModel) GetAllUsers() ([]User, error) {
 // GetUsersByEmailPrefix 按邮箱前缀查询用户
 func (m *UserModel) GetUsersByEmailPrefix(prefix string) ([]User, error) {
 	// 使用 LIKE 进行模糊查询
-	// % 匹配任意字符序列
-	query := "SELECT id, username, email, password, created_at, updated_at FROM users WHERE email LIKE ?"
+	// % 匹配任意字符序列；prefix 中的 % 和 _ 经过 escapeLike 转义后按字面匹配
+	query := `SELECT id, username, email, password, created_at, updated_at, deleted_at FROM users WHERE email LIKE ? ESCAPE '!' AND deleted_at IS NULL`
 
 	// 执行查询
-	rows, err := m.db.Query(query, prefix+"%")
+	rows, err := m.db.Query(query, escapeLike(prefix)+"%")
 	if err != nil {
 		return nil, err
 	}
@@ -291,7 +299,7 @@ func (m *UserModel) GetUsersByEmailPrefix(prefix string) ([]User, error) {
 	for rows.Next() {
 		user := User{}
 		if err := rows.Scan(&user.ID, &user.Username, &user.Email,
-			&user.Password, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			&user.Password, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -300,9 +308,22 @@ func (m *UserModel) GetUsersByEmailPrefix(prefix string) ([]User, error) {
 	return users, rows.Err()
 }
 
+// escapeLike 转义 LIKE 模式中的通配符，使 s 按字面匹配
+// % 和 _ 是 LIKE 的通配符，! 是转义字符本身，都需要加上 ! 前缀：
+//
+//	escapeLike("50%_off!") == "50!%!_off!!"
+//
+// 查询中需要配合 ESCAPE '!'。不使用反斜杠作转义字符：MySQL 的字符串字面量会处理反斜杠，
+// 必须写成 ESCAPE '\\'，而 SQLite、PostgreSQL 只接受 ESCAPE '\'，同一条 SQL 无法通用
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
 // CountUsers 统计用户数量
 func (m *UserModel) CountUsers() (int64, error) {
-	query := "SELECT COUNT(*) FROM users"
+	query := "SELECT COUNT(*) FROM users WHERE deleted_at IS NULL"
 	var count int64
 	err := m.db.QueryRow(query).Scan(&count)
 	return count, err
@@ -315,7 +336,7 @@ func (m *UserModel) UpdateUser(user *User) error {
 	query := `
 		UPDATE users 
 		SET username = ?, email = ?, password = ?, updated_at = NOW()
-		WHERE id = ?
+		WHERE id = ? AND deleted_at IS NULL
 	`
 	result, err := m.db.Exec(query, user.Username, user.Email, user.Password, user.ID)
 	if err != nil {
@@ -336,17 +357,46 @@ func (m *UserModel) UpdateUser(user *User) error {
 }
 
 // UpdatePassword 更新用户密码
+// 与 UpdateUser 一样，软删除的用户视为不存在
 func (m *UserModel) UpdatePassword(id int64, newPassword string) error {
-	query := "UPDATE users SET password = ?, updated_at = NOW() WHERE id = ?"
-	_, err := m.db.Exec(query, newPassword, id)
-	return err
+	query := "UPDATE users SET password = ?, updated_at = NOW() WHERE id = ? AND deleted_at IS NULL"
+	result, err := m.db.Exec(query, newPassword, id)
+	if err != nil {
+		return fmt.Errorf("更新密码失败: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("用户不存在: ID=%d", id)
+	}
+
+	return nil
 }
 
 // ====== 删除数据 ======
-
-// DeleteUserByID 根据 ID 删除用户
+/*
+软删除：
+  删除时只设置 deleted_at = NOW()，数据仍保留在表中，
+  所有查询都带 WHERE deleted_at IS NULL，因此软删除的用户对外不可见。
+
+  - DeleteUserByID / DeleteUserByUsername：软删除
+  - RestoreUser：恢复软删除的用户
+  - HardDeleteUserByID：物理删除（包括已软删除的用户）
+
+注意：
+  - username、email 的唯一索引仍然包含软删除的行，
+    恢复前这些值不能被新用户使用
+  - 已存在的旧表需要手动添加列：
+    ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL
+*/
+
+// DeleteUserByID 根据 ID 软删除用户
 func (m *UserModel) DeleteUserByID(id int64) error {
-	query := "DELETE FROM users WHERE id = ?"
+	query := "UPDATE users SET deleted_at = NOW() WHERE id = ? AND deleted_at IS NULL"
 	result, err := m.db.Exec(query, id)
 	if err != nil {
 		return fmt.Errorf("删除失败: %w", err)
@@ -364,9 +414,9 @@ func (m *UserModel) DeleteUserByID(id int64) error {
 	return nil
 }
 
-// DeleteUserByUsername 根据用户名删除用户
+// DeleteUserByUsername 根据用户名软删除用户
 func (m *UserModel) DeleteUserByUsername(username string) error {
-	query := "DELETE FROM users WHERE username = ?"
+	query := "UPDATE users SET deleted_at = NOW() WHERE username = ? AND deleted_at IS NULL"
 	result, err := m.db.Exec(query, username)
 	if err != nil {
 		return err
@@ -384,6 +434,46 @@ func (m *UserModel) DeleteUserByUsername(username string) error {
 	return nil
 }
 
+// HardDeleteUserByID 根据 ID 物理删除用户（不可恢复）
+func (m *UserModel) HardDeleteUserByID(id int64) error {
+	query := "DELETE FROM users WHERE id = ?"
+	result, err := m.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("删除失败: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("用户不存在: ID=%d", id)
+	}
+
+	return nil
+}
+
+// RestoreUser 恢复软删除的用户
+func (m *UserModel) RestoreUser(id int64) error {
+	query := "UPDATE users SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL"
+	result, err := m.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("恢复失败: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("用户不存在或未被删除: ID=%d", id)
+	}
+
+	return nil
+}
+
 // ====== 事务操作 ======
 
 // TransferMoney 转账示例（使用事务）
@@ -404,7 +494,8 @@ func (m *UserModel) TransferMoney(fromID, toID int64, amount float64) error {
 
 	// 2. 扣除转出账户余额
 	// FOR UPDATE 锁定行，防止并发修改
-	query1 := "UPDATE users SET balance = balance - ? WHERE id = ? AND balance >= ?"
+	// 软删除的账户不能转出也不能转入
+	query1 := "UPDATE users SET balance = balance - ? WHERE id = ? AND balance >= ? AND deleted_at IS NULL"
 	result1, err := tx.Exec(query1, amount, fromID, amount)
 	if err != nil {
 		return fmt.Errorf("扣除余额失败: %w", err)
@@ -420,12 +511,24 @@ func (m *UserModel) TransferMoney(fromID, toID int64, amount float64) error {
 	}
 
 	// 3. 转入账户增加余额
-	query2 := "UPDATE users SET balance = balance + ? WHERE id = ?"
-	_, err = tx.Exec(query2, amount, toID)
+	query2 := "UPDATE users SET balance = balance + ? WHERE id = ? AND deleted_at IS NULL"
+	result2, err := tx.Exec(query2, amount, toID)
 	if err != nil {
 		return fmt.Errorf("增加余额失败: %w", err)
 	}
 
+	rows2, err := result2.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// 转入账户不存在时必须回滚，否则扣掉的余额会丢失
+	// 赋值给 err 才会触发上面 defer 中的回滚
+	if rows2 == 0 {
+		err = fmt.Errorf("转入账户不存在: ID=%d", toID)
+		return err
+	}
+
 	// 4. 提交事务
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("提交事务失败: %w", err)
@@ -434,6 +537,104 @@ func (m *UserModel) TransferMoney(fromID, toID int64, amount float64) error {
 	return nil
 }
 
+// ====== 嵌套事务（保存点） ======
+/*
+多个操作各自需要事务，又要组合在一个大事务里时，
+里层再调用 Begin 会开启另一个连接上的独立事务，无法一起提交或回滚。
+
+WithTx 通过 context 记录当前事务：
+  - ctx 中没有事务：BEGIN，fn 返回 nil 时 COMMIT，否则 ROLLBACK
+  - ctx 中已有事务：SAVEPOINT sp_N，fn 返回 nil 时 RELEASE SAVEPOINT，
+    否则 ROLLBACK TO SAVEPOINT，只撤销里层的修改，外层事务继续
+
+  err := m.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+      if _, err := tx.ExecContext(ctx, "INSERT ..."); err != nil {
+          return err
+      }
+      // 里层失败只回滚到保存点，上面的 INSERT 仍会提交
+      if err := m.WithTx(ctx, optionalStep); err != nil {
+          log.Printf("可选步骤失败: %v", err)
+      }
+      return nil
+  })
+
+fn 必须使用传入的 ctx 和 tx，使用外部的 ctx 会让里层调用开启新的事务。
+
+数据库支持：
+  - MySQL（InnoDB）：支持 SAVEPOINT / ROLLBACK TO SAVEPOINT / RELEASE SAVEPOINT，
+    注意 DDL 语句会隐式提交事务，保存点随之失效
+  - PostgreSQL：支持；事务中的语句出错后必须回滚到保存点才能继续执行
+  - SQLite：支持，语法相同
+  - SQL Server 使用 SAVE TRANSACTION，不适用
+
+sql.Tx 不是并发安全的，同一事务内不要在多个 Goroutine 中调用 WithTx。
+*/
+
+// txContextKey context 中保存当前事务的键
+type txContextKey struct{}
+
+// txState 当前事务及已创建的保存点数量
+type txState struct {
+	tx         *sql.Tx
+	savepoints int
+}
+
+// WithTx 在事务中执行 fn；ctx 中已有事务时使用保存点实现嵌套
+func (m *UserModel) WithTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) (err error) {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return state.withSavepoint(ctx, fn)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+
+	// fn panic 时回滚后继续向上传播
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	txCtx := context.WithValue(ctx, txContextKey{}, &txState{tx: tx})
+	if err := fn(txCtx, tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("回滚事务失败: %w", rbErr))
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// withSavepoint 在保存点中执行 fn，失败时只回滚到保存点
+func (s *txState) withSavepoint(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	// 保存点名称在事务内递增，同名保存点会覆盖之前的
+	s.savepoints++
+	name := fmt.Sprintf("sp_%d", s.savepoints)
+
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("创建保存点失败: %w", err)
+	}
+
+	if err := fn(ctx, s.tx); err != nil {
+		if _, rbErr := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("回滚到保存点失败: %w", rbErr))
+		}
+		return err
+	}
+
+	if _, err := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("释放保存点失败: %w", err)
+	}
+	return nil
+}
+
 // ====== 错误处理 ======
 
 // HandleSQLError 处理 SQL 错误
@@ -0,0 +1,356 @@
+// database/database_sql_test.go
+// 原生 SQL 示例的测试
+//
+// database 目录下每个文件都是独立的示例程序（各有一个 main），需要按文件运行：
+//   go test database/database_sql.go database/database_sql_test.go
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ====== 测试辅助 ======
+/*
+示例的 SQL 按 MySQL 编写，测试使用 SQLite：
+  - 建表语句单独写一份 SQLite 版本（CreateTable 中的 AUTO_INCREMENT、ENGINE 等 SQLite 不支持）
+  - 注册一个带 NOW() 函数的驱动，其余 DML 语句两边相同
+*/
+
+// sqliteMySQLDriver 带 MySQL 兼容函数的 SQLite 驱动名
+const sqliteMySQLDriver = "sqlite3_mysql_compat"
+
+var registerDriverOnce sync.Once
+
+// sqliteUsersTable users 表的 SQLite 版本，列与 CreateTable 相同
+const sqliteUsersTable = `
+	CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username VARCHAR(50) NOT NULL UNIQUE,
+		email VARCHAR(100) NOT NULL UNIQUE,
+		password VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		deleted_at TIMESTAMP NULL DEFAULT NULL
+	)
+`
+
+// newTestUserModel 临时 SQLite 文件上的 UserModel，已创建 users 表
+func newTestUserModel(t *testing.T) *UserModel {
+	t.Helper()
+	registerDriverOnce.Do(func() {
+		sql.Register(sqliteMySQLDriver, &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				return conn.RegisterFunc("NOW", func() string {
+					return time.Now().UTC().Format("2006-01-02 15:04:05")
+				}, false)
+			},
+		})
+	})
+
+	db, err := sql.Open(sqliteMySQLDriver, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(sqliteUsersTable); err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	return &UserModel{db: db}
+}
+
+// insertTestUsers 按用户名插入用户，邮箱为 "<username>@example.com"，返回 ID
+func insertTestUsers(t *testing.T, m *UserModel, usernames ...string) []int64 {
+	t.Helper()
+	ids := make([]int64, len(usernames))
+	for i, name := range usernames {
+		id, err := m.InsertUser(&User{Username: name, Email: name + "@example.com", Password: "secret"})
+		if err != nil {
+			t.Fatalf("插入用户 %s 失败: %v", name, err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// countAllRows 统计表中的行数，包括软删除的用户
+func countAllRows(t *testing.T, m *UserModel) int {
+	t.Helper()
+	var n int
+	if err := m.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		t.Fatalf("统计行数失败: %v", err)
+	}
+	return n
+}
+
+// ====== 查询数据 ======
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"alice", "alice"},
+		{"50%_off!", "50!%!_off!!"},
+		{`a\b`, `a\b`}, // 反斜杠不是转义字符，原样保留
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := escapeLike(tt.in); got != tt.want {
+			t.Errorf("escapeLike(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGetUsersByEmailPrefix(t *testing.T) {
+	m := newTestUserModel(t)
+	for _, email := range []string{"50%off@x.com", "50xoff@x.com", "a_b@x.com", "axb@x.com", `c\d@x.com`, "c!d@x.com"} {
+		if _, err := m.InsertUser(&User{Username: email, Email: email, Password: "secret"}); err != nil {
+			t.Fatalf("插入用户 %s 失败: %v", email, err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"50%", []string{"50%off@x.com"}},
+		{"a_", []string{"a_b@x.com"}},
+		{`c\`, []string{`c\d@x.com`}},
+		{"c!", []string{"c!d@x.com"}},
+		{"nobody", nil},
+	}
+	for _, tt := range tests {
+		users, err := m.GetUsersByEmailPrefix(tt.prefix)
+		if err != nil {
+			t.Fatalf("GetUsersByEmailPrefix(%q) error = %v", tt.prefix, err)
+		}
+		var got []string
+		for _, u := range users {
+			got = append(got, u.Email)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("GetUsersByEmailPrefix(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+// ====== 删除数据 ======
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	m := newTestUserModel(t)
+	ids := insertTestUsers(t, m, "alice", "bob")
+	alice, bob := ids[0], ids[1]
+
+	// 软删除后所有查询都看不到该用户，但行仍在表中
+	if err := m.DeleteUserByID(alice); err != nil {
+		t.Fatalf("DeleteUserByID() error = %v", err)
+	}
+	if u, err := m.GetUserByID(alice); u != nil || err != nil {
+		t.Errorf("软删除后 GetUserByID() = %+v, %v, want nil, nil", u, err)
+	}
+	if u, _ := m.GetUserByUsername("alice"); u != nil {
+		t.Errorf("软删除后 GetUserByUsername() = %+v, want nil", u)
+	}
+	if n, _ := m.CountUsers(); n != 1 {
+		t.Errorf("CountUsers() = %d, want 1", n)
+	}
+	if all, _ := m.GetAllUsers(); len(all) != 1 || all[0].ID != bob {
+		t.Errorf("GetAllUsers() = %v, want 只有 bob", all)
+	}
+	if n := countAllRows(t, m); n != 2 {
+		t.Errorf("表中行数 = %d, want 2", n)
+	}
+
+	// 已经删除的用户不能再删除或更新
+	if err := m.DeleteUserByID(alice); err == nil {
+		t.Error("重复 DeleteUserByID() 应该返回错误")
+	}
+	if err := m.UpdateUser(&User{ID: alice, Username: "alice2", Email: "a2@example.com"}); err == nil {
+		t.Error("UpdateUser(已删除的用户) 应该返回错误")
+	}
+	if err := m.UpdatePassword(alice, "new-secret"); err == nil || !strings.Contains(err.Error(), "用户不存在") {
+		t.Errorf("UpdatePassword(已删除的用户) error = %v, want 用户不存在", err)
+	}
+	var password string
+	if err := m.db.QueryRow("SELECT password FROM users WHERE id = ?", alice).Scan(&password); err != nil || password != "secret" {
+		t.Errorf("已删除用户的密码 = %q, %v, want 不变", password, err)
+	}
+
+	// 恢复后重新可见，deleted_at 被清空
+	if err := m.RestoreUser(alice); err != nil {
+		t.Fatalf("RestoreUser() error = %v", err)
+	}
+	u, err := m.GetUserByID(alice)
+	if err != nil || u == nil || u.Username != "alice" || u.DeletedAt.Valid {
+		t.Errorf("恢复后 GetUserByID() = %+v, %v, want alice 且 deleted_at 为 NULL", u, err)
+	}
+	if err := m.RestoreUser(alice); err == nil {
+		t.Error("RestoreUser(未删除的用户) 应该返回错误")
+	}
+	if err := m.UpdatePassword(alice, "new-secret"); err != nil {
+		t.Errorf("恢复后 UpdatePassword() error = %v", err)
+	}
+
+	// 按用户名软删除
+	if err := m.DeleteUserByUsername("bob"); err != nil {
+		t.Fatalf("DeleteUserByUsername() error = %v", err)
+	}
+	if err := m.DeleteUserByUsername("bob"); err == nil {
+		t.Error("重复 DeleteUserByUsername() 应该返回错误")
+	}
+}
+
+func TestHardDelete(t *testing.T) {
+	m := newTestUserModel(t)
+	ids := insertTestUsers(t, m, "alice", "bob")
+
+	// 物理删除对已软删除的用户同样有效，删除后不能恢复
+	if err := m.DeleteUserByID(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.HardDeleteUserByID(ids[0]); err != nil {
+		t.Fatalf("HardDeleteUserByID(已软删除) error = %v", err)
+	}
+	if err := m.RestoreUser(ids[0]); err == nil {
+		t.Error("物理删除后 RestoreUser() 应该返回错误")
+	}
+	if err := m.HardDeleteUserByID(ids[1]); err != nil {
+		t.Fatalf("HardDeleteUserByID() error = %v", err)
+	}
+	if n := countAllRows(t, m); n != 0 {
+		t.Errorf("表中行数 = %d, want 0", n)
+	}
+	if err := m.HardDeleteUserByID(999); err == nil {
+		t.Error("HardDeleteUserByID(不存在) 应该返回错误")
+	}
+
+	// 物理删除后用户名可以重新使用
+	insertTestUsers(t, m, "alice")
+}
+
+// ====== 嵌套事务（保存点） ======
+
+// insertInTx 在事务中插入用户
+func insertInTx(ctx context.Context, tx *sql.Tx, username string) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO users (username, email, password) VALUES (?, ?, ?)",
+		username, username+"@example.com", "secret")
+	return err
+}
+
+// storedUsernames 按 ID 顺序返回表中的用户名
+func storedUsernames(t *testing.T, m *UserModel) []string {
+	t.Helper()
+	rows, err := m.db.Query("SELECT username FROM users ORDER BY id")
+	if err != nil {
+		t.Fatalf("查询用户名失败: %v", err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestWithTx(t *testing.T) {
+	errStep := errors.New("step failed")
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		fn      func(m *UserModel, ctx context.Context, tx *sql.Tx) error
+		wantErr error
+		want    []string
+	}{
+		{
+			"里层失败只回滚到保存点",
+			func(m *UserModel, ctx context.Context, tx *sql.Tx) error {
+				insertInTx(ctx, tx, "alice")
+				err := m.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+					insertInTx(ctx, tx, "bob")
+					return errStep
+				})
+				if !errors.Is(err, errStep) {
+					t.Errorf("里层 WithTx() error = %v, want errStep", err)
+				}
+				return insertInTx(ctx, tx, "carol")
+			},
+			nil,
+			[]string{"alice", "carol"},
+		},
+		{
+			"外层失败时里层的修改一起回滚",
+			func(m *UserModel, ctx context.Context, tx *sql.Tx) error {
+				insertInTx(ctx, tx, "alice")
+				if err := m.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+					return insertInTx(ctx, tx, "bob")
+				}); err != nil {
+					t.Errorf("里层 WithTx() error = %v", err)
+				}
+				return errStep
+			},
+			errStep,
+			nil,
+		},
+		{
+			"多层嵌套只回滚最里层",
+			func(m *UserModel, ctx context.Context, tx *sql.Tx) error {
+				return m.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+					insertInTx(ctx, tx, "alice")
+					m.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+						insertInTx(ctx, tx, "bob")
+						return errStep
+					})
+					return m.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+						return insertInTx(ctx, tx, "carol")
+					})
+				})
+			},
+			nil,
+			[]string{"alice", "carol"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestUserModel(t)
+			err := m.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error { return tt.fn(m, ctx, tx) })
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WithTx() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := storedUsernames(t, m); !slices.Equal(got, tt.want) {
+				t.Errorf("提交后的用户 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("panic 时回滚并继续传播", func(t *testing.T) {
+		m := newTestUserModel(t)
+		func() {
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Errorf("recover() = %v, want boom", r)
+				}
+			}()
+			m.WithTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+				insertInTx(ctx, tx, "alice")
+				panic("boom")
+			})
+		}()
+		if got := storedUsernames(t, m); len(got) != 0 {
+			t.Errorf("panic 后的用户 = %v, want 空", got)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+// decompress/decompress_gzip.go
+// gzip 请求体解压中间件 - 详细注释版
+
+package decompress
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+)
+
+// ====== 请求体解压基础 ======
+/*
+移动端和批量上报的客户端常用 gzip 压缩请求体：
+
+  POST /api/v1/events
+  Content-Encoding: gzip
+  Content-Type: application/json
+
+  <gzip 数据>
+
+处理器直接按 JSON 解码会失败。中间件在处理器之前把 r.Body 换成解压后的流，
+并删除 Content-Encoding 和 Content-Length，绑定代码不需要任何改动：
+
+  handler := decompress.HTTPMiddleware(decompress.DefaultMaxSize)(router)
+  router.Use(decompress.GinMiddleware(decompress.DefaultMaxSize))
+  e.Use(decompress.EchoMiddleware(decompress.DefaultMaxSize))
+
+解压炸弹：几 KB 的 gzip 可以解压出几 GB 的数据，压缩前的 BodyLimit 限制不住。
+解压后的数据超过 maxSize 时，读取返回 *http.MaxBytesError，
+bind 包和 Echo 示例的 bindJSON 会把它转换为 413，与普通的请求体超限一致。
+
+错误处理：
+  - gzip 头无效（不是 gzip 数据）在进入处理器之前返回 400
+  - 数据中途损坏（校验和错误）只有读到时才知道，由处理器的读取错误处理
+  - 其他编码（br、deflate、多层编码）返回 415，Content-Encoding: identity 视为未压缩
+*/
+
+// DefaultMaxSize 解压后请求体的默认上限
+const DefaultMaxSize = 10 << 20 // 10MB
+
+// requestError 进入处理器之前发现的错误
+type requestError struct {
+	status  int
+	message string
+}
+
+// wrapBody 按 Content-Encoding 替换请求体，未压缩时什么也不做
+func wrapBody(r *http.Request, maxSize int64) *requestError {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+	default:
+		return &requestError{http.StatusUnsupportedMediaType, "Unsupported Content-Encoding: " + encoding}
+	}
+
+	// NewReader 会立即读取 gzip 头，不是 gzip 数据时这里就会失败
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return &requestError{http.StatusBadRequest, "Malformed gzip request body"}
+	}
+
+	r.Body = &gzipBody{zr: zr, src: r.Body, limit: maxSize}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1 // 解压后的长度未知
+	return nil
+}
+
+// gzipBody 解压后的请求体，读取超过 limit 字节时返回 *http.MaxBytesError
+type gzipBody struct {
+	zr    *gzip.Reader
+	src   io.ReadCloser // 原始请求体
+	limit int64
+	n     int64 // 已读取的解压后字节数
+	err   error // 超限后固定返回的错误
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if b.n >= b.limit {
+		// 已经读满 limit，再探测一个字节判断是正好结束还是超限
+		var probe [1]byte
+		for {
+			k, err := b.zr.Read(probe[:])
+			if k > 0 {
+				b.err = &http.MaxBytesError{Limit: b.limit}
+				return 0, b.err
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if remaining := b.limit - b.n; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.zr.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *gzipBody) Close() error {
+	return errors.Join(b.zr.Close(), b.src.Close())
+}
+
+// ====== 框架中间件 ======
+
+// HTTPMiddleware net/http 的解压中间件，maxSize 为解压后的上限
+func HTTPMiddleware(maxSize int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rerr := wrapBody(r, maxSize); rerr != nil {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(rerr.status)
+				json.NewEncoder(w).Encode(map[string]string{"error": rerr.message})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GinMiddleware Gin 的解压中间件
+func GinMiddleware(maxSize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rerr := wrapBody(c.Request, maxSize); rerr != nil {
+			c.AbortWithStatusJSON(rerr.status, gin.H{"error": rerr.message})
+			return
+		}
+		c.Next()
+	}
+}
+
+// EchoMiddleware Echo 的解压中间件，错误交给 HTTPErrorHandler 渲染
+func EchoMiddleware(maxSize int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if rerr := wrapBody(c.Request(), maxSize); rerr != nil {
+				return echo.NewHTTPError(rerr.status, rerr.message)
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// decompress/decompress_gzip_test.go
+// gzip 请求体解压中间件的测试
+
+package decompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+
+	"github.com/austoin/GolangTutorial/bind"
+)
+
+// gzipped 压缩 data
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newRequest 带 Content-Encoding 和 Content-Length 的 POST 请求
+func newRequest(body []byte, encoding string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	return req
+}
+
+// readResult 处理器读到的请求体、读取错误和请求头
+type readResult struct {
+	body     string
+	err      error
+	encoding string
+	length   int64
+}
+
+// echoBody 读取整个请求体后返回 200 的处理器
+func echoBody(got *readResult) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		*got = readResult{string(b), err, r.Header.Get("Content-Encoding"), r.ContentLength}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	payload := []byte(`{"event":"click","count":3}`)
+	tests := []struct {
+		name       string
+		body       []byte
+		encoding   string
+		wantStatus int
+		wantBody   string // 处理器读到的请求体
+	}{
+		{"gzip", gzipped(t, payload), "gzip", http.StatusOK, string(payload)},
+		{"x-gzip 且大小写和空白不敏感", gzipped(t, payload), " X-GZIP ", http.StatusOK, string(payload)},
+		{"未压缩", payload, "", http.StatusOK, string(payload)},
+		{"identity 视为未压缩", payload, "identity", http.StatusOK, string(payload)},
+		{"gzip 头无效返回 400", payload, "gzip", http.StatusBadRequest, ""},
+		{"br 返回 415", payload, "br", http.StatusUnsupportedMediaType, ""},
+		{"多层编码返回 415", payload, "gzip, br", http.StatusUnsupportedMediaType, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got readResult
+			rec := httptest.NewRecorder()
+			HTTPMiddleware(DefaultMaxSize)(echoBody(&got)).ServeHTTP(rec, newRequest(tt.body, tt.encoding))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, body = %s, want %d", rec.Code, rec.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				// 进入处理器之前返回 JSON 错误
+				var resp map[string]string
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp["error"] == "" {
+					t.Errorf("错误响应 = %q, want {\"error\": ...}", rec.Body)
+				}
+				return
+			}
+			if got.err != nil || got.body != tt.wantBody {
+				t.Errorf("处理器读到 %q, %v, want %q", got.body, got.err, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareHeaders(t *testing.T) {
+	var got readResult
+	body := gzipped(t, []byte(`{"a":1}`))
+	HTTPMiddleware(DefaultMaxSize)(echoBody(&got)).ServeHTTP(httptest.NewRecorder(), newRequest(body, "gzip"))
+
+	// 解压后删除 Content-Encoding，长度未知
+	if got.encoding != "" || got.length != -1 {
+		t.Errorf("Content-Encoding = %q, ContentLength = %d, want 空和 -1", got.encoding, got.length)
+	}
+}
+
+func TestDecompressionBomb(t *testing.T) {
+	const limit = 1 << 10
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{"小于上限", limit - 1, false},
+		{"正好等于上限", limit, false},
+		{"超出 1 字节", limit + 1, true},
+		// 1MB 的 0 压缩后只有约 1KB，压缩前的 BodyLimit 限制不住
+		{"解压炸弹", 1 << 20, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got readResult
+			body := gzipped(t, make([]byte, tt.size))
+			HTTPMiddleware(limit)(echoBody(&got)).ServeHTTP(httptest.NewRecorder(), newRequest(body, "gzip"))
+
+			var tooLarge *http.MaxBytesError
+			if !tt.wantErr {
+				if got.err != nil || len(got.body) != tt.size {
+					t.Errorf("读到 %d 字节, %v, want %d 字节", len(got.body), got.err, tt.size)
+				}
+				return
+			}
+			if !errors.As(got.err, &tooLarge) || tooLarge.Limit != limit {
+				t.Fatalf("读取错误 = %v, want *http.MaxBytesError{Limit: %d}", got.err, limit)
+			}
+			if len(got.body) != limit {
+				t.Errorf("超限前读到 %d 字节, want %d", len(got.body), limit)
+			}
+		})
+	}
+}
+
+func TestCorruptedBody(t *testing.T) {
+	// gzip 头有效，但末尾的 CRC 校验和被破坏，只有读到结尾时才发现
+	body := gzipped(t, []byte(strings.Repeat("x", 100)))
+	body[len(body)-8] ^= 0xff
+
+	var got readResult
+	rec := httptest.NewRecorder()
+	HTTPMiddleware(DefaultMaxSize)(echoBody(&got)).ServeHTTP(rec, newRequest(body, "gzip"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 进入处理器", rec.Code)
+	}
+	if !errors.Is(got.err, gzip.ErrChecksum) {
+		t.Errorf("读取错误 = %v, want gzip.ErrChecksum", got.err)
+	}
+}
+
+func TestBindTooLarge(t *testing.T) {
+	// 解压后超限的 JSON 请求体由 bind 转换为 413
+	body := gzipped(t, []byte(`{"name":"`+strings.Repeat("a", 2048)+`"}`))
+	var err error
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var dest struct{ Name string }
+		err = bind.JSON(r, &dest)
+	})
+	HTTPMiddleware(1<<10)(handler).ServeHTTP(httptest.NewRecorder(), newRequest(body, "gzip"))
+
+	var be *bind.Error
+	if !errors.As(err, &be) || be.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("bind.JSON() error = %v, want 413", err)
+	}
+}
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinMiddleware(DefaultMaxSize))
+	r.POST("/events", func(c *gin.Context) {
+		var v map[string]any
+		if err := c.ShouldBindJSON(&v); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.JSON(http.StatusOK, v)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, newRequest(gzipped(t, []byte(`{"event":"click"}`)), "gzip"))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"event":"click"}` {
+		t.Errorf("gzip 请求 status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, newRequest([]byte(`{}`), "deflate"))
+	if rec.Code != http.StatusUnsupportedMediaType || !strings.Contains(rec.Body.String(), "deflate") {
+		t.Errorf("deflate 请求 status = %d, body = %s, want 415", rec.Code, rec.Body)
+	}
+}
+
+func TestEchoMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(EchoMiddleware(DefaultMaxSize))
+	e.POST("/events", func(c echo.Context) error {
+		var v map[string]any
+		if err := c.Bind(&v); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, v)
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, newRequest(gzipped(t, []byte(`{"event":"click"}`)), "gzip"))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"event":"click"}` {
+		t.Errorf("gzip 请求 status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	// 错误交给 HTTPErrorHandler 渲染
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, newRequest([]byte(`not gzip`), "gzip"))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Malformed gzip") {
+		t.Errorf("无效 gzip 请求 status = %d, body = %s, want 400", rec.Code, rec.Body)
+	}
+}
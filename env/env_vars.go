@@ -0,0 +1,192 @@
+// env/env_vars.go
+// 类型安全的环境变量读取 - 详细注释版
+
+package env
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/austoin/GolangTutorial/humanize"
+)
+
+// ====== 环境变量基础 ======
+/*
+十二要素应用（12-Factor App）建议把配置放在环境变量中，
+但 os.Getenv 只返回字符串，各处手写 strconv 既重复又容易忽略错误。
+
+本包提供带默认值和类型转换的读取函数：
+  port, err := env.GetInt("PORT", 8080)          // 未设置时返回 8080
+  debug, err := env.GetBool("DEBUG", false)       // 支持 1/0、true/false、yes/no、on/off
+  timeout, err := env.GetDuration("TIMEOUT", 5*time.Second)
+  limit, err := env.GetBytes("BODY_LIMIT", 1<<20)  // "512KiB"、"10MB"，见 humanize.ParseBytes
+  hosts := env.GetList("REDIS_HOSTS", ",")        // "a:6379, b:6379" → ["a:6379" "b:6379"]
+
+  dsn := env.MustGetString("DATABASE_DSN")        // 未设置时 panic，适合启动时检查必填项
+
+规则：
+  - 变量未设置或为空字符串：返回默认值，err 为 nil
+  - 解析失败：返回默认值和 *ParseError，调用方可以选择记录日志后继续或直接退出
+*/
+
+// ====== 错误类型 ======
+
+// ParseError 环境变量解析失败
+type ParseError struct {
+	Key   string // 变量名
+	Value string // 原始值
+	Type  string // 期望的类型，如 "int"
+	Err   error  // 底层错误
+}
+
+// Error 实现 error 接口
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("env: %s=%q 不是合法的 %s: %v", e.Key, e.Value, e.Type, e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// lookup 读取变量，未设置和空字符串都视为未设置
+func lookup(key string) (string, bool) {
+	v, ok := os.LookupEnv(key)
+	v = strings.TrimSpace(v)
+	return v, ok && v != ""
+}
+
+// ====== 读取函数 ======
+
+// GetString 读取字符串，未设置时返回 def
+func GetString(key, def string) string {
+	if v, ok := lookup(key); ok {
+		return v
+	}
+	return def
+}
+
+// GetInt 读取整数，未设置时返回 def，解析失败返回 def 和 *ParseError
+func GetInt(key string, def int) (int, error) {
+	v, ok := lookup(key)
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def, &ParseError{Key: key, Value: v, Type: "int", Err: err}
+	}
+	return n, nil
+}
+
+// GetBool 读取布尔值，未设置时返回 def，解析失败返回 def 和 *ParseError
+// 除 strconv.ParseBool 支持的格式外，还接受 yes/no、on/off
+func GetBool(key string, def bool) (bool, error) {
+	v, ok := lookup(key)
+	if !ok {
+		return def, nil
+	}
+
+	switch strings.ToLower(v) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, &ParseError{Key: key, Value: v, Type: "bool", Err: err}
+	}
+	return b, nil
+}
+
+// GetDuration 读取时间间隔（如 "500ms"、"1m30s"），未设置时返回 def
+// 解析失败返回 def 和 *ParseError
+func GetDuration(key string, def time.Duration) (time.Duration, error) {
+	v, ok := lookup(key)
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def, &ParseError{Key: key, Value: v, Type: "duration", Err: err}
+	}
+	return d, nil
+}
+
+// GetBytes 读取字节大小（如 "512KiB"、"10MB"），未设置时返回 def
+// 解析失败返回 def 和 *ParseError
+func GetBytes(key string, def int64) (int64, error) {
+	v, ok := lookup(key)
+	if !ok {
+		return def, nil
+	}
+	n, err := humanize.ParseBytes(v)
+	if err != nil {
+		return def, &ParseError{Key: key, Value: v, Type: "byte size", Err: err}
+	}
+	return n, nil
+}
+
+// GetList 按 sep 分割字符串，去掉每项首尾空白并忽略空项
+// 未设置时返回 nil
+func GetList(key, sep string) []string {
+	v, ok := lookup(key)
+	if !ok {
+		return nil
+	}
+
+	var items []string
+	for _, item := range strings.Split(v, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ====== 必填变量 ======
+
+// MustGetString 读取必填的字符串，未设置时 panic
+func MustGetString(key string) string {
+	v, ok := lookup(key)
+	if !ok {
+		panic(fmt.Sprintf("env: 缺少必填的环境变量 %s", key))
+	}
+	return v
+}
+
+// MustGetInt 读取必填的整数，未设置或解析失败时 panic
+func MustGetInt(key string) int {
+	n, err := GetInt(key, 0)
+	mustCheck(key, err)
+	return n
+}
+
+// MustGetBool 读取必填的布尔值，未设置或解析失败时 panic
+func MustGetBool(key string) bool {
+	b, err := GetBool(key, false)
+	mustCheck(key, err)
+	return b
+}
+
+// MustGetDuration 读取必填的时间间隔，未设置或解析失败时 panic
+func MustGetDuration(key string) time.Duration {
+	d, err := GetDuration(key, 0)
+	mustCheck(key, err)
+	return d
+}
+
+// mustCheck 检查变量已设置且解析成功
+func mustCheck(key string, err error) {
+	if _, ok := lookup(key); !ok {
+		panic(fmt.Sprintf("env: 缺少必填的环境变量 %s", key))
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}
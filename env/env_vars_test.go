@@ -0,0 +1,203 @@
+// env/env_vars_test.go
+// 环境变量读取的测试
+
+package env
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKey 测试使用的变量名，空字符串与未设置等价
+const testKey = "ENV_VARS_TEST"
+
+func TestGetString(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"已设置", "hello", "hello"},
+		{"去掉首尾空白", "  hello ", "hello"},
+		{"空字符串返回默认值", "", "def"},
+		{"只有空白返回默认值", "   ", "def"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(testKey, tt.value)
+			if got := GetString(testKey, "def"); got != tt.want {
+				t.Errorf("GetString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"合法整数", "9090", 9090, false},
+		{"负数", "-3", -3, false},
+		{"未设置返回默认值", "", 8080, false},
+		{"非数字返回默认值和错误", "80x", 8080, true},
+		{"小数不是整数", "1.5", 8080, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(testKey, tt.value)
+			got, err := GetInt(testKey, 8080)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("GetInt() = %d, %v, want %d (wantErr %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetBool(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"1", true, false},
+		{"true", true, false},
+		{"TRUE", true, false},
+		{"yes", true, false},
+		{"On", true, false},
+		{"0", false, false},
+		{"false", false, false},
+		{"no", false, false},
+		{"OFF", false, false},
+		{"", true, false},
+		{"maybe", true, true},
+	}
+	for _, tt := range tests {
+		t.Setenv(testKey, tt.value)
+		got, err := GetBool(testKey, true)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("GetBool(%q) = %v, %v, want %v (wantErr %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGetDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"500ms", 500 * time.Millisecond, false},
+		{"1m30s", 90 * time.Second, false},
+		{"", 5 * time.Second, false},
+		{"5", 5 * time.Second, true}, // 缺少单位
+		{"soon", 5 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Setenv(testKey, tt.value)
+		got, err := GetDuration(testKey, 5*time.Second)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("GetDuration(%q) = %v, %v, want %v (wantErr %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGetBytes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"512KiB", 512 << 10, false},
+		{"10MB", 10_000_000, false},
+		{"", 1 << 20, false},
+		{"lots", 1 << 20, true},
+	}
+	for _, tt := range tests {
+		t.Setenv(testKey, tt.value)
+		got, err := GetBytes(testKey, 1<<20)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("GetBytes(%q) = %d, %v, want %d (wantErr %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGetList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"a:6379, b:6379", []string{"a:6379", "b:6379"}},
+		{"a,,b, ,", []string{"a", "b"}},
+		{"single", []string{"single"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Setenv(testKey, tt.value)
+		if got := GetList(testKey, ","); !slices.Equal(got, tt.want) {
+			t.Errorf("GetList(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	t.Setenv(testKey, "abc")
+	_, err := GetInt(testKey, 0)
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("GetInt() error = %T, want *ParseError", err)
+	}
+	if pe.Key != testKey || pe.Value != "abc" || pe.Type != "int" {
+		t.Errorf("ParseError = %+v", pe)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("Unwrap() 应该返回 strconv 的错误, got %v", pe.Err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, testKey) || !strings.Contains(msg, `"abc"`) {
+		t.Errorf("Error() = %q, 应该包含变量名和原始值", msg)
+	}
+}
+
+func TestMustGet(t *testing.T) {
+	mustPanic := func(t *testing.T, name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s 应该 panic", name)
+			}
+		}()
+		fn()
+	}
+
+	t.Run("已设置", func(t *testing.T) {
+		t.Setenv(testKey, "42")
+		if got := MustGetString(testKey); got != "42" {
+			t.Errorf("MustGetString() = %q, want 42", got)
+		}
+		if got := MustGetInt(testKey); got != 42 {
+			t.Errorf("MustGetInt() = %d, want 42", got)
+		}
+	})
+
+	t.Run("未设置时 panic", func(t *testing.T) {
+		t.Setenv(testKey, "")
+		mustPanic(t, "MustGetString", func() { MustGetString(testKey) })
+		mustPanic(t, "MustGetInt", func() { MustGetInt(testKey) })
+		mustPanic(t, "MustGetBool", func() { MustGetBool(testKey) })
+		mustPanic(t, "MustGetDuration", func() { MustGetDuration(testKey) })
+	})
+
+	t.Run("解析失败时 panic", func(t *testing.T) {
+		t.Setenv(testKey, "abc")
+		mustPanic(t, "MustGetInt", func() { MustGetInt(testKey) })
+		mustPanic(t, "MustGetBool", func() { MustGetBool(testKey) })
+		mustPanic(t, "MustGetDuration", func() { MustGetDuration(testKey) })
+	})
+}
@@ -0,0 +1,221 @@
+// eventbus/eventbus_bus.go
+// 进程内事件总线 - 详细注释版
+
+package eventbus
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ====== 事件总线基础 ======
+/*
+聊天室、通知推送等场景都需要"一条消息发给所有订阅者"（扇出），
+各自手写 map + 锁 + 循环发送时，容易出现：
+  - 一个慢订阅者阻塞整个广播
+  - 取消订阅时重复 close channel 导致 panic
+  - 发送与 close 并发，向已关闭的 channel 写入导致 panic
+
+Bus[T] 统一处理这些问题：
+
+  bus := eventbus.New[Message](16, eventbus.DropOldest)
+
+  ch, unsubscribe := bus.Subscribe()
+  defer unsubscribe()
+  for msg := range ch {
+      handle(msg)
+  }
+
+  bus.Publish(Message{Text: "hello"}) // 发给当前所有订阅者
+
+缓冲区满时的策略（Policy）：
+  - DropNewest：丢弃这条新消息，订阅者保留旧消息
+  - DropOldest：丢弃缓冲区中最旧的一条，为新消息腾出位置（适合只关心最新状态）
+  - Block：等待订阅者读取，慢订阅者会拖慢 Publish，但不丢消息
+
+注意：
+  - 订阅之前发布的消息不会补发
+  - unsubscribe 可以重复调用，第一次调用时关闭 channel
+  - Block 策略下，订阅者取消订阅会让正在等待它的 Publish 立即返回
+*/
+
+// Policy 订阅者缓冲区满时的处理策略
+type Policy int
+
+const (
+	DropNewest Policy = iota // 丢弃新消息
+	DropOldest               // 丢弃缓冲区中最旧的消息
+	Block                    // 阻塞直到订阅者读取
+)
+
+// String 实现 fmt.Stringer
+func (p Policy) String() string {
+	switch p {
+	case DropNewest:
+		return "drop-newest"
+	case DropOldest:
+		return "drop-oldest"
+	case Block:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// ====== 总线 ======
+
+// subscriber 单个订阅者
+type subscriber[T any] struct {
+	ch   chan T
+	done chan struct{} // 取消订阅时关闭，唤醒阻塞在该订阅者上的 Publish
+	stop sync.Once     // 保证 done 只关闭一次
+}
+
+// wake 关闭 done，可重复调用
+func (s *subscriber[T]) wake() {
+	s.stop.Do(func() { close(s.done) })
+}
+
+// Bus 泛型事件总线，并发安全
+type Bus[T any] struct {
+	mu     sync.RWMutex
+	subs   map[*subscriber[T]]struct{}
+	closed bool
+
+	closing   chan struct{} // Close 时关闭，唤醒所有阻塞的 Publish
+	closeOnce sync.Once
+
+	bufferSize int
+	policy     Policy
+	dropped    atomic.Uint64
+}
+
+// New 创建事件总线
+// bufferSize 为每个订阅者的缓冲区大小（小于 0 按 0 处理），policy 为缓冲区满时的策略
+func New[T any](bufferSize int, policy Policy) *Bus[T] {
+	return &Bus[T]{
+		subs:       make(map[*subscriber[T]]struct{}),
+		closing:    make(chan struct{}),
+		bufferSize: max(bufferSize, 0),
+		policy:     policy,
+	}
+}
+
+// Subscribe 订阅事件，返回接收 channel 和取消函数
+// 取消函数可以重复调用；总线已关闭时返回已关闭的 channel
+func (b *Bus[T]) Subscribe() (<-chan T, func()) {
+	sub := &subscriber[T]{
+		ch:   make(chan T, b.bufferSize),
+		done: make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub.ch, func() { b.unsubscribe(sub) }
+}
+
+// unsubscribe 移除订阅者并关闭其 channel，重复调用时不做任何事
+func (b *Bus[T]) unsubscribe(sub *subscriber[T]) {
+	// 先唤醒阻塞在该订阅者上的 Publish，它们释放读锁后才能拿到写锁
+	sub.wake()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish 把事件发给当前所有订阅者
+// 总线关闭后调用不做任何事
+func (b *Bus[T]) Publish(event T) {
+	// 发送期间持有读锁：close(ch) 只在写锁内进行，因此不会向已关闭的 channel 写入
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+
+	for sub := range b.subs {
+		if !b.deliver(sub, event) {
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// deliver 按策略把事件写入订阅者缓冲区，返回是否写入成功
+func (b *Bus[T]) deliver(sub *subscriber[T], event T) bool {
+	select {
+	case sub.ch <- event:
+		return true
+	default:
+	}
+
+	switch b.policy {
+	case Block:
+		select {
+		case sub.ch <- event:
+			return true
+		case <-sub.done:
+			return false
+		case <-b.closing:
+			return false
+		}
+
+	case DropOldest:
+		// 丢弃最旧的一条再写入；订阅者或其他 Publish 可能同时在读写，
+		// 所以两步都是非阻塞的，仍然失败时丢弃新消息
+		select {
+		case <-sub.ch:
+			b.dropped.Add(1)
+		default:
+		}
+		select {
+		case sub.ch <- event:
+			return true
+		default:
+			return false
+		}
+
+	default: // DropNewest
+		return false
+	}
+}
+
+// Close 关闭总线，关闭所有订阅者的 channel
+// 之后的 Publish 不做任何事，Subscribe 返回已关闭的 channel
+func (b *Bus[T]) Close() {
+	// 先唤醒所有阻塞的 Publish，否则下面拿不到写锁
+	b.closeOnce.Do(func() { close(b.closing) })
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subs {
+		close(sub.ch)
+	}
+	b.subs = nil
+}
+
+// Len 当前订阅者数量
+func (b *Bus[T]) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Dropped 因缓冲区满而丢弃的事件总数
+func (b *Bus[T]) Dropped() uint64 {
+	return b.dropped.Load()
+}
@@ -0,0 +1,229 @@
+// eventbus/eventbus_bus_test.go
+// 进程内事件总线的测试
+
+package eventbus
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// drain 非阻塞地取出 ch 中已缓冲的事件
+func drain[T any](ch <-chan T) []T {
+	var out []T
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return out
+			}
+			out = append(out, v)
+		default:
+			return out
+		}
+	}
+}
+
+// isClosed 判断 ch 是否已关闭且没有剩余事件
+func isClosed[T any](ch <-chan T) bool {
+	select {
+	case _, ok := <-ch:
+		return !ok
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func TestPublishFanOut(t *testing.T) {
+	bus := New[int](4, DropNewest)
+	a, _ := bus.Subscribe()
+	b, _ := bus.Subscribe()
+
+	bus.Publish(1)
+	bus.Publish(2)
+	// 订阅之前发布的消息不会补发
+	c, _ := bus.Subscribe()
+	bus.Publish(3)
+
+	for name, tt := range map[string]struct {
+		ch   <-chan int
+		want []int
+	}{
+		"a": {a, []int{1, 2, 3}},
+		"b": {b, []int{1, 2, 3}},
+		"c": {c, []int{3}},
+	} {
+		if got := drain(tt.ch); !slices.Equal(got, tt.want) {
+			t.Errorf("订阅者 %s 收到 %v, want %v", name, got, tt.want)
+		}
+	}
+	if bus.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", bus.Len())
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	bus := New[string](1, DropNewest)
+	ch, unsubscribe := bus.Subscribe()
+	other, _ := bus.Subscribe()
+
+	unsubscribe()
+	unsubscribe() // 重复调用不会 panic
+	if !isClosed(ch) {
+		t.Error("取消订阅后 channel 应该关闭")
+	}
+	if bus.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", bus.Len())
+	}
+
+	bus.Publish("hello")
+	if got := drain(other); !slices.Equal(got, []string{"hello"}) {
+		t.Errorf("其他订阅者收到 %v, want [hello]", got)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		policy      Policy
+		want        []int
+		wantDropped uint64
+	}{
+		{DropNewest, []int{1, 2}, 2},
+		{DropOldest, []int{3, 4}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			bus := New[int](2, tt.policy)
+			ch, _ := bus.Subscribe()
+			for i := 1; i <= 4; i++ {
+				bus.Publish(i)
+			}
+			if got := drain(ch); !slices.Equal(got, tt.want) {
+				t.Errorf("收到 %v, want %v", got, tt.want)
+			}
+			if bus.Dropped() != tt.wantDropped {
+				t.Errorf("Dropped() = %d, want %d", bus.Dropped(), tt.wantDropped)
+			}
+		})
+	}
+
+	if got := Policy(99).String(); got != "unknown" {
+		t.Errorf("Policy(99).String() = %q, want unknown", got)
+	}
+}
+
+func TestBlockPolicy(t *testing.T) {
+	// publishAsync 在后台发布，返回发布完成时关闭的 channel
+	publishAsync := func(bus *Bus[int], v int) <-chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			bus.Publish(v)
+			close(done)
+		}()
+		return done
+	}
+	assertBlocked := func(t *testing.T, done <-chan struct{}) {
+		t.Helper()
+		select {
+		case <-done:
+			t.Fatal("缓冲区满时 Publish 应该阻塞")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	t.Run("等待订阅者读取，不丢消息", func(t *testing.T) {
+		bus := New[int](1, Block)
+		ch, _ := bus.Subscribe()
+		bus.Publish(1)
+
+		done := publishAsync(bus, 2)
+		assertBlocked(t, done)
+		if v := <-ch; v != 1 {
+			t.Fatalf("<-ch = %d, want 1", v)
+		}
+		<-done
+		if v := <-ch; v != 2 || bus.Dropped() != 0 {
+			t.Errorf("<-ch = %d, Dropped() = %d, want 2 和 0", v, bus.Dropped())
+		}
+	})
+
+	t.Run("取消订阅唤醒阻塞的 Publish", func(t *testing.T) {
+		bus := New[int](0, Block)
+		_, unsubscribe := bus.Subscribe()
+
+		done := publishAsync(bus, 1)
+		assertBlocked(t, done)
+		unsubscribe()
+		<-done
+		if bus.Dropped() != 1 {
+			t.Errorf("Dropped() = %d, want 1", bus.Dropped())
+		}
+	})
+
+	t.Run("Close 唤醒阻塞的 Publish", func(t *testing.T) {
+		bus := New[int](0, Block)
+		ch, _ := bus.Subscribe()
+
+		done := publishAsync(bus, 1)
+		assertBlocked(t, done)
+		bus.Close()
+		<-done
+		if !isClosed(ch) {
+			t.Error("Close 后 channel 应该关闭")
+		}
+	})
+}
+
+func TestClose(t *testing.T) {
+	bus := New[int](1, DropNewest)
+	ch, unsubscribe := bus.Subscribe()
+
+	bus.Close()
+	bus.Close() // 重复调用不会 panic
+	if !isClosed(ch) || bus.Len() != 0 {
+		t.Fatalf("Close 后 channel 关闭 = %v, Len() = %d", isClosed(ch), bus.Len())
+	}
+	unsubscribe() // Close 之后取消订阅不会重复关闭
+
+	// 关闭后 Publish 不做任何事，Subscribe 返回已关闭的 channel
+	bus.Publish(1)
+	late, unsubscribeLate := bus.Subscribe()
+	defer unsubscribeLate()
+	if !isClosed(late) {
+		t.Error("关闭后 Subscribe() 应该返回已关闭的 channel")
+	}
+}
+
+func TestConcurrentPublishAndUnsubscribe(t *testing.T) {
+	bus := New[int](1, DropOldest)
+	var wg sync.WaitGroup
+
+	// 发布与取消订阅、关闭并发进行时不能向已关闭的 channel 写入
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				bus.Publish(i)
+			}
+		}()
+	}
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ch, unsubscribe := bus.Subscribe()
+			drain(ch)
+			unsubscribe()
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		time.Sleep(time.Millisecond)
+		bus.Close()
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,114 @@
+// flags/flags_redis.go
+// 基于 Redis Hash 的功能开关 - 详细注释版
+
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ====== Redis 实现 ======
+/*
+所有开关保存在一个 Hash 中，字段为开关名，值为 Flag 的 JSON：
+
+  HSET feature_flags new-checkout '{"enabled":true,"percentage":20,"allow_users":[1,2]}'
+
+每次判断都查 Redis 会给每个请求增加一次网络往返，所以整个 Hash 在本地缓存 cacheTTL：
+修改后最多 cacheTTL 生效，同一实例上调用 Set/Delete 会立即生效。
+
+Redis 不可用时继续使用上一次读到的定义，从来没有读取成功过时所有开关视为关闭；
+读取失败后同样等 cacheTTL 再重试，避免每个请求都去连一个已经挂掉的 Redis。
+JSON 格式错误的开关被忽略（视为关闭），不影响其他开关。
+
+  store := flags.NewRedisStore(rc.Client(), "feature_flags", 5*time.Second)
+*/
+
+// RedisStore 从 Redis Hash 读取开关定义
+type RedisStore struct {
+	client   redis.Cmdable
+	key      string
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	flags    map[string]Flag // 最近一次读取成功的定义
+	loadedAt time.Time       // 最近一次读取的时间（无论成功与否）
+
+	now func() time.Time // 便于替换时钟
+}
+
+// NewRedisStore 创建 Redis 开关存储，key 为保存定义的 Hash
+// cacheTTL 为 0 时每次判断都读取 Redis
+func NewRedisStore(client redis.Cmdable, key string, cacheTTL time.Duration) *RedisStore {
+	return &RedisStore{client: client, key: key, cacheTTL: cacheTTL, now: time.Now}
+}
+
+// IsEnabled 实现 Store 接口
+func (s *RedisStore) IsEnabled(ctx context.Context, name string, userID uint) bool {
+	f, ok := s.snapshot(ctx)[name]
+	return ok && f.Evaluate(name, userID)
+}
+
+// Set 写入开关定义
+func (s *RedisStore) Set(ctx context.Context, name string, f Flag) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	// HSET key name json
+	if err := s.client.HSet(ctx, s.key, name, data).Err(); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// Delete 删除开关定义
+func (s *RedisStore) Delete(ctx context.Context, name string) error {
+	// HDEL key name
+	if err := s.client.HDel(ctx, s.key, name).Err(); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// snapshot 返回缓存的定义，过期时重新读取
+// 持有锁读取 Redis，缓存过期时并发的请求只读取一次
+func (s *RedisStore) snapshot(ctx context.Context) map[string]Flag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !s.loadedAt.IsZero() && now.Sub(s.loadedAt) < s.cacheTTL {
+		return s.flags
+	}
+	s.loadedAt = now
+
+	// HGETALL key
+	raw, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return s.flags
+	}
+
+	flags := make(map[string]Flag, len(raw))
+	for name, data := range raw {
+		var f Flag
+		if json.Unmarshal([]byte(data), &f) == nil {
+			flags[name] = f
+		}
+	}
+	s.flags = flags
+	return flags
+}
+
+// invalidate 让下一次判断重新读取
+func (s *RedisStore) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
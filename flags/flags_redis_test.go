@@ -0,0 +1,142 @@
+// flags/flags_redis_test.go
+// Redis 开关存储的测试
+
+package flags
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/austoin/GolangTutorial/testfixtures"
+)
+
+// testClock 可控的时钟
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	client, mr := testfixtures.NewTestRedis(t)
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewRedisStore(client, "feature_flags", 5*time.Second)
+	s.now = clock.Now
+
+	if s.IsEnabled(ctx, "new-checkout", 1) {
+		t.Error("未定义的开关应该关闭")
+	}
+
+	// 同一实例上的 Set 立即生效，定义以 JSON 保存
+	if err := s.Set(ctx, "new-checkout", Flag{Enabled: true, AllowUsers: []uint{1}}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if !s.IsEnabled(ctx, "new-checkout", 1) || s.IsEnabled(ctx, "new-checkout", 2) {
+		t.Error("Set 之后应该只对白名单中的用户 1 开放")
+	}
+	if got := mr.HGet("feature_flags", "new-checkout"); got != `{"enabled":true,"percentage":0,"allow_users":[1]}` {
+		t.Errorf("保存的定义 = %s", got)
+	}
+
+	// 其他实例的修改在缓存过期后生效
+	mr.HSet("feature_flags", "new-checkout", `{"enabled":false}`)
+	if !s.IsEnabled(ctx, "new-checkout", 1) {
+		t.Error("缓存过期前应该继续使用旧定义")
+	}
+	clock.Advance(5 * time.Second)
+	if s.IsEnabled(ctx, "new-checkout", 1) {
+		t.Error("缓存过期后应该读到关闭的定义")
+	}
+
+	// Delete 立即生效
+	if err := s.Set(ctx, "dark-mode", Flag{Enabled: true, Percentage: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "dark-mode"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if s.IsEnabled(ctx, "dark-mode", 1) || mr.HGet("feature_flags", "dark-mode") != "" {
+		t.Error("Delete 之后应该关闭并从 Hash 中删除")
+	}
+}
+
+func TestRedisStoreMalformed(t *testing.T) {
+	ctx := context.Background()
+	client, mr := testfixtures.NewTestRedis(t)
+	mr.HSet("feature_flags", "broken", `{"enabled":tru`)
+	mr.HSet("feature_flags", "ok", `{"enabled":true,"percentage":100}`)
+
+	// JSON 格式错误的开关被忽略，不影响其他开关
+	s := NewRedisStore(client, "feature_flags", 0)
+	if s.IsEnabled(ctx, "broken", 1) {
+		t.Error("格式错误的开关应该关闭")
+	}
+	if !s.IsEnabled(ctx, "ok", 1) {
+		t.Error("其他开关应该照常生效")
+	}
+}
+
+func TestRedisStoreUnavailable(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewRedisStore(client, "feature_flags", time.Second)
+	s.now = clock.Now
+	mr.HSet("feature_flags", "new-checkout", `{"enabled":true,"percentage":100}`)
+
+	// 限制重试时间，客户端默认会重试连接
+	isEnabled := func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		return s.IsEnabled(ctx, "new-checkout", 1)
+	}
+
+	if !isEnabled() {
+		t.Fatal("Redis 可用时应该开放")
+	}
+
+	// Redis 不可用时继续使用上一次读到的定义
+	mr.Close()
+	clock.Advance(time.Second)
+	if !isEnabled() {
+		t.Error("Redis 不可用时应该使用上一次的定义")
+	}
+
+	// 恢复后等 cacheTTL 再重试
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	mr.HSet("feature_flags", "new-checkout", `{"enabled":false}`)
+	if !isEnabled() {
+		t.Error("读取失败后 cacheTTL 内不应该重试")
+	}
+	clock.Advance(time.Second)
+	if isEnabled() {
+		t.Error("cacheTTL 之后应该重新读取到关闭的定义")
+	}
+}
+
+func TestRedisStoreNeverLoaded(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	mr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s := NewRedisStore(client, "feature_flags", time.Minute)
+	if s.IsEnabled(ctx, "new-checkout", 1) {
+		t.Error("从来没有读取成功过时所有开关应该关闭")
+	}
+}
@@ -0,0 +1,145 @@
+// flags/flags_store_test.go
+// 开关计算和内存实现的测试
+
+package flags
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name   string
+		flag   Flag
+		userID uint
+		want   bool
+	}{
+		{"总开关关闭", Flag{Enabled: false, Percentage: 100}, 1, false},
+		{"总开关关闭时白名单也关闭", Flag{Enabled: false, AllowUsers: []uint{1}}, 1, false},
+		{"全量开放", Flag{Enabled: true, Percentage: 100}, 1, true},
+		{"全量开放包括未登录用户", Flag{Enabled: true, Percentage: 100}, 0, true},
+		{"比例超过 100 视为全量", Flag{Enabled: true, Percentage: 150}, 7, true},
+		{"比例为 0", Flag{Enabled: true, Percentage: 0}, 1, false},
+		{"白名单不受比例限制", Flag{Enabled: true, Percentage: 0, AllowUsers: []uint{3, 1}}, 1, true},
+		{"不在白名单中", Flag{Enabled: true, Percentage: 0, AllowUsers: []uint{3}}, 1, false},
+		{"未登录用户无法分桶", Flag{Enabled: true, Percentage: 99}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flag.Evaluate("new-checkout", tt.userID); got != tt.want {
+				t.Errorf("Evaluate(%d) = %v, want %v", tt.userID, got, tt.want)
+			}
+		})
+	}
+}
+
+// enabledUsers 返回 1..n 中对 name 开放的用户
+func enabledUsers(f Flag, name string, n uint) map[uint]bool {
+	out := make(map[uint]bool)
+	for id := uint(1); id <= n; id++ {
+		if f.Evaluate(name, id) {
+			out[id] = true
+		}
+	}
+	return out
+}
+
+func TestEvaluateRollout(t *testing.T) {
+	const n = 10000
+	at10 := enabledUsers(Flag{Enabled: true, Percentage: 10}, "new-checkout", n)
+	at20 := enabledUsers(Flag{Enabled: true, Percentage: 20}, "new-checkout", n)
+
+	// 分桶大致均匀
+	if got := len(at20); got < n*17/100 || got > n*23/100 {
+		t.Errorf("20%% 灰度开放给 %d 个用户, want 约 %d", got, n/5)
+	}
+
+	// 同一用户的结果固定
+	again := enabledUsers(Flag{Enabled: true, Percentage: 20}, "new-checkout", n)
+	if len(again) != len(at20) {
+		t.Fatalf("两次计算结果不同: %d vs %d", len(again), len(at20))
+	}
+	for id := range at20 {
+		if !again[id] {
+			t.Fatalf("用户 %d 第二次计算结果不同", id)
+		}
+	}
+
+	// 比例调大时原来的用户仍在其中
+	for id := range at10 {
+		if !at20[id] {
+			t.Errorf("用户 %d 在 10%% 中但不在 20%% 中", id)
+		}
+	}
+
+	// 不同开关开放给的不是同一批用户
+	other := enabledUsers(Flag{Enabled: true, Percentage: 20}, "dark-mode", n)
+	same := 0
+	for id := range other {
+		if at20[id] {
+			same++
+		}
+	}
+	if same == len(at20) {
+		t.Error("不同开关开放给了完全相同的用户")
+	}
+}
+
+func TestBucket(t *testing.T) {
+	for id := uint(1); id <= 1000; id++ {
+		if b := bucket("f", id); b < 0 || b >= 100 {
+			t.Fatalf("bucket(%d) = %d, want [0, 100)", id, b)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	if s.IsEnabled(ctx, "new-checkout", 1) {
+		t.Error("未定义的开关应该关闭")
+	}
+
+	s.Set("new-checkout", Flag{Enabled: true, AllowUsers: []uint{1}})
+	if !s.IsEnabled(ctx, "new-checkout", 1) || s.IsEnabled(ctx, "new-checkout", 2) {
+		t.Error("只应该对白名单中的用户 1 开放")
+	}
+
+	// 替换定义，紧急关闭
+	s.Set("new-checkout", Flag{Enabled: false, Percentage: 100, AllowUsers: []uint{1}})
+	if s.IsEnabled(ctx, "new-checkout", 1) {
+		t.Error("总开关关闭后应该对所有人关闭")
+	}
+
+	s.Set("new-checkout", Flag{Enabled: true, Percentage: 100})
+	s.Delete("new-checkout")
+	if s.IsEnabled(ctx, "new-checkout", 1) {
+		t.Error("删除后应该关闭")
+	}
+}
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("flag-%d", i%3)
+			s.Set(name, Flag{Enabled: true, Percentage: i * 10})
+			s.Delete(name)
+		}()
+		go func() {
+			defer wg.Done()
+			for id := range uint(100) {
+				s.IsEnabled(ctx, fmt.Sprintf("flag-%d", i%3), id)
+			}
+		}()
+	}
+	wg.Wait()
+}
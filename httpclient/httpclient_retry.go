@@ -0,0 +1,252 @@
+// httpclient/httpclient_retry.go
+// 带重试的 HTTP 客户端 - 详细注释版
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ====== 重试客户端基础 ======
+/*
+直接使用 http.Client 调用外部服务时，常见的坑：
+  - 对方偶发 502/503，一次失败就向上返回错误
+  - 对方返回 429 并带 Retry-After，客户端却立即重试，越重试越被限流
+  - 忘记读完并关闭 resp.Body，连接无法复用，TIME_WAIT 堆积
+
+Client 包装 *http.Client，在以下情况自动重试：
+  - 网络错误（连接被拒绝、连接重置等）
+  - 429 Too Many Requests
+  - 5xx（501 Not Implemented 除外）
+
+4xx 是调用方的问题，重试也不会成功，直接返回。
+
+重试间隔：
+  - 响应带 Retry-After 时按它等待（秒数或 HTTP 日期）
+  - 否则指数退避：BaseDelay * 2^n，加上随机抖动，最多 MaxDelay
+  - 抖动避免大量客户端在同一时刻一起重试（惊群）
+
+总时间由 ctx 控制：
+  ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+  defer cancel()
+  err := c.DoJSON(ctx, http.MethodPost, url, req, &resp)
+  剩余时间不够下一次等待时立即返回，不会白白睡眠
+
+使用示例：
+  c := httpclient.New(httpclient.Config{MaxRetries: 3})
+
+  var user User
+  err := c.DoJSON(ctx, http.MethodGet, "https://api.example.com/users/1", nil, &user)
+
+  var se *httpclient.StatusError
+  if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+      // 用户不存在
+  }
+*/
+
+// ====== 配置 ======
+
+const (
+	defaultMaxRetries = 3
+	defaultBaseDelay  = 100 * time.Millisecond
+	defaultMaxDelay   = 5 * time.Second
+	maxErrorBody      = 4 << 10 // StatusError 中保留的响应体上限
+)
+
+// Config 客户端配置
+type Config struct {
+	HTTPClient *http.Client  // 底层客户端，默认 http.DefaultClient
+	MaxRetries int           // 最大重试次数（不含第一次），默认 3，负数表示不重试
+	BaseDelay  time.Duration // 退避基础间隔，默认 100ms
+	MaxDelay   time.Duration // 单次等待上限（包括 Retry-After），默认 5s
+}
+
+// Client 带重试的 HTTP 客户端，并发安全
+type Client struct {
+	cfg Config
+}
+
+// New 创建客户端
+func New(cfg Config) *Client {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	} else if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultBaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultMaxDelay
+	}
+	return &Client{cfg: cfg}
+}
+
+// ====== 错误类型 ======
+
+// StatusError 服务端返回了非 2xx 状态码（重试耗尽或不可重试）
+type StatusError struct {
+	StatusCode int
+	Body       []byte // 响应体前 4KB，便于排查
+}
+
+// Error 实现 error 接口
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpclient: unexpected status %d: %s", e.StatusCode, bytes.TrimSpace(e.Body))
+}
+
+// ====== 请求 ======
+
+// Do 发送请求，按配置重试
+// 请求体会被完整读入内存，以便每次重试重新发送
+// 返回的响应由调用方负责关闭 Body；中间失败的响应会被自动读完并关闭
+// 重试耗尽时返回最后一次的响应（可能是 5xx/429）或网络错误
+func (c *Client) Do(ctx context.Context, method, url string, body io.Reader, header http.Header) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, fmt.Errorf("httpclient: read request body: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		resp, err := c.cfg.HTTPClient.Do(req)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		// 上下文已取消（超时预算用完）时，网络错误不再重试
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := c.backoff(attempt)
+		if resp != nil {
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = min(d, c.cfg.MaxDelay)
+			}
+		}
+
+		// 重试次数用完，或剩余时间不够等待：返回最后一次的结果，而不是睡到超时
+		if attempt >= c.cfg.MaxRetries || !fitsDeadline(ctx, delay) {
+			return resp, err
+		}
+		if resp != nil {
+			drainAndClose(resp)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// DoJSON 发送 JSON 请求并把 2xx 响应解析到 out
+// body 为 nil 时不发送请求体；out 为 nil 时丢弃响应体
+// 非 2xx 响应返回 *StatusError
+func (c *Client) DoJSON(ctx context.Context, method, url string, body, out interface{}) error {
+	header := http.Header{"Accept": {"application/json"}}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("httpclient: encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Do(ctx, method, url, reader, header)
+	if err != nil {
+		if resp != nil {
+			drainAndClose(resp)
+		}
+		return err
+	}
+	defer drainAndClose(resp)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &StatusError{StatusCode: resp.StatusCode, Body: data}
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("httpclient: decode response: %w", err)
+	}
+	return nil
+}
+
+// ====== 辅助函数 ======
+
+// retryableStatus 状态码是否值得重试
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests ||
+		(code >= 500 && code != http.StatusNotImplemented)
+}
+
+// backoff 第 attempt 次重试前的等待时间（指数退避 + 抖动）
+// 抖动取 [d/2, d]，既打散重试时刻，又保证间隔不会太短
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.BaseDelay << min(attempt, 30)
+	if d <= 0 || d > c.cfg.MaxDelay {
+		d = c.cfg.MaxDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// fitsDeadline ctx 的剩余时间是否足够等待 delay
+func fitsDeadline(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > delay
+}
+
+// parseRetryAfter 解析 Retry-After（秒数或 HTTP 日期）
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(0, time.Until(t)), true
+	}
+	return 0, false
+}
+
+// drainAndClose 读完并关闭响应体，使底层连接可以被复用
+// 只读取有限的字节，避免被超大响应拖住
+func drainAndClose(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,190 @@
+// httpclient/httpclient_retry_test.go
+// 重试客户端的测试
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer 前 failures 次请求返回 status，之后返回 200 和请求体
+func flakyServer(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			fmt.Fprintf(w, "failure %d", n)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"attempt":%d,"echo":%q}`, n, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// newTestClient 退避间隔很短的客户端
+func newTestClient(maxRetries int) *Client {
+	return New(Config{MaxRetries: maxRetries, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+}
+
+func TestDoJSONRetriesThenSucceeds(t *testing.T) {
+	srv, calls := flakyServer(t, 2, http.StatusServiceUnavailable, nil)
+
+	var out struct {
+		Attempt int    `json:"attempt"`
+		Echo    string `json:"echo"`
+	}
+	err := newTestClient(3).DoJSON(context.Background(), http.MethodPost, srv.URL, map[string]string{"name": "alice"}, &out)
+	if err != nil {
+		t.Fatalf("DoJSON() error = %v", err)
+	}
+	if calls.Load() != 3 || out.Attempt != 3 {
+		t.Errorf("请求 %d 次, attempt = %d, want 都为 3", calls.Load(), out.Attempt)
+	}
+	// 每次重试都重新发送完整的请求体
+	if out.Echo != `{"name":"alice"}` {
+		t.Errorf("第 3 次收到的请求体 = %q", out.Echo)
+	}
+}
+
+func TestDoJSONNoRetry(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"400 不重试", http.StatusBadRequest},
+		{"404 不重试", http.StatusNotFound},
+		{"501 不重试", http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := flakyServer(t, 10, tt.status, nil)
+
+			err := newTestClient(3).DoJSON(context.Background(), http.MethodGet, srv.URL, nil, nil)
+			var se *StatusError
+			if !errors.As(err, &se) || se.StatusCode != tt.status {
+				t.Fatalf("DoJSON() error = %v, want StatusError %d", err, tt.status)
+			}
+			if string(se.Body) != "failure 1" {
+				t.Errorf("StatusError.Body = %q, want 响应体", se.Body)
+			}
+			if calls.Load() != 1 {
+				t.Errorf("请求 %d 次, want 1", calls.Load())
+			}
+		})
+	}
+}
+
+func TestDoJSONRetriesExhausted(t *testing.T) {
+	srv, calls := flakyServer(t, 10, http.StatusBadGateway, nil)
+
+	err := newTestClient(2).DoJSON(context.Background(), http.MethodGet, srv.URL, nil, nil)
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusBadGateway {
+		t.Fatalf("DoJSON() error = %v, want StatusError 502", err)
+	}
+	// 第一次 + 2 次重试，返回最后一次的响应
+	if calls.Load() != 3 || string(se.Body) != "failure 3" {
+		t.Errorf("请求 %d 次, Body = %q, want 3 次且为最后一次的响应", calls.Load(), se.Body)
+	}
+}
+
+func TestDoRetryAfter(t *testing.T) {
+	srv, calls := flakyServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+
+	t.Run("剩余时间不够等待时立即返回", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		// MaxDelay 足够大，按 Retry-After 等待 1s，超过了 ctx 的剩余时间
+		c := New(Config{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second})
+
+		start := time.Now()
+		resp, err := c.Do(ctx, http.MethodGet, srv.URL, nil, nil)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("status = %d, want 429", resp.StatusCode)
+		}
+		if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+			t.Errorf("耗时 %v，不应等待到超时", elapsed)
+		}
+	})
+
+	t.Run("Retry-After 不超过 MaxDelay", func(t *testing.T) {
+		calls.Store(0)
+		start := time.Now()
+		resp, err := newTestClient(3).Do(context.Background(), http.MethodGet, srv.URL, nil, nil)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+			t.Errorf("status = %d, 请求 %d 次, want 200 且 2 次", resp.StatusCode, calls.Load())
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("耗时 %v，Retry-After 应被 MaxDelay 截断", elapsed)
+		}
+	})
+}
+
+func TestDoNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close() // 端口已关闭，连接被拒绝
+
+	_, err := newTestClient(2).Do(context.Background(), http.MethodGet, url, nil, nil)
+	if err == nil {
+		t.Fatal("Do() 应该返回网络错误")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		in     string
+		wantOK bool
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"3", true, 3 * time.Second, 3 * time.Second},
+		{"0", true, 0, 0},
+		{future, true, 58 * time.Minute, time.Hour},
+		{"", false, 0, 0},
+		{"-1", false, 0, 0},
+		{"soon", false, 0, 0},
+	}
+	for _, tt := range tests {
+		d, ok := parseRetryAfter(tt.in)
+		if ok != tt.wantOK || d < tt.min || d > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want [%v, %v], %v", tt.in, d, ok, tt.min, tt.max, tt.wantOK)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := New(Config{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for range 20 {
+			if d := c.backoff(attempt); d < want/2 || d > want {
+				t.Fatalf("backoff(%d) = %v, want [%v, %v]", attempt, d, want/2, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// humanize/humanize_bytes.go
+// 字节大小的解析与格式化 - 详细注释版
+
+package humanize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ====== 字节大小基础 ======
+/*
+配置中的大小写成 "10MB" 比 10485760 易读，但 MB 有两种含义：
+
+  SI（十进制）     KB = 1000       MB = 1000²      GB = 1000³      TB = 1000⁴
+  IEC（二进制）    KiB = 1024      MiB = 1024²     GiB = 1024³     TiB = 1024⁴
+
+ParseBytes 按单位原意解析，"10MB" 是 10,000,000 字节，"10MiB" 是 10,485,760 字节：
+
+  humanize.ParseBytes("512")      // 512
+  humanize.ParseBytes("1.5 KiB")  // 1536
+  humanize.ParseBytes("10MB")     // 10000000
+  humanize.ParseBytes("2gib")     // 2147483648（单位不区分大小写）
+
+FormatBytes 使用二进制单位，保留一位小数，输出可以再被 ParseBytes 解析：
+
+  humanize.FormatBytes(1536)      // "1.5 KiB"
+  humanize.FormatBytes(10 << 20)  // "10 MiB"
+
+时间间隔直接使用 time.ParseDuration 解析（"500ms"、"1m30s"），
+输出见 FormatDuration。
+*/
+
+// byteUnits 单位（小写）到字节数
+var byteUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// binaryUnits FormatBytes 使用的单位，依次乘以 1024
+var binaryUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// ParseBytes 解析 "10MB"、"1.5 GiB" 这样的大小，返回字节数
+// 数字和单位之间可以有空格；没有单位时按字节；不接受负数
+func ParseBytes(s string) (int64, error) {
+	in := strings.TrimSpace(s)
+	i := strings.IndexFunc(in, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(in)
+	}
+	num, unit := in[:i], strings.ToLower(strings.TrimSpace(in[i:]))
+
+	mult, ok := byteUnits[unit]
+	if num == "" || !ok {
+		return 0, fmt.Errorf("humanize: invalid byte size %q", s)
+	}
+
+	// 整数直接按整数计算，避免 float64 在大数上丢失精度
+	if !strings.Contains(num, ".") {
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil || n > math.MaxInt64/mult {
+			return 0, fmt.Errorf("humanize: byte size %q out of range", s)
+		}
+		return n * mult, nil
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("humanize: invalid byte size %q", s)
+	}
+	v := math.Round(f * float64(mult))
+	if v >= math.MaxInt64 {
+		return 0, fmt.Errorf("humanize: byte size %q out of range", s)
+	}
+	return int64(v), nil
+}
+
+// FormatBytes 把字节数格式化为 "512 B"、"1.5 KiB"、"10 MiB"
+func FormatBytes(n int64) string {
+	sign := ""
+	u := uint64(n)
+	if n < 0 {
+		sign = "-"
+		u = -u // 对 math.MinInt64 也成立
+	}
+	if u < 1024 {
+		return sign + strconv.FormatUint(u, 10) + " B"
+	}
+
+	v := float64(u) / 1024
+	i := 0
+	// 按保留一位小数后的值判断进位，避免出现 "1024 KiB"
+	for math.Round(v*10)/10 >= 1024 && i < len(binaryUnits)-1 {
+		v /= 1024
+		i++
+	}
+	num := strings.TrimSuffix(strconv.FormatFloat(v, 'f', 1, 64), ".0")
+	return sign + num + " " + binaryUnits[i]
+}
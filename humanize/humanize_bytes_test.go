@@ -0,0 +1,106 @@
+// humanize/humanize_bytes_test.go
+// 字节大小解析与格式化的测试
+
+package humanize
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"512", 512},
+		{"0", 0},
+		{"512B", 512},
+		{"1KB", 1000},
+		{"1 KiB", 1024},
+		{"1.5 KiB", 1536},
+		{".5KiB", 512},
+		{"10MB", 10_000_000},
+		{"10MiB", 10 << 20},
+		{"2gib", 2 << 30},
+		{"  3 TB ", 3_000_000_000_000},
+		{"1.5 B", 2}, // 小数字节四舍五入
+		{"8388607TiB", 8388607 << 40},
+	}
+	for _, tt := range tests {
+		got, err := ParseBytes(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseBytes(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseBytesInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"MB", // 没有数字
+		"-5", // 负数
+		"-5MB",
+		"10 XB", // 未知单位
+		"10 MiBs",
+		"1e3", // 不支持科学计数法
+		"1.5.5KB",
+		"1 000",
+		"8388608TiB",          // 超出 int64
+		"9223372036854775808", // 超出 int64
+		"9000000000 GiB",
+		"8388608.5TiB",
+	}
+	for _, in := range tests {
+		if got, err := ParseBytes(in); err == nil {
+			t.Errorf("ParseBytes(%q) = %d, want 错误", in, got)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1023, "1023 B"},
+		{1024, "1 KiB"},
+		{1536, "1.5 KiB"},
+		{10 << 20, "10 MiB"},
+		{1<<20 - 1, "1 MiB"}, // 1023.999 KiB 保留一位小数后进位，不输出 "1024 KiB"
+		{5 << 30, "5 GiB"},
+		{3 << 40, "3 TiB"},
+		{-1536, "-1.5 KiB"},
+		{math.MaxInt64, "8 EiB"},
+		{math.MinInt64, "-8 EiB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.in); got != tt.want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatParseRoundTrip(t *testing.T) {
+	// 能精确表示的值原样还原
+	for _, n := range []int64{0, 1, 1023, 1024, 1536, 10 << 20, 5 << 30, 7 << 40} {
+		got, err := ParseBytes(FormatBytes(n))
+		if err != nil || got != n {
+			t.Errorf("ParseBytes(FormatBytes(%d)) = %d, %v", n, got, err)
+		}
+	}
+
+	// 其他值保留一位小数，误差不超过所在单位的 0.05
+	for _, n := range []int64{1000, 123456, 999_999_999, 1<<40 + 12345} {
+		s := FormatBytes(n)
+		got, err := ParseBytes(s)
+		if err != nil {
+			t.Fatalf("ParseBytes(%q) error = %v", s, err)
+		}
+		if diff := math.Abs(float64(got-n)) / float64(n); diff > 0.05 {
+			t.Errorf("ParseBytes(FormatBytes(%d)) = %d (%q), 误差 %.3f", n, got, s, diff)
+		}
+	}
+}
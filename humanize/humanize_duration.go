@@ -0,0 +1,46 @@
+// humanize/humanize_duration.go
+// 时间间隔的格式化 - 详细注释版
+
+package humanize
+
+import (
+	"strings"
+	"time"
+)
+
+// ====== 时间间隔 ======
+/*
+time.Duration 的 String() 保留全部精度并输出值为 0 的单位：
+
+  (90 * time.Minute).String()                  // "1h30m0s"
+  (1234567 * time.Microsecond).String()        // "1.234567s"
+
+日志和配置回显中更希望看到 "1h30m"、"1s" 这样的形式，秒以下的精度对长时间间隔没有意义。
+FormatDuration 按大小选择精度并去掉结尾为 0 的单位，结果仍可被 time.ParseDuration 解析：
+
+  小于 1µs    原样输出（"500ns"）
+  小于 1ms    精确到微秒（"12µs"）
+  小于 1s     精确到毫秒（"250ms"）
+  其他        精确到秒，去掉结尾的 0m、0s（"1h30m"、"2m5s"、"48h"）
+*/
+
+// FormatDuration 以易读的精度格式化时间间隔
+func FormatDuration(d time.Duration) string {
+	switch abs := d.Abs(); {
+	case abs < time.Microsecond:
+		return d.String()
+	case abs < time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	case abs < time.Second:
+		return d.Round(time.Millisecond).String()
+	}
+
+	s := d.Round(time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+// humanize/humanize_duration_test.go
+// 时间间隔格式化的测试
+
+package humanize
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{500 * time.Nanosecond, "500ns"},
+		{12345 * time.Nanosecond, "12µs"},
+		{250400 * time.Microsecond, "250ms"},
+		{999600 * time.Microsecond, "1s"},
+		{1234567 * time.Microsecond, "1s"},
+		{2*time.Minute + 5*time.Second, "2m5s"},
+		{5 * time.Minute, "5m"},
+		{90 * time.Minute, "1h30m"},
+		{time.Hour + 5*time.Second, "1h0m5s"},
+		{48 * time.Hour, "48h"},
+		{59*time.Minute + 59600*time.Millisecond, "1h"}, // 进位为 1h0m0s 后去掉结尾的 0m0s
+		{-90 * time.Second, "-1m30s"},
+		{-250 * time.Millisecond, "-250ms"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.in); got != tt.want {
+			t.Errorf("FormatDuration(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDurationParses(t *testing.T) {
+	// 输出可以被 time.ParseDuration 解析，且与原值的差不超过所选的精度
+	tests := []struct {
+		in        time.Duration
+		precision time.Duration
+	}{
+		{800 * time.Nanosecond, 0},
+		{12345 * time.Nanosecond, time.Microsecond},
+		{250400 * time.Microsecond, time.Millisecond},
+		{90*time.Minute + 1500*time.Millisecond, time.Second},
+		{-(36*time.Hour + 1), time.Second},
+	}
+	for _, tt := range tests {
+		s := FormatDuration(tt.in)
+		got, err := time.ParseDuration(s)
+		if err != nil {
+			t.Errorf("time.ParseDuration(%q) error = %v", s, err)
+			continue
+		}
+		if diff := (got - tt.in).Abs(); diff > tt.precision/2 {
+			t.Errorf("FormatDuration(%v) = %q, 解析后差 %v, want 不超过 %v", tt.in, s, diff, tt.precision/2)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// idgen/idgen_snowflake_test.go
+// 分布式 ID 生成器的测试
+
+package idgen
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTime 测试使用的固定时间
+var testTime = Epoch.Add(365 * 24 * time.Hour)
+
+// clockByReads 返回按读取次数决定时间的时钟，第 n 次读取（从 1 开始）返回 at(n)
+// 生成器等待时钟时会反复读取，用读取次数推进时间可以避免真实的等待
+func clockByReads(at func(n int) time.Time) func() time.Time {
+	var mu sync.Mutex
+	n := 0
+	return func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return at(n)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, nodeID := range []int64{0, MaxNodeID} {
+		if _, err := New(nodeID); err != nil {
+			t.Errorf("New(%d) error = %v", nodeID, err)
+		}
+	}
+	for _, nodeID := range []int64{-1, MaxNodeID + 1} {
+		if _, err := New(nodeID); err == nil {
+			t.Errorf("New(%d) 应该返回错误", nodeID)
+		}
+	}
+}
+
+func TestNextUniqueAndMonotonic(t *testing.T) {
+	gen, _ := New(7)
+
+	t.Run("单个 Goroutine 严格递增", func(t *testing.T) {
+		last := int64(0)
+		for range 10000 {
+			id := gen.Next()
+			if id <= last {
+				t.Fatalf("Next() = %d, 不大于上一个 %d", id, last)
+			}
+			last = id
+		}
+	})
+
+	t.Run("并发生成不重复", func(t *testing.T) {
+		var (
+			mu   sync.Mutex
+			seen = make(map[int64]bool)
+			wg   sync.WaitGroup
+		)
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ids := make([]int64, 2000)
+				for i := range ids {
+					ids[i] = gen.Next()
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, id := range ids {
+					if seen[id] {
+						t.Errorf("重复的 ID %d", id)
+					}
+					seen[id] = true
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestDecode(t *testing.T) {
+	gen, _ := New(42)
+	gen.now = func() time.Time { return testTime }
+
+	for want := range int64(3) {
+		ts, node, seq := Decode(gen.Next())
+		if !ts.Equal(testTime) || node != 42 || seq != want {
+			t.Errorf("Decode() = %v, %d, %d, want %v, 42, %d", ts, node, seq, testTime, want)
+		}
+	}
+}
+
+func TestSequenceOverflow(t *testing.T) {
+	gen, _ := New(1)
+	// 前 maxSequence+2 次读取停在同一毫秒，之后进入下一毫秒
+	gen.now = clockByReads(func(n int) time.Time {
+		if n <= maxSequence+2 {
+			return testTime
+		}
+		return testTime.Add(time.Millisecond)
+	})
+
+	for range maxSequence + 1 {
+		gen.Next()
+	}
+	// 本毫秒的 4096 个序列号用完，等待到下一毫秒从 0 开始
+	ts, _, seq := Decode(gen.Next())
+	if !ts.Equal(testTime.Add(time.Millisecond)) || seq != 0 {
+		t.Errorf("序列号用完后 Decode() = %v, seq %d, want 下一毫秒且 seq 0", ts, seq)
+	}
+}
+
+func TestClockBackwards(t *testing.T) {
+	t.Run("小幅回拨等待追上", func(t *testing.T) {
+		gen, _ := New(1)
+		gen.now = func() time.Time { return testTime }
+		first := gen.Next()
+
+		// 下一次读取回拨 5ms，之后恢复
+		gen.now = clockByReads(func(n int) time.Time {
+			if n == 1 {
+				return testTime.Add(-5 * time.Millisecond)
+			}
+			return testTime
+		})
+		id, err := gen.NextID()
+		if err != nil || id <= first {
+			t.Errorf("NextID() = %d, %v, want 大于 %d", id, err, first)
+		}
+	})
+
+	t.Run("大幅回拨返回错误", func(t *testing.T) {
+		gen, _ := New(1)
+		gen.now = func() time.Time { return testTime }
+		gen.Next()
+
+		gen.now = func() time.Time { return testTime.Add(-time.Second) }
+		if _, err := gen.NextID(); !errors.Is(err, ErrClockBackwards) {
+			t.Errorf("NextID() error = %v, want ErrClockBackwards", err)
+		}
+		defer func() {
+			if recover() == nil {
+				t.Error("Next() 应该 panic")
+			}
+		}()
+		gen.Next()
+	})
+}
+
+func TestNewUUID(t *testing.T) {
+	format := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	seen := make(map[string]bool)
+	last := ""
+	for range 1000 {
+		u := NewUUID()
+		if !format.MatchString(u) {
+			t.Fatalf("NewUUID() = %q, 不是 UUIDv7 格式", u)
+		}
+		if seen[u] {
+			t.Fatalf("重复的 UUID %q", u)
+		}
+		seen[u] = true
+		// 前 48 位是毫秒时间戳，按时间大致有序
+		if u[:13] < last {
+			t.Errorf("时间戳部分 %q 小于上一个 %q", u[:13], last)
+		}
+		last = u[:13]
+	}
+}
@@ -0,0 +1,147 @@
+// logger/logger_slog.go
+// 结构化日志 - 详细注释版
+
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// ====== slog 基础 ======
+/*
+log/slog 是 Go 1.21 加入标准库的结构化日志包。
+
+与 log.Printf 的区别：
+  - log.Printf("用户 %d 登录", id)        → 2024/01/01 12:00:00 用户 1 登录
+  - slog.Info("用户登录", "user_id", id)  → time=... level=INFO msg=用户登录 user_id=1
+
+结构化日志的键值对便于检索和统计，JSON 格式可以直接接入 ELK、Loki 等系统。
+
+本包统一 Web、数据库、网络示例的日志输出：
+  logger.Init(logger.Config{Format: "json", Level: slog.LevelDebug})
+  logger.Info("服务启动", "addr", ":8080")
+  logger.WithContext(ctx).Error("查询失败", "err", err)
+*/
+
+// ====== 配置 ======
+
+// Config 日志配置
+type Config struct {
+	Format string     // 输出格式："json" 或 "text"（默认）
+	Level  slog.Level // 最低输出级别，默认 Info
+	Output io.Writer  // 输出位置，默认 os.Stderr
+}
+
+// New 根据配置创建 *slog.Logger
+func New(cfg Config) *slog.Logger {
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, "json") {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
+
+	return slog.New(handler)
+}
+
+// ParseLevel 解析日志级别字符串，如 "debug"、"warn"
+// 无法识别时返回 Info
+func ParseLevel(s string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// defaultLogger 包级默认日志器
+var defaultLogger atomic.Pointer[slog.Logger]
+
+func init() {
+	defaultLogger.Store(New(Config{}))
+}
+
+// Init 使用配置替换默认日志器
+func Init(cfg Config) {
+	SetDefault(New(cfg))
+}
+
+// SetDefault 替换默认日志器
+func SetDefault(l *slog.Logger) {
+	defaultLogger.Store(l)
+}
+
+// L 返回默认日志器
+func L() *slog.Logger {
+	return defaultLogger.Load()
+}
+
+// ====== 请求 ID ======
+
+// requestIDKey 上下文中请求 ID 的键
+// 使用私有类型避免与其他包冲突
+type requestIDKey struct{}
+
+// ContextWithRequestID 把请求 ID 放入上下文
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// NewRequestID 生成随机的请求 ID（32 位十六进制字符串）
+func NewRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// RequestIDFromContext 从上下文读取请求 ID，不存在时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithContext 返回带请求 ID 的日志器
+// 之后的每一行日志都会包含 request_id 字段
+func WithContext(ctx context.Context) *slog.Logger {
+	l := L()
+	if id := RequestIDFromContext(ctx); id != "" {
+		return l.With("request_id", id)
+	}
+	return l
+}
+
+// ====== 包级快捷函数 ======
+
+// Debug 输出调试日志
+func Debug(msg string, args ...any) {
+	L().Debug(msg, args...)
+}
+
+// Info 输出信息日志
+func Info(msg string, args ...any) {
+	L().Info(msg, args...)
+}
+
+// Warn 输出警告日志
+func Warn(msg string, args ...any) {
+	L().Warn(msg, args...)
+}
+
+// Error 输出错误日志
+func Error(msg string, args ...any) {
+	L().Error(msg, args...)
+}
@@ -0,0 +1,127 @@
+// logger/logger_slog_test.go
+// 结构化日志的测试
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// captureDefault 把默认日志器换成写入 buf 的 JSON 日志器，测试结束后恢复
+func captureDefault(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	saved := L()
+	t.Cleanup(func() { SetDefault(saved) })
+
+	var buf bytes.Buffer
+	Init(Config{Format: "json", Level: level, Output: &buf})
+	return &buf
+}
+
+// decodeLines 把每行 JSON 解码为 map
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("不是 JSON: %q", line)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestJSONOutput(t *testing.T) {
+	buf := captureDefault(t, slog.LevelInfo)
+
+	Info("服务启动", "addr", ":8080", "workers", 4)
+
+	lines := decodeLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("输出 %d 行, want 1", len(lines))
+	}
+	line := lines[0]
+	for _, key := range []string{"time", "level", "msg", "addr", "workers"} {
+		if _, ok := line[key]; !ok {
+			t.Errorf("缺少字段 %q: %v", key, line)
+		}
+	}
+	if line["level"] != "INFO" || line["msg"] != "服务启动" || line["addr"] != ":8080" || line["workers"] != 4.0 {
+		t.Errorf("输出 = %v", line)
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	buf := captureDefault(t, slog.LevelWarn)
+
+	Debug("debug")
+	Info("info")
+	Warn("warn")
+	Error("error")
+
+	var levels []string
+	for _, line := range decodeLines(t, buf) {
+		levels = append(levels, line["level"].(string))
+	}
+	if strings.Join(levels, ",") != "WARN,ERROR" {
+		t.Errorf("输出的级别 = %v, want [WARN ERROR]", levels)
+	}
+}
+
+func TestTextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	New(Config{Output: &buf, Level: slog.LevelDebug}).Debug("hello", "k", "v")
+	if got := buf.String(); !strings.Contains(got, "level=DEBUG") || !strings.Contains(got, "msg=hello k=v") {
+		t.Errorf("文本输出 = %q", got)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	buf := captureDefault(t, slog.LevelInfo)
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	WithContext(ctx).Info("带请求 ID")
+	WithContext(context.Background()).Info("不带请求 ID")
+
+	lines := decodeLines(t, buf)
+	if lines[0]["request_id"] != "req-1" {
+		t.Errorf("第一行 request_id = %v, want req-1", lines[0]["request_id"])
+	}
+	if _, ok := lines[1]["request_id"]; ok {
+		t.Errorf("第二行不应包含 request_id: %v", lines[1])
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in   string
+		want slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"WARN", slog.LevelWarn},
+		{"error", slog.LevelError},
+		{"", slog.LevelInfo},
+		{"verbose", slog.LevelInfo},
+	}
+	for _, tt := range tests {
+		if got := ParseLevel(tt.in); got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	a, b := NewRequestID(), NewRequestID()
+	if len(a) != 32 || a == b {
+		t.Errorf("NewRequestID() = %q, %q, want 两个不同的 32 位十六进制字符串", a, b)
+	}
+}
@@ -0,0 +1,173 @@
+// memstore/memstore_store.go
+// 泛型内存仓储 - 详细注释版
+
+package memstore
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ====== 内存仓储基础 ======
+/*
+处理器直接依赖具体的数据库实现时，测试只能连真实数据库。
+把数据访问抽象成接口后，测试中用内存实现替换即可。
+
+MemStore 是一个通用的内存实现，每种实体不用再各写一遍 map + 锁：
+
+  type User struct {
+      ID   int
+      Name string
+      Age  int
+  }
+
+  // 键由调用方提供
+  users := memstore.New(func(u User) int { return u.ID })
+  users.Create(User{ID: 1, Name: "alice"})
+
+  // 自增键：Create 时自动分配 1, 2, 3...
+  users := memstore.NewAutoIncrement(
+      func(u User) int { return u.ID },
+      func(u *User, id int) { u.ID = id },
+  )
+  u, _ := users.Create(User{Name: "bob"}) // u.ID == 1
+
+  adults := users.Filter(func(u User) bool { return u.Age >= 18 })
+
+约定：
+  - 并发安全
+  - Get、Update、Delete 找不到记录时返回 ErrNotFound，Create 键冲突时返回 ErrExists
+  - List、Filter 按创建顺序返回
+  - T 按值保存；T 是指针类型时调用方修改返回值会影响存储中的数据，需要时自行拷贝
+
+需要跨记录约束（例如邮箱唯一）时，Filter 和 Create 之间没有原子性，
+应在外层自己加锁，或使用带唯一索引的真实存储。
+*/
+
+var (
+	// ErrNotFound 记录不存在
+	ErrNotFound = errors.New("memstore: not found")
+
+	// ErrExists 键已存在
+	ErrExists = errors.New("memstore: already exists")
+)
+
+// Integer 可以自增的键类型
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// MemStore 并发安全的内存仓储
+type MemStore[K comparable, T any] struct {
+	mu    sync.RWMutex
+	items map[K]T
+	order []K // 创建顺序
+
+	keyOf   func(T) K // 从记录中取键
+	nextKey func(*T)  // 自增模式下为记录分配键，否则为 nil
+}
+
+// New 创建仓储，keyOf 从记录中取出键
+func New[K comparable, T any](keyOf func(T) K) *MemStore[K, T] {
+	return &MemStore[K, T]{items: make(map[K]T), keyOf: keyOf}
+}
+
+// NewAutoIncrement 创建自增键的仓储
+// Create 时忽略记录原有的键，用 setKey 写入从 1 开始递增的新键
+func NewAutoIncrement[K Integer, T any](keyOf func(T) K, setKey func(*T, K)) *MemStore[K, T] {
+	s := New(keyOf)
+	var seq K
+	s.nextKey = func(item *T) {
+		seq++
+		setKey(item, seq)
+	}
+	return s
+}
+
+// ====== CRUD ======
+
+// Create 保存新记录，返回保存的记录（自增模式下带有分配的键）
+func (s *MemStore[K, T]) Create(item T) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nextKey != nil {
+		s.nextKey(&item)
+	}
+	key := s.keyOf(item)
+	if _, ok := s.items[key]; ok {
+		var zero T
+		return zero, ErrExists
+	}
+
+	s.items[key] = item
+	s.order = append(s.order, key)
+	return item, nil
+}
+
+// Get 按键获取记录
+func (s *MemStore[K, T]) Get(key K) (T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return item, ErrNotFound
+	}
+	return item, nil
+}
+
+// List 按创建顺序返回所有记录
+func (s *MemStore[K, T]) List() []T {
+	return s.Filter(func(T) bool { return true })
+}
+
+// Filter 按创建顺序返回满足 pred 的记录
+// pred 在持有读锁时调用，不能再调用 s 的写方法
+func (s *MemStore[K, T]) Filter(pred func(T) bool) []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]T, 0)
+	for _, key := range s.order {
+		if item := s.items[key]; pred(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Update 替换键相同的已有记录
+func (s *MemStore[K, T]) Update(item T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.keyOf(item)
+	if _, ok := s.items[key]; !ok {
+		return ErrNotFound
+	}
+	s.items[key] = item
+	return nil
+}
+
+// Delete 删除记录
+func (s *MemStore[K, T]) Delete(key K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.items, key)
+	s.order = slices.DeleteFunc(s.order, func(k K) bool { return k == key })
+	return nil
+}
+
+// Len 返回记录数
+func (s *MemStore[K, T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.items)
+}
@@ -0,0 +1,177 @@
+// memstore/memstore_store_test.go
+// 泛型内存仓储的测试
+
+package memstore
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+type user struct {
+	ID   int
+	Name string
+	Age  int
+}
+
+func userID(u user) int { return u.ID }
+
+// names 按顺序取出用户名，便于比较
+func names(users []user) []string {
+	out := make([]string, len(users))
+	for i, u := range users {
+		out[i] = u.Name
+	}
+	return out
+}
+
+func TestCRUD(t *testing.T) {
+	s := New(userID)
+
+	for _, u := range []user{{3, "carol", 30}, {1, "alice", 17}, {2, "bob", 25}} {
+		if _, err := s.Create(u); err != nil {
+			t.Fatalf("Create(%v) error = %v", u, err)
+		}
+	}
+	if _, err := s.Create(user{ID: 1, Name: "dup"}); !errors.Is(err, ErrExists) {
+		t.Errorf("重复键 Create() error = %v, want ErrExists", err)
+	}
+
+	if u, err := s.Get(1); err != nil || u.Name != "alice" {
+		t.Errorf("Get(1) = %v, %v, want alice", u, err)
+	}
+
+	// List 和 Filter 按创建顺序返回
+	if got := names(s.List()); !slices.Equal(got, []string{"carol", "alice", "bob"}) {
+		t.Errorf("List() = %v, want 创建顺序", got)
+	}
+	adults := s.Filter(func(u user) bool { return u.Age >= 18 })
+	if got := names(adults); !slices.Equal(got, []string{"carol", "bob"}) {
+		t.Errorf("Filter() = %v, want [carol bob]", got)
+	}
+
+	if err := s.Update(user{ID: 1, Name: "alice", Age: 18}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if u, _ := s.Get(1); u.Age != 18 {
+		t.Errorf("Update 后 Age = %d, want 18", u.Age)
+	}
+
+	if err := s.Delete(3); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := names(s.List()); !slices.Equal(got, []string{"alice", "bob"}) || s.Len() != 2 {
+		t.Errorf("Delete 后 List() = %v, Len() = %d", got, s.Len())
+	}
+
+	// 删除后可以用同一个键重新创建，排在最后
+	s.Create(user{ID: 3, Name: "carol2"})
+	if got := names(s.List()); !slices.Equal(got, []string{"alice", "bob", "carol2"}) {
+		t.Errorf("重新创建后 List() = %v", got)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	s := New(userID)
+	s.Create(user{ID: 1, Name: "alice"})
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"Get", func() error { _, err := s.Get(2); return err }},
+		{"Update", func() error { return s.Update(user{ID: 2}) }},
+		{"Delete", func() error { return s.Delete(2) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s() error = %v, want ErrNotFound", tt.name, err)
+			}
+		})
+	}
+	if s.Len() != 1 {
+		t.Errorf("失败的操作不应修改数据, Len() = %d", s.Len())
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	s := New(userID)
+	// 没有记录时返回空切片而不是 nil，序列化为 [] 而不是 null
+	if got := s.List(); got == nil || len(got) != 0 {
+		t.Errorf("List() = %#v, want 空切片", got)
+	}
+}
+
+func TestAutoIncrement(t *testing.T) {
+	s := NewAutoIncrement(userID, func(u *user, id int) { u.ID = id })
+
+	for i, name := range []string{"alice", "bob", "carol"} {
+		// 调用方传入的键被忽略
+		u, err := s.Create(user{ID: 100, Name: name})
+		if err != nil || u.ID != i+1 {
+			t.Errorf("Create(%s) = %v, %v, want ID %d", name, u, err, i+1)
+		}
+	}
+	// 删除后不复用键
+	s.Delete(3)
+	if u, _ := s.Create(user{Name: "dave"}); u.ID != 4 {
+		t.Errorf("删除后 Create() ID = %d, want 4", u.ID)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	s := NewAutoIncrement(userID, func(u *user, id int) { u.ID = id })
+
+	const n = 100
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := s.Create(user{Name: "u"})
+			if err != nil {
+				t.Errorf("Create() error = %v", err)
+				return
+			}
+			u.Age = 20
+			s.Update(u)
+			s.Get(u.ID)
+			s.Filter(func(u user) bool { return u.Age > 0 })
+			s.Len()
+		}()
+	}
+	wg.Wait()
+
+	// 并发创建分配的键互不重复
+	ids := make([]int, 0, n)
+	for _, u := range s.List() {
+		ids = append(ids, u.ID)
+	}
+	slices.Sort(ids)
+	if len(slices.Compact(ids)) != n {
+		t.Errorf("键有重复或丢失: %d 个记录", len(ids))
+	}
+
+	// 并发删除，每个键只能删除一次
+	var deleted sync.Map
+	for i := 1; i <= n; i++ {
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := s.Delete(i); err == nil {
+					if _, loaded := deleted.LoadOrStore(i, true); loaded {
+						t.Errorf("键 %d 被删除了两次", i)
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	if s.Len() != 0 || len(s.List()) != 0 {
+		t.Errorf("全部删除后 Len() = %d", s.Len())
+	}
+}
@@ -5,16 +5,25 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 	"google.golang.org/grpc/status"
 
-	pb "GolangTutorial/microservices/proto"
+	"github.com/austoin/GolangTutorial/logger"
+	pb "github.com/austoin/GolangTutorial/microservices/proto"
 )
 
 // ====== gRPC 客户端基础 ======
@@ -23,8 +32,19 @@ import (
 type UserClient struct {
 	client pb.UserServiceClient // 生成的客户端接口
 	conn   *grpc.ClientConn     // 连接实例
+
+	resolver *manual.Resolver // 负载均衡模式下的地址解析器，单地址模式为 nil
+
+	timeout       time.Duration // 一元调用超时
+	streamTimeout time.Duration // 流式调用超时
 }
 
+// 默认超时时间
+const (
+	defaultCallTimeout   = 10 * time.Second
+	defaultStreamTimeout = 60 * time.Second
+)
+
 // NewUserClient 创建新的客户端
 func NewUserClient(address string) (*UserClient, error) {
 	// 1. 创建连接
@@ -33,6 +53,8 @@ func NewUserClient(address string) (*UserClient, error) {
 	// insecure.NewCredentials() 表示不使用 TLS（仅用于开发）
 	conn, err := grpc.Dial(address,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(RequestIDUnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(RequestIDStreamClientInterceptor),
 		grpc.WithBlock(), // 阻塞直到连接成功或超时
 		grpc.WithTimeout(5*time.Second),
 	)
@@ -46,16 +68,134 @@ func NewUserClient(address string) (*UserClient, error) {
 	log.Printf("连接到 gRPC 服务器: %s", address)
 
 	return &UserClient{
-		client: client,
-		conn:   conn,
+		client:        client,
+		conn:          conn,
+		timeout:       defaultCallTimeout,
+		streamTimeout: defaultStreamTimeout,
 	}, nil
 }
 
+// ====== 负载均衡 ======
+/*
+NewUserClientBalanced 把请求分散到多个后端：
+
+  client, err := NewUserClientBalanced([]string{"10.0.0.1:50051", "10.0.0.2:50051"})
+
+  - manual.Resolver 把地址列表直接交给 gRPC，不依赖 DNS
+  - round_robin 为每个地址建立一个子连接，按顺序轮流使用处于 READY 状态的子连接
+  - 某个后端宕机时其子连接进入 TRANSIENT_FAILURE，被跳过，并在后台按退避重连
+  - 扩容、缩容时调用 SetAddresses 推送新列表，已有连接会被复用或关闭
+
+与 NewUserClient 不同，这里不阻塞等待连接：部分后端不可用时也能创建客户端，
+全部不可用时调用会返回 Unavailable。
+*/
+
+// balancedScheme 负载均衡模式使用的解析器 scheme
+// 通过 WithResolvers 注册在单个连接上，不同客户端之间互不影响
+const balancedScheme = "userlb"
+
+// roundRobinServiceConfig 启用 round_robin 负载均衡策略
+const roundRobinServiceConfig = `{"loadBalancingConfig": [{"round_robin": {}}]}`
+
+// NewUserClientBalanced 创建在多个地址之间轮询的客户端
+func NewUserClientBalanced(addresses []string) (*UserClient, error) {
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("至少需要一个服务器地址")
+	}
+
+	r := manual.NewBuilderWithScheme(balancedScheme)
+	r.InitialState(resolverState(addresses))
+
+	conn, err := grpc.NewClient(balancedScheme+":///user-service",
+		grpc.WithResolvers(r),
+		grpc.WithDefaultServiceConfig(roundRobinServiceConfig),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(RequestIDUnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(RequestIDStreamClientInterceptor),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建连接失败: %w", err)
+	}
+	// 立即开始连接所有后端，而不是等到第一次调用
+	conn.Connect()
+
+	log.Printf("负载均衡连接到 gRPC 服务器: %v", addresses)
+
+	return &UserClient{
+		client:        pb.NewUserServiceClient(conn),
+		conn:          conn,
+		resolver:      r,
+		timeout:       defaultCallTimeout,
+		streamTimeout: defaultStreamTimeout,
+	}, nil
+}
+
+// SetAddresses 替换后端地址列表，只能用于 NewUserClientBalanced 创建的客户端
+func (c *UserClient) SetAddresses(addresses []string) error {
+	if c.resolver == nil {
+		return fmt.Errorf("客户端未启用负载均衡")
+	}
+	if len(addresses) == 0 {
+		return fmt.Errorf("至少需要一个服务器地址")
+	}
+
+	c.resolver.UpdateState(resolverState(addresses))
+	log.Printf("更新 gRPC 服务器地址: %v", addresses)
+	return nil
+}
+
+// resolverState 把地址列表转换为解析器状态
+func resolverState(addresses []string) resolver.State {
+	addrs := make([]resolver.Address, len(addresses))
+	for i, a := range addresses {
+		addrs[i] = resolver.Address{Addr: a}
+	}
+	return resolver.State{Addresses: addrs}
+}
+
+// SetTimeouts 设置一元调用和流式调用的超时时间
+func (c *UserClient) SetTimeouts(call, stream time.Duration) {
+	c.timeout = call
+	c.streamTimeout = stream
+}
+
 // Close 关闭连接
 func (c *UserClient) Close() error {
 	return c.conn.Close()
 }
 
+// ====== 请求 ID 拦截器 ======
+
+// requestIDMetadataKey metadata 中请求 ID 的键，与服务端一致
+const requestIDMetadataKey = "x-request-id"
+
+// withRequestID 确保 outgoing metadata 中带有请求 ID
+// 优先使用已有的 metadata，其次使用 context 中的请求 ID，都没有时生成新的
+func withRequestID(ctx context.Context) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(requestIDMetadataKey)) > 0 {
+		return ctx
+	}
+
+	id := logger.RequestIDFromContext(ctx)
+	if id == "" {
+		id = logger.NewRequestID()
+		ctx = logger.ContextWithRequestID(ctx, id)
+	}
+	return metadata.AppendToOutgoingContext(ctx, requestIDMetadataKey, id)
+}
+
+// RequestIDUnaryClientInterceptor 一元调用的请求 ID 拦截器
+func RequestIDUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(withRequestID(ctx), method, req, reply, cc, opts...)
+}
+
+// RequestIDStreamClientInterceptor 流式调用的请求 ID 拦截器
+func RequestIDStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(withRequestID(ctx), desc, cc, method, opts...)
+}
+
 // ====== 客户端方法 ======
 
 // CreateUser 创建用户
@@ -69,7 +209,7 @@ func (c *UserClient) CreateUser(username, email, password string) (*pb.User, err
 
 	// 2. 调用远程方法
 	// context 用于设置超时和取消
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
 	resp, err := c.client.CreateUser(ctx, req)
@@ -89,7 +229,7 @@ func (c *UserClient) CreateUser(username, email, password string) (*pb.User, err
 func (c *UserClient) GetUser(id int64) (*pb.User, error) {
 	req := &pb.GetUserRequest{Id: id}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
 	resp, err := c.client.GetUser(ctx, req)
@@ -104,7 +244,7 @@ func (c *UserClient) GetUser(id int64) (*pb.User, error) {
 func (c *UserClient) ListUsers() ([]*pb.User, error) {
 	req := &pb.ListUsersRequest{}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
 	resp, err := c.client.ListUsers(ctx, req)
@@ -123,7 +263,32 @@ func (c *UserClient) UpdateUser(id int64, username, email string) (*pb.User, err
 		Email:    email,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	resp, err := c.client.UpdateUser(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.User, nil
+}
+
+// UpdateUserFields 按字段掩码更新用户
+// values 的 key 为字段名（username、email、password），只更新列出的字段，
+// 值为空字符串表示清空该字段
+func (c *UserClient) UpdateUserFields(id int64, values map[string]string) (*pb.User, error) {
+	req := &pb.UpdateUserRequest{
+		Id:       id,
+		Username: values["username"],
+		Email:    values["email"],
+		Password: values["password"],
+	}
+	for field := range values {
+		req.UpdateFields = append(req.UpdateFields, field)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
 	resp, err := c.client.UpdateUser(ctx, req)
@@ -138,15 +303,16 @@ func (c *UserClient) UpdateUser(id int64, username, email string) (*pb.User, err
 func (c *UserClient) DeleteUser(id int64) error {
 	req := &pb.DeleteUserRequest{Id: id}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
 	_, err := c.client.DeleteUser(ctx, req)
 	return err
 }
 
-// SearchUsers 搜索用户（客户端流式）
-func (c *UserClient) SearchUsers(usernamePrefix string, minAge int32) error {
+// SearchUsers 搜索用户（服务端流式）
+// 收集流中的所有用户并返回
+func (c *UserClient) SearchUsers(usernamePrefix string, minAge int32) ([]*pb.User, error) {
 	// 1. 创建搜索请求
 	req := &pb.SearchUsersRequest{
 		UsernamePrefix: usernamePrefix,
@@ -154,66 +320,190 @@ func (c *UserClient) SearchUsers(usernamePrefix string, minAge int32) error {
 	}
 
 	// 2. 发起流式调用
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), c.streamTimeout)
 	defer cancel()
 
 	stream, err := c.client.SearchUsers(ctx, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// 3. 接收流式响应
-	log.Println("搜索结果:")
+	var users []*pb.User
 	for {
 		resp, err := stream.Recv()
+		if err == io.EOF {
+			// 服务端正常结束流
+			return users, nil
+		}
 		if err != nil {
-			// 流结束时退出
-			break
+			// 真正的错误（超时、服务端返回错误等），同时返回已收到的数据
+			return users, fmt.Errorf("接收搜索结果失败: %w", err)
 		}
 
-		user := resp.User
-		log.Printf("  - %s (%s), Age: %d", user.Username, user.Email, user.Age)
+		users = append(users, resp.User)
 	}
-
-	return nil
 }
 
 // Chat 聊天（双向流式）
-func (c *UserClient) Chat(userID int64, messages []string) error {
+// 发送所有消息并返回服务端的响应
+func (c *UserClient) Chat(userID int64, messages []string) ([]string, error) {
 	// 1. 发起双向流式调用
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), c.streamTimeout)
 	defer cancel()
 
 	stream, err := c.client.Chat(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// 2. 在单独的协程中接收响应
+	// 边发边收，避免双方缓冲区满时互相等待
+	type result struct {
+		replies []string
+		err     error
 	}
+	done := make(chan result, 1)
+	go func() {
+		var replies []string
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				done <- result{replies: replies}
+				return
+			}
+			if err != nil {
+				done <- result{replies: replies, err: fmt.Errorf("接收聊天响应失败: %w", err)}
+				return
+			}
+			replies = append(replies, resp.Message)
+		}
+	}()
 
-	// 2. 发送消息
+	// 3. 发送消息
 	for _, msg := range messages {
 		if err := stream.Send(&pb.ChatRequest{
 			UserId:  userID,
 			Message: msg,
 		}); err != nil {
-			return err
+			// 发送失败时真正的错误由 Recv 返回
+			break
 		}
 	}
 
-	// 3. 关闭发送流
+	// 4. 关闭发送流
 	if err := stream.CloseSend(); err != nil {
-		return err
+		return nil, err
+	}
+
+	// 5. 等待接收完成
+	res := <-done
+	return res.replies, res.err
+}
+
+// uploadChunkSize 上传时每条消息的数据块大小
+const uploadChunkSize = 64 << 10
+
+// UploadFile 上传文件（客户端流式）
+// 从 r 读取内容分块发送，最后发送 SHA-256 由服务端校验；size 未知时传 0
+func (c *UserClient) UploadFile(filename string, size int64, r io.Reader) (*pb.UploadFileResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.streamTimeout)
+	defer cancel()
+
+	stream, err := c.client.UploadFile(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// 4. 接收响应
+	// 1. 文件信息
+	if err := stream.Send(&pb.UploadFileRequest{
+		Payload: &pb.UploadFileRequest_Info{Info: &pb.FileInfo{Filename: filename, Size: size}},
+	}); err != nil {
+		return nil, uploadError(stream, err)
+	}
+
+	// 2. 数据块
+	h := sha256.New()
 	for {
-		resp, err := stream.Recv()
-		if err != nil {
+		buf := make([]byte, uploadChunkSize)
+		n, err := r.Read(buf)
+		if n > 0 {
+			h.Write(buf[:n])
+			if err := stream.Send(&pb.UploadFileRequest{
+				Payload: &pb.UploadFileRequest_Chunk{Chunk: buf[:n]},
+			}); err != nil {
+				return nil, uploadError(stream, err)
+			}
+		}
+		if err == io.EOF {
 			break
 		}
-		log.Printf("收到响应: %s", resp.Message)
+		if err != nil {
+			return nil, fmt.Errorf("读取上传内容失败: %w", err)
+		}
 	}
 
-	return nil
+	// 3. 校验和，然后关闭发送方向并等待结果
+	if err := stream.Send(&pb.UploadFileRequest{
+		Payload: &pb.UploadFileRequest_Sha256{Sha256: hex.EncodeToString(h.Sum(nil))},
+	}); err != nil {
+		return nil, uploadError(stream, err)
+	}
+	return stream.CloseAndRecv()
+}
+
+// uploadError 服务端提前结束流时 Send 只返回 io.EOF，真正的错误需要通过 CloseAndRecv 获取
+func uploadError(stream pb.UserService_UploadFileClient, err error) error {
+	if err == io.EOF {
+		_, err = stream.CloseAndRecv()
+	}
+	return err
+}
+
+// ErrChecksumMismatch 下载内容的 SHA-256 与服务端发送的不一致
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// DownloadFile 下载文件（服务端流式），内容写入 w，返回写入的字节数
+// 收到的内容与服务端的 SHA-256 不一致时返回 ErrChecksumMismatch，
+// 此时 w 中已经写入了内容，调用方应丢弃
+func (c *UserClient) DownloadFile(filename string, w io.Writer) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.streamTimeout)
+	defer cancel()
+
+	stream, err := c.client.DownloadFile(ctx, &pb.DownloadFileRequest{Filename: filename})
+	if err != nil {
+		return 0, err
+	}
+
+	h := sha256.New()
+	w = io.MultiWriter(w, h)
+	var size int64
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return size, errors.New("下载流在校验和之前结束")
+		}
+		if err != nil {
+			return size, err
+		}
+
+		switch p := resp.Payload.(type) {
+		case *pb.DownloadFileResponse_Info:
+			// 文件信息，目前只用于日志
+			log.Printf("开始下载 %s (%d 字节)", p.Info.Filename, p.Info.Size)
+		case *pb.DownloadFileResponse_Chunk:
+			n, err := w.Write(p.Chunk)
+			size += int64(n)
+			if err != nil {
+				return size, err
+			}
+		case *pb.DownloadFileResponse_Sha256:
+			if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(p.Sha256, sum) {
+				return size, fmt.Errorf("%w: server %s, local %s", ErrChecksumMismatch, p.Sha256, sum)
+			}
+			return size, nil
+		}
+	}
 }
 
 // ====== 主函数 ======
@@ -222,11 +512,17 @@ func main() {
 	fmt.Println("=== gRPC 客户端示例 ===")
 
 	// 1. 解析命令行参数
-	serverAddr := flag.String("server", "localhost:50051", "gRPC 服务器地址")
+	serverAddr := flag.String("server", "localhost:50051", "gRPC 服务器地址，多个地址用逗号分隔时启用负载均衡")
 	flag.Parse()
 
 	// 2. 创建客户端
-	client, err := NewUserClient(*serverAddr)
+	var client *UserClient
+	var err error
+	if addrs := strings.Split(*serverAddr, ","); len(addrs) > 1 {
+		client, err = NewUserClientBalanced(addrs)
+	} else {
+		client, err = NewUserClient(*serverAddr)
+	}
 	if err != nil {
 		log.Fatalf("创建客户端失败: %v", err)
 	}
@@ -281,13 +577,33 @@ func main() {
 		fmt.Printf("更新成功: %s, %s\n", updatedUser.Username, updatedUser.Email)
 	}
 
+	// 只更新邮箱，用户名保持不变
+	updatedUser, err = client.UpdateUserFields(1, map[string]string{"email": "alice@example.com"})
+	if err != nil {
+		log.Printf("按字段更新失败: %v", err)
+	} else {
+		fmt.Printf("按字段更新成功: %s, %s\n", updatedUser.Username, updatedUser.Email)
+	}
+
 	// 7. 测试搜索用户
 	fmt.Println("\n--- 搜索用户 ---")
-	_ = client.SearchUsers("a", 0)
+	found, err := client.SearchUsers("a", 0)
+	if err != nil {
+		log.Printf("搜索用户失败: %v", err)
+	}
+	for _, u := range found {
+		fmt.Printf("  - %s (%s), Age: %d\n", u.Username, u.Email, u.Age)
+	}
 
 	// 8. 测试聊天
 	fmt.Println("\n--- 聊天测试 ---")
-	_ = client.Chat(1, []string{"Hello!", "How are you?", "Bye!"})
+	replies, err := client.Chat(1, []string{"Hello!", "How are you?", "Bye!"})
+	if err != nil {
+		log.Printf("聊天失败: %v", err)
+	}
+	for _, r := range replies {
+		fmt.Printf("收到响应: %s\n", r)
+	}
 
 	// 9. 测试删除用户
 	fmt.Println("\n--- 删除用户 ---")
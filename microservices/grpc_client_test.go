@@ -0,0 +1,317 @@
+// microservices/grpc_client_test.go
+// gRPC 客户端示例的测试
+//
+// microservices 目录下每个文件都是独立的示例程序（各有一个 main），需要按文件运行：
+//   go test microservices/grpc_client.go microservices/grpc_client_test.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/austoin/GolangTutorial/logger"
+	pb "github.com/austoin/GolangTutorial/microservices/proto"
+)
+
+// fakeUserServer 可控的服务端，未实现的方法返回 Unimplemented
+type fakeUserServer struct {
+	pb.UnimplementedUserServiceServer
+
+	searchUsers []*pb.User // SearchUsers 依次发送的用户
+	searchErr   error      // 发送完 searchUsers 后返回的错误
+	searchBlock bool       // 发送完后阻塞直到客户端取消
+
+	requestIDs chan []string // 非 nil 时记录每次 GetUser 收到的 x-request-id
+	name       string        // GetUser 返回的用户名，用于区分不同的后端
+}
+
+func (f *fakeUserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
+	if f.requestIDs != nil {
+		md, _ := metadata.FromIncomingContext(ctx)
+		f.requestIDs <- md.Get(requestIDMetadataKey)
+	}
+	return &pb.GetUserResponse{User: &pb.User{Id: req.Id, Username: f.name}}, nil
+}
+
+func (f *fakeUserServer) SearchUsers(req *pb.SearchUsersRequest, stream pb.UserService_SearchUsersServer) error {
+	for _, u := range f.searchUsers {
+		if err := stream.Send(&pb.SearchUsersResponse{User: u}); err != nil {
+			return err
+		}
+	}
+	if f.searchBlock {
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}
+	return f.searchErr
+}
+
+// Chat 对每条消息回复 "reply: <message>"，收到 "fail" 时以 Internal 结束流
+func (f *fakeUserServer) Chat(stream pb.UserService_ChatServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.Message == "fail" {
+			return status.Error(codes.Internal, "chat failed")
+		}
+		if err := stream.Send(&pb.ChatResponse{UserId: req.UserId, Message: "reply: " + req.Message}); err != nil {
+			return err
+		}
+	}
+}
+
+// startFakeServer 在 bufconn 上启动 srv，返回连接它的 UserClient
+// 客户端使用与 NewUserClient 相同的拦截器
+func startFakeServer(t *testing.T, srv pb.UserServiceServer, opts ...grpc.ServerOption) *UserClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(opts...)
+	pb.RegisterUserServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(RequestIDUnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(RequestIDStreamClientInterceptor),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	c := &UserClient{
+		client:        pb.NewUserServiceClient(conn),
+		conn:          conn,
+		timeout:       defaultCallTimeout,
+		streamTimeout: defaultStreamTimeout,
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// usernames 提取用户名，便于比较
+func usernames(users []*pb.User) []string {
+	var names []string
+	for _, u := range users {
+		names = append(names, u.GetUsername())
+	}
+	return names
+}
+
+// ====== 负载均衡 ======
+
+// startTCPFakeServer 在本地 TCP 端口上启动名为 name 的 fakeUserServer，返回地址和停止函数
+// 负载均衡客户端通过地址建立连接，不能使用 bufconn
+func startTCPFakeServer(t *testing.T, name string) (addr string, stop func()) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterUserServiceServer(s, &fakeUserServer{name: name})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String(), s.Stop
+}
+
+// collectBackends 反复调用 GetUser，直到连续 n 次调用的后端都满足 want，返回这 n 次的后端
+// 连接建立和断开需要时间，期间的失败调用会被忽略
+func collectBackends(t *testing.T, c *UserClient, n int, want func(seen map[string]int) bool) map[string]int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		seen := map[string]int{}
+		for range n {
+			if u, err := c.GetUser(1); err == nil {
+				seen[u.GetUsername()]++
+			}
+		}
+		if want(seen) {
+			return seen
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("5s 内没有达到期望的后端分布")
+	return nil
+}
+
+func TestNewUserClientBalanced(t *testing.T) {
+	addrA, stopA := startTCPFakeServer(t, "a")
+	addrB, _ := startTCPFakeServer(t, "b")
+
+	c, err := NewUserClientBalanced([]string{addrA, addrB})
+	if err != nil {
+		t.Fatalf("NewUserClientBalanced() error = %v", err)
+	}
+	defer c.Close()
+
+	t.Run("两个后端轮流处理", func(t *testing.T) {
+		// 两个子连接都 READY 后严格轮询，10 次调用各 5 次
+		collectBackends(t, c, 10, func(seen map[string]int) bool {
+			return seen["a"] == 5 && seen["b"] == 5
+		})
+	})
+
+	t.Run("后端宕机后跳过", func(t *testing.T) {
+		stopA()
+		collectBackends(t, c, 5, func(seen map[string]int) bool { return seen["b"] == 5 })
+	})
+
+	t.Run("SetAddresses 替换地址列表", func(t *testing.T) {
+		addrC, _ := startTCPFakeServer(t, "c")
+		if err := c.SetAddresses([]string{addrC}); err != nil {
+			t.Fatalf("SetAddresses() error = %v", err)
+		}
+		collectBackends(t, c, 5, func(seen map[string]int) bool { return seen["c"] == 5 })
+	})
+
+	t.Run("参数错误", func(t *testing.T) {
+		if _, err := NewUserClientBalanced(nil); err == nil {
+			t.Error("NewUserClientBalanced(nil) 应该返回错误")
+		}
+		if err := c.SetAddresses(nil); err == nil {
+			t.Error("SetAddresses(nil) 应该返回错误")
+		}
+		// 非负载均衡的客户端没有解析器
+		plain := startFakeServer(t, &fakeUserServer{})
+		if err := plain.SetAddresses([]string{addrB}); err == nil {
+			t.Error("普通客户端 SetAddresses() 应该返回错误")
+		}
+	})
+}
+
+// ====== 请求 ID 拦截器 ======
+
+func TestRequestIDClientInterceptor(t *testing.T) {
+	srv := &fakeUserServer{requestIDs: make(chan []string, 1)}
+	c := startFakeServer(t, srv)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string // 为空时只要求生成了一个 ID
+	}{
+		{"使用 context 中的请求 ID", logger.ContextWithRequestID(context.Background(), "req-ctx"), "req-ctx"},
+		{"已有 metadata 时保持不变",
+			metadata.AppendToOutgoingContext(logger.ContextWithRequestID(context.Background(), "req-ctx"), requestIDMetadataKey, "req-md"),
+			"req-md"},
+		{"都没有时生成", context.Background(), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.client.GetUser(tt.ctx, &pb.GetUserRequest{Id: 1}); err != nil {
+				t.Fatalf("GetUser() error = %v", err)
+			}
+			got := <-srv.requestIDs
+			if len(got) != 1 || got[0] == "" {
+				t.Fatalf("服务端收到 x-request-id = %q, want 恰好一个", got)
+			}
+			if tt.want != "" && got[0] != tt.want {
+				t.Errorf("x-request-id = %q, want %q", got[0], tt.want)
+			}
+		})
+	}
+}
+
+// ====== 流式调用 ======
+
+func TestSearchUsers(t *testing.T) {
+	sent := []*pb.User{{Id: 1, Username: "alice"}, {Id: 2, Username: "alex"}}
+
+	t.Run("正常结束", func(t *testing.T) {
+		c := startFakeServer(t, &fakeUserServer{searchUsers: sent})
+		users, err := c.SearchUsers("al", 0)
+		if err != nil {
+			t.Fatalf("SearchUsers() error = %v", err)
+		}
+		if got := usernames(users); !slices.Equal(got, []string{"alice", "alex"}) {
+			t.Errorf("SearchUsers() = %v, want [alice alex]", got)
+		}
+	})
+
+	t.Run("没有结果", func(t *testing.T) {
+		c := startFakeServer(t, &fakeUserServer{})
+		users, err := c.SearchUsers("zz", 0)
+		if err != nil || len(users) != 0 {
+			t.Errorf("SearchUsers() = %v, %v, want 空结果且无错误", users, err)
+		}
+	})
+
+	t.Run("流中途出错", func(t *testing.T) {
+		c := startFakeServer(t, &fakeUserServer{
+			searchUsers: sent,
+			searchErr:   status.Error(codes.Unavailable, "store down"),
+		})
+		users, err := c.SearchUsers("al", 0)
+		if status.Code(errors.Unwrap(err)) != codes.Unavailable {
+			t.Errorf("SearchUsers() error = %v, want 包装的 Unavailable", err)
+		}
+		// 出错前收到的数据也一并返回
+		if got := usernames(users); !slices.Equal(got, []string{"alice", "alex"}) {
+			t.Errorf("出错时返回的用户 = %v, want [alice alex]", got)
+		}
+	})
+
+	t.Run("流式超时可配置", func(t *testing.T) {
+		c := startFakeServer(t, &fakeUserServer{searchUsers: sent[:1], searchBlock: true})
+		c.SetTimeouts(time.Second, 50*time.Millisecond)
+
+		start := time.Now()
+		users, err := c.SearchUsers("al", 0)
+		if status.Code(errors.Unwrap(err)) != codes.DeadlineExceeded {
+			t.Errorf("SearchUsers() error = %v, want DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("SearchUsers() 用时 %s，没有按 streamTimeout 超时", elapsed)
+		}
+		if len(users) != 1 {
+			t.Errorf("超时前收到 %d 个用户, want 1", len(users))
+		}
+	})
+}
+
+func TestChat(t *testing.T) {
+	c := startFakeServer(t, &fakeUserServer{})
+
+	replies, err := c.Chat(7, []string{"hi", "how are you"})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if want := []string{"reply: hi", "reply: how are you"}; !slices.Equal(replies, want) {
+		t.Errorf("Chat() = %v, want %v", replies, want)
+	}
+
+	if replies, err := c.Chat(7, nil); err != nil || len(replies) != 0 {
+		t.Errorf("Chat(nil) = %v, %v, want 空结果且无错误", replies, err)
+	}
+
+	// 服务端中途结束流：返回已收到的回复和服务端的错误
+	replies, err = c.Chat(7, []string{"hi", "fail", "never sent"})
+	if status.Code(errors.Unwrap(err)) != codes.Internal {
+		t.Errorf("Chat() error = %v, want 包装的 Internal", err)
+	}
+	if !slices.Equal(replies, []string{"reply: hi"}) {
+		t.Errorf("出错时返回的回复 = %v, want [reply: hi]", replies)
+	}
+}
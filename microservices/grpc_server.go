@@ -4,18 +4,41 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"net"
+	"net/http"
+	"net/mail"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
 
-	pb "GolangTutorial/microservices/proto"
+	"github.com/austoin/GolangTutorial/humanize"
+	"github.com/austoin/GolangTutorial/idgen"
+	"github.com/austoin/GolangTutorial/logger"
+	pb "github.com/austoin/GolangTutorial/microservices/proto"
 )
 
 // ====== gRPC 服务端基础 ======
@@ -37,37 +60,45 @@ gRPC 是 Google 开发的高性能远程过程调用（RPC）框架。
 // server 结构体实现 UserServiceServer 接口
 type server struct {
 	pb.UnimplementedUserServiceServer
-	users map[int64]*pb.User // 内存存储用户数据
+	store UserStore   // 用户存储，并发安全由存储实现保证
+	files FileStorage // UploadFile / DownloadFile 使用的目录
 }
 
-// NewServer 创建新的服务器实例
+// NewServer 创建使用内存存储的服务器实例，ID 生成器的节点 ID 为 0
 func NewServer() *server {
+	ids, _ := idgen.New(0) // 0 一定在合法范围内
+	return NewServerWithStore(NewMemoryUserStore(ids))
+}
+
+// NewServerWithStore 创建使用指定存储的服务器实例
+func NewServerWithStore(store UserStore) *server {
 	return &server{
-		users: make(map[int64]*pb.User),
+		store: store,
+		files: FileStorage{Dir: defaultFileDir, MaxSize: defaultMaxFileSize},
 	}
 }
 
 // CreateUser 创建用户
 // 实现 UserServiceServer 接口的 CreateUser 方法
 func (s *server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
-	// 1. 验证请求
-	if req.Username == "" {
-		return nil, status.Error(codes.InvalidArgument, "Username is required")
-	}
-	if req.Email == "" {
-		return nil, status.Error(codes.InvalidArgument, "Email is required")
+	// 1. 规范化邮箱（必填字段和格式已由 ValidationUnaryInterceptor 校验）
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return nil, err
 	}
 
 	// 2. 创建用户
 	user := &pb.User{
-		Id:       generateID(), // 生成唯一 ID
 		Username: req.Username,
-		Email:    req.Email,
+		Email:    email,
 		Password: req.Password,
+		Age:      req.Age,
 	}
 
-	// 3. 存储用户
-	s.users[user.Id] = user
+	// 3. 存储用户（ID 由存储分配，邮箱唯一性由存储保证）
+	if err := s.store.Create(ctx, user); err != nil {
+		return nil, storeError(err)
+	}
 
 	log.Printf("创建用户: %s (ID: %d)", user.Username, user.Id)
 
@@ -85,9 +116,9 @@ func (s *server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUs
 	}
 
 	// 2. 查找用户
-	user, exists := s.users[req.Id]
-	if !exists {
-		return nil, status.Error(codes.NotFound, "User not found")
+	user, err := s.store.Get(ctx, req.Id)
+	if err != nil {
+		return nil, storeError(err)
 	}
 
 	return &pb.GetUserResponse{
@@ -98,9 +129,9 @@ func (s *server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUs
 // ListUsers 列出所有用户
 func (s *server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
 	// 1. 收集所有用户
-	users := make([]*pb.User, 0, len(s.users))
-	for _, user := range s.users {
-		users = append(users, user)
+	users, err := s.store.List(ctx)
+	if err != nil {
+		return nil, storeError(err)
 	}
 
 	// 2. 返回响应
@@ -112,23 +143,20 @@ func (s *server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.L
 
 // UpdateUser 更新用户
 func (s *server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
-	// 1. 验证请求
-	if req.Id == 0 {
-		return nil, status.Error(codes.InvalidArgument, "User ID is required")
+	// 1. 查找用户（请求已由 ValidationUnaryInterceptor 校验）
+	user, err := s.store.Get(ctx, req.Id)
+	if err != nil {
+		return nil, storeError(err)
 	}
 
-	// 2. 查找用户
-	user, exists := s.users[req.Id]
-	if !exists {
-		return nil, status.Error(codes.NotFound, "User not found")
+	// 2. 按字段掩码更新
+	if err := applyUpdateMask(user, req); err != nil {
+		return nil, err
 	}
 
-	// 3. 更新字段
-	if req.Username != "" {
-		user.Username = req.Username
-	}
-	if req.Email != "" {
-		user.Email = req.Email
+	// 3. 保存（邮箱被其他用户占用时返回 AlreadyExists）
+	if err := s.store.Update(ctx, user); err != nil {
+		return nil, storeError(err)
 	}
 
 	// 4. 返回响应
@@ -145,12 +173,10 @@ func (s *server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb
 	}
 
 	// 2. 删除用户
-	if _, exists := s.users[req.Id]; !exists {
-		return nil, status.Error(codes.NotFound, "User not found")
+	if err := s.store.Delete(ctx, req.Id); err != nil {
+		return nil, storeError(err)
 	}
 
-	delete(s.users, req.Id)
-
 	log.Printf("删除用户: ID=%d", req.Id)
 
 	return &pb.DeleteUserResponse{
@@ -158,33 +184,35 @@ func (s *server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb
 	}, nil
 }
 
+// searchPageSize SearchUsers 每次从存储读取的用户数量
+const searchPageSize = 100
+
 // SearchUsers 搜索用户（服务端流式）
+// 过滤在存储层完成，按 ID 分页读取，避免一次加载全部用户
 func (s *server) SearchUsers(req *pb.SearchUsersRequest, stream pb.UserService_SearchUsersServer) error {
-	// 1. 遍历所有用户
-	for _, user := range s.users {
-		// 2. 检查是否匹配搜索条件
-		matched := true
-
-		if req.UsernamePrefix != "" {
-			// 检查用户名是否以指定前缀开头
-			matched = matched && len(user.Username) >= len(req.UsernamePrefix) &&
-				user.Username[:len(req.UsernamePrefix)] == req.UsernamePrefix
-		}
+	ctx := stream.Context()
 
-		if req.MinAge > 0 {
-			// 检查年龄是否大于最小年龄
-			matched = matched && user.Age >= req.MinAge
+	var afterID int64
+	for {
+		// 1. 读取下一页匹配的用户
+		users, err := s.store.Search(ctx, req.UsernamePrefix, req.MinAge, afterID, searchPageSize)
+		if err != nil {
+			return storeError(err)
 		}
 
-		if matched {
-			// 3. 发送匹配的用户到流
+		// 2. 逐个发送到流
+		for _, user := range users {
 			if err := stream.Send(&pb.SearchUsersResponse{User: user}); err != nil {
 				return err
 			}
 		}
-	}
 
-	return nil
+		// 3. 不足一页说明已经是最后一页
+		if len(users) < searchPageSize {
+			return nil
+		}
+		afterID = users[len(users)-1].Id
+	}
 }
 
 // Chat stream 用户聊天（双向流式）
@@ -214,24 +242,1058 @@ func (s *server) Chat(stream pb.UserService_ChatServer) error {
 	}
 }
 
+// ====== 文件传输 ======
+/*
+gRPC 单条消息默认最大 4MB，大文件需要拆成数据块用流传输（消息格式见 user.proto）：
+
+  UploadFile     客户端流式：info → chunk... → sha256，服务端返回保存结果
+  DownloadFile   服务端流式：info → chunk... → sha256，客户端自行校验
+
+上传的安全措施：
+  - 文件名只能是单个路径元素，不能包含 / \ 或以 . 开头（拒绝 ../../etc/passwd 和隐藏文件）
+  - 文件操作通过 os.Root 进行，即使目录中有指向外部的符号链接也无法越界
+  - 声明的大小和实际接收的字节数都不能超过 MaxSize，超出返回 ResourceExhausted
+  - 先写入临时文件，校验和一致后才重命名为目标文件名；中途失败或校验不一致（DataLoss）
+    时删除临时文件，不会留下不完整的文件
+
+同名文件会被覆盖。
+*/
+
+const (
+	defaultFileDir     = "./uploads"
+	defaultMaxFileSize = 100 << 20 // 100MB
+	fileChunkSize      = 64 << 10  // 下载时每条消息的数据块大小
+	maxFileNameLen     = 255
+)
+
+// FileStorage 文件传输的存储目录和单个文件的大小上限
+type FileStorage struct {
+	Dir     string
+	MaxSize int64
+}
+
+// open 打开存储目录，不存在时创建
+func (f FileStorage) open() (*os.Root, error) {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenRoot(f.Dir)
+}
+
+// checkFileName 文件名必须是不含路径的普通文件名
+func checkFileName(name string) error {
+	if name == "" || len(name) > maxFileNameLen ||
+		strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\\x00") {
+		return status.Errorf(codes.InvalidArgument, "invalid filename %q", name)
+	}
+	return nil
+}
+
+// UploadFile 上传文件（客户端流式）
+func (s *server) UploadFile(stream pb.UserService_UploadFileServer) error {
+	// 1. 第一条消息必须是文件信息
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "empty upload")
+	}
+	if err != nil {
+		return err
+	}
+	info := first.GetInfo()
+	if info == nil {
+		return status.Error(codes.InvalidArgument, "first message must carry file info")
+	}
+	if err := checkFileName(info.Filename); err != nil {
+		return err
+	}
+	if info.Size > s.files.MaxSize {
+		return status.Errorf(codes.ResourceExhausted, "file exceeds %s", humanize.FormatBytes(s.files.MaxSize))
+	}
+
+	root, err := s.files.open()
+	if err != nil {
+		return status.Errorf(codes.Internal, "open storage: %v", err)
+	}
+	defer root.Close()
+
+	// 2. 写入临时文件，校验通过之前目标文件保持不变
+	tmpName := "." + info.Filename + "." + strconv.FormatInt(time.Now().UnixNano(), 36) + ".tmp"
+	tmp, err := root.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return status.Errorf(codes.Internal, "create file: %v", err)
+	}
+	committed := false
+	defer func() {
+		tmp.Close()
+		if !committed {
+			root.Remove(tmpName)
+		}
+	}()
+
+	// 3. 接收数据块并校验
+	size, sum, err := s.receiveFile(stream, tmp)
+	if err != nil {
+		return err
+	}
+	if info.Size > 0 && size != info.Size {
+		return status.Errorf(codes.InvalidArgument, "declared size %d but received %d bytes", info.Size, size)
+	}
+
+	// 4. 关闭并重命名为目标文件
+	if err := tmp.Close(); err != nil {
+		return status.Errorf(codes.Internal, "write file: %v", err)
+	}
+	if err := root.Rename(tmpName, info.Filename); err != nil {
+		return status.Errorf(codes.Internal, "save file: %v", err)
+	}
+	committed = true
+
+	return stream.SendAndClose(&pb.UploadFileResponse{
+		Filename: info.Filename,
+		Size:     size,
+		Sha256:   sum,
+	})
+}
+
+// receiveFile 把数据块写入 w，直到收到校验和消息
+// 返回接收的字节数和服务端计算的 SHA-256
+func (s *server) receiveFile(stream pb.UserService_UploadFileServer, w io.Writer) (int64, string, error) {
+	h := sha256.New()
+	w = io.MultiWriter(w, h)
+
+	var size int64
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return 0, "", status.Error(codes.InvalidArgument, "upload ended without checksum")
+		}
+		if err != nil {
+			return 0, "", err
+		}
+
+		switch p := req.Payload.(type) {
+		case *pb.UploadFileRequest_Chunk:
+			size += int64(len(p.Chunk))
+			if size > s.files.MaxSize {
+				return 0, "", status.Errorf(codes.ResourceExhausted, "file exceeds %s", humanize.FormatBytes(s.files.MaxSize))
+			}
+			if _, err := w.Write(p.Chunk); err != nil {
+				return 0, "", status.Errorf(codes.Internal, "write file: %v", err)
+			}
+
+		case *pb.UploadFileRequest_Sha256:
+			sum := hex.EncodeToString(h.Sum(nil))
+			if !strings.EqualFold(p.Sha256, sum) {
+				return 0, "", status.Errorf(codes.DataLoss, "checksum mismatch: got %s, computed %s", p.Sha256, sum)
+			}
+			// 校验和必须是最后一条消息
+			if _, err := stream.Recv(); err != io.EOF {
+				if err == nil {
+					err = status.Error(codes.InvalidArgument, "unexpected message after checksum")
+				}
+				return 0, "", err
+			}
+			return size, sum, nil
+
+		default:
+			return 0, "", status.Error(codes.InvalidArgument, "file info must only be sent first")
+		}
+	}
+}
+
+// DownloadFile 下载文件（服务端流式）
+func (s *server) DownloadFile(req *pb.DownloadFileRequest, stream pb.UserService_DownloadFileServer) error {
+	if err := checkFileName(req.Filename); err != nil {
+		return err
+	}
+
+	root, err := s.files.open()
+	if err != nil {
+		return status.Errorf(codes.Internal, "open storage: %v", err)
+	}
+	defer root.Close()
+
+	// 1. 打开文件，目录和不存在的文件都按 NotFound 处理
+	f, err := root.Open(req.Filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return status.Errorf(codes.NotFound, "file %q not found", req.Filename)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "open file: %v", err)
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return status.Errorf(codes.Internal, "stat file: %v", err)
+	}
+	if !st.Mode().IsRegular() {
+		return status.Errorf(codes.NotFound, "file %q not found", req.Filename)
+	}
+
+	// 2. 文件信息
+	if err := stream.Send(&pb.DownloadFileResponse{
+		Payload: &pb.DownloadFileResponse_Info{Info: &pb.FileInfo{Filename: req.Filename, Size: st.Size()}},
+	}); err != nil {
+		return err
+	}
+
+	// 3. 数据块
+	// 每次使用新的缓冲区：Send 返回后消息仍可能被统计、追踪等组件引用
+	h := sha256.New()
+	for {
+		buf := make([]byte, fileChunkSize)
+		n, err := f.Read(buf)
+		if n > 0 {
+			h.Write(buf[:n])
+			if err := stream.Send(&pb.DownloadFileResponse{
+				Payload: &pb.DownloadFileResponse_Chunk{Chunk: buf[:n]},
+			}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "read file: %v", err)
+		}
+	}
+
+	// 4. 校验和
+	return stream.Send(&pb.DownloadFileResponse{
+		Payload: &pb.DownloadFileResponse_Sha256{Sha256: hex.EncodeToString(h.Sum(nil))},
+	})
+}
+
+// ====== 用户存储 ======
+/*
+UserStore 把数据访问从 gRPC 处理器中分离出来：
+  - MemoryUserStore：内存实现，默认使用，适合示例和测试
+  - GormUserStore：GORM 实现，数据持久化到 MySQL
+
+启动时指定 -dsn 参数使用 GORM 存储：
+  go run grpc_server.go -dsn "root:password@tcp(localhost:3306)/testdb?parseTime=True"
+
+两种实现都要保证：
+  - 并发安全
+  - 邮箱唯一（Create/Update 时冲突返回 ErrEmailTaken）
+  - Search 结果按 ID 升序，afterID 作为分页游标
+*/
+
+var (
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrEmailTaken 邮箱已被其他用户使用
+	ErrEmailTaken = errors.New("email already registered")
+)
+
+// UserStore 用户存储接口
+type UserStore interface {
+	// Create 创建用户，成功后会设置 user.Id
+	Create(ctx context.Context, user *pb.User) error
+	// Get 按 ID 获取用户，不存在返回 ErrUserNotFound
+	Get(ctx context.Context, id int64) (*pb.User, error)
+	// List 列出所有用户
+	List(ctx context.Context) ([]*pb.User, error)
+	// Update 保存用户的所有字段
+	Update(ctx context.Context, user *pb.User) error
+	// Delete 删除用户
+	Delete(ctx context.Context, id int64) error
+	// Search 搜索用户名以 prefix 开头、年龄 >= minAge 的用户
+	// 只返回 ID > afterID 的用户，最多 limit 个
+	Search(ctx context.Context, prefix string, minAge int32, afterID int64, limit int) ([]*pb.User, error)
+}
+
+// storeError 把存储错误转换为 gRPC 状态码
+func storeError(err error) error {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		return status.Error(codes.NotFound, "User not found")
+	case errors.Is(err, ErrEmailTaken):
+		return status.Error(codes.AlreadyExists, "Email is already registered")
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Errorf(codes.Internal, "Storage error: %v", err)
+	}
+}
+
+// MemoryUserStore 内存用户存储
+// 返回的都是副本，调用方修改不会影响存储中的数据
+// ctx 已取消或超时时直接返回 ctx.Err()，与 GORM 存储的行为一致
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users map[int64]*pb.User
+	ids   *idgen.Generator // 用户 ID 生成器
+}
+
+// NewMemoryUserStore 创建内存用户存储
+func NewMemoryUserStore(ids *idgen.Generator) *MemoryUserStore {
+	return &MemoryUserStore{users: make(map[int64]*pb.User), ids: ids}
+}
+
+// Create 分配 ID 并保存用户，回填 Id、CreatedAt 和 UpdatedAt
+func (m *MemoryUserStore) Create(ctx context.Context, user *pb.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.emailTaken(user.Email, 0) {
+		return ErrEmailTaken
+	}
+
+	id, err := m.ids.NextID()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	user.Id = id
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	m.users[user.Id] = cloneUser(user)
+	return nil
+}
+
+// Get 按 ID 获取用户的副本，不存在返回 ErrUserNotFound
+func (m *MemoryUserStore) Get(ctx context.Context, id int64) (*pb.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+// List 按 ID 升序列出所有用户
+func (m *MemoryUserStore) List(ctx context.Context) ([]*pb.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]*pb.User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, cloneUser(user))
+	}
+	sortUsersByID(users)
+	return users, nil
+}
+
+// Update 保存用户的所有字段，保留原 CreatedAt 并刷新 UpdatedAt
+func (m *MemoryUserStore) Update(ctx context.Context, user *pb.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.users[user.Id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if m.emailTaken(user.Email, user.Id) {
+		return ErrEmailTaken
+	}
+
+	user.CreatedAt = old.CreatedAt
+	user.UpdatedAt = time.Now().Unix()
+	m.users[user.Id] = cloneUser(user)
+	return nil
+}
+
+// Delete 删除用户，不存在返回 ErrUserNotFound
+func (m *MemoryUserStore) Delete(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return ErrUserNotFound
+	}
+	delete(m.users, id)
+	return nil
+}
+
+// Search 遍历所有用户筛选，结果按 ID 升序
+func (m *MemoryUserStore) Search(ctx context.Context, prefix string, minAge int32, afterID int64, limit int) ([]*pb.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var users []*pb.User
+	for _, user := range m.users {
+		if user.Id > afterID && strings.HasPrefix(user.Username, prefix) && user.Age >= minAge {
+			users = append(users, cloneUser(user))
+		}
+	}
+
+	// map 遍历无序，排序后才能按 ID 分页
+	sortUsersByID(users)
+	if limit > 0 && len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+// emailTaken 检查邮箱是否已被其他用户使用，调用方需持有 m.mu
+// excludeID 为当前用户 ID，更新自己时不算冲突
+func (m *MemoryUserStore) emailTaken(email string, excludeID int64) bool {
+	for id, u := range m.users {
+		if id != excludeID && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneUser 深拷贝用户
+func cloneUser(user *pb.User) *pb.User {
+	return proto.Clone(user).(*pb.User)
+}
+
+// sortUsersByID 按 ID 升序排序
+func sortUsersByID(users []*pb.User) {
+	slices.SortFunc(users, func(a, b *pb.User) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+}
+
+// userRecord GORM 存储使用的表结构
+type userRecord struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement:false"` // 由 idgen 生成
+	Username  string `gorm:"size:50;not null;index"`
+	Email     string `gorm:"size:100;not null;uniqueIndex"`
+	Password  string `gorm:"size:255"`
+	Age       int32  `gorm:"index"`
+	Status    int32
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName 指定表名，避免与 database 示例中的 users 表冲突
+func (userRecord) TableName() string {
+	return "grpc_users"
+}
+
+// toProto 转换为 protobuf 消息
+func (r *userRecord) toProto() *pb.User {
+	return &pb.User{
+		Id:        r.ID,
+		Username:  r.Username,
+		Email:     r.Email,
+		Password:  r.Password,
+		Age:       r.Age,
+		Status:    pb.UserStatus(r.Status),
+		CreatedAt: r.CreatedAt.Unix(),
+		UpdatedAt: r.UpdatedAt.Unix(),
+	}
+}
+
+// GormUserStore GORM 用户存储
+type GormUserStore struct {
+	db  *gorm.DB
+	ids *idgen.Generator // 用户 ID 生成器
+}
+
+// NewGormUserStore 创建 GORM 用户存储，并自动迁移表结构
+// db 建议开启 gorm.Config{TranslateError: true}，这样唯一索引冲突才能识别为 ErrEmailTaken
+func NewGormUserStore(db *gorm.DB, ids *idgen.Generator) (*GormUserStore, error) {
+	if err := db.AutoMigrate(&userRecord{}); err != nil {
+		return nil, fmt.Errorf("迁移用户表失败: %w", err)
+	}
+	return &GormUserStore{db: db, ids: ids}, nil
+}
+
+// Create 分配 ID 并插入用户，回填 Id、CreatedAt 和 UpdatedAt
+func (g *GormUserStore) Create(ctx context.Context, user *pb.User) error {
+	// 由应用生成 ID，多个实例写同一张表也不会冲突
+	id, err := g.ids.NextID()
+	if err != nil {
+		return err
+	}
+
+	rec := &userRecord{
+		ID:       id,
+		Username: user.Username,
+		Email:    user.Email,
+		Password: user.Password,
+		Age:      user.Age,
+		Status:   int32(user.Status),
+	}
+	if err := g.db.WithContext(ctx).Create(rec).Error; err != nil {
+		return gormStoreError(err)
+	}
+
+	user.Id = rec.ID
+	user.CreatedAt = rec.CreatedAt.Unix()
+	user.UpdatedAt = rec.UpdatedAt.Unix()
+	return nil
+}
+
+// Get 按主键查询用户，不存在返回 ErrUserNotFound
+func (g *GormUserStore) Get(ctx context.Context, id int64) (*pb.User, error) {
+	var rec userRecord
+	if err := g.db.WithContext(ctx).First(&rec, id).Error; err != nil {
+		return nil, gormStoreError(err)
+	}
+	return rec.toProto(), nil
+}
+
+// List 按 ID 升序列出所有用户
+func (g *GormUserStore) List(ctx context.Context) ([]*pb.User, error) {
+	var recs []userRecord
+	if err := g.db.WithContext(ctx).Order("id").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	return recordsToProto(recs), nil
+}
+
+// Update 保存用户的所有字段，并回填 CreatedAt/UpdatedAt
+// 先查询确认用户存在：MySQL 在字段值没有变化时 RowsAffected 为 0，不能据此判断用户不存在
+func (g *GormUserStore) Update(ctx context.Context, user *pb.User) error {
+	db := g.db.WithContext(ctx)
+	var rec userRecord
+	if err := db.Select("id", "created_at").First(&rec, user.Id).Error; err != nil {
+		return gormStoreError(err)
+	}
+
+	now := time.Now()
+	err := db.Model(&userRecord{}).Where("id = ?", user.Id).Updates(map[string]interface{}{
+		"username":   user.Username,
+		"email":      user.Email,
+		"password":   user.Password,
+		"age":        user.Age,
+		"status":     int32(user.Status),
+		"updated_at": now,
+	}).Error
+	if err != nil {
+		return gormStoreError(err)
+	}
+
+	user.CreatedAt = rec.CreatedAt.Unix()
+	user.UpdatedAt = now.Unix()
+	return nil
+}
+
+// Delete 删除用户，没有删除任何行时返回 ErrUserNotFound
+func (g *GormUserStore) Delete(ctx context.Context, id int64) error {
+	result := g.db.WithContext(ctx).Delete(&userRecord{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// Search 用 LIKE 前缀匹配用户名，按 ID 升序分页
+func (g *GormUserStore) Search(ctx context.Context, prefix string, minAge int32, afterID int64, limit int) ([]*pb.User, error) {
+	query := g.db.WithContext(ctx).
+		Where("id > ?", afterID).
+		Where(`username LIKE ? ESCAPE '!' AND age >= ?`, escapeLike(prefix)+"%", minAge).
+		Order("id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var recs []userRecord
+	if err := query.Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	return recordsToProto(recs), nil
+}
+
+// recordsToProto 批量转换为 protobuf 消息
+func recordsToProto(recs []userRecord) []*pb.User {
+	users := make([]*pb.User, len(recs))
+	for i := range recs {
+		users[i] = recs[i].toProto()
+	}
+	return users
+}
+
+// gormStoreError 把 GORM 错误映射为存储错误
+func gormStoreError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrEmailTaken
+	default:
+		return err
+	}
+}
+
+// ====== 请求 ID 与日志拦截器 ======
+/*
+客户端在 metadata 中携带 x-request-id，服务端读取后放入处理器的 context，
+客户端和服务端的日志都带上同一个 request_id，便于串联一次调用的完整链路。
+
+  客户端 RequestIDUnaryClientInterceptor ──x-request-id──▶ 服务端 RequestIDUnaryInterceptor
+                                                              │
+                                                              ▼
+                                               LoggingUnaryInterceptor 记录 request_id
+
+客户端没有携带时由服务端生成，并通过响应 header 返回给客户端。
+*/
+
+// requestIDMetadataKey metadata 中请求 ID 的键（gRPC metadata 键必须小写）
+const requestIDMetadataKey = "x-request-id"
+
+// RequestIDFromContext 从处理器的 context 中读取请求 ID
+func RequestIDFromContext(ctx context.Context) string {
+	return logger.RequestIDFromContext(ctx)
+}
+
+// requestIDContext 从 incoming metadata 读取请求 ID（没有则生成），写入 context 和响应 header
+func requestIDContext(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadataKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = logger.NewRequestID()
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadataKey, id))
+	return logger.ContextWithRequestID(ctx, id)
+}
+
+// RequestIDUnaryInterceptor 一元 RPC 的请求 ID 拦截器
+func RequestIDUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(requestIDContext(ctx), req)
+}
+
+// RequestIDStreamInterceptor 流式 RPC 的请求 ID 拦截器
+func RequestIDStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: requestIDContext(ss.Context())})
+}
+
+// contextServerStream 替换 Context() 的 ServerStream
+// ServerStream 没有 WithContext 方法，只能包装
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// LoggingUnaryInterceptor 一元 RPC 的日志拦截器
+// 必须放在 RequestIDUnaryInterceptor 之后，日志才会带有 request_id
+func LoggingUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logRPC(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+// LoggingStreamInterceptor 流式 RPC 的日志拦截器
+func LoggingStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	logRPC(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+// logRPC 记录一次 RPC 的结果
+func logRPC(ctx context.Context, method string, start time.Time, err error) {
+	l := logger.WithContext(ctx)
+	args := []any{
+		"method", method,
+		"code", status.Code(err).String(),
+		"duration", time.Since(start).String(),
+	}
+	if err != nil {
+		l.Warn("rpc", append(args, "err", err)...)
+		return
+	}
+	l.Info("rpc", args...)
+}
+
+// ====== 监控指标拦截器 ======
+/*
+拦截器（Interceptor）相当于 gRPC 的中间件，可以在每次 RPC 前后执行逻辑。
+
+这里使用 Prometheus 记录：
+  - grpc_server_requests_total: 请求计数，按方法和状态码分类
+  - grpc_server_request_duration_seconds: 请求耗时直方图
+  - grpc_server_in_flight_requests: 正在处理的请求数
+
+安装：
+  go get -u github.com/prometheus/client_golang/prometheus
+*/
+
+var (
+	// rpcRequests 请求计数
+	rpcRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_server_requests_total",
+			Help: "Total number of RPCs handled, by method and status code.",
+		},
+		[]string{"method", "code"},
+	)
+
+	// rpcDuration 请求耗时
+	rpcDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "grpc_server_request_duration_seconds",
+			Help:    "RPC latency in seconds, by method.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method"},
+	)
+
+	// rpcInFlight 正在处理的请求数
+	rpcInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "grpc_server_in_flight_requests",
+			Help: "Number of RPCs currently being handled, by method.",
+		},
+		[]string{"method"},
+	)
+)
+
+func init() {
+	// 注册到默认注册表，promhttp.Handler() 会导出这些指标
+	prometheus.MustRegister(rpcRequests, rpcDuration, rpcInFlight)
+}
+
+// observeRPC 记录一次 RPC 的指标
+// 返回的函数在 RPC 结束时调用
+func observeRPC(method string) func(err error) {
+	start := time.Now()
+	rpcInFlight.WithLabelValues(method).Inc()
+
+	return func(err error) {
+		rpcInFlight.WithLabelValues(method).Dec()
+		rpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		// status.Code 对 nil 返回 codes.OK，对非 gRPC 错误返回 codes.Unknown
+		rpcRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+	}
+}
+
+// MetricsUnaryInterceptor 一元 RPC 的指标拦截器
+func MetricsUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	done := observeRPC(info.FullMethod)
+	resp, err := handler(ctx, req)
+	done(err)
+	return resp, err
+}
+
+// MetricsStreamInterceptor 流式 RPC 的指标拦截器
+// 耗时为整个流的持续时间
+func MetricsStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	done := observeRPC(info.FullMethod)
+	err := handler(srv, ss)
+	done(err)
+	return err
+}
+
+// startMetricsServer 启动独立的 HTTP 服务导出 /metrics
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	go func() {
+		log.Printf("指标服务启动，监听地址: %s/metrics", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("指标服务错误: %v", err)
+		}
+	}()
+}
+
+// ====== 截止时间拦截器 ======
+/*
+客户端没有设置截止时间时（例如 grpcurl 默认不设置），
+处理器可以无限期运行：下游数据库卡住时请求会一直堆积。
+
+DeadlineUnaryInterceptor 为没有截止时间的请求设置默认超时：
+
+  客户端设置了截止时间   → 保持不变（客户端比服务端更清楚自己能等多久）
+  客户端没有设置         → ctx 加上 defaultTimeout
+
+处理器和存储层都使用这个 ctx（GORM 通过 WithContext，内存存储检查 ctx.Err()），
+超时后查询被取消，处理器返回 DeadlineExceeded。
+处理器忽略 ctx 并在超时后才返回时，结果同样被替换为 DeadlineExceeded，
+避免客户端拿到一个服务端已经认为失败的响应。
+
+超时的请求额外记录到 grpc_server_deadline_exceeded_total，
+source 标签区分是客户端的截止时间（client）还是默认超时（default）。
+
+流式 RPC 通常是长连接，不适用默认超时。
+*/
+
+// defaultRPCTimeout 未指定 -default-timeout 时的默认超时
+const defaultRPCTimeout = 10 * time.Second
+
+// rpcDeadlineExceeded 超时的请求数
+var rpcDeadlineExceeded = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "grpc_server_deadline_exceeded_total",
+		Help: "Total number of RPCs that exceeded their deadline, by method and deadline source.",
+	},
+	[]string{"method", "source"},
+)
+
+func init() {
+	prometheus.MustRegister(rpcDeadlineExceeded)
+}
+
+// DeadlineUnaryInterceptor 为没有截止时间的一元 RPC 设置默认超时
+// defaultTimeout <= 0 时不设置默认超时，只记录超时的请求
+func DeadlineUnaryInterceptor(defaultTimeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		source := "client"
+		if _, ok := ctx.Deadline(); !ok && defaultTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+			defer cancel()
+			source = "default"
+		}
+
+		resp, err := handler(ctx, req)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			rpcDeadlineExceeded.WithLabelValues(info.FullMethod, source).Inc()
+			logger.WithContext(ctx).Warn("rpc deadline exceeded",
+				"method", info.FullMethod,
+				"source", source,
+			)
+			return nil, status.Error(codes.DeadlineExceeded, "Deadline exceeded")
+		}
+		return resp, err
+	}
+}
+
+// ====== 请求校验拦截器 ======
+/*
+请求消息实现了 validator 接口（见 proto/user_validate.go）时，
+拦截器在调用处理器之前执行 Validate，失败直接返回 codes.InvalidArgument，
+处理器不再需要逐个检查必填字段和格式。
+
+放在拦截器链的最后，被拒绝的请求同样会被记录日志和指标。
+流式 RPC 对客户端发来的每条消息执行校验。
+*/
+
+// validator 可以自我校验的请求消息
+type validator interface {
+	Validate() error
+}
+
+// validateRequest 执行请求的 Validate，错误统一转换为 InvalidArgument
+func validateRequest(req interface{}) error {
+	v, ok := req.(validator)
+	if !ok {
+		return nil
+	}
+	if err := v.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// ValidationUnaryInterceptor 一元 RPC 的请求校验拦截器
+func ValidationUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// ValidationStreamInterceptor 流式 RPC 的请求校验拦截器
+func ValidationStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	return handler(srv, &validatingServerStream{ServerStream: ss})
+}
+
+// validatingServerStream 在 RecvMsg 时校验每条消息
+type validatingServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validateRequest(m)
+}
+
 // ====== 辅助函数 ======
 
-// generateID 生成唯一 ID
-// 在实际应用中，应该使用数据库自增 ID 或 UUID
-var idCounter int64 = 0
+// normalizeEmail 校验并规范化邮箱
+// 使用 net/mail 解析，只接受纯地址（不接受 "Alice <a@x.com>"），并转为小写
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", status.Errorf(codes.InvalidArgument, "Invalid email address: %q", email)
+	}
+
+	return strings.ToLower(addr.Address), nil
+}
+
+// escapeLike 转义 LIKE 通配符，使用户名前缀与 MemoryUserStore 一样按字面匹配
+// 使用 ! 作转义字符，ESCAPE '!' 在 MySQL 和 SQLite 中写法相同
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
+// ====== 字段掩码 ======
+/*
+字符串字段的零值是 ""，服务端无法区分"没传"和"要清空"。
+UpdateUserRequest.update_fields 显式列出要更新的字段：
+
+  {id: 1, email: "new@example.com", update_fields: ["email"]}  // 只改邮箱
+  {id: 1, password: "", update_fields: ["password"]}           // 清空密码
+
+未列出的字段保持不变；出现未知字段时返回 InvalidArgument，整个请求不生效。
+update_fields 为空时退回旧行为：只更新非空字段。
 
-func generateID() int64 {
-	idCounter++
-	return idCounter
+用户名和邮箱的格式校验（包括"邮箱不允许清空"）在 UpdateUserRequest.Validate 中，
+由 ValidationUnaryInterceptor 在进入处理器之前执行。
+*/
+
+// updatableFields 可以出现在 update_fields 中的字段
+var updatableFields = []string{"username", "email", "password"}
+
+// applyUpdateMask 把请求中的字段写入 user
+// 先校验全部路径，再修改，失败时 user 不会被部分更新
+func applyUpdateMask(user *pb.User, req *pb.UpdateUserRequest) error {
+	paths := req.UpdateFields
+	if len(paths) == 0 {
+		paths = nonEmptyFields(req)
+	}
+
+	for _, path := range paths {
+		if !slices.Contains(updatableFields, path) {
+			return status.Errorf(codes.InvalidArgument,
+				"Unknown update field %q, allowed: %s", path, strings.Join(updatableFields, ", "))
+		}
+	}
+
+	var email string
+	if slices.Contains(paths, "email") {
+		var err error
+		if email, err = normalizeEmail(req.Email); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range paths {
+		switch path {
+		case "username":
+			user.Username = req.Username
+		case "email":
+			user.Email = email
+		case "password":
+			user.Password = req.Password
+		}
+	}
+	return nil
+}
+
+// nonEmptyFields 未指定字段掩码时，把非空字段视为要更新的字段
+func nonEmptyFields(req *pb.UpdateUserRequest) []string {
+	var paths []string
+	if req.Username != "" {
+		paths = append(paths, "username")
+	}
+	if req.Email != "" {
+		paths = append(paths, "email")
+	}
+	if req.Password != "" {
+		paths = append(paths, "password")
+	}
+	return paths
+}
+
+// newUserStore 根据 DSN 创建用户存储
+// nodeID 为 Snowflake 节点 ID，同时运行的每个实例必须不同
+func newUserStore(dsn string, nodeID int64) (UserStore, error) {
+	ids, err := idgen.New(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dsn == "" {
+		log.Println("使用内存用户存储")
+		return NewMemoryUserStore(ids), nil
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("连接数据库失败: %w", err)
+	}
+	log.Println("使用 GORM 用户存储")
+	return NewGormUserStore(db, ids)
 }
 
 // ====== 主函数 ======
 
+// newGRPCServer 创建配置好拦截器的 gRPC 服务器
+// defaultTimeout 为客户端未设置截止时间时的默认超时
+func newGRPCServer(defaultTimeout time.Duration) *grpc.Server {
+	// grpc.NewServer 创建新的 gRPC 服务器实例
+	return grpc.NewServer(
+		// 配置服务器选项（可选）
+		grpc.MaxRecvMsgSize(10*1024*1024), // 最大接收消息大小 10MB
+		grpc.MaxSendMsgSize(10*1024*1024), // 最大发送消息大小 10MB
+
+		// 注册拦截器
+		// 按顺序执行：请求 ID → 日志 → 指标 → 截止时间 → 校验
+		grpc.ChainUnaryInterceptor(RequestIDUnaryInterceptor, LoggingUnaryInterceptor,
+			MetricsUnaryInterceptor, DeadlineUnaryInterceptor(defaultTimeout), ValidationUnaryInterceptor),
+		grpc.ChainStreamInterceptor(RequestIDStreamInterceptor, LoggingStreamInterceptor,
+			MetricsStreamInterceptor, ValidationStreamInterceptor),
+	)
+}
+
 func main() {
 	// 1. 解析命令行参数
 	port := flag.Int("port", 50051, "gRPC 服务器端口")
+	metricsPort := flag.Int("metrics-port", 9090, "Prometheus 指标端口")
+	dsn := flag.String("dsn", "", "MySQL DSN，为空时使用内存存储")
+	nodeID := flag.Int64("node-id", 0, "ID 生成器节点 ID（0~1023），多实例部署时每个实例不同")
+	defaultTimeout := flag.Duration("default-timeout", defaultRPCTimeout, "客户端未设置截止时间时的默认超时，0 表示不限制")
+	fileDir := flag.String("file-dir", defaultFileDir, "UploadFile / DownloadFile 的存储目录")
+	maxFileSize := flag.String("max-file-size", humanize.FormatBytes(defaultMaxFileSize), "上传文件的大小上限，如 100MiB")
 	flag.Parse()
 
+	maxFileBytes, err := humanize.ParseBytes(*maxFileSize)
+	if err != nil {
+		log.Fatalf("-max-file-size: %v", err)
+	}
+
 	// 2. 创建监听器
 	addr := fmt.Sprintf(":%d", *port)
 	lis, err := net.Listen("tcp", addr)
@@ -242,16 +1304,20 @@ func main() {
 	log.Printf("gRPC 服务器启动，监听地址: %s", addr)
 
 	// 3. 创建 gRPC 服务器
-	// grpc.NewServer 创建新的 gRPC 服务器实例
-	s := grpc.NewServer(
-		// 4. 配置服务器选项（可选）
-		grpc.MaxRecvMsgSize(10*1024*1024), // 最大接收消息大小 10MB
-		grpc.MaxSendMsgSize(10*1024*1024), // 最大发送消息大小 10MB
-	)
+	s := newGRPCServer(*defaultTimeout)
+
+	// 启动指标服务
+	startMetricsServer(fmt.Sprintf(":%d", *metricsPort))
 
 	// 5. 注册服务
 	// 将服务实现注册到 gRPC 服务器
-	pb.RegisterUserServiceServer(s, NewServer())
+	store, err := newUserStore(*dsn, *nodeID)
+	if err != nil {
+		log.Fatalf("初始化用户存储失败: %v", err)
+	}
+	srv := NewServerWithStore(store)
+	srv.files = FileStorage{Dir: *fileDir, MaxSize: maxFileBytes}
+	pb.RegisterUserServiceServer(s, srv)
 
 	// 6. 启用反射（用于调试工具如 grpcurl）
 	reflection.Register(s)
@@ -0,0 +1,1140 @@
+// microservices/grpc_server_test.go
+// gRPC 服务端示例的测试
+//
+// microservices 目录下每个文件都是独立的示例程序（各有一个 main），需要按文件运行：
+//   go test microservices/grpc_server.go microservices/grpc_server_test.go
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/austoin/GolangTutorial/idgen"
+	"github.com/austoin/GolangTutorial/logger"
+	pb "github.com/austoin/GolangTutorial/microservices/proto"
+	"github.com/austoin/GolangTutorial/testfixtures"
+)
+
+// startTestServer 在 bufconn 上启动带完整拦截器链的服务端，返回连接它的客户端
+func startTestServer(t *testing.T, srv pb.UserServiceServer) pb.UserServiceClient {
+	t.Helper()
+	return startTestServerWithTimeout(t, srv, defaultRPCTimeout)
+}
+
+// startTestServerWithTimeout 同 startTestServer，使用指定的默认超时
+func startTestServerWithTimeout(t *testing.T, srv pb.UserServiceServer, defaultTimeout time.Duration) pb.UserServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := newGRPCServer(defaultTimeout)
+	pb.RegisterUserServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewUserServiceClient(conn)
+}
+
+// ====== 邮箱校验与规范化 ======
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"alice@example.com", "alice@example.com", false},
+		{"Alice@Example.COM", "alice@example.com", false},
+		{"  bob@example.com ", "bob@example.com", false},
+		{"not-an-email", "", true},
+		{"Alice <alice@example.com>", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeEmail(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeEmail(%q) = %q, %v, want %q (wantErr %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+		if err != nil && status.Code(err) != codes.InvalidArgument {
+			t.Errorf("normalizeEmail(%q) code = %v, want InvalidArgument", tt.in, status.Code(err))
+		}
+	}
+}
+
+func TestUserEmailRules(t *testing.T) {
+	client := startTestServer(t, NewServer())
+	ctx := context.Background()
+
+	alice, err := client.CreateUser(ctx, &pb.CreateUserRequest{Username: "alice", Email: "Alice@Example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser(alice) error = %v", err)
+	}
+	if got := alice.User.Email; got != "alice@example.com" {
+		t.Errorf("保存的邮箱 = %q, want alice@example.com", got)
+	}
+	bob, err := client.CreateUser(ctx, &pb.CreateUserRequest{Username: "bob", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser(bob) error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"创建时邮箱格式错误", func() error {
+			_, err := client.CreateUser(ctx, &pb.CreateUserRequest{Username: "carol", Email: "carol@"})
+			return err
+		}, codes.InvalidArgument},
+		{"创建时邮箱重复", func() error {
+			_, err := client.CreateUser(ctx, &pb.CreateUserRequest{Username: "carol", Email: "bob@example.com"})
+			return err
+		}, codes.AlreadyExists},
+		{"创建时邮箱只有大小写不同", func() error {
+			_, err := client.CreateUser(ctx, &pb.CreateUserRequest{Username: "carol", Email: "ALICE@example.com"})
+			return err
+		}, codes.AlreadyExists},
+		{"更新时邮箱格式错误", func() error {
+			_, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: bob.User.Id, Email: "bob at example.com"})
+			return err
+		}, codes.InvalidArgument},
+		{"更新为他人的邮箱", func() error {
+			_, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: bob.User.Id, Email: "Alice@EXAMPLE.com"})
+			return err
+		}, codes.AlreadyExists},
+		{"只修改自己邮箱的大小写", func() error {
+			_, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: bob.User.Id, Email: "BOB@example.com"})
+			return err
+		}, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Errorf("code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got, _ := client.GetUser(ctx, &pb.GetUserRequest{Id: bob.User.Id}); got.GetUser().GetEmail() != "bob@example.com" {
+		t.Errorf("bob 的邮箱 = %q, want 规范化后的 bob@example.com", got.GetUser().GetEmail())
+	}
+	// 失败的请求不应留下数据
+	if resp, _ := client.ListUsers(ctx, &pb.ListUsersRequest{}); resp.GetCount() != 2 {
+		t.Errorf("用户数 = %d, want 2", resp.GetCount())
+	}
+}
+
+// ====== 文件传输 ======
+
+// newFileTestServer 文件存储在临时目录中的服务端，返回客户端和存储目录
+func newFileTestServer(t *testing.T, maxSize int64) (pb.UserServiceClient, string) {
+	t.Helper()
+	srv := NewServer()
+	srv.files = FileStorage{Dir: t.TempDir(), MaxSize: maxSize}
+	return startTestServer(t, srv), srv.files.Dir
+}
+
+// Upload 消息的构造函数
+func infoMsg(name string, size int64) *pb.UploadFileRequest {
+	return &pb.UploadFileRequest{Payload: &pb.UploadFileRequest_Info{Info: &pb.FileInfo{Filename: name, Size: size}}}
+}
+
+func chunkMsg(data []byte) *pb.UploadFileRequest {
+	return &pb.UploadFileRequest{Payload: &pb.UploadFileRequest_Chunk{Chunk: data}}
+}
+
+func sumMsg(sum string) *pb.UploadFileRequest {
+	return &pb.UploadFileRequest{Payload: &pb.UploadFileRequest_Sha256{Sha256: sum}}
+}
+
+// sha256Hex 计算 data 的 SHA-256
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// upload 依次发送 msgs 后关闭发送端，返回服务端的响应
+func upload(t *testing.T, client pb.UserServiceClient, msgs ...*pb.UploadFileRequest) (*pb.UploadFileResponse, error) {
+	t.Helper()
+	stream, err := client.UploadFile(context.Background())
+	if err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	for _, m := range msgs {
+		// 服务端提前返回错误后 Send 返回 io.EOF，真正的错误由 CloseAndRecv 返回
+		if err := stream.Send(m); err != nil {
+			break
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// download 下载文件，返回文件信息、拼接后的数据、每个数据块的大小和校验和
+func download(t *testing.T, client pb.UserServiceClient, name string) (*pb.FileInfo, []byte, []int, string, error) {
+	t.Helper()
+	stream, err := client.DownloadFile(context.Background(), &pb.DownloadFileRequest{Filename: name})
+	if err != nil {
+		t.Fatalf("DownloadFile() error = %v", err)
+	}
+	var (
+		info   *pb.FileInfo
+		data   []byte
+		chunks []int
+		sum    string
+	)
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return info, data, chunks, sum, nil
+		}
+		if err != nil {
+			return nil, nil, nil, "", err
+		}
+		switch p := resp.Payload.(type) {
+		case *pb.DownloadFileResponse_Info:
+			info = p.Info
+		case *pb.DownloadFileResponse_Chunk:
+			data = append(data, p.Chunk...)
+			chunks = append(chunks, len(p.Chunk))
+		case *pb.DownloadFileResponse_Sha256:
+			sum = p.Sha256
+		}
+	}
+}
+
+// dirEntries 存储目录中的文件名（包括临时文件）
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestUploadDownloadRoundTrip(t *testing.T) {
+	client, dir := newFileTestServer(t, 1<<20)
+
+	// 超过 3 个下载数据块
+	data := make([]byte, 3*fileChunkSize+100)
+	rand.Read(data)
+	sum := sha256Hex(data)
+
+	resp, err := upload(t, client,
+		infoMsg("report.bin", int64(len(data))),
+		chunkMsg(data[:1000]), chunkMsg(data[1000:100000]), chunkMsg(data[100000:]),
+		sumMsg(strings.ToUpper(sum)), // 校验和不区分大小写
+	)
+	if err != nil {
+		t.Fatalf("upload error = %v", err)
+	}
+	if resp.Filename != "report.bin" || resp.Size != int64(len(data)) || resp.Sha256 != sum {
+		t.Errorf("上传结果 = %v, want report.bin, %d, %s", resp, len(data), sum)
+	}
+	if got := dirEntries(t, dir); !slices.Equal(got, []string{"report.bin"}) {
+		t.Errorf("存储目录 = %v, want 只有 report.bin（临时文件已重命名）", got)
+	}
+
+	info, got, chunks, gotSum, err := download(t, client, "report.bin")
+	if err != nil {
+		t.Fatalf("download error = %v", err)
+	}
+	if info.GetFilename() != "report.bin" || info.GetSize() != int64(len(data)) {
+		t.Errorf("文件信息 = %v", info)
+	}
+	if !bytes.Equal(got, data) || gotSum != sum {
+		t.Errorf("下载了 %d 字节, 校验和 %s, want %d 字节, %s", len(got), gotSum, len(data), sum)
+	}
+	if want := []int{fileChunkSize, fileChunkSize, fileChunkSize, 100}; !slices.Equal(chunks, want) {
+		t.Errorf("数据块大小 = %v, want %v", chunks, want)
+	}
+
+	// 同名文件被覆盖；未声明大小（0）时不检查
+	if _, err := upload(t, client, infoMsg("report.bin", 0), chunkMsg([]byte("v2")), sumMsg(sha256Hex([]byte("v2")))); err != nil {
+		t.Fatalf("覆盖上传 error = %v", err)
+	}
+	if _, got, _, _, _ := download(t, client, "report.bin"); string(got) != "v2" {
+		t.Errorf("覆盖后内容 = %q, want v2", got)
+	}
+
+	// 空文件
+	if _, err := upload(t, client, infoMsg("empty.txt", 0), sumMsg(sha256Hex(nil))); err != nil {
+		t.Fatalf("上传空文件 error = %v", err)
+	}
+	if info, got, _, gotSum, err := download(t, client, "empty.txt"); err != nil || info.GetSize() != 0 || len(got) != 0 || gotSum != sha256Hex(nil) {
+		t.Errorf("下载空文件 = %v, %d 字节, %s, %v", info, len(got), gotSum, err)
+	}
+}
+
+func TestUploadFileErrors(t *testing.T) {
+	data := []byte("hello, world")
+	sum := sha256Hex(data)
+	tests := []struct {
+		name string
+		msgs []*pb.UploadFileRequest
+		want codes.Code
+	}{
+		{"空上传", nil, codes.InvalidArgument},
+		{"第一条不是文件信息", []*pb.UploadFileRequest{chunkMsg(data), sumMsg(sum)}, codes.InvalidArgument},
+		{"路径穿越", []*pb.UploadFileRequest{infoMsg("../../etc/passwd", 0), chunkMsg(data), sumMsg(sum)}, codes.InvalidArgument},
+		{"包含目录", []*pb.UploadFileRequest{infoMsg("sub/target.txt", 0), chunkMsg(data), sumMsg(sum)}, codes.InvalidArgument},
+		{"反斜杠", []*pb.UploadFileRequest{infoMsg(`..\target.txt`, 0), chunkMsg(data), sumMsg(sum)}, codes.InvalidArgument},
+		{"隐藏文件", []*pb.UploadFileRequest{infoMsg(".bashrc", 0), chunkMsg(data), sumMsg(sum)}, codes.InvalidArgument},
+		{"空文件名", []*pb.UploadFileRequest{infoMsg("", 0), chunkMsg(data), sumMsg(sum)}, codes.InvalidArgument},
+		{"文件名过长", []*pb.UploadFileRequest{infoMsg(strings.Repeat("a", 256), 0), chunkMsg(data), sumMsg(sum)}, codes.InvalidArgument},
+		{"声明的大小超限", []*pb.UploadFileRequest{infoMsg("big.bin", 101), chunkMsg(data), sumMsg(sum)}, codes.ResourceExhausted},
+		{"实际大小超限", []*pb.UploadFileRequest{infoMsg("big.bin", 0), chunkMsg(make([]byte, 60)), chunkMsg(make([]byte, 60)), sumMsg(sum)}, codes.ResourceExhausted},
+		{"声明的大小不一致", []*pb.UploadFileRequest{infoMsg("target.txt", 5), chunkMsg(data), sumMsg(sum)}, codes.InvalidArgument},
+		{"校验和不一致", []*pb.UploadFileRequest{infoMsg("target.txt", 0), chunkMsg(data), sumMsg(sha256Hex([]byte("other")))}, codes.DataLoss},
+		{"缺少校验和", []*pb.UploadFileRequest{infoMsg("target.txt", 0), chunkMsg(data)}, codes.InvalidArgument},
+		{"校验和之后还有消息", []*pb.UploadFileRequest{infoMsg("target.txt", 0), chunkMsg(data), sumMsg(sum), chunkMsg(data)}, codes.InvalidArgument},
+		{"重复的文件信息", []*pb.UploadFileRequest{infoMsg("target.txt", 0), infoMsg("other.txt", 0), sumMsg(sum)}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, dir := newFileTestServer(t, 100)
+			// 已有的同名文件在上传失败时保持不变
+			if err := os.WriteFile(filepath.Join(dir, "target.txt"), []byte("original"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := upload(t, client, tt.msgs...); status.Code(err) != tt.want {
+				t.Fatalf("upload error = %v, want %v", err, tt.want)
+			}
+
+			// 临时文件已删除，目录之外没有写入任何文件
+			if got := dirEntries(t, dir); !slices.Equal(got, []string{"target.txt"}) {
+				t.Errorf("存储目录 = %v, want 只有原来的 target.txt", got)
+			}
+			if b, _ := os.ReadFile(filepath.Join(dir, "target.txt")); string(b) != "original" {
+				t.Errorf("target.txt = %q, want 不变", b)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "target.txt")); err == nil {
+				t.Error("文件被写到了存储目录之外")
+			}
+		})
+	}
+}
+
+func TestDownloadFileErrors(t *testing.T) {
+	client, dir := newFileTestServer(t, 1<<20)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// 指向目录之外的符号链接
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		want     codes.Code
+	}{
+		{"文件不存在", "missing.txt", codes.NotFound},
+		{"目录", "sub", codes.NotFound},
+		{"路径穿越", "../secret.txt", codes.InvalidArgument},
+		{"绝对路径", outside, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, _, err := download(t, client, tt.filename); status.Code(err) != tt.want {
+				t.Errorf("download(%q) error = %v, want %v", tt.filename, err, tt.want)
+			}
+		})
+	}
+
+	t.Run("符号链接不能越界", func(t *testing.T) {
+		_, data, _, _, err := download(t, client, "link.txt")
+		if err == nil || len(data) != 0 {
+			t.Errorf("download(link.txt) = %q, %v, want 错误且不返回内容", data, err)
+		}
+	})
+}
+
+// ====== 用户存储 ======
+
+// newTestStores 返回两种存储实现，GORM 存储使用内存 SQLite
+func newTestStores(t *testing.T) map[string]UserStore {
+	t.Helper()
+	ids, _ := idgen.New(0)
+	gormStore, err := NewGormUserStore(testfixtures.NewTestDB(t), ids)
+	if err != nil {
+		t.Fatalf("NewGormUserStore() error = %v", err)
+	}
+	return map[string]UserStore{
+		"memory": NewMemoryUserStore(ids),
+		"gorm":   gormStore,
+	}
+}
+
+func TestUserStoreUpdate(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			alice := &pb.User{Username: "alice", Email: "alice@example.com", Age: 30}
+			bob := &pb.User{Username: "bob", Email: "bob@example.com", Age: 25}
+			for _, u := range []*pb.User{alice, bob} {
+				if err := store.Create(ctx, u); err != nil {
+					t.Fatalf("Create(%s) error = %v", u.Username, err)
+				}
+			}
+
+			updated := &pb.User{Id: alice.Id, Username: "alice2", Email: "alice2@example.com", Age: 31}
+			if err := store.Update(ctx, updated); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if updated.CreatedAt != alice.CreatedAt || updated.UpdatedAt == 0 {
+				t.Errorf("Update() 回填 CreatedAt = %d, UpdatedAt = %d, want CreatedAt %d 且 UpdatedAt 非零",
+					updated.CreatedAt, updated.UpdatedAt, alice.CreatedAt)
+			}
+			got, err := store.Get(ctx, alice.Id)
+			if err != nil || got.Username != "alice2" || got.Email != "alice2@example.com" || got.Age != 31 {
+				t.Errorf("Get() = %+v, %v, want 更新后的字段", got, err)
+			}
+
+			// 字段没有变化时不能误报用户不存在
+			same := &pb.User{Id: alice.Id, Username: "alice2", Email: "alice2@example.com", Age: 31}
+			if err := store.Update(ctx, same); err != nil {
+				t.Errorf("Update(未变化) error = %v, want nil", err)
+			}
+
+			if err := store.Update(ctx, &pb.User{Id: 999, Username: "x", Email: "x@example.com"}); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("Update(999) error = %v, want ErrUserNotFound", err)
+			}
+
+			taken := &pb.User{Id: bob.Id, Username: "bob", Email: "alice2@example.com", Age: 25}
+			if err := store.Update(ctx, taken); !errors.Is(err, ErrEmailTaken) {
+				t.Errorf("Update(他人邮箱) error = %v, want ErrEmailTaken", err)
+			}
+			if got, _ := store.Get(ctx, bob.Id); got.GetEmail() != "bob@example.com" {
+				t.Errorf("冲突后 bob 的邮箱 = %q, want 保持 bob@example.com", got.GetEmail())
+			}
+		})
+	}
+}
+
+func TestUserStoreSearchLiteralPrefix(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			// 用户名中含有 LIKE 通配符和转义字符，两种存储都按字面前缀匹配
+			for _, username := range []string{"50%off", "50xoff", "a_b", "axb", "c!d", "cd"} {
+				if err := store.Create(ctx, &pb.User{Username: username, Email: username + "@example.com", Age: 20}); err != nil {
+					t.Fatalf("Create(%s) error = %v", username, err)
+				}
+			}
+
+			tests := []struct {
+				prefix string
+				want   []string
+			}{
+				{"50%", []string{"50%off"}},
+				{"a_", []string{"a_b"}},
+				{"c!", []string{"c!d"}},
+				{"a", []string{"a_b", "axb"}},
+			}
+			for _, tt := range tests {
+				users, err := store.Search(ctx, tt.prefix, 0, 0, 0)
+				if err != nil {
+					t.Fatalf("Search(%q) error = %v", tt.prefix, err)
+				}
+				var got []string
+				for _, u := range users {
+					got = append(got, u.Username)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("Search(%q) = %v, want %v", tt.prefix, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestUserStoreSearchFilters(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			// ID 按创建顺序递增
+			for _, u := range []struct {
+				username string
+				age      int32
+			}{
+				{"alice", 30}, {"alan", 17}, {"bob", 50}, {"albert", 45}, {"carl", 18},
+			} {
+				if err := store.Create(ctx, &pb.User{Username: u.username, Email: u.username + "@example.com", Age: u.age}); err != nil {
+					t.Fatalf("Create(%s) error = %v", u.username, err)
+				}
+			}
+
+			tests := []struct {
+				name   string
+				prefix string
+				minAge int32
+				want   []string
+			}{
+				{"前缀匹配但年龄不够", "al", 18, []string{"alice", "albert"}},
+				{"年龄满足但前缀不匹配", "al", 46, nil},
+				{"只按年龄过滤", "", 45, []string{"bob", "albert"}},
+				{"年龄下限包含边界", "c", 18, []string{"carl"}},
+				{"不过滤", "", 0, []string{"alice", "alan", "bob", "albert", "carl"}},
+			}
+			for _, tt := range tests {
+				users, err := store.Search(ctx, tt.prefix, tt.minAge, 0, 0)
+				if err != nil {
+					t.Fatalf("Search() error = %v", err)
+				}
+				if got := usernames(users); !slices.Equal(got, tt.want) {
+					t.Errorf("%s: Search(%q, %d) = %v, want %v", tt.name, tt.prefix, tt.minAge, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestUserStoreSearchPaging(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var want []string
+			for i := range 8 {
+				username := fmt.Sprintf("user%d", i)
+				// 奇数用户未成年，被年龄过滤掉
+				age := int32(20)
+				if i%2 == 1 {
+					age = 10
+				} else {
+					want = append(want, username)
+				}
+				if err := store.Create(ctx, &pb.User{Username: username, Email: username + "@example.com", Age: age}); err != nil {
+					t.Fatalf("Create(%s) error = %v", username, err)
+				}
+			}
+
+			// 每页 3 个，用上一页最后一个 ID 作为游标
+			var pages [][]string
+			var afterID int64
+			for {
+				users, err := store.Search(ctx, "user", 18, afterID, 3)
+				if err != nil {
+					t.Fatalf("Search(afterID=%d) error = %v", afterID, err)
+				}
+				if len(users) > 3 {
+					t.Fatalf("Search(limit=3) 返回 %d 个", len(users))
+				}
+				if len(users) == 0 {
+					break
+				}
+				pages = append(pages, usernames(users))
+				afterID = users[len(users)-1].Id
+			}
+
+			if len(pages) != 2 || len(pages[0]) != 3 || len(pages[1]) != 1 {
+				t.Errorf("分页结果 = %v, want 3 + 1 个", pages)
+			}
+			if got := slices.Concat(pages...); !slices.Equal(got, want) {
+				t.Errorf("所有页拼接 = %v, want %v（不重复、不遗漏）", got, want)
+			}
+		})
+	}
+}
+
+// usernames 按顺序返回用户名
+func usernames(users []*pb.User) []string {
+	var names []string
+	for _, u := range users {
+		names = append(names, u.Username)
+	}
+	return names
+}
+
+// countingStore 记录 Search 的调用次数
+type countingStore struct {
+	UserStore
+	searches atomic.Int32
+}
+
+func (s *countingStore) Search(ctx context.Context, prefix string, minAge int32, afterID int64, limit int) ([]*pb.User, error) {
+	s.searches.Add(1)
+	return s.UserStore.Search(ctx, prefix, minAge, afterID, limit)
+}
+
+func TestSearchUsersPages(t *testing.T) {
+	srv := NewServer()
+	store := &countingStore{UserStore: srv.store}
+	srv.store = store
+	client := startTestServer(t, srv)
+	ctx := context.Background()
+
+	// 超过两页的匹配用户，中间穿插不匹配的用户
+	n := 2*searchPageSize + 5
+	var wantIDs []int64
+	for i := range n {
+		u := &pb.User{Username: fmt.Sprintf("match%03d", i), Email: fmt.Sprintf("m%d@example.com", i), Age: 20}
+		if err := store.Create(ctx, u); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		wantIDs = append(wantIDs, u.Id)
+		if i%50 == 0 {
+			other := &pb.User{Username: fmt.Sprintf("other%d", i), Email: fmt.Sprintf("o%d@example.com", i), Age: 20}
+			if err := store.Create(ctx, other); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+	}
+
+	stream, err := client.SearchUsers(ctx, &pb.SearchUsersRequest{UsernamePrefix: "match"})
+	if err != nil {
+		t.Fatalf("SearchUsers() error = %v", err)
+	}
+	var gotIDs []int64
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		gotIDs = append(gotIDs, resp.User.Id)
+	}
+
+	// 每个用户恰好发送一次，按 ID 升序
+	if !slices.Equal(gotIDs, wantIDs) {
+		t.Errorf("收到 %d 个用户, want %d 个且按 ID 升序、不重复", len(gotIDs), len(wantIDs))
+	}
+	if got := store.searches.Load(); got != 3 {
+		t.Errorf("Search 调用了 %d 次, want 3（每页 %d 个）", got, searchPageSize)
+	}
+}
+
+// ====== 请求 ID 与日志拦截器 ======
+
+// syncBuffer 并发安全的日志缓冲区，拦截器在服务端 goroutine 中写日志
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// rpcLogRequestIDs 返回 "rpc" 日志中每个方法的 request_id
+func (b *syncBuffer) rpcLogRequestIDs(t *testing.T) map[string]string {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ids := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var entry struct {
+			Msg       string `json:"msg"`
+			Method    string `json:"method"`
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("日志不是 JSON: %q", line)
+		}
+		if entry.Msg == "rpc" {
+			ids[entry.Method] = entry.RequestID
+		}
+	}
+	return ids
+}
+
+// captureLogs 测试期间把默认日志器换成写入缓冲区的 JSON 日志器
+func captureLogs(t *testing.T) *syncBuffer {
+	t.Helper()
+	saved := logger.L()
+	t.Cleanup(func() { logger.SetDefault(saved) })
+
+	buf := &syncBuffer{}
+	logger.SetDefault(logger.New(logger.Config{Format: "json", Output: buf}))
+	return buf
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	logs := captureLogs(t)
+	client := startTestServer(t, NewServer())
+
+	// 一元调用：客户端带上 x-request-id，响应 header 和服务端日志中是同一个 ID
+	ctx := metadata.AppendToOutgoingContext(context.Background(), requestIDMetadataKey, "req-unary")
+	var header metadata.MD
+	created, err := client.CreateUser(ctx, &pb.CreateUserRequest{Username: "alice", Email: "alice@example.com"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if got := header.Get(requestIDMetadataKey); len(got) != 1 || got[0] != "req-unary" {
+		t.Errorf("响应 header x-request-id = %q, want [req-unary]", got)
+	}
+
+	// 没有携带时服务端生成，并通过 header 返回
+	var generated metadata.MD
+	if _, err := client.GetUser(context.Background(), &pb.GetUserRequest{Id: created.User.Id}, grpc.Header(&generated)); err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	genID := generated.Get(requestIDMetadataKey)
+	if len(genID) != 1 || genID[0] == "" {
+		t.Fatalf("服务端没有生成 x-request-id: %q", genID)
+	}
+
+	// 流式调用
+	ctx = metadata.AppendToOutgoingContext(context.Background(), requestIDMetadataKey, "req-stream")
+	stream, err := client.SearchUsers(ctx, &pb.SearchUsersRequest{UsernamePrefix: "a"})
+	if err != nil {
+		t.Fatalf("SearchUsers() error = %v", err)
+	}
+	streamHeader, err := stream.Header()
+	if err != nil {
+		t.Fatalf("Header() error = %v", err)
+	}
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+	if got := streamHeader.Get(requestIDMetadataKey); len(got) != 1 || got[0] != "req-stream" {
+		t.Errorf("流式响应 header x-request-id = %q, want [req-stream]", got)
+	}
+
+	want := map[string]string{
+		"/proto.UserService/CreateUser":  "req-unary",
+		"/proto.UserService/GetUser":     genID[0],
+		"/proto.UserService/SearchUsers": "req-stream",
+	}
+	if got := logs.rpcLogRequestIDs(t); !maps.Equal(got, want) {
+		t.Errorf("日志中的 request_id = %v, want %v", got, want)
+	}
+}
+
+// ====== 监控指标拦截器 ======
+
+// histogramCount 直方图中某个方法的样本数
+func histogramCount(t *testing.T, method string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := rpcDuration.WithLabelValues(method).(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatalf("读取直方图失败: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestMetricsInterceptors(t *testing.T) {
+	client := startTestServer(t, NewServer())
+	ctx := context.Background()
+
+	const (
+		createMethod = "/proto.UserService/CreateUser"
+		getMethod    = "/proto.UserService/GetUser"
+		searchMethod = "/proto.UserService/SearchUsers"
+	)
+	// 指标是全局的，用差值断言，不受其他测试影响
+	counter := func(method string, code codes.Code) float64 {
+		return testutil.ToFloat64(rpcRequests.WithLabelValues(method, code.String()))
+	}
+	createOK := counter(createMethod, codes.OK)
+	getOK := counter(getMethod, codes.OK)
+	getNotFound := counter(getMethod, codes.NotFound)
+	searchOK := counter(searchMethod, codes.OK)
+	getSamples := histogramCount(t, getMethod)
+
+	created, err := client.CreateUser(ctx, &pb.CreateUserRequest{Username: "alice", Email: "alice@example.com", Age: 30})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if _, err := client.GetUser(ctx, &pb.GetUserRequest{Id: created.User.Id}); err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if _, err := client.GetUser(ctx, &pb.GetUserRequest{Id: 999}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetUser(999) code = %v, want NotFound", status.Code(err))
+	}
+	stream, err := client.SearchUsers(ctx, &pb.SearchUsersRequest{UsernamePrefix: "a"})
+	if err != nil {
+		t.Fatalf("SearchUsers() error = %v", err)
+	}
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		code   codes.Code
+		before float64
+	}{
+		{"CreateUser OK", createMethod, codes.OK, createOK},
+		{"GetUser OK", getMethod, codes.OK, getOK},
+		{"GetUser NotFound", getMethod, codes.NotFound, getNotFound},
+		{"SearchUsers OK（流式）", searchMethod, codes.OK, searchOK},
+	}
+	for _, tt := range tests {
+		if got := counter(tt.method, tt.code) - tt.before; got != 1 {
+			t.Errorf("%s 计数增加 %v, want 1", tt.name, got)
+		}
+	}
+	if got := histogramCount(t, getMethod) - getSamples; got != 2 {
+		t.Errorf("GetUser 耗时样本增加 %d, want 2", got)
+	}
+	// 所有 RPC 都已结束
+	if got := testutil.ToFloat64(rpcInFlight.WithLabelValues(getMethod)); got != 0 {
+		t.Errorf("GetUser 正在处理的请求数 = %v, want 0", got)
+	}
+}
+
+// ====== 截止时间拦截器 ======
+
+// slowStore Get 等待 delay 后返回；ignoreCtx 为 true 时不理会 ctx 的取消
+type slowStore struct {
+	UserStore
+	delay     time.Duration
+	ignoreCtx bool
+}
+
+func (s *slowStore) Get(ctx context.Context, id int64) (*pb.User, error) {
+	if s.ignoreCtx {
+		time.Sleep(s.delay)
+		return s.UserStore.Get(context.Background(), id)
+	}
+	select {
+	case <-time.After(s.delay):
+		return s.UserStore.Get(ctx, id)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestDeadlineInterceptor(t *testing.T) {
+	const method = "/proto.UserService/GetUser"
+	deadlineCount := func(source string) float64 {
+		return testutil.ToFloat64(rpcDeadlineExceeded.WithLabelValues(method, source))
+	}
+
+	tests := []struct {
+		name          string
+		delay         time.Duration
+		ignoreCtx     bool
+		clientTimeout time.Duration // 0 表示客户端不设置截止时间
+		wantCode      codes.Code
+		wantSource    string // 服务端记录超时的 source 标签，空表示不记录
+	}{
+		{"快速请求成功", 0, false, 0, codes.OK, ""},
+		{"没有截止时间时使用默认超时", time.Second, false, 0, codes.DeadlineExceeded, "default"},
+		{"处理器忽略 ctx 时结果被替换", 200 * time.Millisecond, true, 0, codes.DeadlineExceeded, "default"},
+		{"保留客户端更长的截止时间", 200 * time.Millisecond, false, 2 * time.Second, codes.OK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, _ := idgen.New(0)
+			base := NewMemoryUserStore(ids)
+			user := &pb.User{Username: "alice", Email: "alice@example.com"}
+			if err := base.Create(context.Background(), user); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			store := &slowStore{UserStore: base, delay: tt.delay, ignoreCtx: tt.ignoreCtx}
+			client := startTestServerWithTimeout(t, NewServerWithStore(store), 50*time.Millisecond)
+			before := map[string]float64{"default": deadlineCount("default"), "client": deadlineCount("client")}
+
+			ctx := context.Background()
+			if tt.clientTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.clientTimeout)
+				defer cancel()
+			}
+			resp, err := client.GetUser(ctx, &pb.GetUserRequest{Id: user.Id})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("GetUser() code = %v, want %v (err = %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode == codes.OK && resp.User.Username != "alice" {
+				t.Errorf("GetUser() = %v, want alice", resp.User)
+			}
+
+			for source, n := range before {
+				want := n
+				if source == tt.wantSource {
+					want++
+				}
+				if got := deadlineCount(source); got != want {
+					t.Errorf("source=%s 超时计数 = %v, want %v", source, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeadlineInterceptorClientDeadline(t *testing.T) {
+	// 通过网络时客户端的 RST_STREAM 可能先到，服务端看到的是 Canceled，这里直接调用拦截器
+	const method = "/proto.UserService/GetUser"
+	before := testutil.ToFloat64(rpcDeadlineExceeded.WithLabelValues(method, "client"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	var got time.Time
+	_, err := DeadlineUnaryInterceptor(time.Hour)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			got, _ = ctx.Deadline()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("error = %v, want DeadlineExceeded", err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("处理器看到的截止时间 = %v, want 客户端的 %v", got, want)
+	}
+	if n := testutil.ToFloat64(rpcDeadlineExceeded.WithLabelValues(method, "client")) - before; n != 1 {
+		t.Errorf("source=client 超时计数增加 %v, want 1", n)
+	}
+}
+
+// ====== 请求校验拦截器 ======
+
+// recordingServer 记录 CreateUser / UpdateUser 处理器是否被调用
+type recordingServer struct {
+	*server
+	calls atomic.Int32
+}
+
+func (s *recordingServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	s.calls.Add(1)
+	return s.server.CreateUser(ctx, req)
+}
+
+func (s *recordingServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	s.calls.Add(1)
+	return s.server.UpdateUser(ctx, req)
+}
+
+func TestValidationUnaryInterceptor(t *testing.T) {
+	srv := &recordingServer{server: NewServer()}
+	client := startTestServer(t, srv)
+	ctx := context.Background()
+
+	create := func(username, email string, age int32) func() error {
+		return func() error {
+			_, err := client.CreateUser(ctx, &pb.CreateUserRequest{Username: username, Email: email, Password: "secret", Age: age})
+			return err
+		}
+	}
+	update := func(req *pb.UpdateUserRequest) func() error {
+		return func() error {
+			_, err := client.UpdateUser(ctx, req)
+			return err
+		}
+	}
+
+	tests := []struct {
+		name    string
+		call    func() error
+		wantMsg string
+	}{
+		{"用户名太短", create("ab", "ab@example.com", 20), "Username must be 3-32 characters"},
+		{"用户名太长", create(strings.Repeat("a", 33), "long@example.com", 20), "Username must be 3-32 characters"},
+		{"年龄为 -1", create("alice", "alice@example.com", -1), "Age must be between 0 and 150"},
+		{"年龄为 151", create("alice", "alice@example.com", 151), "Age must be between 0 and 150"},
+		{"邮箱格式错误", create("alice", "not-an-email", 20), "Invalid email address"},
+		{"更新时缺少 ID", update(&pb.UpdateUserRequest{Username: "alice"}), "User ID is required"},
+		{"更新时清空邮箱", update(&pb.UpdateUserRequest{Id: 1, UpdateFields: []string{"email"}}), "Email cannot be cleared"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.calls.Store(0)
+			err := tt.call()
+			if status.Code(err) != codes.InvalidArgument || !strings.Contains(status.Convert(err).Message(), tt.wantMsg) {
+				t.Errorf("error = %v, want InvalidArgument %q", err, tt.wantMsg)
+			}
+			// 被拒绝的请求不会进入处理器
+			if n := srv.calls.Load(); n != 0 {
+				t.Errorf("处理器被调用了 %d 次, want 0", n)
+			}
+		})
+	}
+
+	// 合法请求正常进入处理器
+	srv.calls.Store(0)
+	if err := create("alice", "alice@example.com", 20)(); err != nil {
+		t.Fatalf("CreateUser(合法请求) error = %v", err)
+	}
+	if n := srv.calls.Load(); n != 1 {
+		t.Errorf("合法请求处理器被调用了 %d 次, want 1", n)
+	}
+}
+
+// fakeServerStream 依次返回 msgs 中的消息，之后返回 io.EOF
+type fakeServerStream struct {
+	grpc.ServerStream
+	msgs []proto.Message
+}
+
+func (s *fakeServerStream) Context() context.Context { return context.Background() }
+
+func (s *fakeServerStream) RecvMsg(m interface{}) error {
+	if len(s.msgs) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.msgs[0])
+	s.msgs = s.msgs[1:]
+	return nil
+}
+
+func TestValidationStreamInterceptor(t *testing.T) {
+	ss := &fakeServerStream{msgs: []proto.Message{
+		&pb.CreateUserRequest{Username: "alice", Email: "alice@example.com"},
+		&pb.CreateUserRequest{Username: "ab", Email: "ab@example.com"},
+		&pb.CreateUserRequest{Username: "carol", Email: "carol@example.com"},
+	}}
+
+	// 处理器逐条接收，校验失败时停止，和真实的流式处理器一样把错误返回给客户端
+	var accepted []string
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		for {
+			var req pb.CreateUserRequest
+			if err := stream.RecvMsg(&req); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			accepted = append(accepted, req.Username)
+		}
+	}
+
+	err := ValidationStreamInterceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: "/test/Stream"}, handler)
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "Username must be 3-32 characters") {
+		t.Errorf("ValidationStreamInterceptor() error = %v, want InvalidArgument", err)
+	}
+	if !slices.Equal(accepted, []string{"alice"}) {
+		t.Errorf("处理器收到 %v, want 只有校验通过的 alice", accepted)
+	}
+
+	// 没有 Validate 方法的消息原样放行
+	ss = &fakeServerStream{msgs: []proto.Message{&pb.ChatRequest{UserId: 1, Message: ""}}}
+	err = ValidationStreamInterceptor(nil, ss, &grpc.StreamServerInfo{}, func(srv interface{}, stream grpc.ServerStream) error {
+		var req pb.ChatRequest
+		return stream.RecvMsg(&req)
+	})
+	if err != nil {
+		t.Errorf("ChatRequest 不需要校验, error = %v", err)
+	}
+}
+
+// ====== 字段掩码 ======
+
+func TestApplyUpdateMask(t *testing.T) {
+	base := func() *pb.User {
+		return &pb.User{Id: 1, Username: "alice", Email: "alice@example.com", Password: "secret"}
+	}
+
+	tests := []struct {
+		name     string
+		req      *pb.UpdateUserRequest
+		want     *pb.User
+		wantCode codes.Code
+	}{
+		{"只更新邮箱",
+			&pb.UpdateUserRequest{Username: "ignored", Email: "New@Example.com", UpdateFields: []string{"email"}},
+			&pb.User{Id: 1, Username: "alice", Email: "new@example.com", Password: "secret"}, codes.OK},
+		{"显式清空密码",
+			&pb.UpdateUserRequest{UpdateFields: []string{"password"}},
+			&pb.User{Id: 1, Username: "alice", Email: "alice@example.com"}, codes.OK},
+		{"没有掩码时只更新非空字段",
+			&pb.UpdateUserRequest{Username: "alice2"},
+			&pb.User{Id: 1, Username: "alice2", Email: "alice@example.com", Password: "secret"}, codes.OK},
+		{"未知字段", &pb.UpdateUserRequest{Username: "x", UpdateFields: []string{"username", "age"}}, nil, codes.InvalidArgument},
+		{"邮箱格式错误", &pb.UpdateUserRequest{Username: "x", Email: "bad", UpdateFields: []string{"username", "email"}}, nil, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := base()
+			err := applyUpdateMask(user, tt.req)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("applyUpdateMask() code = %v, want %v (err %v)", got, tt.wantCode, err)
+			}
+			want := tt.want
+			if want == nil {
+				want = base() // 失败时不会部分更新
+			}
+			if !proto.Equal(user, want) {
+				t.Errorf("user = %v, want %v", user, want)
+			}
+		})
+	}
+}
+
+func TestUpdateUserMask(t *testing.T) {
+	client := startTestServer(t, NewServer())
+	ctx := context.Background()
+
+	created, err := client.CreateUser(ctx, &pb.CreateUserRequest{Username: "alice", Email: "alice@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	id := created.User.Id
+
+	if _, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: id, UpdateFields: []string{"nickname"}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("未知字段 code = %v, want InvalidArgument", status.Code(err))
+	}
+
+	resp, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: id, UpdateFields: []string{"password"}})
+	if err != nil {
+		t.Fatalf("UpdateUser(清空密码) error = %v", err)
+	}
+	if resp.User.Password != "" || resp.User.Username != "alice" {
+		t.Errorf("UpdateUser() = %v, want 密码清空、用户名不变", resp.User)
+	}
+
+	got, _ := client.GetUser(ctx, &pb.GetUserRequest{Id: id})
+	if got.GetUser().GetPassword() != "" || got.GetUser().GetEmail() != "alice@example.com" {
+		t.Errorf("保存的用户 = %v", got.GetUser())
+	}
+}
@@ -0,0 +1,102 @@
+// microservices/proto/user_validate.go
+// 请求校验 - 详细注释版
+
+package proto
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// ====== 请求校验 ======
+/*
+与 user.pb.go 放在同一个包中，为生成的消息类型补充 Validate 方法。
+生成代码会被覆盖，手写的方法必须放在单独的文件里。
+
+服务端的 ValidationUnaryInterceptor 在调用处理器之前执行 Validate，
+失败时返回 codes.InvalidArgument，处理器只需要关心业务逻辑。
+
+规则：
+  - username：3~32 个字符
+  - email：合法的纯地址（不接受 "Alice <a@x.com>"）
+  - age：0~150
+*/
+
+const (
+	minUsernameLen = 3
+	maxUsernameLen = 32
+	maxAge         = 150
+)
+
+// Validate 校验创建用户请求
+func (r *CreateUserRequest) Validate() error {
+	if err := validateUsername(r.GetUsername()); err != nil {
+		return err
+	}
+	if err := validateEmail(r.GetEmail()); err != nil {
+		return err
+	}
+	if age := r.GetAge(); age < 0 || age > maxAge {
+		return fmt.Errorf("Age must be between 0 and %d", maxAge)
+	}
+	return nil
+}
+
+// Validate 校验更新用户请求
+// 只校验要更新的字段：有 update_fields 时为其中列出的字段，否则为非空字段
+// update_fields 中的未知字段由服务端处理
+func (r *UpdateUserRequest) Validate() error {
+	if r.GetId() == 0 {
+		return errors.New("User ID is required")
+	}
+
+	updates := func(field, value string) bool {
+		if len(r.GetUpdateFields()) > 0 {
+			return slices.Contains(r.GetUpdateFields(), field)
+		}
+		return value != ""
+	}
+
+	if updates("username", r.GetUsername()) {
+		if err := validateUsername(r.GetUsername()); err != nil {
+			return err
+		}
+	}
+	if updates("email", r.GetEmail()) {
+		if r.GetEmail() == "" {
+			return errors.New("Email cannot be cleared")
+		}
+		if err := validateEmail(r.GetEmail()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateUsername 按字符数（不是字节数）校验用户名长度
+func validateUsername(username string) error {
+	if username == "" {
+		return errors.New("Username is required")
+	}
+	if n := utf8.RuneCountInString(username); n < minUsernameLen || n > maxUsernameLen {
+		return fmt.Errorf("Username must be %d-%d characters", minUsernameLen, maxUsernameLen)
+	}
+	return nil
+}
+
+// validateEmail 校验邮箱格式，允许首尾空白（服务端规范化时会去掉）
+func validateEmail(email string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return errors.New("Email is required")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("Invalid email address: %q", email)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// microservices/proto/user_validate_test.go
+// 请求校验的测试
+
+package proto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateUserRequestValidate(t *testing.T) {
+	valid := func() *CreateUserRequest {
+		return &CreateUserRequest{Username: "alice", Email: "alice@example.com", Password: "secret", Age: 30}
+	}
+	tests := []struct {
+		name    string
+		modify  func(r *CreateUserRequest)
+		wantErr string // 为空表示校验通过
+	}{
+		{"合法请求", func(r *CreateUserRequest) {}, ""},
+		{"用户名最短 3 个字符", func(r *CreateUserRequest) { r.Username = "abc" }, ""},
+		{"用户名按字符计数", func(r *CreateUserRequest) { r.Username = "张三丰" }, ""},
+		{"用户名最长 32 个字符", func(r *CreateUserRequest) { r.Username = strings.Repeat("a", 32) }, ""},
+		{"年龄上限 150", func(r *CreateUserRequest) { r.Age = 150 }, ""},
+		{"邮箱首尾空白", func(r *CreateUserRequest) { r.Email = " alice@example.com " }, ""},
+		{"缺少用户名", func(r *CreateUserRequest) { r.Username = "" }, "Username is required"},
+		{"用户名太短", func(r *CreateUserRequest) { r.Username = "ab" }, "Username must be 3-32 characters"},
+		{"用户名太长", func(r *CreateUserRequest) { r.Username = strings.Repeat("a", 33) }, "Username must be 3-32 characters"},
+		{"缺少邮箱", func(r *CreateUserRequest) { r.Email = "  " }, "Email is required"},
+		{"邮箱格式错误", func(r *CreateUserRequest) { r.Email = "not-an-email" }, "Invalid email address"},
+		{"邮箱带显示名", func(r *CreateUserRequest) { r.Email = "Alice <alice@example.com>" }, "Invalid email address"},
+		{"年龄为负数", func(r *CreateUserRequest) { r.Age = -1 }, "Age must be between 0 and 150"},
+		{"年龄超过 150", func(r *CreateUserRequest) { r.Age = 151 }, "Age must be between 0 and 150"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.modify(r)
+			checkValidate(t, r.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestUpdateUserRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *UpdateUserRequest
+		wantErr string
+	}{
+		{"缺少 ID", &UpdateUserRequest{Username: "alice"}, "User ID is required"},
+		{"没有要更新的字段", &UpdateUserRequest{Id: 1}, ""},
+		{"只校验非空字段", &UpdateUserRequest{Id: 1, Email: "alice@example.com"}, ""},
+		{"非空的用户名太短", &UpdateUserRequest{Id: 1, Username: "ab"}, "Username must be 3-32 characters"},
+		{"非空的邮箱格式错误", &UpdateUserRequest{Id: 1, Email: "bad"}, "Invalid email address"},
+		{
+			"掩码中的邮箱不能清空",
+			&UpdateUserRequest{Id: 1, Username: "alice", UpdateFields: []string{"email"}},
+			"Email cannot be cleared",
+		},
+		{
+			"掩码中的用户名不能清空",
+			&UpdateUserRequest{Id: 1, UpdateFields: []string{"username"}},
+			"Username is required",
+		},
+		{
+			"掩码外的字段不校验",
+			&UpdateUserRequest{Id: 1, Username: "ab", Email: "alice@example.com", UpdateFields: []string{"email"}},
+			"",
+		},
+		{"清空密码", &UpdateUserRequest{Id: 1, UpdateFields: []string{"password"}}, ""},
+		{"未知字段留给服务端处理", &UpdateUserRequest{Id: 1, UpdateFields: []string{"nickname"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkValidate(t, tt.req.Validate(), tt.wantErr)
+		})
+	}
+}
+
+// checkValidate wantErr 为空时要求校验通过，否则要求错误信息包含 wantErr
+func checkValidate(t *testing.T, err error, wantErr string) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil {
+			t.Errorf("Validate() error = %v, want nil", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Errorf("Validate() error = %v, want 包含 %q", err, wantErr)
+	}
+}
@@ -4,10 +4,20 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/austoin/GolangTutorial/bind"
+	"github.com/austoin/GolangTutorial/decompress"
+	"github.com/austoin/GolangTutorial/logger"
+	"github.com/austoin/GolangTutorial/validate"
 )
 
 // ====== HTTP 服务器基础 ======
@@ -71,11 +81,36 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 	`, time.Now().Format("2006-01-02 15:04:05"))
 }
 
+// HelloRequest POST /hello 的请求体
+type HelloRequest struct {
+	Name string `json:"name" validate:"required,min=2,max=50"`
+}
+
+// HelloQuery GET /hello 的查询参数，name 可以省略
+type HelloQuery struct {
+	Name string `query:"name" validate:"max=50"`
+}
+
 // 处理 /hello 路径的请求
+// GET 从查询参数读取 name，POST 从 JSON 请求体读取并验证
 func helloHandler(w http.ResponseWriter, r *http.Request) {
-	// 获取查询参数 - r.URL.Query() 返回 URL 查询参数的键值对
-	// 使用 Get() 方法可以安全地获取参数值，如果参数不存在则返回空字符串
-	name := r.URL.Query().Get("name")
+	// bind 包按结构体标签绑定并验证：
+	// GET 使用 query 标签读取 r.URL.Query()，POST 使用 json 标签解码请求体
+	var name string
+	if r.Method == http.MethodPost {
+		var req HelloRequest
+		if !bindRequest(w, bind.JSON(r, &req)) {
+			return
+		}
+		name = req.Name
+	} else {
+		var q HelloQuery
+		if !bindRequest(w, bind.Query(r, &q)) {
+			return
+		}
+		name = q.Name
+	}
+
 	if name == "" {
 		name = "Guest"
 	}
@@ -90,6 +125,47 @@ func helloHandler(w http.ResponseWriter, r *http.Request) {
 		name, time.Now().Format(time.RFC3339))
 }
 
+// bindRequest 处理绑定结果，失败时写入错误响应并返回 false
+func bindRequest(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return true
+	}
+
+	var (
+		berr  *bind.Error
+		verrs validate.ValidationErrors
+	)
+	switch {
+	case errors.As(err, &berr):
+		writeJSONError(w, berr.Status, berr.Message, nil)
+	case errors.As(err, &verrs):
+		writeJSONError(w, http.StatusBadRequest, "Validation failed", verrs)
+	default:
+		writeJSONError(w, http.StatusInternalServerError, err.Error(), nil)
+	}
+	return false
+}
+
+// writeJSONError 返回 JSON 格式的错误
+// fields 不为空时附带每个字段的验证错误
+func writeJSONError(w http.ResponseWriter, code int, message string, fields validate.ValidationErrors) {
+	body := map[string]interface{}{"error": message}
+	if len(fields) > 0 {
+		// 同一字段有多条错误时只返回第一条（如 required 先于 min）
+		details := make(map[string]string, len(fields))
+		for _, fe := range fields {
+			if _, ok := details[fe.Field]; !ok {
+				details[fe.Field] = fe.Message
+			}
+		}
+		body["fields"] = details
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
 // 处理 /time 路径的请求
 func timeHandler(w http.ResponseWriter, r *http.Request) {
 	// 获取当前时间并格式化
@@ -111,7 +187,7 @@ func LoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 请求到达前的处理
 		start := time.Now()
-		log.Printf("收到请求: %s %s", r.Method, r.URL.Path)
+		logger.Debug("收到请求", "method", r.Method, "path", r.URL.Path)
 
 		// 创建自定义的 ResponseWriter 来记录响应状态码
 		// 因为 http.ResponseWriter 的 WriteHeader 方法是延迟调用的
@@ -120,10 +196,14 @@ func LoggerMiddleware(next http.Handler) http.Handler {
 		// 调用下一个处理器（请求处理）
 		next.ServeHTTP(lrw, r)
 
-		后的 // 请求处理处理
+		// 请求处理后的处理
 		duration := time.Since(start)
-		log.Printf("请求完成: %s %s - %d - %v",
-			r.Method, r.URL.Path, lrw.statusCode, duration)
+		logger.Info("请求完成",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", lrw.statusCode,
+			"duration", duration.String(),
+		)
 	})
 }
 
@@ -140,6 +220,99 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+// ====== 按方法路由 ======
+
+// Router 在 ServeMux 之上增加按 HTTP 方法分发
+// 路径匹配但方法不匹配时返回 405 并设置 Allow 头，路径不匹配返回 404
+// 注册了 GET 的路径同时接受 HEAD
+//
+// 提示：Go 1.22+ 的 ServeMux 也支持 "GET /time" 这样的模式，
+// 这里手动实现是为了展示其原理
+type Router struct {
+	mux    *http.ServeMux
+	mu     sync.RWMutex
+	routes map[string]map[string]http.Handler // 路径 -> 方法 -> 处理器
+}
+
+// NewRouter 创建路由器
+func NewRouter() *Router {
+	return &Router{
+		mux:    http.NewServeMux(),
+		routes: make(map[string]map[string]http.Handler),
+	}
+}
+
+// Handle 注册 (方法, 路径) -> 处理器
+func (rt *Router) Handle(method, pattern string, handler http.Handler) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	methods, ok := rt.routes[pattern]
+	if !ok {
+		// 同一路径只向 ServeMux 注册一次，由 dispatch 按方法分发
+		methods = make(map[string]http.Handler)
+		rt.routes[pattern] = methods
+		rt.mux.Handle(muxPattern(pattern), rt.dispatch(pattern))
+	}
+	methods[strings.ToUpper(method)] = handler
+}
+
+// muxPattern 注册到 ServeMux 的模式
+// ServeMux 中的 "/" 匹配所有路径，直接注册会让未知路径都进入 "/" 的分发，
+// 得到 405 而不是 404；"/{$}" 只匹配根路径本身
+func muxPattern(pattern string) string {
+	if pattern == "/" {
+		return "/{$}"
+	}
+	return pattern
+}
+
+// HandleFunc 注册处理函数
+func (rt *Router) HandleFunc(method, pattern string, fn func(http.ResponseWriter, *http.Request)) {
+	rt.Handle(method, pattern, http.HandlerFunc(fn))
+}
+
+// ServeHTTP 实现 http.Handler 接口
+// 未注册的路径由 ServeMux 返回 404
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// dispatch 返回按方法分发的处理器
+func (rt *Router) dispatch(pattern string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt.mu.RLock()
+		methods := rt.routes[pattern]
+		handler, ok := methods[r.Method]
+		if !ok && r.Method == http.MethodHead {
+			// HEAD 按 GET 处理，net/http 会丢弃写入的响应体
+			handler, ok = methods[http.MethodGet]
+		}
+		var allowed []string
+		if !ok {
+			for m := range methods {
+				allowed = append(allowed, m)
+			}
+			if _, get := methods[http.MethodGet]; get {
+				if _, head := methods[http.MethodHead]; !head {
+					allowed = append(allowed, http.MethodHead)
+				}
+			}
+		}
+		rt.mu.RUnlock()
+
+		if !ok {
+			// 405 必须通过 Allow 头告诉客户端允许的方法
+			sort.Strings(allowed)
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
 // ====== 静态文件服务 ======
 
 // 使用 http.FileServer 提供静态文件服务
@@ -156,25 +329,31 @@ func staticFileHandler() http.Handler {
 
 // ====== 主函数 - 服务器入口 ======
 
-func main() {
-	// 1. 注册路由处理器
-	// http.Handle 和 http.HandleFunc 用于注册路由
-	// 路由规则：
-	//   - 精确匹配：如 "/hello" 只匹配 /hello
-	//   - 路径前缀：如 "/static/" 匹配所有以 /static/ 开头的路径
-
+// setupRouter 注册所有路由
+// 路由规则：
+//   - 精确匹配：如 "/hello" 只匹配 /hello（"/" 也只匹配根路径）
+//   - 路径前缀：如 "/static/" 匹配所有以 /static/ 开头的路径
+func setupRouter() *Router {
 	// 使用自定义处理器
-	helloHandler := &HelloHandler{name: "Guest"}
-	http.Handle("/custom", helloHandler)
+	router := NewRouter()
+	router.Handle(http.MethodGet, "/custom", &HelloHandler{name: "Guest"})
 
-	// 使用函数处理器
-	http.HandleFunc("/", homeHandler)
-	http.HandleFunc("/hello", helloHandler)
-	http.HandleFunc("/time", timeHandler)
+	// 使用函数处理器，声明允许的方法
+	router.HandleFunc(http.MethodGet, "/", homeHandler)
+	router.HandleFunc(http.MethodGet, "/hello", helloHandler)
+	router.HandleFunc(http.MethodPost, "/hello", helloHandler)
+	router.HandleFunc(http.MethodGet, "/time", timeHandler)
 
 	// 注册静态文件服务
 	// 所有 /static/* 的请求都会从 ./static 目录提供文件
-	http.Handle("/static/", staticFileHandler())
+	router.Handle(http.MethodGet, "/static/", staticFileHandler())
+
+	return router
+}
+
+func main() {
+	// 1. 注册路由处理器
+	router := setupRouter()
 
 	// 2. 应用中间件
 	// 使用 http.TimeoutHandler 添加超时控制
@@ -182,10 +361,14 @@ func main() {
 	// 超时处理会自动返回 503 Service Unavailable 响应
 	// wrappedHandler := LoggerMiddleware(http.DefaultServeMux)
 
+	// 解压 Content-Encoding: gzip 的请求体，解压后超过 10MB 时读取报错
+	handler := decompress.HTTPMiddleware(decompress.DefaultMaxSize)(router)
+
 	// 3. 配置服务器
 	// http.Server 结构体用于配置 HTTP 服务器
 	server := &http.Server{
 		Addr:         ":8080",           // 监听地址和端口，格式为 host:port
+		Handler:      handler,           // 使用按方法分发的路由器
 		ReadTimeout:  10 * time.Second,  // 读取请求的超时时间
 		WriteTimeout: 10 * time.Second,  // 写入响应的超时时间
 		IdleTimeout:  120 * time.Second, // 空闲连接的最大存活时间
@@ -0,0 +1,135 @@
+// networking/network_http_server_test.go
+// HTTP 服务器示例的测试
+//
+// networking 目录下每个文件都是独立的示例程序（各有一个 main），需要按文件运行：
+//   go test networking/network_http_server.go networking/network_http_server_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ====== 按方法路由 ======
+
+func TestRouterMethodDispatch(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		wantCode  int
+		wantAllow string
+	}{
+		{"GET 匹配", http.MethodGet, "/time", http.StatusOK, ""},
+		{"POST 匹配", http.MethodPost, "/hello", http.StatusOK, ""},
+		{"根路径", http.MethodGet, "/", http.StatusOK, ""},
+		{"HEAD 按 GET 处理", http.MethodHead, "/time", http.StatusOK, ""},
+		{"方法不匹配", http.MethodPost, "/time", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"根路径方法不匹配", http.MethodDelete, "/", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"多个方法", http.MethodPut, "/hello", http.StatusMethodNotAllowed, "GET, HEAD, POST"},
+		{"未知路径", http.MethodGet, "/nope", http.StatusNotFound, ""},
+		{"未知路径 POST", http.MethodPost, "/nope", http.StatusNotFound, ""},
+		{"未知的多级路径", http.MethodGet, "/a/b", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body *strings.Reader
+			if tt.method == http.MethodPost {
+				body = strings.NewReader(`{"name":"Alice"}`)
+			} else {
+				body = strings.NewReader("")
+			}
+			req := httptest.NewRequest(tt.method, tt.path, body)
+			if tt.method == http.MethodPost {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("%s %s 状态码 = %d, want %d", tt.method, tt.path, rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestRouterHeadOverHTTP(t *testing.T) {
+	server := httptest.NewServer(setupRouter())
+	defer server.Close()
+
+	resp, err := http.Head(server.URL + "/time")
+	if err != nil {
+		t.Fatalf("HEAD /time error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("HEAD /time 状态码 = %d, want 200", resp.StatusCode)
+	}
+}
+
+// ====== 请求验证 ======
+
+func TestHelloValidation(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantCode   int
+		wantFields map[string]string
+		wantHello  string
+	}{
+		{"POST 合法请求", http.MethodPost, "/hello", `{"name":"alice"}`, http.StatusOK, nil, "Hello, alice!"},
+		{"POST 缺少 name", http.MethodPost, "/hello", `{}`, http.StatusBadRequest,
+			map[string]string{"name": "name is required"}, ""},
+		{"POST name 太短", http.MethodPost, "/hello", `{"name":"a"}`, http.StatusBadRequest,
+			map[string]string{"name": "name length must be at least 2"}, ""},
+		{"POST 非法 JSON", http.MethodPost, "/hello", `{"name":`, http.StatusBadRequest, nil, ""},
+		{"GET 可以省略 name", http.MethodGet, "/hello", "", http.StatusOK, nil, "Hello, Guest!"},
+		{"GET name 太长", http.MethodGet, "/hello?name=" + strings.Repeat("x", 51), "", http.StatusBadRequest,
+			map[string]string{"name": "name length must be at most 50"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d, body = %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			var body struct {
+				Message string            `json:"message"`
+				Error   string            `json:"error"`
+				Fields  map[string]string `json:"fields"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("响应不是 JSON: %q", rec.Body.String())
+			}
+			if tt.wantHello != "" && body.Message != tt.wantHello {
+				t.Errorf("message = %q, want %q", body.Message, tt.wantHello)
+			}
+			if tt.wantCode == http.StatusBadRequest && body.Error == "" {
+				t.Error("400 响应缺少 error")
+			}
+			if tt.wantFields != nil && !maps.Equal(body.Fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", body.Fields, tt.wantFields)
+			}
+		})
+	}
+}
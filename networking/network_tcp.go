@@ -5,12 +5,20 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/austoin/GolangTutorial/logger"
+	"github.com/austoin/GolangTutorial/safego"
 )
 
 // ====== TCP 服务器基础 ======
@@ -18,16 +26,349 @@ import (
 // TCPServer 结构体表示一个 TCP 服务器
 // 包含监听地址和连接管理等
 type TCPServer struct {
-	address  string         // 监听地址，如 ":8080"
-	listener net.Listener   // 监听器，用于接受连接
-	wg       sync.WaitGroup // 用于优雅关闭
+	address    string         // 监听地址，如 ":8080"
+	listener   net.Listener   // 监听器，用于接受连接
+	listenerMu sync.Mutex     // 保护 listener
+	wg         sync.WaitGroup // 用于优雅关闭
+
+	handshake HandshakeConfig // 握手与协议版本配置
+
+	// 连接登记表，用于空闲连接回收
+	connsMu     sync.Mutex
+	conns       map[*trackedConn]struct{}
+	idleTimeout time.Duration // 空闲超过该时长的连接会被关闭，0 表示不回收
+	stopSweep   chan struct{}
+	stopOnce    sync.Once
+
+	// 统计计数器，使用原子操作保证并发安全
+	totalConns  atomic.Int64 // 累计接受的连接数
+	activeConns atomic.Int64 // 当前活跃连接数
+	messages    atomic.Int64 // 累计处理的消息数
+	bytesRead   atomic.Int64 // 累计读取的字节数
+}
+
+// ServerStats 服务器统计快照
+type ServerStats struct {
+	TotalConnections  int64 // 累计接受的连接数
+	ActiveConnections int64 // 当前活跃连接数
+	MessagesProcessed int64 // 累计处理的消息数
+	BytesRead         int64 // 累计读取的字节数
+}
+
+// Stats 返回当前统计数据的快照
+func (s *TCPServer) Stats() ServerStats {
+	return ServerStats{
+		TotalConnections:  s.totalConns.Load(),
+		ActiveConnections: s.activeConns.Load(),
+		MessagesProcessed: s.messages.Load(),
+		BytesRead:         s.bytesRead.Load(),
+	}
+}
+
+// countingReader 统计读取字节数的 io.Reader 包装
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+// Read 读取数据并累加字节数
+func (cr countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n.Add(int64(n))
+	return n, err
 }
 
 // NewTCPServer 创建新的 TCP 服务器实例
+// 使用默认的握手配置，只支持当前协议版本
 func NewTCPServer(address string) *TCPServer {
+	return NewTCPServerWithHandshake(address, DefaultHandshakeConfig())
+}
+
+// NewTCPServerWithHandshake 创建 TCP 服务器，并指定支持的协议版本范围
+func NewTCPServerWithHandshake(address string, cfg HandshakeConfig) *TCPServer {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHandshakeTimeout
+	}
 	return &TCPServer{
-		address: address,
+		address:     address,
+		handshake:   cfg,
+		conns:       make(map[*trackedConn]struct{}),
+		idleTimeout: defaultIdleTimeout,
+		stopSweep:   make(chan struct{}),
+	}
+}
+
+// ====== 空闲连接回收 ======
+/*
+读超时只能在 handleConnection 内部按连接设置，
+这里由服务器统一登记所有连接，后台清理协程定期检查：
+
+  Accept → 登记 trackedConn → 每次 Read/Write 更新最后活动时间
+                 ↑
+  清理协程：每隔 idleTimeout/4 扫描一次，空闲超过 idleTimeout 的连接被关闭
+
+关闭的协调：
+  - trackedConn.Close 使用 sync.Once，清理协程与 handleConnection 谁先关闭都只关闭一次
+  - 清理协程关闭连接后，handleConnection 的读取返回错误并走正常的退出流程（注销、计数）
+  - 被回收的连接会记录原因，handleConnection 据此区分"空闲回收"和真正的读取错误
+*/
+
+// defaultIdleTimeout 默认空闲超时
+const defaultIdleTimeout = 5 * time.Minute
+
+// trackedConn 记录最后活动时间的连接
+type trackedConn struct {
+	net.Conn
+	lastActive atomic.Int64 // 最后一次读写的时间（UnixNano）
+
+	closeOnce sync.Once
+	closeErr  error
+	reaped    atomic.Bool // 是否被清理协程回收
+}
+
+// newTrackedConn 包装连接，并把当前时间作为最后活动时间
+func newTrackedConn(conn net.Conn) *trackedConn {
+	tc := &trackedConn{Conn: conn}
+	tc.touch()
+	return tc
+}
+
+func (tc *trackedConn) touch() {
+	tc.lastActive.Store(time.Now().UnixNano())
+}
+
+// Read 读取数据并更新活动时间
+func (tc *trackedConn) Read(p []byte) (int, error) {
+	n, err := tc.Conn.Read(p)
+	if n > 0 {
+		tc.touch()
 	}
+	return n, err
+}
+
+// Write 写入数据并更新活动时间
+func (tc *trackedConn) Write(p []byte) (int, error) {
+	n, err := tc.Conn.Write(p)
+	if n > 0 {
+		tc.touch()
+	}
+	return n, err
+}
+
+// Close 关闭连接，可以安全地重复调用
+func (tc *trackedConn) Close() error {
+	tc.closeOnce.Do(func() { tc.closeErr = tc.Conn.Close() })
+	return tc.closeErr
+}
+
+// idleFor 距最后一次活动的时长
+func (tc *trackedConn) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, tc.lastActive.Load()))
+}
+
+// SetIdleTimeout 设置空闲超时，需要在 Start 之前调用，0 表示不回收
+func (s *TCPServer) SetIdleTimeout(d time.Duration) {
+	s.idleTimeout = d
+}
+
+// ActiveConnections 返回当前登记的连接数
+func (s *TCPServer) ActiveConnections() int {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	return len(s.conns)
+}
+
+// track 登记连接
+func (s *TCPServer) track(conn net.Conn) *trackedConn {
+	tc := newTrackedConn(conn)
+	s.connsMu.Lock()
+	s.conns[tc] = struct{}{}
+	s.connsMu.Unlock()
+	return tc
+}
+
+// untrack 注销连接
+func (s *TCPServer) untrack(tc *trackedConn) {
+	s.connsMu.Lock()
+	delete(s.conns, tc)
+	s.connsMu.Unlock()
+}
+
+// sweepIdle 后台清理协程，Shutdown 时退出
+func (s *TCPServer) sweepIdle() {
+	ticker := time.NewTicker(s.idleTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopSweep:
+			return
+		case now := <-ticker.C:
+			s.reapIdle(now)
+		}
+	}
+}
+
+// reapIdle 关闭空闲超过 idleTimeout 的连接
+func (s *TCPServer) reapIdle(now time.Time) {
+	// 先在锁内收集，再在锁外关闭，避免 Close 阻塞登记表
+	var idle []*trackedConn
+	s.connsMu.Lock()
+	for tc := range s.conns {
+		if tc.idleFor(now) > s.idleTimeout {
+			idle = append(idle, tc)
+		}
+	}
+	s.connsMu.Unlock()
+
+	for _, tc := range idle {
+		tc.reaped.Store(true)
+		logger.Info("关闭空闲连接", "remote", tc.RemoteAddr().String(),
+			"reason", "idle timeout", "idle", tc.idleFor(now).Round(time.Millisecond).String())
+		tc.Close()
+	}
+}
+
+// ====== 握手与版本协商 ======
+/*
+客户端和服务器的消息格式可能随版本变化，连接建立后先握手，
+不兼容时立即断开，而不是在读写过程中出现难以排查的错误。
+
+握手帧（二进制，网络字节序）：
+  客户端 -> 服务器：magic(4 字节 "GTCP") + version(2 字节，客户端支持的最高版本)
+  服务器 -> 客户端：magic(4 字节) + status(1 字节) + version(2 字节)
+    - status = 0：成功，version 为协商后的版本
+    - status = 1：版本不兼容，version 为服务器支持的最高版本，随后关闭连接
+    - status = 2：握手格式错误（magic 不匹配），随后关闭连接
+
+协商规则：
+  协商版本 = min(客户端版本, 服务器最高版本)，且必须 >= 服务器最低版本
+
+握手成功后才进入按行读写的消息阶段，处理消息时可以通过
+ConnContext.Version 按版本走不同的逻辑。
+*/
+
+// protocolMagic 握手魔数，用于识别本协议的连接
+var protocolMagic = [4]byte{'G', 'T', 'C', 'P'}
+
+// ProtocolVersion 当前协议版本
+const ProtocolVersion uint16 = 2
+
+// defaultHandshakeTimeout 握手超时时间，防止连接建立后不发送握手占用资源
+const defaultHandshakeTimeout = 5 * time.Second
+
+// 握手响应状态
+const (
+	handshakeOK          byte = 0 // 成功
+	handshakeUnsupported byte = 1 // 版本不兼容
+	handshakeBadMagic    byte = 2 // 魔数错误
+)
+
+var (
+	// ErrBadMagic 握手魔数不匹配
+	ErrBadMagic = errors.New("握手失败: 协议魔数不匹配")
+
+	// ErrUnsupportedVersion 协议版本不兼容
+	ErrUnsupportedVersion = errors.New("握手失败: 协议版本不兼容")
+)
+
+// HandshakeConfig 握手配置
+type HandshakeConfig struct {
+	MinVersion uint16        // 服务器支持的最低版本
+	MaxVersion uint16        // 服务器支持的最高版本
+	Timeout    time.Duration // 握手超时时间，默认 5s
+}
+
+// DefaultHandshakeConfig 返回默认握手配置：支持 1 到当前版本
+func DefaultHandshakeConfig() HandshakeConfig {
+	return HandshakeConfig{
+		MinVersion: 1,
+		MaxVersion: ProtocolVersion,
+		Timeout:    defaultHandshakeTimeout,
+	}
+}
+
+// ConnContext 连接上下文，保存握手协商的结果
+type ConnContext struct {
+	Conn    net.Conn // 客户端连接
+	Version uint16   // 协商后的协议版本
+}
+
+// negotiate 根据客户端版本计算协商版本
+func (cfg HandshakeConfig) negotiate(clientVersion uint16) (uint16, bool) {
+	version := min(clientVersion, cfg.MaxVersion)
+	if version < cfg.MinVersion {
+		return 0, false
+	}
+	return version, true
+}
+
+// serverHandshake 服务器端握手
+// r 用于读取（便于统计字节数），w 用于写回响应
+func serverHandshake(r io.Reader, w io.Writer, cfg HandshakeConfig) (uint16, error) {
+	var req [6]byte
+	if _, err := io.ReadFull(r, req[:]); err != nil {
+		return 0, fmt.Errorf("读取握手失败: %w", err)
+	}
+
+	if !bytes.Equal(req[:4], protocolMagic[:]) {
+		writeHandshakeReply(w, handshakeBadMagic, cfg.MaxVersion)
+		return 0, ErrBadMagic
+	}
+
+	clientVersion := binary.BigEndian.Uint16(req[4:])
+	version, ok := cfg.negotiate(clientVersion)
+	if !ok {
+		writeHandshakeReply(w, handshakeUnsupported, cfg.MaxVersion)
+		return 0, fmt.Errorf("%w: 客户端版本 %d，服务器支持 %d-%d",
+			ErrUnsupportedVersion, clientVersion, cfg.MinVersion, cfg.MaxVersion)
+	}
+
+	if err := writeHandshakeReply(w, handshakeOK, version); err != nil {
+		return 0, fmt.Errorf("发送握手响应失败: %w", err)
+	}
+	return version, nil
+}
+
+// clientHandshake 客户端握手，返回协商后的版本
+func clientHandshake(conn net.Conn, version uint16, timeout time.Duration) (uint16, error) {
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	var req [6]byte
+	copy(req[:4], protocolMagic[:])
+	binary.BigEndian.PutUint16(req[4:], version)
+	if _, err := conn.Write(req[:]); err != nil {
+		return 0, fmt.Errorf("发送握手失败: %w", err)
+	}
+
+	var resp [7]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return 0, fmt.Errorf("读取握手响应失败: %w", err)
+	}
+	if !bytes.Equal(resp[:4], protocolMagic[:]) {
+		return 0, ErrBadMagic
+	}
+
+	agreed := binary.BigEndian.Uint16(resp[5:])
+	switch resp[4] {
+	case handshakeOK:
+		return agreed, nil
+	case handshakeUnsupported:
+		return 0, fmt.Errorf("%w: 客户端版本 %d，服务器最高版本 %d", ErrUnsupportedVersion, version, agreed)
+	default:
+		return 0, ErrBadMagic
+	}
+}
+
+// writeHandshakeReply 写出握手响应帧
+func writeHandshakeReply(w io.Writer, status byte, version uint16) error {
+	var resp [7]byte
+	copy(resp[:4], protocolMagic[:])
+	resp[4] = status
+	binary.BigEndian.PutUint16(resp[5:], version)
+	_, err := w.Write(resp[:])
+	return err
 }
 
 // Start 启动 TCP 服务器
@@ -37,30 +378,41 @@ func (s *TCPServer) Start() error {
 	// net.Listen 用于创建 TCP 监听器
 	// 第一个参数是网络类型（"tcp"、"tcp4"、"tcp6"等）
 	// 第二个参数是监听地址，格式为 host:port
-	var err error
-	s.listener, err = net.Listen("tcp", s.address)
+	listener, err := net.Listen("tcp", s.address)
 	if err != nil {
 		return fmt.Errorf("创建监听器失败: %w", err)
 	}
 
-	log.Printf("TCP 服务器启动，监听地址: %s", s.address)
+	logger.Info("TCP 服务器启动", "addr", s.address)
+	return s.serve(listener)
+}
+
+// serve 在已创建的监听器上接受连接，直到 Shutdown 关闭监听器
+func (s *TCPServer) serve(listener net.Listener) error {
+	// listener 由 Shutdown 在其他 goroutine 中关闭，读写需要加锁
+	s.listenerMu.Lock()
+	s.listener = listener
+	s.listenerMu.Unlock()
+
+	if s.idleTimeout > 0 {
+		go s.sweepIdle()
+	}
 
 	// 2. 接受连接循环
 	// Accept 方法会阻塞，直到有新的连接到来
 	// 返回的 net.Conn 表示一个连接，可以进行读写操作
 	for {
-		// 检查服务器是否已关闭
-		if s.listener == nil {
-			break
-		}
-
 		// 接受新连接
-		conn, err := s.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
+			// 监听器被 Shutdown 关闭，正常退出
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
 			// 如果是临时错误，继续接受连接
 			// 如果是严重错误，可能需要停止服务器
 			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
-				log.Printf("临时错误: %v", err)
+				logger.Warn("临时错误", "err", err)
 				continue
 			}
 			return fmt.Errorf("接受连接失败: %w", err)
@@ -68,11 +420,27 @@ func (s *TCPServer) Start() error {
 
 		// 3. 处理连接（使用 Goroutine 并发处理）
 		// 每个连接独立处理，不会阻塞其他连接
+		s.totalConns.Add(1)
+		s.activeConns.Add(1)
 		s.wg.Add(1)
-		go s.handleConnection(conn)
+		go s.serveConn(s.track(conn))
 	}
+}
 
-	return nil
+// serveConn 处理连接，单个连接 panic 时只断开该连接，不影响整个服务器
+func (s *TCPServer) serveConn(conn *trackedConn) {
+	defer s.untrack(conn)
+
+	err := safego.Run(func() error {
+		s.handleConnection(conn)
+		return nil
+	})
+
+	var pe *safego.PanicError
+	if errors.As(err, &pe) {
+		logger.Error("处理连接时 panic", "remote", conn.RemoteAddr().String(),
+			"panic", pe.Value, "stack", string(pe.Stack))
+	}
 }
 
 // handleConnection 处理单个客户端连接
@@ -81,16 +449,31 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 	// 确保连接最后关闭
 	defer func() {
 		conn.Close()
+		s.activeConns.Add(-1)
 		s.wg.Done()
-		log.Printf("客户端断开: %s", conn.RemoteAddr().String())
+		logger.Info("客户端断开", "remote", conn.RemoteAddr().String())
 	}()
 
-	log.Printf("新客户端连接: %s", conn.RemoteAddr().String())
+	logger.Info("新客户端连接", "remote", conn.RemoteAddr().String())
 
-	// 4. 创建缓冲区用于读取数据
+	// 使用 countingReader 统计读取的字节数
+	reader := countingReader{r: conn, n: &s.bytesRead}
+
+	// 4. 握手，不兼容时直接断开
+	conn.SetDeadline(time.Now().Add(s.handshake.Timeout))
+	version, err := serverHandshake(reader, conn, s.handshake)
+	if err != nil {
+		logger.Warn("握手失败", "remote", conn.RemoteAddr().String(), "err", err)
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	cc := &ConnContext{Conn: conn, Version: version}
+	logger.Debug("握手成功", "remote", conn.RemoteAddr().String(), "version", version)
+
+	// 5. 创建缓冲区用于读取数据
 	// bufio.Scanner 提供了方便的数据读取方式
 	// 默认按行分割，最大 64K
-	scanner := bufio.NewScanner(conn)
+	scanner := bufio.NewScanner(reader)
 
 	// 可以设置自定义的分割函数和缓冲区大小
 	// scanner.Split(bufio.ScanLines)
@@ -99,33 +482,44 @@ func (s *TCPServer) handleConnection(conn net.Conn) {
 	for scanner.Scan() {
 		// 读取一行数据
 		message := scanner.Text()
-		log.Printf("收到消息: %s", message)
+		s.messages.Add(1)
+		logger.Debug("收到消息", "message", message)
 
-		// 5. 处理消息并生成响应
-		response := s.processMessage(message)
+		// 6. 处理消息并生成响应
+		response := s.processMessage(cc, message)
 
-		// 6. 发送响应
+		// 7. 发送响应
 		// 写入数据时使用 bufio.Writer 提供缓冲
 		writer := bufio.NewWriter(conn)
 		fmt.Fprintf(writer, "%s\n", response)
 		writer.Flush() // 确保数据发送出去
 	}
 
-	// 检查扫描错误
+	// 检查扫描错误，被空闲回收的连接已由清理协程记录原因
 	if err := scanner.Err(); err != nil {
-		log.Printf("读取错误: %v", err)
+		if tc, ok := conn.(*trackedConn); ok && tc.reaped.Load() {
+			return
+		}
+		logger.Error("读取错误", "err", err)
 	}
 }
 
 // processMessage 处理客户端消息并返回响应
-func (s *TCPServer) processMessage(message string) string {
+// cc.Version 为握手协商的协议版本，可以按版本返回不同格式
+func (s *TCPServer) processMessage(cc *ConnContext, message string) string {
 	message = strings.TrimSpace(message)
 
 	// 根据消息内容生成不同的响应
 	switch message {
 	case "ping":
 		return "pong"
+	case "version":
+		return fmt.Sprintf("v%d", cc.Version)
 	case "time":
+		// 版本 2 起返回 RFC3339 格式，带时区
+		if cc.Version >= 2 {
+			return time.Now().Format(time.RFC3339)
+		}
 		return time.Now().Format("2006-01-02 15:04:05")
 	case "date":
 		return time.Now().Format("2006-01-02")
@@ -144,18 +538,23 @@ func (s *TCPServer) processMessage(message string) string {
 // Shutdown 优雅关闭服务器
 // 等待所有正在处理的连接完成
 func (s *TCPServer) Shutdown() error {
-	log.Println("正在关闭服务器...")
+	logger.Info("正在关闭服务器")
+
+	// 停止空闲连接清理
+	s.stopOnce.Do(func() { close(s.stopSweep) })
 
 	// 关闭监听器，停止接受新连接
+	s.listenerMu.Lock()
 	if s.listener != nil {
 		s.listener.Close()
 		s.listener = nil
 	}
+	s.listenerMu.Unlock()
 
 	// 等待所有连接处理完成
 	s.wg.Wait()
 
-	log.Println("服务器已关闭")
+	logger.Info("服务器已关闭")
 	return nil
 }
 
@@ -165,10 +564,17 @@ func (s *TCPServer) Shutdown() error {
 type TCPClient struct {
 	address string   // 服务器地址
 	conn    net.Conn // 连接
+	version uint16   // 协商后的协议版本
 }
 
-// NewTCPClient 创建新的 TCP 客户端
+// NewTCPClient 创建新的 TCP 客户端，使用当前协议版本握手
 func NewTCPClient(address string) (*TCPClient, error) {
+	return NewTCPClientWithVersion(address, ProtocolVersion)
+}
+
+// NewTCPClientWithVersion 创建 TCP 客户端，以 version 作为支持的最高版本握手
+// 服务器不兼容时返回 ErrUnsupportedVersion
+func NewTCPClientWithVersion(address string, version uint16) (*TCPClient, error) {
 	// 1. 连接到服务器
 	// net.Dial 会阻塞，直到连接建立或超时
 	conn, err := net.Dial("tcp", address)
@@ -176,12 +582,25 @@ func NewTCPClient(address string) (*TCPClient, error) {
 		return nil, fmt.Errorf("连接服务器失败: %w", err)
 	}
 
+	// 2. 握手
+	agreed, err := clientHandshake(conn, version, defaultHandshakeTimeout)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
 	return &TCPClient{
 		address: address,
 		conn:    conn,
+		version: agreed,
 	}, nil
 }
 
+// Version 返回协商后的协议版本
+func (c *TCPClient) Version() uint16 {
+	return c.version
+}
+
 // Send 发送消息并接收响应
 func (c *TCPClient) Send(message string) (string, error) {
 	// 1. 发送消息
@@ -235,18 +654,47 @@ func PipeServerExample() {
 
 // ====== 高级：聊天服务器 ======
 
+// ChatMessage 聊天消息（带时间戳）
+type ChatMessage struct {
+	Time time.Time // 广播时间
+	Text string    // 消息内容
+}
+
+// String 格式化消息，如 "[15:04:05] [alice] hello"
+func (m ChatMessage) String() string {
+	return fmt.Sprintf("[%s] %s", m.Time.Format("15:04:05"), m.Text)
+}
+
+// defaultHistorySize 默认保留的历史消息条数
+const defaultHistorySize = 50
+
 // ChatServer 实现一个简单的多人聊天服务器
 type ChatServer struct {
 	clients   map[net.Conn]string // 客户端连接 -> 用户名
-	mu        sync.RWMutex        // 保护 clients 映射
+	mu        sync.RWMutex        // 保护 clients 映射和历史消息
 	broadcast chan string         // 广播消息通道
+
+	// 历史消息环形缓冲区
+	history     []ChatMessage // 固定容量的缓冲区
+	historyNext int           // 下一条消息写入的位置
+	historyFull bool          // 缓冲区是否已写满
 }
 
 // NewChatServer 创建新的聊天服务器
 func NewChatServer() *ChatServer {
+	return NewChatServerWithHistory(defaultHistorySize)
+}
+
+// NewChatServerWithHistory 创建聊天服务器，保留最近 historySize 条消息
+// 新客户端加入时会先收到这些历史消息
+func NewChatServerWithHistory(historySize int) *ChatServer {
+	if historySize < 0 {
+		historySize = 0
+	}
 	return &ChatServer{
 		clients:   make(map[net.Conn]string),
 		broadcast: make(chan string, 10),
+		history:   make([]ChatMessage, historySize),
 	}
 }
 
@@ -271,16 +719,51 @@ func (cs *ChatServer) Start(address string) error {
 
 // handleBroadcast 处理广播消息
 func (cs *ChatServer) handleBroadcast() {
-	for msg := range cs.broadcast {
-		cs.mu.RLock()
-		defer cs.mu.RUnlock()
+	for text := range cs.broadcast {
+		msg := ChatMessage{Time: time.Now(), Text: text}
 
+		// 记录历史和发送给客户端在同一把锁内完成
+		// 这样新客户端的历史回放与实时消息之间不会有遗漏或重复
+		cs.mu.Lock()
+		cs.appendHistory(msg)
 		for conn := range cs.clients {
 			fmt.Fprintf(conn, "%s\n", msg)
 		}
+		cs.mu.Unlock()
+	}
+}
+
+// appendHistory 把消息写入环形缓冲区，调用方需持有 cs.mu
+func (cs *ChatServer) appendHistory(msg ChatMessage) {
+	if len(cs.history) == 0 {
+		return
+	}
+	cs.history[cs.historyNext] = msg
+	cs.historyNext = (cs.historyNext + 1) % len(cs.history)
+	if cs.historyNext == 0 {
+		cs.historyFull = true
 	}
 }
 
+// History 按时间顺序返回历史消息
+func (cs *ChatServer) History() []ChatMessage {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.historySnapshot()
+}
+
+// historySnapshot 复制历史消息，调用方需持有 cs.mu
+func (cs *ChatServer) historySnapshot() []ChatMessage {
+	if !cs.historyFull {
+		return append([]ChatMessage(nil), cs.history[:cs.historyNext]...)
+	}
+	// 写满后最旧的消息在 historyNext 位置
+	out := make([]ChatMessage, 0, len(cs.history))
+	out = append(out, cs.history[cs.historyNext:]...)
+	out = append(out, cs.history[:cs.historyNext]...)
+	return out
+}
+
 // handleChatClient 处理聊天客户端
 func (cs *ChatServer) handleChatClient(conn net.Conn) {
 	defer conn.Close()
@@ -292,8 +775,12 @@ func (cs *ChatServer) handleChatClient(conn net.Conn) {
 	}
 	username := scanner.Text()
 
-	// 注册客户端
+	// 回放历史消息并注册客户端
+	// 持有锁期间广播协程无法发送新消息，保证回放和实时消息衔接
 	cs.mu.Lock()
+	for _, msg := range cs.historySnapshot() {
+		fmt.Fprintf(conn, "%s\n", msg)
+	}
 	cs.clients[conn] = username
 	cs.mu.Unlock()
 
@@ -342,7 +829,8 @@ func main() {
 	defer client.Close()
 
 	// 发送测试消息
-	tests := []string{"ping", "time", "echo:Hello World", "quit"}
+	fmt.Printf("协商的协议版本: %d\n", client.Version())
+	tests := []string{"ping", "version", "time", "echo:Hello World", "quit"}
 	for _, test := range tests {
 		response, err := client.Send(test)
 		if err != nil {
@@ -0,0 +1,346 @@
+// networking/network_tcp_test.go
+// TCP 示例的测试
+//
+// networking 目录下每个文件都是独立的示例程序（各有一个 main），需要按文件运行：
+//   go test networking/network_tcp.go networking/network_tcp_test.go
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ====== TCP 服务器 ======
+
+// startTCPServer 在随机端口上启动 s，测试结束时关闭，返回监听地址
+func startTCPServer(t *testing.T, s *TCPServer) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.serve(ln) }()
+	t.Cleanup(func() {
+		s.Shutdown()
+		ln.Close() // serve 尚未登记监听器时也能退出
+		if err := <-done; err != nil {
+			t.Errorf("serve() error = %v", err)
+		}
+	})
+	return ln.Addr().String()
+}
+
+// dialTCP 以 version 握手连接服务器，测试结束时关闭
+func dialTCP(t *testing.T, addr string, version uint16) *TCPClient {
+	t.Helper()
+	c, err := NewTCPClientWithVersion(addr, version)
+	if err != nil {
+		t.Fatalf("NewTCPClientWithVersion(%d) error = %v", version, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// waitFor 轮询 cond，超时后报告失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTCPServerStats(t *testing.T) {
+	s := NewTCPServer("")
+	addr := startTCPServer(t, s)
+
+	a := dialTCP(t, addr, ProtocolVersion)
+	b := dialTCP(t, addr, ProtocolVersion)
+	for _, tt := range []struct {
+		c    *TCPClient
+		msg  string
+		want string
+	}{
+		{a, "ping", "pong"},
+		{a, "echo:hi", "hi"},
+		{b, "ping", "pong"},
+	} {
+		if got, err := tt.c.Send(tt.msg); err != nil || got != tt.want {
+			t.Fatalf("Send(%q) = %q, %v, want %q", tt.msg, got, err, tt.want)
+		}
+	}
+
+	waitFor(t, "两个连接都处于活跃状态", func() bool { return s.Stats().ActiveConnections == 2 })
+	b.Close()
+	waitFor(t, "断开的连接被注销", func() bool { return s.Stats().ActiveConnections == 1 })
+
+	// 每个连接的 6 字节握手 + 3 条消息（各带换行符）
+	want := ServerStats{
+		TotalConnections:  2,
+		ActiveConnections: 1,
+		MessagesProcessed: 3,
+		BytesRead:         2*6 + int64(len("ping\n")+len("echo:hi\n")+len("ping\n")),
+	}
+	if got := s.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTCPIdleReaper(t *testing.T) {
+	s := NewTCPServer("")
+	s.SetIdleTimeout(100 * time.Millisecond)
+	addr := startTCPServer(t, s)
+
+	idle := dialTCP(t, addr, ProtocolVersion)
+	active := dialTCP(t, addr, ProtocolVersion)
+	waitFor(t, "两个连接登记", func() bool { return s.ActiveConnections() == 2 })
+
+	// active 持续收发，保持在空闲超时之内
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if got, err := active.Send("ping"); err != nil || got != "pong" {
+			t.Fatalf("活跃连接 Send() = %q, %v, want pong", got, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	waitFor(t, "空闲连接被回收", func() bool { return s.ActiveConnections() == 1 })
+	// 服务端已关闭连接，客户端读到 EOF
+	idle.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := idle.conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Errorf("被回收的连接 Read() error = %v, want io.EOF", err)
+	}
+	if got, err := active.Send("ping"); err != nil || got != "pong" {
+		t.Errorf("活跃连接 Send() = %q, %v, want pong", got, err)
+	}
+}
+
+func TestReapIdle(t *testing.T) {
+	s := NewTCPServer("")
+	s.SetIdleTimeout(time.Minute)
+
+	server, client := net.Pipe()
+	defer client.Close()
+	tc := s.track(server)
+
+	// 未超过 idleTimeout 的连接保留
+	s.reapIdle(time.Now().Add(30 * time.Second))
+	if tc.reaped.Load() || s.ActiveConnections() != 1 {
+		t.Fatalf("30s 后 reaped = %v, 连接数 = %d, want 保留", tc.reaped.Load(), s.ActiveConnections())
+	}
+
+	// 超过后关闭并标记为回收，注销由 handleConnection 退出时完成
+	s.reapIdle(time.Now().Add(2 * time.Minute))
+	if !tc.reaped.Load() {
+		t.Error("空闲超时的连接应该被标记为回收")
+	}
+	if _, err := server.Write([]byte("x")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("回收后 Write() error = %v, want io.ErrClosedPipe", err)
+	}
+	// 重复关闭是安全的
+	if err := tc.Close(); err != nil {
+		t.Errorf("重复 Close() error = %v", err)
+	}
+}
+
+func TestTCPHandshake(t *testing.T) {
+	s := NewTCPServerWithHandshake("", HandshakeConfig{MinVersion: 2, MaxVersion: 3})
+	addr := startTCPServer(t, s)
+
+	tests := []struct {
+		name   string
+		client uint16
+		want   uint16
+	}{
+		{"客户端版本更高时降到服务器最高版本", 5, 3},
+		{"相同版本", 3, 3},
+		{"服务器支持的最低版本", 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dialTCP(t, addr, tt.client)
+			if c.Version() != tt.want {
+				t.Errorf("Version() = %d, want %d", c.Version(), tt.want)
+			}
+			// 服务器按协商的版本处理消息
+			if got, err := c.Send("version"); err != nil || got != fmt.Sprintf("v%d", tt.want) {
+				t.Errorf(`Send("version") = %q, %v, want "v%d"`, got, err, tt.want)
+			}
+		})
+	}
+
+	t.Run("版本过低被拒绝", func(t *testing.T) {
+		c, err := NewTCPClientWithVersion(addr, 1)
+		if !errors.Is(err, ErrUnsupportedVersion) {
+			t.Fatalf("NewTCPClientWithVersion(1) error = %v, want ErrUnsupportedVersion", err)
+		}
+		if c != nil {
+			t.Error("握手失败时不应返回客户端")
+		}
+	})
+
+	t.Run("魔数错误", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("net.Dial() error = %v", err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		conn.Write([]byte{'H', 'T', 'T', 'P', 0, 2})
+
+		var resp [7]byte
+		if _, err := io.ReadFull(conn, resp[:]); err != nil {
+			t.Fatalf("读取握手响应失败: %v", err)
+		}
+		if resp[4] != handshakeBadMagic {
+			t.Errorf("status = %d, want %d", resp[4], handshakeBadMagic)
+		}
+		// 服务器随后关闭连接
+		if _, err := conn.Read(resp[:]); err != io.EOF {
+			t.Errorf("Read() error = %v, want io.EOF", err)
+		}
+	})
+
+	// 失败的握手不计入消息数
+	if got := s.Stats().MessagesProcessed; got != 3 {
+		t.Errorf("MessagesProcessed = %d, want 3", got)
+	}
+}
+
+func TestTCPHandshakeVersionBehavior(t *testing.T) {
+	addr := startTCPServer(t, NewTCPServer(""))
+
+	// 版本 1 的 time 响应不带时区，版本 2 起为 RFC3339
+	for _, tt := range []struct {
+		version uint16
+		layout  string
+	}{
+		{1, "2006-01-02 15:04:05"},
+		{2, time.RFC3339},
+	} {
+		got, err := dialTCP(t, addr, tt.version).Send("time")
+		if err != nil {
+			t.Fatalf("v%d Send(\"time\") error = %v", tt.version, err)
+		}
+		if _, err := time.Parse(tt.layout, got); err != nil {
+			t.Errorf("v%d time = %q, want 格式 %q", tt.version, got, tt.layout)
+		}
+	}
+}
+
+// ====== 聊天服务器 ======
+
+// chatClient 通过 net.Pipe 连接到 ChatServer 的测试客户端
+type chatClient struct {
+	conn  net.Conn
+	lines chan string
+}
+
+// joinChat 以 username 加入聊天，收到的每一行（去掉时间戳）写入 lines
+func joinChat(t *testing.T, cs *ChatServer, username string) *chatClient {
+	t.Helper()
+	client, server := net.Pipe()
+	go cs.handleChatClient(server)
+
+	c := &chatClient{conn: client, lines: make(chan string, 100)}
+	go func() {
+		scanner := bufio.NewScanner(client)
+		for scanner.Scan() {
+			// "[15:04:05] [alice] hello" -> "[alice] hello"
+			_, text, _ := strings.Cut(scanner.Text(), "] ")
+			c.lines <- text
+		}
+		close(c.lines)
+	}()
+	t.Cleanup(func() { client.Close() })
+
+	c.send(t, username)
+	return c
+}
+
+func (c *chatClient) send(t *testing.T, line string) {
+	t.Helper()
+	if _, err := fmt.Fprintf(c.conn, "%s\n", line); err != nil {
+		t.Fatalf("发送 %q 失败: %v", line, err)
+	}
+}
+
+// expect 按顺序收到 want 中的每一行
+func (c *chatClient) expect(t *testing.T, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-c.lines:
+			if got != w {
+				t.Fatalf("收到 %q, want %q", got, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("等待 %q 超时", w)
+		}
+	}
+}
+
+func TestChatServerHistoryReplay(t *testing.T) {
+	cs := NewChatServerWithHistory(3)
+	go cs.handleBroadcast()
+
+	alice := joinChat(t, cs, "alice")
+	alice.expect(t, "[系统] alice 加入聊天")
+	for i := 1; i <= 3; i++ {
+		alice.send(t, fmt.Sprintf("m%d", i))
+	}
+	alice.expect(t, "[alice] m1", "[alice] m2", "[alice] m3")
+
+	// 缓冲区只保留最近 3 条，最早的 "alice 加入聊天" 已被挤掉
+	bob := joinChat(t, cs, "bob")
+	bob.expect(t, "[alice] m1", "[alice] m2", "[alice] m3", "[系统] bob 加入聊天")
+
+	alice.send(t, "live")
+	bob.expect(t, "[alice] live")
+	alice.expect(t, "[系统] bob 加入聊天", "[alice] live")
+}
+
+func TestChatServerHistoryBounded(t *testing.T) {
+	cs := NewChatServerWithHistory(2)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		cs.appendHistory(ChatMessage{Time: base.Add(time.Duration(i) * time.Second), Text: fmt.Sprint(i)})
+	}
+
+	got := cs.History()
+	if len(got) != 2 || got[0].Text != "3" || got[1].Text != "4" {
+		t.Fatalf("History() = %v, want 最近的 3、4", got)
+	}
+	if !got[0].Time.Before(got[1].Time) {
+		t.Errorf("History() 没有按时间排序: %v", got)
+	}
+	if s := got[1].String(); s != "[12:00:04] 4" {
+		t.Errorf("String() = %q, want %q", s, "[12:00:04] 4")
+	}
+
+	// 未写满时按写入顺序返回
+	cs = NewChatServerWithHistory(5)
+	cs.appendHistory(ChatMessage{Text: "a"})
+	cs.appendHistory(ChatMessage{Text: "b"})
+	if got := cs.History(); len(got) != 2 || got[0].Text != "a" || got[1].Text != "b" {
+		t.Errorf("History() = %v, want [a b]", got)
+	}
+
+	// 容量为 0 时不保留历史
+	cs = NewChatServerWithHistory(0)
+	cs.appendHistory(ChatMessage{Text: "x"})
+	if got := cs.History(); len(got) != 0 {
+		t.Errorf("History() = %v, want 空", got)
+	}
+}
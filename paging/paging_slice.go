@@ -0,0 +1,60 @@
+// paging/paging_slice.go
+// 内存数据分页 - 详细注释版
+
+package paging
+
+// ====== 分页基础 ======
+/*
+示例和测试中的处理器经常返回固定的切片，各自手写 items[start:end]
+容易在页码越界时 panic。Page 统一处理参数修正和越界：
+
+  items, total, totalPages := paging.Page(posts, page, pageSize)
+
+参数修正：
+  - page < 1 按第 1 页处理
+  - pageSize < 1 使用 DefaultPageSize，超过 MaxPageSize 时截断为 MaxPageSize
+
+越界：
+  - page 超过总页数时返回空切片（不是 nil，序列化为 [] 而不是 null）
+  - 最后一页可能不满 pageSize
+
+返回的切片与 items 共享底层数组，调用方不应修改。
+*/
+
+const (
+	DefaultPageSize = 10  // pageSize 无效时使用的默认值
+	MaxPageSize     = 100 // 单页最大条数
+)
+
+// Page 返回第 page 页（从 1 开始）的数据
+// total 为总条数，totalPages 为总页数（items 为空时为 0）
+func Page[T any](items []T, page, pageSize int) (pageItems []T, total int, totalPages int) {
+	page, pageSize = Clamp(page, pageSize)
+
+	total = len(items)
+	totalPages = (total + pageSize - 1) / pageSize
+
+	// 先比较页码再计算偏移，避免 page 很大时乘法溢出
+	if page > totalPages {
+		return []T{}, total, totalPages
+	}
+
+	start := (page - 1) * pageSize
+	end := min(start+pageSize, total)
+	return items[start:end], total, totalPages
+}
+
+// Clamp 修正分页参数，规则与 Page 相同
+// 处理器可以用它得到实际生效的 page、pageSize 并返回给客户端
+func Clamp(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	return page, pageSize
+}
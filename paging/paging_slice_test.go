@@ -0,0 +1,76 @@
+// paging/paging_slice_test.go
+// 内存数据分页的测试
+
+package paging
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6, 7}
+	tests := []struct {
+		name           string
+		page, pageSize int
+		want           []int
+		wantTotalPages int
+	}{
+		{"第一页", 1, 3, []int{1, 2, 3}, 3},
+		{"中间页", 2, 3, []int{4, 5, 6}, 3},
+		{"最后一页不满", 3, 3, []int{7}, 3},
+		{"刚好整除", 1, 7, []int{1, 2, 3, 4, 5, 6, 7}, 1},
+		{"page 超出总页数返回空", 4, 3, []int{}, 3},
+		{"page 很大不会溢出", math.MaxInt, 3, []int{}, 3},
+		{"page < 1 按第一页", 0, 3, []int{1, 2, 3}, 3},
+		{"pageSize < 1 使用默认值", 1, 0, []int{1, 2, 3, 4, 5, 6, 7}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, totalPages := Page(items, tt.page, tt.pageSize)
+			if !slices.Equal(got, tt.want) || total != len(items) || totalPages != tt.wantTotalPages {
+				t.Errorf("Page(%d, %d) = %v, %d, %d, want %v, %d, %d",
+					tt.page, tt.pageSize, got, total, totalPages, tt.want, len(items), tt.wantTotalPages)
+			}
+			// 越界时返回空切片而不是 nil，序列化为 []
+			if got == nil {
+				t.Error("Page() 不应返回 nil")
+			}
+		})
+	}
+
+	t.Run("空数据", func(t *testing.T) {
+		got, total, totalPages := Page([]string(nil), 1, 10)
+		if got == nil || len(got) != 0 || total != 0 || totalPages != 0 {
+			t.Errorf("Page(nil) = %v, %d, %d, want [], 0, 0", got, total, totalPages)
+		}
+	})
+
+	t.Run("pageSize 截断为 MaxPageSize", func(t *testing.T) {
+		got, _, totalPages := Page(make([]int, 250), 1, 1000)
+		if len(got) != MaxPageSize || totalPages != 3 {
+			t.Errorf("len = %d, totalPages = %d, want %d, 3", len(got), totalPages, MaxPageSize)
+		}
+	})
+}
+
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		page, pageSize         int
+		wantPage, wantPageSize int
+	}{
+		{2, 20, 2, 20},
+		{0, 20, 1, 20},
+		{-5, 20, 1, 20},
+		{1, 0, 1, DefaultPageSize},
+		{1, -1, 1, DefaultPageSize},
+		{1, MaxPageSize, 1, MaxPageSize},
+		{1, MaxPageSize + 1, 1, MaxPageSize},
+	}
+	for _, tt := range tests {
+		if page, pageSize := Clamp(tt.page, tt.pageSize); page != tt.wantPage || pageSize != tt.wantPageSize {
+			t.Errorf("Clamp(%d, %d) = %d, %d, want %d, %d", tt.page, tt.pageSize, page, pageSize, tt.wantPage, tt.wantPageSize)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// ratecontrol/ratecontrol_debounce.go
+// 防抖与节流 - 详细注释版
+
+package ratecontrol
+
+import (
+	"sync"
+	"time"
+)
+
+// ====== 防抖与节流基础 ======
+/*
+两者都用来控制函数的调用频率，区别在于：
+
+防抖（Debounce）：
+  连续调用时不执行，直到最后一次调用后安静了 d 时间才执行一次。
+  适合：搜索框输入联想、窗口大小变化后重新布局、配置文件变更后重新加载
+
+  调用:  x x x x       x
+  执行:          ↑(d)    ↑(d)
+
+节流（Throttle）：
+  每个间隔 d 内最多执行一次，间隔内的其他调用直接丢弃。
+  适合：滚动事件、进度上报、日志采样
+
+  调用:  x x x x x x x x
+  执行:  ↑     ↑     ↑      （每隔 d 一次）
+
+使用示例：
+  save := ratecontrol.Debounce(500*time.Millisecond, func() { fmt.Println("保存") })
+  defer save.Stop()
+  for i := 0; i < 10; i++ {
+      save.Call() // 只会在最后一次调用 500ms 后打印一次
+  }
+
+两者都是并发安全的，可以在多个 goroutine 中同时调用 Call。
+*/
+
+// ====== 防抖 ======
+
+// Debouncer 防抖器
+type Debouncer struct {
+	mu      sync.Mutex
+	d       time.Duration
+	fn      func()
+	timer   *time.Timer
+	stopped bool
+}
+
+// Debounce 创建防抖器
+// 每次 Call 都会重新计时，距最后一次 Call 满 d 后在新的 goroutine 中执行 fn
+func Debounce(d time.Duration, fn func()) *Debouncer {
+	return &Debouncer{d: d, fn: fn}
+}
+
+// Call 触发一次调用
+func (db *Debouncer) Call() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.stopped {
+		return
+	}
+
+	if db.timer == nil {
+		db.timer = time.AfterFunc(db.d, db.fire)
+		return
+	}
+	// 重新计时
+	db.timer.Reset(db.d)
+}
+
+// Stop 停止防抖器，尚未执行的调用会被取消，之后的 Call 不再生效
+func (db *Debouncer) Stop() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.stopped = true
+	if db.timer != nil {
+		db.timer.Stop()
+	}
+}
+
+// fire 计时结束时执行 fn
+func (db *Debouncer) fire() {
+	db.mu.Lock()
+	stopped := db.stopped
+	db.mu.Unlock()
+
+	// Stop 与计时器到期同时发生时，以 Stop 为准
+	if stopped {
+		return
+	}
+	db.fn()
+}
+
+// ====== 节流 ======
+
+// Throttler 节流器
+type Throttler struct {
+	mu      sync.Mutex
+	d       time.Duration
+	fn      func()
+	last    time.Time // 上次执行时间
+	stopped bool
+}
+
+// Throttle 创建节流器
+// 每个间隔 d 内最多执行一次 fn，第一次调用立即执行
+func Throttle(d time.Duration, fn func()) *Throttler {
+	return &Throttler{d: d, fn: fn}
+}
+
+// Call 触发一次调用，在调用方的 goroutine 中同步执行 fn
+// 返回 fn 是否被执行（false 表示被节流丢弃或已停止）
+func (t *Throttler) Call() bool {
+	t.mu.Lock()
+	now := time.Now()
+	if t.stopped || (!t.last.IsZero() && now.Sub(t.last) < t.d) {
+		t.mu.Unlock()
+		return false
+	}
+	t.last = now
+	t.mu.Unlock()
+
+	// 不持有锁执行，fn 耗时较长时也不会阻塞其他调用方的判断
+	t.fn()
+	return true
+}
+
+// Stop 停止节流器，之后的 Call 不再执行 fn
+func (t *Throttler) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+}
@@ -0,0 +1,120 @@
+// ratecontrol/ratecontrol_debounce_test.go
+// 防抖与节流的测试
+
+package ratecontrol
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDebounceCoalescesBurst(t *testing.T) {
+	fired := make(chan time.Time, 10)
+	db := Debounce(50*time.Millisecond, func() { fired <- time.Now() })
+	defer db.Stop()
+
+	// 连续调用不断重新计时，只在最后一次调用满 d 后执行一次
+	var last time.Time
+	for range 5 {
+		db.Call()
+		last = time.Now()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	select {
+	case at := <-fired:
+		if wait := at.Sub(last); wait < 50*time.Millisecond {
+			t.Errorf("距最后一次 Call %s 就执行了, want >= 50ms", wait)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("等待执行超时")
+	}
+	select {
+	case <-fired:
+		t.Error("一轮连续调用执行了不止一次")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 安静之后的新调用会再执行一次
+	db.Call()
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("第二轮调用没有执行")
+	}
+}
+
+func TestDebounceStop(t *testing.T) {
+	var calls atomic.Int64
+	db := Debounce(30*time.Millisecond, func() { calls.Add(1) })
+
+	db.Call()
+	db.Stop()
+	db.Call() // Stop 之后的调用不生效
+	time.Sleep(80 * time.Millisecond)
+
+	if n := calls.Load(); n != 0 {
+		t.Errorf("Stop 后执行了 %d 次, want 0", n)
+	}
+	db.Stop() // 重复停止是安全的
+}
+
+func TestThrottle(t *testing.T) {
+	var calls atomic.Int64
+	th := Throttle(50*time.Millisecond, func() { calls.Add(1) })
+
+	// 第一次立即执行，间隔内的其他调用被丢弃
+	if !th.Call() {
+		t.Fatal("第一次 Call() = false, want true")
+	}
+	if calls.Load() != 1 {
+		t.Fatal("第一次调用没有同步执行 fn")
+	}
+	for range 5 {
+		if th.Call() {
+			t.Error("间隔内的 Call() = true, want false")
+		}
+	}
+
+	// 间隔过后可以再次执行
+	time.Sleep(60 * time.Millisecond)
+	if !th.Call() {
+		t.Error("间隔过后 Call() = false, want true")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("执行了 %d 次, want 2", n)
+	}
+
+	th.Stop()
+	time.Sleep(60 * time.Millisecond)
+	if th.Call() {
+		t.Error("Stop 后 Call() = true, want false")
+	}
+}
+
+func TestThrottleConcurrent(t *testing.T) {
+	var calls atomic.Int64
+	th := Throttle(time.Hour, func() { calls.Add(1) })
+
+	// 多个 goroutine 同时调用，一个间隔内只有一个能执行
+	var (
+		wg       sync.WaitGroup
+		executed atomic.Int64
+	)
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if th.Call() {
+				executed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls.Load() != 1 || executed.Load() != 1 {
+		t.Errorf("执行了 %d 次（返回 true %d 次）, want 1", calls.Load(), executed.Load())
+	}
+}
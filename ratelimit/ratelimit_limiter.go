@@ -0,0 +1,169 @@
+// ratelimit/ratelimit_limiter.go
+// 限流器接口与内存令牌桶 - 详细注释版
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// ====== 限流基础 ======
+/*
+Gin、Echo 中间件只依赖 Limiter 接口，具体算法和存储可以替换：
+
+  - TokenBucket：进程内令牌桶，适合单实例部署
+  - RedisSlidingWindow：基于 Redis 的滑动窗口，多实例共享同一份配额
+
+使用示例：
+  // 每个 IP 每秒 10 个请求，允许突发 20 个
+  l := ratelimit.NewTokenBucket(10, 20)
+  defer l.Stop()
+
+  allowed, retryAfter, err := l.Allow(ctx, clientIP)
+  if err == nil && !allowed {
+      // 返回 429，并设置 Retry-After: retryAfter
+  }
+
+key 通常是客户端 IP、用户 ID 或 API Key，每个 key 独立计数。
+*/
+
+// Limiter 限流器
+type Limiter interface {
+	// Allow 判断 key 的本次请求是否放行
+	// 被拒绝时 retryAfter 为建议的等待时间；放行时为 0
+	Allow(ctx context.Context, key string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// ====== 令牌桶 ======
+/*
+令牌桶（Token Bucket）：
+  - 桶容量为 burst，初始是满的
+  - 以 rate 个/秒的速度补充令牌，最多补满
+  - 每个请求消耗一个令牌，没有令牌时拒绝
+
+补充是惰性计算的：每次 Allow 时根据距上次的时间差补充，不需要定时器。
+
+每个 key 一个桶，长时间不访问的桶会被清理，避免大量一次性 IP 撑爆内存。
+桶空闲超过"补满所需时间"后与新建的桶状态相同，删除不会影响限流结果。
+*/
+
+const minEvictInterval = time.Minute
+
+// bucket 单个 key 的令牌桶
+type bucket struct {
+	tokens float64   // 当前令牌数
+	last   time.Time // 上次补充时间
+}
+
+// TokenBucket 并发安全的内存令牌桶限流器
+type TokenBucket struct {
+	mu      sync.Mutex
+	rate    float64 // 每秒补充的令牌数
+	burst   float64 // 桶容量
+	buckets map[string]*bucket
+
+	idleTTL time.Duration // 桶空闲多久后可以删除
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	now func() time.Time // 便于替换时钟
+}
+
+// NewTokenBucket 创建令牌桶限流器
+// rate 为每秒补充的令牌数，burst 为桶容量（允许的突发请求数）
+// 会启动一个后台协程定期清理空闲的桶，使用 Stop 停止
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if rate <= 0 {
+		panic("ratelimit: rate must be positive")
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	// 空闲超过补满时间即可删除
+	idleTTL := time.Duration(float64(burst) / rate * float64(time.Second))
+	interval := max(idleTTL, minEvictInterval)
+
+	tb := &TokenBucket{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		idleTTL: idleTTL,
+		stop:    make(chan struct{}),
+		now:     time.Now,
+	}
+	go tb.janitor(interval)
+	return tb
+}
+
+// Allow 实现 Limiter 接口，不会返回错误
+func (tb *TokenBucket) Allow(_ context.Context, key string) (bool, time.Duration, error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := tb.now()
+	b, ok := tb.buckets[key]
+	if !ok {
+		b = &bucket{tokens: tb.burst, last: now}
+		tb.buckets[key] = b
+	}
+
+	// 按经过的时间补充令牌
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(tb.burst, b.tokens+elapsed.Seconds()*tb.rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+
+	// 还差多少令牌，按补充速度换算成等待时间
+	wait := (1 - b.tokens) / tb.rate
+	return false, time.Duration(math.Ceil(wait * float64(time.Second))), nil
+}
+
+// Len 返回当前桶的数量
+func (tb *TokenBucket) Len() int {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	return len(tb.buckets)
+}
+
+// Stop 停止后台清理协程
+func (tb *TokenBucket) Stop() {
+	tb.stopOnce.Do(func() { close(tb.stop) })
+}
+
+// janitor 定期删除空闲的桶
+func (tb *TokenBucket) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tb.stop:
+			return
+		case <-ticker.C:
+			tb.evictIdle()
+		}
+	}
+}
+
+// evictIdle 删除空闲超过 idleTTL 的桶
+func (tb *TokenBucket) evictIdle() {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := tb.now()
+	for key, b := range tb.buckets {
+		if now.Sub(b.last) >= tb.idleTTL {
+			delete(tb.buckets, key)
+		}
+	}
+}
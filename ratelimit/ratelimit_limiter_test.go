@@ -0,0 +1,196 @@
+// ratelimit/ratelimit_limiter_test.go
+// 限流器的测试，同一组用例分别在令牌桶和 Redis 滑动窗口上运行
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/austoin/GolangTutorial/testfixtures"
+)
+
+// fakeClock 可控的时钟
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newLimiterFunc 创建每个 key 在 window 内最多放行 limit 个请求的限流器
+type newLimiterFunc func(t *testing.T, limit int, window time.Duration, clock *fakeClock) Limiter
+
+// limiters 被测的两种实现
+var limiters = map[string]newLimiterFunc{
+	"TokenBucket": func(t *testing.T, limit int, window time.Duration, clock *fakeClock) Limiter {
+		tb := NewTokenBucket(float64(limit)/window.Seconds(), limit)
+		tb.now = clock.Now
+		t.Cleanup(tb.Stop)
+		return tb
+	},
+	"RedisSlidingWindow": func(t *testing.T, limit int, window time.Duration, clock *fakeClock) Limiter {
+		client, _ := testfixtures.NewTestRedis(t)
+		l := NewRedisSlidingWindow(client, limit, window)
+		l.now = clock.Now
+		return l
+	},
+}
+
+func TestLimiterBurstThenDeny(t *testing.T) {
+	for name, newLimiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := newLimiter(t, 3, time.Second, clock)
+			ctx := context.Background()
+
+			for i := 1; i <= 3; i++ {
+				if ok, retry, err := l.Allow(ctx, "ip-1"); !ok || retry != 0 || err != nil {
+					t.Fatalf("第 %d 个请求 Allow() = %v, %v, %v, want 放行", i, ok, retry, err)
+				}
+			}
+
+			ok, retry, err := l.Allow(ctx, "ip-1")
+			if ok || err != nil {
+				t.Fatalf("超出上限 Allow() = %v, %v, want 拒绝", ok, err)
+			}
+			if retry <= 0 || retry > time.Second {
+				t.Errorf("retryAfter = %v, want (0, 1s]", retry)
+			}
+
+			// 每个 key 独立计数
+			if ok, _, _ := l.Allow(ctx, "ip-2"); !ok {
+				t.Error("其他 key 的请求应该放行")
+			}
+
+			// 等待 retryAfter 之后可以再放行一个
+			clock.Advance(retry)
+			if ok, _, err := l.Allow(ctx, "ip-1"); !ok || err != nil {
+				t.Errorf("等待 %v 后 Allow() = %v, %v, want 放行", retry, ok, err)
+			}
+		})
+	}
+}
+
+func TestLimiterRecoversAfterWindow(t *testing.T) {
+	for name, newLimiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := newLimiter(t, 2, time.Minute, clock)
+			ctx := context.Background()
+
+			for range 2 {
+				l.Allow(ctx, "user")
+			}
+			if ok, _, _ := l.Allow(ctx, "user"); ok {
+				t.Fatal("用完配额后应该拒绝")
+			}
+
+			// 过了整个窗口，配额全部恢复
+			clock.Advance(time.Minute + time.Millisecond)
+			for i := 1; i <= 2; i++ {
+				if ok, _, _ := l.Allow(ctx, "user"); !ok {
+					t.Errorf("窗口过后第 %d 个请求被拒绝", i)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterConcurrent(t *testing.T) {
+	for name, newLimiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			l := newLimiter(t, 10, time.Minute, newFakeClock())
+
+			var allowed atomic.Int32
+			var wg sync.WaitGroup
+			for range 50 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ok, _, err := l.Allow(context.Background(), "shared")
+					if err != nil {
+						t.Errorf("Allow() error = %v", err)
+					}
+					if ok {
+						allowed.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := allowed.Load(); got != 10 {
+				t.Errorf("并发放行 %d 个, want 10（不能超发）", got)
+			}
+		})
+	}
+}
+
+func TestRedisSlidingWindowError(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	l := NewRedisSlidingWindow(client, 1, time.Second)
+	mr.Close()
+
+	// 限制重试时间，客户端默认会重试连接
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if ok, _, err := l.Allow(ctx, "k"); ok || err == nil {
+		t.Errorf("Redis 不可用时 Allow() = %v, %v, want 拒绝并返回错误", ok, err)
+	}
+}
+
+func TestTokenBucketEvictIdle(t *testing.T) {
+	clock := newFakeClock()
+	tb := NewTokenBucket(1, 5) // 5 秒补满
+	tb.now = clock.Now
+	defer tb.Stop()
+
+	tb.Allow(context.Background(), "a")
+	clock.Advance(3 * time.Second)
+	tb.Allow(context.Background(), "b")
+
+	clock.Advance(2 * time.Second)
+	tb.evictIdle()
+	if got := tb.Len(); got != 1 {
+		t.Errorf("Len() = %d, want 1（只清理空闲超过补满时间的 a）", got)
+	}
+}
+
+func TestNewLimiterPanics(t *testing.T) {
+	client, _ := testfixtures.NewTestRedis(t)
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"令牌桶速率为 0", func() { NewTokenBucket(0, 1) }},
+		{"滑动窗口上限为 0", func() { NewRedisSlidingWindow(client, 0, time.Second) }},
+		{"滑动窗口小于 1ms", func() { NewRedisSlidingWindow(client, 1, time.Microsecond) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("应该 panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// ratelimit/ratelimit_redis.go
+// 基于 Redis 的滑动窗口限流 - 详细注释版
+
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ====== 滑动窗口基础 ======
+/*
+固定窗口（INCR + EXPIRE）在窗口交界处可能放行两倍的请求：
+  limit=10，窗口 1 分钟
+  00:59 来了 10 个，01:00 又来了 10 个 → 两秒内放行 20 个
+
+滑动窗口日志（Sliding Window Log）记录每个请求的时间戳，
+只统计最近 window 时长内的请求，没有交界问题：
+
+  有序集合 ratelimit:{key}
+    member = 请求唯一标识，score = 请求时间（毫秒）
+
+  1. ZREMRANGEBYSCORE 删除 window 之前的记录
+  2. ZCARD 统计剩余数量
+  3. 未达上限：ZADD 当前请求，放行
+     已达上限：取最早的一条，等它滑出窗口的时间就是 retryAfter
+
+以上步骤在一个 Lua 脚本中完成，多个实例并发调用也不会超发。
+
+使用示例（database 包中的 RedisClient 通过 Client() 取得底层客户端）：
+  l := ratelimit.NewRedisSlidingWindow(rc.Client(), 100, time.Minute)
+  allowed, retryAfter, err := l.Allow(ctx, userID)
+
+内存占用与 limit 成正比，适合 limit 不太大（几千以内）的场景。
+*/
+
+const redisKeyPrefix = "ratelimit:"
+
+// slidingWindowScript 滑动窗口判断
+// KEYS[1] 有序集合；ARGV: 当前时间(ms)、窗口(ms)、上限、请求标识
+// 返回 {是否放行, retryAfter(ms)}
+var slidingWindowScript = redis.NewScript(`
+	local now    = tonumber(ARGV[1])
+	local window = tonumber(ARGV[2])
+	local limit  = tonumber(ARGV[3])
+
+	redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+
+	if redis.call("ZCARD", KEYS[1]) < limit then
+		redis.call("ZADD", KEYS[1], now, ARGV[4])
+		redis.call("PEXPIRE", KEYS[1], window)
+		return {1, 0}
+	end
+
+	local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+	local retry = tonumber(oldest[2]) + window - now
+	if retry < 1 then
+		retry = 1
+	end
+	return {0, retry}
+`)
+
+// RedisSlidingWindow 基于 Redis 有序集合的滑动窗口限流器
+type RedisSlidingWindow struct {
+	client redis.Cmdable
+	limit  int
+	window time.Duration
+
+	now func() time.Time // 便于替换时钟
+}
+
+// NewRedisSlidingWindow 创建滑动窗口限流器
+// 每个 key 在任意 window 时长内最多放行 limit 个请求
+func NewRedisSlidingWindow(client redis.Cmdable, limit int, window time.Duration) *RedisSlidingWindow {
+	if limit < 1 {
+		panic("ratelimit: limit must be positive")
+	}
+	if window < time.Millisecond {
+		panic("ratelimit: window must be at least 1ms")
+	}
+	return &RedisSlidingWindow{client: client, limit: limit, window: window, now: time.Now}
+}
+
+// Allow 实现 Limiter 接口
+func (l *RedisSlidingWindow) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	res, err := slidingWindowScript.Run(ctx, l.client,
+		[]string{redisKeyPrefix + key},
+		l.now().UnixMilli(), l.window.Milliseconds(), l.limit, requestMember(),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	if res[0] == 1 {
+		return true, 0, nil
+	}
+	return false, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// requestMember 生成有序集合成员
+// 同一毫秒内可能有多个请求，不能直接用时间戳作为成员
+func requestMember() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,61 @@
+// reqctx/reqctx_adapters.go
+// 框架 Context 适配器 - 详细注释版
+
+package reqctx
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+)
+
+// ====== 适配器 ======
+/*
+适配器读取框架 Context 中以 KeyRequestID、KeyUserID、KeyRoles 保存的值，
+写入请求原本的 context.Context（保留其取消信号和截止时间）。
+
+请求 ID 的来源按优先级：
+  1. 请求 context 中已有的（例如日志中间件写入的）
+  2. 框架 Context 中的 KeyRequestID
+  3. X-Request-ID 请求头
+
+类型不匹配的值（例如 user_id 被存成了 string）会被忽略。
+*/
+
+// FromGin 把 Gin Context 中的请求数据转换为标准 context.Context
+func FromGin(c *gin.Context) context.Context {
+	return fromValues(c.Request, func(key string) interface{} {
+		v, _ := c.Get(key)
+		return v
+	})
+}
+
+// FromEcho 把 Echo Context 中的请求数据转换为标准 context.Context
+func FromEcho(c echo.Context) context.Context {
+	return fromValues(c.Request(), c.Get)
+}
+
+// fromValues 按键读取框架中的值并写入请求的 context
+func fromValues(req *http.Request, get func(key string) interface{}) context.Context {
+	ctx := req.Context()
+
+	if RequestID(ctx) == "" {
+		id, _ := get(KeyRequestID).(string)
+		if id == "" {
+			id = req.Header.Get("X-Request-ID")
+		}
+		if id != "" {
+			ctx = WithRequestID(ctx, id)
+		}
+	}
+
+	if id, ok := get(KeyUserID).(uint); ok {
+		ctx = WithUserID(ctx, id)
+	}
+	if roles, ok := get(KeyRoles).([]string); ok {
+		ctx = WithRoles(ctx, roles)
+	}
+	return ctx
+}
@@ -0,0 +1,115 @@
+// reqctx/reqctx_adapters_test.go
+// 框架 Context 适配器的测试
+
+package reqctx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+)
+
+// adapterCase 适配器用例：框架 Context 中的值、请求头和请求 context 中已有的请求 ID
+type adapterCase struct {
+	name      string
+	values    map[string]interface{}
+	header    string // X-Request-ID
+	ctxID     string // 请求 context 中已有的请求 ID
+	wantID    string
+	wantUser  uint
+	wantOK    bool
+	wantRoles []string
+}
+
+var adapterCases = []adapterCase{
+	{
+		name:      "读取全部值",
+		values:    map[string]interface{}{KeyRequestID: "req-c", KeyUserID: uint(7), KeyRoles: []string{"admin"}},
+		wantID:    "req-c",
+		wantUser:  7,
+		wantOK:    true,
+		wantRoles: []string{"admin"},
+	},
+	{
+		name:   "请求 context 中的请求 ID 优先",
+		values: map[string]interface{}{KeyRequestID: "req-c"},
+		header: "req-h",
+		ctxID:  "req-ctx",
+		wantID: "req-ctx",
+	},
+	{
+		name:   "没有 KeyRequestID 时使用请求头",
+		header: "req-h",
+		wantID: "req-h",
+	},
+	{
+		name:   "类型不匹配的值被忽略",
+		values: map[string]interface{}{KeyUserID: "7", KeyRoles: "admin"},
+	},
+}
+
+// newAdapterRequest 按用例构造请求，请求 context 可以取消，用来检查取消信号是否被保留
+func newAdapterRequest(t *testing.T, tt adapterCase) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if tt.header != "" {
+		req.Header.Set("X-Request-ID", tt.header)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	t.Cleanup(cancel)
+	if tt.ctxID != "" {
+		ctx = WithRequestID(ctx, tt.ctxID)
+	}
+	return req.WithContext(ctx)
+}
+
+// checkAdapted 检查适配后的 context
+func checkAdapted(t *testing.T, ctx context.Context, req *http.Request, tt adapterCase) {
+	t.Helper()
+	if got := RequestID(ctx); got != tt.wantID {
+		t.Errorf("RequestID() = %q, want %q", got, tt.wantID)
+	}
+	if id, ok := UserID(ctx); id != tt.wantUser || ok != tt.wantOK {
+		t.Errorf("UserID() = %d, %v, want %d, %v", id, ok, tt.wantUser, tt.wantOK)
+	}
+	if got := Roles(ctx); !slices.Equal(got, tt.wantRoles) {
+		t.Errorf("Roles() = %v, want %v", got, tt.wantRoles)
+	}
+	// 保留请求原本的取消信号
+	if ctx.Done() != req.Context().Done() {
+		t.Error("适配后的 context 应该保留请求的取消信号")
+	}
+}
+
+func TestFromGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tt := range adapterCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = newAdapterRequest(t, tt)
+			for k, v := range tt.values {
+				c.Set(k, v)
+			}
+			checkAdapted(t, FromGin(c), c.Request, tt)
+		})
+	}
+}
+
+func TestFromEcho(t *testing.T) {
+	e := echo.New()
+	for _, tt := range adapterCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newAdapterRequest(t, tt)
+			c := e.NewContext(req, httptest.NewRecorder())
+			for k, v := range tt.values {
+				c.Set(k, v)
+			}
+			checkAdapted(t, FromEcho(c), req, tt)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// reqctx/reqctx_context.go
+// 请求级上下文数据 - 详细注释版
+
+package reqctx
+
+import (
+	"context"
+	"slices"
+
+	"github.com/austoin/GolangTutorial/logger"
+)
+
+// ====== 请求上下文基础 ======
+/*
+Gin 和 Echo 各自在框架的 Context 上用字符串键保存请求数据（c.Set("user_id", ...)），
+net/http 和 gRPC 则使用 context.Context。业务代码如果直接读取 *gin.Context，
+就无法在 Echo、gRPC 或单元测试中复用。
+
+本包在标准 context.Context 上提供类型安全的存取函数：
+
+  ctx = reqctx.WithUserID(ctx, 42)
+  ctx = reqctx.WithRoles(ctx, []string{"admin"})
+
+  id, ok := reqctx.UserID(ctx)
+  if reqctx.HasRole(ctx, "admin") { ... }
+
+处理器中用适配器把框架 Context 转换成标准 context.Context，再交给业务代码：
+
+  // Gin
+  svc.DoSomething(reqctx.FromGin(c))
+  // Echo
+  svc.DoSomething(reqctx.FromEcho(c))
+
+请求 ID 与 logger 包共用同一个键，写入后 logger.WithContext(ctx) 会自动带上 request_id。
+*/
+
+// 框架 Context 中使用的键名
+// 认证中间件用这些键调用 c.Set，适配器按同样的键读取
+const (
+	KeyRequestID = "request_id" // string
+	KeyUserID    = "user_id"    // uint
+	KeyRoles     = "roles"      // []string
+)
+
+// 使用私有类型作为 context 键，避免与其他包冲突
+type (
+	userIDKey struct{}
+	rolesKey  struct{}
+)
+
+// ====== 存取函数 ======
+
+// WithRequestID 把请求 ID 放入上下文
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return logger.ContextWithRequestID(ctx, id)
+}
+
+// RequestID 读取请求 ID，不存在时返回空字符串
+func RequestID(ctx context.Context) string {
+	return logger.RequestIDFromContext(ctx)
+}
+
+// WithUserID 把用户 ID 放入上下文
+func WithUserID(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, userIDKey{}, id)
+}
+
+// UserID 读取用户 ID，未认证时 ok 为 false
+func UserID(ctx context.Context) (id uint, ok bool) {
+	id, ok = ctx.Value(userIDKey{}).(uint)
+	return id, ok
+}
+
+// WithRoles 把角色列表放入上下文
+// 保存的是副本，之后修改 roles 不会影响上下文中的值
+func WithRoles(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, rolesKey{}, slices.Clone(roles))
+}
+
+// Roles 读取角色列表，返回副本；不存在时返回 nil
+func Roles(ctx context.Context) []string {
+	roles, _ := ctx.Value(rolesKey{}).([]string)
+	return slices.Clone(roles)
+}
+
+// HasRole 是否拥有指定角色
+func HasRole(ctx context.Context, role string) bool {
+	roles, _ := ctx.Value(rolesKey{}).([]string)
+	return slices.Contains(roles, role)
+}
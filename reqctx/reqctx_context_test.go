@@ -0,0 +1,76 @@
+// reqctx/reqctx_context_test.go
+// 请求级上下文数据的测试
+
+package reqctx
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/austoin/GolangTutorial/logger"
+)
+
+func TestRequestID(t *testing.T) {
+	ctx := context.Background()
+	if got := RequestID(ctx); got != "" {
+		t.Errorf("RequestID(空 ctx) = %q, want 空", got)
+	}
+
+	ctx = WithRequestID(ctx, "req-1")
+	if got := RequestID(ctx); got != "req-1" {
+		t.Errorf("RequestID() = %q, want req-1", got)
+	}
+	// 与 logger 包共用同一个键
+	if got := logger.RequestIDFromContext(ctx); got != "req-1" {
+		t.Errorf("logger.RequestIDFromContext() = %q, want req-1", got)
+	}
+}
+
+func TestUserID(t *testing.T) {
+	if _, ok := UserID(context.Background()); ok {
+		t.Error("UserID(空 ctx) ok = true, want false")
+	}
+	if id, ok := UserID(WithUserID(context.Background(), 42)); !ok || id != 42 {
+		t.Errorf("UserID() = %d, %v, want 42, true", id, ok)
+	}
+	// ID 为 0 也是已设置
+	if id, ok := UserID(WithUserID(context.Background(), 0)); !ok || id != 0 {
+		t.Errorf("UserID() = %d, %v, want 0, true", id, ok)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	if got := Roles(context.Background()); got != nil {
+		t.Errorf("Roles(空 ctx) = %v, want nil", got)
+	}
+
+	roles := []string{"admin", "editor"}
+	ctx := WithRoles(context.Background(), roles)
+
+	// 写入和读取都是副本，修改不会影响上下文中的值
+	roles[0] = "guest"
+	got := Roles(ctx)
+	got[1] = "hacker"
+	if want := []string{"admin", "editor"}; !slices.Equal(Roles(ctx), want) {
+		t.Errorf("Roles() = %v, want %v", Roles(ctx), want)
+	}
+
+	tests := []struct {
+		role string
+		want bool
+	}{
+		{"admin", true},
+		{"editor", true},
+		{"guest", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := HasRole(ctx, tt.role); got != tt.want {
+			t.Errorf("HasRole(%q) = %v, want %v", tt.role, got, tt.want)
+		}
+	}
+	if HasRole(context.Background(), "admin") {
+		t.Error("HasRole(空 ctx) = true, want false")
+	}
+}
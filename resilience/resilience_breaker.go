@@ -0,0 +1,230 @@
+// resilience/resilience_breaker.go
+// 熔断器 - 详细注释版
+
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ====== 熔断器基础 ======
+/*
+下游服务（gRPC、Redis、HTTP）故障时，如果调用方继续请求，
+不仅每次都要等到超时，还会加重下游的负担，使其更难恢复。
+
+熔断器（Circuit Breaker）像电路中的保险丝，有三种状态：
+
+  Closed（闭合）：正常放行，统计连续失败次数
+      │ 连续失败达到阈值
+      ▼
+  Open（断开）：直接拒绝调用，返回 ErrCircuitOpen
+      │ 冷却时间结束
+      ▼
+  HalfOpen（半开）：放行一个探测请求
+      ├── 探测成功 → Closed
+      └── 探测失败 → Open（重新计时）
+
+使用示例：
+  cb := resilience.NewCircuitBreaker(resilience.Config{
+      FailureThreshold: 5,
+      Cooldown:         10 * time.Second,
+      OnStateChange: func(from, to resilience.State) {
+          log.Printf("熔断器状态: %s -> %s", from, to)
+      },
+  })
+
+  err := cb.Execute(func() error {
+      return client.Ping(ctx).Err()
+  })
+  if errors.Is(err, resilience.ErrCircuitOpen) {
+      // 快速失败，可以走降级逻辑
+  }
+*/
+
+// ====== 错误定义 ======
+
+// ErrCircuitOpen 熔断器处于断开状态，调用被拒绝
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ====== 状态 ======
+
+// State 熔断器状态
+type State int
+
+const (
+	StateClosed   State = iota // 闭合：正常放行
+	StateOpen                  // 断开：拒绝调用
+	StateHalfOpen              // 半开：放行探测请求
+)
+
+// String 返回状态名称
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// ====== 配置 ======
+
+const (
+	defaultFailureThreshold = 5
+	defaultCooldown         = 30 * time.Second
+)
+
+// Config 熔断器配置
+type Config struct {
+	FailureThreshold int           // 连续失败多少次后断开，默认 5
+	Cooldown         time.Duration // 断开后多久进入半开，默认 30s
+
+	// OnStateChange 状态变化回调（可选）
+	// 在锁外同步调用，回调中可以安全地调用 State()
+	OnStateChange func(from, to State)
+}
+
+// ====== 熔断器 ======
+
+// CircuitBreaker 并发安全的熔断器
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	cfg      Config
+	state    State
+	failures int       // 闭合状态下的连续失败次数
+	openedAt time.Time // 进入断开状态的时间
+	probing  bool      // 半开状态下是否已有探测请求在执行
+
+	now func() time.Time // 便于替换时钟
+}
+
+// NewCircuitBreaker 创建熔断器，初始为闭合状态
+func NewCircuitBreaker(cfg Config) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultCooldown
+	}
+	return &CircuitBreaker{cfg: cfg, state: StateClosed, now: time.Now}
+}
+
+// State 返回当前状态
+// 断开状态下冷却时间已过时返回 StateHalfOpen
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == StateOpen && cb.cooldownElapsed() {
+		return StateHalfOpen
+	}
+	return cb.state
+}
+
+// Execute 通过熔断器执行 fn
+// 断开状态（或半开状态已有探测请求）时不执行 fn，直接返回 ErrCircuitOpen
+// fn 返回错误或 panic 都计为失败
+func (cb *CircuitBreaker) Execute(fn func() error) (err error) {
+	if err := cb.beforeCall(); err != nil {
+		return err
+	}
+
+	succeeded := false
+	defer func() {
+		cb.afterCall(succeeded)
+	}()
+
+	err = fn()
+	succeeded = err == nil
+	return err
+}
+
+// beforeCall 判断是否放行本次调用
+func (cb *CircuitBreaker) beforeCall() error {
+	cb.mu.Lock()
+
+	notify := func() {}
+	switch cb.state {
+	case StateOpen:
+		if !cb.cooldownElapsed() {
+			cb.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		// 冷却结束，进入半开状态，本次调用作为探测请求
+		notify = cb.setState(StateHalfOpen)
+		cb.probing = true
+
+	case StateHalfOpen:
+		// 同一时间只放行一个探测请求
+		if cb.probing {
+			cb.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+	}
+
+	cb.mu.Unlock()
+	notify()
+	return nil
+}
+
+// afterCall 记录调用结果并更新状态
+func (cb *CircuitBreaker) afterCall(succeeded bool) {
+	cb.mu.Lock()
+
+	notify := func() {}
+	switch cb.state {
+	case StateClosed:
+		if succeeded {
+			cb.failures = 0
+		} else {
+			cb.failures++
+			if cb.failures >= cb.cfg.FailureThreshold {
+				notify = cb.trip()
+			}
+		}
+
+	case StateHalfOpen:
+		cb.probing = false
+		if succeeded {
+			cb.failures = 0
+			notify = cb.setState(StateClosed)
+		} else {
+			notify = cb.trip()
+		}
+	}
+
+	cb.mu.Unlock()
+	notify()
+}
+
+// trip 进入断开状态，调用方需持有 cb.mu
+func (cb *CircuitBreaker) trip() func() {
+	cb.openedAt = cb.now()
+	cb.failures = 0
+	return cb.setState(StateOpen)
+}
+
+// setState 修改状态，调用方需持有 cb.mu
+// 返回在锁外执行的回调通知
+func (cb *CircuitBreaker) setState(to State) func() {
+	from := cb.state
+	cb.state = to
+
+	if from == to || cb.cfg.OnStateChange == nil {
+		return func() {}
+	}
+	callback := cb.cfg.OnStateChange
+	return func() { callback(from, to) }
+}
+
+// cooldownElapsed 冷却时间是否已过，调用方需持有 cb.mu
+func (cb *CircuitBreaker) cooldownElapsed() bool {
+	return cb.now().Sub(cb.openedAt) >= cb.cfg.Cooldown
+}
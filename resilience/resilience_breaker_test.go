@@ -0,0 +1,167 @@
+// resilience/resilience_breaker_test.go
+// 熔断器的测试
+
+package resilience
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errDownstream = errors.New("downstream failed")
+
+// newTestBreaker 使用可控时钟的熔断器，返回推进时钟的函数和记录的状态变化
+func newTestBreaker(threshold int, cooldown time.Duration) (cb *CircuitBreaker, advance func(time.Duration), changes func() []string) {
+	var (
+		mu      sync.Mutex
+		now     = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		history []string
+	)
+	cb = NewCircuitBreaker(Config{
+		FailureThreshold: threshold,
+		Cooldown:         cooldown,
+		OnStateChange: func(from, to State) {
+			mu.Lock()
+			defer mu.Unlock()
+			history = append(history, from.String()+"->"+to.String())
+		},
+	})
+	cb.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance = func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+	changes = func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(history)
+	}
+	return cb, advance, changes
+}
+
+func fail() error    { return errDownstream }
+func succeed() error { return nil }
+
+func TestCircuitBreakerLifecycle(t *testing.T) {
+	cb, advance, changes := newTestBreaker(3, 10*time.Second)
+
+	// 成功会清零连续失败次数
+	cb.Execute(fail)
+	cb.Execute(fail)
+	cb.Execute(succeed)
+	cb.Execute(fail)
+	cb.Execute(fail)
+	if got := cb.State(); got != StateClosed {
+		t.Fatalf("State() = %v, want closed（失败不连续）", got)
+	}
+
+	if err := cb.Execute(fail); !errors.Is(err, errDownstream) {
+		t.Fatalf("Execute() error = %v, want 下游错误", err)
+	}
+	if got := cb.State(); got != StateOpen {
+		t.Fatalf("连续失败 3 次后 State() = %v, want open", got)
+	}
+
+	called := false
+	if err := cb.Execute(func() error { called = true; return nil }); !errors.Is(err, ErrCircuitOpen) || called {
+		t.Errorf("断开时 Execute() = %v, 调用 fn = %v, want ErrCircuitOpen 且不调用", err, called)
+	}
+
+	// 探测失败重新断开并重新计时
+	advance(10 * time.Second)
+	if got := cb.State(); got != StateHalfOpen {
+		t.Fatalf("冷却结束后 State() = %v, want half-open", got)
+	}
+	if err := cb.Execute(fail); !errors.Is(err, errDownstream) {
+		t.Fatalf("探测 Execute() error = %v, want 下游错误", err)
+	}
+	if got := cb.State(); got != StateOpen {
+		t.Fatalf("探测失败后 State() = %v, want open", got)
+	}
+	advance(5 * time.Second)
+	if err := cb.Execute(succeed); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("重新计时未结束时 Execute() = %v, want ErrCircuitOpen", err)
+	}
+
+	// 探测成功恢复闭合
+	advance(5 * time.Second)
+	if err := cb.Execute(succeed); err != nil {
+		t.Fatalf("探测 Execute() error = %v", err)
+	}
+	if got := cb.State(); got != StateClosed {
+		t.Errorf("探测成功后 State() = %v, want closed", got)
+	}
+
+	want := []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}
+	if got := changes(); !slices.Equal(got, want) {
+		t.Errorf("状态变化 = %v, want %v", got, want)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	cb, advance, _ := newTestBreaker(1, time.Second)
+	cb.Execute(fail)
+	advance(time.Second)
+
+	// 第一个探测请求执行期间，其他请求被拒绝
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- cb.Execute(func() error {
+			close(probing)
+			<-release
+			return nil
+		})
+	}()
+	<-probing
+
+	if err := cb.Execute(succeed); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("探测期间 Execute() = %v, want ErrCircuitOpen", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("探测 Execute() error = %v", err)
+	}
+	if err := cb.Execute(succeed); err != nil {
+		t.Errorf("恢复后 Execute() error = %v", err)
+	}
+}
+
+func TestCircuitBreakerPanicCountsAsFailure(t *testing.T) {
+	cb, _, _ := newTestBreaker(1, time.Second)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recover() = %v, want boom", r)
+			}
+		}()
+		cb.Execute(func() error { panic("boom") })
+	}()
+
+	if got := cb.State(); got != StateOpen {
+		t.Errorf("panic 后 State() = %v, want open", got)
+	}
+}
+
+func TestNewCircuitBreakerDefaults(t *testing.T) {
+	cb := NewCircuitBreaker(Config{})
+	if cb.cfg.FailureThreshold != defaultFailureThreshold || cb.cfg.Cooldown != defaultCooldown {
+		t.Errorf("默认配置 = %+v, want 阈值 %d、冷却 %v", cb.cfg, defaultFailureThreshold, defaultCooldown)
+	}
+}
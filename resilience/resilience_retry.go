@@ -0,0 +1,120 @@
+// resilience/resilience_retry.go
+// 重试 - 详细注释版
+
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// ====== 重试基础 ======
+/*
+网络抖动、数据库主从切换、下游短暂过载时，失败的调用稍等片刻再试往往就能成功。
+
+  err := resilience.Retry(ctx, resilience.RetryConfig{MaxAttempts: 3}, func() error {
+      return client.Ping(ctx).Err()
+  })
+
+  // 需要返回值时使用 RetryWithResult
+  user, err := resilience.RetryWithResult(ctx, cfg, func() (*User, error) {
+      return db.GetUserByIDCtx(ctx, id)
+  })
+
+重试间隔为指数退避：BaseDelay * 2^n，最多 MaxDelay，
+并取 [d/2, d] 之间的随机值（抖动），避免大量调用方在同一时刻一起重试。
+
+不是所有错误都值得重试（参数错误、记录不存在重试也不会成功），用 RetryIf 过滤：
+
+  cfg := resilience.RetryConfig{
+      MaxAttempts: 5,
+      RetryIf: func(err error) bool {
+          return status.Code(err) == codes.Unavailable
+      },
+  }
+
+与熔断器配合：重试放在熔断器内层，熔断器断开时 Execute 直接返回 ErrCircuitOpen，
+应把 ErrCircuitOpen 排除在 RetryIf 之外，否则重试只会白白等待。
+*/
+
+// ====== 配置 ======
+
+const (
+	defaultMaxAttempts = 3
+	defaultBaseDelay   = 100 * time.Millisecond
+	defaultMaxDelay    = 5 * time.Second
+)
+
+// RetryConfig 重试配置
+type RetryConfig struct {
+	MaxAttempts int           // 最多执行次数（含第一次），默认 3
+	BaseDelay   time.Duration // 退避基础间隔，默认 100ms
+	MaxDelay    time.Duration // 单次等待上限，默认 5s
+
+	// RetryIf 判断错误是否需要重试（可选），默认所有错误都重试
+	RetryIf func(err error) bool
+}
+
+// withDefaults 填充默认值
+func (cfg RetryConfig) withDefaults() RetryConfig {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultBaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultMaxDelay
+	}
+	return cfg
+}
+
+// backoff 第 attempt 次重试前的等待时间（指数退避 + 抖动）
+func (cfg RetryConfig) backoff(attempt int) time.Duration {
+	d := cfg.BaseDelay << min(attempt, 30)
+	if d <= 0 || d > cfg.MaxDelay {
+		d = cfg.MaxDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// ====== 重试 ======
+
+// Retry 执行 fn，失败时按配置重试
+// 返回 nil 表示某次执行成功；重试耗尽或错误不可重试时返回最后一次的错误
+func Retry(ctx context.Context, cfg RetryConfig, fn func() error) error {
+	_, err := RetryWithResult(ctx, cfg, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// RetryWithResult 与 Retry 相同，成功时同时返回 fn 的结果
+// 失败时返回 T 的零值和最后一次的错误（不会返回失败调用产生的部分结果）
+// ctx 在等待期间被取消时返回 ctx.Err() 与最后一次错误的组合，两者都可以用 errors.Is 判断
+func RetryWithResult[T any](ctx context.Context, cfg RetryConfig, fn func() (T, error)) (T, error) {
+	cfg = cfg.withDefaults()
+	var zero T
+
+	for attempt := 0; ; attempt++ {
+		v, err := fn()
+		if err == nil {
+			return v, nil
+		}
+
+		if attempt+1 >= cfg.MaxAttempts || (cfg.RetryIf != nil && !cfg.RetryIf(err)) {
+			return zero, err
+		}
+
+		timer := time.NewTimer(cfg.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return zero, errors.Join(ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
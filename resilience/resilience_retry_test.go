@@ -0,0 +1,113 @@
+// resilience/resilience_retry_test.go
+// 重试的测试
+
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errNotFound = errors.New("not found")
+
+// fastRetry 间隔很短的重试配置
+func fastRetry(maxAttempts int) RetryConfig {
+	return RetryConfig{MaxAttempts: maxAttempts, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+}
+
+// failTimes 前 n 次调用返回 errDownstream（n 次之后返回 "ok"），calls 记录调用次数
+func failTimes(n int, calls *int) func() (string, error) {
+	return func() (string, error) {
+		*calls++
+		if *calls <= n {
+			return "partial", errDownstream
+		}
+		return "ok", nil
+	}
+}
+
+func TestRetryWithResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		attempts  int
+		want      string
+		wantErr   error
+		wantCalls int
+	}{
+		{"第一次成功", 0, 3, "ok", nil, 1},
+		{"重试后成功", 2, 3, "ok", nil, 3},
+		{"重试耗尽返回最后的错误", 5, 3, "", errDownstream, 3},
+		{"MaxAttempts 为 1 不重试", 1, 1, "", errDownstream, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			got, err := RetryWithResult(context.Background(), fastRetry(tt.attempts), failTimes(tt.failures, &calls))
+			// 失败时返回零值，而不是失败调用产生的部分结果
+			if got != tt.want || !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("RetryWithResult() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("调用 %d 次, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryIf(t *testing.T) {
+	cfg := fastRetry(5)
+	cfg.RetryIf = func(err error) bool { return !errors.Is(err, errNotFound) }
+
+	calls := 0
+	err := Retry(context.Background(), cfg, func() error {
+		calls++
+		if calls == 1 {
+			return errDownstream // 可重试
+		}
+		return errNotFound // 不可重试，立即返回
+	})
+	if !errors.Is(err, errNotFound) || calls != 2 {
+		t.Errorf("Retry() = %v, 调用 %d 次, want errNotFound 且 2 次", err, calls)
+	}
+}
+
+func TestRetryContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	cfg := RetryConfig{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: time.Second}
+
+	calls := 0
+	start := time.Now()
+	_, err := RetryWithResult(ctx, cfg, failTimes(10, &calls))
+
+	// ctx 取消时立即返回，两个错误都可以判断
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errDownstream) {
+		t.Errorf("RetryWithResult() error = %v, want 同时包含 DeadlineExceeded 和下游错误", err)
+	}
+	if calls != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("调用 %d 次, 耗时 %v, want 1 次且不等待完整的退避", calls, time.Since(start))
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	cfg := RetryConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}.withDefaults()
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for range 20 {
+			if d := cfg.backoff(attempt); d < want/2 || d > want {
+				t.Fatalf("backoff(%d) = %v, want [%v, %v]", attempt, d, want/2, want)
+			}
+		}
+	}
+	// 重试次数很大时不会溢出
+	if d := cfg.backoff(100); d < cfg.MaxDelay/2 || d > cfg.MaxDelay {
+		t.Errorf("backoff(100) = %v, want 不超过 MaxDelay", d)
+	}
+
+	def := RetryConfig{}.withDefaults()
+	if def.MaxAttempts != defaultMaxAttempts || def.BaseDelay != defaultBaseDelay || def.MaxDelay != defaultMaxDelay {
+		t.Errorf("默认配置 = %+v", def)
+	}
+}
@@ -0,0 +1,142 @@
+// result/result_types.go
+// 泛型 Result 与 Option - 详细注释版
+
+package result
+
+import "fmt"
+
+// ====== Result 基础 ======
+/*
+Go 的惯用写法是 (T, error) 两个返回值，每一步都要 if err != nil。
+在示例代码里对一批数据逐个处理时，可以把每一步的结果包装成 Result[T]，
+最后统一收集：
+
+  results := make([]result.Result[int], 0, len(inputs))
+  for _, s := range inputs {
+      results = append(results, result.Of(strconv.Atoi(s)))
+  }
+  nums, err := result.Collect(results) // 遇到第一个错误即返回
+
+  doubled := result.Map(result.Ok(21), func(n int) int { return n * 2 }) // Ok(42)
+  n := result.Err[int](err).UnwrapOr(-1)                                // -1
+
+Option[T] 表示"可能没有值"，比用指针或零值表示缺失更明确：
+
+  opt := result.Some("alice")
+  if name, ok := opt.Get(); ok { ... }
+
+注意：这只是为了让示例中的流水线写法更简洁，
+普通业务代码仍然推荐直接返回 (T, error)，这是 Go 生态的通用约定。
+*/
+
+// Result 要么是成功的值，要么是错误
+// 零值等价于 Ok(零值)
+type Result[T any] struct {
+	value T
+	err   error
+}
+
+// Ok 创建成功的 Result
+func Ok[T any](v T) Result[T] {
+	return Result[T]{value: v}
+}
+
+// Err 创建失败的 Result，err 为 nil 时等价于 Ok(零值)
+func Err[T any](err error) Result[T] {
+	return Result[T]{err: err}
+}
+
+// Of 把 (T, error) 形式的返回值包装成 Result
+//
+//	r := result.Of(strconv.Atoi("42"))
+func Of[T any](v T, err error) Result[T] {
+	if err != nil {
+		return Err[T](err)
+	}
+	return Ok(v)
+}
+
+// IsOk 是否成功
+func (r Result[T]) IsOk() bool {
+	return r.err == nil
+}
+
+// Get 拆回 (T, error) 形式
+func (r Result[T]) Get() (T, error) {
+	return r.value, r.err
+}
+
+// Unwrap 返回成功的值，失败时 panic
+// 只应在确定不会失败的地方使用（例如测试）
+func (r Result[T]) Unwrap() T {
+	if r.err != nil {
+		panic(fmt.Sprintf("result: Unwrap on error: %v", r.err))
+	}
+	return r.value
+}
+
+// UnwrapOr 成功时返回值，失败时返回 def
+func (r Result[T]) UnwrapOr(def T) T {
+	if r.err != nil {
+		return def
+	}
+	return r.value
+}
+
+// Map 对成功的值执行 fn，失败时原样传递错误，fn 不会被调用
+// Go 的方法不能有额外的类型参数，所以 Map 是函数而不是方法
+func Map[T, U any](r Result[T], fn func(T) U) Result[U] {
+	if r.err != nil {
+		return Err[U](r.err)
+	}
+	return Ok(fn(r.value))
+}
+
+// Collect 收集所有成功的值，遇到第一个错误立即返回该错误
+func Collect[T any](results []Result[T]) ([]T, error) {
+	values := make([]T, 0, len(results))
+	for _, r := range results {
+		if r.err != nil {
+			return nil, r.err
+		}
+		values = append(values, r.value)
+	}
+	return values, nil
+}
+
+// ====== Option ======
+
+// Option 可能有值也可能没有
+// 零值等价于 None
+type Option[T any] struct {
+	value T
+	ok    bool
+}
+
+// Some 创建有值的 Option
+func Some[T any](v T) Option[T] {
+	return Option[T]{value: v, ok: true}
+}
+
+// None 创建没有值的 Option
+func None[T any]() Option[T] {
+	return Option[T]{}
+}
+
+// Get 返回值以及是否存在
+func (o Option[T]) Get() (T, bool) {
+	return o.value, o.ok
+}
+
+// IsSome 是否有值
+func (o Option[T]) IsSome() bool {
+	return o.ok
+}
+
+// UnwrapOr 有值时返回值，否则返回 def
+func (o Option[T]) UnwrapOr(def T) T {
+	if !o.ok {
+		return def
+	}
+	return o.value
+}
@@ -0,0 +1,125 @@
+// result/result_types_test.go
+// 泛型 Result 与 Option 的测试
+
+package result
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+var errBad = errors.New("bad input")
+
+func TestResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		r         Result[int]
+		wantOk    bool
+		wantValue int
+	}{
+		{"Ok", Ok(42), true, 42},
+		{"Err", Err[int](errBad), false, 0},
+		{"Err(nil) 等价于 Ok(零值)", Err[int](nil), true, 0},
+		{"零值等价于 Ok(零值)", Result[int]{}, true, 0},
+		{"Of 成功", Of(strconv.Atoi("7")), true, 7},
+		{"Of 失败", Of(strconv.Atoi("x")), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.r.IsOk() != tt.wantOk {
+				t.Fatalf("IsOk() = %v, want %v", tt.r.IsOk(), tt.wantOk)
+			}
+			v, err := tt.r.Get()
+			if (err == nil) != tt.wantOk || (tt.wantOk && v != tt.wantValue) {
+				t.Errorf("Get() = %d, %v", v, err)
+			}
+			if got := tt.r.UnwrapOr(-1); tt.wantOk && got != tt.wantValue || !tt.wantOk && got != -1 {
+				t.Errorf("UnwrapOr(-1) = %d", got)
+			}
+		})
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	if got := Ok("alice").Unwrap(); got != "alice" {
+		t.Errorf("Unwrap() = %q, want alice", got)
+	}
+
+	defer func() {
+		if r := recover(); r != "result: Unwrap on error: bad input" {
+			t.Errorf("recover() = %v, want 包含错误信息的 panic", r)
+		}
+	}()
+	Err[string](errBad).Unwrap()
+}
+
+func TestMap(t *testing.T) {
+	double := func(n int) string { return strconv.Itoa(n * 2) }
+	if got := Map(Ok(21), double).Unwrap(); got != "42" {
+		t.Errorf("Map(Ok(21)) = %q, want 42", got)
+	}
+
+	// 失败时原样传递错误，fn 不会被调用
+	called := false
+	r := Map(Err[int](errBad), func(n int) string { called = true; return "" })
+	if _, err := r.Get(); !errors.Is(err, errBad) || called {
+		t.Errorf("Map(Err) error = %v, 调用 fn = %v, want errBad 且不调用", err, called)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	parse := func(inputs ...string) []Result[int] {
+		var results []Result[int]
+		for _, s := range inputs {
+			results = append(results, Of(strconv.Atoi(s)))
+		}
+		return results
+	}
+
+	values, err := Collect(parse("1", "2", "3"))
+	if err != nil || !slices.Equal(values, []int{1, 2, 3}) {
+		t.Errorf("Collect() = %v, %v, want [1 2 3]", values, err)
+	}
+
+	// 返回第一个错误
+	values, err = Collect(parse("1", "x", "y"))
+	var numErr *strconv.NumError
+	if values != nil || !errors.As(err, &numErr) || numErr.Num != "x" {
+		t.Errorf("Collect() = %v, %v, want nil 和 \"x\" 的错误", values, err)
+	}
+
+	if values, err := Collect[int](nil); err != nil || values == nil || len(values) != 0 {
+		t.Errorf("Collect(nil) = %v, %v, want []", values, err)
+	}
+}
+
+func TestOption(t *testing.T) {
+	tests := []struct {
+		name      string
+		opt       Option[string]
+		wantSome  bool
+		wantValue string
+	}{
+		{"Some", Some("alice"), true, "alice"},
+		{"Some 空字符串也是有值", Some(""), true, ""},
+		{"None", None[string](), false, ""},
+		{"零值等价于 None", Option[string]{}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := tt.opt.Get()
+			if ok != tt.wantSome || v != tt.wantValue || tt.opt.IsSome() != tt.wantSome {
+				t.Errorf("Get() = %q, %v, IsSome() = %v, want %q, %v", v, ok, tt.opt.IsSome(), tt.wantValue, tt.wantSome)
+			}
+			want := "def"
+			if tt.wantSome {
+				want = tt.wantValue
+			}
+			if got := tt.opt.UnwrapOr("def"); got != want {
+				t.Errorf("UnwrapOr() = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// safego/safego_go.go
+// 安全启动 Goroutine - 详细注释版
+
+package safego
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// ====== panic 与 Goroutine ======
+/*
+Goroutine 中未恢复的 panic 会让整个进程崩溃，
+而 recover 只能捕获当前 Goroutine 的 panic，调用方无法在外面兜底：
+
+  go func() {
+      handle(conn) // 这里 panic，整个服务器退出
+  }()
+
+本包把 panic 转换成错误：
+
+  // 单个 Goroutine，结果通过 channel 返回
+  errc := safego.Go(func() error {
+      return process(job)
+  })
+  if err := <-errc; err != nil {
+      var pe *safego.PanicError
+      if errors.As(err, &pe) {
+          log.Printf("panic: %v\n%s", pe.Value, pe.Stack)
+      }
+  }
+
+  // 一组 Goroutine，等待全部完成并汇总错误
+  err := safego.GoGroup(task1, task2, task3)
+
+  // 已经在自己的 Goroutine 中，只需要同步保护
+  err := safego.Run(fn)
+
+注意：只能恢复 fn 所在 Goroutine 的 panic，fn 内部再启动的 Goroutine 需要各自保护。
+*/
+
+// ====== 错误类型 ======
+
+// PanicError 从 panic 恢复得到的错误
+type PanicError struct {
+	Value interface{} // recover() 的返回值
+	Stack []byte      // panic 发生时的调用栈
+}
+
+// Error 实现 error 接口
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap panic 的值本身是 error 时返回它，便于 errors.Is 判断
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// ====== 启动函数 ======
+
+// Run 同步执行 fn，把 panic 转换为 *PanicError
+func Run(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// Go 在新的 Goroutine 中执行 fn
+// 返回的 channel 带一个缓冲，fn 结束后收到它的返回值（或 *PanicError）然后关闭，
+// 调用方不读取也不会导致 Goroutine 泄漏
+func Go(fn func() error) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		errc <- Run(fn)
+	}()
+	return errc
+}
+
+// GoGroup 并发执行所有 fns，等待全部完成
+// 返回所有非 nil 错误的 errors.Join，全部成功时返回 nil
+func GoGroup(fns ...func() error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for _, fn := range fns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Run(fn); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,99 @@
+// safego/safego_go_test.go
+// 安全启动 Goroutine 的测试
+
+package safego
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var errTask = errors.New("task failed")
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		fn        func() error
+		wantPanic bool
+		wantIs    error
+	}{
+		{"正常返回", func() error { return nil }, false, nil},
+		{"返回错误", func() error { return errTask }, false, errTask},
+		{"panic 字符串", func() error { panic("boom") }, true, nil},
+		{"panic error 可以用 errors.Is 判断", func() error { panic(errTask) }, true, errTask},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Run(tt.fn)
+
+			var pe *PanicError
+			if got := errors.As(err, &pe); got != tt.wantPanic {
+				t.Fatalf("Run() error = %v, want PanicError = %v", err, tt.wantPanic)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("Run() error = %v, want errors.Is %v", err, tt.wantIs)
+			}
+			if tt.wantIs == nil && !tt.wantPanic && err != nil {
+				t.Errorf("Run() error = %v, want nil", err)
+			}
+			if pe != nil && !strings.Contains(string(pe.Stack), "safego_go_test.go") {
+				t.Errorf("Stack 应该包含 panic 发生的位置:\n%s", pe.Stack)
+			}
+		})
+	}
+
+	if err := Run(func() error { panic("boom") }); err.Error() != "panic: boom" {
+		t.Errorf("Error() = %q, want %q", err.Error(), "panic: boom")
+	}
+}
+
+func TestGo(t *testing.T) {
+	errc := Go(func() error { panic("boom") })
+
+	var pe *PanicError
+	if err := <-errc; !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("<-Go() = %v, want PanicError boom", err)
+	}
+	// 结果发送后 channel 关闭
+	if _, ok := <-errc; ok {
+		t.Error("channel 应该已关闭")
+	}
+
+	// 不读取结果也不会阻塞 Goroutine
+	done := make(chan struct{})
+	Go(func() error { defer close(done); return errTask })
+	<-done
+}
+
+func TestGoGroup(t *testing.T) {
+	t.Run("全部成功返回 nil", func(t *testing.T) {
+		var ran atomic.Int32
+		task := func() error { ran.Add(1); return nil }
+		if err := GoGroup(task, task, task); err != nil || ran.Load() != 3 {
+			t.Errorf("GoGroup() = %v, 执行 %d 个, want nil 和 3", err, ran.Load())
+		}
+	})
+
+	t.Run("汇总所有错误和 panic", func(t *testing.T) {
+		var ran atomic.Int32
+		err := GoGroup(
+			func() error { ran.Add(1); return errTask },
+			func() error { ran.Add(1); panic("boom") },
+			func() error { ran.Add(1); return nil },
+		)
+		// 某个任务 panic 不影响其他任务执行
+		if ran.Load() != 3 {
+			t.Errorf("执行了 %d 个任务, want 3", ran.Load())
+		}
+		var pe *PanicError
+		if !errors.Is(err, errTask) || !errors.As(err, &pe) {
+			t.Errorf("GoGroup() = %v, want 同时包含 errTask 和 PanicError", err)
+		}
+	})
+
+	if err := GoGroup(); err != nil {
+		t.Errorf("GoGroup() 没有任务 = %v, want nil", err)
+	}
+}
@@ -0,0 +1,197 @@
+// scheduler/scheduler_cron.go
+// Cron 表达式解析 - 详细注释版
+
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ====== Cron 表达式 ======
+// 表达式中的 "*/n" 会提前结束块注释，因此本节说明使用行注释。
+//
+// 支持标准的 5 段格式：
+//
+//   ┌──────── 分钟 0-59
+//   │ ┌────── 小时 0-23
+//   │ │ ┌──── 日期 1-31
+//   │ │ │ ┌── 月份 1-12
+//   │ │ │ │ ┌ 星期 0-6（0 和 7 都表示周日）
+//   │ │ │ │ │
+//   * * * * *
+//
+// 每段支持：
+//   *        任意值
+//   5        单个值
+//   1-5      范围
+//   */15     步长（从最小值开始每隔 15）
+//   10-30/5  范围内的步长
+//   1,15,30  以上形式用逗号组合
+//
+// 示例：
+//   "*/5 * * * *"   每 5 分钟
+//   "0 3 * * *"     每天 03:00
+//   "30 9 * * 1-5"  工作日 09:30
+//   "0 0 1 * *"     每月 1 日 00:00
+//
+// 日期和星期同时限定时（都不是 *），满足其一即可，与 Vixie cron 的行为一致：
+//   "0 0 13 * 5"    每月 13 日以及每个周五
+//
+// 不支持月份/星期的英文名称、@daily 等简写，以及秒级精度。
+// 时间按 time.Local 计算。
+
+// cronField 一段表达式的取值范围
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// cronSchedule 解析后的 cron 表达式，每段用位图表示允许的取值
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool // 日期/星期是否以 * 开头（* 或 */n），与 cron 的判断方式一致
+}
+
+// parseCron 解析 5 段 cron 表达式
+func parseCron(spec string) (*cronSchedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("scheduler: cron spec %q must have %d fields, got %d", spec, len(cronFields), len(parts))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("scheduler: cron spec %q: %w", spec, err)
+		}
+		bits[i] = b
+	}
+
+	// 7 与 0 都表示周日
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: strings.HasPrefix(parts[2], "*"),
+		dowAny: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parseCronField 解析一段表达式，返回允许取值的位图
+func parseCronField(expr string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expr, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepPart, f.name)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = parseCronValue(a, f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseCronValue(b, f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q in %s", rangePart, f.name)
+			}
+		default:
+			v, err := parseCronValue(rangePart, f)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// "5/10" 表示从 5 开始每隔 10，没有步长时只有单个值
+			if !hasStep {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// parseCronValue 解析单个数值并检查范围
+func parseCronValue(s string, f cronField) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s", s, f.name)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s value %d out of range [%d, %d]", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// next 返回 after 之后（不含）第一个满足表达式的时刻
+// 5 年内都没有匹配（例如 "0 0 30 2 *"）时返回零值
+func (c *cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	loc := t.Location()
+
+	// 从大到小逐段匹配，不满足时直接跳到下一个月/日/小时，而不是逐分钟尝试
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 日期和星期的组合判断
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
@@ -0,0 +1,81 @@
+// scheduler/scheduler_cron_test.go
+// Cron 表达式解析的测试
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// at 构造 UTC 时间，2024-01-01 是周一
+func at(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"段数不足", "* * * *"},
+		{"段数过多", "* * * * * *"},
+		{"分钟越界", "60 * * * *"},
+		{"小时越界", "* 24 * * *"},
+		{"日期为 0", "* * 0 * *"},
+		{"月份越界", "* * * 13 *"},
+		{"星期越界", "* * * * 8"},
+		{"步长为 0", "*/0 * * * *"},
+		{"步长不是数字", "*/x * * * *"},
+		{"范围颠倒", "30-10 * * * *"},
+		{"范围端点无效", "1-x * * * *"},
+		{"不是数字", "five * * * *"},
+		{"不支持英文名称", "0 0 * * MON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCron(tt.spec); err == nil {
+				t.Errorf("parseCron(%q) 应该返回错误", tt.spec)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"每 5 分钟", "*/5 * * * *", at(2024, 1, 1, 10, 3), at(2024, 1, 1, 10, 5)},
+		{"不包含 after 本身", "*/5 * * * *", at(2024, 1, 1, 10, 5), at(2024, 1, 1, 10, 10)},
+		{"忽略秒数", "* * * * *", at(2024, 1, 1, 10, 5).Add(30 * time.Second), at(2024, 1, 1, 10, 6)},
+		{"每天 03:00 跨天", "0 3 * * *", at(2024, 1, 1, 3, 0), at(2024, 1, 2, 3, 0)},
+		{"工作日跳过周末", "30 9 * * 1-5", at(2024, 1, 5, 10, 0), at(2024, 1, 8, 9, 30)},
+		{"每月 1 日", "0 0 1 * *", at(2024, 1, 15, 0, 0), at(2024, 2, 1, 0, 0)},
+		{"跨年", "0 0 1 1 *", at(2024, 6, 1, 0, 0), at(2025, 1, 1, 0, 0)},
+		{"范围内的步长", "10-30/10 * * * *", at(2024, 1, 1, 10, 25), at(2024, 1, 1, 10, 30)},
+		{"范围步长结束后进入下一小时", "10-30/10 * * * *", at(2024, 1, 1, 10, 30), at(2024, 1, 1, 11, 10)},
+		{"单值加步长", "5/20 * * * *", at(2024, 1, 1, 10, 26), at(2024, 1, 1, 10, 45)},
+		{"逗号列表", "0 8,12,18 * * *", at(2024, 1, 1, 12, 0), at(2024, 1, 1, 18, 0)},
+		{"7 表示周日", "0 0 * * 7", at(2024, 1, 1, 0, 0), at(2024, 1, 7, 0, 0)},
+		{"0 表示周日", "0 0 * * 0", at(2024, 1, 1, 0, 0), at(2024, 1, 7, 0, 0)},
+		{"日期和星期满足其一：周五先到", "0 0 13 * 5", at(2024, 1, 1, 0, 0), at(2024, 1, 5, 0, 0)},
+		{"日期和星期满足其一：13 日先到", "0 0 13 * 5", at(2024, 1, 12, 0, 0), at(2024, 1, 13, 0, 0)},
+		{"星期为 */n 时两者都要满足", "0 0 13 * */1", at(2024, 1, 1, 0, 0), at(2024, 1, 13, 0, 0)},
+		{"闰年 2 月 29 日", "0 0 29 2 *", at(2024, 3, 1, 0, 0), at(2028, 2, 29, 0, 0)},
+		{"永远不会匹配", "0 0 30 2 *", at(2024, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("parseCron(%q) error = %v", tt.spec, err)
+			}
+			if got := c.next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("next(%v) = %v, want %v", tt.after, got, tt.want)
+			}
+		})
+	}
+}
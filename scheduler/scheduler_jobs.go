@@ -0,0 +1,225 @@
+// scheduler/scheduler_jobs.go
+// 进程内定时任务 - 详细注释版
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/austoin/GolangTutorial/logger"
+	"github.com/austoin/GolangTutorial/safego"
+)
+
+// ====== 定时任务基础 ======
+/*
+示例中经常直接写 for { time.Sleep(...) } 或 time.Ticker 来执行周期任务，
+容易遗漏的问题：
+  - 任务 panic 导致整个进程退出
+  - 任务耗时超过间隔，多次执行叠在一起（例如两个清理任务同时删除同一批数据）
+  - 进程退出时无法等待正在执行的任务结束
+
+Scheduler 统一处理：
+
+  s := scheduler.New()
+  s.Every(30*time.Second, func(ctx context.Context) {
+      cleanupExpiredSessions(ctx)
+  })
+  if err := s.At("0 3 * * *", dailyReport); err != nil { // 每天 03:00，格式见 scheduler_cron.go
+      log.Fatal(err)
+  }
+
+  s.Start(ctx)
+  defer s.Stop() // 取消 ctx 并等待正在执行的任务返回
+
+行为：
+  - 每个任务在自己的 Goroutine 中执行，panic 会被恢复并记录日志
+  - 到点时上一次执行还没结束则跳过本次（Skipped 计数 +1），不会叠加
+  - Every 按固定节拍触发（start + n*d），不受任务耗时影响而漂移
+  - 传给任务的 ctx 在 Stop 时取消，长任务应检查 ctx.Done()
+
+只在单个进程内调度；多实例部署时每个实例都会执行，需要只执行一次的任务要配合分布式锁。
+*/
+
+// ====== 调度器 ======
+
+// schedule 计算下一次执行时间
+type schedule interface {
+	next(after time.Time) time.Time
+}
+
+// everySchedule 固定间隔
+type everySchedule time.Duration
+
+func (e everySchedule) next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+// job 一个定时任务
+type job struct {
+	name    string
+	sched   schedule
+	fn      func(ctx context.Context)
+	running atomic.Bool  // 是否有一次执行尚未结束
+	runs    atomic.Int64 // 已开始执行的次数
+	skipped atomic.Int64 // 因上一次未结束而跳过的次数
+}
+
+// Scheduler 定时任务调度器，并发安全
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    []*job
+	ctx     context.Context // Start 之后有效
+	cancel  context.CancelFunc
+	loops   sync.WaitGroup // 调度循环
+	running sync.WaitGroup // 正在执行的任务
+
+	now func() time.Time // 便于替换时钟
+}
+
+// New 创建调度器
+func New() *Scheduler {
+	return &Scheduler{now: time.Now}
+}
+
+// Every 每隔 d 执行一次 fn，第一次在 Start 之后 d 执行
+// d 必须为正数
+func (s *Scheduler) Every(d time.Duration, fn func(ctx context.Context)) {
+	if d <= 0 {
+		panic("scheduler: interval must be positive")
+	}
+	s.add(&job{name: "every " + d.String(), sched: everySchedule(d), fn: fn})
+}
+
+// At 按 cron 表达式执行 fn，表达式无效时返回错误
+func (s *Scheduler) At(spec string, fn func(ctx context.Context)) error {
+	sched, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+	s.add(&job{name: fmt.Sprintf("cron %q", spec), sched: sched, fn: fn})
+	return nil
+}
+
+// add 注册任务；调度器已启动时立即开始调度
+func (s *Scheduler) add(j *job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, j)
+	if s.ctx != nil {
+		s.startLoop(j)
+	}
+}
+
+// Start 开始调度，ctx 取消时停止；重复调用不做任何事
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx != nil {
+		return
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	for _, j := range s.jobs {
+		s.startLoop(j)
+	}
+}
+
+// Stop 停止调度，并等待正在执行的任务返回
+// 任务收到的 ctx 会被取消
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	s.loops.Wait()
+	s.running.Wait()
+}
+
+// startLoop 启动任务的调度循环，调用方需持有 s.mu
+func (s *Scheduler) startLoop(j *job) {
+	ctx := s.ctx
+	s.loops.Add(1)
+	go func() {
+		defer s.loops.Done()
+
+		last := s.now()
+		for {
+			next := j.sched.next(last)
+			if next.IsZero() {
+				logger.Warn("scheduler: job will never run", "job", j.name)
+				return
+			}
+
+			timer := time.NewTimer(next.Sub(s.now()))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			s.trigger(ctx, j)
+			last = next
+		}
+	}()
+}
+
+// trigger 执行一次任务；上一次执行还没结束时跳过
+func (s *Scheduler) trigger(ctx context.Context, j *job) {
+	if !j.running.CompareAndSwap(false, true) {
+		j.skipped.Add(1)
+		logger.Warn("scheduler: previous run still active, skipped", "job", j.name)
+		return
+	}
+	j.runs.Add(1)
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer j.running.Store(false)
+
+		err := safego.Run(func() error {
+			j.fn(ctx)
+			return nil
+		})
+		if err != nil {
+			logger.Error("scheduler: job panicked", "job", j.name, "err", err)
+		}
+	}()
+}
+
+// ====== 统计 ======
+
+// JobStats 任务执行统计
+type JobStats struct {
+	Name    string // 任务描述，如 "every 1m0s"、`cron "0 3 * * *"`
+	Runs    int64  // 已开始执行的次数
+	Skipped int64  // 因上一次未结束而跳过的次数
+	Running bool   // 当前是否正在执行
+}
+
+// Stats 返回所有任务的执行统计，顺序与注册顺序相同
+func (s *Scheduler) Stats() []JobStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]JobStats, len(s.jobs))
+	for i, j := range s.jobs {
+		stats[i] = JobStats{
+			Name:    j.name,
+			Runs:    j.runs.Load(),
+			Skipped: j.skipped.Load(),
+			Running: j.running.Load(),
+		}
+	}
+	return stats
+}
@@ -0,0 +1,178 @@
+// scheduler/scheduler_jobs_test.go
+// 调度器的测试
+
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor 轮询直到 cond 成立，超时则失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEveryRunCount(t *testing.T) {
+	s := New()
+	var runs atomic.Int64
+	s.Every(10*time.Millisecond, func(ctx context.Context) { runs.Add(1) })
+
+	start := time.Now()
+	s.Start(context.Background())
+	waitFor(t, "执行 5 次", func() bool { return runs.Load() >= 5 })
+	s.Stop()
+
+	// 第 5 次在 Start 之后 50ms 触发
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("5 次执行耗时 %v, want >= 50ms", elapsed)
+	}
+
+	// Stop 之后不再执行
+	stopped := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	if got := runs.Load(); got != stopped {
+		t.Errorf("Stop 后又执行了 %d 次", got-stopped)
+	}
+	if st := s.Stats()[0]; st.Runs != stopped || st.Skipped != 0 || st.Running {
+		t.Errorf("Stats() = %+v, want Runs %d、无跳过且不在执行", st, stopped)
+	}
+}
+
+func TestEverySkipsOverlappingRuns(t *testing.T) {
+	s := New()
+	release := make(chan struct{})
+	var runs atomic.Int64
+	s.Every(5*time.Millisecond, func(ctx context.Context) {
+		runs.Add(1)
+		<-release
+	})
+
+	s.Start(context.Background())
+	waitFor(t, "跳过 3 次", func() bool { return s.Stats()[0].Skipped >= 3 })
+
+	// 第一次执行没结束，后面的触发全部跳过
+	if st := s.Stats()[0]; st.Runs != 1 || !st.Running {
+		t.Errorf("Stats() = %+v, want Runs 1 且正在执行", st)
+	}
+	close(release)
+	waitFor(t, "上一次结束后恢复执行", func() bool { return runs.Load() >= 2 })
+	s.Stop()
+}
+
+func TestJobPanicRecovered(t *testing.T) {
+	s := New()
+	var runs atomic.Int64
+	s.Every(5*time.Millisecond, func(ctx context.Context) {
+		if runs.Add(1) == 1 {
+			panic("boom")
+		}
+	})
+
+	s.Start(context.Background())
+	// panic 之后调度继续，并且 running 标记已清除
+	waitFor(t, "panic 后继续执行", func() bool { return runs.Load() >= 3 })
+	s.Stop()
+}
+
+func TestStopCancelsJobContext(t *testing.T) {
+	s := New()
+	started := make(chan struct{})
+	var canceled atomic.Bool
+	s.Every(5*time.Millisecond, func(ctx context.Context) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		canceled.Store(true)
+	})
+
+	s.Start(context.Background())
+	<-started
+	s.Stop()
+
+	// Stop 等待正在执行的任务返回
+	if !canceled.Load() {
+		t.Error("Stop 返回时任务应该已经收到取消并返回")
+	}
+}
+
+func TestStartContextCanceled(t *testing.T) {
+	s := New()
+	var runs atomic.Int64
+	s.Every(5*time.Millisecond, func(ctx context.Context) { runs.Add(1) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	waitFor(t, "开始执行", func() bool { return runs.Load() >= 1 })
+	cancel()
+	s.Stop()
+
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if got := runs.Load(); got != stopped {
+		t.Errorf("ctx 取消后又执行了 %d 次", got-stopped)
+	}
+}
+
+func TestAddAfterStart(t *testing.T) {
+	s := New()
+	s.Start(context.Background())
+	defer s.Stop()
+
+	var runs atomic.Int64
+	s.Every(5*time.Millisecond, func(ctx context.Context) { runs.Add(1) })
+	waitFor(t, "启动后注册的任务执行", func() bool { return runs.Load() >= 2 })
+}
+
+func TestAtCron(t *testing.T) {
+	s := New()
+	// 时钟停在整分钟前 10ms，下一次匹配在 10ms 后，再下一次在一分钟后
+	now := time.Date(2024, 1, 1, 10, 0, 59, 990_000_000, time.Local)
+	s.now = func() time.Time { return now }
+
+	var runs atomic.Int64
+	if err := s.At("* * * * *", func(ctx context.Context) { runs.Add(1) }); err != nil {
+		t.Fatalf("At() error = %v", err)
+	}
+	s.Start(context.Background())
+	waitFor(t, "cron 任务执行", func() bool { return runs.Load() >= 1 })
+	time.Sleep(30 * time.Millisecond)
+	s.Stop()
+
+	if got := runs.Load(); got != 1 {
+		t.Errorf("执行 %d 次, want 1", got)
+	}
+	if got := s.Stats()[0].Name; got != `cron "* * * * *"` {
+		t.Errorf("Name = %s", got)
+	}
+}
+
+func TestAtInvalidSpec(t *testing.T) {
+	s := New()
+	if err := s.At("61 * * * *", func(ctx context.Context) {}); err == nil {
+		t.Fatal("At() 应该返回错误")
+	}
+	if got := len(s.Stats()); got != 0 {
+		t.Errorf("无效表达式不应注册任务, 任务数 = %d", got)
+	}
+}
+
+func TestEveryPanicsOnNonPositive(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Every(0) 应该 panic")
+		}
+	}()
+	New().Every(0, func(ctx context.Context) {})
+}
@@ -0,0 +1,63 @@
+// testfixtures/testfixtures_db.go
+// 测试用的 SQLite 数据库 - 详细注释版
+
+package testfixtures
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ====== 内存数据库 ======
+/*
+":memory:" 的数据库属于单个连接，连接池中的每个连接看到的都是不同的空库，
+所以连接池限制为 1 个连接。每次调用都得到一个全新的库。
+
+SQLite 与 MySQL 的差异：
+  - 类型宽松，size:50 之类的长度约束不会生效
+  - 外键约束默认不开启（DSN 中已加 _foreign_keys=on）
+  - 不支持行锁（SELECT ... FOR UPDATE），并发相关的逻辑测不出来
+
+需要验证 MySQL 特有行为的测试仍应连接真实数据库。
+*/
+
+// memoryDSN 内存库并开启外键约束
+const memoryDSN = "file::memory:?_foreign_keys=on"
+
+// NewTestDB 打开内存 SQLite 并迁移 models
+// 日志为静默模式，翻译驱动错误（唯一索引冲突为 gorm.ErrDuplicatedKey）
+func NewTestDB(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	return NewTestDBWithConfig(t, &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	}, models...)
+}
+
+// NewTestDBWithConfig 使用指定的 GORM 配置打开内存 SQLite
+// 被测代码依赖命名策略（表前缀）等配置时，传入与生产相同的配置
+func NewTestDBWithConfig(t testing.TB, cfg *gorm.Config, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(memoryDSN), cfg)
+	if err != nil {
+		t.Fatalf("testfixtures: open sqlite: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("testfixtures: get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	if len(models) > 0 {
+		if err := db.AutoMigrate(models...); err != nil {
+			t.Fatalf("testfixtures: migrate: %v", err)
+		}
+	}
+	return db
+}
@@ -0,0 +1,81 @@
+// testfixtures/testfixtures_db_test.go
+// NewTestDB 的测试
+
+package testfixtures
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type fixtureUser struct {
+	ID    uint
+	Email string `gorm:"uniqueIndex"`
+}
+
+type fixturePost struct {
+	ID     uint
+	UserID uint
+	User   fixtureUser
+}
+
+func TestNewTestDB(t *testing.T) {
+	var sqlDB *sql.DB
+	t.Run("基本操作", func(t *testing.T) {
+		db := NewTestDB(t, &fixtureUser{}, &fixturePost{})
+
+		if err := db.Create(&fixtureUser{Email: "a@example.com"}).Error; err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		var got fixtureUser
+		if err := db.First(&got, "email = ?", "a@example.com").Error; err != nil {
+			t.Fatalf("First() error = %v", err)
+		}
+
+		// TranslateError：唯一索引冲突翻译为 gorm.ErrDuplicatedKey
+		err := db.Create(&fixtureUser{Email: "a@example.com"}).Error
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			t.Errorf("重复 Create() error = %v, want %v", err, gorm.ErrDuplicatedKey)
+		}
+
+		// 外键约束已开启
+		err = db.Create(&fixturePost{UserID: 999}).Error
+		if !errors.Is(err, gorm.ErrForeignKeyViolated) {
+			t.Errorf("外键不存在时 Create() error = %v, want %v", err, gorm.ErrForeignKeyViolated)
+		}
+
+		sqlDB, _ = db.DB()
+	})
+
+	// 子测试结束时 Cleanup 已经关闭连接
+	if err := sqlDB.Ping(); err == nil {
+		t.Error("Cleanup 后 Ping() 仍然成功")
+	}
+}
+
+func TestNewTestDBIsolated(t *testing.T) {
+	a := NewTestDB(t, &fixtureUser{})
+	b := NewTestDB(t, &fixtureUser{})
+
+	if err := a.Create(&fixtureUser{Email: "a@example.com"}).Error; err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	var n int64
+	if err := b.Model(&fixtureUser{}).Count(&n).Error; err != nil || n != 0 {
+		t.Errorf("另一个库 Count() = %d, %v, want 0", n, err)
+	}
+}
+
+func TestNewTestDBWithConfig(t *testing.T) {
+	db := NewTestDBWithConfig(t, &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{TablePrefix: "t_"},
+	}, &fixtureUser{})
+
+	if !db.Migrator().HasTable("t_fixture_users") {
+		t.Error("表前缀没有生效，t_fixture_users 不存在")
+	}
+}
@@ -0,0 +1,66 @@
+// testfixtures/testfixtures_redis_test.go
+// NewTestRedis 的测试
+
+package testfixtures
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewTestRedis(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		client *redis.Client
+		addr   string
+	)
+	t.Run("基本操作", func(t *testing.T) {
+		var mr *miniredis.Miniredis
+		client, mr = NewTestRedis(t)
+		addr = mr.Addr()
+
+		if err := client.Set(ctx, "greeting", "hello", time.Minute).Err(); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		got, err := client.Get(ctx, "greeting").Result()
+		if err != nil || got != "hello" {
+			t.Fatalf("Get() = %q, %v, want %q", got, err, "hello")
+		}
+		// 直接检查 miniredis 中的数据和 TTL
+		if v, _ := mr.Get("greeting"); v != "hello" {
+			t.Errorf("mr.Get() = %q, want %q", v, "hello")
+		}
+		mr.FastForward(time.Minute)
+		if mr.Exists("greeting") {
+			t.Error("FastForward 之后键仍然存在")
+		}
+	})
+
+	// 子测试结束时 Cleanup 已经执行：客户端已关闭，服务已停止
+	if err := client.Ping(ctx).Err(); err != redis.ErrClosed {
+		t.Errorf("Cleanup 后 Ping() error = %v, want %v", err, redis.ErrClosed)
+	}
+	if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		conn.Close()
+		t.Errorf("Cleanup 后仍能连接 %s", addr)
+	}
+}
+
+func TestNewTestRedisIsolated(t *testing.T) {
+	ctx := context.Background()
+	a, _ := NewTestRedis(t)
+	b, _ := NewTestRedis(t)
+
+	if err := a.Set(ctx, "k", "v", 0).Err(); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := b.Get(ctx, "k").Err(); err != redis.Nil {
+		t.Errorf("另一个实例 Get() error = %v, want redis.Nil", err)
+	}
+}
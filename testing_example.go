@@ -27,6 +27,8 @@ import (
 	"fmt"
 	"math"
 	"testing"
+
+	"github.com/austoin/GolangTutorial/memstore"
 )
 
 // ====== 被测试的代码 ======
@@ -281,27 +283,32 @@ type User struct {
 }
 
 // MockDatabase Mock 数据库实现
+// 基于 memstore.MemStore，不用再手写 map 和锁
 type MockDatabase struct {
-	users map[int]User
+	users *memstore.MemStore[int, User]
 }
 
 func NewMockDatabase() *MockDatabase {
 	return &MockDatabase{
-		users: make(map[int]User),
+		users: memstore.New(func(u User) int { return u.ID }),
 	}
 }
 
 func (m *MockDatabase) GetUser(id int) (User, error) {
-	user, ok := m.users[id]
-	if !ok {
+	user, err := m.users.Get(id)
+	if errors.Is(err, memstore.ErrNotFound) {
 		return User{}, errors.New("用户不存在")
 	}
-	return user, nil
+	return user, err
 }
 
+// CreateUser 与原来的 map 实现一样，ID 已存在时覆盖旧记录
 func (m *MockDatabase) CreateUser(user User) error {
-	m.users[user.ID] = user
-	return nil
+	_, err := m.users.Create(user)
+	if errors.Is(err, memstore.ErrExists) {
+		return m.users.Update(user)
+	}
+	return err
 }
 
 // UserService 用户服务
@@ -0,0 +1,162 @@
+// tracing/tracing_grpc_test.go
+// gRPC 追踪拦截器的测试，客户端和服务端通过 bufconn 连接
+
+package tracing
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startHealthServer 启动带追踪拦截器的健康检查服务，返回带客户端拦截器的连接
+func startHealthServer(t *testing.T) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor()),
+		grpc.StreamInterceptor(StreamServerInterceptor()),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+// spanByKind 按 SpanKind 查找 Span
+func spanByKind(t *testing.T, spans tracetest.SpanStubs, kind trace.SpanKind) tracetest.SpanStub {
+	t.Helper()
+	for _, s := range spans {
+		if s.SpanKind == kind {
+			return s
+		}
+	}
+	t.Fatalf("没有 %v Span, got %v", kind, spans.Snapshots())
+	return tracetest.SpanStub{}
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   string
+		wantCode  grpccodes.Code
+		wantError bool
+	}{
+		{"成功", "", grpccodes.OK, false},
+		{"未知服务返回 NotFound", "no.such.Service", grpccodes.NotFound, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := newTestExporter(t, Config{})
+			client := startHealthServer(t)
+
+			ctx, parent := tracer().Start(context.Background(), "caller")
+			// 调用方已有的 metadata 保留，且不被修改
+			md := metadata.Pairs("x-request-id", "req-1")
+			ctx = metadata.NewOutgoingContext(ctx, md)
+			_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: tt.service})
+			parent.End()
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("Check() error = %v, want %v", err, tt.wantCode)
+			}
+			if len(md) != 1 {
+				t.Errorf("调用方的 metadata 被修改: %v", md)
+			}
+
+			got := spans()
+			if len(got) != 3 {
+				t.Fatalf("导出 %d 个 Span, want 3（caller、客户端、服务端）", len(got))
+			}
+			clientSpan := spanByKind(t, got, trace.SpanKindClient)
+			serverSpan := spanByKind(t, got, trace.SpanKindServer)
+
+			// caller → 客户端 Span → 服务端 Span，同一个 trace
+			if clientSpan.Parent.SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("客户端 Span 的父 Span = %v, want caller", clientSpan.Parent.SpanID())
+			}
+			if serverSpan.Parent.SpanID() != clientSpan.SpanContext.SpanID() || !serverSpan.Parent.IsRemote() {
+				t.Errorf("服务端 Span 的父 Span = %v, want 远程的客户端 Span %v", serverSpan.Parent.SpanID(), clientSpan.SpanContext.SpanID())
+			}
+			if serverSpan.SpanContext.TraceID() != parent.SpanContext().TraceID() {
+				t.Error("服务端 Span 不在调用方的 trace 中")
+			}
+
+			for _, span := range []tracetest.SpanStub{clientSpan, serverSpan} {
+				if span.Name != "grpc.health.v1.Health/Check" {
+					t.Errorf("Span 名称 = %q, want grpc.health.v1.Health/Check", span.Name)
+				}
+				if v, _ := attr(span, "rpc.service"); v.AsString() != "grpc.health.v1.Health" {
+					t.Errorf("rpc.service = %q", v.AsString())
+				}
+				if v, _ := attr(span, "rpc.method"); v.AsString() != "Check" {
+					t.Errorf("rpc.method = %q", v.AsString())
+				}
+				if v, _ := attr(span, "rpc.grpc.status_code"); v.AsInt64() != int64(tt.wantCode) {
+					t.Errorf("rpc.grpc.status_code = %d, want %d", v.AsInt64(), tt.wantCode)
+				}
+				if (span.Status.Code == codes.Error) != tt.wantError {
+					t.Errorf("%v Span Status = %+v, wantError %v", span.SpanKind, span.Status, tt.wantError)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	spans := newTestExporter(t, Config{})
+	client := startHealthServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	if got := spans(); len(got) != 0 {
+		t.Fatalf("流结束前导出了 %d 个 Span, want 0（Span 覆盖整个流）", len(got))
+	}
+
+	// 客户端取消后处理器返回，Span 结束
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	var got tracetest.SpanStubs
+	for len(got) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		got = spans()
+	}
+	if len(got) != 1 {
+		t.Fatalf("导出 %d 个 Span, want 1", len(got))
+	}
+	span := got[0]
+	if span.Name != "grpc.health.v1.Health/Watch" || span.SpanKind != trace.SpanKindServer {
+		t.Errorf("Span = %q (%v), want grpc.health.v1.Health/Watch (server)", span.Name, span.SpanKind)
+	}
+	if v, _ := attr(span, "rpc.grpc.status_code"); v.AsInt64() != int64(grpccodes.Canceled) {
+		t.Errorf("rpc.grpc.status_code = %d, want Canceled", v.AsInt64())
+	}
+}
@@ -0,0 +1,143 @@
+// tracing/tracing_http.go
+// HTTP 追踪中间件 - 详细注释版
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ====== HTTP 中间件 ======
+/*
+每个请求创建一个 Server Span：
+
+  名称    "GET /api/v1/users/:id"（使用路由模板而不是实际路径，避免每个 ID 一个名称）
+  属性    http.request.method、url.path、http.route、http.response.status_code
+  状态    5xx 时标记为 Error 并记录处理器返回的错误；4xx 是客户端的问题，不算 Span 错误
+
+请求头中的 traceparent 会被提取为父 Span；处理器中用 c.Request().Context()
+（Gin 为 c.Request.Context()）调用下游，新的 Span 会自动挂在这个请求下面。
+
+路由模板只有在路由匹配之后才知道，所以先用 "GET" 创建 Span，处理结束后再改名。
+*/
+
+// startServerSpan 提取上游的追踪上下文并创建 Server Span
+func startServerSpan(r *http.Request) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return tracer().Start(ctx, r.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		),
+	)
+}
+
+// endHTTPSpan 记录路由、状态码和错误后结束 Span
+func endHTTPSpan(span trace.Span, method, route string, status int, err error) {
+	if route != "" {
+		span.SetName(method + " " + route)
+		span.SetAttributes(attribute.String("http.route", route))
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+
+	if status >= http.StatusInternalServerError {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+	span.End()
+}
+
+// HTTPMiddleware net/http 的追踪中间件
+// 使用 Go 1.23+ 的 ServeMux 时以匹配的模式（r.Pattern，去掉开头的方法）作为路由
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := startServerSpan(r)
+		r = r.WithContext(ctx)
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		endHTTPSpan(span, r.Method, patternRoute(r.Pattern), sw.status, nil)
+	})
+}
+
+// patternRoute 从 ServeMux 模式中取出路由："GET /users/{id}" → "/users/{id}"
+func patternRoute(pattern string) string {
+	if _, route, ok := strings.Cut(pattern, " "); ok {
+		return strings.TrimLeft(route, " \t")
+	}
+	return pattern
+}
+
+// statusWriter 记录响应状态码
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// GinMiddleware Gin 的追踪中间件
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, span := startServerSpan(c.Request)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		var err error
+		if last := c.Errors.Last(); last != nil {
+			err = last.Err
+		}
+		endHTTPSpan(span, c.Request.Method, c.FullPath(), c.Writer.Status(), err)
+	}
+}
+
+// EchoMiddleware Echo 的追踪中间件
+// 处理器返回的错误按 *echo.HTTPError 的状态码记录，其他错误按 500 记录
+func EchoMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx, span := startServerSpan(req)
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				status = http.StatusInternalServerError
+				var he *echo.HTTPError
+				if errors.As(err, &he) {
+					status = he.Code
+				}
+			}
+			endHTTPSpan(span, req.Method, c.Path(), status, err)
+			return err
+		}
+	}
+}
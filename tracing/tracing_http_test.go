@@ -0,0 +1,194 @@
+// tracing/tracing_http_test.go
+// HTTP 追踪中间件的测试，同一组用例分别在 net/http、Gin 和 Echo 上运行
+
+package tracing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errBoom = errors.New("database is down")
+
+// httpCases 请求 path 时处理器返回 wantStatus
+var httpCases = []struct {
+	name       string
+	path       string
+	wantStatus int
+	wantError  bool
+}{
+	{"成功", "/users/42", http.StatusOK, false},
+	{"4xx 不算 Span 错误", "/users/bad", http.StatusBadRequest, false},
+	{"5xx 标记为 Error", "/users/boom", http.StatusInternalServerError, true},
+}
+
+// statusFor 处理器按 id 决定的状态码
+func statusFor(id string) int {
+	switch id {
+	case "bad":
+		return http.StatusBadRequest
+	case "boom":
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+// checkHTTPSpan 检查唯一导出的 Server Span
+// handlerSpan 是处理器从请求 ctx 中取到的 Span
+func checkHTTPSpan(t *testing.T, got tracetest.SpanStubs, route, path string, wantStatus int, wantError bool,
+	parent, handlerSpan trace.SpanContext) tracetest.SpanStub {
+	t.Helper()
+	if len(got) != 1 {
+		t.Fatalf("导出 %d 个 Span, want 1", len(got))
+	}
+	span := got[0]
+	// 名称使用路由模板而不是实际路径
+	if want := "GET " + route; span.Name != want || span.SpanKind != trace.SpanKindServer {
+		t.Errorf("Span = %q (%v), want %q (server)", span.Name, span.SpanKind, want)
+	}
+	if v, _ := attr(span, "http.route"); v.AsString() != route {
+		t.Errorf("http.route = %q, want %q", v.AsString(), route)
+	}
+	if v, _ := attr(span, "url.path"); v.AsString() != path {
+		t.Errorf("url.path = %q, want %q", v.AsString(), path)
+	}
+	if v, _ := attr(span, "http.response.status_code"); v.AsInt64() != int64(wantStatus) {
+		t.Errorf("http.response.status_code = %d, want %d", v.AsInt64(), wantStatus)
+	}
+	if (span.Status.Code == codes.Error) != wantError {
+		t.Errorf("Status = %+v, wantError %v", span.Status, wantError)
+	}
+	// 上游的 traceparent 成为父 Span，处理器的 ctx 中是本次的 Server Span
+	if span.Parent.SpanID() != parent.SpanID() || span.SpanContext.TraceID() != parent.TraceID() {
+		t.Errorf("父 Span = %v/%v, want %v/%v", span.SpanContext.TraceID(), span.Parent.SpanID(), parent.TraceID(), parent.SpanID())
+	}
+	if handlerSpan.SpanID() != span.SpanContext.SpanID() {
+		t.Errorf("处理器 ctx 中的 Span = %v, want %v", handlerSpan.SpanID(), span.SpanContext.SpanID())
+	}
+	return span
+}
+
+// recordedError 返回 Span 上 RecordError 记录的错误信息
+func recordedError(span tracetest.SpanStub) string {
+	for _, ev := range span.Events {
+		if ev.Name == "exception" {
+			for _, kv := range ev.Attributes {
+				if kv.Key == "exception.message" {
+					return kv.Value.AsString()
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	for _, tt := range httpCases {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := newTestExporter(t, Config{})
+			parent, header := remoteParent(t)
+
+			var handlerSpan trace.SpanContext
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(r.Context())
+				w.WriteHeader(statusFor(r.PathValue("id")))
+			})
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header = header
+			HTTPMiddleware(mux).ServeHTTP(httptest.NewRecorder(), req)
+
+			checkHTTPSpan(t, spans(), "/users/{id}", tt.path, tt.wantStatus, tt.wantError, parent, handlerSpan)
+		})
+	}
+}
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tt := range httpCases {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := newTestExporter(t, Config{})
+			parent, header := remoteParent(t)
+
+			var handlerSpan trace.SpanContext
+			r := gin.New()
+			r.Use(GinMiddleware())
+			r.GET("/users/:id", func(c *gin.Context) {
+				handlerSpan = trace.SpanContextFromContext(c.Request.Context())
+				status := statusFor(c.Param("id"))
+				if status >= http.StatusInternalServerError {
+					c.Error(errBoom)
+				}
+				c.Status(status)
+			})
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header = header
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			span := checkHTTPSpan(t, spans(), "/users/:id", tt.path, tt.wantStatus, tt.wantError, parent, handlerSpan)
+			// c.Errors 中的错误记录到 Span
+			if tt.wantError && recordedError(span) != errBoom.Error() {
+				t.Errorf("记录的错误 = %q, want %q", recordedError(span), errBoom)
+			}
+		})
+	}
+}
+
+func TestEchoMiddleware(t *testing.T) {
+	for _, tt := range httpCases {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := newTestExporter(t, Config{})
+			parent, header := remoteParent(t)
+
+			var handlerSpan trace.SpanContext
+			e := echo.New()
+			e.Use(EchoMiddleware())
+			e.GET("/users/:id", func(c echo.Context) error {
+				handlerSpan = trace.SpanContextFromContext(c.Request().Context())
+				switch status := statusFor(c.Param("id")); status {
+				case http.StatusOK:
+					return c.NoContent(status)
+				case http.StatusBadRequest:
+					// *echo.HTTPError 按其状态码记录
+					return echo.NewHTTPError(status, "bad id")
+				default:
+					// 其他错误按 500 记录
+					return errBoom
+				}
+			})
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header = header
+			e.ServeHTTP(httptest.NewRecorder(), req)
+
+			span := checkHTTPSpan(t, spans(), "/users/:id", tt.path, tt.wantStatus, tt.wantError, parent, handlerSpan)
+			if tt.wantError && recordedError(span) != errBoom.Error() {
+				t.Errorf("记录的错误 = %q, want %q", recordedError(span), errBoom)
+			}
+		})
+	}
+}
+
+func TestPatternRoute(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"GET /users/{id}", "/users/{id}"},
+		{"POST  /users", "/users"},
+		{"/static/", "/static/"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := patternRoute(tt.pattern); got != tt.want {
+			t.Errorf("patternRoute(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// tracing/tracing_provider.go
+// OpenTelemetry 链路追踪初始化 - 详细注释版
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// ====== 链路追踪基础 ======
+/*
+一个请求经过 网关 → Web 服务 → gRPC 服务 → 数据库，日志分散在各个服务中，
+请求 ID 只能把同一服务内的日志串起来。链路追踪把整条调用链记录为一棵 Span 树：
+
+  trace 4bf92f35...
+  └─ GET /api/v1/users/:id            (Echo, 12ms)
+     └─ user.UserService/GetUser      (gRPC 客户端 → 服务端, 8ms)
+        └─ SELECT t_users             (5ms)
+
+上下游之间通过 W3C traceparent 头传递 trace ID 和父 Span：
+
+  traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+
+本包提供：
+  - Init：初始化全局 TracerProvider（OTLP 导出）和 W3C 传播器
+  - HTTPMiddleware / GinMiddleware / EchoMiddleware：每个 HTTP 请求一个 Server Span
+  - UnaryServerInterceptor / StreamServerInterceptor / UnaryClientInterceptor：gRPC Span
+
+用法：
+
+  shutdown, err := tracing.Init(ctx, tracing.Config{
+      Enabled:     os.Getenv("OTEL_ENABLED") == "true",
+      ServiceName: "user-api",
+      Endpoint:    "localhost:4317", // OpenTelemetry Collector / Jaeger 的 OTLP gRPC 端口
+      Insecure:    true,
+  })
+  if err != nil { log.Fatal(err) }
+  defer shutdown(context.Background()) // 退出前把缓冲的 Span 发送出去
+
+  e.Use(tracing.EchoMiddleware())
+  grpc.NewServer(grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor(), ...))
+
+Enabled 为 false 时安装 no-op 实现：中间件照常工作但不产生 Span，几乎没有开销；
+下游传来的 traceparent 仍会放入 context，继续传给更下游的服务。
+
+安装：
+  go get go.opentelemetry.io/otel \
+         go.opentelemetry.io/otel/sdk \
+         go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc
+*/
+
+// instrumentationName 本包创建的 Tracer 名称
+const instrumentationName = "github.com/austoin/GolangTutorial/tracing"
+
+// Config 链路追踪配置
+type Config struct {
+	Enabled     bool    // 为 false 时使用 no-op 实现
+	ServiceName string  // 服务名，显示在追踪系统中
+	Endpoint    string  // OTLP gRPC 地址，如 "localhost:4317"，为空时使用 OTEL_EXPORTER_OTLP_ENDPOINT 或默认值
+	Insecure    bool    // 不使用 TLS 连接 Endpoint（本地 Collector 常用）
+	SampleRatio float64 // 采样比例 (0, 1]，默认 1（全部采样）；上游已决定采样时跟随上游
+}
+
+// Init 按配置安装全局 TracerProvider 和传播器
+// 返回的 shutdown 在进程退出前调用，刷新尚未发送的 Span
+func Init(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	if !cfg.Enabled {
+		otel.SetTextMapPropagator(newPropagator())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{}
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: create OTLP exporter: %w", err)
+	}
+
+	return InitWithExporter(cfg, exporter)
+}
+
+// InitWithExporter 使用指定的导出器安装全局 TracerProvider
+// 用于测试（tracetest.NewInMemoryExporter）或非 OTLP 的后端
+func InitWithExporter(cfg Config, exporter sdktrace.SpanExporter) (shutdown func(context.Context) error, err error) {
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: build resource: %w", err)
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(newPropagator())
+	return tp.Shutdown, nil
+}
+
+// newPropagator W3C traceparent/tracestate 和 baggage
+func newPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// tracer 每次从全局 TracerProvider 获取，Init 之后创建的中间件和之前创建的行为一致
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
@@ -0,0 +1,141 @@
+// tracing/tracing_provider_test.go
+// 链路追踪初始化的测试，以及各测试共用的内存导出器
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestExporter 用内存导出器安装全局 TracerProvider，测试结束后恢复原来的全局设置
+// 返回的函数刷新批处理器中的 Span 并返回目前导出的全部 Span
+func newTestExporter(t *testing.T, cfg Config) func() tracetest.SpanStubs {
+	t.Helper()
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := InitWithExporter(cfg, exporter)
+	if err != nil {
+		t.Fatalf("InitWithExporter() error = %v", err)
+	}
+	tp := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	t.Cleanup(func() {
+		shutdown(context.Background())
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	return func() tracetest.SpanStubs {
+		t.Helper()
+		// WithBatcher 异步导出，读取前先刷新
+		if err := tp.ForceFlush(context.Background()); err != nil {
+			t.Fatalf("ForceFlush() error = %v", err)
+		}
+		return exporter.GetSpans()
+	}
+}
+
+// attr 查找 Span 的属性
+func attr(span tracetest.SpanStub, key string) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// remoteParent 构造一个上游 Span 的 traceparent 请求头
+func remoteParent(t *testing.T) (trace.SpanContext, http.Header) {
+	t.Helper()
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	header := http.Header{}
+	header.Set("traceparent", "00-"+traceID.String()+"-"+spanID.String()+"-01")
+	return sc, header
+}
+
+func TestInitWithExporter(t *testing.T) {
+	spans := newTestExporter(t, Config{ServiceName: "user-api"})
+
+	_, span := tracer().Start(context.Background(), "work")
+	span.End()
+
+	got := spans()
+	if len(got) != 1 {
+		t.Fatalf("导出 %d 个 Span, want 1", len(got))
+	}
+	if got[0].Name != "work" || got[0].InstrumentationScope.Name != instrumentationName {
+		t.Errorf("Span = %q (scope %q), want work (scope %q)", got[0].Name, got[0].InstrumentationScope.Name, instrumentationName)
+	}
+	if v, ok := got[0].Resource.Set().Value("service.name"); !ok || v.AsString() != "user-api" {
+		t.Errorf("service.name = %v, want user-api", v.AsString())
+	}
+}
+
+func TestInitWithExporterSampler(t *testing.T) {
+	spans := newTestExporter(t, Config{SampleRatio: 0.000001})
+	parent, header := remoteParent(t)
+
+	// 几乎不采样，但上游已决定采样时跟随上游
+	_, root := tracer().Start(context.Background(), "root")
+	root.End()
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+	_, child := tracer().Start(ctx, "child")
+	child.End()
+
+	got := spans()
+	if len(got) != 1 || got[0].Name != "child" {
+		t.Fatalf("导出的 Span = %v, want 只有 child", got.Snapshots())
+	}
+	if got[0].Parent.SpanID() != parent.SpanID() {
+		t.Errorf("child 的父 Span = %v, want %v", got[0].Parent.SpanID(), parent.SpanID())
+	}
+}
+
+func TestInitDisabled(t *testing.T) {
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	shutdown, err := Init(context.Background(), Config{Enabled: false})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer shutdown(context.Background())
+
+	_, span := tracer().Start(context.Background(), "work")
+	if span.SpanContext().IsValid() {
+		t.Error("禁用时不应该产生 Span")
+	}
+	span.End()
+
+	// 上游的 traceparent 照常传递
+	parent, header := remoteParent(t)
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+	if got := trace.SpanContextFromContext(ctx); !got.Equal(parent) {
+		t.Errorf("提取的上下文 = %v, want %v", got, parent)
+	}
+}
@@ -0,0 +1,248 @@
+// validate/validate_tags.go
+// 基于结构体标签的数据验证 - 详细注释版
+
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ====== 标签验证基础 ======
+/*
+Gin 和 Echo 通过 go-playground/validator 提供验证，
+原生 net/http 没有这个能力，这里用反射实现一个精简版本。
+
+用法：
+  type CreateUserRequest struct {
+      Username string `json:"username" validate:"required,min=3,max=50"`
+      Email    string `json:"email" validate:"required,email"`
+      Age      int    `json:"age" validate:"min=0,max=150"`
+  }
+
+  if err := validate.Struct(&req); err != nil {
+      // err 的类型是 validate.ValidationErrors，包含每个字段的错误
+  }
+
+支持的规则：
+  - required: 不能是零值（空字符串、0、nil 等）
+  - min=N: 字符串/切片/映射的长度 >= N，数值 >= N
+  - max=N: 字符串/切片/映射的长度 <= N，数值 <= N
+  - email: 合法的邮箱地址（空字符串跳过，需要时配合 required）
+
+错误中的字段名依次取 json、form、query 标签，都没有时使用结构体字段名；
+嵌套结构体（包括指针）会递归验证，字段名形如 "address.city"。
+*/
+
+// ====== 错误类型 ======
+
+// FieldError 单个字段的验证错误
+type FieldError struct {
+	Field   string       // 字段名（优先使用 json、form、query 标签）
+	Rule    string       // 失败的规则，如 "min"
+	Param   string       // 规则参数，如 "3"
+	Kind    reflect.Kind // 字段值的类型（指针取指向的类型），决定 min/max 比较的是长度还是数值
+	Message string       // 可读的错误信息
+}
+
+// Error 实现 error 接口
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors 所有字段的验证错误
+type ValidationErrors []FieldError
+
+// Error 实现 error 接口，把所有错误用分号拼接
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, e := range ve {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ====== 验证入口 ======
+
+// Struct 验证结构体（或结构体指针）
+// 全部通过返回 nil，否则返回 ValidationErrors
+func Struct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return fmt.Errorf("validate: nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: expected struct, got %s", rv.Kind())
+	}
+
+	var errs ValidationErrors
+	validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateStruct 遍历结构体字段
+// prefix 为嵌套字段的路径前缀
+func validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		fv := rv.Field(i)
+		name := prefix + fieldName(sf)
+
+		// 先验证字段自身的规则
+		if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+			for _, rule := range strings.Split(tag, ",") {
+				if fe, ok := checkRule(fv, name, strings.TrimSpace(rule)); !ok {
+					*errs = append(*errs, fe)
+				}
+			}
+		}
+
+		// 再递归验证嵌套结构体
+		nested := fv
+		if nested.Kind() == reflect.Ptr && !nested.IsNil() {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct {
+			validateStruct(nested, name+".", errs)
+		}
+	}
+}
+
+// nameTags 确定字段名时依次查找的标签，与 bind 包读取的标签一致
+var nameTags = []string{"json", "form", "query"}
+
+// fieldName 返回字段在错误信息中使用的名称
+// 优先使用客户端看到的参数名（json、form、query 标签），便于客户端定位
+func fieldName(sf reflect.StructField) string {
+	for _, key := range nameTags {
+		if name, _, _ := strings.Cut(sf.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// ====== 规则实现 ======
+
+// checkRule 检查单条规则，返回是否通过
+func checkRule(fv reflect.Value, field, rule string) (FieldError, bool) {
+	if rule == "" {
+		return FieldError{}, true
+	}
+
+	name, param, _ := strings.Cut(rule, "=")
+	fe := FieldError{Field: field, Rule: name, Param: param, Kind: fv.Kind()}
+	if fv.Kind() == reflect.Ptr {
+		fe.Kind = fv.Type().Elem().Kind()
+	}
+
+	switch name {
+	case "required":
+		if fv.IsZero() {
+			fe.Message = fmt.Sprintf("%s is required", field)
+			return fe, false
+		}
+
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			fe.Message = fmt.Sprintf("%s: invalid %s parameter %q", field, name, param)
+			return fe, false
+		}
+
+		n, isLen, ok := measure(fv)
+		if !ok {
+			// 不支持的类型跳过
+			return fe, true
+		}
+
+		if (name == "min" && n < limit) || (name == "max" && n > limit) {
+			fe.Message = boundMessage(field, name, param, isLen)
+			return fe, false
+		}
+
+	case "email":
+		s, ok := stringValue(fv)
+		if !ok || s == "" {
+			return fe, true
+		}
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			fe.Message = fmt.Sprintf("%s must be a valid email address", field)
+			return fe, false
+		}
+
+	default:
+		fe.Message = fmt.Sprintf("%s: unknown validation rule %q", field, name)
+		return fe, false
+	}
+
+	return fe, true
+}
+
+// measure 返回用于 min/max 比较的数值
+// isLen 为 true 表示比较的是长度
+func measure(fv reflect.Value) (n float64, isLen bool, ok bool) {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return 0, false, false
+		}
+		fv = fv.Elem()
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		// 按字符计数，中文也算一个字符
+		return float64(utf8.RuneCountInString(fv.String())), true, true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false, true
+	}
+	return 0, false, false
+}
+
+// stringValue 取字符串值（支持 *string）
+func stringValue(fv reflect.Value) (string, bool) {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return "", false
+		}
+		fv = fv.Elem()
+	}
+	if fv.Kind() != reflect.String {
+		return "", false
+	}
+	return fv.String(), true
+}
+
+// boundMessage 生成 min/max 的错误信息
+func boundMessage(field, rule, param string, isLen bool) string {
+	word := "at least"
+	if rule == "max" {
+		word = "at most"
+	}
+	if isLen {
+		return fmt.Sprintf("%s length must be %s %s", field, word, param)
+	}
+	return fmt.Sprintf("%s must be %s %s", field, word, param)
+}
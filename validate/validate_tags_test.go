@@ -0,0 +1,143 @@
+// validate/validate_tags_test.go
+// 结构体标签验证的测试
+
+package validate
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// failedRules 把错误转换为 "字段:规则" 列表，便于比较
+func failedRules(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var ve ValidationErrors
+	if !errors.As(err, &ve) {
+		t.Fatalf("error 类型 = %T, want ValidationErrors", err)
+	}
+	var got []string
+	for _, fe := range ve {
+		got = append(got, fe.Field+":"+fe.Rule)
+	}
+	return got
+}
+
+func TestStructRules(t *testing.T) {
+	type req struct {
+		Name  string   `json:"name" validate:"required,min=3,max=5"`
+		Email string   `json:"email" validate:"email"`
+		Age   int      `json:"age" validate:"min=18,max=60"`
+		Score float64  `validate:"max=1.5"`
+		Tags  []string `json:"tags" validate:"min=1"`
+		Nick  *string  `json:"nick" validate:"max=3"`
+		Skip  string   `json:"-" validate:"-"`
+	}
+	valid := func() req {
+		return req{Name: "alice", Email: "a@example.com", Age: 30, Tags: []string{"go"}}
+	}
+	long := "long"
+
+	tests := []struct {
+		name string
+		mod  func(r *req)
+		want []string
+	}{
+		{"全部通过", func(r *req) {}, nil},
+		{"required 空字符串", func(r *req) { r.Name = "" }, []string{"name:required", "name:min"}},
+		{"min 长度", func(r *req) { r.Name = "al" }, []string{"name:min"}},
+		{"min 按字符计数", func(r *req) { r.Name = "张三丰" }, nil},
+		{"max 长度", func(r *req) { r.Name = "alexander" }, []string{"name:max"}},
+		{"email 格式错误", func(r *req) { r.Email = "not-an-email" }, []string{"email:email"}},
+		{"email 不接受带名字的地址", func(r *req) { r.Email = "A <a@example.com>" }, []string{"email:email"}},
+		{"email 为空时跳过", func(r *req) { r.Email = "" }, nil},
+		{"min 数值", func(r *req) { r.Age = 17 }, []string{"age:min"}},
+		{"max 数值", func(r *req) { r.Age = 61 }, []string{"age:max"}},
+		{"max 浮点数，没有 json 标签时使用字段名", func(r *req) { r.Score = 1.6 }, []string{"Score:max"}},
+		{"min 切片长度", func(r *req) { r.Tags = nil }, []string{"tags:min"}},
+		{"指针为 nil 时跳过", func(r *req) { r.Nick = nil }, nil},
+		{"指针指向的值", func(r *req) { r.Nick = &long }, []string{"nick:max"}},
+		{"多个字段同时失败", func(r *req) { r.Name, r.Age = "", 99 }, []string{"name:required", "name:min", "age:max"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.mod(&r)
+			if got := failedRules(t, Struct(&r)); !slices.Equal(got, tt.want) {
+				t.Errorf("Struct() 失败的规则 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructNested(t *testing.T) {
+	type address struct {
+		City string `json:"city" validate:"required"`
+		Zip  string `json:"zip" validate:"min=5"`
+	}
+	type user struct {
+		Name    string   `json:"name" validate:"required"`
+		Address address  `json:"address"`
+		Billing *address `json:"billing"`
+	}
+
+	tests := []struct {
+		name string
+		in   user
+		want []string
+	}{
+		{"嵌套字段带路径前缀", user{Name: "a", Address: address{Zip: "1"}}, []string{"address.city:required", "address.zip:min"}},
+		{"嵌套指针递归验证", user{Name: "a", Address: address{City: "x", Zip: "12345"}, Billing: &address{Zip: "12345"}}, []string{"billing.city:required"}},
+		{"nil 指针不验证", user{Name: "a", Address: address{City: "x", Zip: "12345"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failedRules(t, Struct(tt.in)); !slices.Equal(got, tt.want) {
+				t.Errorf("Struct() 失败的规则 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	type bad struct {
+		A string `validate:"uuid"`
+		B int    `validate:"min=abc"`
+	}
+	if got := failedRules(t, Struct(bad{})); !slices.Equal(got, []string{"A:uuid", "B:min"}) {
+		t.Errorf("未知规则和非法参数 = %v, want [A:uuid B:min]", got)
+	}
+
+	var nilPtr *struct{}
+	if err := Struct(nilPtr); err == nil {
+		t.Error("Struct(nil 指针) 应该返回错误")
+	}
+	if err := Struct(42); err == nil {
+		t.Error("Struct(非结构体) 应该返回错误")
+	}
+
+	type one struct {
+		Name string `json:"name" validate:"required,min=3"`
+	}
+	err := Struct(one{})
+	if want := "name is required; name length must be at least 3"; err == nil || err.Error() != want {
+		t.Errorf("Error() = %v, want %q", err, want)
+	}
+}
+
+func TestFieldNameTags(t *testing.T) {
+	type req struct {
+		A string `json:"a_json" form:"a_form" validate:"required"`
+		B string `form:"b_form" validate:"required"`
+		C string `query:"c_query" validate:"required"`
+		D string `json:"-" query:"d_query" validate:"required"`
+		E string `validate:"required"`
+	}
+	want := []string{"a_json:required", "b_form:required", "c_query:required", "d_query:required", "E:required"}
+	if got := failedRules(t, Struct(req{})); !slices.Equal(got, want) {
+		t.Errorf("字段名 = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+// verr/verr_errors.go
+// 跨框架的校验错误类型 - 详细注释版
+
+package verr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/austoin/GolangTutorial/validate"
+)
+
+// ====== 校验错误基础 ======
+/*
+Gin 的 ShouldBind 返回 validator.ValidationErrors，错误信息是面向开发者的：
+
+  Key: 'User.Username' Error:Field validation for 'Username' failed on the 'min' tag
+
+Echo 示例使用 validate 包，返回的又是另一种结构。
+同一个客户端对接两套接口时，需要处理两种错误格式。
+
+FieldErrors 统一两者，Gin 和 Echo 都渲染成同样的响应：
+
+  ← 400
+  {
+    "error": "Validation failed",
+    "fields": [
+      {"field": "username", "tag": "min", "message": "username must be at least 3 characters"},
+      {"field": "email", "tag": "email", "message": "email must be a valid email address"}
+    ]
+  }
+
+字段名使用 json 标签。validator 默认报告 Go 字段名，
+需要先调用 RegisterJSONTagNames 让它改用 json 标签：
+
+  if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+      verr.RegisterJSONTagNames(v)
+  }
+*/
+
+// ====== 错误类型 ======
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`   // json 字段名，嵌套字段形如 "address.city"
+	Tag     string `json:"tag"`     // 失败的规则，如 "required"
+	Message string `json:"message"` // 可读的错误信息
+}
+
+// FieldErrors 一次校验中所有字段的错误
+type FieldErrors []FieldError
+
+// Error 实现 error 接口，把所有错误用分号拼接
+func (fe FieldErrors) Error() string {
+	msgs := make([]string, len(fe))
+	for i, e := range fe {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Response 返回统一的 400 响应体，Gin 和 Echo 直接序列化即可
+func (fe FieldErrors) Response() map[string]interface{} {
+	return map[string]interface{}{
+		"error":  "Validation failed",
+		"fields": fe,
+	}
+}
+
+// ====== 转换 ======
+
+// RegisterJSONTagNames 让 validator 在错误中报告 json 标签名而不是 Go 字段名
+// json:"-" 的字段保留 Go 字段名
+func RegisterJSONTagNames(v *validator.Validate) {
+	v.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return sf.Name
+		}
+		return name
+	})
+}
+
+// FromValidator 把校验错误转换为 FieldErrors
+// 支持 validator.ValidationErrors 和 validate.ValidationErrors（可以被包装），
+// 其他错误（例如 JSON 语法错误）返回 nil
+func FromValidator(err error) FieldErrors {
+	var ves validator.ValidationErrors
+	if errors.As(err, &ves) {
+		out := make(FieldErrors, len(ves))
+		for i, fe := range ves {
+			field := fieldPath(fe)
+			out[i] = FieldError{Field: field, Tag: fe.Tag(), Message: message(field, fe.Tag(), fe.Param(), fe.Kind())}
+		}
+		return out
+	}
+
+	var vs validate.ValidationErrors
+	if errors.As(err, &vs) {
+		out := make(FieldErrors, len(vs))
+		for i, fe := range vs {
+			out[i] = FieldError{Field: fe.Field, Tag: fe.Rule, Message: message(fe.Field, fe.Rule, fe.Param, fe.Kind)}
+		}
+		return out
+	}
+	return nil
+}
+
+// fieldPath 去掉 Namespace 中的顶层结构体名，"User.address.city" → "address.city"
+func fieldPath(fe validator.FieldError) string {
+	if _, path, ok := strings.Cut(fe.Namespace(), "."); ok {
+		return path
+	}
+	return fe.Field()
+}
+
+// message 把常见规则翻译成可读的错误信息
+// 两种校验器的错误都经过这里，不使用 validate 包自带的 Message，保证 Gin 和 Echo 的文案一致
+// 字符串的 min/max 指字符数，切片、映射指元素个数，数值指大小
+func message(field, tag, param string, kind reflect.Kind) string {
+	switch tag {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "min", "gte":
+		return fmt.Sprintf("%s must be at least %s%s", field, param, lengthUnit(kind))
+	case "max", "lte":
+		return fmt.Sprintf("%s must be at most %s%s", field, param, lengthUnit(kind))
+	default:
+		return fmt.Sprintf("%s failed on the '%s' rule", field, tag)
+	}
+}
+
+// lengthUnit min/max 按长度比较时的单位，数值比较时为空
+func lengthUnit(k reflect.Kind) string {
+	switch k {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return " items"
+	default:
+		return ""
+	}
+}
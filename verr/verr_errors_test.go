@@ -0,0 +1,195 @@
+// verr/verr_errors_test.go
+// 校验错误转换的测试
+
+package verr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"github.com/austoin/GolangTutorial/validate"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type testUser struct {
+	Username string      `json:"username" validate:"required,min=3"`
+	Email    string      `json:"email,omitempty" validate:"required,email"`
+	Tags     []string    `json:"tags" validate:"max=2"`
+	Age      int         `json:"age" validate:"gte=18"`
+	Secret   string      `json:"-" validate:"required"`
+	Nickname string      `validate:"alpha"`
+	Address  testAddress `json:"address"`
+}
+
+// invalidUser 每个字段都不满足规则
+func invalidUser() testUser {
+	return testUser{
+		Username: "al",
+		Email:    "not-an-email",
+		Tags:     []string{"a", "b", "c"},
+		Age:      10,
+		Nickname: "n1",
+	}
+}
+
+func TestFromValidatorJSONTagNames(t *testing.T) {
+	v := validator.New()
+	RegisterJSONTagNames(v)
+
+	fields := FromValidator(v.Struct(invalidUser()))
+	want := FieldErrors{
+		{"username", "min", "username must be at least 3 characters"},
+		{"email", "email", "email must be a valid email address"},
+		{"tags", "max", "tags must be at most 2 items"},
+		{"age", "gte", "age must be at least 18"},
+		{"Secret", "required", "Secret is required"},                 // json:"-" 保留 Go 字段名
+		{"Nickname", "alpha", "Nickname failed on the 'alpha' rule"}, // 没有 json 标签
+		{"address.city", "required", "address.city is required"},
+	}
+	if !slices.Equal(fields, want) {
+		t.Errorf("FromValidator() =\n%v\nwant\n%v", fields, want)
+	}
+}
+
+func TestFromValidatorWithoutRegister(t *testing.T) {
+	// 未注册时报告 Go 字段名，这就是需要 RegisterJSONTagNames 的原因
+	fields := FromValidator(validator.New().Struct(testUser{Username: "alice", Email: "a@example.com", Age: 20, Secret: "s", Nickname: "n"}))
+	if len(fields) != 1 || fields[0].Field != "Address.City" {
+		t.Errorf("FromValidator() = %v, want 只有 Address.City", fields)
+	}
+}
+
+func TestFromValidatorValidatePackage(t *testing.T) {
+	type req struct {
+		Name     string   `json:"name" validate:"required"`
+		Email    string   `json:"email" validate:"email"`
+		Nickname *string  `json:"nickname" validate:"min=3"`
+		Tags     []string `json:"tags" validate:"max=1"`
+		Age      int      `json:"age" validate:"min=18"`
+	}
+	nick := "al"
+	err := validate.Struct(req{Email: "bad", Nickname: &nick, Tags: []string{"a", "b"}, Age: 10})
+
+	// 信息来自 verr 的规则表，而不是 validate 包自带的 "nickname length must be at least 3"
+	fields := FromValidator(fmt.Errorf("bind: %w", err)) // 被包装的错误同样识别
+	want := FieldErrors{
+		{"name", "required", "name is required"},
+		{"email", "email", "email must be a valid email address"},
+		{"nickname", "min", "nickname must be at least 3 characters"},
+		{"tags", "max", "tags must be at most 1 items"},
+		{"age", "min", "age must be at least 18"},
+	}
+	if !slices.Equal(fields, want) {
+		t.Errorf("FromValidator() =\n%v\nwant\n%v", fields, want)
+	}
+}
+
+func TestFromValidatorOtherErrors(t *testing.T) {
+	for _, err := range []error{nil, errors.New("unexpected EOF"), &json.SyntaxError{}} {
+		if got := FromValidator(err); got != nil {
+			t.Errorf("FromValidator(%v) = %v, want nil", err, got)
+		}
+	}
+}
+
+func TestFieldErrorsErrorAndResponse(t *testing.T) {
+	fe := FieldErrors{
+		{Field: "username", Tag: "required", Message: "username is required"},
+		{Field: "email", Tag: "email", Message: "email must be a valid email address"},
+	}
+	if got, want := fe.Error(), "username is required; email must be a valid email address"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	data, err := json.Marshal(fe.Response())
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	want := `{"error":"Validation failed","fields":[` +
+		`{"field":"username","tag":"required","message":"username is required"},` +
+		`{"field":"email","tag":"email","message":"email must be a valid email address"}]}`
+	if string(data) != want {
+		t.Errorf("Response() = %s, want %s", data, want)
+	}
+}
+
+// ====== Gin 与 Echo ======
+
+// signupRequest 同时带 Gin（binding）和 Echo 示例（validate）使用的标签
+type signupRequest struct {
+	Username string   `json:"username" binding:"required,min=3" validate:"required,min=3"`
+	Email    string   `json:"email" binding:"required,email" validate:"required,email"`
+	Tags     []string `json:"tags" binding:"max=2" validate:"max=2"`
+	Age      int      `json:"age" binding:"min=18" validate:"min=18"`
+}
+
+func TestGinAndEchoRenderSameBody(t *testing.T) {
+	const payload = `{"username":"al","email":"not-an-email","tags":["a","b","c"],"age":10}`
+
+	// Gin：ShouldBindJSON 使用 go-playground/validator（同 web_gin.go 的 respondBindError）
+	gin.SetMode(gin.TestMode)
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		RegisterJSONTagNames(v)
+	}
+	router := gin.New()
+	router.POST("/signup", func(c *gin.Context) {
+		var req signupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, FromValidator(err).Response())
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// Echo：绑定后用 validate 包校验（同 web_echo.go 的 validationErrorResponse）
+	e := echo.New()
+	e.POST("/signup", func(c echo.Context) error {
+		var req signupRequest
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		if err := validate.Struct(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, FromValidator(err).Response())
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	send := func(h http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	ginRec, echoRec := send(router), send(e)
+
+	if ginRec.Code != http.StatusBadRequest || echoRec.Code != http.StatusBadRequest {
+		t.Fatalf("status = Gin %d / Echo %d, want 都为 400", ginRec.Code, echoRec.Code)
+	}
+	ginBody, echoBody := strings.TrimSpace(ginRec.Body.String()), strings.TrimSpace(echoRec.Body.String())
+	if ginBody != echoBody {
+		t.Errorf("响应体不同：\nGin  %s\nEcho %s", ginBody, echoBody)
+	}
+	want := `{"error":"Validation failed","fields":[` +
+		`{"field":"username","tag":"min","message":"username must be at least 3 characters"},` +
+		`{"field":"email","tag":"email","message":"email must be a valid email address"},` +
+		`{"field":"tags","tag":"max","message":"tags must be at most 2 items"},` +
+		`{"field":"age","tag":"min","message":"age must be at least 18"}]}`
+	if ginBody != want {
+		t.Errorf("响应体 = %s, want %s", ginBody, want)
+	}
+}
@@ -4,12 +4,49 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime/debug"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/austoin/GolangTutorial/auth"
+	"github.com/austoin/GolangTutorial/decompress"
+	"github.com/austoin/GolangTutorial/env"
+	"github.com/austoin/GolangTutorial/flags"
+	"github.com/austoin/GolangTutorial/humanize"
+	"github.com/austoin/GolangTutorial/logger"
+	"github.com/austoin/GolangTutorial/paging"
+	"github.com/austoin/GolangTutorial/ratelimit"
+	"github.com/austoin/GolangTutorial/reqctx"
+	"github.com/austoin/GolangTutorial/validate"
+	"github.com/austoin/GolangTutorial/verr"
 )
 
 // ====== Echo 框架基础 ======
@@ -31,10 +68,11 @@ Echo 是另一个流行的 Go Web 框架，以高性能和简洁的 API 著称
 
 // User 用户模型
 type User struct {
-	ID       uint   `json:"id" validate:"required"`
-	Username string `json:"username" validate:"required,min=3,max=50"`
-	Email    string `json:"email" validate:"required,email"`
-	Age      int    `json:"age" validate:"gte=0,lte=150"`
+	ID        uint      `json:"id" validate:"required"`
+	Username  string    `json:"username" validate:"required,min=3,max=50"`
+	Email     string    `json:"email" validate:"required,email"`
+	Age       int       `json:"age" validate:"gte=0,lte=150"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Post 帖子模型
@@ -48,8 +86,9 @@ type Post struct {
 
 // ErrorResponse 错误响应
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"` // 与响应头 X-Request-ID 相同，便于用户反馈问题时定位日志
 }
 
 // ====== 创建应用 ======
@@ -64,8 +103,21 @@ func createApp() *echo.Echo {
 	// e.HidePort = true   // 隐藏端口显示
 
 	// 3. 添加全局中间件
+	// 请求 ID 必须最先执行，之后的访问日志、错误日志和 panic 日志才能带上同一个 request_id
+	e.Use(RequestIDMiddleware())
 	e.Use(LoggerMiddleware())
 	e.Use(RecoveryMiddleware())
+	// 维护模式：启动时由 MAINTENANCE_MODE 决定，运行中通过 PUT /admin/maintenance 切换
+	maintenanceMode.Store(os.Getenv("MAINTENANCE_MODE") == "true")
+	e.Use(MaintenanceMiddleware(&maintenanceMode, MaintenanceConfig{
+		AllowReads: true,
+		AllowPaths: []string{"/health", maintenanceAdminPath},
+	}))
+	// 解压 gzip 请求体，放在请求体日志之前，日志中记录的是解压后的内容
+	e.Use(decompress.EchoMiddleware(decompress.DefaultMaxSize))
+	// 请求体日志默认关闭，设置 LOG_HTTP_BODIES=true 开启（只建议在开发环境使用）
+	e.Use(BodyLogMiddleware(BodyLogConfig{Enabled: os.Getenv("LOG_HTTP_BODIES") == "true"}))
+	e.Use(RateLimitMiddleware(ratelimit.NewTokenBucket(10, 20)))
 
 	// 4. 配置错误处理
 	e.HTTPErrorHandler = customErrorHandler
@@ -84,13 +136,22 @@ func setupRoutes(e *echo.Echo) {
 		})
 	})
 
+	// 存活检查：进程在运行就返回 200
+	e.GET("/health/live", liveHandler)
+
+	// 就绪检查：执行所有注册的依赖检查
+	e.GET("/health/ready", readyHandler)
+
 	// 2. 根路由
 	e.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Welcome to Echo Web Framework!")
 	})
 
 	// 3. API 路由组
-	api := e.Group("/api/v1")
+	// ETag 对组内所有 GET 生效，客户端可以用 If-None-Match 做条件请求
+	// BodyLimit 限制请求体大小，超出时返回 413
+	// 上限可以用 API_BODY_LIMIT 覆盖，如 "512KiB"、"2MB"
+	api := e.Group("/api/v1", ETagMiddleware(), BodyLimit(sizeFromEnv("API_BODY_LIMIT", defaultBodyLimit)))
 
 	// 用户路由
 	api.POST("/users", createUserHandler)
@@ -105,21 +166,130 @@ func setupRoutes(e *echo.Echo) {
 	api.GET("/posts/:id", getPostHandler)
 
 	// 4. V2 API 路由组
-	apiV2 := e.Group("/api/v2")
+	// 由 api-v2 开关控制，关闭后 v2 接口返回 404
+	featureFlags.Set("api-v2", flags.Flag{Enabled: true, Percentage: 100})
+	apiV2 := e.Group("/api/v2", RequireFlag(featureFlags, "api-v2"))
 	apiV2.GET("/users", listUsersV2Handler)
 	apiV2.GET("/users/:id", getUserV2Handler)
 }
 
+// ====== 健康检查 ======
+
+// HealthCheck 依赖检查函数
+// 返回 nil 表示依赖可用，返回错误表示不可用
+type HealthCheck func(ctx context.Context) error
+
+// healthCheckTimeout 一次就绪检查的总超时时间
+// 所有检查并发执行、共用这个截止时间，而不是每个检查各自计时
+const healthCheckTimeout = 2 * time.Second
+
+var (
+	healthChecks   = make(map[string]HealthCheck) // 名称 -> 检查函数
+	healthChecksMu sync.RWMutex                   // 保护 healthChecks
+)
+
+// RegisterHealthCheck 注册依赖检查
+// 例如：
+//
+//	RegisterHealthCheck("redis", func(ctx context.Context) error {
+//	    return rdb.Ping(ctx).Err()
+//	})
+//	RegisterHealthCheck("db", func(ctx context.Context) error {
+//	    return sqlDB.PingContext(ctx)
+//	})
+func RegisterHealthCheck(name string, fn func(ctx context.Context) error) {
+	healthChecksMu.Lock()
+	defer healthChecksMu.Unlock()
+	healthChecks[name] = fn
+}
+
+// runHealthChecks 并发执行所有检查
+// 返回每个依赖的状态以及是否全部通过
+// 检查函数不理会 ctx 时也不会卡住就绪接口：超时后仍未返回的检查记为失败
+func runHealthChecks(ctx context.Context) (map[string]string, bool) {
+	healthChecksMu.RLock()
+	checks := make(map[string]HealthCheck, len(healthChecks))
+	for name, fn := range healthChecks {
+		checks[name] = fn
+	}
+	healthChecksMu.RUnlock()
+
+	// 所有检查并发执行，共用一个超时
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	type result struct {
+		name string
+		err  error
+	}
+	// 带缓冲，超时返回后迟到的检查也不会阻塞在发送上
+	done := make(chan result, len(checks))
+	for name, fn := range checks {
+		go func() {
+			done <- result{name, fn(ctx)}
+		}()
+	}
+
+	results := make(map[string]string, len(checks))
+	healthy := true
+	for range checks {
+		select {
+		case r := <-done:
+			if r.err != nil {
+				results[r.name] = r.err.Error()
+				healthy = false
+				continue
+			}
+			results[r.name] = "ok"
+		case <-ctx.Done():
+			for name := range checks {
+				if _, ok := results[name]; !ok {
+					results[name] = "check did not complete: " + ctx.Err().Error()
+				}
+			}
+			return results, false
+		}
+	}
+	return results, healthy
+}
+
+// liveHandler 存活检查
+// GET /health/live
+func liveHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": "ok",
+	})
+}
+
+// readyHandler 就绪检查
+// GET /health/ready
+func readyHandler(c echo.Context) error {
+	results, healthy := runHealthChecks(c.Request().Context())
+
+	// 任意依赖失败返回 503
+	code := http.StatusOK
+	status := "ok"
+	if !healthy {
+		code = http.StatusServiceUnavailable
+		status = "unavailable"
+	}
+
+	return c.JSON(code, map[string]interface{}{
+		"status": status,
+		"checks": results,
+	})
+}
+
 // ====== 路由处理器 ======
 
 // createUserHandler 创建用户
 // POST /api/v1/users
 func createUserHandler(c echo.Context) error {
 	// 1. 绑定请求体到结构体
-	// Bind 方法自动解析 JSON、Form 等格式
+	// bindJSON 区分空请求体、JSON 语法错误和字段类型错误
 	var user User
-	if err := c.Bind(&user); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	if err := bindJSON(c, &user); err != nil {
+		return err
 	}
 
 	// 2. 验证数据（使用自定义验证）
@@ -139,34 +309,34 @@ func createUserHandler(c echo.Context) error {
 }
 
 // listUsersHandler 获取用户列表
-// GET /api/v1/users
+// GET /api/v1/users?page=1&page_size=10&sort=-age
 func listUsersHandler(c echo.Context) error {
-	// 1. 获取查询参数
-	page := c.QueryParam("page")
-	pageSize := c.QueryParam("page_size")
-
-	// 2. 解析参数（带默认值）
-	pageNum := 1
-	if p, err := strconv.Atoi(page); err == nil && p > 0 {
-		pageNum = p
-	}
+	// 1. 解析分页和排序参数
+	pageNum, limit := parsePagination(c)
 
-	limit := 10
-	if ps, err := strconv.Atoi(pageSize); err == nil && ps > 0 && ps <= 100 {
-		limit = ps
+	spec, err := parseSort(c.QueryParam("sort"), userSorters, "id")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	// 3. 返回模拟数据
+	// 2. 模拟数据
 	users := []User{
 		{ID: 1, Username: "alice", Email: "alice@example.com", Age: 25},
 		{ID: 2, Username: "bob", Email: "bob@example.com", Age: 30},
 	}
 
+	// 3. 先排序再分页
+	sortItems(users, spec, userSorters)
+
+	data, total, totalPages := paging.Page(users, pageNum, limit)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"data":      users,
-		"page":      pageNum,
-		"page_size": limit,
-		"total":     2,
+		"data":        data,
+		"page":        pageNum,
+		"page_size":   limit,
+		"total":       total,
+		"total_pages": totalPages,
+		"sort":        spec.String(),
 	})
 }
 
@@ -199,12 +369,17 @@ func getUserHandler(c echo.Context) error {
 // updateUserHandler 更新用户
 // PUT /api/v1/users/:id
 func updateUserHandler(c echo.Context) error {
-	id := c.Param("id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
 
 	var user User
 	if err := c.Bind(&user); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
 	}
+	// 以路径中的 ID 为准，忽略请求体中的 id
+	user.ID = uint(id)
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"message": "User updated successfully",
@@ -229,8 +404,8 @@ func deleteUserHandler(c echo.Context) error {
 // POST /api/v1/posts
 func createPostHandler(c echo.Context) error {
 	var post Post
-	if err := c.Bind(&post); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	if err := bindJSON(c, &post); err != nil {
+		return err
 	}
 
 	post.ID = 1
@@ -243,26 +418,45 @@ func createPostHandler(c echo.Context) error {
 }
 
 // listPostsHandler 获取帖子列表
-// GET /api/v1/posts
+// GET /api/v1/posts?page=1&page_size=10&sort=-created_at
 func listPostsHandler(c echo.Context) error {
+	pageNum, limit := parsePagination(c)
+
+	spec, err := parseSort(c.QueryParam("sort"), postSorters, "-created_at")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	now := time.Now()
 	posts := []Post{
-		{ID: 1, Title: "First Post", Content: "Hello World!", AuthorID: 1},
-		{ID: 2, Title: "Second Post", Content: "Echo is great", AuthorID: 2},
+		{ID: 1, Title: "First Post", Content: "Hello World!", AuthorID: 1, CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: 2, Title: "Second Post", Content: "Echo is great", AuthorID: 2, CreatedAt: now.Add(-24 * time.Hour)},
 	}
 
+	sortItems(posts, spec, postSorters)
+
+	data, total, totalPages := paging.Page(posts, pageNum, limit)
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"data":  posts,
-		"total": 2,
+		"data":        data,
+		"page":        pageNum,
+		"page_size":   limit,
+		"total":       total,
+		"total_pages": totalPages,
+		"sort":        spec.String(),
 	})
 }
 
 // getPostHandler 获取单个帖子
 // GET /api/v1/posts/:id
 func getPostHandler(c echo.Context) error {
-	id := c.Param("id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid post ID")
+	}
 
 	return c.JSON(http.StatusOK, Post{
-		ID:        1,
+		ID:        uint(id),
 		Title:     "First Post",
 		Content:   "Hello World!",
 		AuthorID:  1,
@@ -270,6 +464,73 @@ func getPostHandler(c echo.Context) error {
 	})
 }
 
+// ====== 分页与排序 ======
+
+// parsePagination 解析分页参数（带默认值）
+// page 默认 1，page_size 默认 10，超过 100 时按 100 处理
+func parsePagination(c echo.Context) (page, pageSize int) {
+	page, _ = strconv.Atoi(c.QueryParam("page"))
+	pageSize, _ = strconv.Atoi(c.QueryParam("page_size"))
+	return paging.Clamp(page, pageSize)
+}
+
+// sortSpec 排序规则
+type sortSpec struct {
+	Field string // 排序字段
+	Desc  bool   // 是否倒序
+}
+
+// String 还原为查询参数格式，如 "-created_at"
+func (s sortSpec) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// userSorters 用户允许排序的字段（白名单）
+var userSorters = map[string]func(a, b User) int{
+	"id":       func(a, b User) int { return cmp.Compare(a.ID, b.ID) },
+	"username": func(a, b User) int { return cmp.Compare(a.Username, b.Username) },
+	"age":      func(a, b User) int { return cmp.Compare(a.Age, b.Age) },
+}
+
+// postSorters 帖子允许排序的字段（白名单）
+var postSorters = map[string]func(a, b Post) int{
+	"id":         func(a, b Post) int { return cmp.Compare(a.ID, b.ID) },
+	"title":      func(a, b Post) int { return cmp.Compare(a.Title, b.Title) },
+	"created_at": func(a, b Post) int { return a.CreatedAt.Compare(b.CreatedAt) },
+}
+
+// parseSort 解析排序参数
+// 格式：field 表示升序，-field 表示倒序；字段必须在白名单中
+func parseSort[T any](raw string, sorters map[string]func(a, b T) int, def string) (sortSpec, error) {
+	if raw == "" {
+		raw = def
+	}
+
+	spec := sortSpec{Field: raw}
+	if strings.HasPrefix(raw, "-") {
+		spec = sortSpec{Field: raw[1:], Desc: true}
+	}
+
+	if _, ok := sorters[spec.Field]; !ok {
+		return sortSpec{}, fmt.Errorf("Unknown sort field: %s", spec.Field)
+	}
+	return spec, nil
+}
+
+// sortItems 按排序规则原地排序
+func sortItems[T any](items []T, spec sortSpec, sorters map[string]func(a, b T) int) {
+	compare := sorters[spec.Field]
+	slices.SortStableFunc(items, func(a, b T) int {
+		if spec.Desc {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+}
+
 // ====== V2 路由处理器 ======
 
 // listUsersV2Handler 获取用户列表 V2
@@ -299,6 +560,54 @@ func getUserV2Handler(c echo.Context) error {
 
 // ====== 中间件 ======
 
+// maxRequestIDLen 接受的客户端请求 ID 的最大长度
+const maxRequestIDLen = 128
+
+// RequestIDMiddleware 请求 ID 中间件
+// 沿用客户端（或网关）传入的 X-Request-ID，没有或格式不合法时生成新的；
+// 写入 request context（logger.WithContext 自动带上 request_id）、c.Set 和响应头
+func RequestIDMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(id) {
+				id = logger.NewRequestID()
+			}
+
+			c.SetRequest(req.WithContext(reqctx.WithRequestID(req.Context(), id)))
+			c.Set(reqctx.KeyRequestID, id)
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			return next(c)
+		}
+	}
+}
+
+// validRequestID 只接受字母、数字和 "-_.:"，避免把任意内容写进日志和响应头
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDOf 当前请求的 ID
+// 优先取 RequestIDMiddleware 写入的值；未安装该中间件时退回请求头
+func requestIDOf(c echo.Context) string {
+	if id := reqctx.RequestID(c.Request().Context()); id != "" {
+		return id
+	}
+	return c.Request().Header.Get(echo.HeaderXRequestID)
+}
+
 // LoggerMiddleware 日志中间件
 func LoggerMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -307,48 +616,166 @@ func LoggerMiddleware() echo.MiddlewareFunc {
 			start := time.Now()
 
 			// 处理请求
-			err := next(c)
+			// 处理器返回的错误要到整条中间件链返回后才由 HTTPErrorHandler 写出，
+			// 这里提前交给 c.Error 处理，访问日志记录的才是最终状态码
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
 
 			// 请求处理完成后
 			duration := time.Since(start)
 
-			// 打印日志
-			println(c.Request().Method, c.Request().URL.Path,
-				c.Response().Status, duration.String())
+			// 打印结构化日志
+			logger.WithContext(c.Request().Context()).Info("request",
+				"method", c.Request().Method,
+				"path", c.Request().URL.Path,
+				"status", c.Response().Status,
+				"duration", duration.String(),
+			)
 
-			return err
+			return nil
 		}
 	}
 }
 
 // RecoveryMiddleware 恢复中间件
+// 捕获 panic，记录 panic 值和堆栈，返回 500（不向客户端暴露堆栈）
 func RecoveryMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
+		return func(c echo.Context) (err error) {
 			defer func() {
-				if r := recover(); r != nil {
-					err := echo.NewHTTPError(http.StatusInternalServerError, "Internal server error")
-					c.Error(err)
+				r := recover()
+				if r == nil {
+					return
+				}
+
+				// http.ErrAbortHandler 用于主动中断响应，必须继续向上抛出
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+
+				// 记录错误日志，包含请求 ID 和完整堆栈
+				req := c.Request()
+				requestID := requestIDOf(c)
+				logger.L().Error("panic recovered",
+					"request_id", requestID,
+					"panic", fmt.Sprint(r),
+					"method", req.Method,
+					"path", req.URL.Path,
+					"stack", string(debug.Stack()),
+				)
+
+				// 响应已经开始写出时无法再修改状态码
+				if c.Response().Committed {
+					return
 				}
+				err = c.JSON(http.StatusInternalServerError, ErrorResponse{
+					Error:     "Internal server error",
+					Message:   "An unexpected error occurred",
+					RequestID: requestID,
+				})
 			}()
 			return next(c)
 		}
 	}
 }
 
+// jwtSecret JWT 签名密钥
+// 实际项目中应从环境变量或配置中心读取
+var jwtSecret = []byte("change-me-in-production")
+
 // AuthMiddleware 认证中间件
 func AuthMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			token := c.Request().Header.Get("Authorization")
+			// 格式：Authorization: Bearer <token>
+			token := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
 
 			if token == "" {
 				return echo.NewHTTPError(http.StatusUnauthorized, "Authorization token required")
 			}
 
 			// 验证 token
-			c.Set("user_id", 1)
+			claims, err := auth.ParseToken(token, jwtSecret)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+
+			// 键名与 reqctx 一致，业务代码可以通过 reqctx.FromEcho(c) 读取
+			c.Set(reqctx.KeyUserID, claims.UserID)
+			c.Set(reqctx.KeyRoles, claims.Roles)
+			c.Set("claims", claims)
+
+			return next(c)
+		}
+	}
+}
+
+// RoleMatch 角色匹配模式
+type RoleMatch int
+
+const (
+	MatchAnyRole  RoleMatch = iota // 拥有任意一个角色即可
+	MatchAllRoles                  // 必须拥有全部角色
+)
+
+// RequireRoles 角色校验中间件，拥有任意一个角色即可访问
+// 必须放在 AuthMiddleware 之后使用
+func RequireRoles(roles ...string) echo.MiddlewareFunc {
+	return RequireRolesWithMode(MatchAnyRole, roles...)
+}
+
+// RequireRolesWithMode 按指定模式校验角色
+// 没有认证信息返回 401，角色不满足返回 403
+// roles 为空时 panic：any 模式下会拒绝所有人，all 模式下会放行所有人，都不是调用方想要的
+func RequireRolesWithMode(mode RoleMatch, roles ...string) echo.MiddlewareFunc {
+	if len(roles) == 0 {
+		panic("RequireRolesWithMode: roles must not be empty")
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := c.Get("claims").(*auth.Claims)
+			if !ok || claims == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+			}
+
+			if !hasRoles(claims, mode, roles) {
+				return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// hasRoles 检查声明中的角色是否满足要求
+func hasRoles(claims *auth.Claims, mode RoleMatch, roles []string) bool {
+	for _, role := range roles {
+		has := claims.HasRole(role)
+		if mode == MatchAnyRole && has {
+			return true
+		}
+		if mode == MatchAllRoles && !has {
+			return false
+		}
+	}
+	// any 模式下没有匹配到任何角色；all 模式下全部匹配
+	return mode == MatchAllRoles
+}
 
+// featureFlags 功能开关，多实例部署时换成 flags.NewRedisStore
+var featureFlags = flags.NewMemoryStore()
+
+// RequireFlag 功能开关中间件，开关对当前用户关闭时返回 404
+// 放在 AuthMiddleware 之后时按登录用户灰度，否则只有全量开放的开关能通过
+func RequireFlag(store flags.Store, name string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := reqctx.FromEcho(c)
+			userID, _ := reqctx.UserID(ctx)
+			if !store.IsEnabled(ctx, name, userID) {
+				return echo.ErrNotFound
+			}
 			return next(c)
 		}
 	}
@@ -374,163 +801,1416 @@ func CORSMiddleware() echo.MiddlewareFunc {
 }
 
 // RateLimitMiddleware 限流中间件
-func RateLimitMiddleware() echo.MiddlewareFunc {
-	// 实际实现可以使用 golang.org/x/time/rate
+// 按客户端 IP 限流，算法和存储由 limiter 决定（见 ratelimit 包）
+// 被拒绝时返回 429 并设置 Retry-After；限流器出错时放行
+func RateLimitMiddleware(limiter ratelimit.Limiter) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// 简化的限流逻辑
-			// 实际应该使用令牌桶或漏桶算法
+			ctx := c.Request().Context()
+			allowed, retryAfter, err := limiter.Allow(ctx, c.RealIP())
+			if err != nil {
+				logger.WithContext(ctx).Warn("限流器错误，放行请求", "err", err)
+				return next(c)
+			}
+
+			if !allowed {
+				seconds := max(1, int((retryAfter+time.Second-1)/time.Second))
+				c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+				return c.JSON(http.StatusTooManyRequests, ErrorResponse{
+					Error:     "Too many requests",
+					Message:   "Rate limit exceeded, retry later",
+					RequestID: requestIDOf(c),
+				})
+			}
 
 			return next(c)
 		}
 	}
 }
 
-// ====== 自定义错误处理 ======
+// ====== 维护模式 ======
+/*
+数据库迁移、主从切换时需要暂停写入，但不想停掉整个服务：
 
-// customErrorHandler 自定义错误处理器
-func customErrorHandler(err error, c echo.Context) {
-	// 1. 检查是否是 HTTP 错误
-	httpErr, ok := err.(*echo.HTTPError)
-	if ok {
-		c.JSON(httpErr.Code, ErrorResponse{
-			Error:   httpErr.Message.(string),
-			Message: "An error occurred",
-		})
-		return
-	}
+  开启后   POST/PUT/PATCH/DELETE 返回 503 + Retry-After
+           AllowReads 为 true 时 GET/HEAD/OPTIONS 照常处理，否则同样返回 503
+           AllowPaths 中的路径始终放行（健康检查、切换开关的管理接口）
 
-	// 2. 其他错误
-	c.JSON(http.StatusInternalServerError, ErrorResponse{
-		Error:   "Internal server error",
-		Message: err.Error(),
-	})
-}
+开关是一个 *atomic.Bool，不需要重新部署：
 
-// ====== 数据验证 ======
+  curl -X PUT -H "Authorization: Bearer $ADMIN_TOKEN" \
+       -d '{"enabled":true}' -H "Content-Type: application/json" \
+       localhost:8080/admin/maintenance
 
-// validateUser 验证用户数据
-func validateUser(user *User) error {
-	if user.Username == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "Username is required")
-	}
-	if len(user.Username) < 3 {
-		return echo.NewHTTPError(http.StatusBadRequest, "Username must be at least 3 characters")
-	}
-	if user.Email == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "Email is required")
+管理接口本身也是写请求，必须放进 AllowPaths，否则开启后就无法关闭。
+多实例部署时每个实例的开关是独立的，需要逐个切换，或改为从配置中心读取。
+*/
+
+// maintenanceAdminPath 切换维护模式的管理接口
+const maintenanceAdminPath = "/admin/maintenance"
+
+// maintenanceMode 当前实例的维护模式开关
+var maintenanceMode atomic.Bool
+
+// MaintenanceConfig 维护模式配置
+type MaintenanceConfig struct {
+	RetryAfter time.Duration // Retry-After 响应头，默认 60 秒
+	AllowReads bool          // 维护期间是否继续处理 GET/HEAD/OPTIONS
+	AllowPaths []string      // 始终放行的路径，"/health" 同时匹配 "/health/live" 等子路径
+	Message    string        // 503 响应中的说明
+}
+
+// MaintenanceMiddleware flag 为 true 时拒绝写请求
+func MaintenanceMiddleware(flag *atomic.Bool, cfg MaintenanceConfig) echo.MiddlewareFunc {
+	retryAfter := cfg.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = 60 * time.Second
 	}
-	if user.Age < 0 {
-		return echo.NewHTTPError(http.StatusBadRequest, "Age must be non-negative")
+	seconds := strconv.Itoa(max(1, int((retryAfter+time.Second-1)/time.Second)))
+	message := cmp.Or(cfg.Message, "The service is under maintenance, retry later")
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !flag.Load() {
+				return next(c)
+			}
+
+			req := c.Request()
+			if cfg.AllowReads && isReadMethod(req.Method) {
+				return next(c)
+			}
+			if pathAllowed(req.URL.Path, cfg.AllowPaths) {
+				return next(c)
+			}
+
+			c.Response().Header().Set("Retry-After", seconds)
+			return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+				Error:     "Service unavailable",
+				Message:   message,
+				RequestID: requestIDOf(c),
+			})
+		}
 	}
-	return nil
 }
 
-// ====== 静态文件服务 ======
+// isReadMethod 不修改数据的方法
+func isReadMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
 
-func staticFileHandler(e *echo.Echo) {
-	// 提供静态文件服务
-	e.Static("/static", "./static")
-	e.File("/favicon.ico", "./static/favicon.ico")
+// pathAllowed path 等于某个前缀，或位于其下的子路径
+func pathAllowed(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if path == p || strings.HasPrefix(path, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return false
 }
 
-// ====== 文件上传 ======
+// maintenanceRequest 切换维护模式的请求体
+type maintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
 
-func uploadHandler(e *echo.Echo) {
-	e.POST("/upload", func(c echo.Context) error {
-		// 1. 获取文件
-		file, err := c.FormFile("file")
-		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-		}
+// maintenanceHandler 查询（GET）或切换（PUT）维护模式
+// 应挂在 AuthMiddleware 和 RequireRoles("admin") 之后
+func maintenanceHandler(flag *atomic.Bool) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Request().Method == http.MethodPut {
+			var req maintenanceRequest
+			if err := bindJSON(c, &req); err != nil {
+				return err
+			}
+			if req.Enabled == nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Field 'enabled' is required")
+			}
 
-		// 2. 打开文件
-		src, err := file.Open()
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			flag.Store(*req.Enabled)
+			logger.WithContext(c.Request().Context()).Warn("维护模式已切换",
+				"enabled", *req.Enabled, "user_id", c.Get(reqctx.KeyUserID))
 		}
-		defer src.Close()
-
-		// 3. 保存文件（示例）
-		// dst, err := os.Create("./uploads/" + file.Filename)
-		// if err != nil {
-		//     return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-		// }
-		// defer dst.Close()
-		// io.Copy(dst, src)
 
 		return c.JSON(http.StatusOK, map[string]interface{}{
-			"message":  "File uploaded successfully",
-			"filename": file.Filename,
-			"size":     file.Size,
+			"maintenance": flag.Load(),
 		})
-	})
+	}
 }
 
-// ====== 重定向 ======
+// ====== 请求体日志 ======
+/*
+排查问题时经常需要看到完整的请求和响应内容，但直接打印请求体会把密码、令牌写进日志。
 
-func redirectHandler(e *echo.Echo) {
-	e.GET("/old", func(c echo.Context) error {
-		return c.Redirect(http.StatusMovedPermanently, "/new")
-	})
+BodyLogMiddleware 记录请求体和响应体，并脱敏敏感字段：
 
-	e.GET("/new", func(c echo.Context) error {
-		return c.String(http.StatusOK, "This is the new page!")
-	})
+  e.Use(BodyLogMiddleware(BodyLogConfig{Enabled: os.Getenv("APP_ENV") != "production"}))
+
+  POST /api/v1/login  {"username": "alice", "password": "123456"}
+  → level=INFO msg="http body" method=POST path=/api/v1/login status=200
+      request_body="{\"password\":\"[REDACTED]\",\"username\":\"alice\"}" response_body=...
+
+行为：
+  - 只读取前 MaxBytes 字节用于日志，处理器读到的仍然是完整的原始请求体
+  - 响应体边写边记录，不会被缓冲，不影响流式响应
+  - 字段名（不区分大小写）包含 password、token、secret 等关键字的值被替换为 [REDACTED]，
+    JSON 按字段递归处理（包括数组和嵌套对象），表单按参数名处理
+  - 超出 MaxBytes 的 JSON 无法完整解析，按正则替换字符串值
+  - 其他内容类型（图片、文件等）只记录长度
+
+Enabled 为 false 时中间件直接调用下一个处理器，没有额外开销，
+可以按环境开启（例如只在开发和预发布环境记录）。
+*/
+
+const (
+	defaultBodyLogMaxBytes = 4 << 10 // 4KB
+	redactedValue          = "[REDACTED]"
+)
+
+// defaultRedactKeys 默认的敏感字段关键字
+var defaultRedactKeys = []string{"password", "passwd", "token", "secret", "authorization", "api_key", "apikey"}
+
+// BodyLogConfig 请求体日志配置
+type BodyLogConfig struct {
+	Enabled    bool     // 是否记录
+	MaxBytes   int      // 每个请求体/响应体最多记录的字节数，默认 4KB
+	RedactKeys []string // 敏感字段关键字（字段名包含即脱敏），默认 defaultRedactKeys
 }
 
-// ====== 自定义 404 处理器 ======
+// BodyLogMiddleware 记录脱敏后的请求体和响应体
+func BodyLogMiddleware(cfg BodyLogConfig) echo.MiddlewareFunc {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultBodyLogMaxBytes
+	}
+	if len(cfg.RedactKeys) == 0 {
+		cfg.RedactKeys = defaultRedactKeys
+	}
+	r := newRedactor(cfg.RedactKeys)
 
-func customNotFoundHandler(e *echo.Echo) {
-	e.HTTPErrorHandler = func(err error, c echo.Context) {
-		if httpErr, ok := err.(*echo.HTTPError); ok && httpErr.Code == http.StatusNotFound {
-			c.JSON(http.StatusNotFound, map[string]interface{}{
-				"error":  "Page not found",
-				"path":   c.Request().URL.Path,
-				"method": c.Request().Method,
-			})
-			return
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if !cfg.Enabled {
+			return next
 		}
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			// 1. 读取请求体的前 MaxBytes+1 字节（多读 1 字节用于判断是否截断），
+			//    再把已读部分和剩余部分拼回去，处理器看到的是完整请求体
+			var reqBody []byte
+			if req.Body != nil && req.Body != http.NoBody {
+				head, err := io.ReadAll(io.LimitReader(req.Body, int64(cfg.MaxBytes)+1))
+				if err != nil {
+					return err
+				}
+				reqBody = head
+				req.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(head), req.Body), Closer: req.Body}
+			}
+
+			// 2. 响应边写边记录
+			res := c.Response()
+			orig := res.Writer
+			bw := &bodyLogWriter{ResponseWriter: orig, max: cfg.MaxBytes}
+			res.Writer = bw
+			defer func() { res.Writer = orig }()
+
+			// 与 LoggerMiddleware 相同，提前交给 c.Error 写出错误响应，
+			// 日志中的状态码和响应体才是客户端实际收到的
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
 
-		// 调用默认错误处理
-		echo.DefaultHTTPErrorHandler(err, c)
+			reqLog, reqTruncated := r.body(req.Header.Get(echo.HeaderContentType), reqBody, cfg.MaxBytes)
+			resLog, _ := r.body(res.Header().Get(echo.HeaderContentType), bw.buf.Bytes(), cfg.MaxBytes)
+			logger.WithContext(req.Context()).Info("http body",
+				"method", req.Method,
+				"path", req.URL.Path,
+				"status", res.Status,
+				"request_body", reqLog,
+				"request_truncated", reqTruncated,
+				"response_body", resLog,
+				"response_truncated", bw.truncated,
+			)
+			return nil
+		}
 	}
 }
 
-// ====== 主函数 ======
+// replayBody 先返回已读取的部分，再返回剩余的原始请求体
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
 
-func main() {
-	fmt.Println("=== Echo Web 框架示例 ===")
+// bodyLogWriter 写出响应的同时保留前 max 字节
+type bodyLogWriter struct {
+	http.ResponseWriter
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
 
-	// 1. 创建应用
-	e := createApp()
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	if room := w.max - w.buf.Len(); len(b) > room {
+		w.buf.Write(b[:max(room, 0)])
+		w.truncated = true
+	} else {
+		w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
 
-	// 2. 配置路由
-	setupRoutes(e)
+func (w *bodyLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
 
-	// 3. 配置静态文件
-	staticFileHandler(e)
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *bodyLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
 
-	// 4. 配置文件上传
-	uploadHandler(e)
+// redactor 按字段名脱敏
+type redactor struct {
+	keys     []string       // 小写的关键字
+	jsonExpr *regexp.Regexp // 截断的 JSON 使用的兜底规则
+}
+
+// newRedactor 创建脱敏器
+func newRedactor(keys []string) *redactor {
+	lower := make([]string, len(keys))
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		lower[i] = strings.ToLower(k)
+		quoted[i] = regexp.QuoteMeta(lower[i])
+	}
+	// 匹配 "xxx_password_xxx": "值"，值可能被截断而没有结尾引号
+	expr := regexp.MustCompile(`(?i)("[^"]*(?:` + strings.Join(quoted, "|") + `)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+	return &redactor{keys: lower, jsonExpr: expr}
+}
+
+// sensitive 字段名是否包含敏感关键字
+func (r *redactor) sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, k := range r.keys {
+		if strings.Contains(name, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// body 返回用于日志的脱敏内容，以及是否被截断
+func (r *redactor) body(contentType string, data []byte, max int) (string, bool) {
+	truncated := len(data) > max
+	if truncated {
+		data = data[:max]
+	}
+	if len(data) == 0 {
+		return "", false
+	}
+
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	switch {
+	case mediaType == echo.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json"):
+		return r.json(data), truncated
+	case mediaType == echo.MIMEApplicationForm:
+		return r.form(data), truncated
+	case strings.HasPrefix(mediaType, "text/"):
+		return string(data), truncated
+	default:
+		return fmt.Sprintf("[%d bytes of %s omitted]", len(data), cmp.Or(mediaType, "unknown content type")), truncated
+	}
+}
+
+// json 解析 JSON 并递归脱敏，无法解析（通常是被截断）时按正则替换
+func (r *redactor) json(data []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return r.jsonExpr.ReplaceAllString(string(data), `${1}"`+redactedValue+`"`)
+	}
+	out, err := json.Marshal(r.redactValue(v))
+	if err != nil {
+		return "[unloggable JSON body]"
+	}
+	return string(out)
+}
+
+// redactValue 递归替换对象中的敏感字段
+func (r *redactor) redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if r.sensitive(k) {
+				t[k] = redactedValue
+			} else {
+				t[k] = r.redactValue(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = r.redactValue(val)
+		}
+	}
+	return v
+}
+
+// form 按参数名脱敏 urlencoded 表单
+func (r *redactor) form(data []byte) string {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return "[unparseable form body]"
+	}
+	for k := range values {
+		if r.sensitive(k) {
+			values[k] = []string{redactedValue}
+		}
+	}
+	return values.Encode()
+}
+
+// ====== 超时中间件 ======
+/*
+Timeout 为单个路由设置处理时限：
+  e.GET("/reports", reportHandler, Timeout(2*time.Second))
+
+处理流程：
+  1. 从请求上下文派生带超时的子上下文，通过 c.Request().Context() 传给处理器
+  2. 处理器在单独的 goroutine 中执行
+  3. 超时后立即返回 503 {"error":"timeout"}，并取消子上下文，
+     使用该上下文的数据库、Redis 调用会随之中止
+  4. 处理器已经开始写响应时不再写 503，避免重复写出
+
+echo.Context 会被复用，因此超时后仍会等待处理器 goroutine 退出才返回，
+处理器应当检查 ctx.Done() 尽快结束。
+*/
+
+// Timeout 路由级超时中间件
+func Timeout(d time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, cancel := context.WithTimeout(c.Request().Context(), d)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			// 替换 ResponseWriter，超时后丢弃处理器的写入
+			res := c.Response()
+			orig := res.Writer
+			tw := &timeoutWriter{ctx: ctx, w: orig, h: make(http.Header)}
+			res.Writer = tw
+			defer func() { res.Writer = orig }()
+
+			done := make(chan error, 1)
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						panicked <- r
+					}
+				}()
+				done <- next(c)
+			}()
+
+			var err error
+			finished := false
+			select {
+			case err = <-done:
+				finished = true
+			case r := <-panicked:
+				// 在请求 goroutine 中重新 panic，交给 RecoveryMiddleware 处理
+				panic(r)
+			case <-ctx.Done():
+			}
+
+			// 在时限内完成
+			if finished && ctx.Err() == nil {
+				return err
+			}
+
+			// 已超时：处理器尚未写响应时返回 503
+			wrote := tw.timeout()
+			if wrote {
+				logger.WithContext(ctx).Warn("request timeout",
+					"method", c.Request().Method,
+					"path", c.Request().URL.Path,
+					"timeout", d.String(),
+				)
+			}
+
+			// 等待处理器退出后再返回，避免 echo.Context 被复用后仍在使用
+			if !finished {
+				select {
+				case err = <-done:
+				case r := <-panicked:
+					if !wrote {
+						panic(r)
+					}
+				}
+			}
+
+			if wrote {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// timeoutWriter 超时后丢弃写入的 ResponseWriter
+// 使用独立的 Header，避免超时写 503 时与处理器并发修改同一个 map
+type timeoutWriter struct {
+	mu          sync.Mutex
+	ctx         context.Context // 超时上下文，到期后拒绝处理器的写入
+	w           http.ResponseWriter
+	h           http.Header
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.ctx.Err() != nil {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeaderLocked(http.StatusOK)
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if f, ok := tw.w.(http.Flusher); ok && !tw.timedOut {
+		f.Flush()
+	}
+}
+
+// writeHeaderLocked 把处理器设置的 Header 复制到底层并写出状态码，调用方需持有 tw.mu
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	// 上下文到期后不再写出，留给 timeout() 写 503
+	if tw.timedOut || tw.wroteHeader || tw.ctx.Err() != nil {
+		return
+	}
+	tw.wroteHeader = true
+
+	dst := tw.w.Header()
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(code)
+}
+
+// timeout 标记超时，处理器尚未写响应时写出 503
+// 返回是否写出了 503
+func (tw *timeoutWriter) timeout() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.wroteHeader {
+		return false
+	}
+	tw.timedOut = true
+
+	tw.w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	tw.w.WriteHeader(http.StatusServiceUnavailable)
+	tw.w.Write([]byte(`{"error":"timeout"}`))
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return true
+}
+
+// reportHandler 模拟耗时的报表接口
+// 通过 ctx.Done() 感知超时并提前结束
+func reportHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	select {
+	case <-time.After(5 * time.Second):
+		return c.JSON(http.StatusOK, map[string]string{"report": "done"})
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ====== ETag 中间件 ======
+/*
+ETagMiddleware 为 GET 响应计算强 ETag（响应体的 SHA-256），支持条件请求：
+
+  第一次请求：
+    GET /api/v1/users/1
+    ← 200 OK
+      ETag: "3f2a..."
+
+  再次请求时带上 ETag：
+    GET /api/v1/users/1
+    If-None-Match: "3f2a..."
+    ← 304 Not Modified（没有响应体）
+
+实现方式：
+  1. 替换 ResponseWriter，把处理器写出的响应先缓冲起来
+  2. 处理器返回后对响应体求哈希，设置 ETag
+  3. If-None-Match 匹配时写 304，否则把缓冲的响应原样写出
+
+只处理 200 响应；流式响应（text/event-stream 或调用了 Flush）立即切换为直接写出，不计算 ETag。
+资源本身没有变化时仍然要执行处理器，节省的是带宽而不是服务端计算。
+*/
+
+// ETagMiddleware 为 GET 响应添加 ETag 并处理 If-None-Match
+func ETagMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Method != http.MethodGet {
+				return next(c)
+			}
+
+			res := c.Response()
+			orig := res.Writer
+			ew := &etagWriter{w: orig}
+			res.Writer = ew
+			defer func() { res.Writer = orig }()
+
+			if err := next(c); err != nil {
+				// 处理器出错时交给错误处理器，已缓冲的内容照常写出
+				ew.flushBuffered()
+				return err
+			}
+
+			ew.finish(c.Request().Header.Get("If-None-Match"))
+			return nil
+		}
+	}
+}
+
+// etagWriter 缓冲响应体的 ResponseWriter
+type etagWriter struct {
+	w           http.ResponseWriter
+	buf         bytes.Buffer
+	status      int  // 处理器设置的状态码，0 表示尚未写出
+	passthrough bool // 流式响应，直接写到底层
+}
+
+func (ew *etagWriter) Header() http.Header {
+	return ew.w.Header()
+}
+
+func (ew *etagWriter) WriteHeader(code int) {
+	if ew.passthrough {
+		ew.w.WriteHeader(code)
+		return
+	}
+	if ew.status != 0 {
+		return
+	}
+	ew.status = code
+
+	if strings.HasPrefix(ew.w.Header().Get(echo.HeaderContentType), "text/event-stream") {
+		ew.flushBuffered()
+	}
+}
+
+func (ew *etagWriter) Write(b []byte) (int, error) {
+	if ew.status == 0 {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.passthrough {
+		return ew.w.Write(b)
+	}
+	return ew.buf.Write(b)
+}
+
+// Flush 处理器主动刷新说明是流式响应，不再缓冲
+func (ew *etagWriter) Flush() {
+	ew.flushBuffered()
+	if f, ok := ew.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// flushBuffered 切换为直接写出，并写出已缓冲的状态码和响应体
+func (ew *etagWriter) flushBuffered() {
+	if ew.passthrough {
+		return
+	}
+	ew.passthrough = true
+
+	if ew.status == 0 {
+		// 处理器还没写过任何内容，之后的写入直接交给底层
+		return
+	}
+	ew.w.WriteHeader(ew.status)
+	ew.w.Write(ew.buf.Bytes())
+	ew.buf.Reset()
+}
+
+// finish 处理器正常返回后计算 ETag 并写出响应
+func (ew *etagWriter) finish(ifNoneMatch string) {
+	if ew.passthrough || ew.status != http.StatusOK {
+		ew.flushBuffered()
+		return
+	}
+
+	sum := sha256.Sum256(ew.buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	ew.w.Header().Set("ETag", etag)
+
+	if etagMatch(ifNoneMatch, etag) {
+		// 304 不能带响应体，也不应带描述响应体的头
+		h := ew.w.Header()
+		h.Del(echo.HeaderContentType)
+		h.Del(echo.HeaderContentLength)
+		ew.passthrough = true
+		ew.w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	ew.flushBuffered()
+}
+
+// etagMatch 判断 If-None-Match 是否匹配
+// If-None-Match 可以是 "*" 或逗号分隔的多个 ETag，使用弱比较（忽略 W/ 前缀）
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ====== 响应缓存中间件 ======
+/*
+CacheMiddleware 把 GET 响应（状态码、响应头、响应体）缓存到 Redis，
+命中时直接返回，不再执行处理器：
+
+  第一次请求：执行处理器，响应写入 Redis，响应头 X-Cache: MISS
+  TTL 内再次请求：从 Redis 读取并返回，响应头 X-Cache: HIT
+
+使用示例（database 包中的 RedisClient 通过 Client() 取得底层客户端）：
+  e.GET("/api/v1/posts", listPostsHandler,
+      CacheMiddleware(rc.Client(), time.Minute, nil)) // nil 使用默认键：请求路径 + 查询参数
+
+不缓存的情况：
+  - 非 GET 请求
+  - 带 Authorization 头的请求（响应可能因用户而异），CacheAuthorized 为 true 时放开
+  - 非 200 响应、流式响应
+  - 响应带 Set-Cookie，或 Cache-Control 含 no-store / private
+
+只缓存处理器设置的响应头：外层中间件在命中时会重新设置自己的头，
+缓存它们会让 X-Request-ID 等每个请求不同的值被重放给其他请求。
+
+Redis 出错时跳过缓存直接执行处理器，缓存故障不影响接口可用性。
+缓存不会主动失效，数据修改后最多有 TTL 的延迟，需要强一致的接口不要使用。
+*/
+
+// cacheKeyPrefix 响应缓存键前缀
+const cacheKeyPrefix = "echo:cache:"
+
+// CacheConfig 响应缓存配置
+type CacheConfig struct {
+	Client          redis.Cmdable               // Redis 客户端
+	TTL             time.Duration               // 缓存时长
+	KeyFunc         func(c echo.Context) string // 缓存键，默认为 URL 的路径和查询参数
+	CacheAuthorized bool                        // 是否缓存带 Authorization 头的请求
+}
+
+// cachedResponse 缓存的响应
+type cachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// CacheMiddleware 使用 Redis 缓存 GET 响应，keyFn 为 nil 时使用默认键
+func CacheMiddleware(client redis.Cmdable, ttl time.Duration, keyFn func(echo.Context) string) echo.MiddlewareFunc {
+	return CacheMiddlewareWithConfig(CacheConfig{Client: client, TTL: ttl, KeyFunc: keyFn})
+}
+
+// CacheMiddlewareWithConfig 按配置创建响应缓存中间件
+func CacheMiddlewareWithConfig(cfg CacheConfig) echo.MiddlewareFunc {
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = func(c echo.Context) string {
+			return c.Request().URL.RequestURI()
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method != http.MethodGet ||
+				(!cfg.CacheAuthorized && req.Header.Get(echo.HeaderAuthorization) != "") {
+				return next(c)
+			}
+
+			ctx := req.Context()
+			key := cacheKeyPrefix + cfg.KeyFunc(c)
+
+			// 1. 命中直接返回
+			data, err := cfg.Client.Get(ctx, key).Bytes()
+			if err == nil {
+				var cached cachedResponse
+				if err := json.Unmarshal(data, &cached); err == nil {
+					return writeCachedResponse(c, &cached)
+				}
+			} else if !errors.Is(err, redis.Nil) {
+				c.Logger().Warnf("响应缓存读取失败: %v", err)
+			}
+
+			// 2. 未命中：缓冲处理器的响应（复用 ETag 中间件的 etagWriter）
+			res := c.Response()
+			res.Header().Set("X-Cache", "MISS")
+			before := res.Header().Clone()
+			orig := res.Writer
+			bw := &etagWriter{w: orig}
+			res.Writer = bw
+			defer func() { res.Writer = orig }()
+
+			if err := next(c); err != nil {
+				bw.flushBuffered()
+				return err
+			}
+
+			// 3. 可缓存时写入 Redis，然后照常写出响应
+			if !bw.passthrough && bw.status == http.StatusOK && cacheableHeader(res.Header()) {
+				header := handlerHeader(before, res.Header())
+				data, err := json.Marshal(cachedResponse{Status: bw.status, Header: header, Body: bw.buf.Bytes()})
+				if err == nil {
+					err = cfg.Client.Set(ctx, key, data, cfg.TTL).Err()
+				}
+				if err != nil {
+					c.Logger().Warnf("响应缓存写入失败: %v", err)
+				}
+			}
+
+			bw.flushBuffered()
+			return nil
+		}
+	}
+}
+
+// writeCachedResponse 写出缓存的响应
+func writeCachedResponse(c echo.Context, cached *cachedResponse) error {
+	h := c.Response().Header()
+	for k, v := range cached.Header {
+		h[k] = v
+	}
+	h.Set("X-Cache", "HIT")
+
+	c.Response().WriteHeader(cached.Status)
+	_, err := c.Response().Write(cached.Body)
+	return err
+}
+
+// handlerHeader 处理器新增或修改的响应头
+// X-Request-ID 由内层中间件设置时也会出现在 after 中，同样不缓存
+func handlerHeader(before, after http.Header) http.Header {
+	header := make(http.Header)
+	for k, v := range after {
+		if k != echo.HeaderXRequestID && !slices.Equal(before[k], v) {
+			header[k] = slices.Clone(v)
+		}
+	}
+	return header
+}
+
+// cacheableHeader 根据响应头判断是否允许缓存
+func cacheableHeader(h http.Header) bool {
+	if h.Get("Set-Cookie") != "" {
+		return false
+	}
+	cc := strings.ToLower(h.Get(echo.HeaderCacheControl))
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
+
+// ====== 自定义错误处理 ======
+/*
+所有错误统一返回 JSON，客户端只需要处理一种格式：
+
+  404 路由不存在：
+    {"error": "Not found", "message": "...", "path": "/xxx", "method": "GET"}
+
+  405 路径存在但方法不对（Allow 响应头同时列出允许的方法）：
+    {"error": "Method not allowed", "message": "...", "path": "/api/v1/users",
+     "method": "PATCH", "allowed": ["OPTIONS", "GET", "POST"]}
+
+  其他 *echo.HTTPError：按其状态码和消息返回
+
+  非 HTTPError（数据库错误、panic 恢复后的错误等）：
+    一律 500，细节只写日志，不返回给客户端，避免泄露内部实现
+
+每个错误响应都带 request_id（与 X-Request-ID 响应头相同），5xx 的错误日志也带同一个值，
+用户反馈 "request_id: 9f8e..." 时可以直接搜到访问日志和错误日志。
+
+允许的方法来自路由器：Echo 匹配到路径但方法不对时，
+会把该路径已注册的方法写入 echo.ContextKeyHeaderAllow。
+*/
+
+// customErrorHandler 自定义错误处理器
+func customErrorHandler(err error, c echo.Context) {
+	// 处理器已经写出响应时无法再修改状态码
+	if c.Response().Committed {
+		return
+	}
+
+	req := c.Request()
+	code := http.StatusInternalServerError
+	body := map[string]interface{}{
+		"error":      "Internal server error",
+		"message":    "An unexpected error occurred",
+		"request_id": requestIDOf(c),
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		code = httpErr.Code
+		body["error"] = http.StatusText(code)
+		body["message"] = httpErrorMessage(httpErr)
+
+		switch code {
+		case http.StatusNotFound:
+			body["error"] = "Not found"
+			body["path"] = req.URL.Path
+			body["method"] = req.Method
+		case http.StatusMethodNotAllowed:
+			body["error"] = "Method not allowed"
+			body["path"] = req.URL.Path
+			body["method"] = req.Method
+			body["allowed"] = allowedMethods(c)
+		}
+	}
+
+	// 4xx 是客户端的问题，由访问日志记录即可；5xx 记录错误原因
+	if code >= http.StatusInternalServerError {
+		logger.WithContext(req.Context()).Error("request error",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", code,
+			"err", err,
+		)
+	}
+
+	// HEAD 请求不能带响应体
+	if req.Method == http.MethodHead {
+		err = c.NoContent(code)
+	} else {
+		err = c.JSON(code, body)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// httpErrorMessage 取出 HTTPError 的消息
+// Message 可能是字符串、error 或任意值，只有字符串原样返回
+func httpErrorMessage(he *echo.HTTPError) string {
+	if msg, ok := he.Message.(string); ok && msg != "" {
+		return msg
+	}
+	if text := http.StatusText(he.Code); text != "" {
+		return text
+	}
+	return "An error occurred"
+}
+
+// allowedMethods 当前路径已注册的方法列表
+// Allow 响应头已由 echo.MethodNotAllowedHandler 设置，这里只用于响应体
+func allowedMethods(c echo.Context) []string {
+	allow, _ := c.Get(echo.ContextKeyHeaderAllow).(string)
+	if allow == "" {
+		allow = c.Response().Header().Get(echo.HeaderAllow)
+	}
+	if allow == "" {
+		return []string{}
+	}
+	methods := strings.Split(allow, ",")
+	for i := range methods {
+		methods[i] = strings.TrimSpace(methods[i])
+	}
+	return methods
+}
+
+// ====== 数据验证 ======
+
+// validateUser 验证用户数据
+func validateUser(user *User) error {
+	if user.Username == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Username is required")
+	}
+	if len(user.Username) < 3 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Username must be at least 3 characters")
+	}
+	if user.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email is required")
+	}
+	if user.Age < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Age must be non-negative")
+	}
+	return nil
+}
+
+// ====== 请求体限制与 JSON 解码 ======
+/*
+c.Bind 对请求体没有大小限制，错误信息也难以区分：
+
+  - BodyLimit 中间件用 http.MaxBytesReader 限制请求体大小，超出时返回 413
+  - bindJSON 直接解码 JSON，把错误归为几类，返回明确的 400：
+      空请求体            → "Request body required"
+      语法错误 / 被截断    → "Invalid JSON"（带出错位置）
+      字段类型不匹配       → 指出字段名和期望的类型
+      请求体超出 BodyLimit → 413
+
+  POST /api/v1/users  {"username": "alice", "age": "abc"}
+  ← 400 {"error": "Invalid type for field \"age\": expected int, got string", ...}
+*/
+
+// defaultBodyLimit API 请求体的默认上限
+const defaultBodyLimit = 1 << 20 // 1MB
+
+// sizeFromEnv 从环境变量读取字节大小，未设置或格式错误时使用 def
+func sizeFromEnv(key string, def int64) int64 {
+	n, err := env.GetBytes(key, def)
+	if err != nil {
+		logger.Warn("配置格式错误，使用默认值", "err", err, "default", humanize.FormatBytes(def))
+	}
+	return n
+}
+
+// BodyLimit 请求体大小限制中间件
+// Content-Length 已知且超出时直接返回 413；未知时（分块传输）在读取超出时报错
+func BodyLimit(max int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.ContentLength > max {
+				return bodyTooLarge(max)
+			}
+			req.Body = http.MaxBytesReader(c.Response(), req.Body, max)
+			return next(c)
+		}
+	}
+}
+
+// bodyTooLarge 413 错误
+func bodyTooLarge(max int64) error {
+	return echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Request body exceeds %s", humanize.FormatBytes(max)))
+}
+
+// bindJSON 把 JSON 请求体解码到 dst，解码失败时返回 *echo.HTTPError
+func bindJSON(c echo.Context, dst interface{}) error {
+	if err := json.NewDecoder(c.Request().Body).Decode(dst); err != nil {
+		return jsonDecodeError(err)
+	}
+	return nil
+}
+
+// jsonDecodeError 把 encoding/json 的错误转换为 HTTP 错误
+func jsonDecodeError(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		tooLarge  *http.MaxBytesError
+	)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return echo.NewHTTPError(http.StatusBadRequest, "Request body required")
+
+	case errors.As(err, &tooLarge):
+		return bodyTooLarge(tooLarge.Limit)
+
+	case errors.As(err, &syntaxErr):
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Invalid JSON at offset %d", syntaxErr.Offset))
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		// 请求体在 JSON 结束前被截断
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON: unexpected end of input")
+
+	case errors.As(err, &typeErr):
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Invalid type for field %q: expected %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value))
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+}
+
+// ====== 静态文件服务 ======
+
+func staticFileHandler(e *echo.Echo) {
+	// 提供静态文件服务
+	e.Static("/static", "./static")
+	e.File("/favicon.ico", "./static/favicon.ico")
+}
+
+// ====== 文件上传 ======
+/*
+multipart/form-data 请求可以同时包含普通字段和文件：
+
+  curl -F "title=头像" -F "description=新头像" -F "file=@avatar.png" localhost:8080/upload
+
+BindMultipart 把整个表单绑定到结构体：
+  - 普通字段：使用 c.Bind，按 form 标签匹配
+  - 文件字段：*multipart.FileHeader 或 []*multipart.FileHeader 类型，按 form 标签从表单中取出
+  - 绑定后使用 validate 包校验，返回字段级错误
+  - 整个请求体超过 maxBytes 时返回 413
+*/
+
+// maxUploadSize 上传请求体的最大字节数
+const maxUploadSize = 10 << 20 // 10MB
+
+// multipartMemory 解析表单时保存在内存中的最大字节数，超出部分写入临时文件
+const multipartMemory = 8 << 20
+
+// UploadRequest 上传请求
+type UploadRequest struct {
+	Title       string                `json:"title" form:"title" validate:"required,max=100"`
+	Description string                `json:"description" form:"description" validate:"max=500"`
+	File        *multipart.FileHeader `json:"file" form:"file" validate:"required"`
+}
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// BindMultipart 解析 multipart 表单并绑定到 dst（结构体指针）
+// 校验失败时返回 validate.ValidationErrors，其他错误返回 *echo.HTTPError
+func BindMultipart(c echo.Context, dst interface{}, maxBytes int64) error {
+	req := c.Request()
+
+	// 1. 限制请求体大小，超出时读取会返回 *http.MaxBytesError
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, maxBytes)
+	if err := req.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("Request body exceeds %s", humanize.FormatBytes(maxBytes)))
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid multipart form: "+err.Error())
+	}
+
+	// 2. 绑定普通字段
+	if err := c.Bind(dst); err != nil {
+		return err
+	}
+
+	// 3. 绑定文件字段
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return echo.NewHTTPError(http.StatusInternalServerError, "BindMultipart: dst must be a struct pointer")
+	}
+	rv = rv.Elem()
+	files := req.MultipartForm.File
+	for i := 0; i < rv.NumField(); i++ {
+		sf := rv.Type().Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("form"), ",")
+		if name == "" || !sf.IsExported() {
+			continue
+		}
+
+		headers := files[name]
+		switch sf.Type {
+		case fileHeaderType:
+			if len(headers) > 0 {
+				rv.Field(i).Set(reflect.ValueOf(headers[0]))
+			}
+		case fileHeaderSliceType:
+			rv.Field(i).Set(reflect.ValueOf(headers))
+		}
+	}
+
+	// 4. 校验
+	return validate.Struct(dst)
+}
+
+// validationErrorResponse 返回字段级校验错误，格式与 Gin 相同（见 verr 包）
+func validationErrorResponse(c echo.Context, verrs validate.ValidationErrors) error {
+	return c.JSON(http.StatusBadRequest, verr.FromValidator(verrs).Response())
+}
+
+func uploadHandler(e *echo.Echo) {
+	// 上限可以用 UPLOAD_MAX_SIZE 覆盖，如 "50MiB"
+	maxSize := sizeFromEnv("UPLOAD_MAX_SIZE", maxUploadSize)
+
+	e.POST("/upload", func(c echo.Context) error {
+		// 1. 解析表单（字段 + 文件）
+		var req UploadRequest
+		if err := BindMultipart(c, &req, maxSize); err != nil {
+			var verrs validate.ValidationErrors
+			if errors.As(err, &verrs) {
+				return validationErrorResponse(c, verrs)
+			}
+			return err
+		}
+
+		// 2. 打开文件
+		src, err := req.File.Open()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		defer src.Close()
+
+		// 3. 保存文件（示例）
+		// dst, err := os.Create("./uploads/" + filepath.Base(req.File.Filename))
+		// if err != nil {
+		//     return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		// }
+		// defer dst.Close()
+		// io.Copy(dst, src)
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message":     "File uploaded successfully",
+			"title":       req.Title,
+			"description": req.Description,
+			"filename":    req.File.Filename,
+			"size":        req.File.Size,
+		})
+	})
+}
+
+// ====== 文件下载 ======
+
+// DownloadHandler 文件下载处理器
+// GET /download/*
+// http.ServeContent 自动处理 Range（断点续传）、If-Modified-Since 和 ETag
+func DownloadHandler(baseDir string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// 1. 获取请求的文件名
+		name, err := url.PathUnescape(c.Param("*"))
+		if err != nil || name == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid filename")
+		}
+
+		// 2. 防止路径穿越（如 ../../etc/passwd）
+		// filepath.Join 会清理路径，再检查结果是否仍在 baseDir 内
+		base, err := filepath.Abs(baseDir)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Internal server error")
+		}
+		fullPath := filepath.Join(base, filepath.FromSlash(name))
+		rel, err := filepath.Rel(base, fullPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+		}
+
+		// 3. 打开文件
+		f, err := os.Open(fullPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return echo.NewHTTPError(http.StatusNotFound, "File not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Internal server error")
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Internal server error")
+		}
+		if info.IsDir() {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+
+		// 4. 设置响应头
+		// ETag 由修改时间和大小生成，ServeContent 会据此处理 If-None-Match 和 If-Range
+		res := c.Response()
+		res.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+		// mime.FormatMediaType 按 RFC 2231 编码文件名，非 ASCII 和引号都能正确处理
+		res.Header().Set("Content-Disposition",
+			mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(fullPath)}))
+
+		// 5. 发送文件内容
+		http.ServeContent(res, c.Request(), info.Name(), info.ModTime(), f)
+		return nil
+	}
+}
+
+// ====== 重定向 ======
+
+func redirectHandler(e *echo.Echo) {
+	e.GET("/old", func(c echo.Context) error {
+		return c.Redirect(http.StatusMovedPermanently, "/new")
+	})
+
+	e.GET("/new", func(c echo.Context) error {
+		return c.String(http.StatusOK, "This is the new page!")
+	})
+}
+
+// ====== TLS 服务器 ======
+/*
+e.StartTLS 使用 Go 的默认 TLS 配置，也没有设置读超时。StartTLSServer 在此基础上：
+  - 最低 TLS 1.2；TLS 1.2 只保留支持前向保密的 ECDHE + AEAD 套件（TLS 1.3 的套件不可配置，本身都是安全的）
+  - 通过 ALPN 协商 HTTP/2（"h2"），客户端不支持时回退到 HTTP/1.1
+  - 设置 ReadHeaderTimeout / IdleTimeout，防止慢速攻击（Slowloris）占满连接
+    不设置 WriteTimeout，否则文件下载、SSE 等长响应会被截断
+
+使用示例：
+  // 证书文件
+  go http.ListenAndServe(":80", HTTPSRedirectHandler(""))
+  e.Logger.Fatal(StartTLSServer(e, ":443", "cert.pem", "key.pem"))
+
+  // Let's Encrypt 自动证书（需要公网可访问的 80/443 端口）
+  go http.ListenAndServe(":80", e.AutoTLSManager.HTTPHandler(HTTPSRedirectHandler("")))
+  e.Logger.Fatal(StartAutoTLSServer(e, ":443", "/var/cache/autocert", "example.com"))
+
+80 端口的处理器同时负责 ACME HTTP-01 验证（autocert 的 HTTPHandler）和跳转到 HTTPS。
+服务器由 e.TLSServer 承载，e.Shutdown 可以正常优雅关闭。
+
+本地测试可以用 go run $(go env GOROOT)/src/crypto/tls/generate_cert.go --host localhost 生成自签名证书。
+*/
+
+// secureTLSConfig 返回安全的 TLS 配置
+func secureTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		NextProtos:       []string{"h2", "http/1.1"},
+	}
+}
+
+// StartTLSServer 使用证书文件启动 HTTPS 服务器，阻塞直到服务器关闭
+func StartTLSServer(e *echo.Echo, addr, certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("加载证书失败: %w", err)
+	}
+
+	cfg := secureTLSConfig()
+	cfg.Certificates = []tls.Certificate{cert}
+	return startTLS(e, addr, cfg)
+}
+
+// StartAutoTLSServer 使用 Let's Encrypt 自动申请证书并启动 HTTPS 服务器
+// 只为 domains 中的域名申请证书，证书缓存在 cacheDir，重启后不必重新申请
+func StartAutoTLSServer(e *echo.Echo, addr, cacheDir string, domains ...string) error {
+	e.AutoTLSManager.Prompt = autocert.AcceptTOS
+	e.AutoTLSManager.HostPolicy = autocert.HostWhitelist(domains...)
+	e.AutoTLSManager.Cache = autocert.DirCache(cacheDir)
+
+	cfg := secureTLSConfig()
+	cfg.GetCertificate = e.AutoTLSManager.GetCertificate
+	// TLS-ALPN-01 验证通过 443 端口完成，需要在 ALPN 中声明
+	cfg.NextProtos = append(cfg.NextProtos, acme.ALPNProto)
+	return startTLS(e, addr, cfg)
+}
+
+// startTLS 配置 e.TLSServer 并启动
+func startTLS(e *echo.Echo, addr string, cfg *tls.Config) error {
+	s := e.TLSServer
+	s.Addr = addr
+	s.TLSConfig = cfg
+	s.ReadHeaderTimeout = 10 * time.Second
+	s.IdleTimeout = 120 * time.Second
+	return e.StartServer(s)
+}
+
+// HTTPSRedirectHandler 把 HTTP 请求跳转到 HTTPS 的同一地址
+// httpsPort 为空或 "443" 时跳转地址不带端口
+// GET/HEAD 使用 301，其他方法使用 308 以保留方法和请求体
+func HTTPSRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
+
+// ====== 主函数 ======
+
+func main() {
+	fmt.Println("=== Echo Web 框架示例 ===")
+
+	// 1. 创建应用
+	e := createApp()
+
+	// 2. 配置路由
+	setupRoutes(e)
+
+	// 3. 配置静态文件
+	staticFileHandler(e)
+
+	// 4. 配置文件上传
+	uploadHandler(e)
+
+	// 配置文件下载
+	e.GET("/download/*", DownloadHandler("./downloads"))
+
+	// 耗时接口单独设置超时
+	e.GET("/reports", reportHandler, Timeout(2*time.Second))
 
 	// 5. 配置重定向
 	redirectHandler(e)
 
-	// 6. 配置自定义 404
-	customNotFoundHandler(e)
+	// 6. 404/405 由 createApp 中设置的 customErrorHandler 统一处理
 
 	// 7. 添加中间件到特定路由
-	e.GET("/protected", AuthMiddleware(), func(c echo.Context) error {
-		userID := c.Get("user_id")
+	// Echo 的路由中间件放在处理器之后
+	e.GET("/protected", func(c echo.Context) error {
+		// 转换为标准 context 后读取，业务代码不依赖 Echo
+		userID, _ := reqctx.UserID(reqctx.FromEcho(c))
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"message": "Protected content",
 			"user_id": userID,
 		})
-	})
+	}, AuthMiddleware())
+
+	// 需要 admin 角色的路由
+	e.GET("/admin", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Admin content",
+		})
+	}, AuthMiddleware(), RequireRoles("admin"))
+
+	// 维护模式开关，只有 admin 可以查询和切换
+	e.GET(maintenanceAdminPath, maintenanceHandler(&maintenanceMode), AuthMiddleware(), RequireRoles("admin"))
+	e.PUT(maintenanceAdminPath, maintenanceHandler(&maintenanceMode), AuthMiddleware(), RequireRoles("admin"))
 
 	// 8. 启动服务器
-	// e.Start() 启动服务器
-	// 使用 StartTLS 可以启用 TLS（HTTPS）
+	// 配置了证书时启动 HTTPS（:8443），:8080 只负责跳转
+	if certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE"); certFile != "" && keyFile != "" {
+		go func() {
+			e.Logger.Error(http.ListenAndServe(":8080", HTTPSRedirectHandler("8443")))
+		}()
+		e.Logger.Fatal(StartTLSServer(e, ":8443", certFile, keyFile))
+	}
+
+	// e.Start() 启动 HTTP 服务器
 	e.Logger.Fatal(e.Start(":8080"))
 }
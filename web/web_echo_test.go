@@ -0,0 +1,1488 @@
+// web/web_echo_test.go
+// Echo 示例的测试
+//
+// web 目录下每个文件都是独立的示例程序（各有一个 main），需要按文件运行：
+//   go test web/web_echo.go web/web_echo_test.go
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/austoin/GolangTutorial/auth"
+	"github.com/austoin/GolangTutorial/logger"
+	"github.com/austoin/GolangTutorial/reqctx"
+	"github.com/austoin/GolangTutorial/testfixtures"
+)
+
+// ====== 测试辅助 ======
+
+// newTestEcho 使用示例错误处理器的 Echo 实例，只注册测试需要的路由和中间件
+func newTestEcho() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = customErrorHandler
+	return e
+}
+
+// serve 把请求交给 e 处理，返回记录的响应
+func serve(e *echo.Echo, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeJSON 把响应体解码到 v
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("响应不是 JSON: %q", rec.Body.String())
+	}
+}
+
+// logBuffer 并发安全的日志缓冲区，中间件可能在其他 goroutine 中写日志
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// reset 清空已记录的日志
+func (b *logBuffer) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+// entries 按行解码 JSON 日志
+func (b *logBuffer) entries(t *testing.T) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("日志不是 JSON: %q", line)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// find 返回第一条 msg 匹配的日志
+func (b *logBuffer) find(t *testing.T, msg string) map[string]any {
+	t.Helper()
+	for _, e := range b.entries(t) {
+		if e["msg"] == msg {
+			return e
+		}
+	}
+	t.Fatalf("没有找到日志 %q", msg)
+	return nil
+}
+
+// captureLogs 测试期间把默认日志器换成写入缓冲区的 JSON 日志器
+func captureLogs(t *testing.T) *logBuffer {
+	t.Helper()
+	saved := logger.L()
+	t.Cleanup(func() { logger.SetDefault(saved) })
+
+	buf := &logBuffer{}
+	logger.SetDefault(logger.New(logger.Config{Format: "json", Level: slog.LevelDebug, Output: buf}))
+	return buf
+}
+
+// ====== 健康检查 ======
+
+// withHealthChecks 在测试期间替换已注册的检查
+func withHealthChecks(t *testing.T, checks map[string]HealthCheck) {
+	t.Helper()
+	healthChecksMu.Lock()
+	saved := healthChecks
+	healthChecks = maps.Clone(checks)
+	healthChecksMu.Unlock()
+	t.Cleanup(func() {
+		healthChecksMu.Lock()
+		healthChecks = saved
+		healthChecksMu.Unlock()
+	})
+}
+
+func TestRunHealthChecks(t *testing.T) {
+	withHealthChecks(t, map[string]HealthCheck{
+		"db":    func(context.Context) error { return nil },
+		"redis": func(context.Context) error { return errors.New("connection refused") },
+	})
+
+	results, healthy := runHealthChecks(context.Background())
+	if healthy {
+		t.Error("healthy = true, want false")
+	}
+	if results["db"] != "ok" || results["redis"] != "connection refused" {
+		t.Errorf("results = %v", results)
+	}
+}
+
+func TestRunHealthChecksIgnoringContext(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	withHealthChecks(t, map[string]HealthCheck{
+		"db": func(context.Context) error { return nil },
+		// 不理会 ctx 的检查
+		"stuck": func(context.Context) error { <-block; return nil },
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	results, healthy := runHealthChecks(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("runHealthChecks 用时 %s，没有在 ctx 超时后返回", elapsed)
+	}
+	if healthy {
+		t.Error("healthy = true, want false")
+	}
+	if results["db"] != "ok" {
+		t.Errorf("results[db] = %q, want ok", results["db"])
+	}
+	if !strings.Contains(results["stuck"], "did not complete") {
+		t.Errorf("results[stuck] = %q, want \"check did not complete: ...\"", results["stuck"])
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	pass := func(context.Context) error { return nil }
+	tests := []struct {
+		name       string
+		checks     map[string]HealthCheck
+		wantCode   int
+		wantStatus string
+		wantChecks map[string]string
+	}{
+		{
+			name:       "全部通过",
+			checks:     map[string]HealthCheck{"db": pass, "redis": pass},
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+			wantChecks: map[string]string{"db": "ok", "redis": "ok"},
+		},
+		{
+			name: "一个失败",
+			checks: map[string]HealthCheck{
+				"db":    pass,
+				"redis": func(context.Context) error { return errors.New("connection refused") },
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "unavailable",
+			wantChecks: map[string]string{"db": "ok", "redis": "connection refused"},
+		},
+		{
+			name:       "没有注册检查",
+			checks:     map[string]HealthCheck{},
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+			wantChecks: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withHealthChecks(t, tt.checks)
+			e := newTestEcho()
+			setupRoutes(e)
+
+			rec := serve(e, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("GET /health/ready status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			var body struct {
+				Status string            `json:"status"`
+				Checks map[string]string `json:"checks"`
+			}
+			decodeJSON(t, rec, &body)
+			if body.Status != tt.wantStatus || !maps.Equal(body.Checks, tt.wantChecks) {
+				t.Errorf("响应 = %+v, want status %q, checks %v", body, tt.wantStatus, tt.wantChecks)
+			}
+
+			// 存活检查不执行依赖检查，依赖失败时仍返回 200
+			rec = serve(e, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"ok"`) {
+				t.Errorf("GET /health/live = %d %q, want 200 ok", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// ====== 分页与排序 ======
+
+func TestListSortingAndPaging(t *testing.T) {
+	e := newTestEcho()
+	e.GET("/users", listUsersHandler)
+	e.GET("/posts", listPostsHandler)
+
+	type page struct {
+		Data []struct {
+			ID uint `json:"id"`
+		} `json:"data"`
+		Total      int    `json:"total"`
+		TotalPages int    `json:"total_pages"`
+		Sort       string `json:"sort"`
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		wantIDs  []uint
+		wantSort string
+		wantPage int // 总页数
+	}{
+		{"用户默认按 id 升序", "/users", []uint{1, 2}, "id", 1},
+		{"用户按 age 倒序", "/users?sort=-age", []uint{2, 1}, "-age", 1},
+		{"用户按 username 升序", "/users?sort=username", []uint{1, 2}, "username", 1},
+		{"先排序再分页", "/users?sort=-age&page=2&page_size=1", []uint{1}, "-age", 2},
+		{"超出范围的页为空", "/users?sort=-age&page=3&page_size=1", nil, "-age", 2},
+		{"帖子默认按创建时间倒序", "/posts", []uint{2, 1}, "-created_at", 1},
+		{"帖子按创建时间升序", "/posts?sort=created_at", []uint{1, 2}, "created_at", 1},
+		{"帖子按标题倒序", "/posts?sort=-title&page_size=1", []uint{2}, "-title", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(e, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200, body = %s", rec.Code, rec.Body.String())
+			}
+			var got page
+			decodeJSON(t, rec, &got)
+
+			var ids []uint
+			for _, d := range got.Data {
+				ids = append(ids, d.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+			if got.Sort != tt.wantSort || got.Total != 2 || got.TotalPages != tt.wantPage {
+				t.Errorf("sort = %q, total = %d, total_pages = %d, want %q, 2, %d",
+					got.Sort, got.Total, got.TotalPages, tt.wantSort, tt.wantPage)
+			}
+		})
+	}
+
+	// 不在白名单中的字段（包括模型中存在但不允许排序的字段）返回 400
+	for _, target := range []string{"/users?sort=email", "/users?sort=-password", "/posts?sort=content", "/posts?sort=-"} {
+		rec := serve(e, httptest.NewRequest(http.MethodGet, target, nil))
+		var body map[string]any
+		decodeJSON(t, rec, &body)
+		if rec.Code != http.StatusBadRequest || !strings.HasPrefix(fmt.Sprint(body["message"]), "Unknown sort field") {
+			t.Errorf("GET %s = %d %v, want 400 Unknown sort field", target, rec.Code, body)
+		}
+	}
+}
+
+// ====== 中间件 ======
+
+func TestRequestIDMiddleware(t *testing.T) {
+	logs := captureLogs(t)
+	// 与 newApp 相同的顺序：请求 ID 最先执行
+	e := newTestEcho()
+	e.Use(RequestIDMiddleware(), LoggerMiddleware(), RecoveryMiddleware())
+
+	var fromCtx, fromKey string // 处理器中看到的请求 ID
+	record := func(c echo.Context) {
+		fromCtx = reqctx.RequestID(c.Request().Context())
+		fromKey, _ = c.Get(reqctx.KeyRequestID).(string)
+	}
+	e.GET("/ok", func(c echo.Context) error {
+		record(c)
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/fail", func(c echo.Context) error {
+		record(c)
+		return errors.New("database is down")
+	})
+	e.GET("/panic", func(c echo.Context) error {
+		record(c)
+		panic("boom")
+	})
+
+	tests := []struct {
+		name      string
+		path      string
+		header    string // 客户端传入的 X-Request-ID
+		wantKept  bool   // 是否沿用客户端的 ID
+		status    int
+		errorLogs []string // 除访问日志外应该带上请求 ID 的日志
+	}{
+		{"沿用客户端的 ID", "/ok", "req-abc_1.2:3", true, http.StatusOK, nil},
+		{"没有时生成", "/ok", "", false, http.StatusOK, nil},
+		{"包含非法字符时重新生成", "/ok", "req 1\r\nX-Evil: 1", false, http.StatusOK, nil},
+		{"过长时重新生成", "/ok", strings.Repeat("a", maxRequestIDLen+1), false, http.StatusOK, nil},
+		{"5xx 错误日志", "/fail", "req-fail", true, http.StatusInternalServerError, []string{"request error"}},
+		{"panic 日志", "/panic", "req-panic", true, http.StatusInternalServerError, []string{"panic recovered"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.reset()
+			fromCtx, fromKey = "", ""
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderXRequestID, tt.header)
+			}
+			rec := serve(e, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+
+			id := rec.Header().Get(echo.HeaderXRequestID)
+			if tt.wantKept && id != tt.header {
+				t.Errorf("响应头 X-Request-ID = %q, want %q", id, tt.header)
+			}
+			if !tt.wantKept && (id == "" || id == tt.header || !validRequestID(id)) {
+				t.Errorf("响应头 X-Request-ID = %q, want 新生成的合法 ID", id)
+			}
+
+			// 处理器、访问日志、错误日志和错误响应中都是同一个 ID
+			if fromCtx != id || fromKey != id {
+				t.Errorf("处理器中的请求 ID = %q (ctx), %q (c.Get), want %q", fromCtx, fromKey, id)
+			}
+			for _, msg := range append([]string{"request"}, tt.errorLogs...) {
+				if got := logs.find(t, msg)["request_id"]; got != id {
+					t.Errorf("日志 %q 的 request_id = %v, want %q", msg, got, id)
+				}
+			}
+			if access := logs.find(t, "request"); access["status"] != float64(tt.status) {
+				t.Errorf("访问日志 status = %v, want %d", access["status"], tt.status)
+			}
+			if tt.status >= http.StatusInternalServerError {
+				var body ErrorResponse
+				decodeJSON(t, rec, &body)
+				if body.RequestID != id {
+					t.Errorf("错误响应的 request_id = %q, want %q", body.RequestID, id)
+				}
+			}
+		})
+	}
+
+	t.Run("每个请求生成不同的 ID", func(t *testing.T) {
+		a := serve(e, httptest.NewRequest(http.MethodGet, "/ok", nil)).Header().Get(echo.HeaderXRequestID)
+		b := serve(e, httptest.NewRequest(http.MethodGet, "/ok", nil)).Header().Get(echo.HeaderXRequestID)
+		if a == b {
+			t.Errorf("两次生成的 ID 相同: %q", a)
+		}
+	})
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	logs := captureLogs(t)
+	e := newTestEcho()
+	e.Use(RequestIDMiddleware(), RecoveryMiddleware())
+	e.GET("/panic", func(c echo.Context) error { panic("boom") })
+	e.GET("/partial", func(c echo.Context) error {
+		c.String(http.StatusOK, "partial")
+		panic("after write")
+	})
+	e.GET("/abort", func(c echo.Context) error { panic(http.ErrAbortHandler) })
+
+	t.Run("返回 500 并记录堆栈", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+		req.Header.Set(echo.HeaderXRequestID, "req-123")
+		rec := serve(e, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want 500", rec.Code)
+		}
+		var body ErrorResponse
+		decodeJSON(t, rec, &body)
+		want := ErrorResponse{Error: "Internal server error", Message: "An unexpected error occurred", RequestID: "req-123"}
+		if body != want {
+			t.Errorf("body = %+v, want %+v", body, want)
+		}
+
+		entry := logs.find(t, "panic recovered")
+		if entry["panic"] != "boom" || entry["request_id"] != "req-123" || entry["path"] != "/panic" {
+			t.Errorf("日志 = %v", entry)
+		}
+		// 堆栈应该指向 panic 发生的位置
+		if stack, _ := entry["stack"].(string); !strings.Contains(stack, "web_echo_test.go") {
+			t.Errorf("堆栈中没有 panic 的位置: %q", stack)
+		}
+	})
+
+	t.Run("响应已写出时保留原状态码", func(t *testing.T) {
+		rec := serve(e, httptest.NewRequest(http.MethodGet, "/partial", nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "partial" {
+			t.Errorf("status = %d, body = %q, want 200 partial", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("ErrAbortHandler 继续向上抛出", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != http.ErrAbortHandler {
+				t.Errorf("recover() = %v, want http.ErrAbortHandler", r)
+			}
+		}()
+		serve(e, httptest.NewRequest(http.MethodGet, "/abort", nil))
+	})
+}
+
+// testToken 使用示例密钥签发的 JWT
+func testToken(t *testing.T, roles ...string) string {
+	t.Helper()
+	token, err := auth.GenerateToken(auth.Claims{UserID: 1, Roles: roles}, jwtSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	return token
+}
+
+func TestRequireRoles(t *testing.T) {
+	e := newTestEcho()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }
+	e.GET("/any", ok, AuthMiddleware(), RequireRoles("admin", "editor"))
+	e.GET("/all", ok, AuthMiddleware(), RequireRolesWithMode(MatchAllRoles, "admin", "editor"))
+	e.GET("/no-auth", ok, RequireRoles("admin")) // 漏挂 AuthMiddleware
+
+	tests := []struct {
+		name  string
+		path  string
+		token string // 为空时不带 Authorization
+		want  int
+	}{
+		{"any 模式拥有其中一个角色", "/any", testToken(t, "editor"), http.StatusNoContent},
+		{"any 模式拥有全部角色", "/any", testToken(t, "admin", "editor"), http.StatusNoContent},
+		{"any 模式没有任何角色", "/any", testToken(t, "viewer"), http.StatusForbidden},
+		{"all 模式拥有全部角色", "/all", testToken(t, "editor", "admin", "viewer"), http.StatusNoContent},
+		{"all 模式只拥有部分角色", "/all", testToken(t, "admin"), http.StatusForbidden},
+		{"all 模式没有角色", "/all", testToken(t), http.StatusForbidden},
+		{"没有 token", "/any", "", http.StatusUnauthorized},
+		{"token 无效", "/all", "not-a-jwt", http.StatusUnauthorized},
+		{"没有认证信息", "/no-auth", testToken(t, "admin"), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := serve(e, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, body = %s, want %d", rec.Code, rec.Body, tt.want)
+			}
+		})
+	}
+
+	t.Run("角色列表为空时 panic", func(t *testing.T) {
+		for _, mode := range []RoleMatch{MatchAnyRole, MatchAllRoles} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("RequireRolesWithMode(%d) 没有角色时应该 panic", mode)
+					}
+				}()
+				RequireRolesWithMode(mode)
+			}()
+		}
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	logs := captureLogs(t)
+	e := newTestEcho()
+	handlerErr := make(chan error, 1)
+	e.GET("/slow", func(c echo.Context) error {
+		<-c.Request().Context().Done()
+		handlerErr <- c.Request().Context().Err()
+		return c.JSON(http.StatusOK, map[string]string{"report": "done"})
+	}, Timeout(20*time.Millisecond))
+	e.GET("/fast", func(c echo.Context) error {
+		c.Response().Header().Set("X-Handler", "fast")
+		return c.JSON(http.StatusOK, map[string]string{"report": "done"})
+	}, Timeout(time.Second))
+
+	t.Run("超时返回 503 并取消处理器的上下文", func(t *testing.T) {
+		rec := serve(e, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != `{"error":"timeout"}` {
+			t.Errorf("status = %d, body = %q, want 503 {\"error\":\"timeout\"}", rec.Code, rec.Body.String())
+		}
+		// 超时后处理器的写入被丢弃，不会追加到 503 之后
+		if err := <-handlerErr; !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("处理器看到的 ctx.Err() = %v, want context.DeadlineExceeded", err)
+		}
+		if entry := logs.find(t, "request timeout"); entry["path"] != "/slow" || entry["timeout"] != "20ms" {
+			t.Errorf("日志 = %v", entry)
+		}
+	})
+
+	t.Run("时限内完成正常返回", func(t *testing.T) {
+		rec := serve(e, httptest.NewRequest(http.MethodGet, "/fast", nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("X-Handler") != "fast" {
+			t.Errorf("status = %d, X-Handler = %q, want 200 fast", rec.Code, rec.Header().Get("X-Handler"))
+		}
+		var body map[string]string
+		decodeJSON(t, rec, &body)
+		if body["report"] != "done" {
+			t.Errorf("body = %v, want report=done", body)
+		}
+	})
+}
+
+// ====== 维护模式 ======
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		allowReads bool
+		method     string
+		path       string
+		want       int
+	}{
+		{"关闭时写请求照常处理", false, true, http.MethodPost, "/users", http.StatusOK},
+		{"开启后拒绝 POST", true, true, http.MethodPost, "/users", http.StatusServiceUnavailable},
+		{"开启后拒绝 DELETE", true, true, http.MethodDelete, "/users", http.StatusServiceUnavailable},
+		{"AllowReads 时放行 GET", true, true, http.MethodGet, "/users", http.StatusOK},
+		{"AllowReads 时放行 HEAD", true, true, http.MethodHead, "/users", http.StatusOK},
+		{"不 AllowReads 时拒绝 GET", true, false, http.MethodGet, "/users", http.StatusServiceUnavailable},
+		{"健康检查始终放行", true, false, http.MethodGet, "/health", http.StatusOK},
+		{"健康检查的子路径也放行", true, false, http.MethodGet, "/health/live", http.StatusOK},
+		{"前缀相同的其他路径不放行", true, false, http.MethodGet, "/healthz", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var flag atomic.Bool
+			flag.Store(tt.enabled)
+			e := newTestEcho()
+			e.Use(MaintenanceMiddleware(&flag, MaintenanceConfig{
+				RetryAfter: 1500 * time.Millisecond,
+				AllowReads: tt.allowReads,
+				AllowPaths: []string{"/health"},
+			}))
+			ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+			e.Any("/users", ok)
+			e.GET("/health", ok)
+			e.GET("/health/live", ok)
+			e.GET("/healthz", ok)
+
+			rec := serve(e, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, body = %s, want %d", rec.Code, rec.Body, tt.want)
+			}
+			if tt.want != http.StatusServiceUnavailable {
+				return
+			}
+
+			// 1.5s 向上取整为 2 秒
+			if got := rec.Header().Get("Retry-After"); got != "2" {
+				t.Errorf("Retry-After = %q, want 2", got)
+			}
+			var body ErrorResponse
+			decodeJSON(t, rec, &body)
+			if body.Error != "Service unavailable" || body.Message == "" {
+				t.Errorf("响应 = %+v", body)
+			}
+		})
+	}
+}
+
+func TestMaintenanceToggle(t *testing.T) {
+	var flag atomic.Bool
+	e := newTestEcho()
+	e.Use(MaintenanceMiddleware(&flag, MaintenanceConfig{
+		AllowPaths: []string{maintenanceAdminPath},
+		Message:    "migrating",
+	}))
+	e.POST("/users", func(c echo.Context) error { return c.NoContent(http.StatusCreated) })
+	e.GET(maintenanceAdminPath, maintenanceHandler(&flag), AuthMiddleware(), RequireRoles("admin"))
+	e.PUT(maintenanceAdminPath, maintenanceHandler(&flag), AuthMiddleware(), RequireRoles("admin"))
+
+	toggle := func(body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, maintenanceAdminPath, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return serve(e, req)
+	}
+	write := func() int {
+		return serve(e, httptest.NewRequest(http.MethodPost, "/users", nil)).Code
+	}
+	admin := testToken(t, "admin")
+
+	if rec := toggle(`{"enabled":true}`, admin); rec.Code != http.StatusOK || !flag.Load() {
+		t.Fatalf("开启 status = %d, body = %s, flag = %v", rec.Code, rec.Body, flag.Load())
+	}
+	rec := serve(e, httptest.NewRequest(http.MethodPost, "/users", nil))
+	var body ErrorResponse
+	decodeJSON(t, rec, &body)
+	if rec.Code != http.StatusServiceUnavailable || body.Message != "migrating" || rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("开启后写请求 status = %d, body = %+v, Retry-After = %q, want 503、自定义说明和默认 60 秒",
+			rec.Code, body, rec.Header().Get("Retry-After"))
+	}
+
+	// 管理接口在 AllowPaths 中，维护期间仍能查询和关闭
+	req := httptest.NewRequest(http.MethodGet, maintenanceAdminPath, nil)
+	req.Header.Set("Authorization", "Bearer "+admin)
+	if rec := serve(e, req); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"maintenance":true`) {
+		t.Errorf("查询 status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if rec := toggle(`{"enabled":true}`, testToken(t, "viewer")); rec.Code != http.StatusForbidden {
+		t.Errorf("非管理员切换 status = %d, want 403", rec.Code)
+	}
+	if rec := toggle(`{}`, admin); rec.Code != http.StatusBadRequest || !flag.Load() {
+		t.Errorf("缺少 enabled status = %d, flag = %v, want 400 且不改变开关", rec.Code, flag.Load())
+	}
+	if rec := toggle(`{"enabled":false}`, admin); rec.Code != http.StatusOK || flag.Load() {
+		t.Fatalf("关闭 status = %d, body = %s, flag = %v", rec.Code, rec.Body, flag.Load())
+	}
+	if code := write(); code != http.StatusCreated {
+		t.Errorf("关闭后写请求 status = %d, want 201", code)
+	}
+}
+
+// ====== 请求体日志 ======
+
+func TestBodyLogMiddleware(t *testing.T) {
+	logs := captureLogs(t)
+	e := newTestEcho()
+	e.Use(BodyLogMiddleware(BodyLogConfig{Enabled: true, MaxBytes: 256}))
+
+	// 处理器把收到的请求体原样返回，用来确认没有被脱敏或截断
+	var received []byte
+	e.POST("/echo", func(c echo.Context) error {
+		received, _ = io.ReadAll(c.Request().Body)
+		return c.JSONBlob(http.StatusOK, []byte(`{"access_token":"tok-1","user":"alice"}`))
+	})
+	e.POST("/fail", func(c echo.Context) error {
+		io.ReadAll(c.Request().Body)
+		return echo.NewHTTPError(http.StatusBadRequest, "bad input")
+	})
+
+	tests := []struct {
+		name          string
+		path          string
+		contentType   string
+		body          string
+		wantStatus    int
+		wantReqLog    string // 记录的请求体需要包含的内容
+		wantTruncated bool
+		notInLog      string // 不应出现在日志中的内容
+	}{
+		{
+			"JSON 字段递归脱敏", "/echo", echo.MIMEApplicationJSON,
+			`{"username":"alice","password":"123456","profile":{"API_KEY":"k1"},"items":[{"token":"t1"}]}`,
+			http.StatusOK, `{"items":[{"token":"[REDACTED]"}],"password":"[REDACTED]","profile":{"API_KEY":"[REDACTED]"},"username":"alice"}`, false, "123456",
+		},
+		{
+			"短 JSON 完整记录", "/echo", echo.MIMEApplicationJSON,
+			`{"password":"123456","username":"alice"}`,
+			http.StatusOK, `{"password":"[REDACTED]","username":"alice"}`, false, "123456",
+		},
+		{
+			"表单按参数名脱敏", "/echo", echo.MIMEApplicationForm,
+			"username=alice&user_password=123456",
+			http.StatusOK, "user_password=%5BREDACTED%5D&username=alice", false, "123456",
+		},
+		{
+			"截断的 JSON 按正则脱敏", "/echo", echo.MIMEApplicationJSON,
+			`{"note":"` + strings.Repeat("x", 220) + `","secret":"123456-very-long-secret-value"}`,
+			http.StatusOK, `"secret":"[REDACTED]"`, true, "123456",
+		},
+		{
+			"二进制内容只记录长度", "/echo", "image/png",
+			"\x89PNG....",
+			http.StatusOK, "[8 bytes of image/png omitted]", false, "PNG",
+		},
+		{
+			"错误响应记录最终状态码", "/fail", echo.MIMEApplicationJSON,
+			`{"password":"123456"}`,
+			http.StatusBadRequest, `{"password":"[REDACTED]"}`, false, "123456",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.reset()
+			received = nil
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, tt.contentType)
+			rec := serve(e, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.path == "/echo" && string(received) != tt.body {
+				t.Errorf("处理器收到 %q, want 完整的原始请求体", received)
+			}
+
+			entry := logs.find(t, "http body")
+			if got := entry["status"]; got != float64(tt.wantStatus) {
+				t.Errorf("日志 status = %v, want %d", got, tt.wantStatus)
+			}
+			reqLog, _ := entry["request_body"].(string)
+			if !strings.Contains(reqLog, tt.wantReqLog) {
+				t.Errorf("request_body = %q, want 包含 %q", reqLog, tt.wantReqLog)
+			}
+			if entry["request_truncated"] != tt.wantTruncated {
+				t.Errorf("request_truncated = %v, want %v", entry["request_truncated"], tt.wantTruncated)
+			}
+			for k, v := range entry {
+				if s, ok := v.(string); ok && strings.Contains(s, tt.notInLog) {
+					t.Errorf("日志字段 %s = %q, 不应包含 %q", k, s, tt.notInLog)
+				}
+			}
+		})
+	}
+
+	t.Run("响应体脱敏", func(t *testing.T) {
+		logs.reset()
+
+		rec := serve(e, httptest.NewRequest(http.MethodPost, "/echo", nil))
+		// 客户端收到的是原始响应
+		if !strings.Contains(rec.Body.String(), "tok-1") {
+			t.Errorf("响应体 = %q, 应该是原始内容", rec.Body.String())
+		}
+		entry := logs.find(t, "http body")
+		if got := entry["response_body"]; got != `{"access_token":"[REDACTED]","user":"alice"}` {
+			t.Errorf("response_body = %v", got)
+		}
+	})
+
+	t.Run("错误响应体被记录", func(t *testing.T) {
+		logs.reset()
+
+		serve(e, httptest.NewRequest(http.MethodPost, "/fail", nil))
+		entry := logs.find(t, "http body")
+		if got, _ := entry["response_body"].(string); !strings.Contains(got, "bad input") {
+			t.Errorf("response_body = %q, 应该包含错误处理器写出的内容", got)
+		}
+	})
+}
+
+func TestBodyLogMiddlewareDisabled(t *testing.T) {
+	logs := captureLogs(t)
+	e := newTestEcho()
+	e.Use(BodyLogMiddleware(BodyLogConfig{}))
+	e.POST("/echo", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	serve(e, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"password":"x"}`)))
+	if entries := logs.entries(t); len(entries) != 0 {
+		t.Errorf("未启用时不应记录日志, got %v", entries)
+	}
+}
+
+// ====== ETag 中间件 ======
+
+func TestETagMiddleware(t *testing.T) {
+	e := newTestEcho()
+	report := "v1"
+	e.Use(ETagMiddleware())
+	e.GET("/report", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"report": report})
+	})
+	e.GET("/missing", func(c echo.Context) error {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "missing"})
+	})
+	e.POST("/report", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"report": report})
+	})
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		return serve(e, req)
+	}
+
+	first := get("/report", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || !strings.Contains(first.Body.String(), "v1") {
+		t.Fatalf("首次请求 status = %d, ETag = %q, body = %q", first.Code, etag, first.Body.String())
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        int
+	}{
+		{"ETag 匹配返回 304", etag, http.StatusNotModified},
+		{"弱比较忽略 W/ 前缀", "W/" + etag, http.StatusNotModified},
+		{"多个候选中有一个匹配", `"other", ` + etag, http.StatusNotModified},
+		{"通配符", "*", http.StatusNotModified},
+		{"不匹配返回完整响应", `"other"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get("/report", tt.ifNoneMatch)
+			if rec.Code != tt.want || rec.Header().Get("ETag") != etag {
+				t.Fatalf("status = %d, ETag = %q, want %d %q", rec.Code, rec.Header().Get("ETag"), tt.want, etag)
+			}
+			if tt.want == http.StatusNotModified {
+				// 304 不能带响应体，也不带描述响应体的头
+				if rec.Body.Len() != 0 || rec.Header().Get(echo.HeaderContentType) != "" {
+					t.Errorf("304 body = %q, Content-Type = %q, want 都为空", rec.Body.String(), rec.Header().Get(echo.HeaderContentType))
+				}
+			} else if rec.Body.String() != first.Body.String() {
+				t.Errorf("body = %q, want %q", rec.Body.String(), first.Body.String())
+			}
+		})
+	}
+
+	t.Run("内容变化后 ETag 变化", func(t *testing.T) {
+		report = "v2"
+		defer func() { report = "v1" }()
+		rec := get("/report", etag)
+		if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+			t.Errorf("status = %d, ETag = %q, want 200 和新的 ETag", rec.Code, rec.Header().Get("ETag"))
+		}
+	})
+
+	t.Run("非 200 和非 GET 不计算 ETag", func(t *testing.T) {
+		if rec := get("/missing", "*"); rec.Code != http.StatusNotFound || rec.Header().Get("ETag") != "" {
+			t.Errorf("404 status = %d, ETag = %q", rec.Code, rec.Header().Get("ETag"))
+		}
+		req := httptest.NewRequest(http.MethodPost, "/report", nil)
+		req.Header.Set("If-None-Match", "*")
+		if rec := serve(e, req); rec.Code != http.StatusOK || rec.Header().Get("ETag") != "" {
+			t.Errorf("POST status = %d, ETag = %q", rec.Code, rec.Header().Get("ETag"))
+		}
+	})
+}
+
+// ====== 响应缓存中间件 ======
+
+func TestCacheMiddleware(t *testing.T) {
+	client, mr := testfixtures.NewTestRedis(t)
+	e := newTestEcho()
+	e.Use(RequestIDMiddleware())
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Response().Header().Set("X-Outer", "outer")
+			return next(c)
+		}
+	})
+
+	calls := 0
+	cache := CacheMiddleware(client, time.Minute, nil)
+	e.GET("/posts", func(c echo.Context) error {
+		calls++
+		c.Response().Header().Set("X-Handler", fmt.Sprint(calls))
+		return c.JSON(http.StatusOK, map[string]int{"calls": calls})
+	}, cache)
+	e.GET("/private", func(c echo.Context) error {
+		calls++
+		c.Response().Header().Set(echo.HeaderCacheControl, "private")
+		return c.String(http.StatusOK, "mine")
+	}, cache)
+	e.GET("/missing", func(c echo.Context) error {
+		calls++
+		return echo.NewHTTPError(http.StatusNotFound, "missing")
+	}, cache)
+
+	get := func(path, requestID string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderXRequestID, requestID)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		return serve(e, req)
+	}
+
+	miss := get("/posts?page=1", "req-1")
+	if miss.Header().Get("X-Cache") != "MISS" || calls != 1 {
+		t.Fatalf("第一次请求 X-Cache = %q, 调用 %d 次, want MISS 和 1", miss.Header().Get("X-Cache"), calls)
+	}
+
+	t.Run("命中时不执行处理器", func(t *testing.T) {
+		hit := get("/posts?page=1", "req-2")
+		if hit.Header().Get("X-Cache") != "HIT" || calls != 1 {
+			t.Fatalf("X-Cache = %q, 调用 %d 次, want HIT 且处理器未执行", hit.Header().Get("X-Cache"), calls)
+		}
+		if hit.Code != http.StatusOK || hit.Body.String() != miss.Body.String() ||
+			hit.Header().Get("X-Handler") != "1" || hit.Header().Get(echo.HeaderContentType) != miss.Header().Get(echo.HeaderContentType) {
+			t.Errorf("命中的响应 = %d %v %q, want 与第一次相同", hit.Code, hit.Header(), hit.Body.String())
+		}
+		// 外层中间件的头来自本次请求，而不是缓存
+		if got := hit.Header().Get(echo.HeaderXRequestID); got != "req-2" {
+			t.Errorf("X-Request-ID = %q, want req-2", got)
+		}
+	})
+
+	t.Run("只缓存处理器设置的头", func(t *testing.T) {
+		var cached cachedResponse
+		data, _ := mr.Get(cacheKeyPrefix + "/posts?page=1")
+		if err := json.Unmarshal([]byte(data), &cached); err != nil {
+			t.Fatalf("缓存的内容不是 JSON: %v", err)
+		}
+		for _, k := range []string{echo.HeaderXRequestID, "X-Outer", "X-Cache"} {
+			if _, ok := cached.Header[k]; ok {
+				t.Errorf("缓存的响应头包含 %s: %v", k, cached.Header)
+			}
+		}
+		if cached.Header.Get("X-Handler") != "1" {
+			t.Errorf("缓存的响应头 = %v, want 包含 X-Handler", cached.Header)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		header []string
+		want   []string // 连续两次请求的 X-Cache
+	}{
+		{"查询参数不同使用不同的键", "/posts?page=2", nil, []string{"MISS", "HIT"}},
+		{"带 Authorization 不使用缓存", "/posts?page=1", []string{echo.HeaderAuthorization, "Bearer x"}, []string{"", ""}},
+		{"Cache-Control: private 不缓存", "/private", nil, []string{"MISS", "MISS"}},
+		{"非 200 不缓存", "/missing", nil, []string{"MISS", "MISS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				before := calls
+				rec := get(tt.path, "req-3", tt.header...)
+				if got := rec.Header().Get("X-Cache"); got != want {
+					t.Errorf("第 %d 次请求 X-Cache = %q, want %q", i+1, got, want)
+				}
+				if executed := calls > before; executed != (want != "HIT") {
+					t.Errorf("第 %d 次请求执行处理器 = %v", i+1, executed)
+				}
+			}
+		})
+	}
+
+	t.Run("Redis 故障时直接执行处理器", func(t *testing.T) {
+		mr.Close()
+		before := calls
+		// 限制重试时间，客户端默认会重试连接
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		rec := serve(e, httptest.NewRequest(http.MethodGet, "/posts?page=1", nil).WithContext(ctx))
+		if rec.Code != http.StatusOK || calls != before+1 {
+			t.Errorf("status = %d, 调用 %d 次, want 200 且执行处理器", rec.Code, calls-before)
+		}
+	})
+}
+
+// ====== 自定义错误处理 ======
+
+func TestCustomErrorHandler(t *testing.T) {
+	logs := captureLogs(t)
+	e := newTestEcho()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/users", ok)
+	e.POST("/users", ok)
+	e.GET("/conflict", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusConflict, "Username taken")
+	})
+	e.GET("/internal", func(c echo.Context) error {
+		return errors.New("dial tcp 10.0.0.5:3306: connection refused")
+	})
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(echo.HeaderXRequestID, "req-1")
+		return serve(e, req)
+	}
+
+	t.Run("404 路由不存在", func(t *testing.T) {
+		rec := request(http.MethodGet, "/nope")
+		var body map[string]any
+		decodeJSON(t, rec, &body)
+		if rec.Code != http.StatusNotFound || body["error"] != "Not found" ||
+			body["path"] != "/nope" || body["method"] != "GET" || body["request_id"] != "req-1" {
+			t.Errorf("status = %d, body = %v", rec.Code, body)
+		}
+	})
+
+	t.Run("405 列出允许的方法", func(t *testing.T) {
+		rec := request(http.MethodPatch, "/users")
+		var body struct {
+			Error   string   `json:"error"`
+			Method  string   `json:"method"`
+			Allowed []string `json:"allowed"`
+		}
+		decodeJSON(t, rec, &body)
+		if rec.Code != http.StatusMethodNotAllowed || body.Error != "Method not allowed" || body.Method != "PATCH" {
+			t.Fatalf("status = %d, body = %+v", rec.Code, body)
+		}
+		for _, m := range []string{http.MethodGet, http.MethodPost} {
+			if !slices.Contains(body.Allowed, m) || !strings.Contains(rec.Header().Get(echo.HeaderAllow), m) {
+				t.Errorf("allowed = %v, Allow = %q, want 包含 %s", body.Allowed, rec.Header().Get(echo.HeaderAllow), m)
+			}
+		}
+	})
+
+	t.Run("HTTPError 使用其状态码和消息", func(t *testing.T) {
+		rec := request(http.MethodGet, "/conflict")
+		var body map[string]any
+		decodeJSON(t, rec, &body)
+		if rec.Code != http.StatusConflict || body["error"] != "Conflict" || body["message"] != "Username taken" {
+			t.Errorf("status = %d, body = %v", rec.Code, body)
+		}
+	})
+
+	t.Run("其他错误返回 500 且不泄露细节", func(t *testing.T) {
+		rec := request(http.MethodGet, "/internal")
+		if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "10.0.0.5") {
+			t.Errorf("status = %d, body = %q", rec.Code, rec.Body.String())
+		}
+		// 细节只写日志
+		if entry := logs.find(t, "request error"); !strings.Contains(fmt.Sprint(entry["err"]), "10.0.0.5") {
+			t.Errorf("日志 = %v, want 包含原始错误", entry)
+		}
+	})
+
+	t.Run("HEAD 请求不带响应体", func(t *testing.T) {
+		rec := request(http.MethodHead, "/nope")
+		if rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+			t.Errorf("status = %d, body = %q, want 404 且无响应体", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+// ====== 请求体限制与 JSON 解码 ======
+
+func TestBodyLimitAndBindJSON(t *testing.T) {
+	e := newTestEcho()
+	e.POST("/users", func(c echo.Context) error {
+		var req struct {
+			Username string `json:"username"`
+			Age      int    `json:"age"`
+		}
+		if err := bindJSON(c, &req); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, req)
+	}, BodyLimit(64))
+
+	tests := []struct {
+		name        string
+		body        string
+		chunked     bool // 不设置 Content-Length，读取时才发现超限
+		wantCode    int
+		wantMessage string
+	}{
+		{"合法请求", `{"username":"alice","age":30}`, false, http.StatusOK, ""},
+		{"Content-Length 超限", `{"username":"` + strings.Repeat("a", 100) + `"}`, false,
+			http.StatusRequestEntityTooLarge, "Request body exceeds 64 B"},
+		{"分块传输读取时超限", `{"username":"` + strings.Repeat("a", 100) + `"}`, true,
+			http.StatusRequestEntityTooLarge, "Request body exceeds 64 B"},
+		{"空请求体", "", false, http.StatusBadRequest, "Request body required"},
+		{"语法错误", `{"username":alice}`, false, http.StatusBadRequest, "Invalid JSON at offset 13"},
+		{"被截断", `{"username":"alice"`, false, http.StatusBadRequest, "Invalid JSON: unexpected end of input"},
+		{"字段类型不匹配", `{"username":"alice","age":"abc"}`, false, http.StatusBadRequest,
+			`Invalid type for field "age": expected int, got string`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := serve(e, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d, body = %q", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantMessage == "" {
+				return
+			}
+			var body map[string]any
+			decodeJSON(t, rec, &body)
+			if body["message"] != tt.wantMessage {
+				t.Errorf("message = %q, want %q", body["message"], tt.wantMessage)
+			}
+		})
+	}
+}
+
+// ====== 文件上传 ======
+
+// newUploadRequest 构造 multipart 上传请求，filename 为空时不带文件
+func newUploadRequest(t *testing.T, fields map[string]string, filename string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	if filename != "" {
+		fw, err := w.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatalf("CreateFormFile() error = %v", err)
+		}
+		fw.Write(content)
+	}
+	w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	return req
+}
+
+func TestUploadHandler(t *testing.T) {
+	t.Setenv("UPLOAD_MAX_SIZE", "1KiB")
+	e := newTestEcho()
+	uploadHandler(e)
+
+	t.Run("上传成功", func(t *testing.T) {
+		rec := serve(e, newUploadRequest(t, map[string]string{"title": "头像", "description": "新头像"}, "avatar.png", []byte("PNG data")))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s, want 200", rec.Code, rec.Body)
+		}
+		var body map[string]any
+		decodeJSON(t, rec, &body)
+		if body["title"] != "头像" || body["description"] != "新头像" || body["filename"] != "avatar.png" || body["size"] != float64(len("PNG data")) {
+			t.Errorf("body = %v", body)
+		}
+	})
+
+	t.Run("请求体超出上限返回 413", func(t *testing.T) {
+		rec := serve(e, newUploadRequest(t, map[string]string{"title": "大文件"}, "big.bin", bytes.Repeat([]byte("x"), 2<<10)))
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status = %d, body = %s, want 413", rec.Code, rec.Body)
+		}
+		var body ErrorResponse
+		decodeJSON(t, rec, &body)
+		if body.Message != "Request body exceeds 1 KiB" {
+			t.Errorf("message = %q", body.Message)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		fields   map[string]string
+		filename string
+		want     map[string]string // 字段 -> 失败的规则
+	}{
+		{"缺少标题", nil, "a.txt", map[string]string{"title": "required"}},
+		{"标题过长", map[string]string{"title": strings.Repeat("t", 101)}, "a.txt", map[string]string{"title": "max"}},
+		{"缺少文件", map[string]string{"title": "t"}, "", map[string]string{"file": "required"}},
+		{"多个字段无效", map[string]string{"description": strings.Repeat("d", 501)}, "",
+			map[string]string{"title": "required", "description": "max", "file": "required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(e, newUploadRequest(t, tt.fields, tt.filename, []byte("data")))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, body = %s, want 400", rec.Code, rec.Body)
+			}
+			var body struct {
+				Error  string `json:"error"`
+				Fields []struct {
+					Field string `json:"field"`
+					Tag   string `json:"tag"`
+				} `json:"fields"`
+			}
+			decodeJSON(t, rec, &body)
+			got := make(map[string]string)
+			for _, f := range body.Fields {
+				got[f.Field] = f.Tag
+			}
+			if body.Error != "Validation failed" || !maps.Equal(got, tt.want) {
+				t.Errorf("body = %s, want 字段错误 %v", rec.Body, tt.want)
+			}
+		})
+	}
+
+	t.Run("不是 multipart 表单", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(`{"title":"t"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if rec := serve(e, req); rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+}
+
+// ====== 文件下载 ======
+
+func TestDownloadHandler(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "downloads")
+	if err := os.Mkdir(base, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "0123456789abcdefghij"
+	for name, data := range map[string]string{
+		filepath.Join(base, "report.txt"):    content,
+		filepath.Join(base, "报告 \"v1\".txt"): "x",
+		filepath.Join(root, "secret.txt"):    "top secret",
+	} {
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := echo.New()
+	e.GET("/download/*", DownloadHandler(base))
+	do := func(target string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("完整下载", func(t *testing.T) {
+		rec := do("/download/report.txt", nil)
+		if rec.Code != http.StatusOK || rec.Body.String() != content {
+			t.Fatalf("status = %d, body = %q", rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename=report.txt` {
+			t.Errorf("Content-Disposition = %q", got)
+		}
+		if rec.Header().Get("ETag") == "" {
+			t.Error("缺少 ETag")
+		}
+	})
+
+	t.Run("Range 断点续传", func(t *testing.T) {
+		rec := do("/download/report.txt", map[string]string{"Range": "bytes=10-14"})
+		if rec.Code != http.StatusPartialContent || rec.Body.String() != "abcde" {
+			t.Errorf("status = %d, body = %q, want 206 abcde", rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Range"); got != "bytes 10-14/20" {
+			t.Errorf("Content-Range = %q, want bytes 10-14/20", got)
+		}
+	})
+
+	t.Run("ETag 未变化返回 304", func(t *testing.T) {
+		etag := do("/download/report.txt", nil).Header().Get("ETag")
+		rec := do("/download/report.txt", map[string]string{"If-None-Match": etag})
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("status = %d, body = %q, want 304 且无正文", rec.Code, rec.Body.String())
+		}
+		// If-Range 不匹配时忽略 Range，返回完整内容
+		rec = do("/download/report.txt", map[string]string{"Range": "bytes=0-1", "If-Range": `"stale"`})
+		if rec.Code != http.StatusOK || rec.Body.String() != content {
+			t.Errorf("If-Range 不匹配时 status = %d, body = %q, want 200 完整内容", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("文件名编码", func(t *testing.T) {
+		rec := do("/download/"+url.PathEscape(`报告 "v1".txt`), nil)
+		_, params, err := mime.ParseMediaType(rec.Header().Get("Content-Disposition"))
+		if err != nil || params["filename"] != `报告 "v1".txt` {
+			t.Errorf("Content-Disposition = %q, 解析出 filename = %q, %v",
+				rec.Header().Get("Content-Disposition"), params["filename"], err)
+		}
+	})
+
+	errTests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"路径穿越", "/download/..%2fsecret.txt", http.StatusForbidden},
+		{"多级路径穿越", "/download/a%2f..%2f..%2fsecret.txt", http.StatusForbidden},
+		{"文件不存在", "/download/missing.txt", http.StatusNotFound},
+		{"空文件名", "/download/", http.StatusBadRequest},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.target, nil)
+			if rec.Code != tt.want {
+				t.Errorf("GET %s status = %d, want %d", tt.target, rec.Code, tt.want)
+			}
+			if strings.Contains(rec.Body.String(), "top secret") {
+				t.Error("泄露了 baseDir 之外的文件")
+			}
+		})
+	}
+}
+
+// ====== TLS 服务器 ======
+
+// writeSelfSignedCert 在临时目录生成 127.0.0.1 的自签名证书，返回证书、私钥文件路径和证书池
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成私钥失败: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成证书失败: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("编码私钥失败: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	cert, _ := x509.ParseCertificate(der)
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestStartTLSServer(t *testing.T) {
+	certFile, keyFile, pool := writeSelfSignedCert(t)
+	e := newTestEcho()
+	e.HideBanner, e.HidePort = true, true
+	e.GET("/ping", func(c echo.Context) error { return c.String(http.StatusOK, "pong") })
+
+	done := make(chan error, 1)
+	go func() { done <- StartTLSServer(e, "127.0.0.1:0", certFile, keyFile) }()
+	t.Cleanup(func() {
+		e.Shutdown(context.Background())
+		if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("StartTLSServer() error = %v, want http.ErrServerClosed", err)
+		}
+	})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for e.TLSListenerAddr() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("等待 TLS 服务器启动超时")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	url := "https://" + e.TLSListenerAddr().String() + "/ping"
+
+	// dial 使用给定的客户端 TLS 配置发起请求，h2 为 false 时客户端只支持 HTTP/1.1
+	dial := func(cfg *tls.Config, h2 bool) (*http.Response, error) {
+		cfg.RootCAs = pool
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, ForceAttemptHTTP2: h2}}
+		defer client.CloseIdleConnections()
+		resp, err := client.Get(url)
+		if err == nil {
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	t.Run("通过 ALPN 协商 HTTP/2", func(t *testing.T) {
+		resp, err := dial(&tls.Config{}, true)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Proto != "HTTP/2.0" {
+			t.Errorf("status = %d, proto = %s, want 200 HTTP/2.0", resp.StatusCode, resp.Proto)
+		}
+		if resp.TLS.Version != tls.VersionTLS13 {
+			t.Errorf("TLS 版本 = %s, want TLS 1.3", tls.VersionName(resp.TLS.Version))
+		}
+	})
+
+	t.Run("客户端不支持 HTTP/2 时回退到 HTTP/1.1", func(t *testing.T) {
+		resp, err := dial(&tls.Config{}, false)
+		if err != nil || resp.Proto != "HTTP/1.1" {
+			t.Errorf("resp = %v, err = %v, want HTTP/1.1", resp, err)
+		}
+	})
+
+	t.Run("TLS 1.2 使用前向保密套件", func(t *testing.T) {
+		resp, err := dial(&tls.Config{MaxVersion: tls.VersionTLS12}, true)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		if name := tls.CipherSuiteName(resp.TLS.CipherSuite); !strings.HasPrefix(name, "TLS_ECDHE_") {
+			t.Errorf("套件 = %s, want ECDHE", name)
+		}
+	})
+
+	rejected := []struct {
+		name string
+		cfg  *tls.Config
+	}{
+		{"拒绝 TLS 1.1", &tls.Config{MaxVersion: tls.VersionTLS11}},
+		{"拒绝没有前向保密的套件", &tls.Config{
+			MaxVersion:   tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA},
+		}},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := dial(tt.cfg, true); err == nil {
+				t.Error("握手应该失败")
+			}
+		})
+	}
+}
+
+func TestStartTLSServerMissingCert(t *testing.T) {
+	dir := t.TempDir()
+	err := StartTLSServer(newTestEcho(), "127.0.0.1:0", filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err == nil || !strings.Contains(err.Error(), "加载证书失败") {
+		t.Errorf("StartTLSServer() error = %v, want 加载证书失败", err)
+	}
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsPort string
+		method    string
+		target    string
+		wantCode  int
+		wantURL   string
+	}{
+		{"GET 使用 301", "", http.MethodGet, "http://example.com/a?b=1", http.StatusMovedPermanently, "https://example.com/a?b=1"},
+		{"去掉 HTTP 端口", "443", http.MethodGet, "http://example.com:80/a", http.StatusMovedPermanently, "https://example.com/a"},
+		{"非 443 端口保留", "8443", http.MethodHead, "http://example.com:8080/a", http.StatusMovedPermanently, "https://example.com:8443/a"},
+		{"POST 使用 308", "", http.MethodPost, "http://example.com/form", http.StatusPermanentRedirect, "https://example.com/form"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			HTTPSRedirectHandler(tt.httpsPort).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.wantCode || rec.Header().Get("Location") != tt.wantURL {
+				t.Errorf("status = %d, Location = %q, want %d %q", rec.Code, rec.Header().Get("Location"), tt.wantCode, tt.wantURL)
+			}
+		})
+	}
+}
@@ -4,12 +4,34 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/austoin/GolangTutorial/auth"
+	"github.com/austoin/GolangTutorial/decompress"
+	"github.com/austoin/GolangTutorial/flags"
+	"github.com/austoin/GolangTutorial/logger"
+	"github.com/austoin/GolangTutorial/paging"
+	"github.com/austoin/GolangTutorial/ratelimit"
+	"github.com/austoin/GolangTutorial/reqctx"
+	"github.com/austoin/GolangTutorial/verr"
 )
 
 // ====== Gin 框架基础 ======
@@ -31,10 +53,11 @@ Gin 是 Go 语言中最流行的 Web 框架之一。
 
 // User 用户模型
 type User struct {
-	ID       uint   `json:"id" binding:"required"`
-	Username string `json:"username" binding:"required,min=3,max=50"`
-	Email    string `json:"email" binding:"required,email"`
-	Age      int    `json:"age" binding:"gte=0,lte=150"`
+	ID        uint      `json:"id" binding:"required"`
+	Username  string    `json:"username" binding:"required,min=3,max=50"`
+	Email     string    `json:"email" binding:"required,email"`
+	Age       int       `json:"age" binding:"gte=0,lte=150"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Post 帖子模型
@@ -50,14 +73,25 @@ type Post struct {
 
 func setupRouter() *gin.Engine {
 	// 1. 创建 Gin 路由器
-	// gin.Default() 创建带有默认中间件的路由器
+	// gin.Default() 创建带有默认中间件（Logger + Recovery）的路由器
 	// gin.New() 创建不带中间件的路由器
-	router := gin.Default()
+	// 这里用 gin.New()，由下面手动注册的 RecoveryJSON 代替 gin.Recovery()
+	router := gin.New()
+
+	// 校验错误中使用 json 字段名，与 Echo 的错误格式保持一致
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		verr.RegisterJSONTagNames(v)
+	}
 
 	// 2. 配置全局中间件
 	// Logger 中间件：记录请求日志
-	// Recovery 中间件：从 panic 中恢复
-	router.Use(gin.Recovery())
+	// RecoveryJSON 中间件：从 panic 中恢复，返回 JSON 并用结构化日志记录堆栈
+	router.Use(gin.Logger(), RecoveryJSON(nil))
+	// 解压 gzip 请求体，绑定代码不需要修改
+	router.Use(decompress.GinMiddleware(decompress.DefaultMaxSize))
+	// 限流：每个 IP 每秒 10 个请求，允许突发 20 个
+	// 多实例部署时换成 ratelimit.NewRedisSlidingWindow
+	router.Use(RateLimitMiddleware(ratelimit.NewTokenBucket(10, 20)))
 
 	// 3. 健康检查路由
 	router.GET("/health", func(c *gin.Context) {
@@ -73,7 +107,9 @@ func setupRouter() *gin.Engine {
 	})
 
 	// 5. 路由分组 - API v1
-	v1 := router.Group("/api/v1")
+	// 幂等键中间件只对带 Idempotency-Key 的 POST/PUT/DELETE 生效
+	// 多实例部署时换成 NewRedisIdempotencyStore
+	v1 := router.Group("/api/v1", IdempotencyMiddleware(NewMemoryIdempotencyStore()))
 	{
 		// 用户相关路由
 		v1.POST("/users", createUser)
@@ -89,7 +125,9 @@ func setupRouter() *gin.Engine {
 	}
 
 	// 6. 路由分组 - API v2
-	v2 := router.Group("/api/v2")
+	// 由 api-v2 开关控制，关闭后 v2 接口返回 404
+	featureFlags.Set("api-v2", flags.Flag{Enabled: true, Percentage: 100})
+	v2 := router.Group("/api/v2", RequireFlag(featureFlags, "api-v2"))
 	{
 		v2.GET("/users", listUsersV2)
 		v2.GET("/users/:id", getUserV2)
@@ -105,13 +143,10 @@ func setupRouter() *gin.Engine {
 func createUser(c *gin.Context) {
 	// 1. 绑定 JSON 数据到结构体
 	// ShouldBind 自动验证 binding 标签
-	// 如果验证失败，返回 400 错误
+	// 如果验证失败，返回 400 错误（字段级错误见 respondBindError）
 	var user User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
@@ -194,16 +229,19 @@ func getUser(c *gin.Context) {
 // updateUser 更新用户
 // PUT /api/v1/users/:id
 func updateUser(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 
 	var user User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
+	// 以路径中的 ID 为准，忽略请求体中的 id
+	user.ID = uint(id)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User updated successfully",
@@ -222,6 +260,19 @@ func deleteUser(c *gin.Context) {
 	})
 }
 
+// respondBindError 返回绑定失败的 400 响应
+// 校验失败时返回字段级错误，其他错误（JSON 格式错误等）返回错误信息
+func respondBindError(c *gin.Context, err error) {
+	if fields := verr.FromValidator(err); fields != nil {
+		c.JSON(http.StatusBadRequest, fields.Response())
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Invalid request body",
+		"details": err.Error(),
+	})
+}
+
 // ====== 帖子相关路由 ======
 
 // createPost 创建帖子
@@ -229,10 +280,7 @@ func deleteUser(c *gin.Context) {
 func createPost(c *gin.Context) {
 	var post Post
 	if err := c.ShouldBindJSON(&post); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		respondBindError(c, err)
 		return
 	}
 
@@ -246,26 +294,40 @@ func createPost(c *gin.Context) {
 }
 
 // listPosts 获取帖子列表
-// GET /api/v1/posts
+// GET /api/v1/posts?page=1&page_size=10
 func listPosts(c *gin.Context) {
+	// 无效的分页参数按默认值处理
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	page, pageSize = paging.Clamp(page, pageSize)
+
 	posts := []Post{
 		{ID: 1, Title: "First Post", Content: "Hello World!", AuthorID: 1},
 		{ID: 2, Title: "Second Post", Content: "Gin is great", AuthorID: 2},
 	}
 
+	data, total, totalPages := paging.Page(posts, page, pageSize)
+
 	c.JSON(http.StatusOK, gin.H{
-		"data":  posts,
-		"total": 2,
+		"data":        data,
+		"page":        page,
+		"page_size":   pageSize,
+		"total":       total,
+		"total_pages": totalPages,
 	})
 }
 
 // getPost 获取单个帖子
 // GET /api/v1/posts/:id
 func getPost(c *gin.Context) {
-	id := c.Param("id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
 
 	c.JSON(http.StatusOK, Post{
-		ID:        1,
+		ID:        uint(id),
 		Title:     "First Post",
 		Content:   "Hello World!",
 		AuthorID:  1,
@@ -315,49 +377,592 @@ func LoggerMiddleware() gin.HandlerFunc {
 		// 请求处理完成后
 		duration := time.Since(start)
 
-		// 记录日志
-		gin.DefaultWriter.Write([]byte(
-			c.Request.Method + " " +
-				c.Request.URL.Path + " " +
-				c.Writer.Header().Get("Content-Type") + " " +
-				strconv.Itoa(c.Writer.Status()) + " " +
-				duration.String() + "\n",
-		))
+		// 记录结构化日志
+		logger.WithContext(c.Request.Context()).Info("request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"content_type", c.Writer.Header().Get("Content-Type"),
+			"status", c.Writer.Status(),
+			"duration", duration.String(),
+		)
+	}
+}
+
+// RecoveryJSON 恢复中间件
+// 捕获 panic，以 Error 级别记录 panic 值、堆栈和请求 ID，
+// 返回 {"code":500,"message":"internal error"}，不向客户端暴露堆栈
+// l 为 nil 时使用 logger 包的默认 Logger
+func RecoveryJSON(l *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+
+			// http.ErrAbortHandler 用于主动中断响应，交给 net/http 处理
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			ctx := c.Request.Context()
+			log := l
+			if log == nil {
+				log = logger.L()
+			}
+			requestID := logger.RequestIDFromContext(ctx)
+			if requestID == "" {
+				requestID = c.GetHeader("X-Request-ID")
+			}
+			log.ErrorContext(ctx, "panic recovered",
+				"panic", fmt.Sprint(r),
+				"request_id", requestID,
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"stack", string(debug.Stack()),
+			)
+
+			// 响应已经开始写出时无法再修改状态码，只中止后续处理器
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"code":    http.StatusInternalServerError,
+				"message": "internal error",
+			})
+		}()
+		c.Next()
 	}
 }
 
+// jwtSecret JWT 签名密钥
+// 实际项目中应从环境变量或配置中心读取
+var jwtSecret = []byte("change-me-in-production")
+
 // AuthMiddleware 认证中间件
+// 解析 Authorization: Bearer <token>，验证通过后设置 user_id 和 roles
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从 Header 获取 token
-		token := c.GetHeader("Authorization")
-
-		// 验证 token
-		if token == "" {
+		header := c.GetHeader("Authorization")
+		if header == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "Authorization token required",
 			})
 			return
 		}
 
+		// 必须是 Bearer 格式
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Authorization header must be Bearer token",
+			})
+			return
+		}
+
+		// 验证 token，按错误类型返回不同的提示
+		claims, err := auth.ParseToken(token, jwtSecret)
+		if err != nil {
+			message := "Invalid token"
+			if errors.Is(err, auth.ErrTokenExpired) {
+				message = "Token expired"
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": message,
+			})
+			return
+		}
+
 		// 验证通过，设置用户信息到上下文
-		c.Set("user_id", 1)
+		// 键名与 reqctx 一致，业务代码可以通过 reqctx.FromGin(c) 读取
+		c.Set(reqctx.KeyUserID, claims.UserID)
+		c.Set(reqctx.KeyRoles, claims.Roles)
+		c.Next()
+	}
+}
+
+// RequireRole 角色校验中间件
+// 必须放在 AuthMiddleware 之后使用
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles := c.GetStringSlice(reqctx.KeyRoles)
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "Insufficient permissions",
+		})
+	}
+}
+
+// featureFlags 功能开关，多实例部署时换成 flags.NewRedisStore
+var featureFlags = flags.NewMemoryStore()
+
+// RequireFlag 功能开关中间件，开关对当前用户关闭时返回 404
+// 放在 AuthMiddleware 之后时按登录用户灰度，否则只有全量开放的开关能通过
+func RequireFlag(store flags.Store, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := reqctx.FromGin(c)
+		userID, _ := reqctx.UserID(ctx)
+		if !store.IsEnabled(ctx, name, userID) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
 		c.Next()
 	}
 }
 
 // RateLimitMiddleware 限流中间件
-func RateLimitMiddleware() gin.HandlerFunc {
-	// 使用令牌桶算法实现限流
-	// 这里简化为固定计数
+// 按客户端 IP 限流，算法和存储由 limiter 决定（见 ratelimit 包）
+// 被拒绝时返回 429 并设置 Retry-After；限流器出错时放行，避免 Redis 故障导致整站不可用
+func RateLimitMiddleware(limiter ratelimit.Limiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 检查请求频率
-		// 实际实现可以使用 golang.org/x/time/rate
+		ctx := c.Request.Context()
+		allowed, retryAfter, err := limiter.Allow(ctx, c.ClientIP())
+		if err != nil {
+			logger.WithContext(ctx).Warn("限流器错误，放行请求", "err", err)
+			c.Next()
+			return
+		}
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "Too many requests",
+			})
+			return
+		}
 
 		c.Next()
 	}
 }
 
+// retryAfterSeconds Retry-After 以秒为单位，向上取整且至少为 1
+func retryAfterSeconds(d time.Duration) int {
+	return max(1, int((d+time.Second-1)/time.Second))
+}
+
+// ====== 幂等键中间件 ======
+/*
+表单重复提交、客户端超时重试都会导致重复创建资源。
+客户端为每个"逻辑请求"生成一个唯一的 Idempotency-Key，重试时使用同一个值：
+
+  POST /api/v1/users
+  Idempotency-Key: 7f3c0e9a-...
+
+处理流程：
+  1. 只处理非安全方法（POST、PUT、PATCH、DELETE），且请求带有 Idempotency-Key
+  2. 已有记录的响应：直接重放状态码和响应体，不再执行处理器
+  3. 没有记录：加锁后执行处理器，捕获响应并保存（带 TTL）
+  4. 同一个 key 已有请求在处理中：返回 409
+
+5xx 响应不保存，客户端可以使用同一个 key 重试。
+
+key 按调用方隔离：已认证的请求按用户 ID，匿名请求按客户端 IP。
+不同用户碰巧使用了相同的 key 时互不影响，也不会拿到别人的响应。
+需要按用户隔离时，必须放在 AuthMiddleware 之后。
+*/
+
+const (
+	idempotencyHeader  = "Idempotency-Key"
+	idempotencyTTL     = 24 * time.Hour   // 响应保存时间
+	idempotencyLockTTL = 30 * time.Second // 处理中锁的超时时间，防止进程崩溃后永久占用
+
+	idempotencySweepInterval = time.Minute // MemoryIdempotencyStore 清理过期记录的间隔
+)
+
+// IdempotentResponse 保存的响应
+type IdempotentResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// IdempotencyStore 幂等键存储
+type IdempotencyStore interface {
+	// Lock 占用 key，返回 false 表示已有请求在处理
+	// token 标识这一次占用，释放时原样传给 Unlock
+	Lock(ctx context.Context, key string, ttl time.Duration) (token string, ok bool, err error)
+	// Unlock 释放 key，只有 key 仍被 token 占用时才释放
+	// 锁超时后被其他请求重新占用，迟到的 Unlock 不会释放别人的锁
+	Unlock(ctx context.Context, key, token string) error
+	// Get 获取已保存的响应，不存在时返回 nil, nil
+	Get(ctx context.Context, key string) (*IdempotentResponse, error)
+	// Save 保存响应
+	Save(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error
+}
+
+// IdempotencyMiddleware 幂等键中间件
+func IdempotencyMiddleware(store IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" || isSafeMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		// 同一个 key 只在同一个调用方、同一个接口内有效
+		key = idempotencyScope(c) + " " + c.Request.Method + " " + c.Request.URL.Path + " " + key
+		ctx := c.Request.Context()
+
+		// 1. 已有响应，直接重放
+		if replayIdempotentResponse(c, store, key) {
+			return
+		}
+
+		// 2. 加锁，防止并发的相同请求同时执行
+		token, locked, err := store.Lock(ctx, key, idempotencyLockTTL)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Idempotency store unavailable",
+			})
+			return
+		}
+		if !locked {
+			// 加锁失败时前一个请求可能刚好完成，再检查一次
+			if replayIdempotentResponse(c, store, key) {
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "A request with this Idempotency-Key is already in progress",
+			})
+			return
+		}
+		defer store.Unlock(context.WithoutCancel(ctx), key, token)
+
+		// 3. 执行处理器并捕获响应
+		writer := &bodyCaptureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		resp := &IdempotentResponse{
+			Status:      status,
+			ContentType: c.Writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}
+		if err := store.Save(context.WithoutCancel(ctx), key, resp, idempotencyTTL); err != nil {
+			logger.WithContext(ctx).Warn("保存幂等响应失败", "key", key, "err", err)
+		}
+	}
+}
+
+// idempotencyScope 幂等键的调用方范围
+// AuthMiddleware 设置了用户 ID 时为 "user:<id>"，否则为 "ip:<客户端 IP>"
+func idempotencyScope(c *gin.Context) string {
+	if id, ok := c.Get(reqctx.KeyUserID); ok {
+		return fmt.Sprintf("user:%v", id)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// replayIdempotentResponse 重放已保存的响应，返回是否已重放
+func replayIdempotentResponse(c *gin.Context, store IdempotencyStore, key string) bool {
+	resp, err := store.Get(c.Request.Context(), key)
+	if err != nil || resp == nil {
+		return false
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(resp.Status, resp.ContentType, resp.Body)
+	c.Abort()
+	return true
+}
+
+// isSafeMethod 判断是否为安全方法（不修改资源）
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// bodyCaptureWriter 在写出响应的同时保存一份响应体
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// MemoryIdempotencyStore 内存实现，适合单实例和测试
+// 过期的锁和响应由后台协程定期删除，不再使用时调用 Stop
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	locks     map[string]memoryIdempotentLock
+	responses map[string]memoryIdempotentEntry
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type memoryIdempotentLock struct {
+	token    string
+	expireAt time.Time
+}
+
+type memoryIdempotentEntry struct {
+	resp     *IdempotentResponse
+	expireAt time.Time
+}
+
+// NewMemoryIdempotencyStore 创建内存存储
+// 会启动一个后台协程定期清理过期记录，使用 Stop 停止
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	s := &MemoryIdempotencyStore{
+		locks:     make(map[string]memoryIdempotentLock),
+		responses: make(map[string]memoryIdempotentEntry),
+		stop:      make(chan struct{}),
+	}
+	go s.janitor(idempotencySweepInterval)
+	return s
+}
+
+// Stop 停止后台清理协程
+func (s *MemoryIdempotencyStore) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// janitor 定期删除过期的锁和响应
+// 没有清理时，只写入、不再读取的 key 会一直留在内存中
+func (s *MemoryIdempotencyStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.evictExpired(now)
+		}
+	}
+}
+
+// evictExpired 删除在 now 之前过期的锁和响应
+func (s *MemoryIdempotencyStore) evictExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, lock := range s.locks {
+		if !now.Before(lock.expireAt) {
+			delete(s.locks, key)
+		}
+	}
+	for key, entry := range s.responses {
+		if now.After(entry.expireAt) {
+			delete(s.responses, key)
+		}
+	}
+}
+
+// Lock 占用 key，未过期的锁存在时返回 false
+func (s *MemoryIdempotencyStore) Lock(ctx context.Context, key string, ttl time.Duration) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if lock, ok := s.locks[key]; ok && now.Before(lock.expireAt) {
+		return "", false, nil
+	}
+	token := rand.Text()
+	s.locks[key] = memoryIdempotentLock{token: token, expireAt: now.Add(ttl)}
+	return token, true, nil
+}
+
+// Unlock 释放 key，key 未被占用或已被其他 token 占用时什么也不做
+func (s *MemoryIdempotencyStore) Unlock(ctx context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lock, ok := s.locks[key]; ok && lock.token == token {
+		delete(s.locks, key)
+	}
+	return nil
+}
+
+// Get 获取已保存的响应，不存在或已过期时返回 nil, nil
+func (s *MemoryIdempotencyStore) Get(ctx context.Context, key string) (*IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.responses[key]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(entry.expireAt) {
+		delete(s.responses, key)
+		return nil, nil
+	}
+	return entry.resp, nil
+}
+
+// Save 保存响应，ttl 后过期，已有响应会被覆盖
+func (s *MemoryIdempotencyStore) Save(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[key] = memoryIdempotentEntry{resp: resp, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// RedisIdempotencyStore Redis 实现，适合多实例部署
+type RedisIdempotencyStore struct {
+	client *redis.Client
+}
+
+// NewRedisIdempotencyStore 创建 Redis 存储
+func NewRedisIdempotencyStore(client *redis.Client) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{client: client}
+}
+
+// Lock 使用 SET NX 占用 key，值为随机令牌，锁在 ttl 后自动过期
+func (s *RedisIdempotencyStore) Lock(ctx context.Context, key string, ttl time.Duration) (string, bool, error) {
+	token := rand.Text()
+	ok, err := s.client.SetNX(ctx, "idem:lock:"+key, token, ttl).Result()
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return token, true, nil
+}
+
+// idempotencyUnlockScript 值等于令牌时才删除锁
+// GET 和 DEL 分两次执行时，两次之间锁可能过期并被其他请求占用
+var idempotencyUnlockScript = redis.NewScript(`
+	if redis.call("get", KEYS[1]) == ARGV[1] then
+		return redis.call("del", KEYS[1])
+	end
+	return 0
+`)
+
+// Unlock 锁仍由 token 占用时删除锁
+func (s *RedisIdempotencyStore) Unlock(ctx context.Context, key, token string) error {
+	return idempotencyUnlockScript.Run(ctx, s.client, []string{"idem:lock:" + key}, token).Err()
+}
+
+// Get 获取已保存的响应（JSON），不存在时返回 nil, nil
+func (s *RedisIdempotencyStore) Get(ctx context.Context, key string) (*IdempotentResponse, error) {
+	data, err := s.client.Get(ctx, "idem:result:"+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp IdempotentResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Save 以 JSON 保存响应，由 Redis 在 ttl 后删除
+func (s *RedisIdempotencyStore) Save(ctx context.Context, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, "idem:result:"+key, data, ttl).Err()
+}
+
+// ====== Server-Sent Events ======
+/*
+SSE 是基于普通 HTTP 响应的单向推送：服务端保持连接不关闭，持续写入文本帧，
+浏览器用 EventSource 接收，断线后会自动重连。
+
+每一帧的格式（以空行结束）：
+
+  event: user_created
+  data: {"id":1}
+
+  : keepalive        ← 冒号开头是注释，客户端忽略，用于保持连接
+
+使用方式：
+
+  router.GET("/events", func(c *gin.Context) {
+      events := make(chan SSEEvent)
+      go produce(c.Request.Context(), events) // 生产者负责在结束时 close(events)
+      SSE(c, events)
+  })
+
+注意：
+  - 代理（如 Nginx）默认会缓冲响应，X-Accel-Buffering: no 关闭缓冲
+  - 没有数据时定期发送注释帧，避免连接被代理/负载均衡器当成空闲断开
+  - 生产者应同样监听 c.Request.Context()，客户端断开后停止生产
+*/
+
+// sseKeepAliveInterval 没有事件时发送注释帧的间隔
+const sseKeepAliveInterval = 15 * time.Second
+
+// SSEEvent 一条服务端推送事件
+type SSEEvent struct {
+	Event string // 事件名，为空时客户端按默认的 message 事件处理
+	Data  string // 多行数据会拆成多个 data: 行
+}
+
+// SSE 把 source 中的事件以 text/event-stream 格式推送给客户端
+// source 被关闭或客户端断开连接时返回
+func SSE(c *gin.Context, source <-chan SSEEvent) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	// 立即发送响应头，客户端不必等到第一个事件才确认连接已建立
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+	done := c.Request.Context().Done()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-done:
+			return false
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case ev, ok := <-source:
+			if !ok {
+				return false
+			}
+			return writeSSEEvent(w, ev) == nil
+		}
+	})
+}
+
+// writeSSEEvent 写入一帧事件
+func writeSSEEvent(w io.Writer, ev SSEEvent) error {
+	var buf bytes.Buffer
+	if ev.Event != "" {
+		buf.WriteString("event: " + ev.Event + "\n")
+	}
+	for _, line := range strings.Split(ev.Data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
 // ====== 静态文件服务 ======
 
 func staticFileHandler(router *gin.Engine) {
@@ -463,13 +1068,21 @@ func main() {
 
 	// 7. 添加中间件到特定路由
 	router.GET("/protected", AuthMiddleware(), func(c *gin.Context) {
-		userID, _ := c.Get("user_id")
+		// 转换为标准 context 后读取，业务代码不依赖 Gin
+		userID, _ := reqctx.UserID(reqctx.FromGin(c))
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Protected content",
 			"user_id": userID,
 		})
 	})
 
+	// 需要 admin 角色的路由
+	router.GET("/admin", AuthMiddleware(), RequireRole("admin"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Admin content",
+		})
+	})
+
 	// 8. 启动服务器
 	// gin.Run() 等同于 http.ListenAndServe(":8080", router)
 	router.Run(":8080")
@@ -0,0 +1,510 @@
+// web/web_gin_test.go
+// Gin 示例的测试
+//
+// web 目录下每个文件都是独立的示例程序（各有一个 main），需要按文件运行：
+//   go test web/web_gin.go web/web_gin_test.go
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/austoin/GolangTutorial/auth"
+	"github.com/austoin/GolangTutorial/logger"
+	"github.com/austoin/GolangTutorial/testfixtures"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// testToken 用示例的 jwtSecret 签发令牌
+func testToken(t *testing.T, userID uint, ttl time.Duration, roles ...string) string {
+	t.Helper()
+	token, err := auth.GenerateToken(auth.Claims{UserID: userID, Roles: roles}, jwtSecret, ttl)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	return token
+}
+
+// serve 发送请求并返回响应
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// errorMessage 取出 {"error": "..."} 中的消息
+func errorMessage(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("响应不是 JSON: %q", rec.Body.String())
+	}
+	return body.Error
+}
+
+// ====== 中间件示例 ======
+
+// recoveryRouter 使用 RecoveryJSON 的路由，日志写入返回的缓冲区
+func recoveryRouter(t *testing.T) (*gin.Engine, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	r := gin.New()
+	r.Use(RecoveryJSON(slog.New(slog.NewJSONHandler(&buf, nil))))
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	r.GET("/partial", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		panic("after write")
+	}, func(c *gin.Context) {
+		t.Error("panic 之后的处理器不应该执行")
+	})
+	r.GET("/abort", func(c *gin.Context) { panic(http.ErrAbortHandler) })
+	return r, &buf
+}
+
+func TestRecoveryJSON(t *testing.T) {
+	r, buf := recoveryRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := serve(r, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("响应不是 JSON: %q", rec.Body)
+	}
+	if body["code"] != float64(500) || body["message"] != "internal error" || len(body) != 2 {
+		t.Errorf("响应 = %v, want {code:500, message:internal error}", body)
+	}
+	if strings.Contains(rec.Body.String(), "goroutine") {
+		t.Error("响应中不应该包含堆栈")
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("日志不是一条 JSON: %q", buf)
+	}
+	want := map[string]any{
+		"level": "ERROR", "msg": "panic recovered", "panic": "boom",
+		"request_id": "req-42", "method": "GET", "path": "/panic",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("日志 %s = %v, want %v", k, entry[k], v)
+		}
+	}
+	if stack, _ := entry["stack"].(string); !strings.Contains(stack, "recoveryRouter") {
+		t.Errorf("日志中的堆栈 = %q, 应该包含 panic 的处理器", stack)
+	}
+}
+
+func TestRecoveryJSONAfterWrite(t *testing.T) {
+	r, buf := recoveryRouter(t)
+
+	// 响应已经写出，状态码和响应体保持不变，只中止后续处理器
+	rec := serve(r, httptest.NewRequest(http.MethodGet, "/partial", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "partial" {
+		t.Errorf("status = %d, body = %q, want 200 partial", rec.Code, rec.Body)
+	}
+	if !strings.Contains(buf.String(), `"panic":"after write"`) {
+		t.Errorf("日志 = %q, 应该记录 panic", buf)
+	}
+}
+
+func TestRecoveryJSONAbortHandler(t *testing.T) {
+	r, buf := recoveryRouter(t)
+
+	// http.ErrAbortHandler 重新 panic，交给 net/http 中断连接
+	defer func() {
+		if got := recover(); got != http.ErrAbortHandler {
+			t.Errorf("recover() = %v, want http.ErrAbortHandler", got)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("ErrAbortHandler 不应该记录日志, got %q", buf)
+		}
+	}()
+	serve(r, httptest.NewRequest(http.MethodGet, "/abort", nil))
+}
+
+func TestRecoveryJSONDefaultLogger(t *testing.T) {
+	saved := logger.L()
+	t.Cleanup(func() { logger.SetDefault(saved) })
+	var buf bytes.Buffer
+	logger.SetDefault(logger.New(logger.Config{Format: "json", Output: &buf}))
+
+	r := gin.New()
+	r.Use(RecoveryJSON(nil))
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	serve(r, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if !strings.Contains(buf.String(), `"msg":"panic recovered"`) {
+		t.Errorf("默认 Logger 的输出 = %q, 应该记录 panic", buf.String())
+	}
+}
+
+func TestSetupRouterSingleRecovery(t *testing.T) {
+	router := setupRouter()
+
+	// 全局中间件中只有 RecoveryJSON 一个恢复中间件，没有 gin.Default() 带的 gin.Recovery()
+	var recoveries []string
+	for _, h := range router.Handlers {
+		name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+		if strings.Contains(name, "Recovery") {
+			recoveries = append(recoveries, name)
+		}
+	}
+	if len(recoveries) != 1 || !strings.Contains(recoveries[0], ".RecoveryJSON.") {
+		t.Errorf("恢复中间件 = %v, want 只有 RecoveryJSON", recoveries)
+	}
+}
+
+// ====== 认证与角色 ======
+
+func TestAuthMiddlewareAndRequireRole(t *testing.T) {
+	router := gin.New()
+	router.GET("/admin", AuthMiddleware(), RequireRole("admin"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("user_id")})
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+		wantErr  string
+	}{
+		{"缺少令牌", "", http.StatusUnauthorized, "Authorization token required"},
+		{"不是 Bearer", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, "Authorization header must be Bearer token"},
+		{"令牌无效", "Bearer not-a-jwt", http.StatusUnauthorized, "Invalid token"},
+		{"令牌过期", "Bearer " + testToken(t, 1, -time.Minute, "admin"), http.StatusUnauthorized, "Token expired"},
+		{"缺少角色", "Bearer " + testToken(t, 2, time.Hour, "editor"), http.StatusForbidden, "Insufficient permissions"},
+		{"拥有角色", "Bearer " + testToken(t, 3, time.Hour, "editor", "admin"), http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := serve(router, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("状态码 = %d, want %d（%s）", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantErr != "" {
+				if got := errorMessage(t, rec); got != tt.wantErr {
+					t.Errorf("error = %q, want %q", got, tt.wantErr)
+				}
+				return
+			}
+			var body struct {
+				UserID uint `json:"user_id"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if body.UserID != 3 {
+				t.Errorf("user_id = %d, want 3", body.UserID)
+			}
+		})
+	}
+}
+
+// ====== 幂等键中间件 ======
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	t.Cleanup(store.Stop)
+
+	var calls atomic.Int64
+	router := gin.New()
+	handler := func(c *gin.Context) {
+		n := calls.Add(1)
+		if c.Query("fail") != "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "try later"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"call": n})
+	}
+	router.POST("/anon", IdempotencyMiddleware(store), handler)
+	router.GET("/anon", IdempotencyMiddleware(store), handler)
+	router.POST("/auth", AuthMiddleware(), IdempotencyMiddleware(store), handler)
+
+	do := func(method, target, key, token, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set(idempotencyHeader, key)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.RemoteAddr = ip + ":1234"
+		return serve(router, req)
+	}
+
+	t.Run("重复请求重放第一次的响应", func(t *testing.T) {
+		calls.Store(0)
+		first := do(http.MethodPost, "/anon", "k1", "", "10.0.0.1")
+		second := do(http.MethodPost, "/anon", "k1", "", "10.0.0.1")
+		if calls.Load() != 1 {
+			t.Errorf("处理器执行了 %d 次, want 1", calls.Load())
+		}
+		if second.Code != first.Code || second.Body.String() != first.Body.String() {
+			t.Errorf("重放 = %d %s, want %d %s", second.Code, second.Body.String(), first.Code, first.Body.String())
+		}
+		if second.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+			t.Error("只有重放的响应应带 Idempotent-Replayed")
+		}
+	})
+
+	t.Run("不同客户端 IP 互不影响", func(t *testing.T) {
+		calls.Store(0)
+		do(http.MethodPost, "/anon", "shared", "", "10.0.0.1")
+		rec := do(http.MethodPost, "/anon", "shared", "", "10.0.0.2")
+		if calls.Load() != 2 || rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("处理器执行了 %d 次, want 2（另一个 IP 不应拿到重放的响应）", calls.Load())
+		}
+	})
+
+	t.Run("已认证请求按用户隔离", func(t *testing.T) {
+		calls.Store(0)
+		alice, bob := testToken(t, 1, time.Hour), testToken(t, 2, time.Hour)
+		do(http.MethodPost, "/auth", "shared", alice, "10.0.0.1")
+		// 同一用户换了 IP 仍然重放
+		if rec := do(http.MethodPost, "/auth", "shared", alice, "10.0.0.9"); rec.Header().Get("Idempotent-Replayed") != "true" {
+			t.Error("同一用户的重复请求没有重放")
+		}
+		// 另一个用户在同一 IP 上使用相同的 key
+		if rec := do(http.MethodPost, "/auth", "shared", bob, "10.0.0.1"); rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Error("不同用户拿到了别人的响应")
+		}
+		if calls.Load() != 2 {
+			t.Errorf("处理器执行了 %d 次, want 2", calls.Load())
+		}
+	})
+
+	t.Run("不适用的请求直接执行", func(t *testing.T) {
+		calls.Store(0)
+		do(http.MethodPost, "/anon", "", "", "10.0.0.1")
+		do(http.MethodPost, "/anon", "", "", "10.0.0.1")
+		do(http.MethodGet, "/anon", "k-get", "", "10.0.0.1")
+		do(http.MethodGet, "/anon", "k-get", "", "10.0.0.1")
+		if calls.Load() != 4 {
+			t.Errorf("处理器执行了 %d 次, want 4（没有 key 或安全方法不做幂等处理）", calls.Load())
+		}
+	})
+
+	t.Run("5xx 响应不保存", func(t *testing.T) {
+		calls.Store(0)
+		do(http.MethodPost, "/anon?fail=1", "k5xx", "", "10.0.0.1")
+		rec := do(http.MethodPost, "/anon?fail=1", "k5xx", "", "10.0.0.1")
+		if calls.Load() != 2 || rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("处理器执行了 %d 次, want 2（5xx 后可以用同一个 key 重试）", calls.Load())
+		}
+	})
+
+	t.Run("处理中的相同请求返回 409", func(t *testing.T) {
+		key := "ip:10.0.0.1 POST /anon busy"
+		token, ok, _ := store.Lock(context.Background(), key, time.Minute)
+		if !ok {
+			t.Fatal("Lock() = false")
+		}
+		defer store.Unlock(context.Background(), key, token)
+		if rec := do(http.MethodPost, "/anon", "busy", "", "10.0.0.1"); rec.Code != http.StatusConflict {
+			t.Errorf("status = %d, want 409", rec.Code)
+		}
+	})
+}
+
+// idempotencyTestLockTTL 测试中会过期的锁的 TTL
+const idempotencyTestLockTTL = 50 * time.Millisecond
+
+func TestIdempotencyStoreUnlockOwnership(t *testing.T) {
+	stores := map[string]func(t *testing.T) (store IdempotencyStore, expire func()){
+		"Memory": func(t *testing.T) (IdempotencyStore, func()) {
+			s := NewMemoryIdempotencyStore()
+			t.Cleanup(s.Stop)
+			return s, func() { time.Sleep(2 * idempotencyTestLockTTL) }
+		},
+		"Redis": func(t *testing.T) (IdempotencyStore, func()) {
+			client, mr := testfixtures.NewTestRedis(t)
+			return NewRedisIdempotencyStore(client), func() { mr.FastForward(2 * idempotencyTestLockTTL) }
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store, expire := newStore(t)
+			ctx := context.Background()
+
+			slow, ok, err := store.Lock(ctx, "k", idempotencyTestLockTTL)
+			if !ok || err != nil {
+				t.Fatalf("Lock() = %v, %v, want 加锁成功", ok, err)
+			}
+			if _, ok, _ := store.Lock(ctx, "k", idempotencyTestLockTTL); ok {
+				t.Fatal("锁未过期时 Lock() 应该失败")
+			}
+
+			// 慢请求的锁过期后被另一个请求占用
+			expire()
+			fast, ok, err := store.Lock(ctx, "k", time.Minute)
+			if !ok || err != nil {
+				t.Fatalf("锁过期后 Lock() = %v, %v, want 加锁成功", ok, err)
+			}
+			if fast == slow {
+				t.Fatal("两次加锁的令牌相同")
+			}
+
+			// 慢请求迟到的 Unlock 不能释放别人的锁
+			if err := store.Unlock(ctx, "k", slow); err != nil {
+				t.Fatalf("Unlock(旧令牌) error = %v", err)
+			}
+			if _, ok, _ := store.Lock(ctx, "k", time.Minute); ok {
+				t.Error("旧令牌的 Unlock 释放了新请求的锁")
+			}
+
+			if err := store.Unlock(ctx, "k", fast); err != nil {
+				t.Fatalf("Unlock() error = %v", err)
+			}
+			if _, ok, _ := store.Lock(ctx, "k", time.Minute); !ok {
+				t.Error("持有者 Unlock 后 Lock() 应该成功")
+			}
+		})
+	}
+}
+
+func TestMemoryIdempotencyStoreEvictExpired(t *testing.T) {
+	s := NewMemoryIdempotencyStore()
+	defer s.Stop()
+	ctx := context.Background()
+
+	s.Lock(ctx, "lock-short", time.Second)
+	s.Lock(ctx, "lock-long", time.Hour)
+	s.Save(ctx, "resp-short", &IdempotentResponse{Status: 200}, time.Second)
+	s.Save(ctx, "resp-long", &IdempotentResponse{Status: 200}, time.Hour)
+
+	s.evictExpired(time.Now().Add(time.Minute))
+
+	s.mu.Lock()
+	_, shortLock := s.locks["lock-short"]
+	_, longLock := s.locks["lock-long"]
+	_, shortResp := s.responses["resp-short"]
+	_, longResp := s.responses["resp-long"]
+	s.mu.Unlock()
+	if shortLock || shortResp {
+		t.Error("过期的锁或响应没有被删除")
+	}
+	if !longLock || !longResp {
+		t.Error("未过期的锁或响应被删除")
+	}
+
+	s.Stop() // 重复停止不会 panic
+}
+
+// ====== Server-Sent Events ======
+
+// readSSEFrame 读取一帧事件（到空行为止），不含结尾的空行
+func readSSEFrame(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	var frame strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("读取事件失败: %v（已读 %q）", err, frame.String())
+		}
+		if line == "\n" {
+			return frame.String()
+		}
+		frame.WriteString(line)
+	}
+}
+
+func TestSSE(t *testing.T) {
+	events := make(chan SSEEvent)
+	returned := make(chan struct{})
+	router := gin.New()
+	router.GET("/events", func(c *gin.Context) {
+		defer close(returned)
+		SSE(c, events)
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	if got := resp.Header.Get("X-Accel-Buffering"); got != "no" {
+		t.Errorf("X-Accel-Buffering = %q, want no", got)
+	}
+
+	// 每一帧写入后立即刷新，客户端不需要等响应结束
+	body := bufio.NewReader(resp.Body)
+	events <- SSEEvent{Event: "user_created", Data: `{"id":1}`}
+	if got, want := readSSEFrame(t, body), "event: user_created\ndata: {\"id\":1}\n"; got != want {
+		t.Errorf("第 1 帧 = %q, want %q", got, want)
+	}
+	events <- SSEEvent{Data: "line1\nline2"}
+	if got, want := readSSEFrame(t, body), "data: line1\ndata: line2\n"; got != want {
+		t.Errorf("第 2 帧 = %q, want %q", got, want)
+	}
+
+	// 客户端断开后 SSE 返回，不再等待 source
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatal("客户端断开后 SSE 没有返回")
+	}
+}
+
+func TestSSESourceClosed(t *testing.T) {
+	events := make(chan SSEEvent, 1)
+	events <- SSEEvent{Event: "done", Data: "bye"}
+	close(events)
+
+	router := gin.New()
+	router.GET("/events", func(c *gin.Context) { SSE(c, events) })
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatalf("GET /events error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	// source 关闭后响应正常结束
+	body, _ := io.ReadAll(resp.Body)
+	if got, want := string(body), "event: done\ndata: bye\n\n"; got != want {
+		t.Errorf("响应体 = %q, want %q", got, want)
+	}
+}